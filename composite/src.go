@@ -0,0 +1,150 @@
+package composite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/containers/image/v5/internal/imagesource"
+	"github.com/containers/image/v5/internal/imagesource/impl"
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/internal/signature"
+	"github.com/containers/image/v5/transports"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+)
+
+// compositeImageSource presents an image, and signatures and attestations read from other locations, as a single ImageSource.
+type compositeImageSource struct {
+	impl.Compat
+
+	ref          compositeReference
+	image        private.ImageSource
+	signatures   private.ImageSource // May be the same object as image
+	attestations private.ImageSource // May be the same object as image
+	toClose      []private.ImageSource
+}
+
+// newImageSource returns an ImageSource for ref.
+func newImageSource(ctx context.Context, sys *types.SystemContext, ref compositeReference) (private.ImageSource, error) {
+	s := &compositeImageSource{ref: ref}
+	succeeded := false
+	defer func() {
+		if !succeeded {
+			_ = s.Close()
+		}
+	}()
+
+	open := func(componentRef types.ImageReference) (private.ImageSource, error) {
+		if componentRef == nil {
+			return s.image, nil
+		}
+		src, err := componentRef.NewImageSource(ctx, sys)
+		if err != nil {
+			return nil, fmt.Errorf("initializing source %s: %w", transports.ImageName(componentRef), err)
+		}
+		privateSrc := imagesource.FromPublic(src)
+		s.toClose = append(s.toClose, privateSrc)
+		return privateSrc, nil
+	}
+	var err error
+	if s.image, err = open(ref.image); err != nil {
+		return nil, err
+	}
+	if s.signatures, err = open(ref.signatures); err != nil {
+		return nil, err
+	}
+	if s.attestations, err = open(ref.attestations); err != nil {
+		return nil, err
+	}
+	s.Compat = impl.AddCompat(s)
+
+	succeeded = true
+	return s, nil
+}
+
+// Reference returns the reference used to set up this source, _as specified by the user_
+// (not as the image itself, or its underlying storage, claims).  This can be used e.g. to determine which public keys are trusted for this image.
+func (s *compositeImageSource) Reference() types.ImageReference {
+	return s.ref
+}
+
+// Close removes resources associated with an initialized ImageSource, if any.
+func (s *compositeImageSource) Close() error {
+	errs := []error{}
+	for _, src := range s.toClose {
+		if err := src.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// GetManifest returns the image's manifest along with its MIME type (which may be empty when it can't be determined but the manifest is available).
+// It may use a remote (= slow) service.
+// If instanceDigest is not nil, it contains a digest of the specific manifest instance to retrieve (when the primary manifest is a manifest list);
+// this never happens if the primary manifest is not a manifest list (e.g. if the source never returns manifest lists).
+func (s *compositeImageSource) GetManifest(ctx context.Context, instanceDigest *digest.Digest) ([]byte, string, error) {
+	return s.image.GetManifest(ctx, instanceDigest)
+}
+
+// HasThreadSafeGetBlob indicates whether GetBlob can be executed concurrently.
+func (s *compositeImageSource) HasThreadSafeGetBlob() bool {
+	return s.image.HasThreadSafeGetBlob()
+}
+
+// GetBlob returns a stream for the specified blob, and the blob’s size (or -1 if unknown).
+// The Digest field in BlobInfo is guaranteed to be provided, Size may be -1 and MediaType may be optionally provided.
+// May update BlobInfoCache, preferably after it knows for certain that a blob truly exists at a specific location.
+func (s *compositeImageSource) GetBlob(ctx context.Context, info types.BlobInfo, cache types.BlobInfoCache) (io.ReadCloser, int64, error) {
+	return s.image.GetBlob(ctx, info, cache)
+}
+
+// SupportsGetBlobAt() returns true if GetBlobAt (BlobChunkAccessor) is supported.
+func (s *compositeImageSource) SupportsGetBlobAt() bool {
+	return s.image.SupportsGetBlobAt()
+}
+
+// GetBlobAt returns a sequential channel of readers that contain data for the requested
+// blob chunks, and a channel that might get a single error value.
+// The specified chunks must be not overlapping and sorted by their offset.
+// The readers must be fully consumed, in the order they are returned, before blocking
+// to read the next chunk.
+// If the Length for the last chunk is set to math.MaxUint64, then it
+// fully fetches the remaining data from the offset to the end of the blob.
+func (s *compositeImageSource) GetBlobAt(ctx context.Context, info types.BlobInfo, chunks []private.ImageSourceChunk) (chan io.ReadCloser, chan error, error) {
+	return s.image.GetBlobAt(ctx, info, chunks)
+}
+
+// LayerInfosForCopy returns either nil (meaning the values in the manifest are fine), or updated values for the layer
+// blobsums that are listed in the image's manifest.  If values are returned, they should be used when using GetBlob()
+// to read the image's layers.
+// If instanceDigest is not nil, it contains a digest of the specific manifest instance to retrieve BlobInfos for
+// (when the primary manifest is a manifest list); this never happens if the primary manifest is not a manifest list
+// (e.g. if the source never returns manifest lists).
+// The Digest field is guaranteed to be provided; Size may be -1.
+// WARNING: The list may contain duplicates, and they are semantically relevant.
+func (s *compositeImageSource) LayerInfosForCopy(ctx context.Context, instanceDigest *digest.Digest) ([]types.BlobInfo, error) {
+	return s.image.LayerInfosForCopy(ctx, instanceDigest)
+}
+
+// GetSignaturesWithFormat returns the image's signatures.  It may use a remote (= slow) service.
+// If instanceDigest is not nil, it contains a digest of the specific manifest instance to retrieve signatures for
+// (when the primary manifest is a manifest list); this never happens if the primary manifest is not a manifest list
+// (e.g. if the source never returns manifest lists).
+func (s *compositeImageSource) GetSignaturesWithFormat(ctx context.Context, instanceDigest *digest.Digest) ([]signature.Signature, error) {
+	return s.signatures.GetSignaturesWithFormat(ctx, instanceDigest)
+}
+
+// GetAttestations returns the attestations attached to the image, as sigstore attachments.
+// If instanceDigest is not nil, it contains a digest of the specific manifest instance to retrieve attestations for
+// (when the primary manifest is a manifest list); this never happens if the primary manifest is not a manifest list
+// (e.g. if the source never returns manifest lists).
+func (s *compositeImageSource) GetAttestations(ctx context.Context, instanceDigest *digest.Digest) ([]signature.Sigstore, error) {
+	src, ok := s.attestations.(private.ImageSourceWithAttestations)
+	if !ok {
+		return nil, nil
+	}
+	return src.GetAttestations(ctx, instanceDigest)
+}
@@ -0,0 +1,107 @@
+package composite
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/internal/signature"
+	_ "github.com/containers/image/v5/oci/layout"
+	"github.com/containers/image/v5/pkg/blobinfocache/memory"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeDirComponent creates a dir: location containing files, and returns a reference to it.
+func writeDirComponent(t *testing.T, files map[string][]byte) types.ImageReference {
+	dir := t.TempDir()
+	files["version"] = []byte("Directory Transport Version: 1.1\n")
+	for name, contents := range files {
+		err := os.WriteFile(filepath.Join(dir, name), contents, 0o644)
+		require.NoError(t, err)
+	}
+	return parseComponentRef(t, "dir:"+dir)
+}
+
+// simpleSig returns a fake simple signing signature blob with contents.
+func simpleSig(contents string) []byte {
+	return append([]byte{0xA3}, contents...) // Looks like an OpenPGP compressed data packet
+}
+
+func TestImageSource(t *testing.T) {
+	manifestBlob := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json"}`)
+	blob := []byte("blob contents")
+	blobDigest := digest.FromBytes(blob)
+	imageRef := writeDirComponent(t, map[string][]byte{
+		"manifest.json":      manifestBlob,
+		blobDigest.Encoded(): blob,
+		"signature-1":        simpleSig("image signature"),
+	})
+	sigsRef := writeDirComponent(t, map[string][]byte{
+		"signature-1": simpleSig("separate signature 1"),
+		"signature-2": simpleSig("separate signature 2"),
+	})
+
+	for _, c := range []struct {
+		sigs     types.ImageReference
+		expected [][]byte
+	}{
+		{nil, [][]byte{simpleSig("image signature")}},
+		{sigsRef, [][]byte{simpleSig("separate signature 1"), simpleSig("separate signature 2")}},
+	} {
+		ref, err := NewReference(imageRef, c.sigs, nil)
+		require.NoError(t, err)
+		src, err := ref.NewImageSource(context.Background(), nil)
+		require.NoError(t, err)
+		defer src.Close()
+		assert.Equal(t, ref, src.Reference())
+
+		m, _, err := src.GetManifest(context.Background(), nil)
+		require.NoError(t, err)
+		assert.Equal(t, manifestBlob, m)
+		rc, _, err := src.GetBlob(context.Background(), types.BlobInfo{Digest: blobDigest, Size: -1}, memory.New())
+		require.NoError(t, err)
+		contents, err := io.ReadAll(rc)
+		rc.Close()
+		require.NoError(t, err)
+		assert.Equal(t, blob, contents)
+
+		sigs, err := src.GetSignatures(context.Background(), nil)
+		require.NoError(t, err)
+		assert.Equal(t, c.expected, sigs)
+
+		// dir: does not support attestations
+		attestations, err := src.(private.ImageSourceWithAttestations).GetAttestations(context.Background(), nil)
+		require.NoError(t, err)
+		assert.Empty(t, attestations)
+	}
+
+	// Failure to open a component
+	ref, err := NewReference(imageRef, parseComponentRef(t, "oci:"+filepath.Join(t.TempDir(), "does-not-exist")), nil)
+	require.NoError(t, err)
+	_, err = ref.NewImageSource(context.Background(), nil)
+	assert.Error(t, err)
+}
+
+// attestationSource is a private.ImageSourceWithAttestations which only implements GetAttestations.
+type attestationSource struct {
+	private.ImageSource
+	attestations []signature.Sigstore
+}
+
+func (s attestationSource) GetAttestations(ctx context.Context, instanceDigest *digest.Digest) ([]signature.Sigstore, error) {
+	return s.attestations, nil
+}
+
+func TestImageSourceGetAttestations(t *testing.T) {
+	attestation := signature.SigstoreFromComponents("application/vnd.dsse.envelope.v1+json", []byte("{}"), nil)
+	s := &compositeImageSource{attestations: attestationSource{attestations: []signature.Sigstore{attestation}}}
+	res, err := s.GetAttestations(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, []signature.Sigstore{attestation}, res)
+}
@@ -0,0 +1,218 @@
+package composite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/internal/image"
+	"github.com/containers/image/v5/transports"
+	"github.com/containers/image/v5/types"
+)
+
+func init() {
+	transports.Register(Transport)
+}
+
+// Transport is an ImageTransport for images composed from separately specified locations
+// of the image, its signatures, and its attestations.
+var Transport = compositeTransport{}
+
+const (
+	// componentSeparator separates components within a reference.
+	componentSeparator = ";"
+
+	componentImage        = "image"
+	componentSignatures   = "signatures"
+	componentAttestations = "attestations"
+)
+
+type compositeTransport struct{}
+
+func (t compositeTransport) Name() string {
+	return "composite"
+}
+
+// ParseReference converts a string, which should not start with the ImageTransport.Name prefix, into an ImageReference.
+func (t compositeTransport) ParseReference(reference string) (types.ImageReference, error) {
+	return ParseReference(reference)
+}
+
+// ValidatePolicyConfigurationScope checks that scope is a valid name for a signature.PolicyTransportScopes keys
+// (i.e. a valid PolicyConfigurationIdentity() or PolicyConfigurationNamespaces() return value).
+// It is acceptable to allow an invalid value which will never be matched, it can "only" cause user confusion.
+// scope passed to this function will not be "", that value is always allowed.
+func (t compositeTransport) ValidatePolicyConfigurationScope(scope string) error {
+	transportName, withinTransport, ok := strings.Cut(scope, ":")
+	if !ok || withinTransport == "" {
+		return fmt.Errorf("Invalid scope %q: expected transport:scope", scope)
+	}
+	transport := transports.Get(transportName)
+	if transport == nil {
+		return fmt.Errorf("Invalid scope %q: unknown transport %q", scope, transportName)
+	}
+	if transport.Name() == t.Name() {
+		return fmt.Errorf("Invalid scope %q: composite references can not be nested", scope)
+	}
+	return transport.ValidatePolicyConfigurationScope(withinTransport)
+}
+
+// compositeReference is an ImageReference for images composed from separate locations.
+type compositeReference struct {
+	image        types.ImageReference // The image (manifests and blobs)
+	signatures   types.ImageReference // The location of signatures; nil if they are read from image
+	attestations types.ImageReference // The location of attestations; nil if they are read from image
+}
+
+// ParseReference converts a string, which should not start with the ImageTransport.Name prefix, into a composite ImageReference.
+//
+// The reference consists of semicolon-separated name=reference components, where each reference includes a transport prefix:
+// image=… (required) specifies the location of the image, signatures=… the location to read signatures from,
+// and attestations=… the location to read attestations from. If signatures or attestations are not specified,
+// they are read from the image location.
+// Signatures and attestations are looked up for the manifest digest of the image, so their locations should typically
+// be specified by digest (e.g. docker://registry.example.com/signatures@sha256:…), to avoid reading the manifest from there.
+func ParseReference(ref string) (types.ImageReference, error) {
+	components := map[string]types.ImageReference{}
+	for _, component := range strings.Split(ref, componentSeparator) {
+		name, value, ok := strings.Cut(component, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid composite reference component %q, expected name=transport:reference", component)
+		}
+		switch name {
+		case componentImage, componentSignatures, componentAttestations:
+		default:
+			return nil, fmt.Errorf("unknown composite reference component %q", name)
+		}
+		if _, ok := components[name]; ok {
+			return nil, fmt.Errorf("duplicate composite reference component %q", name)
+		}
+		componentRef, err := parseComponent(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid composite reference component %q: %w", name, err)
+		}
+		components[name] = componentRef
+	}
+	return NewReference(components[componentImage], components[componentSignatures], components[componentAttestations])
+}
+
+// parseComponent parses a transport:reference value of a component of a composite reference.
+func parseComponent(value string) (types.ImageReference, error) {
+	// We can't use alltransports.ParseImageName, which imports this package.
+	transportName, withinTransport, ok := strings.Cut(value, ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid image name %q, expected colon-separated transport:reference", value)
+	}
+	transport := transports.Get(transportName)
+	if transport == nil {
+		return nil, fmt.Errorf("invalid image name %q, unknown transport %q", value, transportName)
+	}
+	return transport.ParseReference(withinTransport)
+}
+
+// NewReference returns a composite reference for imageRef, with signatures and attestations read from
+// signaturesRef and attestationsRef. signaturesRef and attestationsRef may be nil, in which case they are read from imageRef.
+func NewReference(imageRef, signaturesRef, attestationsRef types.ImageReference) (types.ImageReference, error) {
+	if imageRef == nil {
+		return nil, errors.New(`composite reference must specify an "image" component`)
+	}
+	for _, ref := range []types.ImageReference{imageRef, signaturesRef, attestationsRef} {
+		if ref == nil {
+			continue
+		}
+		if ref.Transport().Name() == Transport.Name() {
+			return nil, errors.New("composite references can not be nested")
+		}
+		if strings.Contains(ref.StringWithinTransport(), componentSeparator) {
+			return nil, fmt.Errorf("reference %q can not be a component of a composite reference, it contains %q",
+				transports.ImageName(ref), componentSeparator)
+		}
+	}
+	return compositeReference{image: imageRef, signatures: signaturesRef, attestations: attestationsRef}, nil
+}
+
+func (ref compositeReference) Transport() types.ImageTransport {
+	return Transport
+}
+
+// StringWithinTransport returns a string representation of the reference, which MUST be such that
+// reference.Transport().ParseReference(reference.StringWithinTransport()) returns an equivalent reference.
+// NOTE: The returned string is not promised to be equal to the original input to ParseReference;
+// e.g. default attribute values omitted by the user may be filled in the return value, or vice versa.
+// WARNING: Do not use the return value in the UI to describe an image, it does not contain the Transport().Name() prefix;
+// instead, see transports.ImageName().
+func (ref compositeReference) StringWithinTransport() string {
+	components := []string{componentImage + "=" + transports.ImageName(ref.image)}
+	if ref.signatures != nil {
+		components = append(components, componentSignatures+"="+transports.ImageName(ref.signatures))
+	}
+	if ref.attestations != nil {
+		components = append(components, componentAttestations+"="+transports.ImageName(ref.attestations))
+	}
+	return strings.Join(components, componentSeparator)
+}
+
+// DockerReference returns a Docker reference associated with this reference
+// (fully explicit, i.e. !reference.IsNameOnly, but reflecting user intent,
+// not e.g. after redirect or alias processing), or nil if unknown/not applicable.
+func (ref compositeReference) DockerReference() reference.Named {
+	return ref.image.DockerReference()
+}
+
+// PolicyConfigurationIdentity returns a string representation of the reference, suitable for policy lookup.
+// This MUST reflect user intent, not e.g. after processing of third-party redirects or aliases;
+// The value SHOULD be fully explicit about its semantics, with no hidden defaults, AND canonical
+// (i.e. various references with exactly the same semantics should return the same configuration identity)
+// It is fine for the return value to be equal to StringWithinTransport(), and it is desirable but
+// not required/guaranteed that it will be a valid input to Transport().ParseReference().
+// Returns "" if configuration identities for these references are not supported.
+func (ref compositeReference) PolicyConfigurationIdentity() string {
+	// The policy applies to the image; the locations of signatures and attestations are deliberately not included,
+	// so that they can be distributed separately without relaxing the policy for the image.
+	identity := ref.image.PolicyConfigurationIdentity()
+	if identity == "" {
+		return ""
+	}
+	return ref.image.Transport().Name() + ":" + identity
+}
+
+// PolicyConfigurationNamespaces returns a list of other policy configuration namespaces to search
+// for if explicit configuration for PolicyConfigurationIdentity() is not set.  The list will be processed
+// in order, terminating on first match, and an implicit "" is always checked at the end.
+// It is STRONGLY recommended for the first element, if any, to be a prefix of PolicyConfigurationIdentity(),
+// and each following element to be a prefix of the element preceding it.
+func (ref compositeReference) PolicyConfigurationNamespaces() []string {
+	res := []string{}
+	for _, ns := range ref.image.PolicyConfigurationNamespaces() {
+		res = append(res, ref.image.Transport().Name()+":"+ns)
+	}
+	return res
+}
+
+// NewImage returns a types.ImageCloser for this reference, possibly specialized for this ImageTransport.
+// The caller must call .Close() on the returned ImageCloser.
+// NOTE: If any kind of signature verification should happen, build an UnparsedImage from the value returned by NewImageSource,
+// verify that UnparsedImage, and convert it into a real Image via image.FromUnparsedImage.
+// WARNING: This may not do the right thing for a manifest list, see image.FromSource for details.
+func (ref compositeReference) NewImage(ctx context.Context, sys *types.SystemContext) (types.ImageCloser, error) {
+	return image.FromReference(ctx, sys, ref)
+}
+
+// NewImageSource returns a types.ImageSource for this reference.
+// The caller must call .Close() on the returned ImageSource.
+func (ref compositeReference) NewImageSource(ctx context.Context, sys *types.SystemContext) (types.ImageSource, error) {
+	return newImageSource(ctx, sys, ref)
+}
+
+// NewImageDestination returns a types.ImageDestination for this reference.
+// The caller must call .Close() on the returned ImageDestination.
+func (ref compositeReference) NewImageDestination(ctx context.Context, sys *types.SystemContext) (types.ImageDestination, error) {
+	return nil, errors.New(`"composite:" locations can only be read from, not written to`)
+}
+
+// DeleteImage deletes the named image from the registry, if supported.
+func (ref compositeReference) DeleteImage(ctx context.Context, sys *types.SystemContext) error {
+	return errors.New("Deleting images not implemented for composite: images")
+}
@@ -0,0 +1,136 @@
+package composite
+
+import (
+	"context"
+	"testing"
+
+	_ "github.com/containers/image/v5/directory"
+	_ "github.com/containers/image/v5/docker"
+	"github.com/containers/image/v5/transports"
+	"github.com/containers/image/v5/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransportName(t *testing.T) {
+	assert.Equal(t, "composite", Transport.Name())
+}
+
+func TestTransportParseReference(t *testing.T) {
+	for _, c := range []struct{ input, expected string }{
+		{"image=docker://busybox", "image=docker://busybox:latest"},
+		{"image=dir:/image;signatures=dir:/sigs", "image=dir:/image;signatures=dir:/sigs"},
+		{"attestations=dir:/att;signatures=dir:/sigs;image=dir:/image", "image=dir:/image;signatures=dir:/sigs;attestations=dir:/att"},
+	} {
+		ref, err := Transport.ParseReference(c.input)
+		require.NoError(t, err, c.input)
+		assert.Equal(t, c.expected, ref.StringWithinTransport(), c.input)
+		ref2, err := Transport.ParseReference(ref.StringWithinTransport())
+		require.NoError(t, err, c.input)
+		assert.Equal(t, ref, ref2, c.input)
+	}
+
+	for _, input := range []string{
+		"",
+		"signatures=dir:/sigs",              // No image
+		"image=dir:/image;",                 // Empty component
+		"image",                             // No value
+		"image=dir:/image;unknown=dir:/x",   // Unknown component
+		"image=dir:/image;image=dir:/other", // Duplicate component
+		"image=/image",                      // No transport
+		"image=unknown:/image",              // Unknown transport
+		"image=docker:busybox",              // Invalid reference within transport
+		"image=composite:image=dir:/image",  // Nested
+		"image=dir:/image;signatures=composite:xx", // Nested
+	} {
+		_, err := Transport.ParseReference(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestTransportValidatePolicyConfigurationScope(t *testing.T) {
+	for _, scope := range []string{
+		"docker:example.com",
+		"docker:example.com/ns/repo:tag",
+		"dir:/etc",
+	} {
+		err := Transport.ValidatePolicyConfigurationScope(scope)
+		assert.NoError(t, err, scope)
+	}
+
+	for _, scope := range []string{
+		"example.com",            // No transport
+		"docker:",                // Empty scope
+		"unknown:example.com",    // Unknown transport
+		"dir:relative/path",      // Invalid within transport
+		"composite:docker:a.com", // Nested
+	} {
+		err := Transport.ValidatePolicyConfigurationScope(scope)
+		assert.Error(t, err, scope)
+	}
+}
+
+// parseComponentRef parses a transport:reference value.
+func parseComponentRef(t *testing.T, value string) types.ImageReference {
+	ref, err := parseComponent(value)
+	require.NoError(t, err)
+	return ref
+}
+
+func TestNewReference(t *testing.T) {
+	image := parseComponentRef(t, "docker://example.com/ns/repo:tag")
+	sigs := parseComponentRef(t, "dir:/sigs")
+
+	ref, err := NewReference(image, sigs, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "composite:image=docker://example.com/ns/repo:tag;signatures=dir:/sigs", transports.ImageName(ref))
+
+	_, err = NewReference(nil, sigs, nil)
+	assert.Error(t, err)
+	_, err = NewReference(ref, nil, nil) // Nested
+	assert.Error(t, err)
+	_, err = NewReference(image, parseComponentRef(t, "dir:/a;b"), nil) // Contains the separator
+	assert.Error(t, err)
+}
+
+func TestReferenceDockerReference(t *testing.T) {
+	ref, err := NewReference(parseComponentRef(t, "docker://example.com/ns/repo:tag"), parseComponentRef(t, "dir:/sigs"), nil)
+	require.NoError(t, err)
+	dockerRef := ref.DockerReference()
+	require.NotNil(t, dockerRef)
+	assert.Equal(t, "example.com/ns/repo:tag", dockerRef.String())
+
+	ref, err = NewReference(parseComponentRef(t, "dir:/image"), nil, nil)
+	require.NoError(t, err)
+	assert.Nil(t, ref.DockerReference())
+}
+
+func TestReferencePolicyConfiguration(t *testing.T) {
+	ref, err := NewReference(parseComponentRef(t, "docker://example.com/ns/repo:tag"), parseComponentRef(t, "dir:/sigs"), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "docker:example.com/ns/repo:tag", ref.PolicyConfigurationIdentity())
+	assert.Equal(t, []string{
+		"docker:example.com/ns/repo",
+		"docker:example.com/ns",
+		"docker:example.com",
+		"docker:*.com",
+	}, ref.PolicyConfigurationNamespaces())
+	for _, scope := range append([]string{ref.PolicyConfigurationIdentity()}, ref.PolicyConfigurationNamespaces()...) {
+		err := Transport.ValidatePolicyConfigurationScope(scope)
+		assert.NoError(t, err, scope)
+	}
+}
+
+func TestReferenceNewImageDestination(t *testing.T) {
+	ref, err := NewReference(parseComponentRef(t, "dir:/image"), nil, nil)
+	require.NoError(t, err)
+	_, err = ref.NewImageDestination(context.Background(), nil)
+	assert.Error(t, err)
+}
+
+func TestReferenceDeleteImage(t *testing.T) {
+	ref, err := NewReference(parseComponentRef(t, "dir:/image"), nil, nil)
+	require.NoError(t, err)
+	err = ref.DeleteImage(context.Background(), nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,68 @@
+package copy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/containers/image/v5/signature"
+	"github.com/containers/image/v5/transports"
+	"github.com/containers/image/v5/types"
+	"github.com/sirupsen/logrus"
+)
+
+// BatchImage is a single image copied by Images.
+type BatchImage struct {
+	Source      types.ImageReference
+	Destination types.ImageReference
+}
+
+// BatchImageResult describes the outcome of copying a single BatchImage.
+type BatchImageResult struct {
+	Source         types.ImageReference
+	Destination    types.ImageReference
+	CopiedManifest []byte // The manifest written to the destination, as returned by Image; nil if the copy failed
+	Err            error  // nil if the copy succeeded
+}
+
+// Images copies each of images in turn, using policyContext and options as Image does,
+// e.g. to copy all images of a multi-image docker-archive (see the List method of docker/archive.Reader).
+//
+// If options.ContinueOnInstanceFailure is set, a failure to copy one of the images is not fatal: the remaining images
+// are copied, and if any of the images failed to copy, the returned error aggregates all of the failures.
+// Otherwise, Images stops at the first failure, and returns its error.
+// In both cases, the returned slice contains a result for every image Images has attempted to copy, in the order of images.
+//
+// Report* fields of options are updated by every copy, so they typically only describe the last copy;
+// fields appended to, like ReportInstanceFailures, accumulate records of all copies.
+func Images(ctx context.Context, policyContext *signature.PolicyContext, images []BatchImage, options *Options) ([]BatchImageResult, error) {
+	continueOnFailure := options != nil && options.ContinueOnInstanceFailure
+	res := make([]BatchImageResult, 0, len(images))
+	failures := []error{}
+	for i, img := range images {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		copiedManifest, err := Image(ctx, policyContext, img.Destination, img.Source, options)
+		if err != nil {
+			err = fmt.Errorf("copying image %d/%d from %s: %w", i+1, len(images), transports.ImageName(img.Source), err)
+		}
+		res = append(res, BatchImageResult{
+			Source:         img.Source,
+			Destination:    img.Destination,
+			CopiedManifest: copiedManifest,
+			Err:            err,
+		})
+		if err != nil {
+			if !continueOnFailure {
+				return res, err
+			}
+			logrus.Warnf("Skipping image %s: %v", transports.ImageName(img.Source), err)
+			failures = append(failures, err)
+		}
+	}
+	if len(failures) != 0 {
+		return res, fmt.Errorf("%d of %d images failed to copy: %w", len(failures), len(images), errors.Join(failures...))
+	}
+	return res, nil
+}
@@ -0,0 +1,95 @@
+package copy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containers/image/v5/directory"
+	"github.com/containers/image/v5/docker/archive"
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImages(t *testing.T) {
+	ctx := context.Background()
+	policyContext := newInsecureAcceptAnythingPolicyContext(t)
+
+	// A multi-image docker-archive.
+	archivePath := filepath.Join(t.TempDir(), "archive.tar")
+	writer, err := archive.NewWriter(nil, archivePath)
+	require.NoError(t, err)
+	for i, tag := range []string{"example.com/first:latest", "example.com/second:latest"} {
+		srcDir, _ := createDirImage(t, []byte("layer "+tag))
+		srcRef, err := directory.NewReference(srcDir)
+		require.NoError(t, err)
+		named, err := reference.ParseNormalizedNamed(tag)
+		require.NoError(t, err)
+		destRef, err := writer.NewReference(named.(reference.NamedTagged))
+		require.NoError(t, err)
+		_, err = Image(ctx, policyContext, destRef, srcRef, &Options{})
+		require.NoError(t, err, i)
+	}
+	err = writer.Close()
+	require.NoError(t, err)
+	reader, err := archive.NewReader(nil, archivePath)
+	require.NoError(t, err)
+	defer reader.Close()
+	archiveRefs, err := reader.List()
+	require.NoError(t, err)
+	require.Len(t, archiveRefs, 2)
+
+	// An image with a missing layer.
+	brokenLayer := []byte("missing layer")
+	brokenDir, _ := createDirImage(t, brokenLayer)
+	err = os.Remove(filepath.Join(brokenDir, digest.FromBytes(brokenLayer).Encoded()))
+	require.NoError(t, err)
+	brokenRef, err := directory.NewReference(brokenDir)
+	require.NoError(t, err)
+
+	batch := func() []BatchImage {
+		res := []BatchImage{}
+		for _, src := range []types.ImageReference{archiveRefs[0][0], brokenRef, archiveRefs[1][0]} {
+			dest, err := directory.NewReference(t.TempDir())
+			require.NoError(t, err)
+			res = append(res, BatchImage{Source: src, Destination: dest})
+		}
+		return res
+	}
+
+	// By default, the copy stops at the first failure.
+	results, err := Images(ctx, policyContext, batch(), &Options{})
+	assert.Error(t, err)
+	require.Len(t, results, 2)
+	assert.NoError(t, results[0].Err)
+	assert.NotNil(t, results[0].CopiedManifest)
+	assert.Error(t, results[1].Err)
+	assert.Nil(t, results[1].CopiedManifest)
+
+	// With ContinueOnInstanceFailure, the other images are copied, and failures are aggregated.
+	images := batch()
+	results, err = Images(ctx, policyContext, images, &Options{ContinueOnInstanceFailure: true})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, results[1].Err)
+	require.Len(t, results, 3)
+	for i, r := range results {
+		assert.Equal(t, images[i].Source, r.Source)
+		assert.Equal(t, images[i].Destination, r.Destination)
+		if i == 1 {
+			assert.Error(t, r.Err)
+		} else {
+			assert.NoError(t, r.Err)
+			assert.NotNil(t, r.CopiedManifest)
+		}
+	}
+
+	// Without failures, there is no error.
+	images = batch()
+	results, err = Images(ctx, policyContext, []BatchImage{images[0], images[2]}, &Options{ContinueOnInstanceFailure: true})
+	require.NoError(t, err)
+	assert.Len(t, results, 2)
+}
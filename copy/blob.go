@@ -23,7 +23,7 @@ func (ic *imageCopier) copyBlobFromStream(ctx context.Context, srcReader io.Read
 	// that pipeline is built by updating stream.
 	// === Input: srcReader
 	stream := sourceStream{
-		reader: srcReader,
+		reader: ic.c.governor.reader(ctx, ic.c.resources.downloadReader(srcReader)),
 		info:   srcInfo,
 	}
 
@@ -73,9 +73,8 @@ func (ic *imageCopier) copyBlobFromStream(ctx context.Context, srcReader io.Read
 	defer compressionStep.close()
 
 	// === Encrypt the stream for valid mediatypes if ociEncryptConfig provided
-	if decryptionStep.decrypting && toEncrypt {
-		// If nothing else, we can only set uploadedInfo.CryptoOperation to a single value.
-		// Before relaxing this, see the original pull request’s review if there are other reasons to reject this.
+	if decryptionStep.decrypting && toEncrypt && !ic.c.options.OciReencrypt {
+		// Without OciReencrypt, the user asked to decrypt the image, and storing this layer encrypted again would be surprising.
 		return types.BlobInfo{}, errors.New("Unable to support both decryption and encryption in the same copy")
 	}
 	encryptionStep, err := ic.blobPipelineEncryptionStep(&stream, toEncrypt, srcInfo, decryptionStep)
@@ -83,12 +82,12 @@ func (ic *imageCopier) copyBlobFromStream(ctx context.Context, srcReader io.Read
 		return types.BlobInfo{}, err
 	}
 
-	// === Report progress using the ic.c.options.Progress channel, if required.
-	if ic.c.options.Progress != nil && ic.c.options.ProgressInterval > 0 {
+	// === Report progress using the ic.c.progress channel, if required.
+	if ic.c.progress != nil {
 		progressReader := newProgressReader(
 			stream.reader,
-			ic.c.options.Progress,
-			ic.c.options.ProgressInterval,
+			ic.c.progress,
+			ic.c.progressInterval,
 			srcInfo,
 		)
 		defer progressReader.reportDone()
@@ -100,17 +99,25 @@ func (ic *imageCopier) copyBlobFromStream(ctx context.Context, srcReader io.Read
 		Cache:      ic.c.blobInfoCache,
 		IsConfig:   isConfig,
 		EmptyLayer: emptyLayer,
+		// If the stream is not modified, digestingReader already fails reading it if it does not match stream.info.Digest.
+		SkipDigestVerification: stream.info.Digest == srcInfo.Digest,
 	}
 	if !isConfig {
 		options.LayerIndex = &layerIndex
 	}
-	destBlob, err := ic.c.dest.PutBlobWithOptions(ctx, &errorAnnotationReader{stream.reader}, stream.info, options)
+	destBlob, err := ic.c.dest.PutBlobWithOptions(ctx, &errorAnnotationReader{ic.c.resources.uploadReader(stream.reader)}, stream.info, options)
 	if err != nil {
 		return types.BlobInfo{}, fmt.Errorf("writing blob: %w", err)
 	}
 	uploadedInfo := updatedBlobInfoFromUpload(stream.info, destBlob)
 
 	compressionStep.updateCompressionEdits(&uploadedInfo.CompressionOperation, &uploadedInfo.CompressionAlgorithm, &uploadedInfo.Annotations)
+	if compressionStep.normalization != nil {
+		if compressionStep.normalization.diffID == "" {
+			return types.BlobInfo{}, fmt.Errorf("Internal error writing blob %s, the normalized layer was not fully consumed", srcInfo.Digest)
+		}
+		ic.normalizedLayers.record(layerIndex, compressionStep.normalization.diffID)
+	}
 	decryptionStep.updateCryptoOperation(&uploadedInfo.CryptoOperation)
 	if err := encryptionStep.updateCryptoOperationAndAnnotations(&uploadedInfo.CryptoOperation, &uploadedInfo.Annotations); err != nil {
 		return types.BlobInfo{}, err
@@ -1,14 +1,26 @@
 package copy
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 
+	"github.com/containers/image/v5/directory"
+	"github.com/containers/image/v5/docker/archive"
+	"github.com/containers/image/v5/internal/imagedestination"
 	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/manifest"
 	"github.com/containers/image/v5/pkg/compression"
 	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
 	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestUpdatedBlobInfoFromUpload(t *testing.T) {
@@ -74,3 +86,94 @@ func TestUpdatedBlobInfoFromUpload(t *testing.T) {
 		assert.Equal(t, c.expected, res, fmt.Sprintf("%#v", c.uploaded))
 	}
 }
+
+// digestVerificationRecordingReference is a types.ImageReference whose destination records PutBlobOptions.SkipDigestVerification.
+type digestVerificationRecordingReference struct {
+	types.ImageReference
+	recorder *digestVerificationRecorder
+}
+
+type digestVerificationRecorder struct {
+	mutex   sync.Mutex
+	skipped map[bool]bool // PutBlobOptions.IsConfig -> PutBlobOptions.SkipDigestVerification
+}
+
+func (ref digestVerificationRecordingReference) NewImageDestination(ctx context.Context, sys *types.SystemContext) (types.ImageDestination, error) {
+	dest, err := ref.ImageReference.NewImageDestination(ctx, sys)
+	if err != nil {
+		return nil, err
+	}
+	return digestVerificationRecordingDestination{ImageDestination: imagedestination.FromPublic(dest), recorder: ref.recorder}, nil
+}
+
+type digestVerificationRecordingDestination struct {
+	private.ImageDestination
+	recorder *digestVerificationRecorder
+}
+
+func (d digestVerificationRecordingDestination) PutBlobWithOptions(ctx context.Context, stream io.Reader, inputInfo types.BlobInfo, options private.PutBlobOptions) (private.UploadedBlob, error) {
+	d.recorder.mutex.Lock()
+	d.recorder.skipped[options.IsConfig] = options.SkipDigestVerification
+	d.recorder.mutex.Unlock()
+	return d.ImageDestination.PutBlobWithOptions(ctx, stream, inputInfo, options)
+}
+
+func TestCopyBlobSkipDigestVerification(t *testing.T) {
+	layerData := bytes.Repeat([]byte("layer"), 1000)
+	var gzipLayer bytes.Buffer
+	compressor, err := compression.CompressStream(&gzipLayer, compression.Gzip, nil)
+	require.NoError(t, err)
+	_, err = compressor.Write(layerData)
+	require.NoError(t, err)
+	err = compressor.Close()
+	require.NoError(t, err)
+	config := []byte(`{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":["` + digest.FromBytes(layerData).String() + `"]}}`)
+	manifestBlob, err := manifest.OCI1FromComponents(imgspecv1.Descriptor{
+		MediaType: imgspecv1.MediaTypeImageConfig,
+		Digest:    digest.FromBytes(config),
+		Size:      int64(len(config)),
+	}, []imgspecv1.Descriptor{{
+		MediaType: imgspecv1.MediaTypeImageLayerGzip,
+		Digest:    digest.FromBytes(gzipLayer.Bytes()),
+		Size:      int64(gzipLayer.Len()),
+	}}).Serialize()
+	require.NoError(t, err)
+	srcDir := writeDirImage(t, manifestBlob, [][]byte{config, gzipLayer.Bytes()})
+	srcRef, err := directory.NewReference(srcDir)
+	require.NoError(t, err)
+	policyContext := newInsecureAcceptAnythingPolicyContext(t)
+
+	newDestRef := func() (digestVerificationRecordingReference, *digestVerificationRecorder) {
+		archiveRef, err := archive.ParseReference(filepath.Join(t.TempDir(), "archive.tar") + ":example.com/repo:tag")
+		require.NoError(t, err)
+		recorder := &digestVerificationRecorder{skipped: map[bool]bool{}}
+		return digestVerificationRecordingReference{ImageReference: archiveRef, recorder: recorder}, recorder
+	}
+
+	// Verification is only skipped for blobs which are sent unmodified, and are verified by copy.
+	for _, c := range []struct {
+		name          string
+		sys           *types.SystemContext
+		expectedLayer bool
+	}{
+		{"decompressed", &types.SystemContext{}, false},
+		{"preserved", &types.SystemContext{DockerArchiveLayerCompression: types.DockerArchiveLayerCompressionPreserve}, true},
+	} {
+		destRef, recorder := newDestRef()
+		_, err := Image(context.Background(), policyContext, destRef, srcRef, &Options{DestinationCtx: c.sys})
+		require.NoError(t, err, c.name)
+		assert.Equal(t, map[bool]bool{true: true, false: c.expectedLayer}, recorder.skipped, c.name)
+	}
+
+	// A corrupted source blob is still rejected.
+	corrupted := bytes.Clone(gzipLayer.Bytes())
+	corrupted[len(corrupted)-1] ^= 0xff
+	err = os.WriteFile(filepath.Join(srcDir, digest.FromBytes(gzipLayer.Bytes()).Encoded()), corrupted, 0o644)
+	require.NoError(t, err)
+	destRef, recorder := newDestRef()
+	_, err = Image(context.Background(), policyContext, destRef, srcRef, &Options{
+		DestinationCtx: &types.SystemContext{DockerArchiveLayerCompression: types.DockerArchiveLayerCompressionPreserve},
+	})
+	assert.ErrorContains(t, err, "Digest did not match")
+	assert.True(t, recorder.skipped[false])
+}
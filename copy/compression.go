@@ -89,6 +89,7 @@ type bpCompressionStepData struct {
 	uploadedCompressorBaseVariantName     string                      // Compressor base variant name to record in the blob info cache for the uploaded blob.
 	uploadedCompressorSpecificVariantName string                      // Compressor specific variant name to record in the blob info cache for the uploaded blob.
 	closers                               []io.Closer                 // Objects to close after the upload is done, if any.
+	normalization                         *layerNormalization         // Set if the layer is normalized, see Options.NormalizeLayers.
 }
 
 type bpcOperation int
@@ -162,7 +163,8 @@ func (ic *imageCopier) bpcCompressUncompressed(stream *sourceStream, detected bp
 			uploadedAlgorithm = defaultCompressionFormat
 		}
 
-		reader, annotations := ic.compressedStream(stream.reader, *uploadedAlgorithm)
+		normalization := ic.newLayerNormalization()
+		reader, annotations := ic.compressedStream(stream.reader, *uploadedAlgorithm, normalization)
 		// Note: reader must be closed on all return paths.
 		stream.reader = reader
 		stream.info = types.BlobInfo{ // FIXME? Should we preserve more data in src.info?
@@ -182,6 +184,7 @@ func (ic *imageCopier) bpcCompressUncompressed(stream *sourceStream, detected bp
 			uploadedCompressorBaseVariantName:     uploadedAlgorithm.BaseVariantName(),
 			uploadedCompressorSpecificVariantName: specificVariantName,
 			closers:                               []io.Closer{reader},
+			normalization:                         normalization,
 		}, nil
 	}
 	return nil, nil
@@ -207,7 +210,8 @@ func (ic *imageCopier) bpcRecompressCompressed(stream *sourceStream, detected bp
 			}
 		}()
 
-		recompressed, annotations := ic.compressedStream(decompressed, *ic.compressionFormat)
+		normalization := ic.newLayerNormalization()
+		recompressed, annotations := ic.compressedStream(decompressed, *ic.compressionFormat, normalization)
 		// Note: recompressed must be closed on all return paths.
 		stream.reader = recompressed
 		stream.info = types.BlobInfo{ // FIXME? Should we preserve more data in src.info? Notably the current approach correctly removes zstd:chunked metadata annotations.
@@ -228,6 +232,7 @@ func (ic *imageCopier) bpcRecompressCompressed(stream *sourceStream, detected bp
 			uploadedCompressorBaseVariantName:     ic.compressionFormat.BaseVariantName(),
 			uploadedCompressorSpecificVariantName: specificVariantName,
 			closers:                               []io.Closer{decompressed, recompressed},
+			normalization:                         normalization,
 		}, nil
 	}
 	return nil, nil
@@ -335,19 +340,28 @@ func (d *bpCompressionStepData) recordValidatedDigestData(c *copier, uploadedInf
 		case bpcOpPreserveOpaque:
 			// No useful information
 		case bpcOpCompressUncompressed:
-			c.blobInfoCache.RecordDigestUncompressedPair(uploadedInfo.Digest, srcInfo.Digest)
+			uncompressedDigest := srcInfo.Digest
+			if d.normalization != nil {
+				uncompressedDigest = d.normalization.diffID
+			}
+			c.blobInfoCache.RecordDigestUncompressedPair(uploadedInfo.Digest, uncompressedDigest)
 			if d.uploadedAnnotations != nil {
 				tocDigest, err := chunkedToc.GetTOCDigest(d.uploadedAnnotations)
 				if err != nil {
 					return fmt.Errorf("parsing just-created compression annotations: %w", err)
 				}
 				if tocDigest != nil {
-					c.blobInfoCache.RecordTOCUncompressedPair(*tocDigest, srcInfo.Digest)
+					c.blobInfoCache.RecordTOCUncompressedPair(*tocDigest, uncompressedDigest)
 				}
 			}
 		case bpcOpDecompressCompressed:
 			c.blobInfoCache.RecordDigestUncompressedPair(srcInfo.Digest, uploadedInfo.Digest)
 		case bpcOpRecompressCompressed, bpcOpPreserveCompressed:
+			if d.normalization != nil {
+				// We have computed the uncompressed digest of the normalized layer ourselves.
+				c.blobInfoCache.RecordDigestUncompressedPair(uploadedInfo.Digest, d.normalization.diffID)
+				break
+			}
 			// We know one or two compressed digests. BlobInfoCache associates compression variants via the uncompressed digest,
 			// and we don’t know that one.
 			// That also means that repeated copies with the same recompression don’t identify reuse opportunities (unless
@@ -392,16 +406,21 @@ func (d *bpCompressionStepData) close() {
 	}
 }
 
-// doCompression reads all input from src and writes its compressed equivalent to dest.
-func doCompression(dest io.Writer, src io.Reader, metadata map[string]string, compressionFormat compressiontypes.Algorithm, compressionLevel *int) error {
+// doCompression reads all input from src and writes its compressed equivalent to dest,
+// normalizing the layer first if normalization is not nil.
+func doCompression(dest io.Writer, src io.Reader, metadata map[string]string, compressionFormat compressiontypes.Algorithm, compressionLevel *int,
+	normalization *layerNormalization) error {
 	compressor, err := compression.CompressStreamWithMetadata(dest, metadata, compressionFormat, compressionLevel)
 	if err != nil {
 		return err
 	}
 
-	buf := make([]byte, compressionBufferSize)
-
-	_, err = io.CopyBuffer(compressor, src, buf) // Sets err to nil, i.e. causes dest.Close()
+	if normalization != nil {
+		err = normalization.normalize(compressor, src)
+	} else {
+		buf := make([]byte, compressionBufferSize)
+		_, err = io.CopyBuffer(compressor, src, buf) // Sets err to nil, i.e. causes dest.Close()
+	}
 	if err != nil {
 		compressor.Close()
 		return err
@@ -410,25 +429,27 @@ func doCompression(dest io.Writer, src io.Reader, metadata map[string]string, co
 	return compressor.Close()
 }
 
-// compressGoroutine reads all input from src and writes its compressed equivalent to dest.
-func (ic *imageCopier) compressGoroutine(dest *io.PipeWriter, src io.Reader, metadata map[string]string, compressionFormat compressiontypes.Algorithm) {
+// compressGoroutine reads all input from src and writes its compressed equivalent to dest, normalizing it if normalization is not nil.
+func (ic *imageCopier) compressGoroutine(dest *io.PipeWriter, src io.Reader, metadata map[string]string, compressionFormat compressiontypes.Algorithm,
+	normalization *layerNormalization) {
 	err := errors.New("Internal error: unexpected panic in compressGoroutine")
 	defer func() { // Note that this is not the same as {defer dest.CloseWithError(err)}; we need err to be evaluated lazily.
 		_ = dest.CloseWithError(err) // CloseWithError(nil) is equivalent to Close(), always returns nil
 	}()
 
-	err = doCompression(dest, src, metadata, compressionFormat, ic.compressionLevel)
+	err = doCompression(dest, src, metadata, compressionFormat, ic.compressionLevel, normalization)
 }
 
 // compressedStream returns a stream the input reader compressed using format, and a metadata map.
+// If normalization is not nil, the layer is normalized before compression.
 // The caller must close the returned reader.
-// AFTER the stream is consumed, metadata will be updated with annotations to use on the data.
-func (ic *imageCopier) compressedStream(reader io.Reader, algorithm compressiontypes.Algorithm) (io.ReadCloser, map[string]string) {
+// AFTER the stream is consumed, metadata will be updated with annotations to use on the data, and normalization with the DiffID.
+func (ic *imageCopier) compressedStream(reader io.Reader, algorithm compressiontypes.Algorithm, normalization *layerNormalization) (io.ReadCloser, map[string]string) {
 	pipeReader, pipeWriter := io.Pipe()
 	annotations := map[string]string{}
 	// If this fails while writing data, it will do pipeWriter.CloseWithError(); if it fails otherwise,
 	// e.g. because we have exited and due to pipeReader.Close() above further writing to the pipe has failed,
 	// we don’t care.
-	go ic.compressGoroutine(pipeWriter, reader, annotations, algorithm) // Closes pipeWriter
+	go ic.compressGoroutine(pipeWriter, reader, annotations, algorithm, normalization) // Closes pipeWriter
 	return pipeReader, annotations
 }
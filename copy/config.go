@@ -0,0 +1,78 @@
+package copy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// ConfigMutator modifies the configuration of an image being copied, e.g. to set environment variables, labels, or the entrypoint,
+// without rebuilding the image after the copy.
+//
+// It is called for every copied image (for multi-platform images, once for each copied instance, with that instance’s config),
+// after reading the image manifest and before copying layers; config is a private copy of the source image config which can be modified.
+// It is not called for non-image artifacts (e.g. Helm charts or SBOMs), which have no image config.
+// If it returns an error, the copy fails with that error.
+//
+// The layers are not modified, so RootFS must not be changed, and history entries may only be added or removed if they
+// do not create layers (with EmptyLayer set). Changes to fields of config are applied to the original config without
+// affecting fields not represented in imgspecv1.Image (e.g. the Docker health check).
+type ConfigMutator func(ctx context.Context, config *imgspecv1.Image) error
+
+// mutateConfig calls ic.c.options.ConfigMutator, if set, and records any changes in ic.manifestUpdates.
+// Non-image artifacts, which have no image config, are not modified.
+func (ic *imageCopier) mutateConfig(ctx context.Context) error {
+	if ic.c.options.ConfigMutator == nil || ic.src.IsArtifact() {
+		return nil
+	}
+	original, err := ic.src.OCIConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("reading image config to mutate: %w", err)
+	}
+	originalJSON, err := json.Marshal(original)
+	if err != nil {
+		return err
+	}
+	edited := &imgspecv1.Image{}
+	if err := json.Unmarshal(originalJSON, edited); err != nil { // Make a deep copy
+		return err
+	}
+	if err := ic.c.options.ConfigMutator(ctx, edited); err != nil {
+		return fmt.Errorf("mutating image config: %w", err)
+	}
+	editedJSON, err := json.Marshal(edited)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(editedJSON, originalJSON) {
+		return nil
+	}
+
+	if !reflect.DeepEqual(edited.RootFS, original.RootFS) {
+		return errors.New("mutating image config: RootFS can not be modified")
+	}
+	if layerCreatingHistoryEntries(edited) != layerCreatingHistoryEntries(original) {
+		return errors.New("mutating image config: history entries which create layers can not be added or removed")
+	}
+	if ic.cannotModifyManifestReason != "" {
+		return fmt.Errorf("Mutating the image config would change the manifest, which we cannot do: %q", ic.cannotModifyManifestReason)
+	}
+	ic.manifestUpdates.Config = edited
+	return nil
+}
+
+// layerCreatingHistoryEntries returns the number of history entries in config which create a layer.
+func layerCreatingHistoryEntries(config *imgspecv1.Image) int {
+	res := 0
+	for _, h := range config.History {
+		if !h.EmptyLayer {
+			res++
+		}
+	}
+	return res
+}
@@ -0,0 +1,93 @@
+package copy
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containers/image/v5/directory"
+	"github.com/containers/image/v5/manifest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigMutator(t *testing.T) {
+	srcDir, _ := createDirImage(t, []byte("layer contents"))
+	srcRef, err := directory.NewReference(srcDir)
+	require.NoError(t, err)
+	srcManifest, err := os.ReadFile(filepath.Join(srcDir, "manifest.json"))
+	require.NoError(t, err)
+	policyContext := newInsecureAcceptAnythingPolicyContext(t)
+
+	// The config is modified, and the manifest is updated to match
+	destDir := t.TempDir()
+	destRef, err := directory.NewReference(destDir)
+	require.NoError(t, err)
+	copiedManifest, err := Image(context.Background(), policyContext, destRef, srcRef, &Options{
+		ConfigMutator: func(_ context.Context, config *imgspecv1.Image) error {
+			config.Config.Env = append(config.Config.Env, "FOO=bar")
+			config.Config.Labels = map[string]string{"label": "value"}
+			config.Config.Entrypoint = []string{"/bin/app"}
+			return nil
+		},
+	})
+	require.NoError(t, err)
+	assert.NotEqual(t, srcManifest, copiedManifest)
+	m, err := manifest.OCI1FromManifest(copiedManifest)
+	require.NoError(t, err)
+	configBlob, err := os.ReadFile(filepath.Join(destDir, m.Config.Digest.Encoded()))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"architecture":"amd64","os":"linux",`+
+		`"config":{"Env":["FOO=bar"],"Labels":{"label":"value"},"Entrypoint":["/bin/app"]},`+
+		`"rootfs":{"type":"layers","diff_ids":["`+m.Layers[0].Digest.String()+`"]}}`, string(configBlob))
+	assert.Equal(t, m.Config.Digest, m.Config.Digest.Algorithm().FromBytes(configBlob))
+
+	// A mutator which does not change anything does not change the manifest
+	destRef, err = directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+	copiedManifest, err = Image(context.Background(), policyContext, destRef, srcRef, &Options{
+		ConfigMutator: func(_ context.Context, config *imgspecv1.Image) error { return nil },
+	})
+	require.NoError(t, err)
+	assert.Equal(t, srcManifest, copiedManifest)
+
+	// Failures
+	mutatorErr := errors.New("mutator failed")
+	for _, c := range []struct {
+		name    string
+		options Options
+	}{
+		{"mutator error", Options{ConfigMutator: func(_ context.Context, config *imgspecv1.Image) error { return mutatorErr }}},
+		{"RootFS modified", Options{ConfigMutator: func(_ context.Context, config *imgspecv1.Image) error {
+			config.RootFS.DiffIDs = nil
+			return nil
+		}}},
+		{"layer history added", Options{ConfigMutator: func(_ context.Context, config *imgspecv1.Image) error {
+			config.History = append(config.History, imgspecv1.History{CreatedBy: "RUN something"})
+			return nil
+		}}},
+		{"digests preserved", Options{PreserveDigests: true, ConfigMutator: func(_ context.Context, config *imgspecv1.Image) error {
+			config.Author = "new author"
+			return nil
+		}}},
+	} {
+		destRef, err = directory.NewReference(t.TempDir())
+		require.NoError(t, err)
+		_, err = Image(context.Background(), policyContext, destRef, srcRef, &c.options)
+		assert.Error(t, err, c.name)
+	}
+
+	// History entries which don’t create layers can be added
+	destRef, err = directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+	_, err = Image(context.Background(), policyContext, destRef, srcRef, &Options{
+		ConfigMutator: func(_ context.Context, config *imgspecv1.Image) error {
+			config.History = append(config.History, imgspecv1.History{CreatedBy: "ENV FOO=bar", EmptyLayer: true})
+			return nil
+		},
+	})
+	assert.NoError(t, err)
+}
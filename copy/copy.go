@@ -25,6 +25,7 @@ import (
 	"github.com/containers/image/v5/types"
 	encconfig "github.com/containers/ocicrypt/config"
 	digest "github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/sync/semaphore"
 	"golang.org/x/term"
@@ -81,12 +82,21 @@ type Options struct {
 	SignBySigstorePrivateKeyFile     string          // If non-empty, asks for a signature to be added during the copy, using a sigstore private key file at the provided path.
 	SignSigstorePrivateKeyPassphrase []byte          // Passphrase to use when signing with `SignBySigstorePrivateKeyFile`.
 	SignIdentity                     reference.Named // Identify to use when signing, defaults to the docker reference of the destination
+	// SignersWithStorage are additional signers to use to add signatures during the copy, each with its own choice of where
+	// the signatures are stored at the destination; this allows e.g. storing simple signing signatures in lookaside
+	// and sigstore signatures as referrers in a single copy.
+	// As with Signers, callers are responsible for closing these Signer objects.
+	SignersWithStorage []SignerWithStorage
 
 	ReportWriter     io.Writer
 	SourceCtx        *types.SystemContext
 	DestinationCtx   *types.SystemContext
 	ProgressInterval time.Duration                 // time to wait between reports to signal the progress channel
 	Progress         chan types.ProgressProperties // Reported to when ProgressInterval has arrived for a single artifact+offset.
+	// If ProgressJSONWriter is set, progress events are written to it as JSON lines, one JSONProgressEvent per line, for consumption by programs.
+	// JSONProgressEventRead events are written at most once per ProgressInterval, or once per second if ProgressInterval is not set.
+	// This does not affect reporting to Progress.
+	ProgressJSONWriter io.Writer
 
 	// Preserve digests, and fail if we cannot.
 	PreserveDigests bool
@@ -94,6 +104,24 @@ type Options struct {
 	ForceManifestMIMEType string
 	ImageListSelection    ImageListSelection // set to either CopySystemImage (the default), CopyAllImages, or CopySpecificImages to control which instances we copy when the source reference is a list; ignored if the source reference is not a list
 	Instances             []digest.Digest    // if ImageListSelection is CopySpecificImages, copy only these instances and the list itself
+	// If ImageListSelection is CopySpecificImages, also copy the BuildKit attestation manifests which refer to one of Instances
+	// (see manifest.DockerAttestationManifestSubject); otherwise they are only copied if included in Instances.
+	IncludeAttestationManifests bool
+	// If ContinueOnInstanceFailure is set, and the source is a list, a failure to copy one of the instances is not fatal:
+	// the failed instance is skipped and removed from the copied list, along with attestation manifests referring to it,
+	// so that the destination only references instances which exist there; the rest of the list is copied,
+	// and the failure is recorded in ReportInstanceFailures.
+	// Removing instances modifies the list, so the copy fails if any instance fails to copy and the list is signed,
+	// or the list digest must be preserved. The copy also fails if all instances fail to copy.
+	// With Images, ContinueOnInstanceFailure also continues past failures to copy individual images of the batch.
+	ContinueOnInstanceFailure bool
+	// If SortListInstances is set, instances of a copied list are sorted into a canonical order (by platform, with zstd-compressed instances
+	// after other instances of the same platform, and attestations and other artifacts last), so that repeated copies of lists with the same
+	// instances produce identical list digests, regardless of the order used by the source.
+	// This modifies the list unless it is already sorted, so it is not possible if the list is signed, or if the list digest must be preserved.
+	SortListInstances bool
+	// ReportInstanceFailures, if set, is appended a record of every instance skipped due to ContinueOnInstanceFailure.
+	ReportInstanceFailures *[]InstanceCopyFailure
 	// Give priority to pulling gzip images if multiple images are present when configured to OptionalBoolTrue,
 	// prefers the best compression if this is configured as OptionalBoolFalse. Choose automatically (and the choice may change over time)
 	// if this is set to OptionalBoolUndefined (which is the default behavior, and recommended for most callers).
@@ -112,6 +140,11 @@ type Options struct {
 	// OciDecryptConfig contains the config that can be used to decrypt an image if it is
 	// encrypted if non-nil. If nil, it does not attempt to decrypt an image.
 	OciDecryptConfig *encconfig.DecryptConfig
+	// OciReencrypt, if set, causes layers which are encrypted in the source to be decrypted using OciDecryptConfig,
+	// and encrypted again using OciEncryptConfig, instead of being stored decrypted.
+	// This allows changing the layers (e.g. converting their compression algorithm) while keeping them encrypted.
+	// Both OciDecryptConfig and OciEncryptConfig must be set.
+	OciReencrypt bool
 
 	// A weighted semaphore to limit the amount of concurrently copied layers and configs. Applies to all copy operations using the semaphore. If set, MaxParallelDownloads is ignored.
 	ConcurrentBlobCopiesSemaphore *semaphore.Weighted
@@ -119,20 +152,60 @@ type Options struct {
 	// MaxParallelDownloads indicates the maximum layers to pull at the same time. Applies to a single copy operation. A reasonable default is used if this is left as 0. Ignored if ConcurrentBlobCopiesSemaphore is set.
 	MaxParallelDownloads uint
 
+	// Governor, if set, limits blob copies of this operation together with all other operations using the same Governor,
+	// e.g. to enforce per-registry limits on concurrency and bandwidth in services performing many copies at once.
+	Governor *Governor
+
 	// When OptimizeDestinationImageAlreadyExists is set, optimize the copy assuming that the destination image already
 	// exists (and is equivalent). Making the eventual (no-op) copy more performant for this case. Enabling the option
 	// is slightly pessimistic if the destination image doesn't exist, or is not equivalent.
 	OptimizeDestinationImageAlreadyExists bool
 
+	// BlobMountCandidates, if set, lists additional repositories (e.g. all repositories in the same namespace) which may already
+	// contain the copied blobs. Destinations which support it (currently docker://) check these repositories for blobs missing
+	// at the destination, and mount the blobs found there instead of uploading them. Repositories on other registries are ignored.
+	BlobMountCandidates []reference.Named
+
 	// Download layer contents with "nondistributable" media types ("foreign" layers) and translate the layer media type
 	// to not indicate "nondistributable".
 	DownloadForeignLayers bool
 
+	// MetadataOnly, if set, copies only the manifests and configs, and never reads layers from the source:
+	// every layer must already exist at the destination (or be mountable there, e.g. from another repository on the same registry),
+	// otherwise the copy fails with a MissingBlobsError listing all missing layers.
+	// Layers are never substituted, so the layer digests are not changed; this can not be combined with
+	// OciEncryptLayers, OciDecryptConfig, LayerScanner, EnsureCompressionVariantsExist or NormalizeLayers.
+	MetadataOnly bool
+
+	// LayerMediaTypeRewrites, if set, maps layer media types to replacement media types: matching layers in the manifests written
+	// to the destination (after any manifest format conversion) are relabeled, without modifying the layer contents.
+	// Each replacement must be equivalent to the original, see manifest.LayerMediaTypesEquivalent.
+	// Relabeling a nondistributable layer as distributable is only possible if the layer is copied to the destination,
+	// see DownloadForeignLayers.
+	LayerMediaTypeRewrites map[string]string
+
+	// LayerScanner, if set, is called with the uncompressed contents of every layer read from the source,
+	// and can prevent the manifest from being written to the destination; see LayerScanner for details.
+	// When it is set, layers are always read from the source (blobs already present at the destination are not reused,
+	// and partial pulls are not used), so that all layers are scanned, except for foreign layers which are not
+	// downloaded (see DownloadForeignLayers).
+	LayerScanner LayerScanner
+
 	// Contains slice of OptionCompressionVariant, where copy will ensure that for each platform
 	// in the manifest list, a variant with the requested compression will exist.
 	// Invalid when copying a non-multi-architecture image. That will probably
 	// change in the future.
 	EnsureCompressionVariantsExist []OptionCompressionVariant
+	// NormalizeLayers, if set, normalizes the contents of layers which are compressed or recompressed during the copy
+	// (it has no effect on layers copied without recompression): entries are ordered by path, user and group names are cleared,
+	// PAX records other than extended attributes are removed, and modification times are rounded to whole seconds.
+	// This allows semantically identical layers produced by different tools to have identical digests at the destination.
+	// The DiffIDs in the image config are updated accordingly, so this changes the config and manifest digests.
+	NormalizeLayers bool
+	// ConfigMutator, if set, can modify the configuration of every copied image before the destination manifest is generated;
+	// see ConfigMutator for details. This changes the config and manifest digests, so it can not be combined with PreserveDigests,
+	// or with copying signed images unless RemoveSignatures is set; signatures requested by Signers etc. sign the updated manifest.
+	ConfigMutator ConfigMutator
 	// ForceCompressionFormat ensures that the compression algorithm set in
 	// DestinationCtx.CompressionFormat is used exclusively, and blobs of other
 	// compression algorithms are not reused.
@@ -155,6 +228,56 @@ type Options struct {
 	// In oci-archive: destinations, this will set the create/mod/access timestamps in each tar entry
 	// (but not a timestamp of the created archive file).
 	DestinationTimestamp *time.Time
+
+	// ResourceQuota, if set, limits resources used by the copy; the copy fails with a ResourceQuotaExceededError
+	// if they are exceeded.
+	ResourceQuota *ResourceQuota
+	// If RetryWithCompatibleFormatOnRejection is true, and writing an image manifest fails because the destination rejected
+	// the manifest format (types.ManifestTypeRejectedError), or because no manifest format accepted by the destination
+	// supports the layer compression, the image is copied again using gzip compression and, if the destination supports it,
+	// a Docker schema2 manifest.
+	// This is not done if the manifest can’t be modified (e.g. for signed images, or with PreserveDigests), or for the
+	// compression variants created due to EnsureCompressionVariantsExist.
+	RetryWithCompatibleFormatOnRejection bool
+	// ReportCompatibilityFallbacks, if set, is appended a record of every image copied again due to RetryWithCompatibleFormatOnRejection.
+	ReportCompatibilityFallbacks *[]CompatibilityFallback
+
+	// If LenientManifestParsing is set, well-understood deviations from the manifest format in source manifests
+	// (e.g. emitted by some old registries) are repaired instead of failing the copy; see image.LenientUnparsedInstance.
+	// The repaired manifests have different digests, so signatures of the source can not be verified, and this can not be
+	// combined with PreserveDigests.
+	LenientManifestParsing bool
+	// ReportManifestRepairs, if set, is appended a record of every repair made due to LenientManifestParsing.
+	ReportManifestRepairs *[]image.ManifestRepair
+
+	// ReferrerGenerator, if set, is called after the manifest is written to the destination to generate artifacts
+	// (e.g. SBOMs or provenance attestations) which are pushed to the destination as referrers of the copied image;
+	// see ReferrerGenerator for details.
+	ReferrerGenerator ReferrerGenerator
+	// ReportReferrers, if set, is appended descriptors of all artifact manifests pushed due to ReferrerGenerator,
+	// or to store signatures created by SignersWithStorage with SignatureStorageReferrers.
+	ReportReferrers *[]imgspecv1.Descriptor
+
+	// ReportResourceUsage, if set, is updated with the resources used by the copy, even if the copy fails.
+	//
+	// If ResourceQuota or ReportResourceUsage is set, the source and destination use private subdirectories
+	// of their directories for big files (see types.SystemContext.BigFilesTemporaryDir), to allow measuring their usage.
+	ReportResourceUsage *ResourceUsage
+
+	// SkipIfUpToDate, if set, compares the source image with the image currently at the destination before copying anything,
+	// and if the destination is up to date according to the selected check, does not copy the image;
+	// Image then returns the manifest of the destination image.
+	// The destination is only read using destRef.NewImageSource; if that fails, the image is copied.
+	// The source is still evaluated using policyContext, and the copy fails if it is rejected.
+	SkipIfUpToDate UpToDateCheck
+	// ReportSkippedUpToDate, if set, is set to true if the copy was skipped due to SkipIfUpToDate, and to false otherwise.
+	ReportSkippedUpToDate *bool
+
+	// ReportContentOrigins, if set, is set to records of where each manifest and blob read from the source came from,
+	// and why endpoints tried earlier failed, even if the copy fails.
+	// It is only set to a non-empty value for sources which can read content from one of several endpoints
+	// (currently docker:, e.g. with registry mirrors).
+	ReportContentOrigins *[]ContentOrigin
 }
 
 // OptionCompressionVariant allows to supply information about
@@ -177,12 +300,20 @@ type copier struct {
 
 	reportWriter   io.Writer
 	progressOutput io.Writer
+	// Blob progress is sent to progress (if not nil), at progressInterval.
+	// This is either options.Progress, or jsonProgress.channel.
+	progress         chan<- types.ProgressProperties
+	progressInterval time.Duration
+	jsonProgress     *jsonProgressWriter // nil if options.ProgressJSONWriter is not set
 
 	unparsedToplevel              *image.UnparsedImage // for rawSource
 	blobInfoCache                 internalblobinfocache.BlobInfoCache2
-	concurrentBlobCopiesSemaphore *semaphore.Weighted // Limits the amount of concurrently copied blobs
-	signers                       []*signer.Signer    // Signers to use to create new signatures for the image
-	signersToClose                []*signer.Signer    // Signers that should be closed when this copier is destroyed.
+	concurrentBlobCopiesSemaphore *semaphore.Weighted    // Limits the amount of concurrently copied blobs
+	signers                       []SignerWithStorage    // Signers to use to create new signatures for the image
+	signersToClose                []*signer.Signer       // Signers that should be closed when this copier is destroyed.
+	lenientInstances              []*image.UnparsedImage // UnparsedImages created with LenientManifestParsing, to report repairs.
+	resources                     *resourceAccounting    // nil if resource accounting was not requested
+	governor                      *governorScope         // nil if options.Governor is not set
 }
 
 // Internal function to validate `requireCompressionFormatMatch` for copySingleImageOptions
@@ -204,6 +335,41 @@ func Image(ctx context.Context, policyContext *signature.PolicyContext, destRef,
 	if err := validateImageListSelection(options.ImageListSelection); err != nil {
 		return nil, err
 	}
+	if err := validateLayerMediaTypeRewrites(options.LayerMediaTypeRewrites); err != nil {
+		return nil, err
+	}
+	if err := validateMetadataOnly(options); err != nil {
+		return nil, err
+	}
+	if err := validateOciReencrypt(options); err != nil {
+		return nil, err
+	}
+	if options.LenientManifestParsing && options.PreserveDigests {
+		return nil, errors.New("lenient manifest parsing can not be combined with preserving digests")
+	}
+	if err := validateUpToDateCheck(options.SkipIfUpToDate); err != nil {
+		return nil, err
+	}
+	if options.ReportSkippedUpToDate != nil {
+		*options.ReportSkippedUpToDate = false
+	}
+	if options.ReportContentOrigins != nil {
+		*options.ReportContentOrigins = nil
+	}
+	if options.SkipIfUpToDate != UpToDateCheckNone {
+		// This must happen before creating the destination, which may overwrite the current image (e.g. in docker-archive:).
+		destManifest, err := destinationUpToDate(ctx, policyContext, destRef, srcRef, options)
+		if err != nil {
+			return nil, err
+		}
+		if destManifest != nil {
+			logrus.Debugf("Destination %s is up to date, skipping the copy", transports.ImageName(destRef))
+			if options.ReportSkippedUpToDate != nil {
+				*options.ReportSkippedUpToDate = true
+			}
+			return destManifest, nil
+		}
+	}
 
 	reportWriter := io.Discard
 
@@ -225,19 +391,45 @@ func Image(ctx context.Context, policyContext *signature.PolicyContext, destRef,
 		}
 	}
 
-	publicDest, err := destRef.NewImageDestination(ctx, options.DestinationCtx)
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	resources, options, err := newResourceAccounting(options, cancel)
+	if err != nil {
+		return nil, err
+	}
+	if resources != nil {
+		defer resources.close()
+		defer func() {
+			if options.ReportResourceUsage != nil {
+				*options.ReportResourceUsage = resources.usage()
+			}
+			// If the copy was aborted because of the temporary storage quota, report that instead of a cancellation.
+			var quotaErr ResourceQuotaExceededError
+			if retErr != nil && !errors.As(retErr, &quotaErr) && errors.As(context.Cause(ctx), &quotaErr) {
+				retErr = fmt.Errorf("%w: %s", quotaErr, retErr.Error())
+			}
+		}()
+		monitorCtx, stopMonitor := context.WithCancel(ctx)
+		defer stopMonitor()
+		go resources.monitorTemporaryUsage(monitorCtx)
+	}
+
+	publicDest, err := destRef.NewImageDestination(ctx, withArchiveProgress(options.DestinationCtx, options))
 	if err != nil {
 		return nil, fmt.Errorf("initializing destination %s: %w", transports.ImageName(destRef), err)
 	}
 	dest := imagedestination.FromPublic(publicDest)
 	defer safeClose("dest", dest)
 
-	publicRawSource, err := srcRef.NewImageSource(ctx, options.SourceCtx)
+	publicRawSource, err := srcRef.NewImageSource(ctx, withArchiveProgress(options.SourceCtx, options))
 	if err != nil {
 		return nil, fmt.Errorf("initializing source %s: %w", transports.ImageName(srcRef), err)
 	}
 	rawSource := imagesource.FromPublic(publicRawSource)
 	defer safeClose("src", rawSource)
+	if options.ReportContentOrigins != nil {
+		defer reportContentOrigins(options.ReportContentOrigins, rawSource)
+	}
 
 	// If reportWriter is not a TTY (e.g., when piping to a file), do not
 	// print the progress bars to avoid long and hard to parse output.
@@ -256,13 +448,28 @@ func Image(ctx context.Context, policyContext *signature.PolicyContext, destRef,
 		reportWriter:   reportWriter,
 		progressOutput: progressOutput,
 
-		unparsedToplevel: image.UnparsedInstance(rawSource, nil),
 		// FIXME? The cache is used for sources and destinations equally, but we only have a SourceCtx and DestinationCtx.
 		// For now, use DestinationCtx (because blob reuse changes the behavior of the destination side more).
 		// Conceptually the cache settings should be in copy.Options instead.
 		blobInfoCache: internalblobinfocache.FromBlobInfoCache(blobinfocache.DefaultCache(options.DestinationCtx)),
+		resources:     resources,
+		governor:      options.Governor.scope(srcRef, destRef),
 	}
 	defer c.close()
+	if options.Progress != nil && options.ProgressInterval > 0 {
+		c.progress = options.Progress
+		c.progressInterval = options.ProgressInterval
+	}
+	if options.ProgressJSONWriter != nil {
+		c.jsonProgress = newJSONProgressWriter(options.ProgressJSONWriter, c.progress)
+		defer c.jsonProgress.close()
+		c.progress = c.jsonProgress.channel
+		if c.progressInterval <= 0 {
+			c.progressInterval = defaultJSONProgressInterval
+		}
+	}
+	c.unparsedToplevel = c.unparsedInstance(nil)
+	defer c.reportManifestRepairs()
 	c.blobInfoCache.Open()
 	defer c.blobInfoCache.Close()
 
@@ -332,7 +539,7 @@ func Image(ctx context.Context, policyContext *signature.PolicyContext, destRef,
 			return nil, fmt.Errorf("choosing an image from manifest list %s: %w", transports.ImageName(srcRef), err)
 		}
 		logrus.Debugf("Source is a manifest list; copying (only) instance %s for current system", instanceDigest)
-		unparsedInstance := image.UnparsedInstance(rawSource, &instanceDigest)
+		unparsedInstance := c.unparsedInstance(&instanceDigest)
 		single, err := c.copySingleImage(ctx, unparsedInstance, nil, copySingleImageOptions{requireCompressionFormatMatch: requireCompressionFormatMatch})
 		if err != nil {
 			return nil, fmt.Errorf("copying system image from manifest list: %w", err)
@@ -355,6 +562,10 @@ func Image(ctx context.Context, policyContext *signature.PolicyContext, destRef,
 		}
 	}
 
+	if err := c.pushReferrers(ctx, copiedManifest); err != nil {
+		return nil, err
+	}
+
 	if options.ReportResolvedReference != nil {
 		*options.ReportResolvedReference = nil // The default outcome, if not specifically supported by the transport.
 	}
@@ -387,6 +598,55 @@ func (c *copier) close() {
 	}
 }
 
+// CompatibilityFallback describes an image copied again due to Options.RetryWithCompatibleFormatOnRejection.
+type CompatibilityFallback struct {
+	OriginalError    error         // The error which caused the image to be copied again
+	ManifestDigest   digest.Digest // The digest of the manifest written by the second attempt
+	ManifestMIMEType string        // The MIME type of the manifest written by the second attempt
+}
+
+// ContentOrigin records where a manifest or a blob read from the source came from.
+type ContentOrigin struct {
+	Digest          digest.Digest     // The digest of the manifest or blob
+	IsManifest      bool              // true for manifests, false for blobs
+	Endpoint        string            // The endpoint the content was read from, e.g. a registry repository or a URL
+	IsMirror        bool              // Endpoint is a registry mirror configured in registries.conf, not the primary location of the image
+	FailedEndpoints []EndpointFailure // Endpoints which were tried before Endpoint, in order
+}
+
+// EndpointFailure describes a failure to read content from an endpoint.
+type EndpointFailure struct {
+	Endpoint string // The endpoint, e.g. a registry repository or a URL
+	Err      error  // The reason reading from the endpoint failed
+}
+
+// reportContentOrigins sets *dest to records of where content read from src came from, if src supports reporting that.
+func reportContentOrigins(dest *[]ContentOrigin, src private.ImageSource) {
+	withOrigins, ok := src.(private.ImageSourceWithContentOrigins)
+	if !ok {
+		return
+	}
+	for _, o := range withOrigins.ContentOrigins() {
+		var failures []EndpointFailure
+		for _, f := range o.FailedEndpoints {
+			failures = append(failures, EndpointFailure{Endpoint: f.Endpoint, Err: f.Err})
+		}
+		*dest = append(*dest, ContentOrigin{
+			Digest:          o.Digest,
+			IsManifest:      o.IsManifest,
+			Endpoint:        o.Endpoint,
+			IsMirror:        o.IsMirror,
+			FailedEndpoints: failures,
+		})
+	}
+}
+
+// InstanceCopyFailure describes an instance of a list which was not copied due to Options.ContinueOnInstanceFailure.
+type InstanceCopyFailure struct {
+	SourceDigest digest.Digest // The digest of the instance in the source list
+	Err          error         // The reason the copy failed
+}
+
 // validateImageListSelection returns an error if the passed-in value is not one that we recognize as a valid ImageListSelection value
 func validateImageListSelection(selection ImageListSelection) error {
 	switch selection {
@@ -397,6 +657,16 @@ func validateImageListSelection(selection ImageListSelection) error {
 	}
 }
 
+// validateLayerMediaTypeRewrites returns an error if rewrites contains a rewrite which would require modifying layer contents.
+func validateLayerMediaTypeRewrites(rewrites map[string]string) error {
+	for from, to := range rewrites {
+		if !manifest.LayerMediaTypesEquivalent(from, to) {
+			return fmt.Errorf("Invalid value for options.LayerMediaTypeRewrites: relabeling %q as %q would require modifying layer contents", from, to)
+		}
+	}
+	return nil
+}
+
 // Checks if the destination supports accepting multiple images by checking if it can support
 // manifest types that are lists of other manifests.
 func supportsMultipleImages(dest types.ImageDestination) bool {
@@ -0,0 +1,51 @@
+package copy
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/containers/image/v5/internal/private"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+)
+
+// contentOriginsSource is a private.ImageSourceWithContentOrigins which only implements ContentOrigins.
+type contentOriginsSource struct {
+	private.ImageSource
+	origins []private.ContentOrigin
+}
+
+func (s contentOriginsSource) ContentOrigins() []private.ContentOrigin {
+	return s.origins
+}
+
+func TestReportContentOrigins(t *testing.T) {
+	mirrorErr := errors.New("mirror failed")
+	src := contentOriginsSource{origins: []private.ContentOrigin{
+		{
+			Digest:          digest.FromString("manifest"),
+			IsManifest:      true,
+			Endpoint:        "mirror.example.com/busybox",
+			IsMirror:        true,
+			FailedEndpoints: []private.EndpointFailure{{Endpoint: "broken.example.com/busybox", Err: mirrorErr}},
+		},
+		{Digest: digest.FromString("blob"), Endpoint: "mirror.example.com/busybox"},
+	}}
+	var res []ContentOrigin
+	reportContentOrigins(&res, src)
+	assert.Equal(t, []ContentOrigin{
+		{
+			Digest:          digest.FromString("manifest"),
+			IsManifest:      true,
+			Endpoint:        "mirror.example.com/busybox",
+			IsMirror:        true,
+			FailedEndpoints: []EndpointFailure{{Endpoint: "broken.example.com/busybox", Err: mirrorErr}},
+		},
+		{Digest: digest.FromString("blob"), Endpoint: "mirror.example.com/busybox"},
+	}, res)
+
+	// Sources which don’t support reporting origins report nothing.
+	res = nil
+	reportContentOrigins(&res, struct{ private.ImageSource }{})
+	assert.Nil(t, res)
+}
@@ -1,6 +1,7 @@
 package copy
 
 import (
+	"errors"
 	"fmt"
 	"maps"
 	"slices"
@@ -25,6 +26,17 @@ func isEncrypted(i types.Image) bool {
 	})
 }
 
+// validateOciReencrypt returns an error if options.OciReencrypt is set without the configuration it requires.
+func validateOciReencrypt(options *Options) error {
+	if !options.OciReencrypt {
+		return nil
+	}
+	if options.OciDecryptConfig == nil || options.OciEncryptConfig == nil {
+		return errors.New("re-encrypting layers requires both OciDecryptConfig and OciEncryptConfig")
+	}
+	return nil
+}
+
 // bpDecryptionStepData contains data that the copy pipeline needs about the decryption step.
 type bpDecryptionStepData struct {
 	decrypting bool // We are actually decrypting the stream
@@ -85,7 +97,7 @@ type bpEncryptionStepData struct {
 // Returns data for other steps; the caller should eventually call updateCryptoOperationAndAnnotations.
 func (ic *imageCopier) blobPipelineEncryptionStep(stream *sourceStream, toEncrypt bool, srcInfo types.BlobInfo,
 	decryptionStep *bpDecryptionStepData) (*bpEncryptionStepData, error) {
-	if !toEncrypt || isOciEncrypted(srcInfo.MediaType) || ic.c.options.OciEncryptConfig == nil {
+	if !toEncrypt || (isOciEncrypted(srcInfo.MediaType) && !decryptionStep.decrypting) || ic.c.options.OciEncryptConfig == nil {
 		return &bpEncryptionStepData{
 			encrypting: false,
 		}, nil
@@ -95,12 +107,15 @@ func (ic *imageCopier) blobPipelineEncryptionStep(stream *sourceStream, toEncryp
 		return nil, fmt.Errorf("layer %s should be encrypted, but we can’t modify the manifest: %s", srcInfo.Digest, ic.cannotModifyManifestReason)
 	}
 
+	mediaType := srcInfo.MediaType
 	var annotations map[string]string
-	if !decryptionStep.decrypting {
+	if decryptionStep.decrypting { // Re-encrypting; the keys of the original encryption must not be reused.
+		mediaType = strings.TrimSuffix(mediaType, "+encrypted")
+	} else {
 		annotations = srcInfo.Annotations
 	}
 	desc := imgspecv1.Descriptor{
-		MediaType:   srcInfo.MediaType,
+		MediaType:   mediaType,
 		Digest:      srcInfo.Digest,
 		Size:        srcInfo.Size,
 		Annotations: annotations,
@@ -120,6 +135,7 @@ func (ic *imageCopier) blobPipelineEncryptionStep(stream *sourceStream, toEncryp
 }
 
 // updateCryptoOperationAndAnnotations sets *operation and updates *annotations, if necessary.
+// If *operation was already set to types.Decrypt by the decryption step, the layer was re-encrypted.
 func (d *bpEncryptionStepData) updateCryptoOperationAndAnnotations(operation *types.LayerCrypto, annotations *map[string]string) error {
 	if !d.encrypting {
 		return nil
@@ -129,7 +145,11 @@ func (d *bpEncryptionStepData) updateCryptoOperationAndAnnotations(operation *ty
 	if err != nil {
 		return fmt.Errorf("Unable to finalize encryption: %w", err)
 	}
-	*operation = types.Encrypt
+	if *operation == types.Decrypt {
+		*operation = types.Reencrypt
+	} else {
+		*operation = types.Encrypt
+	}
 	if *annotations == nil {
 		*annotations = map[string]string{}
 	}
@@ -0,0 +1,64 @@
+package copy
+
+import (
+	"testing"
+
+	"github.com/containers/image/v5/types"
+	encconfig "github.com/containers/ocicrypt/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateOciReencrypt(t *testing.T) {
+	for _, c := range []struct {
+		options Options
+		valid   bool
+	}{
+		{Options{}, true},
+		{Options{OciDecryptConfig: &encconfig.DecryptConfig{}}, true},
+		{Options{OciDecryptConfig: &encconfig.DecryptConfig{}, OciEncryptConfig: &encconfig.EncryptConfig{}, OciReencrypt: true}, true},
+		{Options{OciReencrypt: true}, false},
+		{Options{OciDecryptConfig: &encconfig.DecryptConfig{}, OciReencrypt: true}, false},
+		{Options{OciEncryptConfig: &encconfig.EncryptConfig{}, OciReencrypt: true}, false},
+	} {
+		err := validateOciReencrypt(&c.options)
+		if c.valid {
+			assert.NoError(t, err, "%#v", c.options)
+		} else {
+			assert.Error(t, err, "%#v", c.options)
+		}
+	}
+}
+
+func TestBpEncryptionStepDataUpdateCryptoOperationAndAnnotations(t *testing.T) {
+	finalizer := func() (map[string]string, error) {
+		return map[string]string{"org.opencontainers.image.enc.keys.jwe": "new-keys"}, nil
+	}
+
+	for _, c := range []struct {
+		decrypting bool
+		expected   types.LayerCrypto
+	}{
+		{false, types.Encrypt},
+		{true, types.Reencrypt},
+	} {
+		operation := types.PreserveOriginalCrypto
+		decryptionStep := bpDecryptionStepData{decrypting: c.decrypting}
+		decryptionStep.updateCryptoOperation(&operation)
+		var annotations map[string]string
+		encryptionStep := bpEncryptionStepData{encrypting: true, finalizer: finalizer}
+		err := encryptionStep.updateCryptoOperationAndAnnotations(&operation, &annotations)
+		require.NoError(t, err)
+		assert.Equal(t, c.expected, operation)
+		assert.Equal(t, map[string]string{"org.opencontainers.image.enc.keys.jwe": "new-keys"}, annotations)
+	}
+
+	// Nothing is changed if not encrypting
+	operation := types.Decrypt
+	annotations := map[string]string{"a": "b"}
+	encryptionStep := bpEncryptionStepData{encrypting: false}
+	err := encryptionStep.updateCryptoOperationAndAnnotations(&operation, &annotations)
+	require.NoError(t, err)
+	assert.Equal(t, types.Decrypt, operation)
+	assert.Equal(t, map[string]string{"a": "b"}, annotations)
+}
@@ -0,0 +1,250 @@
+package copy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/types"
+	"golang.org/x/sync/semaphore"
+)
+
+// governedTransportName is the name of the transport whose references are subject to per-registry limits of a Governor.
+// (We compare the name instead of importing the docker transport, to keep this package independent of specific transports.)
+const governedTransportName = "docker"
+
+// governorMaxReadSize is the maximum amount of data read at once by readers throttled by a Governor,
+// so that the bandwidth is shared reasonably evenly between concurrent copies.
+const governorMaxReadSize = 32 * 1024
+
+// GovernorLimits contains limits enforced by a Governor. Fields set to 0 mean no limit.
+type GovernorLimits struct {
+	MaxConcurrentBlobCopies int   // The maximum number of blobs (layers or configs) copied at the same time
+	MaxBytesPerSecond       int64 // The maximum combined rate of reading blob data from sources
+}
+
+// GovernorOptions configures a Governor.
+type GovernorOptions struct {
+	// Total limits all copies using the Governor, combined.
+	Total GovernorLimits
+	// PerRegistry limits copies from or to each registry, unless overridden in Registries.
+	PerRegistry GovernorLimits
+	// Registries limits copies from or to specific registries, indexed by host[:port] as used in image references
+	// (e.g. "docker.io" for Docker Hub).
+	Registries map[string]GovernorLimits
+}
+
+// Governor enforces combined limits on blob copies performed by any number of concurrent Image calls which use it
+// as Options.Governor, e.g. so that services copying many images at once don’t overload their registries.
+//
+// Limits of a registry apply to copies both from and to that registry, using the docker:// transport;
+// copies from or to other transports are only subject to GovernorOptions.Total.
+// The limits apply in addition to Options.MaxParallelDownloads or Options.ConcurrentBlobCopiesSemaphore.
+//
+// A Governor is safe for concurrent use.
+type Governor struct {
+	options GovernorOptions
+	total   *governorLimiter
+
+	mutex      sync.Mutex                  // Protects registries
+	registries map[string]*governorLimiter // Created lazily, on first use of a registry
+}
+
+// NewGovernor returns a Governor enforcing options.
+func NewGovernor(options GovernorOptions) (*Governor, error) {
+	if err := options.Total.validate(); err != nil {
+		return nil, fmt.Errorf("invalid total governor limits: %w", err)
+	}
+	if err := options.PerRegistry.validate(); err != nil {
+		return nil, fmt.Errorf("invalid per-registry governor limits: %w", err)
+	}
+	registries := make(map[string]GovernorLimits, len(options.Registries))
+	for registry, limits := range options.Registries {
+		if err := limits.validate(); err != nil {
+			return nil, fmt.Errorf("invalid governor limits for registry %q: %w", registry, err)
+		}
+		registries[registry] = limits
+	}
+	options.Registries = registries // Don’t let the caller modify the map later
+	return &Governor{
+		options:    options,
+		total:      newGovernorLimiter(options.Total),
+		registries: map[string]*governorLimiter{},
+	}, nil
+}
+
+// validate returns an error if limits are invalid.
+func (limits GovernorLimits) validate() error {
+	if limits.MaxConcurrentBlobCopies < 0 {
+		return fmt.Errorf("negative maximum number of concurrent blob copies %d", limits.MaxConcurrentBlobCopies)
+	}
+	if limits.MaxBytesPerSecond < 0 {
+		return fmt.Errorf("negative maximum bandwidth %d", limits.MaxBytesPerSecond)
+	}
+	return nil
+}
+
+// registryLimiter returns the governorLimiter for registry.
+func (g *Governor) registryLimiter(registry string) *governorLimiter {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if l, ok := g.registries[registry]; ok {
+		return l
+	}
+	limits, ok := g.options.Registries[registry]
+	if !ok {
+		limits = g.options.PerRegistry
+	}
+	l := newGovernorLimiter(limits)
+	g.registries[registry] = l
+	return l
+}
+
+// scope returns a governorScope applying g to a copy between refs.
+// A nil *Governor is valid, and returns a nil *governorScope.
+func (g *Governor) scope(refs ...types.ImageReference) *governorScope {
+	if g == nil {
+		return nil
+	}
+	registries := []string{}
+	for _, ref := range refs {
+		if ref.Transport().Name() != governedTransportName {
+			continue
+		}
+		if named := ref.DockerReference(); named != nil {
+			if registry := reference.Domain(named); !slices.Contains(registries, registry) {
+				registries = append(registries, registry)
+			}
+		}
+	}
+	// Always acquire limits in the same order (the total first, then registries sorted by name), so that concurrent
+	// copies acquiring overlapping sets of limits can not deadlock.
+	slices.Sort(registries)
+	gs := &governorScope{limiters: []*governorLimiter{g.total}}
+	for _, registry := range registries {
+		gs.limiters = append(gs.limiters, g.registryLimiter(registry))
+	}
+	return gs
+}
+
+// governorLimiter enforces one GovernorLimits value.
+type governorLimiter struct {
+	copies    *semaphore.Weighted // nil if the number of concurrent copies is unlimited
+	bandwidth *bandwidthLimiter   // nil if the bandwidth is unlimited
+}
+
+// newGovernorLimiter returns a governorLimiter enforcing limits.
+func newGovernorLimiter(limits GovernorLimits) *governorLimiter {
+	l := &governorLimiter{}
+	if limits.MaxConcurrentBlobCopies > 0 {
+		l.copies = semaphore.NewWeighted(int64(limits.MaxConcurrentBlobCopies))
+	}
+	if limits.MaxBytesPerSecond > 0 {
+		l.bandwidth = &bandwidthLimiter{bytesPerSecond: limits.MaxBytesPerSecond}
+	}
+	return l
+}
+
+// bandwidthLimiter delays readers so that the combined rate of data read does not exceed bytesPerSecond.
+type bandwidthLimiter struct {
+	bytesPerSecond int64
+
+	mutex sync.Mutex
+	next  time.Time // The time at which all data read so far is within the limit
+}
+
+// wait records that n bytes were read, and waits until that is allowed by the limit, or until ctx is done.
+func (l *bandwidthLimiter) wait(ctx context.Context, n int) error {
+	l.mutex.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now // Unused bandwidth in the past can’t be used to exceed the limit now.
+	}
+	l.next = l.next.Add(time.Duration(float64(n) / float64(l.bytesPerSecond) * float64(time.Second)))
+	delay := l.next.Sub(now)
+	l.mutex.Unlock()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	case <-timer.C:
+		return nil
+	}
+}
+
+// governorScope applies a Governor to a single copy.Image operation.
+// A nil *governorScope is valid, and does nothing.
+type governorScope struct {
+	limiters []*governorLimiter // In the order in which they must be acquired
+}
+
+// acquireBlobCopy waits until a blob copy is allowed by all limits, or until ctx is done.
+// On success, the caller must call the returned function after the blob copy finishes.
+func (gs *governorScope) acquireBlobCopy(ctx context.Context) (func(), error) {
+	if gs == nil {
+		return func() {}, nil
+	}
+	acquired := []*semaphore.Weighted{}
+	release := func() {
+		for _, s := range acquired {
+			s.Release(1)
+		}
+	}
+	for _, l := range gs.limiters {
+		if l.copies == nil {
+			continue
+		}
+		if err := l.copies.Acquire(ctx, 1); err != nil {
+			release()
+			return nil, fmt.Errorf("waiting for the governor to allow a blob copy: %w", err)
+		}
+		acquired = append(acquired, l.copies)
+	}
+	return release, nil
+}
+
+// reader returns a reader of r which is throttled to the bandwidth limits.
+func (gs *governorScope) reader(ctx context.Context, r io.Reader) io.Reader {
+	if gs == nil {
+		return r
+	}
+	limiters := []*bandwidthLimiter{}
+	for _, l := range gs.limiters {
+		if l.bandwidth != nil {
+			limiters = append(limiters, l.bandwidth)
+		}
+	}
+	if len(limiters) == 0 {
+		return r
+	}
+	return &governedReader{ctx: ctx, source: r, limiters: limiters}
+}
+
+// governedReader is an io.Reader which is throttled by bandwidthLimiters.
+type governedReader struct {
+	ctx      context.Context
+	source   io.Reader
+	limiters []*bandwidthLimiter
+}
+
+func (r *governedReader) Read(p []byte) (int, error) {
+	if len(p) > governorMaxReadSize {
+		p = p[:governorMaxReadSize]
+	}
+	n, err := r.source.Read(p)
+	if n > 0 {
+		for _, l := range r.limiters {
+			if waitErr := l.wait(r.ctx, n); waitErr != nil {
+				return n, waitErr
+			}
+		}
+	}
+	return n, err
+}
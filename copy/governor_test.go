@@ -0,0 +1,155 @@
+package copy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/containers/image/v5/directory"
+	"github.com/containers/image/v5/docker"
+	"github.com/containers/image/v5/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewGovernor(t *testing.T) {
+	for _, options := range []GovernorOptions{
+		{Total: GovernorLimits{MaxConcurrentBlobCopies: -1}},
+		{PerRegistry: GovernorLimits{MaxBytesPerSecond: -1}},
+		{Registries: map[string]GovernorLimits{"registry.example.com": {MaxConcurrentBlobCopies: -1}}},
+	} {
+		_, err := NewGovernor(options)
+		assert.Error(t, err, "%#v", options)
+	}
+
+	registries := map[string]GovernorLimits{"registry.example.com": {MaxConcurrentBlobCopies: 1}}
+	g, err := NewGovernor(GovernorOptions{Registries: registries})
+	require.NoError(t, err)
+	registries["other.example.com"] = GovernorLimits{MaxConcurrentBlobCopies: 1}
+	assert.Len(t, g.options.Registries, 1)
+}
+
+func TestGovernorScope(t *testing.T) {
+	dockerRef := func(s string) types.ImageReference {
+		ref, err := docker.ParseReference("//" + s)
+		require.NoError(t, err)
+		return ref
+	}
+	dirRef, err := directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+
+	// A nil Governor does nothing
+	var g *Governor
+	assert.Nil(t, g.scope(dockerRef("registry.example.com/a")))
+
+	g, err = NewGovernor(GovernorOptions{
+		PerRegistry: GovernorLimits{MaxConcurrentBlobCopies: 2},
+		Registries:  map[string]GovernorLimits{"b.example.com": {MaxBytesPerSecond: 1000}},
+	})
+	require.NoError(t, err)
+	gs := g.scope(dirRef, dirRef)
+	assert.Equal(t, []*governorLimiter{g.total}, gs.limiters)
+
+	gs = g.scope(dockerRef("b.example.com/repo"), dockerRef("a.example.com/repo:tag"))
+	require.Len(t, gs.limiters, 3)
+	assert.Same(t, g.total, gs.limiters[0])
+	assert.NotNil(t, gs.limiters[1].copies) // a.example.com, using the per-registry default
+	assert.Nil(t, gs.limiters[1].bandwidth)
+	assert.Nil(t, gs.limiters[2].copies) // b.example.com, overridden
+	assert.NotNil(t, gs.limiters[2].bandwidth)
+
+	// Limiters are shared by all copies using the registry, and each registry is only included once
+	gs2 := g.scope(dockerRef("a.example.com/other"), dockerRef("a.example.com/repo"))
+	assert.Equal(t, []*governorLimiter{g.total, gs.limiters[1]}, gs2.limiters)
+}
+
+func TestGovernorAcquireBlobCopy(t *testing.T) {
+	// A nil scope does nothing
+	var gs *governorScope
+	release, err := gs.acquireBlobCopy(context.Background())
+	require.NoError(t, err)
+	release()
+
+	g, err := NewGovernor(GovernorOptions{
+		Total:      GovernorLimits{MaxConcurrentBlobCopies: 2},
+		Registries: map[string]GovernorLimits{"a.example.com": {MaxConcurrentBlobCopies: 1}},
+	})
+	require.NoError(t, err)
+	a := &governorScope{limiters: []*governorLimiter{g.total, g.registryLimiter("a.example.com")}}
+	b := &governorScope{limiters: []*governorLimiter{g.total, g.registryLimiter("b.example.com")}}
+
+	tryAcquire := func(gs *governorScope) (func(), error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		return gs.acquireBlobCopy(ctx)
+	}
+	releaseA, err := tryAcquire(a)
+	require.NoError(t, err)
+	_, err = tryAcquire(a) // Over the limit of a.example.com
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	releaseB, err := tryAcquire(b)
+	require.NoError(t, err)
+	_, err = tryAcquire(b) // Over the total limit
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	releaseA()
+	releaseA2, err := tryAcquire(a)
+	require.NoError(t, err)
+	releaseA2()
+	releaseB()
+}
+
+func TestGovernorReader(t *testing.T) {
+	// A nil scope, or one without bandwidth limits, does nothing
+	r := bytes.NewReader([]byte("abc"))
+	var gs *governorScope
+	assert.Same(t, r, gs.reader(context.Background(), r))
+	g, err := NewGovernor(GovernorOptions{Total: GovernorLimits{MaxConcurrentBlobCopies: 1}})
+	require.NoError(t, err)
+	assert.Same(t, r, g.scope().reader(context.Background(), r))
+
+	// The bandwidth is shared by all readers
+	g, err = NewGovernor(GovernorOptions{Total: GovernorLimits{MaxBytesPerSecond: 1024 * 1024}})
+	require.NoError(t, err)
+	gs = g.scope()
+	start := time.Now()
+	done := make(chan error)
+	for range 2 {
+		go func() {
+			data, err := io.ReadAll(gs.reader(context.Background(), bytes.NewReader(make([]byte, 128*1024))))
+			if err == nil && len(data) != 128*1024 {
+				err = io.ErrUnexpectedEOF
+			}
+			done <- err
+		}()
+	}
+	for range 2 {
+		require.NoError(t, <-done)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond) // 256 KiB at 1 MiB/s take 250 ms
+
+	// Canceling the context aborts the read
+	g, err = NewGovernor(GovernorOptions{Total: GovernorLimits{MaxBytesPerSecond: 1}})
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = io.ReadAll(g.scope().reader(ctx, bytes.NewReader(make([]byte, 10))))
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestImageGovernor(t *testing.T) {
+	srcDir, blobsSize := createDirImage(t, bytes.Repeat([]byte("layer"), 20*1024))
+	srcRef, err := directory.NewReference(srcDir)
+	require.NoError(t, err)
+	destRef, err := directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+	g, err := NewGovernor(GovernorOptions{Total: GovernorLimits{MaxConcurrentBlobCopies: 1, MaxBytesPerSecond: 1024 * 1024}})
+	require.NoError(t, err)
+
+	start := time.Now()
+	_, err = Image(context.Background(), newInsecureAcceptAnythingPolicyContext(t), destRef, srcRef, &Options{Governor: g})
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), time.Duration(blobsSize)*time.Second/(1024*1024)*8/10)
+}
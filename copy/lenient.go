@@ -0,0 +1,30 @@
+package copy
+
+import (
+	"github.com/containers/image/v5/internal/image"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/sirupsen/logrus"
+)
+
+// unparsedInstance returns an UnparsedImage for instanceDigest (or the top-level manifest, if nil) of c.rawSource,
+// honoring c.options.LenientManifestParsing.
+func (c *copier) unparsedInstance(instanceDigest *digest.Digest) *image.UnparsedImage {
+	if !c.options.LenientManifestParsing {
+		return image.UnparsedInstance(c.rawSource, instanceDigest)
+	}
+	res := image.LenientUnparsedInstance(c.rawSource, instanceDigest)
+	c.lenientInstances = append(c.lenientInstances, res)
+	return res
+}
+
+// reportManifestRepairs logs, and records in c.options.ReportManifestRepairs, the repairs made due to c.options.LenientManifestParsing.
+func (c *copier) reportManifestRepairs() {
+	for _, instance := range c.lenientInstances {
+		for _, repair := range instance.ManifestRepairs() {
+			logrus.Warnf("Repaired manifest %s: %s: %s", repair.ManifestDigest, repair.Field, repair.Description)
+			if c.options.ReportManifestRepairs != nil {
+				*c.options.ReportManifestRepairs = append(*c.options.ReportManifestRepairs, repair)
+			}
+		}
+	}
+}
@@ -57,6 +57,7 @@ type determineManifestConversionInputs struct {
 	requestedCompressionFormat *compressiontypes.Algorithm // Compression algorithm to use, if the user _explictily_ requested one.
 	requiresOCIEncryption      bool                        // Restrict to manifest formats that can support OCI encryption
 	cannotModifyManifestReason string                      // The reason the manifest cannot be modified, or an empty string if it can
+	srcIsNonImageArtifact      bool                        // The source is a non-image artifact, which can not be converted to other manifest formats
 }
 
 // manifestConversionPlan contains the decisions made by determineManifestConversion.
@@ -110,8 +111,9 @@ func determineManifestConversion(in determineManifestConversionInputs) (manifest
 				return manifestConversionPlan{}, fmt.Errorf("compression using %s, and encryption, required together with format %s, which does not support both",
 					in.requestedCompressionFormat.Name(), in.forceManifestMIMEType)
 			case in.requiresOCIEncryption:
-				return manifestConversionPlan{}, fmt.Errorf("encryption required together with format %s, which does not support encryption",
-					in.forceManifestMIMEType)
+				return manifestConversionPlan{}, manifest.ManifestLayerEncryptionIncompatibilityError{
+					ManifestMIMETypes: []string{in.forceManifestMIMEType},
+				}
 			case restrictiveCompressionRequired:
 				return manifestConversionPlan{}, fmt.Errorf("compression using %s required together with format %s, which does not support it",
 					in.requestedCompressionFormat.Name(), in.forceManifestMIMEType)
@@ -136,8 +138,9 @@ func determineManifestConversion(in determineManifestConversionInputs) (manifest
 			return manifestConversionPlan{}, fmt.Errorf("compression using %s, and encryption, required but the destination only supports MIME types [%s], none of which support both",
 				in.requestedCompressionFormat.Name(), destMIMEList)
 		case in.requiresOCIEncryption:
-			return manifestConversionPlan{}, fmt.Errorf("encryption required but the destination only supports MIME types [%s], none of which support encryption",
-				destMIMEList)
+			return manifestConversionPlan{}, manifest.ManifestLayerEncryptionIncompatibilityError{
+				ManifestMIMETypes: destSupportedManifestMIMETypes,
+			}
 		case restrictiveCompressionRequired:
 			return manifestConversionPlan{}, fmt.Errorf("compression using %s required but the destination only supports MIME types [%s], none of which support it",
 				in.requestedCompressionFormat.Name(), destMIMEList)
@@ -146,6 +149,18 @@ func determineManifestConversion(in determineManifestConversionInputs) (manifest
 		}
 	}
 
+	if in.srcIsNonImageArtifact {
+		// Other manifest formats can only represent container images; so, use the original format, or fail.
+		if !supportedByDest.Contains(srcType) {
+			return manifestConversionPlan{}, fmt.Errorf("non-image artifacts can only be stored using MIME type %s, but the destination only supports MIME types [%s]",
+				srcType, strings.Join(destSupportedManifestMIMETypes, ", "))
+		}
+		return manifestConversionPlan{
+			preferredMIMEType:       srcType,
+			otherMIMETypeCandidates: []string{},
+		}, nil
+	}
+
 	// destSupportedManifestMIMETypes is a static guess; a particular registry may still only support a subset of the types.
 	// So, build a list of types to try in order of decreasing preference.
 	// FIXME? This treats manifest.DockerV2Schema1SignedMediaType and manifest.DockerV2Schema1MediaType as distinct,
@@ -251,3 +266,83 @@ func (c *copier) determineListConversion(currentListMIMEType string, destSupport
 	// Done.
 	return selectedType, otherSupportedTypes, nil
 }
+
+// layerMediaTypeRewritesApply returns true if rewrites (see Options.LayerMediaTypeRewrites) would relabel any of layers.
+func layerMediaTypeRewritesApply(layers []types.BlobInfo, rewrites map[string]string) bool {
+	for _, layer := range layers {
+		if updated, ok := rewrites[layer.MediaType]; ok && updated != layer.MediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// rewriteLayerMediaTypes returns manifestBlob (of manifestMIMEType) with layer media types relabeled according to rewrites
+// (see Options.LayerMediaTypeRewrites), or the original manifestBlob if no layers match.
+// cannotModifyManifestReason is the reason the manifest cannot be modified, or "" if it can.
+func rewriteLayerMediaTypes(manifestBlob []byte, manifestMIMEType string, rewrites map[string]string, cannotModifyManifestReason string) ([]byte, error) {
+	// relabel updates *mediaType of a layer, and returns true if it was changed.
+	relabel := func(mediaType *string, layerURLs []string) (bool, error) {
+		updated, ok := rewrites[*mediaType]
+		if !ok || updated == *mediaType {
+			return false, nil
+		}
+		if cannotModifyManifestReason != "" {
+			return false, fmt.Errorf("relabeling layer media type %q as %q would modify the manifest: %s", *mediaType, updated, cannotModifyManifestReason)
+		}
+		if len(layerURLs) != 0 && !manifest.IsNondistributableLayerMediaType(updated) {
+			return false, fmt.Errorf("relabeling layer media type %q as %q: the layer was not copied to the destination (consider DownloadForeignLayers)", *mediaType, updated)
+		}
+		*mediaType = updated
+		return true, nil
+	}
+
+	changed := false
+	var m manifest.Manifest
+	switch manifestMIMEType {
+	case manifest.DockerV2Schema2MediaType:
+		s2, err := manifest.Schema2FromManifest(manifestBlob)
+		if err != nil {
+			return nil, err
+		}
+		for i := range s2.LayersDescriptors {
+			layer := &s2.LayersDescriptors[i]
+			layerChanged, err := relabel(&layer.MediaType, layer.URLs)
+			if err != nil {
+				return nil, err
+			}
+			if layerChanged {
+				if err := manifest.SupportedSchema2MediaType(layer.MediaType); err != nil {
+					return nil, fmt.Errorf("relabeling layer %s: %w", layer.Digest, err)
+				}
+				changed = true
+			}
+		}
+		m = s2
+	case v1.MediaTypeImageManifest:
+		oci, err := manifest.OCI1FromManifest(manifestBlob)
+		if err != nil {
+			return nil, err
+		}
+		for i := range oci.Layers {
+			layer := &oci.Layers[i]
+			layerChanged, err := relabel(&layer.MediaType, layer.URLs)
+			if err != nil {
+				return nil, err
+			}
+			if layerChanged {
+				if err := manifest.SupportedOCI1MediaType(layer.MediaType); err != nil {
+					return nil, fmt.Errorf("relabeling layer %s: %w", layer.Digest, err)
+				}
+				changed = true
+			}
+		}
+		m = oci
+	default: // Schema1 manifests don’t contain layer media types.
+		return manifestBlob, nil
+	}
+	if !changed {
+		return manifestBlob, nil
+	}
+	return m.Serialize()
+}
@@ -9,6 +9,8 @@ import (
 	"github.com/containers/image/v5/internal/testing/mocks"
 	"github.com/containers/image/v5/manifest"
 	"github.com/containers/image/v5/pkg/compression"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
 	v1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -405,10 +407,36 @@ func TestDetermineManifestConversion(t *testing.T) {
 				assert.Equal(t, c.expected, res, desc)
 			} else {
 				assert.Error(t, err, desc)
+				if in.requiresOCIEncryption && in.requestedCompressionFormat == nil {
+					var encryptionErr manifest.ManifestLayerEncryptionIncompatibilityError
+					assert.ErrorAs(t, err, &encryptionErr, desc)
+				}
 			}
 		}
 	}
 
+	// Non-image artifacts are never converted
+	for _, destTypes := range [][]string{nil, supportS1S2OCI, supportS1OCI} {
+		res, err := determineManifestConversion(determineManifestConversionInputs{
+			srcMIMEType:                    v1.MediaTypeImageManifest,
+			destSupportedManifestMIMETypes: destTypes,
+			srcIsNonImageArtifact:          true,
+		})
+		require.NoError(t, err, destTypes)
+		assert.Equal(t, manifestConversionPlan{
+			preferredMIMEType:                v1.MediaTypeImageManifest,
+			preferredMIMETypeNeedsConversion: false,
+			otherMIMETypeCandidates:          []string{},
+		}, res, destTypes)
+	}
+	for _, in := range []determineManifestConversionInputs{
+		{srcMIMEType: v1.MediaTypeImageManifest, destSupportedManifestMIMETypes: supportS1S2, srcIsNonImageArtifact: true},
+		{srcMIMEType: v1.MediaTypeImageManifest, destSupportedManifestMIMETypes: supportS1S2OCI, forceManifestMIMEType: manifest.DockerV2Schema2MediaType, srcIsNonImageArtifact: true},
+	} {
+		_, err := determineManifestConversion(in)
+		assert.Error(t, err, "%#v", in)
+	}
+
 	// When encryption using a completely unsupported algorithm is required:
 	for _, c := range []struct {
 		description string
@@ -622,3 +650,75 @@ func TestDetermineManifestListConversion(t *testing.T) {
 	_, _, err := copier.determineListConversion(v1.MediaTypeImageIndex, supportOnlyS1, "")
 	assert.Error(t, err)
 }
+
+func TestRewriteLayerMediaTypes(t *testing.T) {
+	layerDigest := digest.FromString("layer")
+	s2 := manifest.Schema2FromComponents(manifest.Schema2Descriptor{MediaType: manifest.DockerV2Schema2ConfigMediaType, Digest: digest.FromString("config"), Size: 6},
+		[]manifest.Schema2Descriptor{{MediaType: manifest.DockerV2Schema2ForeignLayerMediaTypeGzip, Digest: layerDigest, Size: 5}})
+	s2Blob, err := s2.Serialize()
+	require.NoError(t, err)
+
+	// No matching layers
+	res, err := rewriteLayerMediaTypes(s2Blob, manifest.DockerV2Schema2MediaType, map[string]string{v1.MediaTypeImageLayerGzip: manifest.DockerV2Schema2LayerMediaType}, "")
+	require.NoError(t, err)
+	assert.Equal(t, s2Blob, res)
+
+	// Relabeling a nondistributable layer
+	rewrites := map[string]string{manifest.DockerV2Schema2ForeignLayerMediaTypeGzip: manifest.DockerV2Schema2LayerMediaType}
+	res, err = rewriteLayerMediaTypes(s2Blob, manifest.DockerV2Schema2MediaType, rewrites, "")
+	require.NoError(t, err)
+	updated, err := manifest.Schema2FromManifest(res)
+	require.NoError(t, err)
+	assert.Equal(t, manifest.DockerV2Schema2LayerMediaType, updated.LayersDescriptors[0].MediaType)
+	assert.Equal(t, layerDigest, updated.LayersDescriptors[0].Digest)
+	// … fails if the manifest can’t be modified
+	_, err = rewriteLayerMediaTypes(s2Blob, manifest.DockerV2Schema2MediaType, rewrites, "Would invalidate signatures")
+	assert.Error(t, err)
+	// … or if the layer was not copied
+	s2.LayersDescriptors[0].URLs = []string{"https://example.com/layer"}
+	s2WithURLs, err := s2.Serialize()
+	require.NoError(t, err)
+	_, err = rewriteLayerMediaTypes(s2WithURLs, manifest.DockerV2Schema2MediaType, rewrites, "")
+	assert.Error(t, err)
+
+	// OCI media types are not valid in schema2 manifests
+	_, err = rewriteLayerMediaTypes(s2Blob, manifest.DockerV2Schema2MediaType, map[string]string{manifest.DockerV2Schema2ForeignLayerMediaTypeGzip: v1.MediaTypeImageLayerGzip}, "")
+	assert.Error(t, err)
+
+	// OCI
+	oci := manifest.OCI1FromComponents(v1.Descriptor{MediaType: v1.MediaTypeImageConfig, Digest: digest.FromString("config"), Size: 6},
+		[]v1.Descriptor{{MediaType: v1.MediaTypeImageLayerGzip, Digest: layerDigest, Size: 5}})
+	ociBlob, err := oci.Serialize()
+	require.NoError(t, err)
+	res, err = rewriteLayerMediaTypes(ociBlob, v1.MediaTypeImageManifest, map[string]string{v1.MediaTypeImageLayerGzip: v1.MediaTypeImageLayerNonDistributableGzip}, "") //nolint:staticcheck // NonDistributable layers are deprecated, but we want to continue to support manipulating pre-existing images.
+	require.NoError(t, err)
+	updatedOCI, err := manifest.OCI1FromManifest(res)
+	require.NoError(t, err)
+	assert.Equal(t, v1.MediaTypeImageLayerNonDistributableGzip, updatedOCI.Layers[0].MediaType) //nolint:staticcheck // NonDistributable layers are deprecated, but we want to continue to support manipulating pre-existing images.
+
+	// OCI manifests can't contain Docker layer media types
+	_, err = rewriteLayerMediaTypes(ociBlob, v1.MediaTypeImageManifest, map[string]string{v1.MediaTypeImageLayerGzip: manifest.DockerV2Schema2LayerMediaType}, "")
+	assert.Error(t, err)
+
+	// Schema1 manifests are not modified
+	res, err = rewriteLayerMediaTypes([]byte("schema1"), manifest.DockerV2Schema1SignedMediaType, rewrites, "")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("schema1"), res)
+}
+
+func TestLayerMediaTypeRewritesApply(t *testing.T) {
+	layers := []types.BlobInfo{{MediaType: v1.MediaTypeImageLayerGzip}, {MediaType: v1.MediaTypeImageLayerZstd}}
+	assert.False(t, layerMediaTypeRewritesApply(layers, nil))
+	assert.False(t, layerMediaTypeRewritesApply(layers, map[string]string{manifest.DockerV2Schema2LayerMediaType: v1.MediaTypeImageLayerGzip}))
+	assert.False(t, layerMediaTypeRewritesApply(layers, map[string]string{v1.MediaTypeImageLayerGzip: v1.MediaTypeImageLayerGzip}))
+	assert.True(t, layerMediaTypeRewritesApply(layers, map[string]string{v1.MediaTypeImageLayerZstd: "application/vnd.example.layer+zstd"}))
+}
+
+func TestValidateLayerMediaTypeRewrites(t *testing.T) {
+	err := validateLayerMediaTypeRewrites(nil)
+	assert.NoError(t, err)
+	err = validateLayerMediaTypeRewrites(map[string]string{manifest.DockerV2Schema2LayerMediaType: v1.MediaTypeImageLayerGzip})
+	assert.NoError(t, err)
+	err = validateLayerMediaTypeRewrites(map[string]string{manifest.DockerV2Schema2LayerMediaType: v1.MediaTypeImageLayerZstd})
+	assert.Error(t, err)
+}
@@ -0,0 +1,86 @@
+package copy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/sirupsen/logrus"
+)
+
+// MissingBlobsError is returned by a copy with Options.MetadataOnly if some of the layers do not exist at the destination.
+type MissingBlobsError struct {
+	Destination string          // A transport-qualified name of the destination
+	Digests     []digest.Digest // All layers missing at the destination, in manifest order
+}
+
+func (e MissingBlobsError) Error() string {
+	digests := make([]string, 0, len(e.Digests))
+	for _, d := range e.Digests {
+		digests = append(digests, d.String())
+	}
+	return fmt.Sprintf("metadata-only copy to %s: %d layer(s) missing at the destination: %s", e.Destination, len(e.Digests), strings.Join(digests, ", "))
+}
+
+// validateMetadataOnly returns an error if options.MetadataOnly is combined with options which require reading the layers.
+func validateMetadataOnly(options *Options) error {
+	if !options.MetadataOnly {
+		return nil
+	}
+	switch {
+	case options.OciEncryptLayers != nil:
+		return errors.New("metadata-only copies can not encrypt layers")
+	case options.OciDecryptConfig != nil:
+		return errors.New("metadata-only copies can not decrypt layers")
+	case options.LayerScanner != nil:
+		return errors.New("metadata-only copies can not scan layers")
+	case len(options.EnsureCompressionVariantsExist) != 0:
+		return errors.New("metadata-only copies can not create compression variants")
+	case options.NormalizeLayers:
+		return errors.New("metadata-only copies can not normalize layers")
+	}
+	return nil
+}
+
+// reuseLayerForMetadataOnlyCopy is used instead of copyLayer with Options.MetadataOnly: it never reads the layer from the source,
+// and only accepts the exact srcInfo blob if it already exists at the destination (or can be mounted there).
+// It returns (blobInfo, false, nil) if the blob was reused, and (_, true, nil) if the blob is missing.
+func (ic *imageCopier) reuseLayerForMetadataOnlyCopy(ctx context.Context, srcInfo types.BlobInfo, layerIndex int, srcRef reference.Named, emptyLayer bool) (types.BlobInfo, bool, error) {
+	if ic.diffIDsAreNeeded {
+		return types.BlobInfo{}, false, errors.New("metadata-only copies can not compute layer DiffIDs required for the manifest conversion")
+	}
+	// See copyLayer.
+	if srcInfo.CompressionOperation == types.PreserveOriginal && srcInfo.CompressionAlgorithm == nil {
+		op, algo, err := compressionEditsFromBlobInfo(srcInfo)
+		if err != nil {
+			return types.BlobInfo{}, false, err
+		}
+		srcInfo.CompressionOperation = op
+		srcInfo.CompressionAlgorithm = algo
+	}
+
+	ic.c.printCopyInfo("blob", srcInfo)
+	reused, reusedBlob, err := ic.c.dest.TryReusingBlobWithOptions(ctx, srcInfo, private.TryReusingBlobOptions{
+		Cache:                   ic.c.blobInfoCache,
+		CanSubstitute:           false,
+		EmptyLayer:              emptyLayer,
+		LayerIndex:              &layerIndex,
+		SrcRef:                  srcRef,
+		PossibleManifestFormats: append([]string{ic.manifestConversionPlan.preferredMIMEType}, ic.manifestConversionPlan.otherMIMETypeCandidates...),
+		CandidateRepositories:   ic.c.options.BlobMountCandidates,
+	})
+	if err != nil {
+		return types.BlobInfo{}, false, fmt.Errorf("trying to reuse blob %s at destination: %w", srcInfo.Digest, err)
+	}
+	if !reused {
+		logrus.Debugf("Blob %s is missing at the destination", srcInfo.Digest)
+		return types.BlobInfo{}, true, nil
+	}
+	logrus.Debugf("Skipping blob %s (already present)", srcInfo.Digest)
+	return updatedBlobInfoFromReuse(srcInfo, reusedBlob), false, nil
+}
@@ -0,0 +1,47 @@
+package copy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containers/image/v5/directory"
+	"github.com/containers/image/v5/oci/layout"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageMetadataOnly(t *testing.T) {
+	layerData := bytes.Repeat([]byte("layer"), 1000)
+	srcDir, _ := createDirImage(t, layerData)
+	srcRef, err := directory.NewReference(srcDir)
+	require.NoError(t, err)
+	policyContext := newInsecureAcceptAnythingPolicyContext(t)
+
+	// Layers missing at the destination are reported
+	destDir := filepath.Join(t.TempDir(), "layout")
+	destRef, err := layout.NewReference(destDir, "metadata-only")
+	require.NoError(t, err)
+	_, err = Image(context.Background(), policyContext, destRef, srcRef, &Options{MetadataOnly: true})
+	var missingErr MissingBlobsError
+	require.True(t, errors.As(err, &missingErr), "%v", err)
+	assert.Equal(t, []digest.Digest{digest.FromBytes(layerData)}, missingErr.Digests)
+
+	// Layers already present at the destination are used
+	layerDigest := digest.FromBytes(layerData)
+	blobDir := filepath.Join(destDir, "blobs", layerDigest.Algorithm().String())
+	err = os.MkdirAll(blobDir, 0o755)
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(blobDir, layerDigest.Encoded()), layerData, 0o644)
+	require.NoError(t, err)
+	_, err = Image(context.Background(), policyContext, destRef, srcRef, &Options{MetadataOnly: true})
+	require.NoError(t, err)
+
+	// Options requiring layer contents are rejected
+	_, err = Image(context.Background(), policyContext, destRef, srcRef, &Options{MetadataOnly: true, OciEncryptLayers: &[]int{}})
+	assert.Error(t, err)
+}
@@ -11,7 +11,6 @@ import (
 	"strings"
 
 	"github.com/containers/image/v5/docker/reference"
-	"github.com/containers/image/v5/internal/image"
 	internalManifest "github.com/containers/image/v5/internal/manifest"
 	"github.com/containers/image/v5/internal/set"
 	"github.com/containers/image/v5/manifest"
@@ -98,6 +97,16 @@ func validateCompressionVariantExists(input []OptionCompressionVariant) error {
 	return nil
 }
 
+// isIncludedAttestationManifest returns true if an instance with annotations is an attestation manifest
+// which should be copied due to options.IncludeAttestationManifests.
+func isIncludedAttestationManifest(annotations map[string]string, options *Options) bool {
+	if !options.IncludeAttestationManifests {
+		return false
+	}
+	subject, ok := manifest.DockerAttestationManifestSubject(annotations)
+	return ok && slices.Contains(options.Instances, subject)
+}
+
 // prepareInstanceCopies prepares a list of instances which needs to copied to the manifest list.
 func prepareInstanceCopies(list internalManifest.List, instanceDigests []digest.Digest, options *Options) ([]instanceCopy, error) {
 	res := []instanceCopy{}
@@ -118,15 +127,16 @@ func prepareInstanceCopies(list internalManifest.List, instanceDigests []digest.
 		return nil, err
 	}
 	for i, instanceDigest := range instanceDigests {
-		if options.ImageListSelection == CopySpecificImages &&
-			!slices.Contains(options.Instances, instanceDigest) {
-			logrus.Debugf("Skipping instance %s (%d/%d)", instanceDigest, i+1, len(instanceDigests))
-			continue
-		}
 		instanceDetails, err := list.Instance(instanceDigest)
 		if err != nil {
 			return res, fmt.Errorf("getting details for instance %s: %w", instanceDigest, err)
 		}
+		if options.ImageListSelection == CopySpecificImages &&
+			!slices.Contains(options.Instances, instanceDigest) &&
+			!isIncludedAttestationManifest(instanceDetails.ReadOnly.Annotations, options) {
+			logrus.Debugf("Skipping instance %s (%d/%d)", instanceDigest, i+1, len(instanceDigests))
+			continue
+		}
 		forceCompressionFormat, err := shouldRequireCompressionFormatMatch(options)
 		if err != nil {
 			return nil, err
@@ -156,6 +166,60 @@ func prepareInstanceCopies(list internalManifest.List, instanceDigests []digest.
 	return res, nil
 }
 
+// skipFailedInstance handles a failure err to copy instance sourceDigest of a list:
+// it returns err if Options.ContinueOnInstanceFailure is not set, otherwise it records the failure, including in *failures.
+func (c *copier) skipFailedInstance(sourceDigest digest.Digest, err error, failures *[]error) error {
+	if !c.options.ContinueOnInstanceFailure {
+		return err
+	}
+	logrus.Warnf("Skipping image %s: %v", sourceDigest, err)
+	*failures = append(*failures, err)
+	if c.options.ReportInstanceFailures != nil {
+		*c.options.ReportInstanceFailures = append(*c.options.ReportInstanceFailures, InstanceCopyFailure{
+			SourceDigest: sourceDigest,
+			Err:          err,
+		})
+	}
+	return nil
+}
+
+// prunedInstanceEdits returns edits of list, modified to remove the instances with digests in failed,
+// and attestation manifests referring to them, instead of updating them.
+func prunedInstanceEdits(list internalManifest.List, edits []internalManifest.ListEdit, failed *set.Set[digest.Digest]) ([]internalManifest.ListEdit, error) {
+	removed := set.New[digest.Digest]()
+	res := []internalManifest.ListEdit{}
+	for _, d := range list.Instances() {
+		if removed.Contains(d) {
+			continue
+		}
+		remove := failed.Contains(d)
+		if !remove {
+			instance, err := list.Instance(d)
+			if err != nil {
+				return nil, fmt.Errorf("reading instance %s of manifest list: %w", d, err)
+			}
+			if subject, ok := manifest.DockerAttestationManifestSubject(instance.ReadOnly.Annotations); ok && failed.Contains(subject) {
+				remove = true
+			}
+		}
+		if remove {
+			logrus.Warnf("Removing image %s from the copied manifest list", d)
+			removed.Add(d)
+			res = append(res, internalManifest.ListEdit{
+				ListOperation: internalManifest.ListOpRemove,
+				RemoveDigest:  d,
+			})
+		}
+	}
+	for _, edit := range edits {
+		if edit.ListOperation == internalManifest.ListOpUpdate && removed.Contains(edit.UpdateOldDigest) {
+			continue
+		}
+		res = append(res, edit)
+	}
+	return res, nil
+}
+
 // copyMultipleImages copies some or all of an image list's instances, using
 // c.policyContext to validate source image admissibility.
 func (c *copier) copyMultipleImages(ctx context.Context) (copiedManifest []byte, retErr error) {
@@ -233,6 +297,8 @@ func (c *copier) copyMultipleImages(ctx context.Context) (copiedManifest []byte,
 		return nil, fmt.Errorf("preparing instances for copy: %w", err)
 	}
 	c.Printf("Copying %d images generated from %d images in list\n", len(instanceCopyList), len(instanceDigests))
+	instanceFailures := []error{}
+	failedInstances := set.New[digest.Digest]() // Source digests of instances skipped after failing to copy (not clones)
 	for i, instance := range instanceCopyList {
 		// Update instances to be edited by their `ListOperation` and
 		// populate necessary fields.
@@ -240,10 +306,15 @@ func (c *copier) copyMultipleImages(ctx context.Context) (copiedManifest []byte,
 		case instanceCopyCopy:
 			logrus.Debugf("Copying instance %s (%d/%d)", instance.sourceDigest, i+1, len(instanceCopyList))
 			c.Printf("Copying image %s (%d/%d)\n", instance.sourceDigest, i+1, len(instanceCopyList))
-			unparsedInstance := image.UnparsedInstance(c.rawSource, &instanceCopyList[i].sourceDigest)
+			unparsedInstance := c.unparsedInstance(&instanceCopyList[i].sourceDigest)
 			updated, err := c.copySingleImage(ctx, unparsedInstance, &instanceCopyList[i].sourceDigest, copySingleImageOptions{requireCompressionFormatMatch: instance.copyForceCompressionFormat})
 			if err != nil {
-				return nil, fmt.Errorf("copying image %d/%d from manifest list: %w", i+1, len(instanceCopyList), err)
+				err = fmt.Errorf("copying image %d/%d from manifest list: %w", i+1, len(instanceCopyList), err)
+				if err := c.skipFailedInstance(instance.sourceDigest, err, &instanceFailures); err != nil {
+					return nil, err
+				}
+				failedInstances.Add(instance.sourceDigest)
+				continue
 			}
 			// Record the result of a possible conversion here.
 			instanceEdits = append(instanceEdits, internalManifest.ListEdit{
@@ -256,13 +327,17 @@ func (c *copier) copyMultipleImages(ctx context.Context) (copiedManifest []byte,
 		case instanceCopyClone:
 			logrus.Debugf("Replicating instance %s (%d/%d)", instance.sourceDigest, i+1, len(instanceCopyList))
 			c.Printf("Replicating image %s (%d/%d)\n", instance.sourceDigest, i+1, len(instanceCopyList))
-			unparsedInstance := image.UnparsedInstance(c.rawSource, &instanceCopyList[i].sourceDigest)
+			unparsedInstance := c.unparsedInstance(&instanceCopyList[i].sourceDigest)
 			updated, err := c.copySingleImage(ctx, unparsedInstance, &instanceCopyList[i].sourceDigest, copySingleImageOptions{
 				requireCompressionFormatMatch: true,
 				compressionFormat:             &instance.cloneCompressionVariant.Algorithm,
 				compressionLevel:              instance.cloneCompressionVariant.Level})
 			if err != nil {
-				return nil, fmt.Errorf("replicating image %d/%d from manifest list: %w", i+1, len(instanceCopyList), err)
+				err = fmt.Errorf("replicating image %d/%d from manifest list: %w", i+1, len(instanceCopyList), err)
+				if err := c.skipFailedInstance(instance.sourceDigest, err, &instanceFailures); err != nil {
+					return nil, err
+				}
+				continue
 			}
 			// Record the result of a possible conversion here.
 			instanceEdits = append(instanceEdits, internalManifest.ListEdit{
@@ -280,6 +355,26 @@ func (c *copier) copyMultipleImages(ctx context.Context) (copiedManifest []byte,
 		}
 	}
 
+	if len(instanceCopyList) != 0 && len(instanceFailures) == len(instanceCopyList) {
+		return nil, fmt.Errorf("all images in the manifest list failed to copy: %w", errors.Join(instanceFailures...))
+	}
+
+	if !failedInstances.Empty() {
+		// Don’t write a list referencing instances which don’t exist at the destination.
+		if cannotModifyManifestListReason != "" {
+			return nil, fmt.Errorf("%d images in the manifest list failed to copy, and they can not be removed from the list: %q: %w",
+				len(instanceFailures), cannotModifyManifestListReason, errors.Join(instanceFailures...))
+		}
+		instanceEdits, err = prunedInstanceEdits(updatedList, instanceEdits, failedInstances)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if c.options.SortListInstances {
+		instanceEdits = append(instanceEdits, internalManifest.ListEdit{ListOperation: internalManifest.ListOpSort})
+	}
+
 	// Now reset the digest/size/types of the manifests in the list to account for any conversions that we made.
 	if err = updatedList.EditInstances(instanceEdits); err != nil {
 		return nil, fmt.Errorf("updating manifest list: %w", err)
@@ -332,6 +427,13 @@ func (c *copier) copyMultipleImages(ctx context.Context) (copiedManifest []byte,
 		}
 		errs = nil
 		manifestList = attemptedManifestList
+		if c.jsonProgress != nil {
+			manifestListDigest, err := manifest.Digest(manifestList)
+			if err != nil {
+				return nil, err
+			}
+			c.jsonProgress.manifestWritten(thisListType, manifestListDigest, len(manifestList))
+		}
 		break
 	}
 	if errs != nil {
@@ -339,7 +441,7 @@ func (c *copier) copyMultipleImages(ctx context.Context) (copiedManifest []byte,
 	}
 
 	// Sign the manifest list.
-	newSigs, err := c.createSignatures(ctx, manifestList, c.options.SignIdentity)
+	newSigs, referrerSigs, err := c.createSignatures(ctx, manifestList, c.options.SignIdentity)
 	if err != nil {
 		return nil, err
 	}
@@ -349,6 +451,9 @@ func (c *copier) copyMultipleImages(ctx context.Context) (copiedManifest []byte,
 	if err := c.dest.PutSignaturesWithFormat(ctx, sigs, nil); err != nil {
 		return nil, fmt.Errorf("writing signatures: %w", err)
 	}
+	if err := c.pushSignatureReferrers(ctx, manifestList, referrerSigs); err != nil {
+		return nil, err
+	}
 
 	return manifestList, nil
 }
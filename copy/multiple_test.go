@@ -1,13 +1,23 @@
 package copy
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 
 	internalManifest "github.com/containers/image/v5/internal/manifest"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/oci/layout"
 	"github.com/containers/image/v5/pkg/compression"
+	"github.com/containers/image/v5/types"
 	digest "github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -47,6 +57,46 @@ func TestPrepareCopyInstancesforInstanceCopyCopy(t *testing.T) {
 	require.EqualError(t, err, "cannot use ForceCompressionFormat with undefined default compression format")
 }
 
+func TestPrepareCopyInstancesAttestationManifests(t *testing.T) {
+	image1 := digest.Digest("sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")
+	image2 := digest.Digest("sha256:bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb")
+	attestation1 := digest.Digest("sha256:cccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccc")
+	attestation2 := digest.Digest("sha256:dddddddddddddddddddddddddddddddddddddddddddddddddddddddddddddddd")
+	index := manifest.OCI1IndexFromComponents([]imgspecv1.Descriptor{
+		{MediaType: imgspecv1.MediaTypeImageManifest, Digest: image1, Size: 1, Platform: &imgspecv1.Platform{OS: "linux", Architecture: "amd64"}},
+		{MediaType: imgspecv1.MediaTypeImageManifest, Digest: image2, Size: 1, Platform: &imgspecv1.Platform{OS: "linux", Architecture: "arm64"}},
+		{MediaType: imgspecv1.MediaTypeImageManifest, Digest: attestation1, Size: 1, Platform: &imgspecv1.Platform{OS: "unknown", Architecture: "unknown"},
+			Annotations: manifest.DockerAttestationManifestAnnotations(image1)},
+		{MediaType: imgspecv1.MediaTypeImageManifest, Digest: attestation2, Size: 1, Platform: &imgspecv1.Platform{OS: "unknown", Architecture: "unknown"},
+			Annotations: manifest.DockerAttestationManifestAnnotations(image2)},
+	}, nil)
+	indexBlob, err := index.Serialize()
+	require.NoError(t, err)
+	list, err := internalManifest.ListFromBlob(indexBlob, imgspecv1.MediaTypeImageIndex)
+	require.NoError(t, err)
+	sourceInstances := []digest.Digest{image1, image2, attestation1, attestation2}
+
+	for _, c := range []struct {
+		include  bool
+		expected []digest.Digest
+	}{
+		{false, []digest.Digest{image1}},
+		{true, []digest.Digest{image1, attestation1}},
+	} {
+		instancesToCopy, err := prepareInstanceCopies(list, sourceInstances, &Options{
+			ImageListSelection:          CopySpecificImages,
+			Instances:                   []digest.Digest{image1},
+			IncludeAttestationManifests: c.include,
+		})
+		require.NoError(t, err)
+		copied := []digest.Digest{}
+		for _, instance := range instancesToCopy {
+			copied = append(copied, instance.sourceDigest)
+		}
+		assert.Equal(t, c.expected, copied, c.include)
+	}
+}
+
 // Test `instanceCopyClone` cases.
 func TestPrepareCopyInstancesforInstanceCopyClone(t *testing.T) {
 	validManifest, err := os.ReadFile(filepath.Join("..", "internal", "manifest", "testdata", "oci1.index.zstd-selection.json"))
@@ -160,3 +210,172 @@ func convertInstanceCopyToSimplerInstanceCopy(copies []instanceCopy) []simplerIn
 	}
 	return res
 }
+
+// writeOCILayoutWithIndex creates an oci: layout tagged "latest" containing an index of one image per entry of layers,
+// and returns the directory and the digests of the instances. Layers which are nil are referenced but missing.
+func writeOCILayoutWithIndex(t *testing.T, layers [][]byte) (string, []digest.Digest) {
+	dir := t.TempDir()
+	writeBlob := func(blob []byte) imgspecv1.Descriptor {
+		d := digest.FromBytes(blob)
+		err := os.MkdirAll(filepath.Join(dir, "blobs", "sha256"), 0o755)
+		require.NoError(t, err)
+		err = os.WriteFile(filepath.Join(dir, "blobs", "sha256", d.Encoded()), blob, 0o644)
+		require.NoError(t, err)
+		return imgspecv1.Descriptor{Digest: d, Size: int64(len(blob))}
+	}
+
+	instances := []digest.Digest{}
+	descriptors := []imgspecv1.Descriptor{}
+	for i, layer := range layers {
+		layerDesc := imgspecv1.Descriptor{Digest: digest.FromString("missing"), Size: 7}
+		if layer != nil {
+			layerDesc = writeBlob(layer)
+		}
+		layerDesc.MediaType = imgspecv1.MediaTypeImageLayer
+		configDesc := writeBlob([]byte(fmt.Sprintf(`{"architecture":"arch%d","os":"linux","rootfs":{"type":"layers","diff_ids":["%s"]}}`, i, layerDesc.Digest)))
+		configDesc.MediaType = imgspecv1.MediaTypeImageConfig
+		manifestBlob, err := manifest.OCI1FromComponents(configDesc, []imgspecv1.Descriptor{layerDesc}).Serialize()
+		require.NoError(t, err)
+		manifestDesc := writeBlob(manifestBlob)
+		manifestDesc.MediaType = imgspecv1.MediaTypeImageManifest
+		manifestDesc.Platform = &imgspecv1.Platform{Architecture: fmt.Sprintf("arch%d", i), OS: "linux"}
+		descriptors = append(descriptors, manifestDesc)
+		instances = append(instances, manifestDesc.Digest)
+	}
+	indexBlob, err := manifest.OCI1IndexFromComponents(descriptors, nil).Serialize()
+	require.NoError(t, err)
+	indexDesc := writeBlob(indexBlob)
+	indexDesc.MediaType = imgspecv1.MediaTypeImageIndex
+	indexDesc.Annotations = map[string]string{imgspecv1.AnnotationRefName: "latest"}
+	topLevel, err := json.Marshal(imgspecv1.Index{Versioned: specs.Versioned{SchemaVersion: 2}, Manifests: []imgspecv1.Descriptor{indexDesc}})
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(dir, "index.json"), topLevel, 0o644)
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(dir, "oci-layout"), []byte(`{"imageLayoutVersion":"1.0.0"}`), 0o644)
+	require.NoError(t, err)
+	return dir, instances
+}
+
+func TestImageContinueOnInstanceFailure(t *testing.T) {
+	srcDir, instances := writeOCILayoutWithIndex(t, [][]byte{[]byte("layer 1"), nil, []byte("layer 3")})
+	srcRef, err := layout.NewReference(srcDir, "latest")
+	require.NoError(t, err)
+	policyContext := newInsecureAcceptAnythingPolicyContext(t)
+
+	// By default, the copy fails on the first failed instance.
+	destRef, err := layout.NewReference(t.TempDir(), "latest")
+	require.NoError(t, err)
+	_, err = Image(context.Background(), policyContext, destRef, srcRef, &Options{ImageListSelection: CopyAllImages})
+	assert.Error(t, err)
+
+	// With ContinueOnInstanceFailure, the other instances are copied, and failures are reported.
+	destDir := t.TempDir()
+	destRef, err = layout.NewReference(destDir, "latest")
+	require.NoError(t, err)
+	failures := []InstanceCopyFailure{}
+	copiedList, err := Image(context.Background(), policyContext, destRef, srcRef, &Options{
+		DestinationCtx:            &types.SystemContext{OCIAcceptUncompressedLayers: true}, // So that instance digests don’t change
+		ImageListSelection:        CopyAllImages,
+		ContinueOnInstanceFailure: true,
+		ReportInstanceFailures:    &failures,
+	})
+	require.NoError(t, err)
+	require.Len(t, failures, 1)
+	assert.Equal(t, instances[1], failures[0].SourceDigest)
+	assert.Error(t, failures[0].Err)
+	// The failed instance is removed from the copied list.
+	list, err := manifest.ListFromBlob(copiedList, manifest.GuessMIMEType(copiedList))
+	require.NoError(t, err)
+	assert.Equal(t, []digest.Digest{instances[0], instances[2]}, list.Instances())
+	for i, instance := range instances {
+		_, err := os.Stat(filepath.Join(destDir, "blobs", "sha256", instance.Encoded()))
+		if i == 1 {
+			assert.ErrorIs(t, err, fs.ErrNotExist)
+		} else {
+			assert.NoError(t, err)
+		}
+	}
+
+	// If the list can’t be modified, the copy fails.
+	destRef, err = layout.NewReference(t.TempDir(), "latest")
+	require.NoError(t, err)
+	_, err = Image(context.Background(), policyContext, destRef, srcRef, &Options{
+		DestinationCtx:            &types.SystemContext{OCIAcceptUncompressedLayers: true},
+		ImageListSelection:        CopyAllImages,
+		ContinueOnInstanceFailure: true,
+		PreserveDigests:           true,
+	})
+	assert.ErrorContains(t, err, "can not be removed from the list")
+
+	// If all instances fail, the copy fails.
+	srcDir, _ = writeOCILayoutWithIndex(t, [][]byte{nil, nil})
+	srcRef, err = layout.NewReference(srcDir, "latest")
+	require.NoError(t, err)
+	destRef, err = layout.NewReference(t.TempDir(), "latest")
+	require.NoError(t, err)
+	_, err = Image(context.Background(), policyContext, destRef, srcRef, &Options{
+		ImageListSelection:        CopyAllImages,
+		ContinueOnInstanceFailure: true,
+	})
+	assert.Error(t, err)
+}
+
+func TestImageSortListInstances(t *testing.T) {
+	srcDir, instances := writeOCILayoutWithIndex(t, [][]byte{[]byte("layer 1"), []byte("layer 2"), []byte("layer 3")})
+	// Reverse the order of instances in the source index.
+	topLevel, err := os.ReadFile(filepath.Join(srcDir, "index.json"))
+	require.NoError(t, err)
+	var topLevelIndex imgspecv1.Index
+	err = json.Unmarshal(topLevel, &topLevelIndex)
+	require.NoError(t, err)
+	indexBlob, err := os.ReadFile(filepath.Join(srcDir, "blobs", "sha256", topLevelIndex.Manifests[0].Digest.Encoded()))
+	require.NoError(t, err)
+	index, err := manifest.OCI1IndexFromManifest(indexBlob)
+	require.NoError(t, err)
+	slices.Reverse(index.Manifests)
+	indexBlob, err = index.Serialize()
+	require.NoError(t, err)
+	indexDigest := digest.FromBytes(indexBlob)
+	err = os.WriteFile(filepath.Join(srcDir, "blobs", "sha256", indexDigest.Encoded()), indexBlob, 0o644)
+	require.NoError(t, err)
+	topLevelIndex.Manifests[0].Digest = indexDigest
+	topLevelIndex.Manifests[0].Size = int64(len(indexBlob))
+	topLevel, err = json.Marshal(topLevelIndex)
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(srcDir, "index.json"), topLevel, 0o644)
+	require.NoError(t, err)
+
+	srcRef, err := layout.NewReference(srcDir, "latest")
+	require.NoError(t, err)
+	policyContext := newInsecureAcceptAnythingPolicyContext(t)
+	for _, c := range []struct {
+		sort     bool
+		expected []digest.Digest
+	}{
+		{false, []digest.Digest{instances[2], instances[1], instances[0]}},
+		{true, instances},
+	} {
+		destRef, err := layout.NewReference(t.TempDir(), "latest")
+		require.NoError(t, err)
+		copiedList, err := Image(context.Background(), policyContext, destRef, srcRef, &Options{
+			DestinationCtx:     &types.SystemContext{OCIAcceptUncompressedLayers: true}, // So that instance digests don’t change
+			ImageListSelection: CopyAllImages,
+			SortListInstances:  c.sort,
+		})
+		require.NoError(t, err)
+		list, err := manifest.ListFromBlob(copiedList, manifest.GuessMIMEType(copiedList))
+		require.NoError(t, err)
+		assert.Equal(t, c.expected, list.Instances(), c.sort)
+	}
+
+	// Sorting is not possible if the list digest must be preserved.
+	destRef, err := layout.NewReference(t.TempDir(), "latest")
+	require.NoError(t, err)
+	_, err = Image(context.Background(), policyContext, destRef, srcRef, &Options{
+		DestinationCtx:     &types.SystemContext{OCIAcceptUncompressedLayers: true},
+		ImageListSelection: CopyAllImages,
+		SortListInstances:  true,
+		PreserveDigests:    true,
+	})
+	assert.Error(t, err)
+}
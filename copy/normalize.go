@@ -0,0 +1,265 @@
+package copy
+
+import (
+	"archive/tar"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containers/image/v5/internal/tmpdir"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/sirupsen/logrus"
+)
+
+// whiteoutPrefix is the prefix of names of whiteout files in layers, see the OCI image specification.
+const whiteoutPrefix = ".wh."
+
+// layerNormalization is a normalization of a single layer, as requested by Options.NormalizeLayers.
+type layerNormalization struct {
+	sys    *types.SystemContext // Used to choose a directory for big temporary files
+	diffID digest.Digest        // DiffID of the normalized layer. WARNING: This is only set after the normalized stream is fully consumed.
+}
+
+// newLayerNormalization returns a *layerNormalization if layers being (re)compressed should be normalized, or nil.
+func (ic *imageCopier) newLayerNormalization() *layerNormalization {
+	if !ic.c.options.NormalizeLayers {
+		return nil
+	}
+	return &layerNormalization{sys: ic.c.options.DestinationCtx}
+}
+
+// normalize reads an uncompressed layer from src, writes a normalized equivalent to dest, and records its DiffID.
+// The layer is read completely before anything is written, because the entries need to be reordered.
+func (n *layerNormalization) normalize(dest io.Writer, src io.Reader) error {
+	spool, err := tmpdir.CreateBigFileTemp(n.sys, "normalized-layer")
+	if err != nil {
+		return fmt.Errorf("creating temporary file for normalizing a layer: %w", err)
+	}
+	defer func() {
+		spool.Close()
+		if err := os.Remove(spool.Name()); err != nil {
+			logrus.Debugf("Error removing temporary file %q: %v", spool.Name(), err)
+		}
+	}()
+
+	digester := digest.Canonical.Digester()
+	if err := normalizeLayerTar(io.MultiWriter(dest, digester.Hash()), src, spool); err != nil {
+		return fmt.Errorf("normalizing layer: %w", err)
+	}
+	n.diffID = digester.Digest()
+	return nil
+}
+
+// normalizedEntry is an entry of a layer being normalized.
+type normalizedEntry struct {
+	hdr    *tar.Header      // Already normalized using normalizedHeader; Name is not set.
+	offset int64            // Offset of the contents in the spool file, if hdr.Typeflag == tar.TypeReg
+	target *normalizedEntry // The target of a hard link, or nil
+}
+
+// normalizeLayerTar reads a tar stream from src, and writes a normalized equivalent to dest, using spool to store the file contents.
+// The normalized stream has entries ordered by path (with parent directories preceding their contents, and whiteout files preceding
+// their siblings), no user and group names, no PAX records other than extended attributes, and modification times rounded
+// to whole seconds. Hard links always refer to the first member of the set of linked paths.
+// If a path occurs several times in src, only the last entry is preserved, as it would be when extracting the layer.
+func normalizeLayerTar(dest io.Writer, src io.Reader, spool io.ReadWriteSeeker) error {
+	entries := map[string]*normalizedEntry{}
+	offset := int64(0)
+	tr := tar.NewReader(src)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		name := normalizedEntryName(hdr.Name)
+		if name == "" || hdr.Typeflag == tar.TypeXGlobalHeader {
+			continue // The root directory can not be meaningfully extracted, and global PAX records are not preserved.
+		}
+		entry := &normalizedEntry{hdr: normalizedHeader(hdr)}
+		switch hdr.Typeflag {
+		case tar.TypeReg, tar.TypeGNUSparse: // Sparse files are expanded by tar.Reader
+			entry.hdr.Typeflag = tar.TypeReg
+			n, err := io.Copy(spool, tr)
+			if err != nil {
+				return fmt.Errorf("storing contents of %q: %w", hdr.Name, err)
+			}
+			entry.hdr.Size = n
+			entry.offset = offset
+			offset += n
+		case tar.TypeLink:
+			target, ok := entries[normalizedEntryName(hdr.Linkname)]
+			if !ok {
+				return fmt.Errorf("hard link %q refers to %q, which is not present in the layer", hdr.Name, hdr.Linkname)
+			}
+			if target.target != nil {
+				target = target.target
+			}
+			if target.hdr.Typeflag == tar.TypeDir {
+				return fmt.Errorf("hard link %q refers to directory %q", hdr.Name, hdr.Linkname)
+			}
+			entry.target = target
+		case tar.TypeSymlink, tar.TypeChar, tar.TypeBlock, tar.TypeDir, tar.TypeFifo:
+		default:
+			return fmt.Errorf("unsupported type %q of tar entry %q", hdr.Typeflag, hdr.Name)
+		}
+		entries[name] = entry
+	}
+	// Consume the padding after the end of the archive as well, so that the digest of the input can be verified.
+	if _, err := io.Copy(io.Discard, src); err != nil {
+		return err
+	}
+
+	// Hard-linked paths are written as a file at the first of the paths, followed by links to it.
+	linkGroups := map[*normalizedEntry][]string{}
+	for name, entry := range entries {
+		if entry.target != nil {
+			linkGroups[entry.target] = append(linkGroups[entry.target], name)
+		} else if entry.hdr.Typeflag != tar.TypeDir {
+			linkGroups[entry] = append(linkGroups[entry], name)
+		}
+	}
+	for target, names := range linkGroups {
+		leader := slices.MinFunc(names, compareNormalizedPaths)
+		for _, name := range names {
+			if name == leader {
+				entries[name] = target
+				continue
+			}
+			linkHdr := *target.hdr
+			linkHdr.Typeflag = tar.TypeLink
+			linkHdr.Linkname = leader
+			linkHdr.Size = 0
+			entries[name] = &normalizedEntry{hdr: &linkHdr}
+		}
+	}
+
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	slices.SortFunc(names, compareNormalizedPaths)
+	tw := tar.NewWriter(dest)
+	for _, name := range names {
+		entry := entries[name]
+		hdr := *entry.hdr
+		hdr.Name = name
+		if hdr.Typeflag == tar.TypeDir {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(&hdr); err != nil {
+			return err
+		}
+		if hdr.Typeflag == tar.TypeReg && hdr.Size != 0 {
+			if _, err := spool.Seek(entry.offset, io.SeekStart); err != nil {
+				return err
+			}
+			if _, err := io.CopyN(tw, spool, hdr.Size); err != nil {
+				return fmt.Errorf("writing contents of %q: %w", name, err)
+			}
+		}
+	}
+	return tw.Close()
+}
+
+// normalizedEntryName returns a normalized form of a path in a layer: relative, cleaned, and without a trailing slash.
+func normalizedEntryName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// normalizedHeader returns a copy of hdr without data which is not relevant to the contents of the layer.
+func normalizedHeader(hdr *tar.Header) *tar.Header {
+	res := &tar.Header{
+		Typeflag: hdr.Typeflag,
+		Linkname: hdr.Linkname,
+		Size:     hdr.Size,
+		Mode:     hdr.Mode,
+		Uid:      hdr.Uid,
+		Gid:      hdr.Gid,
+		ModTime:  hdr.ModTime.Round(time.Second),
+		Devmajor: hdr.Devmajor,
+		Devminor: hdr.Devminor,
+	}
+	if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeGNUSparse {
+		res.Size = 0
+	}
+	for key, value := range hdr.PAXRecords {
+		if strings.HasPrefix(key, "SCHILY.xattr.") {
+			if res.PAXRecords == nil {
+				res.PAXRecords = map[string]string{}
+			}
+			res.PAXRecords[key] = value
+		}
+	}
+	return res
+}
+
+// compareNormalizedPaths orders paths in a normalized layer component by component, so that directories precede their contents,
+// and whiteout files precede their siblings (as recommended by the OCI image specification).
+func compareNormalizedPaths(a, b string) int {
+	aComponents := strings.Split(a, "/")
+	bComponents := strings.Split(b, "/")
+	for i := 0; i < len(aComponents) && i < len(bComponents); i++ {
+		if aComponents[i] == bComponents[i] {
+			continue
+		}
+		aWhiteout := strings.HasPrefix(aComponents[i], whiteoutPrefix)
+		bWhiteout := strings.HasPrefix(bComponents[i], whiteoutPrefix)
+		if aWhiteout != bWhiteout {
+			if aWhiteout {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(aComponents[i], bComponents[i])
+	}
+	return cmp.Compare(len(aComponents), len(bComponents))
+}
+
+// normalizedLayerDiffIDs collects DiffIDs of layers normalized during a copy of a single image.
+type normalizedLayerDiffIDs struct {
+	mutex   sync.Mutex
+	diffIDs map[int]digest.Digest // Indexed by layer index
+}
+
+// record records diffID as the DiffID of the normalized layer at layerIndex.
+func (n *normalizedLayerDiffIDs) record(layerIndex int, diffID digest.Digest) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if n.diffIDs == nil {
+		n.diffIDs = map[int]digest.Digest{}
+	}
+	n.diffIDs[layerIndex] = diffID
+}
+
+// diffID returns the DiffID of the normalized layer at layerIndex, or "" if the layer was not normalized.
+func (n *normalizedLayerDiffIDs) diffID(layerIndex int) digest.Digest {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return n.diffIDs[layerIndex]
+}
+
+// layerUpdates returns a value for types.ManifestUpdateOptions.LayerDiffIDs for an image with layerCount layers,
+// or nil if no layers were normalized.
+func (n *normalizedLayerDiffIDs) layerUpdates(layerCount int) []digest.Digest {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if len(n.diffIDs) == 0 {
+		return nil
+	}
+	res := make([]digest.Digest, layerCount)
+	for i, d := range n.diffIDs {
+		res[i] = d
+	}
+	return res
+}
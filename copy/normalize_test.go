@@ -0,0 +1,194 @@
+package copy
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/containers/image/v5/directory"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testTarEntry is an entry of a tar stream created by createTestTar.
+type testTarEntry struct {
+	hdr      tar.Header
+	contents string
+}
+
+// createTestTar returns a tar stream with entries.
+func createTestTar(t *testing.T, entries []testTarEntry) []byte {
+	buf := bytes.Buffer{}
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := e.hdr
+		hdr.Size = int64(len(e.contents))
+		err := tw.WriteHeader(&hdr)
+		require.NoError(t, err)
+		_, err = tw.Write([]byte(e.contents))
+		require.NoError(t, err)
+	}
+	err := tw.Close()
+	require.NoError(t, err)
+	return buf.Bytes()
+}
+
+// differentlyBuiltTestLayers returns two tar streams with the same filesystem contents, but different tar metadata.
+func differentlyBuiltTestLayers(t *testing.T) ([]byte, []byte) {
+	modTime := time.Unix(1700000000, 0)
+	first := createTestTar(t, []testTarEntry{
+		{hdr: tar.Header{Typeflag: tar.TypeDir, Name: "usr/", Mode: 0o755, ModTime: modTime, Uname: "root", Gname: "root"}},
+		{hdr: tar.Header{Typeflag: tar.TypeReg, Name: "usr/b", Mode: 0o644, ModTime: modTime, Uname: "root", Gname: "root"}, contents: "shared"},
+		{hdr: tar.Header{Typeflag: tar.TypeLink, Name: "usr/a", Linkname: "usr/b", ModTime: modTime}},
+		{hdr: tar.Header{Typeflag: tar.TypeReg, Name: "usr/.wh.removed", Mode: 0o644, ModTime: modTime}},
+		{hdr: tar.Header{Typeflag: tar.TypeSymlink, Name: "usr/link", Linkname: "b", Mode: 0o777, ModTime: modTime}},
+		{hdr: tar.Header{Typeflag: tar.TypeReg, Name: "etc-file", Mode: 0o600, ModTime: modTime, Uname: "root", Gname: "root"}, contents: "old"},
+		{hdr: tar.Header{Typeflag: tar.TypeReg, Name: "etc-file", Mode: 0o600, ModTime: modTime, Uname: "root", Gname: "root"}, contents: "config"},
+	})
+	second := createTestTar(t, []testTarEntry{
+		{hdr: tar.Header{Typeflag: tar.TypeDir, Name: "./", Mode: 0o755, ModTime: modTime}},
+		{hdr: tar.Header{Typeflag: tar.TypeReg, Name: "./etc-file", Mode: 0o600, ModTime: modTime.Add(100 * time.Millisecond), Format: tar.FormatPAX,
+			PAXRecords: map[string]string{"atime": "1700000001"}}, contents: "config"},
+		{hdr: tar.Header{Typeflag: tar.TypeDir, Name: "./usr", Mode: 0o755, ModTime: modTime, Uname: "builder", Gname: "builder"}},
+		{hdr: tar.Header{Typeflag: tar.TypeSymlink, Name: "./usr/link", Linkname: "b", Mode: 0o777, ModTime: modTime}},
+		{hdr: tar.Header{Typeflag: tar.TypeReg, Name: "./usr/a", Mode: 0o644, ModTime: modTime}, contents: "shared"},
+		{hdr: tar.Header{Typeflag: tar.TypeLink, Name: "./usr/b", Linkname: "./usr/a", ModTime: modTime}},
+		{hdr: tar.Header{Typeflag: tar.TypeReg, Name: "./usr/.wh.removed", Mode: 0o644, ModTime: modTime}},
+	})
+	return first, second
+}
+
+func TestNormalizeLayerTar(t *testing.T) {
+	first, second := differentlyBuiltTestLayers(t)
+	require.NotEqual(t, first, second)
+
+	normalized := [][]byte{}
+	for _, input := range [][]byte{first, second} {
+		spool, err := os.CreateTemp(t.TempDir(), "spool")
+		require.NoError(t, err)
+		defer spool.Close()
+		buf := bytes.Buffer{}
+		err = normalizeLayerTar(&buf, bytes.NewReader(input), spool)
+		require.NoError(t, err)
+		normalized = append(normalized, buf.Bytes())
+	}
+	assert.Equal(t, normalized[0], normalized[1])
+
+	tr := tar.NewReader(bytes.NewReader(normalized[0]))
+	names := []string{}
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, hdr.Name)
+		assert.Empty(t, hdr.Uname)
+		assert.Empty(t, hdr.Gname)
+		assert.Empty(t, hdr.PAXRecords)
+		switch hdr.Name {
+		case "usr/a":
+			assert.Equal(t, byte(tar.TypeReg), hdr.Typeflag)
+			contents, err := io.ReadAll(tr)
+			require.NoError(t, err)
+			assert.Equal(t, "shared", string(contents))
+		case "usr/b":
+			assert.Equal(t, byte(tar.TypeLink), hdr.Typeflag)
+			assert.Equal(t, "usr/a", hdr.Linkname)
+		case "etc-file":
+			contents, err := io.ReadAll(tr)
+			require.NoError(t, err)
+			assert.Equal(t, "config", string(contents))
+		}
+	}
+	assert.Equal(t, []string{"etc-file", "usr/", "usr/.wh.removed", "usr/a", "usr/b", "usr/link"}, names)
+
+	// Hard links to paths which are not in the layer are rejected.
+	invalid := createTestTar(t, []testTarEntry{
+		{hdr: tar.Header{Typeflag: tar.TypeLink, Name: "a", Linkname: "missing"}},
+	})
+	spool, err := os.CreateTemp(t.TempDir(), "spool")
+	require.NoError(t, err)
+	defer spool.Close()
+	err = normalizeLayerTar(io.Discard, bytes.NewReader(invalid), spool)
+	assert.Error(t, err)
+}
+
+func TestCompareNormalizedPaths(t *testing.T) {
+	for _, c := range []struct{ a, b string }{
+		{"a", "a/b"},
+		{"a/b", "a-b"},
+		{"a/.wh.b", "a/-"},
+		{"a/.wh..wh..opq", "a/.wh.b"},
+		{"a/z", "b"},
+	} {
+		assert.Equal(t, -1, compareNormalizedPaths(c.a, c.b), c)
+		assert.Equal(t, 1, compareNormalizedPaths(c.b, c.a), c)
+	}
+	assert.Equal(t, 0, compareNormalizedPaths("a/b", "a/b"))
+}
+
+func TestImageNormalizeLayers(t *testing.T) {
+	first, second := differentlyBuiltTestLayers(t)
+	policyContext := newInsecureAcceptAnythingPolicyContext(t)
+
+	// copyCompressed copies an image with layer to a new directory, compressing the layer, and returns the layer digest and the DiffID in the config.
+	copyCompressed := func(layer []byte, normalize bool) (digest.Digest, digest.Digest) {
+		srcDir, _ := createDirImage(t, layer)
+		srcRef, err := directory.NewReference(srcDir)
+		require.NoError(t, err)
+		destDir := t.TempDir()
+		destRef, err := directory.NewReference(destDir)
+		require.NoError(t, err)
+		manifestBlob, err := Image(context.Background(), policyContext, destRef, srcRef, &Options{
+			DestinationCtx:  &types.SystemContext{DirForceCompress: true},
+			NormalizeLayers: normalize,
+		})
+		require.NoError(t, err)
+		m, err := manifest.OCI1FromManifest(manifestBlob)
+		require.NoError(t, err)
+		require.Len(t, m.Layers, 1)
+		assert.Equal(t, imgspecv1.MediaTypeImageLayerGzip, m.Layers[0].MediaType)
+
+		configBlob, err := os.ReadFile(filepath.Join(destDir, m.Config.Digest.Encoded()))
+		require.NoError(t, err)
+		var config imgspecv1.Image
+		err = json.Unmarshal(configBlob, &config)
+		require.NoError(t, err)
+		require.Len(t, config.RootFS.DiffIDs, 1)
+		// The DiffID in the config matches the uncompressed layer.
+		layerFile, err := os.Open(filepath.Join(destDir, m.Layers[0].Digest.Encoded()))
+		require.NoError(t, err)
+		defer layerFile.Close()
+		uncompressed, err := gzip.NewReader(layerFile)
+		require.NoError(t, err)
+		diffID, err := digest.Canonical.FromReader(uncompressed)
+		require.NoError(t, err)
+		assert.Equal(t, config.RootFS.DiffIDs[0], diffID)
+		return m.Layers[0].Digest, diffID
+	}
+
+	firstDigest, firstDiffID := copyCompressed(first, false)
+	secondDigest, secondDiffID := copyCompressed(second, false)
+	assert.NotEqual(t, firstDigest, secondDigest)
+	assert.Equal(t, digest.FromBytes(first), firstDiffID)
+	assert.Equal(t, digest.FromBytes(second), secondDiffID)
+
+	firstDigest, firstDiffID = copyCompressed(first, true)
+	secondDigest, secondDiffID = copyCompressed(second, true)
+	assert.Equal(t, firstDigest, secondDigest)
+	assert.Equal(t, firstDiffID, secondDiffID)
+	assert.NotEqual(t, digest.FromBytes(first), firstDiffID)
+}
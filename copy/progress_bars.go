@@ -145,11 +145,12 @@ func (bar *progressBar) mark100PercentComplete() {
 	}
 }
 
-// blobChunkAccessorProxy wraps a BlobChunkAccessor and updates a *progressBar
+// blobChunkAccessorProxy wraps a BlobChunkAccessor and updates a *progressBar and resource accounting
 // with the number of received bytes.
 type blobChunkAccessorProxy struct {
-	wrapped private.BlobChunkAccessor // The underlying BlobChunkAccessor
-	bar     *progressBar              // A progress bar updated with the number of bytes read so far
+	wrapped   private.BlobChunkAccessor // The underlying BlobChunkAccessor
+	bar       *progressBar              // A progress bar updated with the number of bytes read so far
+	resources *resourceAccounting       // Updated with the number of bytes read so far; may be nil
 }
 
 // GetBlobAt returns a sequential channel of readers that contain data for the requested
@@ -161,6 +162,14 @@ type blobChunkAccessorProxy struct {
 // fully fetches the remaining data from the offset to the end of the blob.
 func (s *blobChunkAccessorProxy) GetBlobAt(ctx context.Context, info types.BlobInfo, chunks []private.ImageSourceChunk) (chan io.ReadCloser, chan error, error) {
 	start := time.Now()
+	for _, c := range chunks {
+		// Chunks with an unknown length are not accounted.
+		if c.Length != math.MaxUint64 {
+			if err := s.resources.addDownloaded(int64(c.Length)); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
 	rc, errs, err := s.wrapped.GetBlobAt(ctx, info, chunks)
 	if err == nil {
 		total := int64(0)
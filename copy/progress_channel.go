@@ -77,3 +77,18 @@ func (r *progressReader) Read(p []byte) (int, error) {
 	}
 	return n, err
 }
+
+// withArchiveProgress returns sys, modified to report progress of writing or extracting archives to options.Progress,
+// if progress reporting is requested in options and sys does not set types.SystemContext.ArchiveProgress.
+func withArchiveProgress(sys *types.SystemContext, options *Options) *types.SystemContext {
+	if options.Progress == nil || options.ProgressInterval <= 0 || (sys != nil && sys.ArchiveProgress != nil) {
+		return sys
+	}
+	res := types.SystemContext{}
+	if sys != nil {
+		res = *sys
+	}
+	res.ArchiveProgress = options.Progress
+	res.ArchiveProgressInterval = options.ProgressInterval
+	return &res
+}
@@ -2,12 +2,17 @@ package copy
 
 import (
 	"bytes"
+	"context"
 	"io"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/containers/image/v5/directory"
+	ociarchive "github.com/containers/image/v5/oci/archive"
 	"github.com/containers/image/v5/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func newSUT(
@@ -78,3 +83,36 @@ func TestReadWithEvent(t *testing.T) {
 	assert.Nil(t, err)
 
 }
+
+func TestImageArchiveProgress(t *testing.T) {
+	srcDir, _ := createDirImage(t, []byte("layer contents"))
+	srcRef, err := directory.NewReference(srcDir)
+	require.NoError(t, err)
+	destRef, err := ociarchive.NewReference(filepath.Join(t.TempDir(), "archive.tar"), "")
+	require.NoError(t, err)
+	policyContext := newInsecureAcceptAnythingPolicyContext(t)
+
+	channel := make(chan types.ProgressProperties)
+	eventsDone := make(chan []types.ProgressProperties)
+	go func() {
+		events := []types.ProgressProperties{}
+		for e := range channel {
+			if e.Event == types.ProgressEventArchivePacking || e.Event == types.ProgressEventArchivePacked {
+				events = append(events, e)
+			}
+		}
+		eventsDone <- events
+	}()
+	_, err = Image(context.Background(), policyContext, destRef, srcRef, &Options{
+		Progress:         channel,
+		ProgressInterval: time.Millisecond,
+	})
+	close(channel)
+	events := <-eventsDone
+	require.NoError(t, err)
+	require.NotEmpty(t, events)
+	last := events[len(events)-1]
+	assert.Equal(t, types.ProgressEventArchivePacked, last.Event)
+	assert.NotZero(t, last.Offset)
+	assert.NotZero(t, last.ArchiveEntries)
+}
@@ -0,0 +1,151 @@
+package copy
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sirupsen/logrus"
+)
+
+// Values of JSONProgressEvent.Event.
+// Warning: new event types may be added any time; consumers should ignore events they don’t recognize.
+const (
+	// Copying of a blob has started.
+	JSONProgressEventNewArtifact = "new-artifact"
+	// A blob is being copied; reported at most once per the progress interval.
+	JSONProgressEventRead = "read"
+	// Copying of a blob has finished.
+	JSONProgressEventDone = "done"
+	// A blob has been skipped because it is already available at the destination.
+	JSONProgressEventSkipped = "skipped"
+	// A manifest, or a manifest list, has been written to the destination.
+	JSONProgressEventManifestWritten = "manifest-written"
+)
+
+// defaultJSONProgressInterval is the interval of JSONProgressEventRead events if Options.ProgressInterval is not set.
+const defaultJSONProgressInterval = time.Second
+
+// JSONProgressEvent is a single line written to Options.ProgressJSONWriter.
+type JSONProgressEvent struct {
+	// One of the JSONProgressEvent* values.
+	Event string `json:"event"`
+	// The time the event was emitted.
+	Time time.Time `json:"time"`
+	// The blob, or the manifest, the event relates to.
+	// Blob media types are not always known while copying; in that case MediaType is empty.
+	Descriptor imgspecv1.Descriptor `json:"descriptor"`
+	// The number of bytes of the blob read so far, for JSONProgressEventRead and JSONProgressEventDone.
+	Offset uint64 `json:"offset,omitempty"`
+	// The number of bytes of the blob read since the previous event, for JSONProgressEventRead and JSONProgressEventDone.
+	OffsetUpdate uint64 `json:"offsetUpdate,omitempty"`
+}
+
+// jsonProgressWriter writes JSONProgressEvents to a writer, as JSON lines.
+type jsonProgressWriter struct {
+	channel   chan types.ProgressProperties // Blob progress, to be written and forwarded
+	manifests chan JSONProgressEvent        // Manifest events; handled by run() so that they are ordered after all preceding blob progress
+	forward   chan<- types.ProgressProperties
+	done      chan struct{}
+
+	mutex   sync.Mutex // Protects the following members
+	encoder *json.Encoder
+	failed  bool // A write has failed; we don’t write any more events.
+}
+
+// newJSONProgressWriter returns a jsonProgressWriter which writes to w, and also forwards blob progress to forward, if not nil.
+// The caller must call close() when done.
+func newJSONProgressWriter(w io.Writer, forward chan<- types.ProgressProperties) *jsonProgressWriter {
+	res := &jsonProgressWriter{
+		channel:   make(chan types.ProgressProperties),
+		manifests: make(chan JSONProgressEvent),
+		forward:   forward,
+		done:      make(chan struct{}),
+		encoder:   json.NewEncoder(w),
+	}
+	go res.run()
+	return res
+}
+
+// run handles events sent to p.channel and p.manifests, until p.channel is closed.
+func (p *jsonProgressWriter) run() {
+	defer close(p.done)
+	for {
+		select {
+		case props, ok := <-p.channel:
+			if !ok {
+				return
+			}
+			p.handleBlobProgress(props)
+		case event := <-p.manifests:
+			p.write(event)
+		}
+	}
+}
+
+// handleBlobProgress writes, and forwards, a single blob progress event.
+func (p *jsonProgressWriter) handleBlobProgress(props types.ProgressProperties) {
+	event := JSONProgressEvent{
+		Descriptor: imgspecv1.Descriptor{
+			MediaType: props.Artifact.MediaType,
+			Digest:    props.Artifact.Digest,
+			Size:      props.Artifact.Size,
+		},
+		Offset:       props.Offset,
+		OffsetUpdate: props.OffsetUpdate,
+	}
+	switch props.Event {
+	case types.ProgressEventNewArtifact:
+		event.Event = JSONProgressEventNewArtifact
+	case types.ProgressEventRead:
+		event.Event = JSONProgressEventRead
+	case types.ProgressEventDone:
+		event.Event = JSONProgressEventDone
+	case types.ProgressEventSkipped:
+		event.Event = JSONProgressEventSkipped
+	}
+	if event.Event != "" {
+		p.write(event)
+	}
+	if p.forward != nil {
+		p.forward <- props
+	}
+}
+
+// manifestWritten records that a manifest with mimeType, manifestDigest and size was written to the destination.
+// The event is written by run(), i.e. only after all blob progress sent before this call.
+func (p *jsonProgressWriter) manifestWritten(mimeType string, manifestDigest digest.Digest, size int) {
+	p.manifests <- JSONProgressEvent{
+		Event: JSONProgressEventManifestWritten,
+		Descriptor: imgspecv1.Descriptor{
+			MediaType: mimeType,
+			Digest:    manifestDigest,
+			Size:      int64(size),
+		},
+	}
+}
+
+// write writes event.
+func (p *jsonProgressWriter) write(event JSONProgressEvent) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.failed {
+		return
+	}
+	event.Time = time.Now()
+	if err := p.encoder.Encode(event); err != nil {
+		logrus.Warnf("Error writing JSON progress, not reporting any more progress: %v", err)
+		p.failed = true
+	}
+}
+
+// close waits for all blob progress to be written.
+func (p *jsonProgressWriter) close() {
+	close(p.channel)
+	<-p.done
+}
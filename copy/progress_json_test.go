@@ -0,0 +1,68 @@
+package copy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/containers/image/v5/directory"
+	"github.com/containers/image/v5/manifest"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageProgressJSONWriter(t *testing.T) {
+	srcDir, blobsSize := createDirImage(t, []byte("layer contents"))
+	srcRef, err := directory.NewReference(srcDir)
+	require.NoError(t, err)
+	destRef, err := directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+	policyContext := newInsecureAcceptAnythingPolicyContext(t)
+
+	var output bytes.Buffer
+	copiedManifest, err := Image(context.Background(), policyContext, destRef, srcRef, &Options{
+		ProgressJSONWriter: &output,
+	})
+	require.NoError(t, err)
+
+	decoder := json.NewDecoder(&output)
+	events := []JSONProgressEvent{}
+	for {
+		var event JSONProgressEvent
+		err := decoder.Decode(&event)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		assert.False(t, event.Time.IsZero())
+		events = append(events, event)
+	}
+
+	started := map[digest.Digest]bool{}
+	doneSize := int64(0)
+	for _, e := range events[:len(events)-1] {
+		switch e.Event {
+		case JSONProgressEventNewArtifact:
+			started[e.Descriptor.Digest] = true
+		case JSONProgressEventDone:
+			assert.True(t, started[e.Descriptor.Digest])
+			assert.Equal(t, uint64(e.Descriptor.Size), e.Offset)
+			doneSize += e.Descriptor.Size
+		case JSONProgressEventRead:
+		default:
+			assert.Failf(t, "Unexpected event", "%#v", e)
+		}
+	}
+	assert.Len(t, started, 2)
+	assert.Equal(t, blobsSize, doneSize)
+
+	last := events[len(events)-1]
+	assert.Equal(t, JSONProgressEventManifestWritten, last.Event)
+	assert.Equal(t, manifest.GuessMIMEType(copiedManifest), last.Descriptor.MediaType)
+	assert.Equal(t, digest.FromBytes(copiedManifest), last.Descriptor.Digest)
+	assert.Equal(t, int64(len(copiedManifest)), last.Descriptor.Size)
+}
@@ -0,0 +1,152 @@
+package copy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	imgspec "github.com/opencontainers/image-spec/specs-go"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sirupsen/logrus"
+)
+
+// ReferrerGenerator can be set in Options.ReferrerGenerator to generate artifacts describing the copied image,
+// e.g. an SBOM or a provenance attestation. copy.Image pushes the generated artifacts to the destination
+// as OCI artifact manifests referring to the copied image using their “subject” field.
+type ReferrerGenerator interface {
+	// GenerateReferrers is called after the manifest of the copied image has been written to the destination.
+	// subject describes that manifest, and manifestBlob contains it exactly as written.
+	// If the copied image is a manifest list, GenerateReferrers is called only once, for the list.
+	// If GenerateReferrers fails, the copy fails.
+	GenerateReferrers(ctx context.Context, subject imgspecv1.Descriptor, manifestBlob []byte) ([]ReferrerArtifact, error)
+}
+
+// ReferrerArtifact is an artifact generated by a ReferrerGenerator.
+type ReferrerArtifact struct {
+	ArtifactType string                 // The IANA media type of the artifact, e.g. "application/spdx+json"; required.
+	Blobs        []ReferrerArtifactBlob // The contents of the artifact; may be empty if the artifact consists only of annotations.
+	Annotations  map[string]string      // Annotations of the artifact manifest; optional.
+}
+
+// ReferrerArtifactBlob is a single blob of a ReferrerArtifact.
+type ReferrerArtifactBlob struct {
+	MediaType   string
+	Data        []byte
+	Annotations map[string]string // Annotations of the blob descriptor; optional.
+}
+
+// pushReferrers calls c.options.ReferrerGenerator for the just-written manifestBlob, if it is set,
+// and pushes the generated artifacts to c.dest.
+func (c *copier) pushReferrers(ctx context.Context, manifestBlob []byte) error {
+	if c.options.ReferrerGenerator == nil {
+		return nil
+	}
+	subject, err := referrerSubject(manifestBlob)
+	if err != nil {
+		return err
+	}
+	manifestDigest := subject.Digest
+	artifacts, err := c.options.ReferrerGenerator.GenerateReferrers(ctx, subject, manifestBlob)
+	if err != nil {
+		return fmt.Errorf("generating referrers of %s: %w", manifestDigest, err)
+	}
+	for i, artifact := range artifacts {
+		desc, err := c.pushReferrer(ctx, subject, artifact)
+		if err != nil {
+			return fmt.Errorf("pushing referrer %d (%s) of %s: %w", i+1, artifact.ArtifactType, manifestDigest, err)
+		}
+		logrus.Debugf("Pushed referrer %s (%s) of %s", desc.Digest, artifact.ArtifactType, manifestDigest)
+		c.reportReferrer(desc)
+	}
+	return nil
+}
+
+// referrerSubject returns a descriptor of manifestBlob, for use as the “subject” of referrer artifacts.
+func referrerSubject(manifestBlob []byte) (imgspecv1.Descriptor, error) {
+	manifestDigest, err := manifest.Digest(manifestBlob)
+	if err != nil {
+		return imgspecv1.Descriptor{}, err
+	}
+	return imgspecv1.Descriptor{
+		MediaType: manifest.GuessMIMEType(manifestBlob),
+		Digest:    manifestDigest,
+		Size:      int64(len(manifestBlob)),
+	}, nil
+}
+
+// reportReferrer records desc, a descriptor of a pushed referrer artifact manifest, in c.options.ReportReferrers, if set.
+func (c *copier) reportReferrer(desc imgspecv1.Descriptor) {
+	if c.options.ReportReferrers != nil {
+		*c.options.ReportReferrers = append(*c.options.ReportReferrers, desc)
+	}
+}
+
+// pushReferrer pushes artifact, referring to subject, to c.dest, and returns a descriptor of the artifact manifest.
+func (c *copier) pushReferrer(ctx context.Context, subject imgspecv1.Descriptor, artifact ReferrerArtifact) (imgspecv1.Descriptor, error) {
+	if artifact.ArtifactType == "" {
+		return imgspecv1.Descriptor{}, errors.New("artifact type is not set")
+	}
+	config := imgspecv1.DescriptorEmptyJSON
+	if err := c.putReferrerBlob(ctx, config, []byte("{}"), true); err != nil {
+		return imgspecv1.Descriptor{}, err
+	}
+	layers := []imgspecv1.Descriptor{}
+	for _, blob := range artifact.Blobs {
+		desc := imgspecv1.Descriptor{
+			MediaType:   blob.MediaType,
+			Digest:      digest.FromBytes(blob.Data),
+			Size:        int64(len(blob.Data)),
+			Annotations: blob.Annotations,
+		}
+		if err := c.putReferrerBlob(ctx, desc, blob.Data, false); err != nil {
+			return imgspecv1.Descriptor{}, err
+		}
+		layers = append(layers, desc)
+	}
+	if len(layers) == 0 {
+		// The image-spec recommends using the empty descriptor if there are no blobs; the config blob has already been written.
+		layers = append(layers, imgspecv1.DescriptorEmptyJSON)
+	}
+
+	manifestBlob, err := json.Marshal(imgspecv1.Manifest{
+		Versioned:    imgspec.Versioned{SchemaVersion: 2},
+		MediaType:    imgspecv1.MediaTypeImageManifest,
+		ArtifactType: artifact.ArtifactType,
+		Config:       config,
+		Layers:       layers,
+		Subject:      &subject,
+		Annotations:  artifact.Annotations,
+	})
+	if err != nil {
+		return imgspecv1.Descriptor{}, err
+	}
+	manifestDigest := digest.FromBytes(manifestBlob)
+	// Use instanceDigest so that the artifact is written by digest, without affecting the tag or index entry of the image.
+	if err := c.dest.PutManifest(ctx, manifestBlob, &manifestDigest); err != nil {
+		return imgspecv1.Descriptor{}, err
+	}
+	return imgspecv1.Descriptor{
+		MediaType:    imgspecv1.MediaTypeImageManifest,
+		ArtifactType: artifact.ArtifactType,
+		Digest:       manifestDigest,
+		Size:         int64(len(manifestBlob)),
+		Annotations:  artifact.Annotations,
+	}, nil
+}
+
+// putReferrerBlob writes a blob of a referrer artifact to c.dest.
+func (c *copier) putReferrerBlob(ctx context.Context, desc imgspecv1.Descriptor, data []byte, isConfig bool) error {
+	// The blobs are expected to be small, so we don’t bother with TryReusingBlobWithOptions.
+	info := types.BlobInfo{Digest: desc.Digest, Size: desc.Size, MediaType: desc.MediaType}
+	_, err := c.dest.PutBlobWithOptions(ctx, bytes.NewReader(data), info, private.PutBlobOptions{
+		Cache:    c.blobInfoCache,
+		IsConfig: isConfig,
+	})
+	return err
+}
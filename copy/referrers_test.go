@@ -0,0 +1,117 @@
+package copy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containers/image/v5/directory"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/oci/layout"
+	digest "github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testReferrerGenerator is a ReferrerGenerator which returns fixed artifacts, and records the subject.
+type testReferrerGenerator struct {
+	artifacts []ReferrerArtifact
+	err       error
+
+	subject      imgspecv1.Descriptor
+	manifestBlob []byte
+}
+
+func (g *testReferrerGenerator) GenerateReferrers(ctx context.Context, subject imgspecv1.Descriptor, manifestBlob []byte) ([]ReferrerArtifact, error) {
+	g.subject = subject
+	g.manifestBlob = manifestBlob
+	return g.artifacts, g.err
+}
+
+func TestReferrerGenerator(t *testing.T) {
+	srcDir, _ := createDirImage(t, []byte("layer contents"))
+	srcRef, err := directory.NewReference(srcDir)
+	require.NoError(t, err)
+	policyContext := newInsecureAcceptAnythingPolicyContext(t)
+
+	sbom := []byte(`{"spdxVersion":"SPDX-2.3"}`)
+	destDir := t.TempDir()
+	destRef, err := layout.NewReference(destDir, "latest")
+	require.NoError(t, err)
+	generator := &testReferrerGenerator{artifacts: []ReferrerArtifact{
+		{
+			ArtifactType: "application/spdx+json",
+			Blobs:        []ReferrerArtifactBlob{{MediaType: "application/spdx+json", Data: sbom}},
+			Annotations:  map[string]string{"org.example.generator": "test"},
+		},
+		{ArtifactType: "application/vnd.example.provenance"},
+	}}
+	referrers := []imgspecv1.Descriptor{}
+	copiedManifest, err := Image(context.Background(), policyContext, destRef, srcRef, &Options{
+		ReferrerGenerator: generator,
+		ReportReferrers:   &referrers,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, copiedManifest, generator.manifestBlob)
+	assert.Equal(t, imgspecv1.Descriptor{
+		MediaType: imgspecv1.MediaTypeImageManifest,
+		Digest:    digest.FromBytes(copiedManifest),
+		Size:      int64(len(copiedManifest)),
+	}, generator.subject)
+
+	readBlob := func(d digest.Digest) []byte {
+		data, err := os.ReadFile(filepath.Join(destDir, "blobs", d.Algorithm().String(), d.Encoded()))
+		require.NoError(t, err)
+		return data
+	}
+	require.Len(t, referrers, 2)
+	for i, desc := range referrers {
+		artifact := generator.artifacts[i]
+		assert.Equal(t, artifact.ArtifactType, desc.ArtifactType)
+		manifestBlob := readBlob(desc.Digest)
+		assert.Equal(t, desc.Size, int64(len(manifestBlob)))
+		assert.Equal(t, imgspecv1.MediaTypeImageManifest, manifest.GuessMIMEType(manifestBlob))
+		var m imgspecv1.Manifest
+		err = json.Unmarshal(manifestBlob, &m)
+		require.NoError(t, err)
+		assert.Equal(t, artifact.ArtifactType, m.ArtifactType)
+		assert.Equal(t, artifact.Annotations, m.Annotations)
+		require.NotNil(t, m.Subject)
+		assert.Equal(t, generator.subject, *m.Subject)
+		assert.Equal(t, imgspecv1.DescriptorEmptyJSON.Digest, m.Config.Digest)
+		assert.Equal(t, []byte("{}"), readBlob(m.Config.Digest))
+		require.Len(t, m.Layers, 1)
+		if len(artifact.Blobs) == 0 {
+			assert.Equal(t, imgspecv1.DescriptorEmptyJSON.Digest, m.Layers[0].Digest)
+		} else {
+			assert.Equal(t, artifact.Blobs[0].MediaType, m.Layers[0].MediaType)
+			assert.Equal(t, artifact.Blobs[0].Data, readBlob(m.Layers[0].Digest))
+		}
+	}
+
+	// The image itself is still the only entry in the index.
+	indexBlob, err := os.ReadFile(filepath.Join(destDir, "index.json"))
+	require.NoError(t, err)
+	var index imgspecv1.Index
+	err = json.Unmarshal(indexBlob, &index)
+	require.NoError(t, err)
+	require.Len(t, index.Manifests, 1)
+	assert.Equal(t, generator.subject.Digest, index.Manifests[0].Digest)
+
+	// Generator failures fail the copy.
+	generatorErr := errors.New("generating SBOM failed")
+	_, err = Image(context.Background(), policyContext, destRef, srcRef, &Options{
+		ReferrerGenerator: &testReferrerGenerator{err: generatorErr},
+	})
+	assert.ErrorIs(t, err, generatorErr)
+
+	// Artifacts without a type are rejected.
+	_, err = Image(context.Background(), policyContext, destRef, srcRef, &Options{
+		ReferrerGenerator: &testReferrerGenerator{artifacts: []ReferrerArtifact{{}}},
+	})
+	assert.Error(t, err)
+}
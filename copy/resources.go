@@ -0,0 +1,224 @@
+package copy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/containers/image/v5/internal/tmpdir"
+	"github.com/containers/image/v5/types"
+	"github.com/sirupsen/logrus"
+)
+
+// ResourceUsage contains resources used by a copy operation; see Options.ReportResourceUsage.
+type ResourceUsage struct {
+	// BytesDownloaded is the amount of blob data read from the source.
+	BytesDownloaded int64
+	// BytesUploaded is the amount of blob data consumed by the destination.
+	BytesUploaded int64
+	// PeakTemporaryBytes is the largest observed total size of the temporary files created by the source and destination
+	// in their directories for big files (see types.SystemContext.BigFilesTemporaryDir).
+	// The size is sampled periodically, so short-lived peaks may not be recorded.
+	PeakTemporaryBytes int64
+}
+
+// ResourceQuota contains limits on resources used by a copy operation; see Options.ResourceQuota.
+// Fields set to 0 mean no limit.
+type ResourceQuota struct {
+	MaxBytesDownloaded int64 // See ResourceUsage.BytesDownloaded
+	MaxBytesUploaded   int64 // See ResourceUsage.BytesUploaded
+	MaxTemporaryBytes  int64 // See ResourceUsage.PeakTemporaryBytes
+}
+
+// ResourceQuotaExceededError is returned when a copy operation is aborted because it exceeded Options.ResourceQuota.
+type ResourceQuotaExceededError struct {
+	Resource string // A human-readable name of the resource
+	Limit    int64  // The quota that was exceeded, in bytes
+}
+
+func (e ResourceQuotaExceededError) Error() string {
+	return fmt.Sprintf("copy exceeded the %s quota of %d bytes", e.Resource, e.Limit)
+}
+
+// temporaryUsageSamplingInterval is the interval between measurements of ResourceUsage.PeakTemporaryBytes.
+var temporaryUsageSamplingInterval = time.Second
+
+// resourceAccounting tracks resources used by a single copy.Image operation.
+// A nil *resourceAccounting is valid, and does nothing.
+type resourceAccounting struct {
+	quota         ResourceQuota
+	cancel        context.CancelCauseFunc // Aborts the copy operation when the temporary storage quota is exceeded
+	temporaryDirs []string                // Private directories used as BigFilesTemporaryDir by the source and destination
+
+	downloaded    atomic.Int64
+	uploaded      atomic.Int64
+	peakTemporary atomic.Int64
+}
+
+// newResourceAccounting returns a resourceAccounting for options, or nil if options don’t ask for any.
+// If it returns a non-nil value, it also returns a copy of options which should be used instead, and the caller must call close().
+// cancel is used to abort the copy when the temporary storage quota is exceeded.
+func newResourceAccounting(options *Options, cancel context.CancelCauseFunc) (*resourceAccounting, *Options, error) {
+	if options.ResourceQuota == nil && options.ReportResourceUsage == nil {
+		return nil, options, nil
+	}
+	ra := &resourceAccounting{cancel: cancel}
+	if options.ResourceQuota != nil {
+		ra.quota = *options.ResourceQuota
+	}
+
+	// Point the source and destination to private temporary directories, so that their usage can be measured.
+	updatedOptions := *options
+	sourceCtx, err := ra.useTemporaryDir(options.SourceCtx)
+	if err != nil {
+		return nil, nil, err
+	}
+	updatedOptions.SourceCtx = sourceCtx
+	destCtx, err := ra.useTemporaryDir(options.DestinationCtx)
+	if err != nil {
+		ra.close()
+		return nil, nil, err
+	}
+	updatedOptions.DestinationCtx = destCtx
+	return ra, &updatedOptions, nil
+}
+
+// useTemporaryDir creates a private directory within the directory for big files of sys,
+// and returns a copy of sys which uses it as the directory for big files.
+func (ra *resourceAccounting) useTemporaryDir(sys *types.SystemContext) (*types.SystemContext, error) {
+	dir, err := tmpdir.MkDirBigFileTemp(sys, "copy")
+	if err != nil {
+		return nil, fmt.Errorf("creating a temporary directory for resource accounting: %w", err)
+	}
+	ra.temporaryDirs = append(ra.temporaryDirs, dir)
+	updated := types.SystemContext{}
+	if sys != nil {
+		updated = *sys
+	}
+	updated.BigFilesTemporaryDir = dir
+	return &updated, nil
+}
+
+// monitorTemporaryUsage periodically measures usage of the temporary directories, until ctx is done.
+func (ra *resourceAccounting) monitorTemporaryUsage(ctx context.Context) {
+	if ra == nil {
+		return
+	}
+	ticker := time.NewTicker(temporaryUsageSamplingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			ra.sampleTemporaryUsage()
+		}
+	}
+}
+
+// sampleTemporaryUsage measures usage of the temporary directories, and aborts the copy if it exceeds the quota.
+func (ra *resourceAccounting) sampleTemporaryUsage() {
+	total := int64(0)
+	for _, dir := range ra.temporaryDirs {
+		_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil // Files may be removed while we are walking the directory.
+			}
+			if d.Type().IsRegular() {
+				if info, err := d.Info(); err == nil {
+					total += info.Size()
+				}
+			}
+			return nil
+		})
+	}
+	for {
+		peak := ra.peakTemporary.Load()
+		if total <= peak || ra.peakTemporary.CompareAndSwap(peak, total) {
+			break
+		}
+	}
+	if ra.quota.MaxTemporaryBytes > 0 && total > ra.quota.MaxTemporaryBytes {
+		ra.cancel(ResourceQuotaExceededError{Resource: "temporary storage", Limit: ra.quota.MaxTemporaryBytes})
+	}
+}
+
+// addDownloaded records n bytes read from the source.
+func (ra *resourceAccounting) addDownloaded(n int64) error {
+	if ra == nil {
+		return nil
+	}
+	if total := ra.downloaded.Add(n); ra.quota.MaxBytesDownloaded > 0 && total > ra.quota.MaxBytesDownloaded {
+		return ResourceQuotaExceededError{Resource: "download", Limit: ra.quota.MaxBytesDownloaded}
+	}
+	return nil
+}
+
+// addUploaded records n bytes consumed by the destination.
+func (ra *resourceAccounting) addUploaded(n int64) error {
+	if ra == nil {
+		return nil
+	}
+	if total := ra.uploaded.Add(n); ra.quota.MaxBytesUploaded > 0 && total > ra.quota.MaxBytesUploaded {
+		return ResourceQuotaExceededError{Resource: "upload", Limit: ra.quota.MaxBytesUploaded}
+	}
+	return nil
+}
+
+// downloadReader returns a reader which records data read from r as downloaded.
+func (ra *resourceAccounting) downloadReader(r io.Reader) io.Reader {
+	if ra == nil {
+		return r
+	}
+	return &accountingReader{source: r, add: ra.addDownloaded}
+}
+
+// uploadReader returns a reader which records data read from r as uploaded.
+func (ra *resourceAccounting) uploadReader(r io.Reader) io.Reader {
+	if ra == nil {
+		return r
+	}
+	return &accountingReader{source: r, add: ra.addUploaded}
+}
+
+// usage returns the resources used so far.
+func (ra *resourceAccounting) usage() ResourceUsage {
+	return ResourceUsage{
+		BytesDownloaded:    ra.downloaded.Load(),
+		BytesUploaded:      ra.uploaded.Load(),
+		PeakTemporaryBytes: ra.peakTemporary.Load(),
+	}
+}
+
+// close removes the private temporary directories.
+func (ra *resourceAccounting) close() {
+	if ra == nil {
+		return
+	}
+	for _, dir := range ra.temporaryDirs {
+		if err := os.RemoveAll(dir); err != nil {
+			logrus.Debugf("Error removing temporary directory %s: %v", dir, err)
+		}
+	}
+}
+
+// accountingReader is an io.Reader which reports the amount of data read, and fails if a quota is exceeded.
+type accountingReader struct {
+	source io.Reader
+	add    func(int64) error
+}
+
+func (r *accountingReader) Read(p []byte) (int, error) {
+	n, err := r.source.Read(p)
+	if n > 0 {
+		if quotaErr := r.add(int64(n)); quotaErr != nil {
+			return n, quotaErr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,165 @@
+package copy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containers/image/v5/directory"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/signature"
+	"github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResourceAccountingReaders(t *testing.T) {
+	// nil does nothing
+	var ra *resourceAccounting
+	r := bytes.NewReader([]byte("abc"))
+	assert.Same(t, r, ra.downloadReader(r))
+	assert.Same(t, r, ra.uploadReader(r))
+	assert.NoError(t, ra.addDownloaded(100))
+
+	ra = &resourceAccounting{quota: ResourceQuota{MaxBytesDownloaded: 10}}
+	data, err := io.ReadAll(ra.downloadReader(bytes.NewReader(make([]byte, 10))))
+	require.NoError(t, err)
+	assert.Len(t, data, 10)
+	data, err = io.ReadAll(ra.uploadReader(bytes.NewReader(make([]byte, 20))))
+	require.NoError(t, err)
+	assert.Len(t, data, 20)
+	assert.Equal(t, ResourceUsage{BytesDownloaded: 10, BytesUploaded: 20}, ra.usage())
+
+	_, err = io.ReadAll(ra.downloadReader(bytes.NewReader([]byte{1})))
+	var quotaErr ResourceQuotaExceededError
+	require.ErrorAs(t, err, &quotaErr)
+	assert.Equal(t, ResourceQuotaExceededError{Resource: "download", Limit: 10}, quotaErr)
+}
+
+func TestResourceAccountingTemporaryUsage(t *testing.T) {
+	var cause error
+	ra, options, err := newResourceAccounting(&Options{
+		ResourceQuota: &ResourceQuota{MaxTemporaryBytes: 100},
+	}, func(err error) { cause = err })
+	require.NoError(t, err)
+	require.NotNil(t, ra)
+	defer ra.close()
+	require.Len(t, ra.temporaryDirs, 2)
+	assert.Equal(t, ra.temporaryDirs[0], options.SourceCtx.BigFilesTemporaryDir)
+	assert.Equal(t, ra.temporaryDirs[1], options.DestinationCtx.BigFilesTemporaryDir)
+
+	err = os.WriteFile(filepath.Join(ra.temporaryDirs[0], "a"), make([]byte, 60), 0o600)
+	require.NoError(t, err)
+	ra.sampleTemporaryUsage()
+	assert.NoError(t, cause)
+	assert.Equal(t, int64(60), ra.usage().PeakTemporaryBytes)
+
+	err = os.WriteFile(filepath.Join(ra.temporaryDirs[1], "b"), make([]byte, 60), 0o600)
+	require.NoError(t, err)
+	ra.sampleTemporaryUsage()
+	assert.Equal(t, ResourceQuotaExceededError{Resource: "temporary storage", Limit: 100}, cause)
+	assert.Equal(t, int64(120), ra.usage().PeakTemporaryBytes)
+
+	err = os.Remove(filepath.Join(ra.temporaryDirs[1], "b"))
+	require.NoError(t, err)
+	ra.sampleTemporaryUsage()
+	assert.Equal(t, int64(120), ra.usage().PeakTemporaryBytes) // The peak does not decrease
+
+	dirs := ra.temporaryDirs
+	ra.close()
+	for _, dir := range dirs {
+		assert.NoDirExists(t, dir)
+	}
+
+	// No resource accounting requested
+	originalOptions := &Options{}
+	ra, options, err = newResourceAccounting(originalOptions, func(error) {})
+	require.NoError(t, err)
+	assert.Nil(t, ra)
+	assert.Same(t, originalOptions, options)
+}
+
+// writeDirImage creates a dir: image with manifestBlob and blobs.
+func writeDirImage(t *testing.T, manifestBlob []byte, blobs [][]byte) string {
+	dir := t.TempDir()
+	for _, blob := range blobs {
+		err := os.WriteFile(filepath.Join(dir, digest.FromBytes(blob).Encoded()), blob, 0o644)
+		require.NoError(t, err)
+	}
+	err := os.WriteFile(filepath.Join(dir, "manifest.json"), manifestBlob, 0o644)
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(dir, "version"), []byte("Directory Transport Version: 1.1\n"), 0o644)
+	require.NoError(t, err)
+	return dir
+}
+
+// createDirImage creates a dir: image with a single layer containing layerData, and returns the total size of its blobs.
+func createDirImage(t *testing.T, layerData []byte) (string, int64) {
+	config := []byte(`{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":["` + digest.FromBytes(layerData).String() + `"]}}`)
+	m := manifest.OCI1FromComponents(imgspecv1.Descriptor{
+		MediaType: imgspecv1.MediaTypeImageConfig,
+		Digest:    digest.FromBytes(config),
+		Size:      int64(len(config)),
+	}, []imgspecv1.Descriptor{{
+		MediaType: imgspecv1.MediaTypeImageLayer,
+		Digest:    digest.FromBytes(layerData),
+		Size:      int64(len(layerData)),
+	}})
+	manifestBlob, err := m.Serialize()
+	require.NoError(t, err)
+	return writeDirImage(t, manifestBlob, [][]byte{config, layerData}), int64(len(config) + len(layerData))
+}
+
+// newInsecureAcceptAnythingPolicyContext returns a policy context which accepts any image.
+func newInsecureAcceptAnythingPolicyContext(t *testing.T) *signature.PolicyContext {
+	policyContext, err := signature.NewPolicyContext(&signature.Policy{
+		Default: signature.PolicyRequirements{signature.NewPRInsecureAcceptAnything()},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		err := policyContext.Destroy()
+		require.NoError(t, err)
+	})
+	return policyContext
+}
+
+func TestImageResourceQuota(t *testing.T) {
+	srcDir, blobsSize := createDirImage(t, bytes.Repeat([]byte("layer"), 1000))
+	srcRef, err := directory.NewReference(srcDir)
+	require.NoError(t, err)
+	policyContext := newInsecureAcceptAnythingPolicyContext(t)
+
+	// Usage is reported
+	destRef, err := directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+	usage := ResourceUsage{}
+	_, err = Image(context.Background(), policyContext, destRef, srcRef, &Options{
+		ResourceQuota:       &ResourceQuota{MaxBytesDownloaded: blobsSize, MaxBytesUploaded: blobsSize},
+		ReportResourceUsage: &usage,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, blobsSize, usage.BytesDownloaded)
+	assert.Equal(t, blobsSize, usage.BytesUploaded)
+
+	// Quotas are enforced
+	for _, quota := range []ResourceQuota{
+		{MaxBytesDownloaded: blobsSize - 1},
+		{MaxBytesUploaded: blobsSize - 1},
+	} {
+		destRef, err := directory.NewReference(t.TempDir())
+		require.NoError(t, err)
+		usage := ResourceUsage{}
+		_, err = Image(context.Background(), policyContext, destRef, srcRef, &Options{
+			ResourceQuota:       &quota,
+			ReportResourceUsage: &usage,
+		})
+		var quotaErr ResourceQuotaExceededError
+		assert.True(t, errors.As(err, &quotaErr), "%#v: %v", quota, err)
+		assert.NotZero(t, usage.BytesDownloaded)
+	}
+}
@@ -0,0 +1,88 @@
+package copy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	compressiontypes "github.com/containers/image/v5/pkg/compression/types"
+	"github.com/containers/image/v5/types"
+)
+
+// LayerScanner inspects the contents of layers being copied, e.g. to look for malware or leaked secrets,
+// and can prevent the image from being written to the destination.
+type LayerScanner interface {
+	// ScanLayer is called, concurrently with copying the layer described by layer (as it exists in the source),
+	// with the uncompressed (and decrypted, if applicable) layer contents.
+	// It may read as much of uncompressed as it needs; the rest is discarded. uncompressed must not be used after ScanLayer returns.
+	// Note that copying the layer does not progress while ScanLayer is not reading from uncompressed.
+	//
+	// ScanLayer may continue processing after it is done reading uncompressed; the copy waits for all ScanLayer calls
+	// to return before writing the image configuration and manifest to the destination. If any of them returns an error,
+	// the copy fails with that error, and the manifest is not written (layers may already have been written).
+	//
+	// The layer digest is only verified after all of the layer has been read; if it does not match, the copy fails
+	// regardless of the value returned by ScanLayer.
+	ScanLayer(ctx context.Context, layer types.BlobInfo, uncompressed io.Reader) error
+}
+
+// layerScanBarrier tracks asynchronous LayerScanner.ScanLayer calls for a single image.
+// The zero value is ready for use.
+type layerScanBarrier struct {
+	wg    sync.WaitGroup
+	mutex sync.Mutex // Protects err
+	err   error      // The first error returned by a scan, if any
+}
+
+// start starts an asynchronous scan of layer, reading stream (decompressed using decompressor, if not nil).
+// It consumes all of stream, and closes it.
+func (b *layerScanBarrier) start(ctx context.Context, scanner LayerScanner, layer types.BlobInfo, stream io.ReadCloser, decompressor compressiontypes.DecompressorFunc) {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		defer stream.Close()
+
+		err := func() error { // A scope for defer
+			var uncompressed io.Reader = stream
+			if decompressor != nil {
+				s, err := decompressor(stream)
+				if err != nil {
+					return fmt.Errorf("decompressing layer %s for scanning: %w", layer.Digest, err)
+				}
+				defer s.Close()
+				uncompressed = s
+			}
+			if err := scanner.ScanLayer(ctx, layer, uncompressed); err != nil {
+				return fmt.Errorf("layer %s rejected by scanner: %w", layer.Digest, err)
+			}
+			return nil
+		}()
+		// Consume the rest of the input, so that the layer copy can proceed; any failure is reported by b.wait().
+		_, _ = io.Copy(io.Discard, stream)
+		if err != nil {
+			b.mutex.Lock()
+			if b.err == nil {
+				b.err = err
+			}
+			b.mutex.Unlock()
+		}
+	}()
+}
+
+// wait waits for all scans started so far to finish, and returns an error if any of them failed.
+func (b *layerScanBarrier) wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for layer scans: %w", ctx.Err())
+	case <-done:
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.err
+}
@@ -0,0 +1,80 @@
+package copy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/containers/image/v5/directory"
+	"github.com/containers/image/v5/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testLayerScanner is a LayerScanner which records the scanned data.
+type testLayerScanner struct {
+	readLimit int64 // Maximum number of bytes to read
+	delay     time.Duration
+	err       error
+
+	mutex   sync.Mutex
+	scanned map[string][]byte
+}
+
+func (s *testLayerScanner) ScanLayer(ctx context.Context, layer types.BlobInfo, uncompressed io.Reader) error {
+	data, err := io.ReadAll(io.LimitReader(uncompressed, s.readLimit))
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	s.scanned[layer.Digest.String()] = data
+	s.mutex.Unlock()
+	time.Sleep(s.delay) // After returning from ScanLayer, continue processing asynchronously.
+	return s.err
+}
+
+func TestLayerScanner(t *testing.T) {
+	payload := bytes.Repeat([]byte("layer contents"), 1000)
+	compressed := bytes.Buffer{}
+	gzipWriter := gzip.NewWriter(&compressed)
+	_, err := gzipWriter.Write(payload)
+	require.NoError(t, err)
+	err = gzipWriter.Close()
+	require.NoError(t, err)
+	srcDir, _ := createDirImage(t, compressed.Bytes())
+	srcRef, err := directory.NewReference(srcDir)
+	require.NoError(t, err)
+	policyContext := newInsecureAcceptAnythingPolicyContext(t)
+
+	// Accepted
+	destDir := t.TempDir()
+	destRef, err := directory.NewReference(destDir)
+	require.NoError(t, err)
+	scanner := &testLayerScanner{readLimit: int64(len(payload)) + 1, delay: 10 * time.Millisecond, scanned: map[string][]byte{}}
+	_, err = Image(context.Background(), policyContext, destRef, srcRef, &Options{LayerScanner: scanner})
+	require.NoError(t, err)
+	assert.Len(t, scanner.scanned, 1)
+	for _, data := range scanner.scanned {
+		assert.Equal(t, payload, data)
+	}
+	_, err = os.Stat(filepath.Join(destDir, "manifest.json"))
+	assert.NoError(t, err)
+
+	// Rejected, after reading only part of the layer
+	destDir = t.TempDir()
+	destRef, err = directory.NewReference(destDir)
+	require.NoError(t, err)
+	scanErr := errors.New("found something suspicious")
+	scanner = &testLayerScanner{readLimit: 10, delay: 10 * time.Millisecond, err: scanErr, scanned: map[string][]byte{}}
+	_, err = Image(context.Background(), policyContext, destRef, srcRef, &Options{LayerScanner: scanner})
+	assert.ErrorIs(t, err, scanErr)
+	_, err = os.Stat(filepath.Join(destDir, "manifest.json"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
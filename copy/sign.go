@@ -8,15 +8,49 @@ import (
 	"github.com/containers/image/v5/internal/private"
 	internalsig "github.com/containers/image/v5/internal/signature"
 	internalSigner "github.com/containers/image/v5/internal/signer"
+	"github.com/containers/image/v5/signature/signer"
 	"github.com/containers/image/v5/signature/sigstore"
 	"github.com/containers/image/v5/signature/simplesigning"
 	"github.com/containers/image/v5/transports"
+	"github.com/sirupsen/logrus"
 )
 
+// sigstoreReferrerArtifactType is the artifact type of referrers storing sigstore signatures, as used by cosign.
+const sigstoreReferrerArtifactType = "application/vnd.dev.cosign.artifact.sig.v1+json"
+
+// SignatureStorage specifies where signatures created by a signer in Options.SignersWithStorage are stored.
+type SignatureStorage int
+
+const (
+	// SignatureStorageDefault stores signatures the way the destination stores signatures of their format by default,
+	// e.g. for docker:// destinations, simple signing signatures in lookaside or using the registry API extension,
+	// and sigstore signatures as sigstore attachments.
+	SignatureStorageDefault SignatureStorage = iota
+	// SignatureStorageReferrers stores signatures as OCI artifacts referring to the signed manifest using their “subject” field.
+	// Only sigstore signatures can be stored this way.
+	SignatureStorageReferrers
+)
+
+// SignerWithStorage is a signer, along with where signatures it creates are stored.
+type SignerWithStorage struct {
+	Signer  *signer.Signer
+	Storage SignatureStorage
+}
+
 // setupSigners initializes c.signers.
 func (c *copier) setupSigners() error {
-	c.signers = append(c.signers, c.options.Signers...)
-	// c.signersToClose is intentionally not updated with c.options.Signers.
+	for _, s := range c.options.Signers {
+		c.signers = append(c.signers, SignerWithStorage{Signer: s, Storage: SignatureStorageDefault})
+	}
+	for _, s := range c.options.SignersWithStorage {
+		switch s.Storage {
+		case SignatureStorageDefault, SignatureStorageReferrers:
+		default:
+			return fmt.Errorf("unknown signature storage %d", s.Storage)
+		}
+		c.signers = append(c.signers, s)
+	}
+	// c.signersToClose is intentionally not updated with c.options.Signers or c.options.SignersWithStorage.
 
 	// We immediately append created signers to c.signers, and we rely on c.close() to clean them up; so we don’t need
 	// to clean up any created signers on failure.
@@ -32,7 +66,7 @@ func (c *copier) setupSigners() error {
 		if err != nil {
 			return err
 		}
-		c.signers = append(c.signers, signer)
+		c.signers = append(c.signers, SignerWithStorage{Signer: signer, Storage: SignatureStorageDefault})
 		c.signersToClose = append(c.signersToClose, signer)
 	}
 
@@ -43,7 +77,7 @@ func (c *copier) setupSigners() error {
 		if err != nil {
 			return err
 		}
-		c.signers = append(c.signers, signer)
+		c.signers = append(c.signers, SignerWithStorage{Signer: signer, Storage: SignatureStorageDefault})
 		c.signersToClose = append(c.signersToClose, signer)
 	}
 
@@ -76,40 +110,84 @@ func (c *copier) sourceSignatures(ctx context.Context, unparsed private.Unparsed
 }
 
 // createSignatures creates signatures for manifest and an optional identity.
-func (c *copier) createSignatures(ctx context.Context, manifest []byte, identity reference.Named) ([]internalsig.Signature, error) {
+// It returns signatures to be stored using c.dest.PutSignaturesWithFormat, and signatures to be stored using c.pushSignatureReferrers.
+func (c *copier) createSignatures(ctx context.Context, manifest []byte, identity reference.Named) ([]internalsig.Signature, []internalsig.Signature, error) {
 	if len(c.signers) == 0 {
 		// We must exit early here, otherwise copies with no Docker reference wouldn’t be possible.
-		return nil, nil
+		return nil, nil, nil
 	}
 
 	if identity != nil {
 		if reference.IsNameOnly(identity) {
-			return nil, fmt.Errorf("Sign identity must be a fully specified reference %s", identity.String())
+			return nil, nil, fmt.Errorf("Sign identity must be a fully specified reference %s", identity.String())
 		}
 	} else {
 		identity = c.dest.Reference().DockerReference()
 		if identity == nil {
-			return nil, fmt.Errorf("Cannot determine canonical Docker reference for destination %s", transports.ImageName(c.dest.Reference()))
+			return nil, nil, fmt.Errorf("Cannot determine canonical Docker reference for destination %s", transports.ImageName(c.dest.Reference()))
 		}
 	}
 
-	res := make([]internalsig.Signature, 0, len(c.signers))
+	destSigs := []internalsig.Signature{}
+	referrerSigs := []internalsig.Signature{}
 	for signerIndex, signer := range c.signers {
-		msg := internalSigner.ProgressMessage(signer)
+		msg := internalSigner.ProgressMessage(signer.Signer)
 		if len(c.signers) == 1 {
 			c.Printf("Creating signature: %s\n", msg)
 		} else {
 			c.Printf("Creating signature %d: %s\n", signerIndex+1, msg)
 		}
-		newSig, err := internalSigner.SignImageManifest(ctx, signer, manifest, identity)
+		newSig, err := internalSigner.SignImageManifest(ctx, signer.Signer, manifest, identity)
+		if err == nil && signer.Storage == SignatureStorageReferrers {
+			if _, ok := newSig.(internalsig.Sigstore); !ok {
+				err = fmt.Errorf("signatures of format %s can not be stored as referrers", newSig.FormatID())
+			}
+		}
 		if err != nil {
 			if len(c.signers) == 1 {
-				return nil, fmt.Errorf("creating signature: %w", err)
+				return nil, nil, fmt.Errorf("creating signature: %w", err)
 			} else {
-				return nil, fmt.Errorf("creating signature %d: %w", signerIndex+1, err)
+				return nil, nil, fmt.Errorf("creating signature %d: %w", signerIndex+1, err)
 			}
 		}
-		res = append(res, newSig)
+		if signer.Storage == SignatureStorageReferrers {
+			referrerSigs = append(referrerSigs, newSig)
+		} else {
+			destSigs = append(destSigs, newSig)
+		}
+	}
+	return destSigs, referrerSigs, nil
+}
+
+// pushSignatureReferrers pushes sigs, sigstore signatures of manifestBlob, to c.dest as artifacts referring to manifestBlob.
+// The caller must have already written manifestBlob to c.dest.
+func (c *copier) pushSignatureReferrers(ctx context.Context, manifestBlob []byte, sigs []internalsig.Signature) error {
+	if len(sigs) == 0 {
+		return nil
+	}
+	subject, err := referrerSubject(manifestBlob)
+	if err != nil {
+		return err
 	}
-	return res, nil
+	c.Printf("Storing signatures as referrers\n")
+	for i, sig := range sigs {
+		sigstoreSig, ok := sig.(internalsig.Sigstore)
+		if !ok { // createSignatures should have rejected this
+			return fmt.Errorf("internal error: signature %d of format %s can not be stored as a referrer", i+1, sig.FormatID())
+		}
+		desc, err := c.pushReferrer(ctx, subject, ReferrerArtifact{
+			ArtifactType: sigstoreReferrerArtifactType,
+			Blobs: []ReferrerArtifactBlob{{
+				MediaType:   sigstoreSig.UntrustedMIMEType(),
+				Data:        sigstoreSig.UntrustedPayload(),
+				Annotations: sigstoreSig.UntrustedAnnotations(),
+			}},
+		})
+		if err != nil {
+			return fmt.Errorf("pushing signature %d of %s as a referrer: %w", i+1, subject.Digest, err)
+		}
+		logrus.Debugf("Pushed signature referrer %s of %s", desc.Digest, subject.Digest)
+		c.reportReferrer(desc)
+	}
+	return nil
 }
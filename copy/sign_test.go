@@ -2,8 +2,12 @@ package copy
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/containers/image/v5/directory"
@@ -14,6 +18,8 @@ import (
 	internalSigner "github.com/containers/image/v5/internal/signer"
 	"github.com/containers/image/v5/signature/signer"
 	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -141,11 +147,12 @@ func TestCreateSignatures(t *testing.T) {
 		defer c.close()
 		err := c.setupSigners()
 		require.NoError(t, err, cc.name)
-		sigs, err := c.createSignatures(context.Background(), manifestBlob, identity)
+		sigs, referrerSigs, err := c.createSignatures(context.Background(), manifestBlob, identity)
 		switch {
 		case cc.successfullySignedIdentity != "":
 			require.NoError(t, err, cc.name)
 			require.Len(t, sigs, 1, cc.name)
+			assert.Empty(t, referrerSigs, cc.name)
 			stubSig, ok := sigs[0].(internalsig.Sigstore)
 			require.True(t, ok, cc.name)
 			// Compare how stubSignerImpl.SignImageManifest stuffs the signing parameters into these fields.
@@ -155,9 +162,89 @@ func TestCreateSignatures(t *testing.T) {
 		case cc.successWithNoSigs:
 			require.NoError(t, err, cc.name)
 			require.Empty(t, sigs, cc.name)
+			require.Empty(t, referrerSigs, cc.name)
 
 		default:
 			assert.Error(t, err, cc.name)
 		}
 	}
 }
+
+// stubSimpleSignerImpl is a signer.SigningImplementation that creates fake simple signing signatures.
+type stubSimpleSignerImpl struct{}
+
+func (s *stubSimpleSignerImpl) ProgressMessage() string {
+	return "Signing with stubSimpleSigner"
+}
+
+func (s *stubSimpleSignerImpl) SignImageManifest(ctx context.Context, m []byte, dockerReference reference.Named) (internalsig.Signature, error) {
+	return internalsig.SimpleSigningFromBlob(m), nil
+}
+
+func (s *stubSimpleSignerImpl) Close() error {
+	return nil
+}
+
+func TestSignersWithStorage(t *testing.T) {
+	srcDir, _ := createDirImage(t, []byte("layer contents"))
+	srcRef, err := directory.NewReference(srcDir)
+	require.NoError(t, err)
+	policyContext := newInsecureAcceptAnythingPolicyContext(t)
+	identity, err := reference.ParseNormalizedNamed("example.com/repo:tag")
+	require.NoError(t, err)
+
+	stubSigner := internalSigner.NewSigner(&stubSignerImpl{})
+	defer stubSigner.Close()
+	simpleSigner := internalSigner.NewSigner(&stubSimpleSignerImpl{})
+	defer simpleSigner.Close()
+
+	// Signatures are stored as referrers of the signed manifest, in addition to signatures stored by the destination.
+	destDir := t.TempDir()
+	destRef, err := directory.NewReference(destDir)
+	require.NoError(t, err)
+	referrers := []imgspecv1.Descriptor{}
+	copiedManifest, err := Image(context.Background(), policyContext, destRef, srcRef, &Options{
+		SignIdentity: identity,
+		SignersWithStorage: []SignerWithStorage{
+			{Signer: simpleSigner, Storage: SignatureStorageDefault},
+			{Signer: stubSigner, Storage: SignatureStorageReferrers},
+		},
+		ReportReferrers: &referrers,
+	})
+	require.NoError(t, err)
+	_, err = os.Stat(filepath.Join(destDir, "signature-1"))
+	require.NoError(t, err)
+	_, err = os.Stat(filepath.Join(destDir, "signature-2"))
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	require.Len(t, referrers, 1)
+	assert.Equal(t, sigstoreReferrerArtifactType, referrers[0].ArtifactType)
+	manifestBlob, err := os.ReadFile(filepath.Join(destDir, referrers[0].Digest.Encoded()+".manifest.json"))
+	require.NoError(t, err)
+	var m imgspecv1.Manifest
+	err = json.Unmarshal(manifestBlob, &m)
+	require.NoError(t, err)
+	assert.Equal(t, sigstoreReferrerArtifactType, m.ArtifactType)
+	require.NotNil(t, m.Subject)
+	assert.Equal(t, digest.FromBytes(copiedManifest), m.Subject.Digest)
+	require.Len(t, m.Layers, 1)
+	// Compare how stubSignerImpl.SignImageManifest stuffs the signing parameters into these fields.
+	assert.Equal(t, identity.String(), m.Layers[0].MediaType)
+	layer, err := os.ReadFile(filepath.Join(destDir, m.Layers[0].Digest.Encoded()))
+	require.NoError(t, err)
+	assert.Equal(t, copiedManifest, layer)
+
+	// Signatures which can’t be stored as referrers are rejected.
+	_, err = Image(context.Background(), policyContext, destRef, srcRef, &Options{
+		SignIdentity:       identity,
+		SignersWithStorage: []SignerWithStorage{{Signer: simpleSigner, Storage: SignatureStorageReferrers}},
+	})
+	assert.Error(t, err)
+
+	// Unknown storage values are rejected.
+	_, err = Image(context.Background(), policyContext, destRef, srcRef, &Options{
+		SignIdentity:       identity,
+		SignersWithStorage: []SignerWithStorage{{Signer: stubSigner, Storage: SignatureStorage(100)}},
+	})
+	assert.Error(t, err)
+}
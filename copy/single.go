@@ -42,12 +42,31 @@ type imageCopier struct {
 	compressionFormat             *compressiontypes.Algorithm // Compression algorithm to use, if the user explicitly requested one, or nil.
 	compressionLevel              *int
 	requireCompressionFormatMatch bool
+	layerScans                    layerScanBarrier       // Scans started using c.options.LayerScanner
+	normalizedLayers              normalizedLayerDiffIDs // Layers normalized due to c.options.NormalizeLayers
 }
 
 type copySingleImageOptions struct {
 	requireCompressionFormatMatch bool
 	compressionFormat             *compressiontypes.Algorithm // Compression algorithm to use, if the user explicitly requested one, or nil.
 	compressionLevel              *int
+	// compatibilityFallback is set when retrying the copy due to Options.RetryWithCompatibleFormatOnRejection:
+	// use gzip compression, and prefer the Docker schema2 manifest format.
+	compatibilityFallback bool
+}
+
+// formatRejectedError is returned by copySingleImageOnce when the destination rejected all attempted manifest formats,
+// and the manifest could have been modified (so a retry with a different format might succeed).
+type formatRejectedError struct {
+	err error
+}
+
+func (e formatRejectedError) Error() string {
+	return e.err.Error()
+}
+
+func (e formatRejectedError) Unwrap() error {
+	return e.err
 }
 
 // copySingleImageResult carries data produced by copySingleImage
@@ -60,7 +79,35 @@ type copySingleImageResult struct {
 
 // copySingleImage copies a single (non-manifest-list) image unparsedImage, using c.policyContext to validate
 // source image admissibility.
+// If the destination rejects the manifest format and c.options.RetryWithCompatibleFormatOnRejection, it copies the image again
+// using more widely compatible formats.
 func (c *copier) copySingleImage(ctx context.Context, unparsedImage *image.UnparsedImage, targetInstance *digest.Digest, opts copySingleImageOptions) (copySingleImageResult, error) {
+	res, err := c.copySingleImageOnce(ctx, unparsedImage, targetInstance, opts)
+	var rejected formatRejectedError
+	if err == nil || !c.options.RetryWithCompatibleFormatOnRejection || opts.compressionFormat != nil || opts.compatibilityFallback ||
+		!errors.As(err, &rejected) {
+		return res, err
+	}
+
+	logrus.Warnf("Destination rejected the image format (%v), retrying with gzip compression and a more compatible manifest format", err)
+	fallbackOpts := opts
+	fallbackOpts.compatibilityFallback = true
+	res, fallbackErr := c.copySingleImageOnce(ctx, unparsedImage, targetInstance, fallbackOpts)
+	if fallbackErr != nil {
+		return copySingleImageResult{}, fmt.Errorf("%w; retrying with a compatible format also failed: %w", err, fallbackErr)
+	}
+	if c.options.ReportCompatibilityFallbacks != nil {
+		*c.options.ReportCompatibilityFallbacks = append(*c.options.ReportCompatibilityFallbacks, CompatibilityFallback{
+			OriginalError:    rejected.err,
+			ManifestDigest:   res.manifestDigest,
+			ManifestMIMEType: res.manifestMIMEType,
+		})
+	}
+	return res, nil
+}
+
+// copySingleImageOnce is copySingleImage, without retries due to c.options.RetryWithCompatibleFormatOnRejection.
+func (c *copier) copySingleImageOnce(ctx context.Context, unparsedImage *image.UnparsedImage, targetInstance *digest.Digest, opts copySingleImageOptions) (copySingleImageResult, error) {
 	// The caller is handling manifest lists; this could happen only if a manifest list contains a manifest list.
 	// Make sure we fail cleanly in such cases.
 	multiImage, err := isMultiImage(ctx, unparsedImage)
@@ -151,6 +198,15 @@ func (c *copier) copySingleImage(ctx context.Context, unparsedImage *image.Unpar
 		ic.compressionFormat = c.options.DestinationCtx.CompressionFormat
 		ic.compressionLevel = c.options.DestinationCtx.CompressionLevel
 	}
+	if ic.compressionFormat == nil {
+		// The destination may need a specific compression algorithm; note that this can be nil as well.
+		ic.compressionFormat = c.dest.Capabilities().LayerCompressionFormat
+	}
+	if opts.compatibilityFallback {
+		ic.compressionFormat = &compression.Gzip
+		ic.compressionLevel = nil
+		ic.requireCompressionFormatMatch = true
+	}
 	// HACK: Don’t combine zstd:chunked and encryption.
 	// zstd:chunked can only usefully be consumed using range requests of parts of the layer, which would require the encryption
 	// to support decrypting arbitrary subsets of the stream. That’s plausible but not supported using the encryption API we have.
@@ -159,7 +215,7 @@ func (c *copier) copySingleImage(ctx context.Context, unparsedImage *image.Unpar
 	//
 	// Ideally this should query a well-defined property of the compression algorithm (and $somehow determine the right fallback) instead of
 	// hard-coding zstd:chunked / zstd.
-	if ic.c.options.OciEncryptLayers != nil {
+	if ic.c.options.OciEncryptLayers != nil || (ic.c.options.OciReencrypt && isEncrypted(src)) {
 		format := ic.compressionFormat
 		if format == nil {
 			format = defaultCompressionFormat
@@ -186,16 +242,27 @@ func (c *copier) copySingleImage(ctx context.Context, unparsedImage *image.Unpar
 	if err := ic.updateEmbeddedDockerReference(); err != nil {
 		return copySingleImageResult{}, err
 	}
+	if err := ic.mutateConfig(ctx); err != nil {
+		return copySingleImageResult{}, err
+	}
 
-	destRequiresOciEncryption := (isEncrypted(src) && ic.c.options.OciDecryptConfig == nil) || c.options.OciEncryptLayers != nil
+	destRequiresOciEncryption := (isEncrypted(src) && (ic.c.options.OciDecryptConfig == nil || ic.c.options.OciReencrypt)) || c.options.OciEncryptLayers != nil
 
+	forceManifestMIMEType := c.options.ForceManifestMIMEType
+	if opts.compatibilityFallback && forceManifestMIMEType == "" && !destRequiresOciEncryption {
+		destSupported := ic.c.dest.SupportedManifestMIMETypes()
+		if len(destSupported) == 0 || slices.Contains(destSupported, manifest.DockerV2Schema2MediaType) {
+			forceManifestMIMEType = manifest.DockerV2Schema2MediaType
+		}
+	}
 	ic.manifestConversionPlan, err = determineManifestConversion(determineManifestConversionInputs{
 		srcMIMEType:                    ic.src.ManifestMIMEType,
 		destSupportedManifestMIMETypes: ic.c.dest.SupportedManifestMIMETypes(),
-		forceManifestMIMEType:          c.options.ForceManifestMIMEType,
+		forceManifestMIMEType:          forceManifestMIMEType,
 		requestedCompressionFormat:     ic.compressionFormat,
 		requiresOCIEncryption:          destRequiresOciEncryption,
 		cannotModifyManifestReason:     ic.cannotModifyManifestReason,
+		srcIsNonImageArtifact:          ic.src.IsArtifact(),
 	})
 	if err != nil {
 		return copySingleImageResult{}, err
@@ -214,7 +281,7 @@ func (c *copier) copySingleImage(ctx context.Context, unparsedImage *image.Unpar
 	// If enabled, fetch and compare the destination's manifest. And as an optimization skip updating the destination iff equal
 	if c.options.OptimizeDestinationImageAlreadyExists {
 		shouldUpdateSigs := len(sigs) > 0 || len(c.signers) != 0 // TODO: Consider allowing signatures updates only and skipping the image's layers/manifest copy if possible
-		noPendingManifestUpdates := ic.noPendingManifestUpdates()
+		noPendingManifestUpdates := ic.noPendingManifestUpdates() && !layerMediaTypeRewritesApply(ic.src.LayerInfos(), c.options.LayerMediaTypeRewrites)
 
 		logrus.Debugf("Checking if we can skip copying: has signatures=%t, OCI encryption=%t, no manifest updates=%t, compression match required for reusing blobs=%t", shouldUpdateSigs, destRequiresOciEncryption, noPendingManifestUpdates, opts.requireCompressionFormatMatch)
 		if !shouldUpdateSigs && !destRequiresOciEncryption && noPendingManifestUpdates && !ic.requireCompressionFormatMatch {
@@ -251,16 +318,21 @@ func (c *copier) copySingleImage(ctx context.Context, unparsedImage *image.Unpar
 		logrus.Debugf("Writing manifest using preferred type %s failed: %v", ic.manifestConversionPlan.preferredMIMEType, err)
 		// … if it fails, and the failure is either because the manifest is rejected by the registry, or
 		// because we failed to create a manifest of the specified type because the specific manifest type
-		// doesn't support the type of compression we're trying to use (e.g. docker v2s2 and zstd), we may
+		// doesn’t support the type of compression or encryption we’re trying to use (e.g. docker v2s2 and zstd), we may
 		// have other options available that could still succeed.
 		var manifestTypeRejectedError types.ManifestTypeRejectedError
 		var manifestLayerCompressionIncompatibilityError manifest.ManifestLayerCompressionIncompatibilityError
+		var manifestLayerEncryptionIncompatibilityError manifest.ManifestLayerEncryptionIncompatibilityError
 		isManifestRejected := errors.As(err, &manifestTypeRejectedError)
-		isCompressionIncompatible := errors.As(err, &manifestLayerCompressionIncompatibilityError)
-		if (!isManifestRejected && !isCompressionIncompatible) || len(ic.manifestConversionPlan.otherMIMETypeCandidates) == 0 {
+		isLayerFormatIncompatible := errors.As(err, &manifestLayerCompressionIncompatibilityError) ||
+			errors.As(err, &manifestLayerEncryptionIncompatibilityError)
+		if (!isManifestRejected && !isLayerFormatIncompatible) || len(ic.manifestConversionPlan.otherMIMETypeCandidates) == 0 {
 			// We don’t have other options.
 			// In principle the code below would handle this as well, but the resulting  error message is fairly ugly.
 			// Don’t bother the user with MIME types if we have no choice.
+			if (isManifestRejected || isLayerFormatIncompatible) && ic.cannotModifyManifestReason == "" {
+				return copySingleImageResult{}, formatRejectedError{err: err}
+			}
 			return copySingleImageResult{}, err
 		}
 		// If the original MIME type is acceptable, determineManifestConversion always uses it as ic.manifestConversionPlan.preferredMIMEType.
@@ -293,14 +365,14 @@ func (c *copier) copySingleImage(ctx context.Context, unparsedImage *image.Unpar
 			break
 		}
 		if errs != nil {
-			return copySingleImageResult{}, fmt.Errorf("Uploading manifest failed, attempted the following formats: %s", strings.Join(errs, ", "))
+			return copySingleImageResult{}, formatRejectedError{err: fmt.Errorf("Uploading manifest failed, attempted the following formats: %s", strings.Join(errs, ", "))}
 		}
 	}
 	if targetInstance != nil {
 		targetInstance = &wipResult.manifestDigest
 	}
 
-	newSigs, err := c.createSignatures(ctx, wipResult.manifest, c.options.SignIdentity)
+	newSigs, referrerSigs, err := c.createSignatures(ctx, wipResult.manifest, c.options.SignIdentity)
 	if err != nil {
 		return copySingleImageResult{}, err
 	}
@@ -312,6 +384,9 @@ func (c *copier) copySingleImage(ctx context.Context, unparsedImage *image.Unpar
 			return copySingleImageResult{}, fmt.Errorf("writing signatures: %w", err)
 		}
 	}
+	if err := c.pushSignatureReferrers(ctx, wipResult.manifest, referrerSigs); err != nil {
+		return copySingleImageResult{}, err
+	}
 	wipResult.compressionAlgorithms = compressionAlgos
 	res := wipResult // We are done
 	return res, nil
@@ -451,6 +526,7 @@ func (ic *imageCopier) copyLayers(ctx context.Context) ([]compressiontypes.Algor
 	type copyLayerData struct {
 		destInfo types.BlobInfo
 		diffID   digest.Digest
+		missing  bool // With Options.MetadataOnly, the layer does not exist at the destination
 		err      error
 	}
 
@@ -470,6 +546,12 @@ func (ic *imageCopier) copyLayers(ctx context.Context) ([]compressiontypes.Algor
 		defer ic.c.concurrentBlobCopiesSemaphore.Release(1)
 		defer copyGroup.Done()
 		cld := copyLayerData{}
+		releaseGovernor, err := ic.c.governor.acquireBlobCopy(ctx)
+		if err != nil {
+			data[index] = copyLayerData{err: fmt.Errorf("copying layer: %w", err)}
+			return
+		}
+		defer releaseGovernor()
 		if !ic.c.options.DownloadForeignLayers && ic.c.dest.AcceptsForeignLayerURLs() && len(srcLayer.URLs) != 0 {
 			// DiffIDs are, currently, needed only when converting from schema1.
 			// In which case src.LayerInfos will not have URLs because schema1
@@ -480,6 +562,8 @@ func (ic *imageCopier) copyLayers(ctx context.Context) ([]compressiontypes.Algor
 				cld.destInfo = srcLayer
 				logrus.Debugf("Skipping foreign layer %q copy to %s", cld.destInfo.Digest, ic.c.dest.Reference().Transport().Name())
 			}
+		} else if ic.c.options.MetadataOnly {
+			cld.destInfo, cld.missing, cld.err = ic.reuseLayerForMetadataOnlyCopy(ctx, srcLayer, index, srcRef, manifestLayerInfos[index].EmptyLayer)
 		} else {
 			cld.destInfo, cld.diffID, cld.err = ic.copyLayer(ctx, srcLayer, toEncrypt, pool, index, srcRef, manifestLayerInfos[index].EmptyLayer)
 		}
@@ -521,7 +605,8 @@ func (ic *imageCopier) copyLayers(ctx context.Context) ([]compressiontypes.Algor
 				return fmt.Errorf("copying layer: %w", err)
 			}
 			copyGroup.Add(1)
-			go copyLayerHelper(i, srcLayer, layersToEncrypt.Contains(i), progressPool, ic.c.rawSource.Reference().DockerReference())
+			toEncrypt := layersToEncrypt.Contains(i) || (ic.c.options.OciReencrypt && isOciEncrypted(srcLayer.MediaType))
+			go copyLayerHelper(i, srcLayer, toEncrypt, progressPool, ic.c.rawSource.Reference().DockerReference())
 		}
 
 		// A call to copyGroup.Wait() is done at this point by the defer above.
@@ -533,16 +618,24 @@ func (ic *imageCopier) copyLayers(ctx context.Context) ([]compressiontypes.Algor
 	compressionAlgos := set.New[string]()
 	destInfos := make([]types.BlobInfo, len(srcInfos))
 	diffIDs := make([]digest.Digest, len(srcInfos))
+	missingBlobs := []digest.Digest{}
 	for i, cld := range data {
 		if cld.err != nil {
 			return nil, cld.err
 		}
+		if cld.missing {
+			missingBlobs = append(missingBlobs, srcInfos[i].Digest)
+			continue
+		}
 		if cld.destInfo.CompressionAlgorithm != nil {
 			compressionAlgos.Add(cld.destInfo.CompressionAlgorithm.Name())
 		}
 		destInfos[i] = cld.destInfo
 		diffIDs[i] = cld.diffID
 	}
+	if len(missingBlobs) != 0 {
+		return nil, MissingBlobsError{Destination: transports.ImageName(ic.c.dest.Reference()), Digests: missingBlobs}
+	}
 
 	// WARNING: If you are adding new reasons to change ic.manifestUpdates, also update the
 	// OptimizeDestinationImageAlreadyExists short-circuit conditions
@@ -553,6 +646,7 @@ func (ic *imageCopier) copyLayers(ctx context.Context) ([]compressiontypes.Algor
 	if srcInfosUpdated || layerDigestsDiffer(srcInfos, destInfos) {
 		ic.manifestUpdates.LayerInfos = destInfos
 	}
+	ic.manifestUpdates.LayerDiffIDs = ic.normalizedLayers.layerUpdates(len(srcInfos))
 	algos, err := algorithmsByNames(compressionAlgos.All())
 	if err != nil {
 		return nil, err
@@ -591,10 +685,31 @@ func (ic *imageCopier) copyUpdatedConfigAndManifest(ctx context.Context, instanc
 		}
 		pendingImage = pi
 	}
-	man, _, err := pendingImage.Manifest(ctx)
+	man, manifestType, err := pendingImage.Manifest(ctx)
 	if err != nil {
 		return nil, "", fmt.Errorf("reading manifest: %w", err)
 	}
+	if len(ic.c.options.LayerMediaTypeRewrites) != 0 {
+		man, err = rewriteLayerMediaTypes(man, manifestType, ic.c.options.LayerMediaTypeRewrites, ic.cannotModifyManifestReason)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	if manifestType == imgspecv1.MediaTypeImageManifest {
+		ociManifest, err := manifest.OCI1FromManifest(man)
+		if err != nil {
+			return nil, "", fmt.Errorf("parsing manifest: %w", err)
+		}
+		if err := ociManifest.ValidateEmptyJSONConfig(); err != nil {
+			return nil, "", fmt.Errorf("invalid config-less artifact: %w", err)
+		}
+	}
+
+	if ic.c.options.LayerScanner != nil {
+		if err := ic.layerScans.wait(ctx); err != nil {
+			return nil, "", err
+		}
+	}
 
 	if err := ic.copyConfig(ctx, pendingImage); err != nil {
 		return nil, "", err
@@ -612,6 +727,9 @@ func (ic *imageCopier) copyUpdatedConfigAndManifest(ctx context.Context, instanc
 		logrus.Debugf("Error %v while writing manifest %q", err, string(man))
 		return nil, "", fmt.Errorf("writing manifest: %w", err)
 	}
+	if ic.c.jsonProgress != nil {
+		ic.c.jsonProgress.manifestWritten(manifestType, manifestDigest, len(man))
+	}
 	return man, manifestDigest, nil
 }
 
@@ -624,6 +742,11 @@ func (ic *imageCopier) copyConfig(ctx context.Context, src types.Image) error {
 			return fmt.Errorf("copying config: %w", err)
 		}
 		defer ic.c.concurrentBlobCopiesSemaphore.Release(1)
+		releaseGovernor, err := ic.c.governor.acquireBlobCopy(ctx)
+		if err != nil {
+			return fmt.Errorf("copying config: %w", err)
+		}
+		defer releaseGovernor()
 
 		destInfo, err := func() (types.BlobInfo, error) { // A scope for defer
 			progressPool := ic.c.newProgressPool()
@@ -639,6 +762,11 @@ func (ic *imageCopier) copyConfig(ctx context.Context, src types.Image) error {
 			if err != nil {
 				return types.BlobInfo{}, fmt.Errorf("reading config blob %s: %w", srcInfo.Digest, err)
 			}
+			if manifest.IsOCI1EmptyJSONConfig(srcInfo) {
+				if err := manifest.ValidateOCI1EmptyJSONConfig(srcInfo, configBlob); err != nil {
+					return types.BlobInfo{}, fmt.Errorf("invalid config-less artifact: %w", err)
+				}
+			}
 
 			destInfo, err := ic.copyBlobFromStream(ctx, bytes.NewReader(configBlob), srcInfo, nil, true, false, bar, -1, false)
 			if err != nil {
@@ -690,6 +818,32 @@ func compressionEditsFromBlobInfo(srcInfo types.BlobInfo) (types.LayerCompressio
 	}
 }
 
+// restoredChunkedAnnotations returns srcInfo.Annotations, extended with chunked metadata annotations recorded
+// in the blob info cache for srcInfo.Digest if srcInfo.Annotations don’t contain any.
+// This preserves the ability to partially pull a layer we have created, even if it was copied
+// through a transport which can’t record the annotations (e.g. docker-archive:).
+func (ic *imageCopier) restoredChunkedAnnotations(srcInfo types.BlobInfo) map[string]string {
+	if len(compression.ChunkedAnnotations(srcInfo.Annotations)) != 0 {
+		return srcInfo.Annotations
+	}
+	// The cache only records the specific variant annotations for blobs we have created, so they match srcInfo.Digest.
+	data := ic.c.blobInfoCache.DigestCompressorData(srcInfo.Digest)
+	if data.SpecificVariantCompressor != compressiontypes.ZstdChunkedAlgorithmName {
+		return srcInfo.Annotations
+	}
+	chunked := compression.ChunkedAnnotations(data.SpecificVariantAnnotations)
+	if len(chunked) == 0 {
+		return srcInfo.Annotations
+	}
+	logrus.Debugf("Restoring chunked metadata annotations of blob %s", srcInfo.Digest)
+	res := maps.Clone(srcInfo.Annotations)
+	if res == nil {
+		res = map[string]string{}
+	}
+	maps.Copy(res, chunked)
+	return res
+}
+
 // copyLayer copies a layer with srcInfo (with known Digest and Annotations and possibly known Size) in src to dest, perhaps (de/re/)compressing it,
 // and returns a complete blobInfo of the copied layer, and a value for LayerDiffIDs if diffIDIsNeeded
 // srcRef can be used as an additional hint to the destination during checking whether a layer can be reused but srcRef can be nil.
@@ -701,6 +855,9 @@ func (ic *imageCopier) copyLayer(ctx context.Context, srcInfo types.BlobInfo, to
 	// which uses the compression information to compute the updated MediaType values.
 	// (Sadly UpdatedImage() is documented to not update MediaTypes from
 	//  ManifestUpdateOptions.LayerInfos[].MediaType, so we are doing it indirectly.)
+	if !isOciEncrypted(srcInfo.MediaType) {
+		srcInfo.Annotations = ic.restoredChunkedAnnotations(srcInfo)
+	}
 	if srcInfo.CompressionOperation == types.PreserveOriginal && srcInfo.CompressionAlgorithm == nil {
 		op, algo, err := compressionEditsFromBlobInfo(srcInfo)
 		if err != nil {
@@ -722,7 +879,8 @@ func (ic *imageCopier) copyLayer(ctx context.Context, srcInfo types.BlobInfo, to
 	// (e.g. if we know the DiffID of an encrypted compressed layer, it might not be necessary to pull, decrypt and decompress again),
 	// but it’s not trivially safe to do such things, so until someone takes the effort to make a comprehensive argument, let’s not.
 	encryptingOrDecrypting := toEncrypt || (isOciEncrypted(srcInfo.MediaType) && ic.c.options.OciDecryptConfig != nil)
-	canAvoidProcessingCompleteLayer := !diffIDIsNeeded && !encryptingOrDecrypting
+	// With a LayerScanner, we must read all layers, so that they are scanned.
+	canAvoidProcessingCompleteLayer := !diffIDIsNeeded && !encryptingOrDecrypting && ic.c.options.LayerScanner == nil
 
 	// Don’t read the layer from the source if we already have the blob, and optimizations are acceptable.
 	if canAvoidProcessingCompleteLayer {
@@ -757,6 +915,7 @@ func (ic *imageCopier) copyLayer(ctx context.Context, srcInfo types.BlobInfo, to
 			RequiredCompression:     requiredCompression,
 			OriginalCompression:     srcInfo.CompressionAlgorithm,
 			TOCDigest:               tocDigest,
+			CandidateRepositories:   ic.c.options.BlobMountCandidates,
 		})
 		if err != nil {
 			return types.BlobInfo{}, "", fmt.Errorf("trying to reuse blob %s at destination: %w", srcInfo.Digest, err)
@@ -780,8 +939,8 @@ func (ic *imageCopier) copyLayer(ctx context.Context, srcInfo types.BlobInfo, to
 			}
 
 			// Throw an event that the layer has been skipped
-			if ic.c.options.Progress != nil && ic.c.options.ProgressInterval > 0 {
-				ic.c.options.Progress <- types.ProgressProperties{
+			if ic.c.progress != nil {
+				ic.c.progress <- types.ProgressProperties{
 					Event:    types.ProgressEventSkipped,
 					Artifact: srcInfo,
 				}
@@ -807,8 +966,9 @@ func (ic *imageCopier) copyLayer(ctx context.Context, srcInfo types.BlobInfo, to
 			}()
 
 			proxy := blobChunkAccessorProxy{
-				wrapped: ic.c.rawSource,
-				bar:     bar,
+				wrapped:   ic.c.rawSource,
+				bar:       bar,
+				resources: ic.c.resources,
 			}
 			uploadedBlob, err := ic.c.dest.PutBlobPartial(ctx, &proxy, srcInfo, private.PutBlobPartialOptions{
 				Cache:      ic.c.blobInfoCache,
@@ -884,6 +1044,9 @@ func (ic *imageCopier) copyLayer(ctx context.Context, srcInfo types.BlobInfo, to
 				diffID = diffIDResult.digest
 			}
 		}
+		if normalizedDiffID := ic.normalizedLayers.diffID(layerIndex); normalizedDiffID != "" {
+			diffID = normalizedDiffID
+		}
 
 		bar.mark100PercentComplete()
 		return blobInfo, diffID, nil
@@ -897,11 +1060,9 @@ func updatedBlobInfoFromReuse(inputInfo types.BlobInfo, reusedBlob private.Reuse
 	// Handling of compression, encryption, and the related MIME types and the like are all the responsibility
 	// of the generic code in this package.
 	res := types.BlobInfo{
-		Digest: reusedBlob.Digest,
-		Size:   reusedBlob.Size,
-		URLs:   nil, // This _must_ be cleared if Digest changes; clear it in other cases as well, to preserve previous behavior.
-		// FIXME: This should remove zstd:chunked annotations IF the original was chunked and the new one isn’t
-		// (but those annotations being left with incorrect values should not break pulls).
+		Digest:               reusedBlob.Digest,
+		Size:                 reusedBlob.Size,
+		URLs:                 nil, // This _must_ be cleared if Digest changes; clear it in other cases as well, to preserve previous behavior.
 		Annotations:          maps.Clone(inputInfo.Annotations),
 		MediaType:            inputInfo.MediaType, // Mostly irrelevant, MediaType is updated based on Compression*/CryptoOperation.
 		CompressionOperation: reusedBlob.CompressionOperation,
@@ -911,7 +1072,11 @@ func updatedBlobInfoFromReuse(inputInfo types.BlobInfo, reusedBlob private.Reuse
 	// The transport is only expected to fill CompressionOperation and CompressionAlgorithm
 	// if the blob was substituted; otherwise, it is optional, and if not set, fill it in based
 	// on what we know from the srcInfos we were given.
-	if reusedBlob.Digest == inputInfo.Digest {
+	if reusedBlob.Digest != inputInfo.Digest {
+		// Chunked metadata annotations describe the original blob; the substituted one is only chunked
+		// if reusedBlob.CompressionAnnotations say so.
+		res.Annotations = compression.WithoutChunkedAnnotations(res.Annotations)
+	} else {
 		if res.CompressionOperation == types.PreserveOriginal {
 			res.CompressionOperation = inputInfo.CompressionOperation
 		}
@@ -932,9 +1097,10 @@ func updatedBlobInfoFromReuse(inputInfo types.BlobInfo, reusedBlob private.Reuse
 // it copies a blob with srcInfo (with known Digest and Annotations and possibly known Size) from srcStream to dest,
 // perhaps (de/re/)compressing the stream,
 // and returns a complete blobInfo of the copied blob and perhaps a <-chan diffIDResult if diffIDIsNeeded, to be read by the caller.
+// If ic.c.options.LayerScanner is set, it also starts a scan of the layer, tracked in ic.layerScans.
 func (ic *imageCopier) copyLayerFromStream(ctx context.Context, srcStream io.Reader, srcInfo types.BlobInfo,
 	diffIDIsNeeded bool, toEncrypt bool, bar *progressBar, layerIndex int, emptyLayer bool) (types.BlobInfo, <-chan diffIDResult, error) {
-	var getDiffIDRecorder func(compressiontypes.DecompressorFunc) io.Writer // = nil
+	var originalLayerCopyWriters []func(compressiontypes.DecompressorFunc) io.Writer
 	var diffIDChan chan diffIDResult
 
 	err := errors.New("Internal error: unexpected panic in copyLayer") // For pipeWriter.CloseWithbelow
@@ -945,7 +1111,7 @@ func (ic *imageCopier) copyLayerFromStream(ctx context.Context, srcStream io.Rea
 			_ = pipeWriter.CloseWithError(err) // CloseWithError(nil) is equivalent to Close(), always returns nil
 		}()
 
-		getDiffIDRecorder = func(decompressor compressiontypes.DecompressorFunc) io.Writer {
+		originalLayerCopyWriters = append(originalLayerCopyWriters, func(decompressor compressiontypes.DecompressorFunc) io.Writer {
 			// If this fails, e.g. because we have exited and due to pipeWriter.CloseWithError() above further
 			// reading from the pipe has failed, we don’t really care.
 			// We only read from diffIDChan if the rest of the flow has succeeded, and when we do read from it,
@@ -955,10 +1121,36 @@ func (ic *imageCopier) copyLayerFromStream(ctx context.Context, srcStream io.Rea
 			// closed above, so we are happy enough with both pipeReader and pipeWriter to just get collected by GC.
 			go diffIDComputationGoroutine(diffIDChan, pipeReader, decompressor) // Closes pipeReader
 			return pipeWriter
+		})
+	}
+	if scanner := ic.c.options.LayerScanner; scanner != nil {
+		pipeReader, pipeWriter := io.Pipe()
+		defer func() { // Note that this is not the same as {defer pipeWriter.CloseWithError(err)}; we need err to be evaluated lazily.
+			_ = pipeWriter.CloseWithError(err) // CloseWithError(nil) is equivalent to Close(), always returns nil
+		}()
+
+		originalLayerCopyWriters = append(originalLayerCopyWriters, func(decompressor compressiontypes.DecompressorFunc) io.Writer {
+			// The scan consumes all of pipeReader, and reports failures only through ic.layerScans.
+			ic.layerScans.start(ctx, scanner, srcInfo, pipeReader, decompressor) // Closes pipeReader
+			return pipeWriter
+		})
+	}
+	var getOriginalLayerCopyWriter func(compressiontypes.DecompressorFunc) io.Writer // = nil
+	switch len(originalLayerCopyWriters) {
+	case 0:
+	case 1:
+		getOriginalLayerCopyWriter = originalLayerCopyWriters[0]
+	default:
+		getOriginalLayerCopyWriter = func(decompressor compressiontypes.DecompressorFunc) io.Writer {
+			writers := make([]io.Writer, 0, len(originalLayerCopyWriters))
+			for _, w := range originalLayerCopyWriters {
+				writers = append(writers, w(decompressor))
+			}
+			return io.MultiWriter(writers...)
 		}
 	}
 
-	blobInfo, err := ic.copyBlobFromStream(ctx, srcStream, srcInfo, getDiffIDRecorder, false, toEncrypt, bar, layerIndex, emptyLayer) // Sets err to nil on success
+	blobInfo, err := ic.copyBlobFromStream(ctx, srcStream, srcInfo, getOriginalLayerCopyWriter, false, toEncrypt, bar, layerIndex, emptyLayer) // Sets err to nil on success
 	return blobInfo, diffIDChan, err
 	// We need the defer … pipeWriter.CloseWithError() to happen HERE so that the caller can block on reading from diffIDChan
 }
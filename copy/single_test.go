@@ -1,15 +1,26 @@
 package copy
 
 import (
+	"archive/tar"
 	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/containers/image/v5/directory"
+	"github.com/containers/image/v5/docker/archive"
+	"github.com/containers/image/v5/internal/blobinfocache"
 	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/oci/layout"
+	"github.com/containers/image/v5/pkg/blobinfocache/memory"
 	"github.com/containers/image/v5/pkg/compression"
 	compressiontypes "github.com/containers/image/v5/pkg/compression/types"
 	"github.com/containers/image/v5/types"
@@ -95,6 +106,73 @@ func TestUpdatedBlobInfoFromReuse(t *testing.T) {
 		res := updatedBlobInfoFromReuse(srcInfo, c.reused)
 		assert.Equal(t, c.expected, res, fmt.Sprintf("%#v", c.reused))
 	}
+
+	// Chunked metadata annotations are dropped if the blob is substituted, unless the substitute is also chunked.
+	chunkedSrcInfo := srcInfo
+	chunkedSrcInfo.Annotations = map[string]string{
+		"test-annotation-2": "two",
+		"io.github.containers.zstd-chunked.manifest-checksum": "sha256:0000000000000000000000000000000000000000000000000000000000000001",
+		"io.github.containers.zstd-chunked.manifest-position": "1:2:3:4",
+	}
+	res := updatedBlobInfoFromReuse(chunkedSrcInfo, private.ReusedBlob{
+		Digest:               "sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+		Size:                 513543640,
+		CompressionOperation: types.Compress,
+		CompressionAlgorithm: &compression.Gzip,
+	})
+	assert.Equal(t, map[string]string{"test-annotation-2": "two"}, res.Annotations)
+	res = updatedBlobInfoFromReuse(chunkedSrcInfo, private.ReusedBlob{
+		Digest:               "sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+		Size:                 513543640,
+		CompressionOperation: types.Compress,
+		CompressionAlgorithm: &compression.ZstdChunked,
+		CompressionAnnotations: map[string]string{
+			"io.github.containers.zstd-chunked.manifest-checksum": "sha256:0000000000000000000000000000000000000000000000000000000000000002",
+		},
+	})
+	assert.Equal(t, map[string]string{
+		"test-annotation-2": "two",
+		"io.github.containers.zstd-chunked.manifest-checksum": "sha256:0000000000000000000000000000000000000000000000000000000000000002",
+	}, res.Annotations)
+	res = updatedBlobInfoFromReuse(chunkedSrcInfo, private.ReusedBlob{
+		Digest: chunkedSrcInfo.Digest,
+		Size:   chunkedSrcInfo.Size,
+	})
+	assert.Equal(t, chunkedSrcInfo.Annotations, res.Annotations)
+}
+
+func TestRestoredChunkedAnnotations(t *testing.T) {
+	const blobDigest = digest.Digest("sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")
+	chunkedAnnotations := map[string]string{
+		"io.github.containers.zstd-chunked.manifest-checksum": "sha256:0000000000000000000000000000000000000000000000000000000000000001",
+		"io.github.containers.zstd-chunked.manifest-position": "1:2:3:4",
+	}
+	cache := blobinfocache.FromBlobInfoCache(memory.New())
+	ic := &imageCopier{c: &copier{blobInfoCache: cache}}
+
+	// Nothing is known
+	info := types.BlobInfo{Digest: blobDigest, Annotations: map[string]string{"a": "b"}}
+	assert.Equal(t, map[string]string{"a": "b"}, ic.restoredChunkedAnnotations(info))
+
+	cache.RecordDigestCompressorData(blobDigest, blobinfocache.DigestCompressorData{
+		BaseVariantCompressor:      compressiontypes.ZstdAlgorithmName,
+		SpecificVariantCompressor:  compressiontypes.ZstdChunkedAlgorithmName,
+		SpecificVariantAnnotations: chunkedAnnotations,
+	})
+	// Annotations are restored, without modifying the input
+	res := ic.restoredChunkedAnnotations(info)
+	assert.Equal(t, map[string]string{
+		"a": "b",
+		"io.github.containers.zstd-chunked.manifest-checksum": "sha256:0000000000000000000000000000000000000000000000000000000000000001",
+		"io.github.containers.zstd-chunked.manifest-position": "1:2:3:4",
+	}, res)
+	assert.Equal(t, map[string]string{"a": "b"}, info.Annotations)
+	assert.Equal(t, chunkedAnnotations, ic.restoredChunkedAnnotations(types.BlobInfo{Digest: blobDigest}))
+	// Existing chunked annotations are not modified
+	existing := map[string]string{"containerd.io/snapshot/stargz/toc.digest": "sha256:0000000000000000000000000000000000000000000000000000000000000003"}
+	assert.Equal(t, existing, ic.restoredChunkedAnnotations(types.BlobInfo{Digest: blobDigest, Annotations: existing}))
+	// Other blobs are not affected
+	assert.Nil(t, ic.restoredChunkedAnnotations(types.BlobInfo{Digest: digest.FromString("other")}))
 }
 
 func goDiffIDComputationGoroutineWithTimeout(layerStream io.ReadCloser, decompressor compressiontypes.DecompressorFunc) *diffIDResult {
@@ -159,3 +237,294 @@ func TestComputeDiffID(t *testing.T) {
 	_, err = computeDiffID(reader, nil)
 	assert.Error(t, err)
 }
+
+func TestCopyEmptyJSONConfigArtifact(t *testing.T) {
+	policyContext := newInsecureAcceptAnythingPolicyContext(t)
+	data := []byte("artifact data")
+	layer := imgspecv1.Descriptor{
+		MediaType: "application/vnd.example.data",
+		Digest:    digest.FromBytes(data),
+		Size:      int64(len(data)),
+	}
+
+	for _, c := range []struct {
+		name         string
+		artifactType string
+		configBlob   []byte
+		success      bool
+	}{
+		{"valid", "application/vnd.example.artifact", manifest.OCI1EmptyJSONConfigBlob(), true},
+		{"missing artifactType", "", manifest.OCI1EmptyJSONConfigBlob(), false},
+		{"invalid config", "application/vnd.example.artifact", []byte("{ }"), false},
+	} {
+		m := manifest.OCI1ArtifactFromComponents(c.artifactType, []imgspecv1.Descriptor{layer})
+		if !bytes.Equal(c.configBlob, manifest.OCI1EmptyJSONConfigBlob()) {
+			m.Config.Digest = digest.FromBytes(c.configBlob)
+			m.Config.Size = int64(len(c.configBlob))
+			m.Config.Data = nil
+		}
+		manifestBlob, err := m.Serialize()
+		require.NoError(t, err, c.name)
+		srcRef, err := directory.NewReference(writeDirImage(t, manifestBlob, [][]byte{c.configBlob, data}))
+		require.NoError(t, err, c.name)
+		destRef, err := directory.NewReference(t.TempDir())
+		require.NoError(t, err, c.name)
+
+		_, err = Image(context.Background(), policyContext, destRef, srcRef, &Options{})
+		if c.success {
+			assert.NoError(t, err, c.name)
+		} else {
+			assert.ErrorContains(t, err, "invalid config-less artifact", c.name)
+		}
+	}
+}
+
+func TestCopyNonImageArtifact(t *testing.T) {
+	policyContext := newInsecureAcceptAnythingPolicyContext(t)
+	config := []byte(`{"name":"chart","version":"1.0.0"}`)
+	data := []byte("chart data")
+	m := manifest.OCI1FromComponents(imgspecv1.Descriptor{
+		MediaType: "application/vnd.cncf.helm.config.v1+json",
+		Digest:    digest.FromBytes(config),
+		Size:      int64(len(config)),
+	}, []imgspecv1.Descriptor{{
+		MediaType: "application/vnd.cncf.helm.chart.content.v1.tar+gzip",
+		Digest:    digest.FromBytes(data),
+		Size:      int64(len(data)),
+	}})
+	m.Annotations = map[string]string{imgspecv1.AnnotationCreated: "2024-01-01T00:00:00Z"}
+	manifestBlob, err := m.Serialize()
+	require.NoError(t, err)
+	srcRef, err := directory.NewReference(writeDirImage(t, manifestBlob, [][]byte{config, data}))
+	require.NoError(t, err)
+
+	// The artifact is copied unmodified; image-specific options don’t apply to it
+	destRef, err := directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+	mutatorCalled := false
+	options := &Options{
+		ConfigMutator: func(_ context.Context, config *imgspecv1.Image) error {
+			mutatorCalled = true
+			return nil
+		},
+		SkipIfUpToDate: UpToDateCheckCreated,
+	}
+	copiedManifest, err := Image(context.Background(), policyContext, destRef, srcRef, options)
+	require.NoError(t, err)
+	assert.Equal(t, manifestBlob, copiedManifest)
+	assert.False(t, mutatorCalled)
+
+	// The creation time is read from the manifest annotation
+	skipped := false
+	options.ReportSkippedUpToDate = &skipped
+	_, err = Image(context.Background(), policyContext, destRef, srcRef, options)
+	require.NoError(t, err)
+	assert.True(t, skipped)
+
+	// … also through an OCI layout
+	layoutRef, err := layout.NewReference(t.TempDir(), "artifact")
+	require.NoError(t, err)
+	_, err = Image(context.Background(), policyContext, layoutRef, destRef, &Options{})
+	require.NoError(t, err)
+	roundTripRef, err := directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+	copiedManifest, err = Image(context.Background(), policyContext, roundTripRef, layoutRef, &Options{})
+	require.NoError(t, err)
+	assert.Equal(t, manifestBlob, copiedManifest)
+
+	// The artifact is not converted to formats which can only represent images
+	archiveRef, err := archive.ParseReference(filepath.Join(t.TempDir(), "archive.tar"))
+	require.NoError(t, err)
+	_, err = Image(context.Background(), policyContext, archiveRef, srcRef, &Options{})
+	assert.ErrorContains(t, err, "non-image artifacts can only be stored using MIME type")
+}
+
+// manifestRejectingReference is a types.ImageReference whose destination rejects manifests of rejectedMIMEType.
+type manifestRejectingReference struct {
+	types.ImageReference
+	rejectedMIMEType string
+}
+
+func (ref manifestRejectingReference) NewImageDestination(ctx context.Context, sys *types.SystemContext) (types.ImageDestination, error) {
+	dest, err := ref.ImageReference.NewImageDestination(ctx, sys)
+	if err != nil {
+		return nil, err
+	}
+	return manifestRejectingDestination{ImageDestination: dest, rejectedMIMEType: ref.rejectedMIMEType}, nil
+}
+
+type manifestRejectingDestination struct {
+	types.ImageDestination
+	rejectedMIMEType string
+}
+
+func (d manifestRejectingDestination) PutManifest(ctx context.Context, m []byte, instanceDigest *digest.Digest) error {
+	if mimeType := manifest.GuessMIMEType(m); mimeType == d.rejectedMIMEType {
+		return types.ManifestTypeRejectedError{Err: fmt.Errorf("manifest type %s not supported", mimeType)}
+	}
+	return d.ImageDestination.PutManifest(ctx, m, instanceDigest)
+}
+
+func TestCopyRetryWithCompatibleFormatOnRejection(t *testing.T) {
+	policyContext := newInsecureAcceptAnythingPolicyContext(t)
+	srcDir, _ := createDirImage(t, []byte("layer data"))
+	srcRef, err := directory.NewReference(srcDir)
+	require.NoError(t, err)
+
+	for _, retry := range []bool{false, true} {
+		destDir := t.TempDir()
+		dirRef, err := directory.NewReference(destDir)
+		require.NoError(t, err)
+		destRef := manifestRejectingReference{ImageReference: dirRef, rejectedMIMEType: imgspecv1.MediaTypeImageManifest}
+		fallbacks := []CompatibilityFallback{}
+
+		manifestBlob, err := Image(context.Background(), policyContext, destRef, srcRef, &Options{
+			DestinationCtx:                       &types.SystemContext{CompressionFormat: &compression.Zstd},
+			RetryWithCompatibleFormatOnRejection: retry,
+			ReportCompatibilityFallbacks:         &fallbacks,
+		})
+		if !retry {
+			var rejected types.ManifestTypeRejectedError
+			assert.ErrorAs(t, err, &rejected)
+			assert.Empty(t, fallbacks)
+			continue
+		}
+		require.NoError(t, err)
+		assert.Equal(t, manifest.DockerV2Schema2MediaType, manifest.GuessMIMEType(manifestBlob))
+		require.Len(t, fallbacks, 1)
+		assert.Equal(t, digest.FromBytes(manifestBlob), fallbacks[0].ManifestDigest)
+		assert.Equal(t, manifest.DockerV2Schema2MediaType, fallbacks[0].ManifestMIMEType)
+		var rejected types.ManifestTypeRejectedError
+		assert.ErrorAs(t, fallbacks[0].OriginalError, &rejected)
+	}
+}
+
+func TestDockerArchiveLayerCompression(t *testing.T) {
+	// Create a dir: image with a zstd layer
+	layerData := bytes.Repeat([]byte("layer"), 1000)
+	diffID := digest.FromBytes(layerData)
+	var zstdLayer bytes.Buffer
+	compressor, err := compression.CompressStream(&zstdLayer, compression.Zstd, nil)
+	require.NoError(t, err)
+	_, err = compressor.Write(layerData)
+	require.NoError(t, err)
+	err = compressor.Close()
+	require.NoError(t, err)
+	config := []byte(`{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":["` + diffID.String() + `"]}}`)
+	manifestBlob, err := manifest.OCI1FromComponents(imgspecv1.Descriptor{
+		MediaType: imgspecv1.MediaTypeImageConfig,
+		Digest:    digest.FromBytes(config),
+		Size:      int64(len(config)),
+	}, []imgspecv1.Descriptor{{
+		MediaType: imgspecv1.MediaTypeImageLayerZstd,
+		Digest:    digest.FromBytes(zstdLayer.Bytes()),
+		Size:      int64(zstdLayer.Len()),
+	}}).Serialize()
+	require.NoError(t, err)
+	srcRef, err := directory.NewReference(writeDirImage(t, manifestBlob, [][]byte{config, zstdLayer.Bytes()}))
+	require.NoError(t, err)
+	policyContext := newInsecureAcceptAnythingPolicyContext(t)
+
+	for _, c := range []struct {
+		name                string
+		sys                 *types.SystemContext
+		expectedCompression string // "" if uncompressed
+	}{
+		{"default", &types.SystemContext{}, ""},
+		{"preserve", &types.SystemContext{DockerArchiveLayerCompression: types.DockerArchiveLayerCompressionPreserve}, compressiontypes.ZstdAlgorithmName},
+		{"gzip", &types.SystemContext{DockerArchiveLayerCompression: types.DockerArchiveLayerCompressionGzip}, compressiontypes.GzipAlgorithmName},
+	} {
+		archivePath := filepath.Join(t.TempDir(), "archive.tar")
+		destRef, err := archive.ParseReference(archivePath + ":example.com/repo:tag")
+		require.NoError(t, err, c.name)
+		_, err = Image(context.Background(), policyContext, destRef, srcRef, &Options{DestinationCtx: c.sys})
+		require.NoError(t, err, c.name)
+
+		// Collect the relevant files from the archive
+		files := map[string][]byte{}
+		f, err := os.Open(archivePath)
+		require.NoError(t, err, c.name)
+		tr := tar.NewReader(f)
+		for {
+			hdr, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			require.NoError(t, err, c.name)
+			if hdr.Typeflag == tar.TypeReg {
+				data, err := io.ReadAll(tr)
+				require.NoError(t, err, c.name)
+				files[hdr.Name] = data
+			}
+		}
+		f.Close()
+		var items []struct {
+			Layers       []string
+			LayerSources map[digest.Digest]imgspecv1.Descriptor
+		}
+		err = json.Unmarshal(files["manifest.json"], &items)
+		require.NoError(t, err, c.name)
+		require.Len(t, items, 1, c.name)
+		require.Len(t, items[0].Layers, 1, c.name)
+		layer := files[items[0].Layers[0]]
+		algo, decompressor, _, err := compression.DetectCompressionFormat(bytes.NewReader(layer))
+		require.NoError(t, err, c.name)
+		if c.expectedCompression == "" {
+			assert.Nil(t, decompressor, c.name)
+			assert.Equal(t, layerData, layer, c.name)
+			assert.Empty(t, items[0].LayerSources, c.name)
+		} else {
+			assert.Equal(t, c.expectedCompression, algo.Name(), c.name)
+			require.Contains(t, items[0].LayerSources, diffID, c.name)
+			assert.Equal(t, digest.FromBytes(layer), items[0].LayerSources[diffID].Digest, c.name)
+		}
+
+		// The archive can be read back
+		destDir := t.TempDir()
+		dirRef, err := directory.NewReference(destDir)
+		require.NoError(t, err, c.name)
+		_, err = Image(context.Background(), policyContext, dirRef, destRef, &Options{})
+		require.NoError(t, err, c.name)
+	}
+}
+
+func TestCopyLayerMediaTypeRewritesExistingDestination(t *testing.T) {
+	// Use a compressed layer, so that the oci: destination stores the image unmodified.
+	var layer bytes.Buffer
+	gzipWriter := gzip.NewWriter(&layer)
+	_, err := gzipWriter.Write([]byte("layer"))
+	require.NoError(t, err)
+	err = gzipWriter.Close()
+	require.NoError(t, err)
+	config := []byte(`{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":["` + digest.FromString("layer").String() + `"]}}`)
+	srcManifest, err := manifest.OCI1FromComponents(imgspecv1.Descriptor{
+		MediaType: imgspecv1.MediaTypeImageConfig,
+		Digest:    digest.FromBytes(config),
+		Size:      int64(len(config)),
+	}, []imgspecv1.Descriptor{{
+		MediaType: imgspecv1.MediaTypeImageLayerGzip,
+		Digest:    digest.FromBytes(layer.Bytes()),
+		Size:      int64(layer.Len()),
+	}}).Serialize()
+	require.NoError(t, err)
+	srcRef, err := directory.NewReference(writeDirImage(t, srcManifest, [][]byte{config, layer.Bytes()}))
+	require.NoError(t, err)
+	destRef, err := layout.NewReference(t.TempDir(), "latest")
+	require.NoError(t, err)
+	policyContext := newInsecureAcceptAnythingPolicyContext(t)
+
+	_, err = Image(context.Background(), policyContext, destRef, srcRef, &Options{})
+	require.NoError(t, err)
+	// The destination already contains the image, but relabeling layers changes the manifest, so the copy must not be skipped.
+	manifestBlob, err := Image(context.Background(), policyContext, destRef, srcRef, &Options{
+		OptimizeDestinationImageAlreadyExists: true,
+		LayerMediaTypeRewrites: map[string]string{
+			imgspecv1.MediaTypeImageLayerGzip: imgspecv1.MediaTypeImageLayerNonDistributableGzip, //nolint:staticcheck // NonDistributable layers are deprecated, but we want to continue to support manipulating pre-existing images.
+		},
+	})
+	require.NoError(t, err)
+	m, err := manifest.OCI1FromManifest(manifestBlob)
+	require.NoError(t, err)
+	require.Len(t, m.Layers, 1)
+	assert.Equal(t, imgspecv1.MediaTypeImageLayerNonDistributableGzip, m.Layers[0].MediaType) //nolint:staticcheck // NonDistributable layers are deprecated, but we want to continue to support manipulating pre-existing images.
+}
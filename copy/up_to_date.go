@@ -0,0 +1,190 @@
+package copy
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/containers/image/v5/internal/image"
+	internalManifest "github.com/containers/image/v5/internal/manifest"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/signature"
+	"github.com/containers/image/v5/transports"
+	"github.com/containers/image/v5/types"
+	"github.com/sirupsen/logrus"
+)
+
+// UpToDateCheck selects how Options.SkipIfUpToDate determines that the destination image is up to date.
+type UpToDateCheck int
+
+const (
+	// UpToDateCheckNone always copies the image. This is the default.
+	UpToDateCheckNone UpToDateCheck = iota
+	// UpToDateCheckDigest skips the copy if the destination manifest has the same digest as the manifest which would be copied
+	// (the source manifest, or with CopySystemImage, the manifest of the instance for the current system).
+	// This is useful when digests are preserved, e.g. when mirroring between registries.
+	UpToDateCheckDigest
+	// UpToDateCheckCreated skips the copy unless the source image was created after the destination image,
+	// according to the "created" values of their configs (with manifest lists, of the instances for the current system;
+	// for non-image artifacts, of the org.opencontainers.image.created manifest annotations).
+	// The copy is not skipped if either value is missing.
+	UpToDateCheckCreated
+)
+
+// validateUpToDateCheck returns an error if check is not a valid UpToDateCheck value.
+func validateUpToDateCheck(check UpToDateCheck) error {
+	switch check {
+	case UpToDateCheckNone, UpToDateCheckDigest, UpToDateCheckCreated:
+		return nil
+	default:
+		return fmt.Errorf("Invalid value for options.SkipIfUpToDate: %d", check)
+	}
+}
+
+// destinationUpToDate returns the manifest of the image at destRef if it is up to date with srcRef according to
+// options.SkipIfUpToDate, or nil if the image should be copied.
+// The source is evaluated using policyContext before anything is compared, so that a skipped copy
+// never succeeds for a source which would be rejected.
+// Failures to read the destination image only cause the image to be copied.
+func destinationUpToDate(ctx context.Context, policyContext *signature.PolicyContext, destRef, srcRef types.ImageReference, options *Options) ([]byte, error) {
+	src, err := srcRef.NewImageSource(ctx, options.SourceCtx)
+	if err != nil {
+		return nil, fmt.Errorf("initializing source %s: %w", transports.ImageName(srcRef), err)
+	}
+	defer src.Close()
+	unparsedSrc := image.UnparsedInstance(src, nil)
+	srcManifest, srcMIMEType, err := unparsedSrc.Manifest(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reading manifest for %s: %w", transports.ImageName(srcRef), err)
+	}
+
+	// Please keep the policy checks BEFORE reading any other information about the image.
+	copiedManifest := srcManifest // The manifest which would be copied, for UpToDateCheckDigest
+	if manifest.MIMETypeIsMultiImage(srcMIMEType) && options.ImageListSelection != CopySystemImage {
+		// We don’t know here exactly which instances would be copied; if any instance is rejected,
+		// let the copy proceed and apply the policy to the instances it actually copies.
+		allowed, err := allInstancesAllowed(ctx, policyContext, src, srcManifest, srcMIMEType)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			logrus.Debugf("Some instances of %s are rejected by policy, not skipping the copy", transports.ImageName(srcRef))
+			return nil, nil
+		}
+	} else {
+		unparsedCopied := unparsedSrc
+		if manifest.MIMETypeIsMultiImage(srcMIMEType) {
+			list, err := internalManifest.ListFromBlob(srcManifest, srcMIMEType)
+			if err != nil {
+				return nil, fmt.Errorf("parsing primary manifest as list for %s: %w", transports.ImageName(srcRef), err)
+			}
+			instanceDigest, err := list.ChooseInstanceByCompression(options.SourceCtx, options.PreferGzipInstances)
+			if err != nil {
+				return nil, fmt.Errorf("choosing an image from manifest list %s: %w", transports.ImageName(srcRef), err)
+			}
+			unparsedCopied = image.UnparsedInstance(src, &instanceDigest)
+			copiedManifest, _, err = unparsedCopied.Manifest(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("reading manifest for %s: %w", transports.ImageName(srcRef), err)
+			}
+		}
+		if allowed, err := policyContext.IsRunningImageAllowed(ctx, unparsedCopied); !allowed || err != nil { // Be paranoid and fail if either return value indicates so.
+			return nil, fmt.Errorf("Source image rejected: %w", err)
+		}
+	}
+
+	dest, err := destRef.NewImageSource(ctx, options.DestinationCtx)
+	if err != nil {
+		logrus.Debugf("Can not read destination %s, copying: %v", transports.ImageName(destRef), err)
+		return nil, nil
+	}
+	defer dest.Close()
+	unparsedDest := image.UnparsedInstance(dest, nil)
+	destManifest, _, err := unparsedDest.Manifest(ctx)
+	if err != nil {
+		logrus.Debugf("Can not read manifest of destination %s, copying: %v", transports.ImageName(destRef), err)
+		return nil, nil
+	}
+
+	switch options.SkipIfUpToDate {
+	case UpToDateCheckDigest:
+		srcDigest, err := manifest.Digest(copiedManifest)
+		if err != nil {
+			return nil, fmt.Errorf("computing digest of source manifest: %w", err)
+		}
+		destDigest, err := manifest.Digest(destManifest)
+		if err != nil {
+			logrus.Debugf("Can not compute digest of destination manifest, copying: %v", err)
+			return nil, nil
+		}
+		if srcDigest != destDigest {
+			logrus.Debugf("Destination manifest %s differs from source manifest %s, copying", destDigest, srcDigest)
+			return nil, nil
+		}
+
+	case UpToDateCheckCreated:
+		srcImg, err := image.FromUnparsedImage(ctx, options.SourceCtx, unparsedSrc)
+		if err != nil {
+			return nil, fmt.Errorf("parsing source image %s: %w", transports.ImageName(srcRef), err)
+		}
+		srcCreated, err := imageCreated(ctx, srcImg)
+		if err != nil {
+			return nil, fmt.Errorf("reading source image configuration for %s: %w", transports.ImageName(srcRef), err)
+		}
+		destImg, err := image.FromUnparsedImage(ctx, options.DestinationCtx, unparsedDest)
+		if err != nil {
+			logrus.Debugf("Can not parse destination image, copying: %v", err)
+			return nil, nil
+		}
+		destCreated, err := imageCreated(ctx, destImg)
+		if err != nil {
+			logrus.Debugf("Can not read destination image configuration, copying: %v", err)
+			return nil, nil
+		}
+		if srcCreated == nil || destCreated == nil {
+			logrus.Debugf("Creation time of the source or destination image is unknown, copying")
+			return nil, nil
+		}
+		if srcCreated.After(*destCreated) {
+			logrus.Debugf("Source image created at %s is newer than destination image created at %s, copying", srcCreated, destCreated)
+			return nil, nil
+		}
+
+	default:
+		return nil, nil
+	}
+	return destManifest, nil
+}
+
+// allInstancesAllowed returns true if policyContext allows all instances of the manifest list srcManifest of src.
+func allInstancesAllowed(ctx context.Context, policyContext *signature.PolicyContext, src types.ImageSource, srcManifest []byte, srcMIMEType string) (bool, error) {
+	list, err := internalManifest.ListFromBlob(srcManifest, srcMIMEType)
+	if err != nil {
+		return false, fmt.Errorf("parsing primary manifest as list for %s: %w", transports.ImageName(src.Reference()), err)
+	}
+	for _, instanceDigest := range list.Instances() {
+		allowed, err := policyContext.IsRunningImageAllowed(ctx, image.UnparsedInstance(src, &instanceDigest))
+		if !allowed || err != nil {
+			logrus.Debugf("Instance %s of %s rejected: %v", instanceDigest, transports.ImageName(src.Reference()), err)
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// imageCreated returns the creation time of img, or nil if it is unknown.
+// For non-image artifacts, which have no image config, it uses the manifest annotation instead.
+func imageCreated(ctx context.Context, img *image.SourcedImage) (*time.Time, error) {
+	if img.IsArtifact() {
+		info, err := img.ArtifactInfo()
+		if err != nil {
+			return nil, err
+		}
+		return info.Created, nil
+	}
+	info, err := img.Inspect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return info.Created, nil
+}
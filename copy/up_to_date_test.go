@@ -0,0 +1,117 @@
+package copy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/containers/image/v5/directory"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/signature"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createDirImageWithCreated creates a dir: image with a single layer containing layerData, and a config with the specified created value.
+func createDirImageWithCreated(t *testing.T, layerData []byte, created string) types.ImageReference {
+	config := []byte(`{"created":"` + created + `","architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":["` + digest.FromBytes(layerData).String() + `"]}}`)
+	m := manifest.OCI1FromComponents(imgspecv1.Descriptor{
+		MediaType: imgspecv1.MediaTypeImageConfig,
+		Digest:    digest.FromBytes(config),
+		Size:      int64(len(config)),
+	}, []imgspecv1.Descriptor{{
+		MediaType: imgspecv1.MediaTypeImageLayer,
+		Digest:    digest.FromBytes(layerData),
+		Size:      int64(len(layerData)),
+	}})
+	manifestBlob, err := m.Serialize()
+	require.NoError(t, err)
+	ref, err := directory.NewReference(writeDirImage(t, manifestBlob, [][]byte{config, layerData}))
+	require.NoError(t, err)
+	return ref
+}
+
+func TestImageSkipIfUpToDate(t *testing.T) {
+	policyContext := newInsecureAcceptAnythingPolicyContext(t)
+	copyImage := func(destRef, srcRef types.ImageReference, check UpToDateCheck) ([]byte, bool) {
+		skipped := true
+		m, err := Image(context.Background(), policyContext, destRef, srcRef, &Options{
+			SkipIfUpToDate:        check,
+			ReportSkippedUpToDate: &skipped,
+		})
+		require.NoError(t, err)
+		return m, skipped
+	}
+
+	t1 := createDirImageWithCreated(t, []byte("layer 1"), "2024-01-01T00:00:00Z")
+	t2 := createDirImageWithCreated(t, []byte("layer 2"), "2024-02-01T00:00:00Z")
+	t0 := createDirImageWithCreated(t, []byte("layer 0"), "2023-12-01T00:00:00Z")
+
+	// Digest checks
+	destRef, err := directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+	m1, skipped := copyImage(destRef, t1, UpToDateCheckDigest) // The destination does not exist yet
+	assert.False(t, skipped)
+	m, skipped := copyImage(destRef, t1, UpToDateCheckDigest)
+	assert.True(t, skipped)
+	assert.Equal(t, m1, m)
+	_, skipped = copyImage(destRef, t0, UpToDateCheckDigest) // Older, but different
+	assert.False(t, skipped)
+	_, skipped = copyImage(destRef, t0, UpToDateCheckNone)
+	assert.False(t, skipped)
+
+	// Created checks
+	destRef, err = directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+	_, skipped = copyImage(destRef, t1, UpToDateCheckCreated) // The destination does not exist yet
+	assert.False(t, skipped)
+	m, skipped = copyImage(destRef, t0, UpToDateCheckCreated) // Older
+	assert.True(t, skipped)
+	assert.Equal(t, m1, m)
+	m, skipped = copyImage(destRef, t1, UpToDateCheckCreated) // Same
+	assert.True(t, skipped)
+	assert.Equal(t, m1, m)
+	m, skipped = copyImage(destRef, t2, UpToDateCheckCreated) // Newer
+	assert.False(t, skipped)
+	assert.NotEqual(t, m1, m)
+
+	// Invalid values
+	_, err = Image(context.Background(), policyContext, destRef, t1, &Options{SkipIfUpToDate: UpToDateCheck(99)})
+	assert.Error(t, err)
+}
+
+func TestImageSkipIfUpToDateRejectedSource(t *testing.T) {
+	t1 := createDirImageWithCreated(t, []byte("layer 1"), "2024-01-01T00:00:00Z")
+	t0 := createDirImageWithCreated(t, []byte("layer 0"), "2023-12-01T00:00:00Z")
+	destRef, err := directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+	_, err = Image(context.Background(), newInsecureAcceptAnythingPolicyContext(t), destRef, t1, &Options{})
+	require.NoError(t, err)
+
+	rejectPolicyContext, err := signature.NewPolicyContext(&signature.Policy{
+		Default: signature.PolicyRequirements{signature.NewPRReject()},
+	})
+	require.NoError(t, err)
+	defer func() {
+		err := rejectPolicyContext.Destroy()
+		require.NoError(t, err)
+	}()
+	for _, c := range []struct {
+		src   types.ImageReference
+		check UpToDateCheck
+	}{
+		{t1, UpToDateCheckDigest},
+		{t1, UpToDateCheckCreated},
+		{t0, UpToDateCheckCreated},
+	} {
+		skipped := true
+		_, err := Image(context.Background(), rejectPolicyContext, destRef, c.src, &Options{
+			SkipIfUpToDate:        c.check,
+			ReportSkippedUpToDate: &skipped,
+		})
+		assert.Error(t, err)
+		assert.False(t, skipped)
+	}
+}
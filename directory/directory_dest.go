@@ -33,7 +33,8 @@ type dirImageDestination struct {
 	stubs.NoPutBlobPartialInitialize
 	stubs.AlwaysSupportsSignatures
 
-	ref dirReference
+	ref       dirReference
+	integrity *integrityRecorder // nil if the integrity manifest is not written
 }
 
 // newImageDestination returns an ImageDestination for writing to a directory.
@@ -99,6 +100,8 @@ func newImageDestination(sys *types.SystemContext, ref dirReference) (private.Im
 	if err != nil {
 		return nil, fmt.Errorf("creating version file %q: %w", ref.versionPath(), err)
 	}
+	integrity := newIntegrityRecorder(sys)
+	integrity.record(filepath.Base(ref.versionPath()), digest.FromString(version))
 
 	d := &dirImageDestination{
 		PropertyMethodsInitialize: impl.PropertyMethods(impl.Properties{
@@ -108,10 +111,15 @@ func newImageDestination(sys *types.SystemContext, ref dirReference) (private.Im
 			MustMatchRuntimeOS:             false,
 			IgnoresEmbeddedDockerReference: false, // N/A, DockerReference() returns nil.
 			HasThreadSafePutBlob:           true,
+			Capabilities: private.DestinationCapabilities{
+				Referrers: types.OptionalBoolFalse,
+				Deletion:  types.OptionalBoolFalse,
+			},
 		}),
 		NoPutBlobPartialInitialize: stubs.NoPutBlobPartial(ref),
 
-		ref: ref,
+		ref:       ref,
+		integrity: integrity,
 	}
 	d.Compat = impl.AddCompat(d)
 	return d, nil
@@ -152,6 +160,11 @@ func (d *dirImageDestination) PutBlobWithOptions(ctx context.Context, stream io.
 	}()
 
 	digester, stream := putblobdigest.DigestIfCanonicalUnknown(stream, inputInfo)
+	var contentsDigester digest.Digester // Set if recording the integrity manifest; unlike digester, this always digests the actual contents.
+	if d.integrity != nil {
+		contentsDigester = digest.Canonical.Digester()
+		stream = io.TeeReader(stream, contentsDigester.Hash())
+	}
 	// TODO: This can take quite some time, and should ideally be cancellable using ctx.Done().
 	size, err := io.Copy(blobFile, stream)
 	if err != nil {
@@ -185,6 +198,9 @@ func (d *dirImageDestination) PutBlobWithOptions(ctx context.Context, stream io.
 	if err := os.Rename(blobFile.Name(), blobPath); err != nil {
 		return private.UploadedBlob{}, err
 	}
+	if contentsDigester != nil {
+		d.integrity.record(filepath.Base(blobPath), contentsDigester.Digest())
+	}
 	succeeded = true
 	return private.UploadedBlob{Digest: blobDigest, Size: size}, nil
 }
@@ -228,7 +244,11 @@ func (d *dirImageDestination) PutManifest(ctx context.Context, manifest []byte,
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(path, manifest, 0644)
+	if err := os.WriteFile(path, manifest, 0644); err != nil {
+		return err
+	}
+	d.integrity.record(filepath.Base(path), digest.FromBytes(manifest))
+	return nil
 }
 
 // PutSignaturesWithFormat writes a set of signatures to the destination.
@@ -248,6 +268,7 @@ func (d *dirImageDestination) PutSignaturesWithFormat(ctx context.Context, signa
 		if err := os.WriteFile(path, blob, 0644); err != nil {
 			return err
 		}
+		d.integrity.record(filepath.Base(path), digest.FromBytes(blob))
 	}
 	return nil
 }
@@ -257,6 +278,9 @@ func (d *dirImageDestination) PutSignaturesWithFormat(ctx context.Context, signa
 // - Uploaded data MAY be visible to others before CommitWithOptions() is called
 // - Uploaded data MAY be removed or MAY remain around if Close() is called without CommitWithOptions() (i.e. rollback is allowed but not guaranteed)
 func (d *dirImageDestination) CommitWithOptions(ctx context.Context, options private.CommitOptions) error {
+	if d.integrity != nil {
+		return d.integrity.write(d.ref)
+	}
 	return nil
 }
 
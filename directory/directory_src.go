@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 
 	"github.com/containers/image/v5/internal/imagesource/impl"
 	"github.com/containers/image/v5/internal/imagesource/stubs"
@@ -21,22 +22,28 @@ type dirImageSource struct {
 	impl.DoesNotAffectLayerInfosForCopy
 	stubs.NoGetBlobAtInitialize
 
-	ref dirReference
+	ref       dirReference
+	integrity *integrityVerifier // nil if the integrity manifest is not verified
 }
 
 // newImageSource returns an ImageSource reading from an existing directory.
 // The caller must call .Close() on the returned ImageSource.
-func newImageSource(ref dirReference) private.ImageSource {
+func newImageSource(sys *types.SystemContext, ref dirReference) (private.ImageSource, error) {
+	integrity, err := loadIntegrityVerifier(sys, ref)
+	if err != nil {
+		return nil, fmt.Errorf("verifying %q: %w", ref.path, err)
+	}
 	s := &dirImageSource{
 		PropertyMethodsInitialize: impl.PropertyMethods(impl.Properties{
 			HasThreadSafeGetBlob: false,
 		}),
 		NoGetBlobAtInitialize: stubs.NoGetBlobAt(ref),
 
-		ref: ref,
+		ref:       ref,
+		integrity: integrity,
 	}
 	s.Compat = impl.AddCompat(s)
-	return s
+	return s, nil
 }
 
 // Reference returns the reference used to set up this source, _as specified by the user_
@@ -63,6 +70,9 @@ func (s *dirImageSource) GetManifest(ctx context.Context, instanceDigest *digest
 	if err != nil {
 		return nil, "", err
 	}
+	if err := s.integrity.verifyContents(filepath.Base(path), m); err != nil {
+		return nil, "", err
+	}
 	return m, manifest.GuessMIMEType(m), err
 }
 
@@ -80,9 +90,15 @@ func (s *dirImageSource) GetBlob(ctx context.Context, info types.BlobInfo, cache
 	}
 	fi, err := r.Stat()
 	if err != nil {
+		r.Close()
 		return nil, -1, err
 	}
-	return r, fi.Size(), nil
+	stream, err := s.integrity.verifyingReader(filepath.Base(path), r)
+	if err != nil {
+		r.Close()
+		return nil, -1, err
+	}
+	return stream, fi.Size(), nil
 }
 
 // GetSignaturesWithFormat returns the image's signatures.  It may use a remote (= slow) service.
@@ -99,10 +115,16 @@ func (s *dirImageSource) GetSignaturesWithFormat(ctx context.Context, instanceDi
 		sigBlob, err := os.ReadFile(path)
 		if err != nil {
 			if os.IsNotExist(err) {
+				if err := s.integrity.verifyMissing(filepath.Base(path)); err != nil {
+					return nil, err
+				}
 				break
 			}
 			return nil, err
 		}
+		if err := s.integrity.verifyContents(filepath.Base(path), sigBlob); err != nil {
+			return nil, err
+		}
 		signature, err := signature.FromBlob(sigBlob)
 		if err != nil {
 			return nil, fmt.Errorf("parsing signature %q: %w", path, err)
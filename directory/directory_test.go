@@ -3,9 +3,12 @@ package directory
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/containers/image/v5/internal/private"
@@ -208,3 +211,116 @@ func TestSourceReference(t *testing.T) {
 	ref2 := src.Reference()
 	assert.Equal(t, tmpDir, ref2.StringWithinTransport())
 }
+
+func TestIntegrityManifest(t *testing.T) {
+	ctx := context.Background()
+	ref, tmpDir := refToTempDir(t)
+	sys := &types.SystemContext{DirIntegrityKey: []byte("secret")}
+	cache := memory.New()
+
+	man := []byte("test-manifest")
+	blob := []byte("test-blob")
+	blobDigest := digest.FromBytes(blob)
+	signatures := [][]byte{[]byte("\xA3sig1"), []byte("\xA3sig2")}
+
+	// write creates the image, with the integrity manifest if sys is set.
+	write := func(sys *types.SystemContext) {
+		dest, err := ref.NewImageDestination(ctx, sys)
+		require.NoError(t, err)
+		defer dest.Close()
+		_, err = dest.PutBlob(ctx, bytes.NewReader(blob), types.BlobInfo{Digest: blobDigest, Size: int64(len(blob))}, cache, false)
+		require.NoError(t, err)
+		err = dest.PutManifest(ctx, man, nil)
+		require.NoError(t, err)
+		err = dest.PutSignatures(ctx, signatures, nil)
+		require.NoError(t, err)
+		err = dest.Commit(ctx, nil) // nil unparsedToplevel is invalid, we don’t currently use the value
+		require.NoError(t, err)
+	}
+	// read reads all of the image through src.
+	read := func(src types.ImageSource) error {
+		if _, _, err := src.GetManifest(ctx, nil); err != nil {
+			return err
+		}
+		if _, err := src.GetSignatures(ctx, nil); err != nil {
+			return err
+		}
+		stream, _, err := src.GetBlob(ctx, types.BlobInfo{Digest: blobDigest, Size: -1}, cache)
+		if err != nil {
+			return err
+		}
+		defer stream.Close()
+		_, err = io.ReadAll(stream)
+		return err
+	}
+	// readWithModification creates a fresh image, calls modify, and reads the image using sys.
+	readWithModification := func(sys *types.SystemContext, modify func()) error {
+		write(&types.SystemContext{DirIntegrityKey: []byte("secret")})
+		modify()
+		src, err := ref.NewImageSource(ctx, sys)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		return read(src)
+	}
+
+	// Success
+	err := readWithModification(sys, func() {})
+	assert.NoError(t, err)
+	err = readWithModification(&types.SystemContext{DirIntegrity: true}, func() {})
+	assert.NoError(t, err)
+
+	// Modified files are detected
+	blobPath, err := ref.(dirReference).layerPath(blobDigest)
+	require.NoError(t, err)
+	for _, path := range []string{filepath.Join(tmpDir, "manifest.json"), filepath.Join(tmpDir, "signature-1"), blobPath} {
+		err = readWithModification(sys, func() {
+			err := os.WriteFile(path, []byte("\xA3modified"), 0o644)
+			require.NoError(t, err)
+		})
+		assert.Error(t, err, path)
+	}
+	// Removed signatures are detected
+	err = readWithModification(sys, func() {
+		err := os.Remove(filepath.Join(tmpDir, "signature-2"))
+		require.NoError(t, err)
+	})
+	assert.Error(t, err)
+	// Added signatures are detected
+	err = readWithModification(sys, func() {
+		err := os.WriteFile(filepath.Join(tmpDir, "signature-3"), []byte("\xA3sig3"), 0o644)
+		require.NoError(t, err)
+	})
+	assert.Error(t, err)
+	// A different key is rejected
+	err = readWithModification(&types.SystemContext{DirIntegrityKey: []byte("other")}, func() {})
+	assert.Error(t, err)
+	// A modified integrity manifest is rejected
+	err = readWithModification(sys, func() {
+		var m integrityManifest
+		b, err := os.ReadFile(filepath.Join(tmpDir, "integrity.json"))
+		require.NoError(t, err)
+		err = json.Unmarshal(b, &m)
+		require.NoError(t, err)
+		m.Files["manifest.json"] = digest.FromString("modified")
+		m.Checksum = integrityChecksum(m.Files)
+		b, err = json.Marshal(m)
+		require.NoError(t, err)
+		err = os.WriteFile(filepath.Join(tmpDir, "integrity.json"), b, 0o644)
+		require.NoError(t, err)
+	})
+	assert.Error(t, err)
+
+	// A missing integrity manifest is rejected only if verification is requested
+	write(nil)
+	_, err = os.Stat(filepath.Join(tmpDir, "integrity.json"))
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	_, err = ref.NewImageSource(ctx, sys)
+	assert.Error(t, err)
+	src, err := ref.NewImageSource(ctx, nil)
+	require.NoError(t, err)
+	defer src.Close()
+	err = read(src)
+	assert.NoError(t, err)
+}
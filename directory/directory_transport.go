@@ -146,7 +146,7 @@ func (ref dirReference) NewImage(ctx context.Context, sys *types.SystemContext)
 // NewImageSource returns a types.ImageSource for this reference.
 // The caller must call .Close() on the returned ImageSource.
 func (ref dirReference) NewImageSource(ctx context.Context, sys *types.SystemContext) (types.ImageSource, error) {
-	return newImageSource(ref), nil
+	return newImageSource(sys, ref)
 }
 
 // NewImageDestination returns a types.ImageDestination for this reference.
@@ -195,3 +195,8 @@ func (ref dirReference) signaturePath(index int, instanceDigest *digest.Digest)
 func (ref dirReference) versionPath() string {
 	return filepath.Join(ref.path, "version")
 }
+
+// integrityPath returns a path for the integrity manifest within a directory using our conventions.
+func (ref dirReference) integrityPath() string {
+	return filepath.Join(ref.path, "integrity.json")
+}
@@ -0,0 +1,215 @@
+package directory
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+)
+
+// integrityManifest is the on-disk format of the integrity manifest of a directory, see types.SystemContext.DirIntegrity.
+type integrityManifest struct {
+	// Files maps the name of every file in the directory, other than the integrity manifest itself, to the digest of its contents.
+	Files map[string]digest.Digest `json:"files"`
+	// Checksum is the digest of the canonical form of Files, see integrityChecksum.
+	Checksum digest.Digest `json:"checksum"`
+	// Signature, if present, is HMAC-SHA256 of Checksum using types.SystemContext.DirIntegrityKey.
+	Signature []byte `json:"signature,omitempty"`
+}
+
+// integrityOptions returns whether the integrity manifest should be used according to sys, and the key to authenticate it, if any.
+func integrityOptions(sys *types.SystemContext) (bool, []byte) {
+	if sys == nil {
+		return false, nil
+	}
+	return sys.DirIntegrity || len(sys.DirIntegrityKey) != 0, sys.DirIntegrityKey
+}
+
+// integrityChecksum returns the digest of the canonical form of files:
+// one line of "<digest>  <name>\n" per file, sorted by name.
+func integrityChecksum(files map[string]digest.Digest) digest.Digest {
+	var b strings.Builder
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		fmt.Fprintf(&b, "%s  %s\n", files[name].String(), name)
+	}
+	return digest.Canonical.FromString(b.String())
+}
+
+// integritySignature returns the HMAC-SHA256 of checksum using key.
+func integritySignature(checksum digest.Digest, key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(checksum.String()))
+	return mac.Sum(nil)
+}
+
+// integrityRecorder collects the digests of files written by a dirImageDestination.
+type integrityRecorder struct {
+	key   []byte
+	mutex sync.Mutex
+	files map[string]digest.Digest // Protected by mutex
+}
+
+// newIntegrityRecorder returns an integrityRecorder, or nil if the integrity manifest should not be written according to sys.
+func newIntegrityRecorder(sys *types.SystemContext) *integrityRecorder {
+	enabled, key := integrityOptions(sys)
+	if !enabled {
+		return nil
+	}
+	return &integrityRecorder{key: key, files: map[string]digest.Digest{}}
+}
+
+// record records that the file name in the directory has contents with fileDigest.
+// It does nothing if r is nil.
+func (r *integrityRecorder) record(name string, fileDigest digest.Digest) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.files[name] = fileDigest
+}
+
+// write writes the integrity manifest for ref.
+func (r *integrityRecorder) write(ref dirReference) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	m := integrityManifest{
+		Files:    r.files,
+		Checksum: integrityChecksum(r.files),
+	}
+	if len(r.key) != 0 {
+		m.Signature = integritySignature(m.Checksum, r.key)
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(ref.integrityPath(), b, 0644); err != nil {
+		return fmt.Errorf("writing integrity manifest: %w", err)
+	}
+	return nil
+}
+
+// integrityVerifier verifies files read by a dirImageSource against an integrity manifest.
+type integrityVerifier struct {
+	files map[string]digest.Digest
+}
+
+// loadIntegrityVerifier returns an integrityVerifier for ref, or nil if the integrity manifest should not be verified according to sys.
+func loadIntegrityVerifier(sys *types.SystemContext, ref dirReference) (*integrityVerifier, error) {
+	enabled, key := integrityOptions(sys)
+	if !enabled {
+		return nil, nil
+	}
+	b, err := os.ReadFile(ref.integrityPath())
+	if err != nil {
+		return nil, fmt.Errorf("reading integrity manifest: %w", err)
+	}
+	var m integrityManifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("parsing integrity manifest: %w", err)
+	}
+	for name, d := range m.Files {
+		if err := d.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid digest of %q in integrity manifest: %w", name, err)
+		}
+	}
+	if checksum := integrityChecksum(m.Files); checksum != m.Checksum {
+		return nil, fmt.Errorf("integrity manifest checksum mismatch: expected %s, computed %s", m.Checksum, checksum)
+	}
+	if len(key) != 0 {
+		if len(m.Signature) == 0 {
+			return nil, errors.New("integrity manifest is not signed")
+		}
+		if !hmac.Equal(m.Signature, integritySignature(m.Checksum, key)) {
+			return nil, errors.New("integrity manifest signature does not match the key")
+		}
+	}
+	return &integrityVerifier{files: m.Files}, nil
+}
+
+// expectedDigest returns the digest of the file name in the directory, as recorded in the integrity manifest.
+func (v *integrityVerifier) expectedDigest(name string) (digest.Digest, error) {
+	d, ok := v.files[name]
+	if !ok {
+		return "", fmt.Errorf("file %q is not listed in the integrity manifest", name)
+	}
+	return d, nil
+}
+
+// verifyContents fails if data, the contents of the file name in the directory, don’t match the integrity manifest.
+// It does nothing if v is nil.
+func (v *integrityVerifier) verifyContents(name string, data []byte) error {
+	if v == nil {
+		return nil
+	}
+	expected, err := v.expectedDigest(name)
+	if err != nil {
+		return err
+	}
+	if actual := expected.Algorithm().FromBytes(data); actual != expected {
+		return fmt.Errorf("file %q does not match the integrity manifest: expected %s, got %s", name, expected, actual)
+	}
+	return nil
+}
+
+// verifyMissing fails if the file name in the directory, which does not exist, is listed in the integrity manifest.
+// It does nothing if v is nil.
+func (v *integrityVerifier) verifyMissing(name string) error {
+	if v == nil {
+		return nil
+	}
+	if _, ok := v.files[name]; ok {
+		return fmt.Errorf("file %q is listed in the integrity manifest, but missing", name)
+	}
+	return nil
+}
+
+// verifyingReader returns a reader for stream, the contents of the file name in the directory,
+// which fails at EOF if the contents don’t match the integrity manifest.
+// It returns stream unmodified if v is nil.
+func (v *integrityVerifier) verifyingReader(name string, stream io.ReadCloser) (io.ReadCloser, error) {
+	if v == nil {
+		return stream, nil
+	}
+	expected, err := v.expectedDigest(name)
+	if err != nil {
+		return nil, err
+	}
+	return &verifyingReader{source: stream, name: name, expected: expected, verifier: expected.Verifier()}, nil
+}
+
+// verifyingReader is an io.ReadCloser which fails at EOF if the contents of source don’t match expected.
+type verifyingReader struct {
+	source   io.ReadCloser
+	name     string
+	expected digest.Digest
+	verifier digest.Verifier
+}
+
+func (r *verifyingReader) Read(p []byte) (int, error) {
+	n, err := r.source.Read(p)
+	if n > 0 {
+		if _, err := r.verifier.Write(p[:n]); err != nil {
+			return 0, err // Coverage: This should not happen, digest.Verifier.Write never fails.
+		}
+	}
+	if errors.Is(err, io.EOF) && !r.verifier.Verified() {
+		return n, fmt.Errorf("file %q does not match the integrity manifest digest %s", r.name, r.expected)
+	}
+	return n, err
+}
+
+func (r *verifyingReader) Close() error {
+	return r.source.Close()
+}
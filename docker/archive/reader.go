@@ -1,7 +1,10 @@
 package archive
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 
 	"github.com/containers/image/v5/docker/internal/tarfile"
 	"github.com/containers/image/v5/docker/reference"
@@ -66,6 +69,7 @@ func NewReaderForReference(sys *types.SystemContext, ref types.ImageReference) (
 
 // List returns the a set of references for images in the Reader,
 // grouped by the image the references point to.
+// Tags of multi-platform images refer to manifest lists; the per-platform images are listed without tags.
 // The references are valid only until the Reader is closed.
 func (r *Reader) List() ([][]types.ImageReference, error) {
 	res := [][]types.ImageReference{}
@@ -95,6 +99,30 @@ func (r *Reader) List() ([][]types.ImageReference, error) {
 		}
 		res = append(res, refs)
 	}
+	for _, list := range r.archive.ManifestLists {
+		refs := []types.ImageReference{}
+		for _, tag := range list.RepoTags {
+			parsedTag, err := reference.ParseNormalizedNamed(tag)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid tag %#v of manifest list %s: %w", tag, list.Digest.String(), err)
+			}
+			nt, ok := parsedTag.(reference.NamedTagged)
+			if !ok {
+				return nil, fmt.Errorf("Invalid tag %q (%s): does not contain a tag", tag, parsedTag.String())
+			}
+			if _, _, err := r.archive.ChooseManifestItem(nt, -1); err == nil {
+				continue // The tag refers to an image in manifest.json, which takes precedence; see tarfile.Source.
+			}
+			ref, err := newReference(r.path, nt, -1, r.archive, nil)
+			if err != nil {
+				return nil, fmt.Errorf("creating a reference for tag %#v of manifest list %s: %w", tag, list.Digest.String(), err)
+			}
+			refs = append(refs, ref)
+		}
+		if len(refs) != 0 {
+			res = append(res, refs)
+		}
+	}
 	return res, nil
 }
 
@@ -112,6 +140,11 @@ func (r *Reader) ManifestTagsForReference(ref types.ImageReference) ([]string, e
 	}
 	manifestItem, tagIndex, err := r.archive.ChooseManifestItem(archiveRef.ref, archiveRef.sourceIndex)
 	if err != nil {
+		if archiveRef.ref != nil {
+			if list, listTagIndex, listErr := r.archive.ChooseManifestList(archiveRef.ref); listErr == nil && list != nil {
+				return []string{list.RepoTags[listTagIndex]}, nil
+			}
+		}
 		return nil, err
 	}
 	if tagIndex != -1 {
@@ -119,3 +152,66 @@ func (r *Reader) ManifestTagsForReference(ref types.ImageReference) ([]string, e
 	}
 	return manifestItem.RepoTags, nil
 }
+
+// RemoveImages writes a copy of the archive to path, without the images referenced by refs, which must have been returned by r.List().
+// A reference with a tag removes only that tag; an image (or a multi-platform image) is removed when all of its tags are removed.
+// A reference to an untagged image removes the image.
+// Layers and other files shared with the images remaining in the archive are preserved.
+// path may be the path of the archive read by r; the archive is replaced only after the copy is successfully written,
+// and r (and references created by it) must not be used afterwards, other than calling r.Close().
+// The new archive is not compressed.
+func (r *Reader) RemoveImages(refs []types.ImageReference, path string) error {
+	removeTags := []reference.NamedTagged{}
+	removeImages := []int{}
+	for _, ref := range refs {
+		archiveRef, ok := ref.(archiveReference)
+		if !ok {
+			return fmt.Errorf("Internal error: RemoveImages called for a non-docker/archive ImageReference %s", transports.ImageName(ref))
+		}
+		if archiveRef.archiveReader != r.archive {
+			return fmt.Errorf("Internal error: RemoveImages called for a reference %s not created by this Reader", archiveRef.StringWithinTransport())
+		}
+		switch {
+		case archiveRef.ref != nil:
+			removeTags = append(removeTags, archiveRef.ref)
+		case archiveRef.sourceIndex != -1:
+			removeImages = append(removeImages, archiveRef.sourceIndex)
+		default:
+			return errors.New("Internal error: RemoveImages called for a reference without a tag or a source index")
+		}
+	}
+
+	mode := os.FileMode(0o644)
+	if fi, err := os.Stat(path); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".docker-archive-*")
+	if err != nil {
+		return fmt.Errorf("creating a temporary file for %q: %w", path, err)
+	}
+	succeeded := false
+	closed := false
+	defer func() {
+		if !succeeded {
+			if !closed {
+				f.Close()
+			}
+			os.Remove(f.Name())
+		}
+	}()
+	if err := r.archive.WriteWithoutImages(f, removeTags, removeImages); err != nil {
+		return err
+	}
+	if err := f.Chmod(mode); err != nil {
+		return err
+	}
+	closed = true
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return err
+	}
+	succeeded = true
+	return nil
+}
@@ -1,6 +1,7 @@
 package archive
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -9,6 +10,8 @@ import (
 
 	"github.com/containers/image/v5/docker/internal/tarfile"
 	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/internal/archiveprogress"
+	"github.com/containers/image/v5/internal/seekablezstd"
 	"github.com/containers/image/v5/types"
 )
 
@@ -16,8 +19,10 @@ import (
 type Writer struct {
 	path        string // The original, user-specified path; not the maintained temporary file, if any
 	regularFile bool   // path refers to a regular file (e.g. not a pipe)
+	appending   bool   // Images are added to a pre-existing archive at path
 	archive     *tarfile.Writer
-	writer      io.Closer
+	writer      io.Closer                 // nil if archive closes the underlying file
+	progress    *archiveprogress.Reporter // nil if progress of writing the archive should not be reported
 
 	// The following state can only be accessed with the mutex held.
 	mutex     sync.Mutex
@@ -27,6 +32,18 @@ type Writer struct {
 // NewWriter returns a Writer for path.
 // The caller should call .Close() on the returned object.
 func NewWriter(sys *types.SystemContext, path string) (*Writer, error) {
+	options, err := tarfile.WriterOptionsFromSystemContext(sys)
+	if err != nil {
+		return nil, err
+	}
+
+	if sys != nil && sys.DockerArchiveCompression != nil && sys.ArchiveSeekableZstd {
+		return nil, errors.New("DockerArchiveCompression and ArchiveSeekableZstd can not be used together")
+	}
+	if sys != nil && sys.DockerArchiveEntryIndex && (sys.DockerArchiveCompression != nil || sys.ArchiveSeekableZstd) {
+		return nil, errors.New("DockerArchiveEntryIndex can not be combined with DockerArchiveCompression or ArchiveSeekableZstd")
+	}
+
 	// path can be either a pipe or a regular file
 	// in the case of a pipe, we require that we can open it for write
 	// in the case of a regular file, we don't want to overwrite any pre-existing file
@@ -49,21 +66,82 @@ func NewWriter(sys *types.SystemContext, path string) (*Writer, error) {
 	}
 	regularFile := fhStat.Mode().IsRegular()
 	if regularFile && fhStat.Size() != 0 {
-		return nil, errors.New("docker-archive doesn't support modifying existing images")
+		if sys == nil || !sys.DockerArchiveAppend {
+			return nil, errors.New("docker-archive doesn't support modifying existing images")
+		}
+		if sys.ArchiveSeekableZstd {
+			return nil, errors.New("adding images to an existing docker-archive can not be combined with ArchiveSeekableZstd")
+		}
+		if sys.DockerArchiveCompression != nil {
+			return nil, errors.New("adding images to an existing docker-archive can not be combined with DockerArchiveCompression")
+		}
+		if sys.DockerArchiveLegacyMetadata == types.DockerArchiveLegacyMetadataOnly {
+			return nil, errors.New("adding images to an existing docker-archive can not be combined with DockerArchiveLegacyMetadataOnly")
+		}
+		fh.Close()
+		succeeded = true // fh is already closed
+		options.EntryIndex = sys.DockerArchiveEntryIndex
+		archive, err := tarfile.OpenWriterForAppendWithOptions(path, options)
+		if err != nil {
+			return nil, err
+		}
+		return &Writer{
+			path:        path,
+			regularFile: regularFile,
+			appending:   true,
+			archive:     archive,
+			writer:      nil, // archive owns the file
+			hadCommit:   false,
+		}, nil
 	}
 
-	archive := tarfile.NewWriter(fh)
+	var dest io.Writer = fh
+	var closer io.Closer = fh
+	if sys != nil && sys.ArchiveSeekableZstd {
+		compressor, err := seekablezstd.NewWriter(fh)
+		if err != nil {
+			return nil, err
+		}
+		dest = compressor
+		closer = &compressedFileCloser{compressor: compressor, file: fh}
+	}
+	progress := archiveprogress.NewPacking(sys)
+	options.EntryIndex = sys != nil && sys.DockerArchiveEntryIndex && regularFile
+	if progress != nil {
+		options.Progress = func(p tarfile.WriterProgress) {
+			progress.SetEntry(p.Path, uint64(p.EntryOffset), uint64(p.EntrySize))
+		}
+	}
+	archive, err := tarfile.NewWriterWithOptions(progress.Writer(dest), options)
+	if err != nil {
+		return nil, err
+	}
 
 	succeeded = true
 	return &Writer{
 		path:        path,
 		regularFile: regularFile,
 		archive:     archive,
-		writer:      fh,
+		writer:      closer,
+		progress:    progress,
 		hadCommit:   false,
 	}, nil
 }
 
+// compressedFileCloser finishes a compressed stream, and closes the file it is written to.
+type compressedFileCloser struct {
+	compressor io.Closer
+	file       io.Closer
+}
+
+func (c *compressedFileCloser) Close() error {
+	err := c.compressor.Close()
+	if err2 := c.file.Close(); err2 != nil && err == nil {
+		err = err2
+	}
+	return err
+}
+
 // imageCommitted notifies the Writer that at least one image was successfully committed to the stream.
 func (w *Writer) imageCommitted() {
 	w.mutex.Lock()
@@ -71,15 +149,29 @@ func (w *Writer) imageCommitted() {
 	w.hadCommit = true
 }
 
+// AdditionalFilesCallback is called by Writer.Close, after all images have been added, to add custom files
+// (e.g. a LICENSE, or a list of checksums) to the archive by calling addFile.
+// addFile writes a regular file at path (relative to the archive root, using forward slashes), with size bytes read from contents.
+type AdditionalFilesCallback func(ctx context.Context, addFile func(path string, size int64, contents io.Reader) error) error
+
+// AddFilesOnClose registers callback to add custom files to the archive when the Writer is closed.
+// Callbacks are called in the order they were registered; the paths must not conflict with files describing the images.
+// The callback must not use the Writer.
+func (w *Writer) AddFilesOnClose(callback AdditionalFilesCallback) error {
+	return w.archive.AddFilesOnClose(tarfile.AdditionalFilesCallback(callback))
+}
+
 // Close writes all outstanding data about images to the archive, and
 // releases state associated with the Writer, if any.
 // No more images can be added after this is called.
 func (w *Writer) Close() error {
 	err := w.archive.Close()
-	if err2 := w.writer.Close(); err2 != nil && err == nil {
-		err = err2
+	if w.writer != nil {
+		if err2 := w.writer.Close(); err2 != nil && err == nil {
+			err = err2
+		}
 	}
-	if err == nil && w.regularFile && !w.hadCommit {
+	if err == nil && w.regularFile && !w.appending && !w.hadCommit {
 		// Writing to the destination never had a success; delete the destination if we created it.
 		// This is done primarily because we don’t implement adding another image to a pre-existing image, so if we
 		// left a partial archive around (notably because reading from the _source_ has failed), we couldn’t retry without
@@ -93,6 +185,9 @@ func (w *Writer) Close() error {
 			err = err2
 		}
 	}
+	if err == nil && w.hadCommit {
+		w.progress.Done()
+	}
 	return err
 }
 
@@ -0,0 +1,166 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/pkg/docker/config"
+	"github.com/containers/image/v5/pkg/sysregistriesv2"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/sirupsen/logrus"
+)
+
+// blobLocationHintRel is the relation type of a Link header, returned by a registry which does not serve a blob
+// (e.g. an upstream cache), pointing at the canonical location of the blob, e.g.
+// Link: <https://registry.example.com/v2/library/busybox/blobs/sha256:…>; rel="canonical"
+const blobLocationHintRel = "canonical"
+
+// blobLocationHint returns the repository hinted by header as the canonical location of the blob with blobDigest,
+// or nil if there is no usable hint.
+func blobLocationHint(header http.Header, blobDigest digest.Digest) reference.Named {
+	for _, link := range header.Values("Link") {
+		for _, value := range strings.Split(link, ",") {
+			target, params, ok := strings.Cut(strings.TrimSpace(value), ";")
+			if !ok || !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			isHint := false
+			for _, param := range strings.Split(params, ";") {
+				name, arg, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if strings.EqualFold(name, "rel") && strings.Trim(arg, `"`) == blobLocationHintRel {
+					isHint = true
+				}
+			}
+			if !isHint {
+				continue
+			}
+			hintURL, err := url.Parse(strings.TrimSuffix(strings.TrimPrefix(target, "<"), ">"))
+			if err != nil || !hintURL.IsAbs() || hintURL.Host == "" || hintURL.RawQuery != "" {
+				logrus.Debugf("Ignoring invalid blob location hint %q", target)
+				continue
+			}
+			repo, ok := strings.CutPrefix(hintURL.Path, "/v2/")
+			if !ok {
+				logrus.Debugf("Ignoring invalid blob location hint %q", target)
+				continue
+			}
+			repo, ok = strings.CutSuffix(repo, "/blobs/"+blobDigest.String())
+			if !ok {
+				logrus.Debugf("Ignoring blob location hint %q which does not refer to blob %s", target, blobDigest.String())
+				continue
+			}
+			named, err := reference.ParseNamed(hintURL.Host + "/" + repo)
+			if err != nil || !reference.IsNameOnly(named) {
+				logrus.Debugf("Ignoring invalid blob location hint %q", target)
+				continue
+			}
+			return named
+		}
+	}
+	return nil
+}
+
+// getBlobFromLocationHint returns a stream for the blob described by info from hintedRepo, a location hinted by the registry
+// of ref, and the blob’s size (or -1 if unknown). The stream fails at EOF if the data does not match info.Digest.
+// If origin is not nil, it is updated to record where the blob was read from.
+func (c *dockerClient) getBlobFromLocationHint(ctx context.Context, ref dockerReference, hintedRepo reference.Named, info types.BlobInfo,
+	cache types.BlobInfoCache, origin *private.ContentOrigin) (io.ReadCloser, int64, error) {
+	hintedRef, err := newReference(hintedRepo, true)
+	if err != nil {
+		return nil, 0, err
+	}
+	registry, err := sysregistriesv2.FindRegistry(c.sys, hintedRepo.Name())
+	if err != nil {
+		return nil, 0, fmt.Errorf("loading registries configuration: %w", err)
+	}
+	if registry != nil && registry.Blocked {
+		return nil, 0, fmt.Errorf("blob location hint %s is blocked in registries configuration", hintedRepo.Name())
+	}
+
+	sys := c.sys
+	// sys.DockerAuthConfig does not explicitly specify a registry; we must not blindly send the credentials intended for ref to a different registry.
+	if sys != nil && sys.DockerAuthConfig != nil && reference.Domain(hintedRepo) != reference.Domain(ref.ref) {
+		copy := *sys
+		copy.DockerAuthConfig = nil
+		copy.DockerBearerRegistryToken = ""
+		sys = &copy
+	}
+	auth, err := config.GetCredentialsForRef(sys, hintedRepo)
+	if err != nil {
+		return nil, 0, fmt.Errorf("getting username and password: %w", err)
+	}
+	client, err := newDockerClient(sys, reference.Domain(hintedRepo), hintedRepo.Name())
+	if err != nil {
+		return nil, 0, err
+	}
+	client.auth = auth
+	if sys != nil {
+		client.registryToken = sys.DockerBearerRegistryToken
+	}
+	client.scope.resourceType = "repository"
+	client.scope.actions = "pull"
+	client.scope.remoteName = reference.Path(hintedRepo)
+
+	path := fmt.Sprintf(blobsPath, reference.Path(hintedRepo), info.Digest.String())
+	logrus.Debugf("Downloading %s from %s, as hinted by %s", path, reference.Domain(hintedRepo), reference.Domain(ref.ref))
+	res, err := client.makeRequest(ctx, http.MethodGet, path, nil, nil, v2Auth, nil)
+	if err != nil {
+		client.Close()
+		return nil, 0, err
+	}
+	if res.StatusCode != http.StatusOK {
+		err := registryHTTPResponseToError(res)
+		res.Body.Close()
+		client.Close()
+		return nil, 0, fmt.Errorf("fetching blob from hinted location %s: %w", hintedRepo.Name(), err)
+	}
+	cache.RecordKnownLocation(hintedRef.Transport(), bicTransportScope(hintedRef), info.Digest, newBICLocationReference(hintedRef))
+	if origin != nil {
+		origin.Endpoint = hintedRepo.Name()
+	}
+	blobSize, err := getBlobSize(res)
+	if err != nil {
+		blobSize = -1
+	}
+	return &hintedBlobReader{
+		body:     res.Body,
+		client:   client,
+		digest:   info.Digest,
+		verifier: info.Digest.Verifier(),
+	}, blobSize, nil
+}
+
+// hintedBlobReader is a stream of a blob fetched from a hinted location, which fails at EOF if the data does not match digest.
+// It owns client, and closes it when closed.
+type hintedBlobReader struct {
+	body     io.ReadCloser
+	client   *dockerClient
+	digest   digest.Digest
+	verifier digest.Verifier
+}
+
+func (r *hintedBlobReader) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	if n > 0 {
+		_, _ = r.verifier.Write(p[:n]) // Writes to a digest.Verifier never fail.
+	}
+	if err == io.EOF && !r.verifier.Verified() {
+		return n, fmt.Errorf("blob fetched from a hinted location does not match the expected digest %s", r.digest.String())
+	}
+	return n, err
+}
+
+func (r *hintedBlobReader) Close() error {
+	err := r.body.Close()
+	if err2 := r.client.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
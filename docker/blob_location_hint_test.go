@@ -0,0 +1,131 @@
+package docker
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/pkg/blobinfocache/memory"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlobLocationHint(t *testing.T) {
+	blobDigest := digest.FromString("blob")
+	for _, c := range []struct {
+		links    []string
+		expected string
+	}{
+		{nil, ""},
+		{[]string{`<https://registry.example.com/v2/ns/repo/blobs/` + blobDigest.String() + `>; rel="canonical"`}, "registry.example.com/ns/repo"},
+		{[]string{`<https://registry.example.com:5000/v2/repo/blobs/` + blobDigest.String() + `>;rel=canonical`}, "registry.example.com:5000/repo"},
+		{[]string{`</v2/other/tags/list?n=10>; rel="next", <https://registry.example.com/v2/repo/blobs/` + blobDigest.String() + `>; rel="canonical"`}, "registry.example.com/repo"},
+		{[]string{`</v2/other/tags/list?n=10>; rel="next"`, `<https://registry.example.com/v2/repo/blobs/` + blobDigest.String() + `>; rel="canonical"`}, "registry.example.com/repo"},
+		{[]string{`<https://registry.example.com/v2/repo/blobs/` + blobDigest.String() + `>; rel="next"`}, ""},                      // Not a hint
+		{[]string{`</v2/repo/blobs/` + blobDigest.String() + `>; rel="canonical"`}, ""},                                             // Relative
+		{[]string{`<https://registry.example.com/v2/repo/blobs/` + digest.FromString("other").String() + `>; rel="canonical"`}, ""}, // Different blob
+		{[]string{`<https://registry.example.com/v2/repo/manifests/` + blobDigest.String() + `>; rel="canonical"`}, ""},             // Not a blob
+		{[]string{`<https://registry.example.com/repo/blobs/` + blobDigest.String() + `>; rel="canonical"`}, ""},                    // Not a /v2/ path
+		{[]string{`<https://registry.example.com/v2/Repo/blobs/` + blobDigest.String() + `>; rel="canonical"`}, ""},                 // Invalid repository
+		{[]string{`<https://registry.example.com/v2/repo:tag/blobs/` + blobDigest.String() + `>; rel="canonical"`}, ""},             // Not a repository
+		{[]string{`<https://registry.example.com/v2/repo/blobs/` + blobDigest.String() + `?x=y>; rel="canonical"`}, ""},             // Query
+	} {
+		header := http.Header{}
+		for _, l := range c.links {
+			header.Add("Link", l)
+		}
+		res := blobLocationHint(header, blobDigest)
+		if c.expected == "" {
+			assert.Nil(t, res, "%#v", c.links)
+		} else if assert.NotNil(t, res, "%#v", c.links) {
+			assert.Equal(t, c.expected, res.Name(), "%#v", c.links)
+		}
+	}
+}
+
+func TestGetBlobFromLocationHint(t *testing.T) {
+	blob := []byte("blob contents")
+	blobDigest := digest.FromBytes(blob)
+	corruptDigest := digest.FromString("corrupt")
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/":
+			rw.WriteHeader(http.StatusOK)
+		case "/v2/upstream/repo/blobs/" + blobDigest.String(), "/v2/upstream/repo/blobs/" + corruptDigest.String():
+			_, err := rw.Write(blob)
+			assert.NoError(t, err)
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer upstream.Close()
+	upstreamURL, err := url.Parse(upstream.URL)
+	require.NoError(t, err)
+
+	cacheServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			rw.WriteHeader(http.StatusOK)
+			return
+		}
+		if r.URL.Path == "/v2/repo/blobs/"+blobDigest.String() || r.URL.Path == "/v2/repo/blobs/"+corruptDigest.String() {
+			d := r.URL.Path[len("/v2/repo/blobs/"):]
+			rw.Header().Set("Link", "<"+upstream.URL+"/v2/upstream/repo/blobs/"+d+`>; rel="canonical"`)
+		}
+		rw.WriteHeader(http.StatusNotFound)
+	}))
+	defer cacheServer.Close()
+	cacheURL, err := url.Parse(cacheServer.URL)
+	require.NoError(t, err)
+	ref, err := ParseReference("//" + cacheURL.Host + "/repo:latest")
+	require.NoError(t, err)
+	dr, ok := ref.(dockerReference)
+	require.True(t, ok)
+
+	getBlob := func(sys *types.SystemContext, blobDigest digest.Digest, cache types.BlobInfoCache, origin *private.ContentOrigin) ([]byte, error) {
+		client, err := newDockerClient(sys, cacheURL.Host, cacheURL.Host)
+		require.NoError(t, err)
+		defer client.Close()
+		r, _, err := client.getBlob(context.Background(), dr, types.BlobInfo{Digest: blobDigest, Size: -1}, cache, origin)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	}
+	registriesConf := filepath.Join(t.TempDir(), "registries.conf")
+	err = os.WriteFile(registriesConf, []byte{}, 0o600)
+	require.NoError(t, err)
+	sys := &types.SystemContext{
+		DockerPerHostCertDirPath:    "/this/does/not/exist",
+		DockerInsecureSkipTLSVerify: types.OptionalBoolTrue,
+		SystemRegistriesConfPath:    registriesConf,
+		SystemRegistriesConfDirPath: "/this/does/not/exist",
+	}
+
+	// Hints are ignored by default.
+	_, err = getBlob(sys, blobDigest, memory.New(), nil)
+	assert.Error(t, err)
+
+	sys.DockerFollowBlobLocationHints = true
+	cache := memory.New()
+	origin := private.ContentOrigin{}
+	data, err := getBlob(sys, blobDigest, cache, &origin)
+	require.NoError(t, err)
+	assert.Equal(t, blob, data)
+	assert.Equal(t, upstreamURL.Host+"/upstream/repo", origin.Endpoint)
+	candidates := cache.CandidateLocations(dr.Transport(), types.BICTransportScope{Opaque: upstreamURL.Host}, blobDigest, false)
+	assert.Equal(t, []types.BICReplacementCandidate{{Digest: blobDigest, Location: types.BICLocationReference{Opaque: upstreamURL.Host + "/upstream/repo"}}}, candidates)
+
+	// Data from the hinted location is verified.
+	_, err = getBlob(sys, corruptDigest, memory.New(), nil)
+	assert.Error(t, err)
+}
@@ -30,6 +30,7 @@ type bodyReader struct {
 	ctx                 context.Context
 	c                   *dockerClient
 	path                string   // path to pass to makeRequest to retry
+	redirectURL         *url.URL // URL on a different host the registry redirected the original request to, or nil
 	logURL              *url.URL // a string to use in error messages
 	firstConnectionTime time.Time
 
@@ -41,9 +42,10 @@ type bodyReader struct {
 }
 
 // newBodyReader creates a bodyReader for request path in c.
+// redirectURL, if not nil, is the URL on a different host which the registry has redirected the request to.
 // firstBody is an already correctly opened body for the blob, returning the full blob from the start.
 // If reading from firstBody fails, bodyReader may heuristically decide to resume.
-func newBodyReader(ctx context.Context, c *dockerClient, path string, firstBody io.ReadCloser) (io.ReadCloser, error) {
+func newBodyReader(ctx context.Context, c *dockerClient, path string, redirectURL *url.URL, firstBody io.ReadCloser) (io.ReadCloser, error) {
 	logURL, err := c.resolveRequestURL(path)
 	if err != nil {
 		return nil, err
@@ -52,6 +54,7 @@ func newBodyReader(ctx context.Context, c *dockerClient, path string, firstBody
 		ctx:                 ctx,
 		c:                   c,
 		path:                path,
+		redirectURL:         redirectURL,
 		logURL:              logURL,
 		firstConnectionTime: time.Now(),
 
@@ -160,10 +163,7 @@ func (br *bodyReader) Read(p []byte) (int, error) {
 		br.body = nil
 		time.Sleep(1*time.Second + rand.N(100_000*time.Microsecond)) // Some jitter so that a failure blip doesn’t cause a deterministic stampede
 
-		headers := map[string][]string{
-			"Range": {fmt.Sprintf("bytes=%d-", br.offset)},
-		}
-		res, err := br.c.makeRequest(br.ctx, http.MethodGet, br.path, headers, nil, v2Auth, nil)
+		res, err := br.reconnect()
 		if err != nil {
 			return n, fmt.Errorf("%w (while reconnecting: %v)", originalErr, err)
 		}
@@ -190,6 +190,9 @@ func (br *bodyReader) Read(p []byte) (int, error) {
 			return n, fmt.Errorf("%w (after reconnecting, server did not process a Range: header, status %d)", originalErr, http.StatusOK)
 		default:
 			err := registryHTTPResponseToError(res)
+			if redirectURL := br.c.redirectedURL(res); redirectURL != nil {
+				err = BlobRedirectError{Host: redirectURL.Host, StatusCode: res.StatusCode, Err: err}
+			}
 			return n, fmt.Errorf("%w (after reconnecting, fetching blob: %v)", originalErr, err)
 		}
 
@@ -206,6 +209,36 @@ func (br *bodyReader) Read(p []byte) (int, error) {
 	}
 }
 
+// reconnect opens a new connection to read the blob starting at br.offset.
+// If the registry has redirected the original request, it first tries the redirect target directly,
+// and falls back to asking the registry (and possibly getting a new redirect) if that fails.
+func (br *bodyReader) reconnect() (*http.Response, error) {
+	headers := map[string][]string{
+		"Range": {fmt.Sprintf("bytes=%d-", br.offset)},
+	}
+	if br.redirectURL != nil {
+		// The redirect target is not the registry; never send it our registry credentials.
+		res, err := br.c.makeRequestToResolvedURL(br.ctx, http.MethodGet, br.redirectURL, headers, nil, -1, noAuth, nil)
+		if err == nil && res.StatusCode == http.StatusPartialContent {
+			return res, nil
+		}
+		if err != nil {
+			logrus.Debugf("Reconnecting to redirect target %s failed: %v, retrying via the registry", br.redirectURL.Host, err)
+		} else {
+			logrus.Debugf("Reconnecting to redirect target %s failed with status %d, retrying via the registry", br.redirectURL.Host, res.StatusCode)
+			res.Body.Close()
+		}
+	}
+	res, err := br.c.makeRequest(br.ctx, http.MethodGet, br.path, headers, nil, v2Auth, nil)
+	if err != nil {
+		return nil, err
+	}
+	if redirectURL := br.c.redirectedURL(res); redirectURL != nil {
+		br.redirectURL = redirectURL
+	}
+	return res, nil
+}
+
 // millisecondsSinceOptional is like currentTime.Sub(tm).Milliseconds, but it returns a floating-point value.
 // If tm is time.Time{}, it returns math.NaN()
 func millisecondsSinceOptional(currentTime time.Time, tm time.Time) float64 {
@@ -1,7 +1,11 @@
 package docker
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/internal/blobinfocache"
 	"github.com/containers/image/v5/types"
 )
 
@@ -17,7 +21,34 @@ func newBICLocationReference(ref dockerReference) types.BICLocationReference {
 	return types.BICLocationReference{Opaque: ref.ref.Name()}
 }
 
-// parseBICLocationReference returns a repository for encoded lr.
-func parseBICLocationReference(lr types.BICLocationReference) (reference.Named, error) {
-	return reference.ParseNormalizedNamed(lr.Opaque)
+// parseBICLocationReference returns a repository for encoded lr, recorded within scope.
+// It fails if lr does not refer to a repository within the registry identified by scope, so that a cache entry
+// (possibly recorded based on data from a misconfigured or malicious registry) can’t redirect us to a different registry.
+func parseBICLocationReference(scope types.BICTransportScope, lr types.BICLocationReference) (reference.Named, error) {
+	repo, err := reference.ParseNormalizedNamed(lr.Opaque)
+	if err != nil {
+		return nil, err
+	}
+	if domain := reference.Domain(repo); domain != scope.Opaque {
+		return nil, fmt.Errorf("location %q is not within registry %q", lr.Opaque, scope.Opaque)
+	}
+	return repo, nil
+}
+
+// PurgeBlobInfoCacheRegistry removes all known blob locations within registry (as used in image references, e.g. "quay.io"),
+// recorded by the docker:// transport, from cache.
+func PurgeBlobInfoCacheRegistry(cache types.BlobInfoCache, registry string) error {
+	if registry == "" || strings.Contains(registry, "/") {
+		return fmt.Errorf("invalid registry %q", registry)
+	}
+	named, err := reference.ParseNormalizedNamed(registry + "/image")
+	if err != nil {
+		return fmt.Errorf("invalid registry %q: %w", registry, err)
+	}
+	domain := reference.Domain(named) // Normalizes e.g. index.docker.io
+	if domain == dockerHostname && !strings.Contains(registry, ".") {
+		// registry was not recognized as a host name, and was treated as a namespace on Docker Hub.
+		return fmt.Errorf("invalid registry %q", registry)
+	}
+	return blobinfocache.FromBlobInfoCache(cache).PurgeScope(Transport, types.BICTransportScope{Opaque: domain})
 }
@@ -0,0 +1,78 @@
+package docker
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/containers/image/v5/pkg/blobinfocache/memory"
+	"github.com/containers/image/v5/pkg/blobinfocache/none"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBICLocationReference(t *testing.T) {
+	for _, c := range []struct{ scope, location, expected string }{
+		{"quay.io", "quay.io/ns/repo", "quay.io/ns/repo"},
+		{"docker.io", "docker.io/library/busybox", "docker.io/library/busybox"},
+		{"docker.io", "busybox", "docker.io/library/busybox"},
+		{"localhost:5000", "localhost:5000/repo", "localhost:5000/repo"},
+		{"quay.io", "registry.example.com/ns/repo", ""}, // A different registry
+		{"quay.io", "busybox", ""},                      // Normalized to docker.io
+		{"quay.io", "quay.io.example.com/repo", ""},
+		{"quay.io", "", ""},
+		{"quay.io", "quay.io/UPPERCASE", ""},
+	} {
+		res, err := parseBICLocationReference(types.BICTransportScope{Opaque: c.scope}, types.BICLocationReference{Opaque: c.location})
+		if c.expected == "" {
+			assert.Error(t, err, c.location)
+		} else {
+			require.NoError(t, err, c.location)
+			assert.Equal(t, c.expected, res.String(), c.location)
+		}
+	}
+}
+
+func TestPurgeBlobInfoCacheRegistry(t *testing.T) {
+	const blobDigest = digest.Digest("sha256:1111111111111111111111111111111111111111111111111111111111111111")
+	for _, c := range []struct {
+		registry string
+		purged   []string // Names of registries in the cache which are expected to be purged
+	}{
+		{"quay.io", []string{"quay.io"}},
+		{"docker.io", []string{"docker.io"}},
+		{"index.docker.io", []string{"docker.io"}},
+		{"localhost:5000", []string{"localhost:5000"}},
+		{"localhost", []string{}},
+		{"registry.example.com", []string{}},
+	} {
+		cache := memory.New()
+		for _, registry := range []string{"quay.io", "docker.io", "localhost:5000"} {
+			ref, err := ParseReference("//" + registry + "/ns/repo:latest")
+			require.NoError(t, err)
+			dr := ref.(dockerReference)
+			cache.RecordKnownLocation(Transport, bicTransportScope(dr), blobDigest, newBICLocationReference(dr))
+		}
+
+		err := PurgeBlobInfoCacheRegistry(cache, c.registry)
+		require.NoError(t, err, c.registry)
+		for _, registry := range []string{"quay.io", "docker.io", "localhost:5000"} {
+			candidates := cache.CandidateLocations(Transport, types.BICTransportScope{Opaque: registry}, blobDigest, false)
+			if slices.Contains(c.purged, registry) {
+				assert.Empty(t, candidates, "%s %s", c.registry, registry)
+			} else {
+				assert.Len(t, candidates, 1, "%s %s", c.registry, registry)
+			}
+		}
+	}
+
+	for _, registry := range []string{"", "quay.io/ns", "notaregistry", "quay.io:invalid"} {
+		err := PurgeBlobInfoCacheRegistry(memory.New(), registry)
+		assert.Error(t, err, registry)
+	}
+
+	// The "none" cache has nothing to purge.
+	err := PurgeBlobInfoCacheRegistry(none.NoCache, "quay.io")
+	assert.NoError(t, err)
+}
@@ -2,8 +2,10 @@ package daemon
 
 import (
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/containers/image/v5/types"
@@ -11,6 +13,29 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+// fakeEngine returns a server implementing the docker engine API version apiVersion, which responds to requests using handlers,
+// keyed by the request path without the API version prefix; requests for other paths fail with 404.
+func fakeEngine(t *testing.T, apiVersion string, handlers map[string]http.HandlerFunc) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		if i := strings.Index(path[1:], "/"); strings.HasPrefix(path, "/v") && i != -1 {
+			path = path[i+1:] // Drop the API version
+		}
+		if path == "/_ping" {
+			w.Header().Set("Api-Version", apiVersion)
+			return
+		}
+		handler, ok := handlers[path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
 func TestDockerClientFromNilSystemContext(t *testing.T) {
 	client, err := newDockerClient(nil)
 
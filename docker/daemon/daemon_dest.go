@@ -6,12 +6,17 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sync"
 
 	"github.com/containers/image/v5/docker/internal/tarfile"
 	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/internal/archiveprogress"
 	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/internal/set"
 	"github.com/containers/image/v5/types"
+	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/client"
+	"github.com/opencontainers/go-digest"
 	"github.com/sirupsen/logrus"
 )
 
@@ -25,7 +30,12 @@ type daemonImageDestination struct {
 	statusChannel   <-chan error
 	writer          *io.PipeWriter
 	// Other state
-	committed bool // writer has been closed
+	committed bool                      // writer has been closed
+	progress  *archiveprogress.Reporter // nil if progress of writing the archive should not be reported
+	// For omitting layers which already exist in the engine
+	client               *client.Client
+	existingChainIDsOnce sync.Once
+	existingChainIDs     *set.Set[digest.Digest] // nil if layers can not be omitted
 }
 
 // newImageDestination returns a types.ImageDestination for the specified image reference.
@@ -43,18 +53,38 @@ func newImageDestination(ctx context.Context, sys *types.SystemContext, ref daem
 		mustMatchRuntimeOS = false
 	}
 
+	options, err := tarfile.WriterOptionsFromSystemContext(sys)
+	if err != nil {
+		return nil, err
+	}
+	options.Deterministic = false // The archive is consumed immediately, there is no point in holding back its contents.
+
 	c, err := newDockerClient(sys)
 	if err != nil {
 		return nil, fmt.Errorf("initializing docker engine client: %w", err)
 	}
+	if err := configureOCIArchive(ctx, sys, c, &options); err != nil {
+		c.Close()
+		return nil, err
+	}
 
 	reader, writer := io.Pipe()
-	archive := tarfile.NewWriter(writer)
+	progress := archiveprogress.NewPacking(sys)
+	if progress != nil {
+		options.Progress = func(p tarfile.WriterProgress) {
+			progress.SetEntry(p.Path, uint64(p.EntryOffset), uint64(p.EntrySize))
+		}
+	}
+	archive, err := tarfile.NewWriterWithOptions(progress.Writer(writer), options)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
 	// Commit() may never be called, so we may never read from this channel; so, make this buffered to allow imageLoadGoroutine to write status and terminate even if we never read it.
 	statusChannel := make(chan error, 1)
 
 	goroutineContext, goroutineCancel := context.WithCancel(ctx)
-	go imageLoadGoroutine(goroutineContext, c, reader, statusChannel)
+	go imageLoadGoroutine(goroutineContext, c, reader, newLoadProgressReporter(sys), statusChannel)
 
 	d := &daemonImageDestination{
 		ref:                ref,
@@ -64,13 +94,78 @@ func newImageDestination(ctx context.Context, sys *types.SystemContext, ref daem
 		statusChannel:      statusChannel,
 		writer:             writer,
 		committed:          false,
+		progress:           progress,
+		client:             c,
 	}
 	d.Destination = tarfile.NewDestination(sys, archive, ref.Transport().Name(), namedTaggedRef, d.CommitWithOptions)
+	// Layers can only be omitted if the engine reads manifest.json, which refers to layers it already has by DiffID.
+	if options.Format != tarfile.FormatOCILayout && options.LegacyMetadata != tarfile.LegacyMetadataOnly {
+		d.Destination.SkipExistingLayers(d.layerExists)
+	}
 	return d, nil
 }
 
+// layerExists returns true if the engine already contains a layer with chainID, so that it does not need to be sent.
+func (d *daemonImageDestination) layerExists(ctx context.Context, chainID digest.Digest) (bool, error) {
+	d.existingChainIDsOnce.Do(func() {
+		chainIDs, err := existingChainIDs(ctx, d.client)
+		if err != nil {
+			// This is only an optimization; just send all layers.
+			logrus.Debugf("docker-daemon: can not determine existing layers, sending all layers: %v", err)
+			return
+		}
+		d.existingChainIDs = chainIDs
+	})
+	return d.existingChainIDs != nil && d.existingChainIDs.Contains(chainID), nil
+}
+
+// existingChainIDs returns chain IDs of layers in the engine which can be used by a loaded image instead of layers in the archive,
+// or nil if the engine requires all layers to be present in the archive.
+func existingChainIDs(ctx context.Context, c *client.Client) (*set.Set[digest.Digest], error) {
+	info, err := c.Info(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("querying docker engine information: %w", err)
+	}
+	for _, status := range info.DriverStatus {
+		if status[0] == "driver-type" && status[1] == "io.containerd.snapshotter.v1" {
+			// The containerd image store imports the archive using containerd, which requires all blobs to be present.
+			logrus.Debugf("docker-daemon: engine uses the containerd image store, sending all layers")
+			return nil, nil
+		}
+	}
+
+	images, err := c.ImageList(ctx, image.ListOptions{All: true})
+	if err != nil {
+		return nil, fmt.Errorf("listing images in docker engine: %w", err)
+	}
+	res := set.New[digest.Digest]()
+	for _, img := range images {
+		inspect, err := c.ImageInspect(ctx, img.ID)
+		if err != nil {
+			// The image may have been removed in the meantime.
+			logrus.Debugf("docker-daemon: inspecting image %s: %v", img.ID, err)
+			continue
+		}
+		if inspect.RootFS.Type != "layers" {
+			continue
+		}
+		chainID := digest.Digest("")
+		for _, layer := range inspect.RootFS.Layers {
+			diffID, err := digest.Parse(layer)
+			if err != nil {
+				logrus.Debugf("docker-daemon: invalid layer %q in image %s: %v", layer, img.ID, err)
+				break
+			}
+			chainID = tarfile.ChainID(chainID, diffID)
+			res.Add(chainID)
+		}
+	}
+	return res, nil
+}
+
 // imageLoadGoroutine accepts tar stream on reader, sends it to c, and reports error or success by writing to statusChannel
-func imageLoadGoroutine(ctx context.Context, c *client.Client, reader *io.PipeReader, statusChannel chan<- error) {
+// progress of loading the image is reported to progress.
+func imageLoadGoroutine(ctx context.Context, c *client.Client, reader *io.PipeReader, progress *loadProgressReporter, statusChannel chan<- error) {
 	defer c.Close()
 	err := errors.New("Internal error: unexpected panic in imageLoadGoroutine")
 	defer func() {
@@ -87,12 +182,13 @@ func imageLoadGoroutine(ctx context.Context, c *client.Client, reader *io.PipeRe
 		}
 	}()
 
-	err = imageLoad(ctx, c, reader)
+	err = imageLoad(ctx, c, reader, progress)
 }
 
-// imageLoad accepts tar stream on reader and sends it to c
-func imageLoad(ctx context.Context, c *client.Client, reader *io.PipeReader) error {
-	resp, err := c.ImageLoad(ctx, reader, client.ImageLoadWithQuiet(true))
+// imageLoad accepts tar stream on reader and sends it to c, reporting the engine’s progress to progress.
+func imageLoad(ctx context.Context, c *client.Client, reader *io.PipeReader, progress *loadProgressReporter) error {
+	// The engine only reports progress of loading the image if not quiet.
+	resp, err := c.ImageLoad(ctx, reader, client.ImageLoadWithQuiet(progress == nil))
 	if err != nil {
 		return fmt.Errorf("starting a load operation in docker engine: %w", err)
 	}
@@ -104,6 +200,7 @@ func imageLoad(ctx context.Context, c *client.Client, reader *io.PipeReader) err
 		Message string `json:"message,omitempty"`
 	}
 	type jsonMessage struct {
+		loadProgressMessage
 		Error *jsonError `json:"errorDetail,omitempty"`
 	}
 
@@ -119,7 +216,9 @@ func imageLoad(ctx context.Context, c *client.Client, reader *io.PipeReader) err
 		if msg.Error != nil {
 			return fmt.Errorf("docker engine reported: %q", msg.Error.Message)
 		}
+		progress.message(ctx, msg.loadProgressMessage)
 	}
+	progress.done(ctx)
 	return nil // No error reported = success
 }
 
@@ -166,9 +265,10 @@ func (d *daemonImageDestination) Reference() types.ImageReference {
 // - Uploaded data MAY be removed or MAY remain around if Close() is called without CommitWithOptions() (i.e. rollback is allowed but not guaranteed)
 func (d *daemonImageDestination) CommitWithOptions(ctx context.Context, options private.CommitOptions) error {
 	logrus.Debugf("docker-daemon: Closing tar stream")
-	if err := d.archive.Close(); err != nil {
+	if err := d.archive.CloseWithContext(ctx); err != nil {
 		return err
 	}
+	d.progress.Done()
 	if err := d.writer.Close(); err != nil {
 		return err
 	}
@@ -1,5 +1,91 @@
 package daemon
 
-import "github.com/containers/image/v5/internal/private"
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/containers/image/v5/docker/internal/tarfile"
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
 
 var _ private.ImageDestination = (*daemonImageDestination)(nil)
+
+// chainIDsTestEngine returns a server implementing the parts of the docker engine API used by existingChainIDs.
+func chainIDsTestEngine(t *testing.T, driverStatus [][2]string, images map[string][]string) *httptest.Server {
+	writeJSON := func(w http.ResponseWriter, res any) {
+		err := json.NewEncoder(w).Encode(res)
+		require.NoError(t, err)
+	}
+	handlers := map[string]http.HandlerFunc{
+		"/info": func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, map[string]any{"DriverStatus": driverStatus})
+		},
+		"/images/json": func(w http.ResponseWriter, r *http.Request) {
+			list := []map[string]any{}
+			for id := range images {
+				list = append(list, map[string]any{"Id": id})
+			}
+			writeJSON(w, list)
+		},
+	}
+	for id, layers := range images {
+		handlers["/images/"+id+"/json"] = func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, map[string]any{"Id": id, "RootFS": map[string]any{"Type": "layers", "Layers": layers}})
+		}
+	}
+	return fakeEngine(t, "1.41", handlers)
+}
+
+func TestExistingChainIDs(t *testing.T) {
+	layer1 := digest.FromString("layer 1")
+	layer2 := digest.FromString("layer 2")
+	layer3 := digest.FromString("layer 3")
+	images := map[string][]string{
+		"sha256:1111111111111111111111111111111111111111111111111111111111111111": {layer1.String(), layer2.String()},
+		"sha256:2222222222222222222222222222222222222222222222222222222222222222": {layer3.String()},
+	}
+
+	server := chainIDsTestEngine(t, [][2]string{{"Backing Filesystem", "extfs"}}, images)
+	c, err := newDockerClient(&types.SystemContext{DockerDaemonHost: server.URL})
+	require.NoError(t, err)
+	defer c.Close()
+	res, err := existingChainIDs(context.Background(), c)
+	require.NoError(t, err)
+	require.NotNil(t, res)
+	assert.ElementsMatch(t, []digest.Digest{
+		tarfile.ChainID("", layer1),
+		tarfile.ChainID(tarfile.ChainID("", layer1), layer2),
+		tarfile.ChainID("", layer3),
+	}, slices.Collect(res.All()))
+
+	// The containerd image store
+	server = chainIDsTestEngine(t, [][2]string{{"driver-type", "io.containerd.snapshotter.v1"}}, images)
+	c2, err := newDockerClient(&types.SystemContext{DockerDaemonHost: server.URL})
+	require.NoError(t, err)
+	defer c2.Close()
+	res, err = existingChainIDs(context.Background(), c2)
+	require.NoError(t, err)
+	assert.Nil(t, res)
+}
+
+func TestNewImageDestinationArchiveOptions(t *testing.T) {
+	ref, err := ParseReference("busybox:latest")
+	require.NoError(t, err)
+	dr, ok := ref.(daemonReference)
+	require.True(t, ok)
+	// Invalid archive options are rejected before contacting the engine.
+	_, err = newImageDestination(context.Background(), &types.SystemContext{
+		DockerDaemonHost:            "unix:///this/does/not/exist",
+		DockerArchiveFormat:         types.DockerArchiveFormatOCILayout,
+		DockerArchiveLegacyMetadata: types.DockerArchiveLegacyMetadataOnly,
+	}, dr)
+	assert.ErrorContains(t, err, "legacy metadata")
+}
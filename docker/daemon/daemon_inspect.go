@@ -0,0 +1,166 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/types"
+	"github.com/docker/docker/api/types/image"
+	"github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Inspect returns information about the image at ref, using the docker engine’s inspect API,
+// without exporting the image from the engine (as ref.NewImage() does).
+//
+// The returned value is consistent with types.Image.Inspect for the same image, except that layer sizes are not known.
+func Inspect(ctx context.Context, sys *types.SystemContext, ref types.ImageReference) (*types.ImageInspectInfo, error) {
+	inspect, err := inspectImage(ctx, sys, ref)
+	if err != nil {
+		return nil, err
+	}
+	return inspectInfoFromEngine(inspect)
+}
+
+// ImageConfig returns the configuration of the image at ref, e.g. its labels and exposed ports, using the docker engine’s
+// inspect API, without exporting the image from the engine (as ref.NewImage() does).
+//
+// The result is reconstructed from the data reported by the engine; it does not include the image history,
+// and it is not byte-for-byte identical to the config blob of the image. Use types.Image.ConfigBlob if that matters.
+func ImageConfig(ctx context.Context, sys *types.SystemContext, ref types.ImageReference) (*imgspecv1.Image, error) {
+	inspect, err := inspectImage(ctx, sys, ref)
+	if err != nil {
+		return nil, err
+	}
+	return configFromEngine(inspect)
+}
+
+// inspectImage returns the engine’s inspect data for ref.
+func inspectImage(ctx context.Context, sys *types.SystemContext, ref types.ImageReference) (image.InspectResponse, error) {
+	dr, ok := ref.(daemonReference)
+	if !ok {
+		return image.InspectResponse{}, fmt.Errorf("%s is not a docker-daemon: reference", ref.StringWithinTransport())
+	}
+
+	c, err := newDockerClient(sys)
+	if err != nil {
+		return image.InspectResponse{}, fmt.Errorf("initializing docker engine client: %w", err)
+	}
+	defer c.Close()
+
+	inspect, err := c.ImageInspect(ctx, dr.StringWithinTransport())
+	if err != nil {
+		return image.InspectResponse{}, fmt.Errorf("inspecting image in docker engine: %w", err)
+	}
+	return inspect, nil
+}
+
+// createdFromEngine parses the Created field of inspect, returning nil if it is not set.
+func createdFromEngine(inspect image.InspectResponse) (*time.Time, error) {
+	if inspect.Created == "" {
+		return nil, nil
+	}
+	created, err := time.Parse(time.RFC3339Nano, inspect.Created)
+	if err != nil {
+		return nil, fmt.Errorf("parsing image creation time %q: %w", inspect.Created, err)
+	}
+	return &created, nil
+}
+
+// diffIDsFromEngine returns the layer DiffIDs of inspect.
+func diffIDsFromEngine(inspect image.InspectResponse) ([]digest.Digest, error) {
+	res := make([]digest.Digest, 0, len(inspect.RootFS.Layers))
+	for _, l := range inspect.RootFS.Layers {
+		d, err := digest.Parse(l)
+		if err != nil {
+			return nil, fmt.Errorf("invalid layer DiffID %q: %w", l, err)
+		}
+		res = append(res, d)
+	}
+	return res, nil
+}
+
+// inspectInfoFromEngine converts the engine’s inspect data to types.ImageInspectInfo.
+func inspectInfoFromEngine(inspect image.InspectResponse) (*types.ImageInspectInfo, error) {
+	created, err := createdFromEngine(inspect)
+	if err != nil {
+		return nil, err
+	}
+	diffIDs, err := diffIDsFromEngine(inspect)
+	if err != nil {
+		return nil, err
+	}
+	res := &types.ImageInspectInfo{
+		Tag:           "",
+		Created:       created,
+		DockerVersion: inspect.DockerVersion,
+		Architecture:  inspect.Architecture,
+		Variant:       inspect.Variant,
+		Os:            inspect.Os,
+		Layers:        []string{},
+		LayersData:    []types.ImageInspectLayer{},
+		Author:        inspect.Author,
+	}
+	// docker-daemon: sources use the DiffIDs as layer digests, see tarfile.Source.
+	for _, d := range diffIDs {
+		res.Layers = append(res.Layers, d.String())
+		res.LayersData = append(res.LayersData, types.ImageInspectLayer{
+			MIMEType: manifest.DockerV2Schema2LayerMediaType,
+			Digest:   d,
+			Size:     -1,
+		})
+	}
+	if inspect.Config != nil {
+		res.Labels = inspect.Config.Labels
+		res.Env = inspect.Config.Env
+	}
+	return res, nil
+}
+
+// configFromEngine converts the engine’s inspect data to an OCI image configuration.
+func configFromEngine(inspect image.InspectResponse) (*imgspecv1.Image, error) {
+	created, err := createdFromEngine(inspect)
+	if err != nil {
+		return nil, err
+	}
+	diffIDs, err := diffIDsFromEngine(inspect)
+	if err != nil {
+		return nil, err
+	}
+	res := &imgspecv1.Image{
+		Created: created,
+		Author:  inspect.Author,
+		Platform: imgspecv1.Platform{
+			Architecture: inspect.Architecture,
+			OS:           inspect.Os,
+			OSVersion:    inspect.OsVersion,
+			Variant:      inspect.Variant,
+		},
+		RootFS: imgspecv1.RootFS{
+			Type:    "layers",
+			DiffIDs: diffIDs,
+		},
+	}
+	if c := inspect.Config; c != nil {
+		res.Config = imgspecv1.ImageConfig{
+			User:        c.User,
+			Env:         c.Env,
+			Entrypoint:  c.Entrypoint,
+			Cmd:         c.Cmd,
+			Volumes:     c.Volumes,
+			WorkingDir:  c.WorkingDir,
+			Labels:      c.Labels,
+			StopSignal:  c.StopSignal,
+			ArgsEscaped: c.ArgsEscaped, //nolint:staticcheck // ArgsEscaped is deprecated, but we want to preserve the value reported by the engine.
+		}
+		if len(c.ExposedPorts) != 0 {
+			res.Config.ExposedPorts = make(map[string]struct{}, len(c.ExposedPorts))
+			for port := range c.ExposedPorts {
+				res.Config.ExposedPorts[string(port)] = struct{}{}
+			}
+		}
+	}
+	return res, nil
+}
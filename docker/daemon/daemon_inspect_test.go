@@ -0,0 +1,153 @@
+package daemon
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/containers/image/v5/directory"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const inspectTestResponse = `{
+	"Id": "sha256:1111111111111111111111111111111111111111111111111111111111111111",
+	"RepoTags": ["busybox:latest"],
+	"Created": "2024-05-01T12:34:56.789Z",
+	"DockerVersion": "26.1.0",
+	"Author": "Example Author",
+	"Config": {
+		"User": "1000",
+		"ExposedPorts": {"80/tcp": {}, "53/udp": {}},
+		"Env": ["PATH=/usr/bin"],
+		"Cmd": ["sh"],
+		"WorkingDir": "/srv",
+		"Labels": {"com.example.label": "value"}
+	},
+	"Architecture": "arm64",
+	"Variant": "v8",
+	"Os": "linux",
+	"Size": 4096,
+	"RootFS": {
+		"Type": "layers",
+		"Layers": [
+			"sha256:2222222222222222222222222222222222222222222222222222222222222222",
+			"sha256:3333333333333333333333333333333333333333333333333333333333333333"
+		]
+	}
+}`
+
+// inspectTestEngine returns a server implementing the image inspect API of the docker engine, counting requests to export images.
+func inspectTestEngine(t *testing.T, name, response string, exports *int) *httptest.Server {
+	return fakeEngine(t, "1.41", map[string]http.HandlerFunc{
+		"/images/" + name + "/json": func(w http.ResponseWriter, r *http.Request) {
+			_, err := w.Write([]byte(response))
+			require.NoError(t, err)
+		},
+		"/images/get": func(w http.ResponseWriter, r *http.Request) {
+			*exports++
+			http.Error(w, "exporting is not expected", http.StatusInternalServerError)
+		},
+	})
+}
+
+func TestInspect(t *testing.T) {
+	exports := 0
+	server := inspectTestEngine(t, "busybox:latest", inspectTestResponse, &exports)
+	sys := &types.SystemContext{DockerDaemonHost: server.URL}
+	ref, err := ParseReference("busybox:latest")
+	require.NoError(t, err)
+
+	created := time.Date(2024, 5, 1, 12, 34, 56, 789000000, time.UTC)
+	diffIDs := []digest.Digest{
+		"sha256:2222222222222222222222222222222222222222222222222222222222222222",
+		"sha256:3333333333333333333333333333333333333333333333333333333333333333",
+	}
+
+	info, err := Inspect(context.Background(), sys, ref)
+	require.NoError(t, err)
+	assert.Equal(t, &types.ImageInspectInfo{
+		Created:       &created,
+		DockerVersion: "26.1.0",
+		Labels:        map[string]string{"com.example.label": "value"},
+		Architecture:  "arm64",
+		Variant:       "v8",
+		Os:            "linux",
+		Layers:        []string{diffIDs[0].String(), diffIDs[1].String()},
+		LayersData: []types.ImageInspectLayer{
+			{MIMEType: manifest.DockerV2Schema2LayerMediaType, Digest: diffIDs[0], Size: -1},
+			{MIMEType: manifest.DockerV2Schema2LayerMediaType, Digest: diffIDs[1], Size: -1},
+		},
+		Env:    []string{"PATH=/usr/bin"},
+		Author: "Example Author",
+	}, info)
+
+	config, err := ImageConfig(context.Background(), sys, ref)
+	require.NoError(t, err)
+	assert.Equal(t, &imgspecv1.Image{
+		Created: &created,
+		Author:  "Example Author",
+		Platform: imgspecv1.Platform{
+			Architecture: "arm64",
+			OS:           "linux",
+			Variant:      "v8",
+		},
+		Config: imgspecv1.ImageConfig{
+			User:         "1000",
+			ExposedPorts: map[string]struct{}{"80/tcp": {}, "53/udp": {}},
+			Env:          []string{"PATH=/usr/bin"},
+			Cmd:          []string{"sh"},
+			WorkingDir:   "/srv",
+			Labels:       map[string]string{"com.example.label": "value"},
+		},
+		RootFS: imgspecv1.RootFS{Type: "layers", DiffIDs: diffIDs},
+	}, config)
+
+	assert.Equal(t, 0, exports)
+
+	// Missing optional data
+	server = inspectTestEngine(t, "busybox:latest", `{"Id":"sha256:1111111111111111111111111111111111111111111111111111111111111111","Os":"linux"}`, &exports)
+	sys = &types.SystemContext{DockerDaemonHost: server.URL}
+	info, err = Inspect(context.Background(), sys, ref)
+	require.NoError(t, err)
+	assert.Nil(t, info.Created)
+	assert.Equal(t, []string{}, info.Layers)
+	assert.Nil(t, info.Labels)
+	config, err = ImageConfig(context.Background(), sys, ref)
+	require.NoError(t, err)
+	assert.Equal(t, imgspecv1.ImageConfig{}, config.Config)
+	assert.Equal(t, []digest.Digest{}, config.RootFS.DiffIDs)
+
+	// Invalid data
+	for _, response := range []string{
+		`{"Created":"this is invalid"}`,
+		`{"RootFS":{"Type":"layers","Layers":["this is invalid"]}}`,
+	} {
+		server = inspectTestEngine(t, "busybox:latest", response, &exports)
+		sys = &types.SystemContext{DockerDaemonHost: server.URL}
+		_, err = Inspect(context.Background(), sys, ref)
+		assert.Error(t, err, response)
+		_, err = ImageConfig(context.Background(), sys, ref)
+		assert.Error(t, err, response)
+	}
+
+	// An image not known to the engine
+	ref, err = ParseReference("unknown:latest")
+	require.NoError(t, err)
+	_, err = Inspect(context.Background(), sys, ref)
+	assert.Error(t, err)
+
+	// A reference for a different transport
+	dirRef, err := directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+	_, err = Inspect(context.Background(), sys, dirRef)
+	assert.Error(t, err)
+	_, err = ImageConfig(context.Background(), sys, dirRef)
+	assert.Error(t, err)
+}
@@ -0,0 +1,102 @@
+package daemon
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/containers/image/v5/types"
+)
+
+// loadProgressReporter reports progress of loading an image into the docker engine, as requested by
+// types.SystemContext.ArchiveProgress.
+// A nil *loadProgressReporter is valid, and does not report anything.
+type loadProgressReporter struct {
+	channel  chan<- types.ProgressProperties
+	interval time.Duration
+
+	lastUpdate time.Time
+	lastStatus string // The DaemonLoadStatus value of the last report
+	lastID     string // The DaemonLoadID value of the last report
+}
+
+// newLoadProgressReporter returns a loadProgressReporter, or nil if sys does not request reporting progress.
+func newLoadProgressReporter(sys *types.SystemContext) *loadProgressReporter {
+	if sys == nil || sys.ArchiveProgress == nil || sys.ArchiveProgressInterval <= 0 {
+		return nil
+	}
+	return &loadProgressReporter{
+		channel:  sys.ArchiveProgress,
+		interval: sys.ArchiveProgressInterval,
+	}
+}
+
+// loadProgressMessage is a small subset of docker/docker/pkg/jsonmessage.JSONMessage, copied here to minimize dependencies.
+type loadProgressMessage struct {
+	Stream   string               `json:"stream,omitempty"`
+	Status   string               `json:"status,omitempty"`
+	ID       string               `json:"id,omitempty"`
+	Progress *loadProgressDetails `json:"progressDetail,omitempty"`
+}
+
+// loadProgressDetails is a subset of docker/docker/pkg/jsonmessage.JSONProgress.
+type loadProgressDetails struct {
+	Current int64 `json:"current,omitempty"`
+	Total   int64 `json:"total,omitempty"`
+}
+
+// message reports msg received from the engine.
+// Changes of the status or the item it applies to, and completion of an item, are always reported;
+// other progress updates at most once per r.interval.
+// The report is dropped if ctx is canceled before it can be delivered.
+func (r *loadProgressReporter) message(ctx context.Context, msg loadProgressMessage) {
+	if r == nil {
+		return
+	}
+	status := msg.Status
+	if status == "" {
+		status = strings.TrimSpace(msg.Stream) // e.g. "Loaded image: …"
+	}
+	if status == "" {
+		return
+	}
+	var current, total uint64
+	if msg.Progress != nil {
+		current = uint64(max(msg.Progress.Current, 0))
+		total = uint64(max(msg.Progress.Total, 0))
+	}
+	changed := status != r.lastStatus || msg.ID != r.lastID
+	completed := total != 0 && current >= total
+	if !changed && !completed && time.Since(r.lastUpdate) <= r.interval {
+		return
+	}
+	r.send(ctx, types.ProgressProperties{
+		Event:             types.ProgressEventDaemonLoading,
+		DaemonLoadStatus:  status,
+		DaemonLoadID:      msg.ID,
+		DaemonLoadCurrent: current,
+		DaemonLoadTotal:   total,
+	})
+	r.lastUpdate = time.Now()
+	r.lastStatus = status
+	r.lastID = msg.ID
+}
+
+// done reports that the engine has successfully loaded the image.
+func (r *loadProgressReporter) done(ctx context.Context) {
+	if r == nil {
+		return
+	}
+	r.send(ctx, types.ProgressProperties{
+		Event: types.ProgressEventDaemonLoaded,
+	})
+}
+
+// send sends p to r.channel, unless ctx is canceled first.
+// (imageLoadGoroutine may still be running after the copy was aborted, and the caller may no longer be reading the channel.)
+func (r *loadProgressReporter) send(ctx context.Context, p types.ProgressProperties) {
+	select {
+	case r.channel <- p:
+	case <-ctx.Done():
+	}
+}
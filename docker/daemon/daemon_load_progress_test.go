@@ -0,0 +1,153 @@
+package daemon
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/containers/image/v5/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLoadProgressReporter(t *testing.T) {
+	channel := make(chan types.ProgressProperties)
+	for _, sys := range []*types.SystemContext{
+		nil,
+		{},
+		{ArchiveProgress: channel},
+		{ArchiveProgressInterval: time.Second},
+	} {
+		assert.Nil(t, newLoadProgressReporter(sys))
+	}
+	assert.NotNil(t, newLoadProgressReporter(&types.SystemContext{ArchiveProgress: channel, ArchiveProgressInterval: time.Second}))
+
+	// A nil reporter does nothing
+	var r *loadProgressReporter
+	r.message(context.Background(), loadProgressMessage{Status: "Loading layer"})
+	r.done(context.Background())
+}
+
+func TestLoadProgressReporterMessage(t *testing.T) {
+	channel := make(chan types.ProgressProperties, 100)
+	r := newLoadProgressReporter(&types.SystemContext{ArchiveProgress: channel, ArchiveProgressInterval: time.Hour})
+	require.NotNil(t, r)
+	for _, msg := range []loadProgressMessage{
+		{Status: "Loading layer", ID: "1111", Progress: &loadProgressDetails{Current: 0, Total: 100}},
+		{Status: "Loading layer", ID: "1111", Progress: &loadProgressDetails{Current: 50, Total: 100}}, // Throttled
+		{Status: "Loading layer", ID: "1111", Progress: &loadProgressDetails{Current: 100, Total: 100}},
+		{Status: "Loading layer", ID: "2222", Progress: &loadProgressDetails{Current: 10, Total: 20}},
+		{Status: "Loading layer", ID: "2222", Progress: &loadProgressDetails{Current: -1, Total: -1}}, // Throttled
+		{},
+		{Stream: "Loaded image: busybox:latest\n"},
+	} {
+		r.message(context.Background(), msg)
+	}
+	r.done(context.Background())
+	close(channel)
+
+	res := []types.ProgressProperties{}
+	for p := range channel {
+		res = append(res, p)
+	}
+	assert.Equal(t, []types.ProgressProperties{
+		{Event: types.ProgressEventDaemonLoading, DaemonLoadStatus: "Loading layer", DaemonLoadID: "1111", DaemonLoadCurrent: 0, DaemonLoadTotal: 100},
+		{Event: types.ProgressEventDaemonLoading, DaemonLoadStatus: "Loading layer", DaemonLoadID: "1111", DaemonLoadCurrent: 100, DaemonLoadTotal: 100},
+		{Event: types.ProgressEventDaemonLoading, DaemonLoadStatus: "Loading layer", DaemonLoadID: "2222", DaemonLoadCurrent: 10, DaemonLoadTotal: 20},
+		{Event: types.ProgressEventDaemonLoading, DaemonLoadStatus: "Loaded image: busybox:latest"},
+		{Event: types.ProgressEventDaemonLoaded},
+	}, res)
+
+	// Reports are dropped if the context is canceled
+	blocking := make(chan types.ProgressProperties)
+	r = newLoadProgressReporter(&types.SystemContext{ArchiveProgress: blocking, ArchiveProgressInterval: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r.message(ctx, loadProgressMessage{Status: "Loading layer"})
+	r.done(ctx)
+}
+
+// loadTestEngine returns a server implementing the image load API of the docker engine, responding with response,
+// and recording the value of the "quiet" parameter.
+func loadTestEngine(t *testing.T, response string, quiet *string) *httptest.Server {
+	return fakeEngine(t, "1.41", map[string]http.HandlerFunc{
+		"/images/load": func(w http.ResponseWriter, r *http.Request) {
+			*quiet = r.URL.Query().Get("quiet")
+			_, err := io.Copy(io.Discard, r.Body)
+			require.NoError(t, err)
+			_, err = w.Write([]byte(response))
+			require.NoError(t, err)
+		},
+	})
+}
+
+func TestImageLoadProgress(t *testing.T) {
+	const response = `{"status":"Loading layer","progressDetail":{"current":512,"total":1024},"id":"1111"}
+{"status":"Loading layer","progressDetail":{"current":1024,"total":1024},"id":"1111"}
+{"stream":"Loaded image: busybox:latest\n"}
+`
+	for _, c := range []struct {
+		reportProgress bool
+		quiet          string
+		events         []types.ProgressProperties
+	}{
+		{false, "1", []types.ProgressProperties{}},
+		{true, "0", []types.ProgressProperties{
+			{Event: types.ProgressEventDaemonLoading, DaemonLoadStatus: "Loading layer", DaemonLoadID: "1111", DaemonLoadCurrent: 512, DaemonLoadTotal: 1024},
+			{Event: types.ProgressEventDaemonLoading, DaemonLoadStatus: "Loading layer", DaemonLoadID: "1111", DaemonLoadCurrent: 1024, DaemonLoadTotal: 1024},
+			{Event: types.ProgressEventDaemonLoading, DaemonLoadStatus: "Loaded image: busybox:latest"},
+			{Event: types.ProgressEventDaemonLoaded},
+		}},
+	} {
+		var quiet string
+		server := loadTestEngine(t, response, &quiet)
+		channel := make(chan types.ProgressProperties, 100)
+		sys := &types.SystemContext{DockerDaemonHost: server.URL}
+		if c.reportProgress {
+			sys.ArchiveProgress = channel
+			sys.ArchiveProgressInterval = time.Hour
+		}
+		client, err := newDockerClient(sys)
+		require.NoError(t, err)
+		reader, writer := io.Pipe()
+		go func() {
+			_, _ = writer.Write([]byte("archive contents"))
+			writer.Close()
+		}()
+		err = imageLoad(context.Background(), client, reader, newLoadProgressReporter(sys))
+		require.NoError(t, err)
+		client.Close()
+		close(channel)
+
+		assert.Equal(t, c.quiet, quiet)
+		events := []types.ProgressProperties{}
+		for p := range channel {
+			events = append(events, p)
+		}
+		assert.Equal(t, c.events, events)
+	}
+
+	// Errors reported by the engine
+	var quiet string
+	server := loadTestEngine(t, `{"status":"Loading layer","id":"1111"}
+{"errorDetail":{"message":"no space left on device"},"error":"no space left on device"}
+`, &quiet)
+	channel := make(chan types.ProgressProperties, 100)
+	sys := &types.SystemContext{DockerDaemonHost: server.URL, ArchiveProgress: channel, ArchiveProgressInterval: time.Hour}
+	client, err := newDockerClient(sys)
+	require.NoError(t, err)
+	defer client.Close()
+	reader, writer := io.Pipe()
+	go func() {
+		writer.Close()
+	}()
+	err = imageLoad(context.Background(), client, reader, newLoadProgressReporter(sys))
+	assert.ErrorContains(t, err, "no space left on device")
+	close(channel)
+	for p := range channel {
+		assert.NotEqual(t, types.ProgressEventDaemonLoaded, p.Event)
+	}
+}
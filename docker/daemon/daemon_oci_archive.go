@@ -0,0 +1,42 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+
+	"github.com/containers/image/v5/docker/internal/tarfile"
+	"github.com/containers/image/v5/types"
+	"github.com/docker/docker/client"
+	"github.com/sirupsen/logrus"
+)
+
+// ociArchiveMinAPIVersion is the first engine API version (Docker 25) which accepts OCI image layouts in (docker load).
+const ociArchiveMinAPIVersion = "1.44"
+
+// configureOCIArchive updates options to send an OCI image layout to c, if configured by sys and supported by c.
+func configureOCIArchive(ctx context.Context, sys *types.SystemContext, c *client.Client, options *tarfile.WriterOptions) error {
+	requested := types.OptionalBoolUndefined
+	if sys != nil {
+		requested = sys.DockerDaemonOCIArchive
+	}
+	switch {
+	case requested == types.OptionalBoolFalse:
+		return nil
+	case options.LegacyMetadata == tarfile.LegacyMetadataOnly:
+		if requested == types.OptionalBoolTrue {
+			return errors.New("DockerDaemonOCIArchive can not be combined with DockerArchiveLegacyMetadataOnly")
+		}
+		return nil
+	case requested == types.OptionalBoolUndefined:
+		if err := c.NewVersionError(ctx, ociArchiveMinAPIVersion, "OCI archives"); err != nil {
+			logrus.Debugf("docker-daemon: not sending an OCI archive: %v", err)
+			return nil
+		}
+	}
+	if options.Format == tarfile.FormatDockerSave {
+		// Keep manifest.json, so that layers which already exist in the engine can be omitted.
+		options.Format = tarfile.FormatDockerSaveAndOCILayout
+	}
+	options.PreserveOCIManifests = true
+	return nil
+}
@@ -0,0 +1,54 @@
+package daemon
+
+import (
+	"context"
+	"testing"
+
+	"github.com/containers/image/v5/docker/internal/tarfile"
+	"github.com/containers/image/v5/types"
+	"github.com/docker/docker/api/types/image"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigureOCIArchive(t *testing.T) {
+	for _, c := range []struct {
+		apiVersion     string
+		requested      types.OptionalBool
+		options        tarfile.WriterOptions
+		expectedFormat tarfile.ArchiveFormat // Only relevant if expectedOCI
+		expectedOCI    bool
+	}{
+		{"1.44", types.OptionalBoolUndefined, tarfile.WriterOptions{}, tarfile.FormatDockerSaveAndOCILayout, true},
+		{"1.43", types.OptionalBoolUndefined, tarfile.WriterOptions{}, tarfile.FormatDockerSave, false},
+		{"1.43", types.OptionalBoolTrue, tarfile.WriterOptions{}, tarfile.FormatDockerSaveAndOCILayout, true},
+		{"1.44", types.OptionalBoolFalse, tarfile.WriterOptions{}, tarfile.FormatDockerSave, false},
+		{"1.44", types.OptionalBoolUndefined, tarfile.WriterOptions{Format: tarfile.FormatOCILayout}, tarfile.FormatOCILayout, true},
+		{"1.44", types.OptionalBoolUndefined, tarfile.WriterOptions{LegacyMetadata: tarfile.LegacyMetadataOnly}, tarfile.FormatDockerSave, false},
+	} {
+		var exportedPlatform string
+		server := platformTestEngine(t, c.apiVersion, image.InspectResponse{}, &exportedPlatform)
+		sys := &types.SystemContext{DockerDaemonHost: server.URL, DockerDaemonOCIArchive: c.requested}
+		client, err := newDockerClient(sys)
+		require.NoError(t, err)
+		options := c.options
+		err = configureOCIArchive(context.Background(), sys, client, &options)
+		client.Close()
+		require.NoError(t, err, "%#v", c)
+		assert.Equal(t, c.expectedOCI, options.PreserveOCIManifests, "%#v", c)
+		if c.expectedOCI {
+			assert.Equal(t, c.expectedFormat, options.Format, "%#v", c)
+		} else {
+			assert.Equal(t, c.options, options, "%#v", c)
+		}
+	}
+
+	// An explicit request which can not be satisfied is rejected, without contacting the engine.
+	sys := &types.SystemContext{DockerDaemonHost: "unix:///this/does/not/exist", DockerDaemonOCIArchive: types.OptionalBoolTrue}
+	client, err := newDockerClient(sys)
+	require.NoError(t, err)
+	defer client.Close()
+	options := tarfile.WriterOptions{LegacyMetadata: tarfile.LegacyMetadataOnly}
+	err = configureOCIArchive(context.Background(), sys, client, &options)
+	assert.ErrorContains(t, err, "DockerArchiveLegacyMetadataOnly")
+}
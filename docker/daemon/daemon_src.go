@@ -6,9 +6,15 @@ import (
 
 	"github.com/containers/image/v5/docker/internal/tarfile"
 	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/internal/tmpdir"
 	"github.com/containers/image/v5/types"
 )
 
+// InsufficientTemporarySpaceError is returned if the directory for temporary big files
+// (see types.SystemContext.BigFilesTemporaryDir) is estimated not to have enough space
+// to buffer the image exported from the docker engine.
+type InsufficientTemporarySpaceError = tmpdir.InsufficientSpaceError
+
 type daemonImageSource struct {
 	ref             daemonReference
 	*tarfile.Source // Implements most of types.ImageSource
@@ -32,7 +38,14 @@ func newImageSource(ctx context.Context, sys *types.SystemContext, ref daemonRef
 
 	// Per NewReference(), ref.StringWithinTransport() is either an image ID (config digest), or a !reference.NameOnly() reference.
 	// Either way ImageSave should create a tarball with exactly one image.
-	inputStream, err := c.ImageSave(ctx, []string{ref.StringWithinTransport()})
+	// The whole tarball is buffered in a temporary file, so fail early if it can’t fit.
+	saveOptions, size := imageExportOptions(ctx, sys, c, ref.StringWithinTransport())
+	if size > 0 {
+		if err := tmpdir.CheckSpaceForBigFiles(sys, uint64(size)); err != nil {
+			return nil, fmt.Errorf("loading image from docker engine: %w", err)
+		}
+	}
+	inputStream, err := c.ImageSave(ctx, []string{ref.StringWithinTransport()}, saveOptions...)
 	if err != nil {
 		return nil, fmt.Errorf("loading image from docker engine: %w", err)
 	}
@@ -43,6 +56,10 @@ func newImageSource(ctx context.Context, sys *types.SystemContext, ref daemonRef
 		return nil, err
 	}
 	src := tarfile.NewSource(archive, true, ref.Transport().Name(), nil, -1)
+	if sys == nil || sys.DockerDaemonOCIArchive != types.OptionalBoolFalse {
+		// Engines which don’t support OCI archives don’t include an OCI layout, so there is no need to check the API version.
+		src.UseOCIManifests()
+	}
 	return &daemonImageSource{
 		ref:    ref,
 		Source: src,
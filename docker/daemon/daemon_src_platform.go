@@ -0,0 +1,59 @@
+package daemon
+
+import (
+	"context"
+
+	"github.com/containers/image/v5/internal/pkg/platform"
+	"github.com/containers/image/v5/types"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+	"github.com/sirupsen/logrus"
+)
+
+// imageExportOptions returns options for exporting the image name from c, and the estimated size of the exported data (0 if unknown).
+//
+// If the engine uses the containerd image store, which can store multi-platform images, only the platform
+// matching sys is exported. The engine API does not provide access to individual blobs, so the image is still exported
+// as a (docker save) archive; but this avoids exporting, and buffering, all platforms of an image just to read one of them.
+func imageExportOptions(ctx context.Context, sys *types.SystemContext, c *client.Client, name string) ([]client.ImageSaveOption, int64) {
+	if err := c.NewVersionError(ctx, "1.48", "manifests"); err != nil {
+		logrus.Debugf("docker-daemon: not selecting a platform to export: %v", err)
+		inspect, err := c.ImageInspect(ctx, name)
+		if err != nil {
+			logrus.Debugf("docker-daemon: inspecting image failed: %v", err)
+			return nil, 0
+		}
+		return nil, inspect.Size
+	}
+
+	inspect, err := c.ImageInspect(ctx, name, client.ImageInspectWithManifests(true))
+	if err != nil {
+		logrus.Debugf("docker-daemon: inspecting image failed: %v", err)
+		return nil, 0
+	}
+	if len(inspect.Manifests) == 0 { // The engine does not use the containerd image store
+		return nil, inspect.Size
+	}
+	m := chooseExportedManifest(sys, inspect.Manifests)
+	if m == nil {
+		logrus.Debugf("docker-daemon: no available platform of %s matches, exporting all platforms", name)
+		return nil, inspect.Size
+	}
+	logrus.Debugf("docker-daemon: exporting only platform %s/%s/%s of %s", m.ImageData.Platform.OS, m.ImageData.Platform.Architecture, m.ImageData.Platform.Variant, name)
+	return []client.ImageSaveOption{client.ImageSaveWithPlatforms(m.ImageData.Platform)}, m.Size.Content
+}
+
+// chooseExportedManifest returns the item of manifests which best matches the platform requested by sys,
+// considering only images with contents available in the engine, or nil if there is no such item.
+func chooseExportedManifest(sys *types.SystemContext, manifests []image.ManifestSummary) *image.ManifestSummary {
+	for _, wanted := range platform.WantedPlatforms(sys) {
+		for i := range manifests {
+			m := &manifests[i]
+			if m.Kind == image.ManifestKindImage && m.Available && m.ImageData != nil &&
+				platform.MatchesPlatform(m.ImageData.Platform, wanted) {
+				return m
+			}
+		}
+	}
+	return nil
+}
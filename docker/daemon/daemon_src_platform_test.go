@@ -0,0 +1,119 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/containers/image/v5/types"
+	"github.com/docker/docker/api/types/image"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// manifestSummaryForPlatform returns an image.ManifestSummary for an image of the specified platform.
+func manifestSummaryForPlatform(os, arch, variant string, available bool, size int64) image.ManifestSummary {
+	m := image.ManifestSummary{
+		Available: available,
+		Kind:      image.ManifestKindImage,
+		ImageData: &image.ImageProperties{Platform: imgspecv1.Platform{OS: os, Architecture: arch, Variant: variant}},
+	}
+	m.Size.Content = size
+	return m
+}
+
+func TestChooseExportedManifest(t *testing.T) {
+	manifests := []image.ManifestSummary{
+		{Kind: image.ManifestKindAttestation, Available: true},
+		manifestSummaryForPlatform("linux", "amd64", "", false, 1),
+		manifestSummaryForPlatform("linux", "arm64", "v8", true, 2),
+		manifestSummaryForPlatform("linux", "arm", "v7", true, 3),
+	}
+	for _, c := range []struct {
+		os, arch, variant string
+		expected          int // Index into manifests, or -1 if no match is expected
+	}{
+		{"linux", "arm64", "", 2},
+		{"linux", "arm", "v7", 3},
+		{"linux", "amd64", "", -1}, // Not available
+		{"windows", "amd64", "", -1},
+	} {
+		sys := &types.SystemContext{OSChoice: c.os, ArchitectureChoice: c.arch, VariantChoice: c.variant}
+		res := chooseExportedManifest(sys, manifests)
+		if c.expected == -1 {
+			assert.Nil(t, res, "%#v", c)
+		} else {
+			assert.Equal(t, &manifests[c.expected], res, "%#v", c)
+		}
+	}
+}
+
+// platformTestEngine returns a server implementing the image inspect and export APIs of an engine supporting apiVersion,
+// responding to inspect requests with inspect, and recording the platform requested when exporting the image.
+func platformTestEngine(t *testing.T, apiVersion string, inspect image.InspectResponse, exportedPlatform *string) *httptest.Server {
+	return fakeEngine(t, apiVersion, map[string]http.HandlerFunc{
+		"/images/busybox:latest/json": func(w http.ResponseWriter, r *http.Request) {
+			res := inspect
+			if r.URL.Query().Get("manifests") != "1" {
+				res.Manifests = nil
+			}
+			err := json.NewEncoder(w).Encode(res)
+			require.NoError(t, err)
+		},
+		"/images/get": func(w http.ResponseWriter, r *http.Request) {
+			*exportedPlatform = r.URL.Query().Get("platform")
+		},
+	})
+}
+
+func TestImageExportOptions(t *testing.T) {
+	inspect := image.InspectResponse{
+		ID:   "sha256:1111111111111111111111111111111111111111111111111111111111111111",
+		Size: 1000,
+		Manifests: []image.ManifestSummary{
+			manifestSummaryForPlatform("linux", "amd64", "", true, 100),
+			manifestSummaryForPlatform("linux", "arm64", "v8", true, 200),
+		},
+	}
+	sys := &types.SystemContext{OSChoice: "linux", ArchitectureChoice: "arm64"}
+
+	for _, c := range []struct {
+		apiVersion string
+		inspect    image.InspectResponse
+		platform   string
+		size       int64
+	}{
+		{"1.48", inspect, `{"architecture":"arm64","os":"linux","variant":"v8"}`, 200},
+		{"1.47", inspect, "", 1000}, // The engine is too old to list platforms
+		{"1.48", image.InspectResponse{ID: inspect.ID, Size: 1000}, "", 1000},                                   // The engine does not use the containerd image store
+		{"1.48", image.InspectResponse{ID: inspect.ID, Size: 1000, Manifests: inspect.Manifests[:1]}, "", 1000}, // No matching platform
+	} {
+		var exportedPlatform string
+		server := platformTestEngine(t, c.apiVersion, c.inspect, &exportedPlatform)
+		c2, err := newDockerClient(&types.SystemContext{DockerDaemonHost: server.URL})
+		require.NoError(t, err)
+		options, size := imageExportOptions(context.Background(), sys, c2, "busybox:latest")
+		assert.Equal(t, c.size, size, c.apiVersion)
+		stream, err := c2.ImageSave(context.Background(), []string{"busybox:latest"}, options...)
+		require.NoError(t, err)
+		_, err = io.Copy(io.Discard, stream)
+		require.NoError(t, err)
+		stream.Close()
+		c2.Close()
+		assert.Equal(t, c.platform, exportedPlatform, c.apiVersion)
+	}
+
+	// Inspecting the image fails
+	var exportedPlatform string
+	server := platformTestEngine(t, "1.48", inspect, &exportedPlatform)
+	c, err := newDockerClient(&types.SystemContext{DockerDaemonHost: server.URL})
+	require.NoError(t, err)
+	defer c.Close()
+	options, size := imageExportOptions(context.Background(), sys, c, "unknown:latest")
+	assert.Nil(t, options)
+	assert.Equal(t, int64(0), size)
+}
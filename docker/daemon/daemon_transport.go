@@ -41,6 +41,10 @@ func (t daemonTransport) ValidatePolicyConfigurationScope(scope string) error {
 	if _, err := digest.Parse(scope); err == nil {
 		return fmt.Errorf(`docker-daemon: can not use algo:digest value %s as a namespace`, scope)
 	}
+	// Unlike docker:, pattern scopes are not supported; refuse them instead of silently never matching them.
+	if policyconfiguration.DockerReferenceScopeIsPattern(scope) {
+		return fmt.Errorf(`docker-daemon: pattern scopes like %s are not supported`, scope)
+	}
 
 	// FIXME? We could be verifying the various character set and length restrictions
 	// from docker/distribution/reference.regexp.go, but other than that there
@@ -40,9 +40,13 @@ func TestTransportValidatePolicyConfigurationScope(t *testing.T) {
 		assert.NoError(t, err, scope)
 	}
 
-	// Hexadecimal IDs are rejected. algo:hexdigest is clearly an invalid host:port value.
-	err := Transport.ValidatePolicyConfigurationScope(sha256digest)
-	assert.Error(t, err)
+	for _, scope := range []string{
+		sha256digest,                          // Hexadecimal IDs are rejected. algo:hexdigest is clearly an invalid host:port value.
+		"registry.example.com/ns/*:release-*", // Pattern scopes are not supported
+	} {
+		err := Transport.ValidatePolicyConfigurationScope(scope)
+		assert.Error(t, err, scope)
+	}
 }
 
 func TestParseReference(t *testing.T) {
@@ -0,0 +1,312 @@
+package docker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/internal/iolimits"
+	"github.com/containers/image/v5/internal/set"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/types"
+	"github.com/docker/distribution/registry/api/errcode"
+	"github.com/opencontainers/go-digest"
+	"github.com/sirupsen/logrus"
+)
+
+// DeleteOptions configures DeleteImageWithOptions.
+type DeleteOptions struct {
+	// UntagOnly, if set, only removes the tag of the reference (which must be tagged, not a digested reference);
+	// the manifest, and any other tags referring to it, are preserved.
+	// This uses deletion of tags as defined by the OCI distribution spec, which is not supported by all registries;
+	// see ProbeDeleteCapabilities.
+	UntagOnly bool
+	// DeleteInstances, if set and the reference refers to a manifest list or an image index, also deletes the manifests
+	// of all instances it references, after deleting the list itself.
+	// Instances which the registry does not permit deleting (e.g. because they are also referenced from other lists)
+	// are skipped, and reported in DeleteResult.SkippedInstances.
+	DeleteInstances bool
+}
+
+// DeleteResult describes the effects of DeleteImageWithOptions.
+type DeleteResult struct {
+	// Untagged is true if the tag was removed, with DeleteOptions.UntagOnly.
+	Untagged bool
+	// DeletedManifests are the digests of the deleted manifests: the primary manifest first, then any instances.
+	DeletedManifests []digest.Digest
+	// SkippedInstances are the instances the registry did not permit deleting, with the registry’s error.
+	SkippedInstances map[digest.Digest]error
+}
+
+// DeleteCapabilities describes which deletion operations a registry supports, as determined by ProbeDeleteCapabilities.
+type DeleteCapabilities struct {
+	// ManifestDeletion is true if manifests can be deleted by digest (i.e. DeleteImageWithOptions without UntagOnly).
+	ManifestDeletion bool
+	// TagDeletion is true if tags can be deleted (i.e. DeleteImageWithOptions with UntagOnly).
+	TagDeletion bool
+}
+
+// deleteProbeTagPrefix is the prefix of random tags used by ProbeDeleteCapabilities.
+const deleteProbeTagPrefix = "containers-image-delete-probe-"
+
+// deleteProbeTargets returns a random tag and a random manifest digest, used by ProbeDeleteCapabilities.
+// Both are unguessable, so they don’t exist in any repository.
+func deleteProbeTargets() (string, digest.Digest, error) {
+	randomBytes := make([]byte, 16)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", "", fmt.Errorf("generating a random tag: %w", err)
+	}
+	tag := deleteProbeTagPrefix + hex.EncodeToString(randomBytes)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", "", fmt.Errorf("generating a random digest: %w", err)
+	}
+	return tag, digest.FromBytes(randomBytes), nil
+}
+
+// DeleteImageWithOptions deletes the image at ref, which must be a docker:// reference, from the registry, as configured by options.
+// Compared to types.ImageReference.DeleteImage, it can delete instances of a manifest list, or only remove a tag.
+//
+// If deleting instances fails for a reason other than the registry not permitting it, the returned DeleteResult
+// describes what has been deleted so far, along with the error.
+func DeleteImageWithOptions(ctx context.Context, sys *types.SystemContext, ref types.ImageReference, options DeleteOptions) (*DeleteResult, error) {
+	dr, ok := ref.(dockerReference)
+	if !ok {
+		return nil, errors.New("ref must be a dockerReference")
+	}
+	if dr.isUnknownDigest {
+		return nil, errors.New("Docker reference without a tag or digest cannot be deleted")
+	}
+	if options.UntagOnly && options.DeleteInstances {
+		return nil, errors.New("deleting instances can not be combined with only removing a tag")
+	}
+
+	c, err := newDeleteClient(sys, dr)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	if options.UntagOnly {
+		return c.untag(ctx, dr)
+	}
+
+	refTail, err := dr.tagOrDigest()
+	if err != nil {
+		return nil, err
+	}
+	manifestBody, mimeType, err := c.getManifestForDelete(ctx, dr, refTail)
+	if err != nil {
+		return nil, err
+	}
+	manifestDigest, err := manifest.Digest(manifestBody)
+	if err != nil {
+		return nil, fmt.Errorf("computing manifest digest: %w", err)
+	}
+	res := &DeleteResult{}
+	if err := c.deleteManifestAndSignatures(ctx, dr, manifestDigest); err != nil {
+		return nil, fmt.Errorf("deleting %v: %w", dr.ref, err)
+	}
+	res.DeletedManifests = append(res.DeletedManifests, manifestDigest)
+
+	if !options.DeleteInstances || !manifest.MIMETypeIsMultiImage(mimeType) {
+		return res, nil
+	}
+	list, err := manifest.ListFromBlob(manifestBody, mimeType)
+	if err != nil {
+		return res, fmt.Errorf("parsing manifest list %s: %w", manifestDigest, err)
+	}
+	seen := set.New[digest.Digest]()
+	for _, instance := range list.Instances() {
+		if seen.Contains(instance) {
+			continue
+		}
+		seen.Add(instance)
+		status, err := c.deleteManifest(ctx, dr, instance.String())
+		switch {
+		case err == nil:
+			if err := c.deleteLookasideSignatures(instance); err != nil {
+				return res, err
+			}
+			res.DeletedManifests = append(res.DeletedManifests, instance)
+		case status == http.StatusNotFound:
+			logrus.Debugf("Instance %s of %s does not exist, not deleting it", instance, manifestDigest)
+		case status == http.StatusForbidden || status == http.StatusMethodNotAllowed || status == http.StatusConflict:
+			logrus.Debugf("Registry did not permit deleting instance %s of %s: %v", instance, manifestDigest, err)
+			if res.SkippedInstances == nil {
+				res.SkippedInstances = map[digest.Digest]error{}
+			}
+			res.SkippedInstances[instance] = err
+		default:
+			return res, fmt.Errorf("deleting instance %s of %v: %w", instance, dr.ref, err)
+		}
+	}
+	return res, nil
+}
+
+// ProbeDeleteCapabilities determines which deletion operations the registry of ref, which must be a docker:// reference,
+// supports in the repository of ref, for the credentials configured in sys.
+//
+// Warning: The probe sends DELETE requests to the registry, for a manifest digest and a tag which are randomly generated
+// for every probe, so they don’t exist and nothing is deleted; still, the requests may be recorded in audit logs of the registry.
+func ProbeDeleteCapabilities(ctx context.Context, sys *types.SystemContext, ref types.ImageReference) (DeleteCapabilities, error) {
+	dr, ok := ref.(dockerReference)
+	if !ok {
+		return DeleteCapabilities{}, errors.New("ref must be a dockerReference")
+	}
+	c, err := newDeleteClient(sys, dr)
+	if err != nil {
+		return DeleteCapabilities{}, err
+	}
+	defer c.Close()
+
+	probeTag, probeDigest, err := deleteProbeTargets()
+	if err != nil {
+		return DeleteCapabilities{}, err
+	}
+	res := DeleteCapabilities{}
+	for _, probe := range []struct {
+		tagOrDigest string
+		dest        *bool
+	}{
+		{probeDigest.String(), &res.ManifestDeletion},
+		{probeTag, &res.TagDeletion},
+	} {
+		status, err := c.deleteManifest(ctx, dr, probe.tagOrDigest)
+		switch {
+		case err == nil, status == http.StatusNotFound: // The request was accepted; nothing was deleted, hopefully.
+			*probe.dest = true
+		case deletionUnsupported(status, err), status == http.StatusUnauthorized, status == http.StatusForbidden:
+			*probe.dest = false
+		default:
+			return DeleteCapabilities{}, fmt.Errorf("probing deletion support of %v: %w", dr.ref, err)
+		}
+	}
+	return res, nil
+}
+
+// newDeleteClient returns a client for deleting images in the repository of ref.
+// The caller must call Close() on the returned client.
+func newDeleteClient(sys *types.SystemContext, ref dockerReference) (*dockerClient, error) {
+	registryConfig, err := loadRegistryConfiguration(sys)
+	if err != nil {
+		return nil, err
+	}
+	// docker/distribution does not document what action should be used for deleting images.
+	//
+	// Current docker/distribution requires "pull" for reading the manifest and "delete" for deleting it.
+	// quay.io requires "push" (an explicit "pull" is unnecessary), does not grant any token (fails parsing the request) if "delete" is included.
+	// OpenShift ignores the action string (both the password and the token is an OpenShift API token identifying a user).
+	//
+	// We have to hard-code a single string, luckily both docker/distribution and quay.io support "*" to mean "everything".
+	return newDockerClientFromRef(sys, ref, registryConfig, true, "*")
+}
+
+// getManifestForDelete returns the manifest of ref at tagOrDigest, and its MIME type, before deleting it.
+func (c *dockerClient) getManifestForDelete(ctx context.Context, ref dockerReference, tagOrDigest string) ([]byte, string, error) {
+	headers := map[string][]string{
+		"Accept": manifest.DefaultRequestedManifestMIMETypes,
+	}
+	getPath := fmt.Sprintf(manifestPath, reference.Path(ref.ref), tagOrDigest)
+	get, err := c.makeRequest(ctx, http.MethodGet, getPath, headers, nil, v2Auth, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer get.Body.Close()
+	switch get.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, "", fmt.Errorf("Unable to delete %v. Image may not exist or is not stored with a v2 Schema in a v2 registry", ref.ref)
+	default:
+		return nil, "", fmt.Errorf("deleting %v: %w", ref.ref, registryHTTPResponseToError(get))
+	}
+	manifestBody, err := iolimits.ReadAtMost(get.Body, iolimits.MaxManifestBodySize)
+	if err != nil {
+		return nil, "", err
+	}
+	mimeType := simplifyContentType(get.Header.Get("Content-Type"))
+	if mimeType == "" {
+		mimeType = manifest.GuessMIMEType(manifestBody)
+	}
+	return manifestBody, mimeType, nil
+}
+
+// deleteManifest deletes the manifest, or only the tag, tagOrDigest in the repository of ref.
+// It returns the HTTP status of the response, and an error if the registry has not accepted the request
+// (the status is 0 if no response was received).
+func (c *dockerClient) deleteManifest(ctx context.Context, ref dockerReference, tagOrDigest string) (int, error) {
+	deletePath := fmt.Sprintf(manifestPath, reference.Path(ref.ref), tagOrDigest)
+	headers := map[string][]string{
+		"Accept": manifest.DefaultRequestedManifestMIMETypes,
+	}
+	res, err := c.makeRequest(ctx, http.MethodDelete, deletePath, headers, nil, v2Auth, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusAccepted {
+		return res.StatusCode, registryHTTPResponseToError(res)
+	}
+	return res.StatusCode, nil
+}
+
+// deleteManifestAndSignatures deletes the manifest with manifestDigest in the repository of ref, and its lookaside signatures.
+func (c *dockerClient) deleteManifestAndSignatures(ctx context.Context, ref dockerReference, manifestDigest digest.Digest) error {
+	if _, err := c.deleteManifest(ctx, ref, manifestDigest.String()); err != nil {
+		return err
+	}
+	return c.deleteLookasideSignatures(manifestDigest)
+}
+
+// deleteLookasideSignatures deletes lookaside signatures of manifestDigest, if any.
+func (c *dockerClient) deleteLookasideSignatures(manifestDigest digest.Digest) error {
+	for i := 0; ; i++ {
+		sigURL, err := lookasideStorageURL(c.signatureBase, manifestDigest, i)
+		if err != nil {
+			return err
+		}
+		missing, err := c.deleteOneSignature(sigURL)
+		if err != nil {
+			return err
+		}
+		if missing {
+			return nil
+		}
+	}
+}
+
+// untag removes the tag of ref, keeping the manifest.
+func (c *dockerClient) untag(ctx context.Context, ref dockerReference) (*DeleteResult, error) {
+	tagged, ok := ref.ref.(reference.NamedTagged)
+	if !ok {
+		return nil, fmt.Errorf("removing a tag requires a tagged reference, not %v", ref.ref)
+	}
+	if _, ok := ref.ref.(reference.Canonical); ok {
+		return nil, fmt.Errorf("removing a tag requires a reference without a digest, not %v", ref.ref)
+	}
+	status, err := c.deleteManifest(ctx, ref, tagged.Tag())
+	if err != nil {
+		if deletionUnsupported(status, err) {
+			return nil, fmt.Errorf("removing tag %v: %w: %w", ref.ref, ErrTagDeletionUnsupported, err)
+		}
+		return nil, fmt.Errorf("removing tag %v: %w", ref.ref, err)
+	}
+	return &DeleteResult{Untagged: true}, nil
+}
+
+// deletionUnsupported returns true if the status and err returned by deleteManifest indicate that
+// the registry does not support the deletion.
+func deletionUnsupported(status int, err error) bool {
+	if status == http.StatusMethodNotAllowed {
+		return true
+	}
+	var ec errcode.ErrorCoder
+	if errors.As(err, &ec) && ec.ErrorCode() == errcode.ErrorCodeUnsupported {
+		return true
+	}
+	// Older docker/distribution versions respond to deleting a tag with 400 DIGEST_INVALID.
+	return status == http.StatusBadRequest
+}
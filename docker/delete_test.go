@@ -0,0 +1,207 @@
+package docker
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// deleteTestRegistry is a minimal registry serving and deleting manifests of a single repository, "repo".
+type deleteTestRegistry struct {
+	t                 *testing.T
+	mu                sync.Mutex
+	manifests         map[digest.Digest][]byte
+	mimeTypes         map[digest.Digest]string
+	tags              map[string]digest.Digest
+	protected         map[digest.Digest]bool // Deleting these manifests is refused with 403
+	tagDeletionStatus int                    // If not 0, deleting tags fails with this status
+}
+
+func (r *deleteTestRegistry) addManifest(m []byte, mimeType string) digest.Digest {
+	d := digest.FromBytes(m)
+	r.manifests[d] = m
+	r.mimeTypes[d] = mimeType
+	return d
+}
+
+func (r *deleteTestRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if req.URL.Path == "/v2/" {
+		return
+	}
+	tagOrDigest, ok := strings.CutPrefix(req.URL.Path, "/v2/repo/manifests/")
+	if !ok {
+		assert.Failf(r.t, "Unexpected request", "%s %s", req.Method, req.URL)
+		http.NotFound(w, req)
+		return
+	}
+	d, err := digest.Parse(tagOrDigest)
+	isTag := err != nil
+	if isTag && req.Method == http.MethodDelete && r.tagDeletionStatus != 0 {
+		w.WriteHeader(r.tagDeletionStatus)
+		return
+	}
+	if isTag {
+		d, ok = r.tags[tagOrDigest]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+	}
+	m, ok := r.manifests[d]
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	switch req.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", r.mimeTypes[d])
+		_, err := w.Write(m)
+		require.NoError(r.t, err)
+	case http.MethodDelete:
+		switch {
+		case isTag:
+			delete(r.tags, tagOrDigest)
+			w.WriteHeader(http.StatusAccepted)
+		case r.protected[d]:
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			delete(r.manifests, d)
+			w.WriteHeader(http.StatusAccepted)
+		}
+	default:
+		assert.Failf(r.t, "Unexpected request", "%s %s", req.Method, req.URL)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// newDeleteTestRegistry returns a registry containing a tagged image index with two instances,
+// the digests of the index and the instances, and a SystemContext for accessing the registry.
+func newDeleteTestRegistry(t *testing.T) (*deleteTestRegistry, string, digest.Digest, []digest.Digest, *types.SystemContext) {
+	r := &deleteTestRegistry{
+		t:         t,
+		manifests: map[digest.Digest][]byte{},
+		mimeTypes: map[digest.Digest]string{},
+		tags:      map[string]digest.Digest{},
+		protected: map[digest.Digest]bool{},
+	}
+	instances := []digest.Digest{}
+	descriptors := []imgspecv1.Descriptor{}
+	for _, arch := range []string{"amd64", "arm64"} {
+		m := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":"` +
+			digest.FromString(arch).String() + `","size":1},"layers":[]}`)
+		d := r.addManifest(m, imgspecv1.MediaTypeImageManifest)
+		instances = append(instances, d)
+		descriptors = append(descriptors, imgspecv1.Descriptor{
+			MediaType: imgspecv1.MediaTypeImageManifest,
+			Digest:    d,
+			Size:      int64(len(m)),
+			Platform:  &imgspecv1.Platform{OS: "linux", Architecture: arch},
+		})
+	}
+	index, err := manifest.OCI1IndexFromComponents(descriptors, nil).Serialize()
+	require.NoError(t, err)
+	indexDigest := r.addManifest(index, imgspecv1.MediaTypeImageIndex)
+	r.tags["latest"] = indexDigest
+
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+	sys := &types.SystemContext{
+		DockerInsecureSkipTLSVerify: types.OptionalBoolTrue,
+		AuthFilePath:                filepath.Join(t.TempDir(), "auth.json"),
+		RegistriesDirPath:           t.TempDir(),
+	}
+	return r, strings.TrimPrefix(server.URL, "http://"), indexDigest, instances, sys
+}
+
+func TestDeleteImageWithOptions(t *testing.T) {
+	parse := func(s string) types.ImageReference {
+		ref, err := ParseReference("//" + s)
+		require.NoError(t, err)
+		return ref
+	}
+
+	// Only the index is deleted by default
+	r, registry, indexDigest, instances, sys := newDeleteTestRegistry(t)
+	res, err := DeleteImageWithOptions(context.Background(), sys, parse(registry+"/repo:latest"), DeleteOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, &DeleteResult{DeletedManifests: []digest.Digest{indexDigest}}, res)
+	assert.NotContains(t, r.manifests, indexDigest)
+	assert.Len(t, r.manifests, 2)
+
+	// Instances are deleted, unless the registry refuses
+	r, registry, indexDigest, instances, sys = newDeleteTestRegistry(t)
+	r.protected[instances[1]] = true
+	res, err = DeleteImageWithOptions(context.Background(), sys, parse(registry+"/repo@"+indexDigest.String()), DeleteOptions{DeleteInstances: true})
+	require.NoError(t, err)
+	assert.Equal(t, []digest.Digest{indexDigest, instances[0]}, res.DeletedManifests)
+	require.Len(t, res.SkippedInstances, 1)
+	assert.Error(t, res.SkippedInstances[instances[1]])
+	assert.Equal(t, []digest.Digest{instances[1]}, slices.Collect(maps.Keys(r.manifests)))
+
+	// Untagging keeps the manifest
+	r, registry, indexDigest, _, sys = newDeleteTestRegistry(t)
+	res, err = DeleteImageWithOptions(context.Background(), sys, parse(registry+"/repo:latest"), DeleteOptions{UntagOnly: true})
+	require.NoError(t, err)
+	assert.Equal(t, &DeleteResult{Untagged: true}, res)
+	assert.Empty(t, r.tags)
+	assert.Contains(t, r.manifests, indexDigest)
+	_, err = DeleteImageWithOptions(context.Background(), sys, parse(registry+"/repo@"+indexDigest.String()), DeleteOptions{UntagOnly: true})
+	assert.Error(t, err)
+	_, err = DeleteImageWithOptions(context.Background(), sys, parse(registry+"/repo:latest"), DeleteOptions{UntagOnly: true, DeleteInstances: true})
+	assert.Error(t, err)
+
+	// Registries which don't support deleting tags
+	for _, status := range []int{http.StatusBadRequest, http.StatusMethodNotAllowed} {
+		r, registry, _, _, sys = newDeleteTestRegistry(t)
+		r.tagDeletionStatus = status
+		_, err = DeleteImageWithOptions(context.Background(), sys, parse(registry+"/repo:latest"), DeleteOptions{UntagOnly: true})
+		assert.ErrorIs(t, err, ErrTagDeletionUnsupported, status)
+		assert.Contains(t, r.tags, "latest")
+	}
+}
+
+func TestProbeDeleteCapabilities(t *testing.T) {
+	r, registry, _, _, sys := newDeleteTestRegistry(t)
+	ref, err := ParseReference("//" + registry + "/repo")
+	require.NoError(t, err)
+	// A tag with a name similar to the probe tags is not affected.
+	r.tags[deleteProbeTagPrefix+"existing"] = r.tags["latest"]
+	res, err := ProbeDeleteCapabilities(context.Background(), sys, ref)
+	require.NoError(t, err)
+	assert.Equal(t, DeleteCapabilities{ManifestDeletion: true, TagDeletion: true}, res)
+	assert.Len(t, r.manifests, 3)
+	assert.Len(t, r.tags, 2)
+
+	r.tagDeletionStatus = http.StatusMethodNotAllowed
+	res, err = ProbeDeleteCapabilities(context.Background(), sys, ref)
+	require.NoError(t, err)
+	assert.Equal(t, DeleteCapabilities{ManifestDeletion: true, TagDeletion: false}, res)
+}
+
+func TestDeleteProbeTargets(t *testing.T) {
+	tag1, digest1, err := deleteProbeTargets()
+	require.NoError(t, err)
+	tag2, digest2, err := deleteProbeTargets()
+	require.NoError(t, err)
+	assert.NotEqual(t, tag1, tag2)
+	assert.NotEqual(t, digest1, digest2)
+	for _, tag := range []string{tag1, tag2} {
+		assert.Regexp(t, "^"+reference.TagRegexp.String()+"$", tag)
+	}
+}
@@ -1,6 +1,8 @@
 package docker
 
 import (
+	"bytes"
+	"cmp"
 	"context"
 	"crypto/tls"
 	"encoding/json"
@@ -20,6 +22,7 @@ import (
 	"github.com/containers/image/v5/docker/reference"
 	"github.com/containers/image/v5/internal/iolimits"
 	"github.com/containers/image/v5/internal/multierr"
+	"github.com/containers/image/v5/internal/private"
 	"github.com/containers/image/v5/internal/set"
 	"github.com/containers/image/v5/internal/useragent"
 	"github.com/containers/image/v5/manifest"
@@ -44,9 +47,11 @@ const (
 
 	resolvedPingV2URL       = "%s://%s/v2/"
 	tagsPath                = "/v2/%s/tags/list"
+	catalogPath             = "/v2/_catalog"
 	manifestPath            = "/v2/%s/manifests/%s"
 	blobsPath               = "/v2/%s/blobs/%s"
 	blobUploadPath          = "/v2/%s/blobs/uploads/"
+	referrersPath           = "/v2/%s/referrers/%s"
 	extensionsSignaturePath = "/extensions/v2/%s/signatures/%s"
 
 	minimumTokenLifetimeSeconds = 60
@@ -57,6 +62,8 @@ const (
 	backoffNumIterations = 5
 	backoffInitialDelay  = 2 * time.Second
 	backoffMaxDelay      = 60 * time.Second
+
+	maxRedirects = 10 // The same as the net/http default
 )
 
 type certPath struct {
@@ -90,6 +97,7 @@ type extensionSignatureList struct {
 type bearerToken struct {
 	token          string
 	expirationTime time.Time
+	refreshToken   string // A new OAuth2 refresh token issued by the token server, if any
 }
 
 // dockerClient is configuration for dealing with a single container registry.
@@ -111,13 +119,18 @@ type dockerClient struct {
 
 	// The following members are detected registry properties:
 	// They are set after a successful detectProperties(), and never change afterwards.
-	client             *http.Client
+	client             *http.Client    // Used for requests to hosts other than the registry
+	backend            registryBackend // Used for requests to the registry
 	scheme             string
 	challenges         []challenge
 	supportsSignatures bool
 
 	// Private state for setupRequestAuth (key: string, value: bearerToken)
 	tokenCache sync.Map
+	// Private state for requestAuth:
+	authLock               sync.Mutex
+	authOverride           *types.DockerAuthConfig // If set, used instead of auth: obtained from DockerTokenProvider, or with a rotated refresh token
+	authOverrideExpiration time.Time               // The time authOverride expires, or the zero value if it does not
 	// Private state for detectProperties:
 	detectPropertiesOnce  sync.Once // detectPropertiesOnce is used to execute detectProperties() at most once.
 	detectPropertiesError error     // detectPropertiesError caches the initial error.
@@ -203,6 +216,10 @@ func newDockerClientFromRef(sys *types.SystemContext, ref dockerReference, regis
 	if err != nil {
 		return nil, err
 	}
+	if err := registryConfig.setupTLSCACertificates(ref, client.tlsClientConfig); err != nil {
+		client.Close()
+		return nil, err
+	}
 	client.auth = auth
 	if sys != nil {
 		client.registryToken = sys.DockerBearerRegistryToken
@@ -257,17 +274,21 @@ func newDockerClient(sys *types.SystemContext, registry, reference string) (*doc
 		}
 		skipVerify = reg.Insecure
 	}
-	tlsClientConfig.InsecureSkipVerify = skipVerify
-
-	userAgent := useragent.DefaultUserAgent
-	if sys != nil && sys.DockerRegistryUserAgent != "" {
-		userAgent = sys.DockerRegistryUserAgent
+	if sys != nil && sys.DockerStrictTLS {
+		if sys.DockerInsecureSkipTLSVerify == types.OptionalBoolTrue {
+			return nil, errors.New("DockerInsecureSkipTLSVerify can not be used together with DockerStrictTLS")
+		}
+		if skipVerify {
+			logrus.Debugf("Ignoring the insecure setting of registry %s, DockerStrictTLS is set", reg.Prefix)
+		}
+		skipVerify = false
 	}
+	tlsClientConfig.InsecureSkipVerify = skipVerify
 
 	return &dockerClient{
 		sys:              sys,
 		registry:         registry,
-		userAgent:        userAgent,
+		userAgent:        registryUserAgent(sys),
 		tlsClientConfig:  tlsClientConfig,
 		reportedWarnings: set.New[string](),
 	}, nil
@@ -392,7 +413,7 @@ func SearchRegistry(ctx context.Context, sys *types.SystemContext, registry, ima
 
 	logrus.Debugf("trying to talk to v2 search endpoint")
 	searchRes := []SearchResult{}
-	path := "/v2/_catalog"
+	path := catalogPath
 	for len(searchRes) < limit {
 		resp, err := client.makeRequest(ctx, http.MethodGet, path, nil, nil, v2Auth, nil)
 		if err != nil {
@@ -608,13 +629,39 @@ func (c *dockerClient) makeRequestToResolvedURLOnce(ctx context.Context, method
 		}
 	}
 	req.Header.Add("User-Agent", c.userAgent)
-	if auth == v2Auth {
+	toRegistry := resolvedURL.Host == c.registry
+	if c.sys != nil {
+		addHeaders(req.Header, c.sys.DockerTraceHeaders)
+		if toRegistry {
+			addHeaders(req.Header, c.sys.DockerRegistryHeaders)
+		}
+	}
+	switch {
+	case toRegistry && c.backend.authenticatesRequests():
+		// The backend authenticates the request itself.
+	case c.sys != nil && c.sys.DockerRequestSigner != nil && toRegistry:
+		// The signature replaces any other authentication; this also applies to the noAuth ping,
+		// because registries which require signing typically reject unsigned requests.
+		if err := c.sys.DockerRequestSigner.SignRequest(req); err != nil {
+			return nil, fmt.Errorf("signing request: %w", err)
+		}
+	case auth == v2Auth:
 		if err := c.setupRequestAuth(req, extraScope); err != nil {
 			return nil, err
 		}
 	}
+	if c.sys != nil && c.sys.DockerRequestRateLimiter != nil && toRegistry {
+		if err := c.sys.DockerRequestRateLimiter.Wait(ctx, c.registry, c.scope.remoteName); err != nil {
+			return nil, fmt.Errorf("waiting for the request rate limit: %w", err)
+		}
+	}
 	logrus.Debugf("%s %s", method, resolvedURL.Redacted())
-	res, err := c.client.Do(req)
+	var res *http.Response
+	if toRegistry {
+		res, err = c.backend.do(req)
+	} else {
+		res, err = c.client.Do(req)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -624,6 +671,67 @@ func (c *dockerClient) makeRequestToResolvedURLOnce(ctx context.Context, method
 	return res, nil
 }
 
+// registryUserAgent returns the User-Agent value to use when contacting registries, as configured by sys.
+func registryUserAgent(sys *types.SystemContext) string {
+	userAgent := useragent.DefaultUserAgent
+	if sys != nil && sys.DockerRegistryUserAgent != "" {
+		userAgent = sys.DockerRegistryUserAgent
+	}
+	if sys != nil && len(sys.DockerRegistryUserAgentProducts) != 0 {
+		userAgent = strings.Join(sys.DockerRegistryUserAgentProducts, " ") + " " + userAgent
+	}
+	return userAgent
+}
+
+// addHeaders adds all values in extra to header.
+func addHeaders(header, extra http.Header) {
+	for name, values := range extra {
+		for _, value := range values {
+			header.Add(name, value)
+		}
+	}
+}
+
+// checkRedirect is used as c.client.CheckRedirect.
+// Registries commonly redirect blob requests to object storage or a CDN, using pre-signed URLs;
+// such servers must not receive our registry credentials, and some reject requests which contain them.
+func (c *dockerClient) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	if req.URL.Host != c.registry {
+		// net/http already drops these headers when redirecting to a host which is not the same domain or a subdomain;
+		// be stricter, and drop them for any other host.
+		req.Header.Del("Authorization")
+		req.Header.Del("Cookie")
+		if c.sys != nil && len(c.sys.DockerRegistryHeaders) != 0 {
+			for name := range c.sys.DockerRegistryHeaders {
+				req.Header.Del(name)
+			}
+			// In case some of the deleted headers were also trace headers.
+			for name, values := range c.sys.DockerTraceHeaders {
+				if _, ok := c.sys.DockerRegistryHeaders[name]; ok {
+					for _, value := range values {
+						req.Header.Add(name, value)
+					}
+				}
+			}
+		}
+		// Don’t log the full URL, the query typically contains a signature.
+		logrus.Debugf("Following redirect of %s to host %s", via[len(via)-1].URL.Redacted(), req.URL.Host)
+	}
+	return nil
+}
+
+// redirectedURL returns the URL which produced res if the request was redirected by the registry to a different host,
+// or nil otherwise.
+func (c *dockerClient) redirectedURL(res *http.Response) *url.URL {
+	if res.Request == nil || res.Request.URL.Host == c.registry {
+		return nil
+	}
+	return res.Request.URL
+}
+
 // logResponseWarnings logs warningHeaders from res, if any.
 func (c *dockerClient) logResponseWarnings(res *http.Response, warningHeaders []string) {
 	c.reportedWarningsLock.Lock()
@@ -703,12 +811,20 @@ func (c *dockerClient) setupRequestAuth(req *http.Request, extraScope *authScope
 	if len(c.challenges) == 0 {
 		return nil
 	}
+	auth := c.auth
+	if c.registryToken == "" {
+		a, err := c.requestAuth(req.Context())
+		if err != nil {
+			return err
+		}
+		auth = a
+	}
 	schemeNames := make([]string, 0, len(c.challenges))
 	for _, challenge := range c.challenges {
 		schemeNames = append(schemeNames, challenge.Scheme)
 		switch challenge.Scheme {
 		case "basic":
-			req.SetBasicAuth(c.auth.Username, c.auth.Password)
+			req.SetBasicAuth(auth.Username, auth.Password)
 			return nil
 		case "bearer":
 			registryToken := c.registryToken
@@ -736,15 +852,7 @@ func (c *dockerClient) setupRequestAuth(req *http.Request, extraScope *authScope
 					token = t.(bearerToken)
 				}
 				if !inCache || time.Now().After(token.expirationTime) {
-					var (
-						t   *bearerToken
-						err error
-					)
-					if c.auth.IdentityToken != "" {
-						t, err = c.getBearerTokenOAuth2(req.Context(), challenge, scopes)
-					} else {
-						t, err = c.getBearerToken(req.Context(), challenge, scopes)
-					}
+					t, err := c.obtainBearerToken(req.Context(), auth, challenge, scopes)
 					if err != nil {
 						return err
 					}
@@ -764,7 +872,39 @@ func (c *dockerClient) setupRequestAuth(req *http.Request, extraScope *authScope
 	return nil
 }
 
-func (c *dockerClient) getBearerTokenOAuth2(ctx context.Context, challenge challenge,
+// obtainBearerToken returns a token for scopes from the server sending challenge, using auth, reusing a token obtained by
+// other dockerClient instances if possible.
+func (c *dockerClient) obtainBearerToken(ctx context.Context, auth types.DockerAuthConfig, challenge challenge, scopes []authScope) (*bearerToken, error) {
+	sharedCache := sharedTokenCache(c.sys)
+	sharedKey := ""
+	if sharedCache != nil {
+		sharedKey = c.sharedTokenCacheKey(auth, challenge, scopes)
+		if sharedKey == "" {
+			sharedCache = nil
+		} else if token, expiration, ok := sharedCache.GetToken(sharedKey); ok && time.Now().Before(expiration) {
+			return &bearerToken{token: token, expirationTime: expiration}, nil
+		}
+	}
+
+	var (
+		t   *bearerToken
+		err error
+	)
+	if auth.IdentityToken != "" {
+		t, err = c.getBearerTokenOAuth2(ctx, auth, challenge, scopes)
+	} else {
+		t, err = c.getBearerToken(ctx, auth, challenge, scopes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if sharedCache != nil {
+		sharedCache.PutToken(sharedKey, t.token, t.expirationTime)
+	}
+	return t, nil
+}
+
+func (c *dockerClient) getBearerTokenOAuth2(ctx context.Context, auth types.DockerAuthConfig, challenge challenge,
 	scopes []authScope) (*bearerToken, error) {
 	realm, ok := challenge.Parameters["realm"]
 	if !ok {
@@ -789,11 +929,14 @@ func (c *dockerClient) getBearerTokenOAuth2(ctx context.Context, challenge chall
 		}
 	}
 	params.Add("grant_type", "refresh_token")
-	params.Add("refresh_token", c.auth.IdentityToken)
+	params.Add("refresh_token", auth.IdentityToken)
 	params.Add("client_id", "containers/image")
 
 	authReq.Body = io.NopCloser(strings.NewReader(params.Encode()))
 	authReq.Header.Add("User-Agent", c.userAgent)
+	if c.sys != nil {
+		addHeaders(authReq.Header, c.sys.DockerTraceHeaders)
+	}
 	authReq.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	logrus.Debugf("%s %s", authReq.Method, authReq.URL.Redacted())
 	res, err := c.client.Do(authReq)
@@ -805,10 +948,18 @@ func (c *dockerClient) getBearerTokenOAuth2(ctx context.Context, challenge chall
 		return nil, err
 	}
 
-	return newBearerTokenFromHTTPResponseBody(res)
+	t, err := newBearerTokenFromHTTPResponseBody(res)
+	if err != nil {
+		return nil, err
+	}
+	if t.refreshToken != "" && t.refreshToken != auth.IdentityToken {
+		// The token server has rotated the refresh token; the original one may no longer be accepted.
+		c.rotateIdentityToken(auth.IdentityToken, t.refreshToken)
+	}
+	return t, nil
 }
 
-func (c *dockerClient) getBearerToken(ctx context.Context, challenge challenge,
+func (c *dockerClient) getBearerToken(ctx context.Context, auth types.DockerAuthConfig, challenge challenge,
 	scopes []authScope) (*bearerToken, error) {
 	realm, ok := challenge.Parameters["realm"]
 	if !ok {
@@ -821,8 +972,8 @@ func (c *dockerClient) getBearerToken(ctx context.Context, challenge challenge,
 	}
 
 	params := authReq.URL.Query()
-	if c.auth.Username != "" {
-		params.Add("account", c.auth.Username)
+	if auth.Username != "" {
+		params.Add("account", auth.Username)
 	}
 
 	if service, ok := challenge.Parameters["service"]; ok && service != "" {
@@ -837,10 +988,13 @@ func (c *dockerClient) getBearerToken(ctx context.Context, challenge challenge,
 
 	authReq.URL.RawQuery = params.Encode()
 
-	if c.auth.Username != "" && c.auth.Password != "" {
-		authReq.SetBasicAuth(c.auth.Username, c.auth.Password)
+	if auth.Username != "" && auth.Password != "" {
+		authReq.SetBasicAuth(auth.Username, auth.Password)
 	}
 	authReq.Header.Add("User-Agent", c.userAgent)
+	if c.sys != nil {
+		addHeaders(authReq.Header, c.sys.DockerTraceHeaders)
+	}
 
 	logrus.Debugf("%s %s", authReq.Method, authReq.URL.Redacted())
 	res, err := c.client.Do(authReq)
@@ -866,6 +1020,7 @@ func newBearerTokenFromHTTPResponseBody(res *http.Response) (*bearerToken, error
 	var token struct {
 		Token          string    `json:"token"`
 		AccessToken    string    `json:"access_token"`
+		RefreshToken   string    `json:"refresh_token"`
 		ExpiresIn      int       `json:"expires_in"`
 		IssuedAt       time.Time `json:"issued_at"`
 		expirationTime time.Time
@@ -880,7 +1035,8 @@ func newBearerTokenFromHTTPResponseBody(res *http.Response) (*bearerToken, error
 	}
 
 	bt := &bearerToken{
-		token: token.Token,
+		token:        token.Token,
+		refreshToken: token.RefreshToken,
 	}
 	if bt.token == "" {
 		bt.token = token.AccessToken
@@ -905,13 +1061,22 @@ func (c *dockerClient) detectPropertiesHelper(ctx context.Context) error {
 	if c.sys != nil && c.sys.DockerInsecureSkipTLSVerify != types.OptionalBoolUndefined {
 		c.tlsClientConfig.InsecureSkipVerify = c.sys.DockerInsecureSkipTLSVerify == types.OptionalBoolTrue
 	}
+	if c.sys != nil && c.sys.DockerStrictTLS {
+		// Ignore also the insecure settings of mirrors, applied by callers after newDockerClient.
+		c.tlsClientConfig.InsecureSkipVerify = false
+	}
 	tr := tlsclientconfig.NewTransport()
 	tr.TLSClientConfig = c.tlsClientConfig
 	// if set DockerProxyURL explicitly, use the DockerProxyURL instead of system proxy
 	if c.sys != nil && c.sys.DockerProxyURL != nil {
 		tr.Proxy = http.ProxyURL(c.sys.DockerProxyURL)
 	}
-	c.client = &http.Client{Transport: tr}
+	configureConnectionPool(tr, c.sys)
+	c.client = &http.Client{Transport: tr, CheckRedirect: c.checkRedirect}
+	if c.sys != nil && c.sys.DockerStrictTLS {
+		c.client.Transport = strictTLSTransport{transport: tr}
+	}
+	c.backend = newRegistryBackend(c.sys, c.registry, c.client)
 
 	ping := func(scheme string) error {
 		pingURL, err := url.Parse(fmt.Sprintf(resolvedPingV2URL, scheme, c.registry))
@@ -943,6 +1108,51 @@ func (c *dockerClient) detectPropertiesHelper(ctx context.Context) error {
 	return err
 }
 
+// configureConnectionPool applies the connection pool and HTTP protocol options of sys to tr.
+func configureConnectionPool(tr *http.Transport, sys *types.SystemContext) {
+	if sys == nil {
+		return
+	}
+	if sys.DockerMaxConnsPerHost != 0 {
+		tr.MaxConnsPerHost = sys.DockerMaxConnsPerHost
+	}
+	switch {
+	case sys.DockerMaxIdleConnsPerHost != 0:
+		tr.MaxIdleConnsPerHost = sys.DockerMaxIdleConnsPerHost
+	case sys.DockerMaxConnsPerHost != 0:
+		tr.MaxIdleConnsPerHost = sys.DockerMaxConnsPerHost
+	}
+	if tr.MaxIdleConns != 0 && tr.MaxIdleConnsPerHost > tr.MaxIdleConns {
+		tr.MaxIdleConns = tr.MaxIdleConnsPerHost
+	}
+	if sys.DockerIdleConnTimeout != 0 {
+		tr.IdleConnTimeout = sys.DockerIdleConnTimeout
+	}
+	switch sys.DockerAttemptHTTP2 {
+	case types.OptionalBoolTrue:
+		tr.ForceAttemptHTTP2 = true
+	case types.OptionalBoolFalse:
+		// A non-nil empty map disables HTTP/2, per the net/http documentation.
+		tr.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+}
+
+// strictTLSTransport is a http.RoundTripper which refuses any request not using HTTPS, for SystemContext.DockerStrictTLS.
+// The TLS configuration of transport must verify certificates.
+type strictTLSTransport struct {
+	transport *http.Transport
+}
+
+func (t strictTLSTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "https" {
+		return nil, fmt.Errorf("refusing to connect to %s using %q, only HTTPS is allowed with DockerStrictTLS", req.URL.Host, req.URL.Scheme)
+	}
+	if t.transport.TLSClientConfig == nil || t.transport.TLSClientConfig.InsecureSkipVerify {
+		return nil, errors.New("Internal error: TLS verification is disabled with DockerStrictTLS")
+	}
+	return t.transport.RoundTrip(req)
+}
+
 // detectProperties detects various properties of the registry.
 // See the dockerClient documentation for members which are affected by this.
 func (c *dockerClient) detectProperties(ctx context.Context) error {
@@ -950,12 +1160,84 @@ func (c *dockerClient) detectProperties(ctx context.Context) error {
 	return c.detectPropertiesError
 }
 
+// useManifestCache returns true if processManifestCache may be used.
+func (c *dockerClient) useManifestCache() bool {
+	return c.sys == nil || !c.sys.DockerDisableManifestCache
+}
+
+// manifestCacheKey returns the processManifestCache key for (the repo of ref) + tagOrDigest.
+func (c *dockerClient) manifestCacheKey(ref dockerReference, tagOrDigest string) manifestCacheKey {
+	return manifestCacheKey{
+		scheme:      c.scheme,
+		registry:    c.registry,
+		repo:        reference.Path(ref.ref),
+		tagOrDigest: tagOrDigest,
+		accept:      strings.Join(c.requestedManifestMIMETypes(), ", "),
+	}
+}
+
+// requestedManifestMIMETypes returns the manifest MIME types to request from the registry, in order of preference.
+func (c *dockerClient) requestedManifestMIMETypes() []string {
+	if c.sys != nil && len(c.sys.DockerRequestedManifestMIMETypes) != 0 {
+		return c.sys.DockerRequestedManifestMIMETypes
+	}
+	return manifest.DefaultRequestedManifestMIMETypes
+}
+
+// manifestMIMETypeIsAcceptable returns true if a manifest with mimeType, as returned by the registry, is one of the requested types.
+func (c *dockerClient) manifestMIMETypeIsAcceptable(mimeType string) bool {
+	if c.sys == nil || len(c.sys.DockerRequestedManifestMIMETypes) == 0 {
+		return true // We don’t restrict MIME types by default, e.g. registries might not return any useful Content-Type.
+	}
+	normalized := manifest.NormalizedMIMEType(mimeType)
+	isSchema1 := func(mimeType string) bool {
+		return mimeType == manifest.DockerV2Schema1MediaType || mimeType == manifest.DockerV2Schema1SignedMediaType
+	}
+	return slices.ContainsFunc(c.sys.DockerRequestedManifestMIMETypes, func(requested string) bool {
+		return requested == normalized || (isSchema1(requested) && isSchema1(normalized))
+	})
+}
+
 // fetchManifest fetches a manifest for (the repo of ref) + tagOrDigest.
 // The caller is responsible for ensuring tagOrDigest uses the expected format.
 func (c *dockerClient) fetchManifest(ctx context.Context, ref dockerReference, tagOrDigest string) ([]byte, string, error) {
+	if err := c.detectProperties(ctx); err != nil {
+		return nil, "", err
+	}
+
 	path := fmt.Sprintf(manifestPath, reference.Path(ref.ref), tagOrDigest)
 	headers := map[string][]string{
-		"Accept": manifest.DefaultRequestedManifestMIMETypes,
+		"Accept": c.requestedManifestMIMETypes(),
+	}
+	useCache := c.useManifestCache()
+	cacheKey := c.manifestCacheKey(ref, tagOrDigest)
+	diskCache := newHTTPDiskCache(c.sys)
+	manifestDigest, digestErr := digest.Parse(tagOrDigest)
+	byDigest := digestErr == nil
+	if diskCache != nil {
+		if byDigest {
+			if m, ok := diskCache.getManifest(cacheKey, manifestDigest); ok && c.manifestMIMETypeIsAcceptable(m.MIMEType) {
+				logrus.Debugf("Using manifest %s in %s from the HTTP cache", tagOrDigest, ref.ref.Name())
+				return m.Manifest, m.MIMEType, nil
+			}
+		} else if tag, m, ok := diskCache.getTag(cacheKey); ok && time.Now().Before(tag.Expires) && c.manifestMIMETypeIsAcceptable(m.MIMEType) {
+			logrus.Debugf("Using fresh manifest %s in %s from the HTTP cache", tagOrDigest, ref.ref.Name())
+			return m.Manifest, m.MIMEType, nil
+		}
+	}
+	var cached manifestCacheEntry
+	haveCached := false
+	if useCache {
+		cached, haveCached = processManifestCache.get(cacheKey)
+	}
+	if !haveCached && diskCache != nil && !byDigest {
+		if tag, m, ok := diskCache.getTag(cacheKey); ok && tag.ETag != "" {
+			cached = manifestCacheEntry{key: cacheKey, etag: tag.ETag, manifest: m.Manifest, mimeType: m.MIMEType}
+			haveCached = true
+		}
+	}
+	if haveCached {
+		headers["If-None-Match"] = []string{cached.etag}
 	}
 	res, err := c.makeRequest(ctx, http.MethodGet, path, headers, nil, v2Auth, nil)
 	if err != nil {
@@ -963,7 +1245,24 @@ func (c *dockerClient) fetchManifest(ctx context.Context, ref dockerReference, t
 	}
 	logrus.Debugf("Content-Type from manifest GET is %q", res.Header.Get("Content-Type"))
 	defer res.Body.Close()
+	if haveCached && res.StatusCode == http.StatusNotModified {
+		logrus.Debugf("Manifest %s in %s not modified, using cached copy", tagOrDigest, ref.ref.Name())
+		if diskCache != nil && !byDigest {
+			diskCache.recordTagResponse(cacheKey, res.Header, cached.etag, cached.manifest, cached.mimeType)
+		}
+		return slices.Clone(cached.manifest), cached.mimeType, nil
+	}
 	if res.StatusCode != http.StatusOK {
+		if useCache {
+			processManifestCache.remove(cacheKey)
+		}
+		if diskCache != nil && !byDigest {
+			diskCache.removeTag(cacheKey)
+		}
+		if res.StatusCode == http.StatusNotAcceptable {
+			return nil, "", fmt.Errorf("reading manifest %s in %s: %w", tagOrDigest, ref.ref.Name(),
+				UnacceptableManifestMIMETypeError{Requested: slices.Clone(c.requestedManifestMIMETypes())})
+		}
 		return nil, "", fmt.Errorf("reading manifest %s in %s: %w", tagOrDigest, ref.ref.Name(), registryHTTPResponseToError(res))
 	}
 
@@ -971,13 +1270,41 @@ func (c *dockerClient) fetchManifest(ctx context.Context, ref dockerReference, t
 	if err != nil {
 		return nil, "", err
 	}
-	return manblob, simplifyContentType(res.Header.Get("Content-Type")), nil
+	mimeType := simplifyContentType(res.Header.Get("Content-Type"))
+	if !c.manifestMIMETypeIsAcceptable(mimeType) {
+		if useCache {
+			processManifestCache.remove(cacheKey)
+		}
+		return nil, "", fmt.Errorf("reading manifest %s in %s: %w", tagOrDigest, ref.ref.Name(),
+			UnacceptableManifestMIMETypeError{MIMEType: manifest.NormalizedMIMEType(mimeType), Requested: slices.Clone(c.requestedManifestMIMETypes())})
+	}
+	if useCache {
+		if etag := res.Header.Get("ETag"); etag != "" {
+			processManifestCache.put(cacheKey, etag, slices.Clone(manblob), mimeType)
+		} else {
+			processManifestCache.remove(cacheKey)
+		}
+	}
+	if diskCache != nil {
+		if byDigest {
+			// Only record manifests which match the digest, so that the cache can be used without revalidation.
+			if storable, _ := httpCacheControl(res.Header); storable {
+				if matches, err := manifest.MatchesDigest(manblob, manifestDigest); err == nil && matches {
+					diskCache.putManifest(cacheKey, manifestDigest, slices.Clone(manblob), mimeType)
+				}
+			}
+		} else {
+			diskCache.recordTagResponse(cacheKey, res.Header, res.Header.Get("ETag"), manblob, mimeType)
+		}
+	}
+	return manblob, mimeType, nil
 }
 
 // getExternalBlob returns the reader of the first available blob URL from urls, which must not be empty.
 // This function can return nil reader when no url is supported by this function. In this case, the caller
 // should fallback to fetch the non-external blob (i.e. pull from the registry).
-func (c *dockerClient) getExternalBlob(ctx context.Context, urls []string) (io.ReadCloser, int64, error) {
+// If origin is not nil, it is updated to record the URL the blob was read from, and the URLs which failed.
+func (c *dockerClient) getExternalBlob(ctx context.Context, urls []string, origin *private.ContentOrigin) (io.ReadCloser, int64, error) {
 	if len(urls) == 0 {
 		return nil, 0, errors.New("internal error: getExternalBlob called with no URLs")
 	}
@@ -993,11 +1320,13 @@ func (c *dockerClient) getExternalBlob(ctx context.Context, urls []string) (io.R
 		resp, err := c.makeRequestToResolvedURL(ctx, http.MethodGet, blobURL, nil, nil, -1, noAuth, nil)
 		if err != nil {
 			remoteErrors = append(remoteErrors, err)
+			recordEndpointFailure(origin, u, err)
 			continue
 		}
 		if resp.StatusCode != http.StatusOK {
 			err := fmt.Errorf("error fetching external blob from %q: %w", u, newUnexpectedHTTPStatusError(resp))
 			remoteErrors = append(remoteErrors, err)
+			recordEndpointFailure(origin, u, err)
 			logrus.Debug(err)
 			resp.Body.Close()
 			continue
@@ -1007,6 +1336,9 @@ func (c *dockerClient) getExternalBlob(ctx context.Context, urls []string) (io.R
 		if err != nil {
 			size = -1
 		}
+		if origin != nil {
+			origin.Endpoint = u
+		}
 		return resp.Body, size, nil
 	}
 	if remoteErrors == nil {
@@ -1015,6 +1347,13 @@ func (c *dockerClient) getExternalBlob(ctx context.Context, urls []string) (io.R
 	return nil, 0, fmt.Errorf("failed fetching external blob from all urls: %w", multierr.Format("", ", ", "", remoteErrors))
 }
 
+// recordEndpointFailure records, if origin is not nil, that reading content from endpoint failed with err.
+func recordEndpointFailure(origin *private.ContentOrigin, endpoint string, err error) {
+	if origin != nil {
+		origin.FailedEndpoints = append(origin.FailedEndpoints, private.EndpointFailure{Endpoint: endpoint, Err: err})
+	}
+}
+
 func getBlobSize(resp *http.Response) (int64, error) {
 	hdrs := resp.Header.Values("Content-Length")
 	if len(hdrs) == 0 {
@@ -1034,37 +1373,79 @@ func getBlobSize(resp *http.Response) (int64, error) {
 // getBlob returns a stream for the specified blob in ref, and the blob’s size (or -1 if unknown).
 // The Digest field in BlobInfo is guaranteed to be provided, Size may be -1 and MediaType may be optionally provided.
 // May update BlobInfoCache, preferably after it knows for certain that a blob truly exists at a specific location.
-func (c *dockerClient) getBlob(ctx context.Context, ref dockerReference, info types.BlobInfo, cache types.BlobInfoCache) (io.ReadCloser, int64, error) {
+// If origin is not nil, it is updated to record where the blob was read from, and which endpoints failed.
+func (c *dockerClient) getBlob(ctx context.Context, ref dockerReference, info types.BlobInfo, cache types.BlobInfoCache, origin *private.ContentOrigin) (io.ReadCloser, int64, error) {
 	if len(info.URLs) != 0 {
-		r, s, err := c.getExternalBlob(ctx, info.URLs)
+		r, s, err := c.getExternalBlob(ctx, info.URLs, origin)
 		if err != nil {
 			return nil, 0, err
 		} else if r != nil {
 			return r, s, nil
 		}
 	}
+	if origin != nil {
+		origin.Endpoint = ref.ref.Name()
+	}
 
 	if err := info.Digest.Validate(); err != nil { // Make sure info.Digest.String() does not contain any unexpected characters
 		return nil, 0, err
 	}
+	diskCache := newHTTPDiskCache(c.sys)
+	diskCacheKey := c.manifestCacheKey(ref, info.Digest.String())
+	if diskCache != nil && info.Size >= 0 && info.Size <= httpDiskCacheMaxBlobSize {
+		if data, ok := diskCache.getBlob(diskCacheKey, info.Digest); ok {
+			logrus.Debugf("Using blob %s in %s from the HTTP cache", info.Digest.String(), ref.ref.Name())
+			cache.RecordKnownLocation(ref.Transport(), bicTransportScope(ref), info.Digest, newBICLocationReference(ref))
+			return io.NopCloser(bytes.NewReader(data)), int64(len(data)), nil
+		}
+	}
 	path := fmt.Sprintf(blobsPath, reference.Path(ref.ref), info.Digest.String())
 	logrus.Debugf("Downloading %s", path)
 	res, err := c.makeRequest(ctx, http.MethodGet, path, nil, nil, v2Auth, nil)
 	if err != nil {
 		return nil, 0, err
 	}
+	redirectURL := c.redirectedURL(res)
+	if redirectURL != nil && isRetryableRedirectedBlobStatus(res.StatusCode) {
+		// The object storage might have been temporarily unavailable, or the pre-signed URL might have expired
+		// before we used it; ask the registry for a new redirect, once.
+		logrus.Debugf("Fetching blob %s from %s failed with status %d, retrying via the registry", info.Digest.String(), redirectURL.Host, res.StatusCode)
+		res.Body.Close()
+		res, err = c.makeRequest(ctx, http.MethodGet, path, nil, nil, v2Auth, nil)
+		if err != nil {
+			return nil, 0, err
+		}
+		redirectURL = c.redirectedURL(res)
+	}
 	if res.StatusCode != http.StatusOK {
 		err := registryHTTPResponseToError(res)
 		res.Body.Close()
+		if redirectURL != nil {
+			err = BlobRedirectError{Host: redirectURL.Host, StatusCode: res.StatusCode, Err: err}
+		} else if c.sys != nil && c.sys.DockerFollowBlobLocationHints {
+			if hintedRepo := blobLocationHint(res.Header, info.Digest); hintedRepo != nil {
+				return c.getBlobFromLocationHint(ctx, ref, hintedRepo, info, cache, origin)
+			}
+		}
 		return nil, 0, fmt.Errorf("fetching blob: %w", err)
 	}
+	if redirectURL != nil {
+		logrus.Debugf("Blob %s was redirected to %s", info.Digest.String(), redirectURL.Host)
+	}
 	cache.RecordKnownLocation(ref.Transport(), bicTransportScope(ref), info.Digest, newBICLocationReference(ref))
 	blobSize, err := getBlobSize(res)
 	if err != nil {
 		blobSize = -1
 	}
 
-	reconnectingReader, err := newBodyReader(ctx, c, path, res.Body)
+	if diskCache != nil && blobSize >= 0 && blobSize <= httpDiskCacheMaxBlobSize {
+		r, err := diskCache.readCacheableBlob(diskCacheKey, info.Digest, res.Header, res.Body)
+		if err != nil {
+			return nil, 0, err
+		}
+		return r, blobSize, nil
+	}
+	reconnectingReader, err := newBodyReader(ctx, c, path, redirectURL, res.Body)
 	if err != nil {
 		res.Body.Close()
 		return nil, 0, err
@@ -1085,7 +1466,7 @@ func (c *dockerClient) getOCIDescriptorContents(ctx context.Context, ref dockerR
 		return nil, fmt.Errorf("invalid digest %q: unsupported digest algorithm %q", desc.Digest.String(), digestAlgorithm.String())
 	}
 
-	reader, _, err := c.getBlob(ctx, ref, manifest.BlobInfoFromOCI1Descriptor(desc), cache)
+	reader, _, err := c.getBlob(ctx, ref, manifest.BlobInfoFromOCI1Descriptor(desc), cache, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -1135,6 +1516,12 @@ func (c *dockerClient) getSigstoreAttachmentManifest(ctx context.Context, ref do
 	if err != nil {
 		return nil, err
 	}
+	return c.getSigstoreAttachmentManifestForTag(ctx, ref, tag)
+}
+
+// getSigstoreAttachmentManifestForTag loads and parses a manifest of sigstore attachments with tag in ref.
+// It returns (nil, nil) if the manifest does not exist.
+func (c *dockerClient) getSigstoreAttachmentManifestForTag(ctx context.Context, ref dockerReference, tag string) (*manifest.OCI1, error) {
 	sigstoreRef, err := reference.WithTag(reference.TrimNamed(ref.ref), tag)
 	if err != nil {
 		return nil, err
@@ -1200,6 +1587,14 @@ func sigstoreAttachmentTag(d digest.Digest) (string, error) {
 	return strings.Replace(d.String(), ":", "-", 1) + ".sig", nil
 }
 
+// sigstoreAttestationTag returns a tag of sigstore attestation attachments for the specified digest.
+func sigstoreAttestationTag(d digest.Digest) (string, error) {
+	if err := d.Validate(); err != nil { // Make sure d.String() doesn’t contain any unexpected characters
+		return "", err
+	}
+	return strings.Replace(d.String(), ":", "-", 1) + ".att", nil
+}
+
 // Close removes resources associated with an initialized dockerClient, if any.
 func (c *dockerClient) Close() error {
 	if c.client != nil {
@@ -1207,3 +1602,116 @@ func (c *dockerClient) Close() error {
 	}
 	return nil
 }
+
+const (
+	// endpointLatencyWeightPercent is the weight of a new sample in the moving average of an endpoint’s latency.
+	endpointLatencyWeightPercent = 30
+	// endpointFailureBackoff is how long an endpoint is tried only after other endpoints, after a failure.
+	// It doubles with every consecutive failure, up to endpointFailureMaxBackoff.
+	endpointFailureBackoff    = 30 * time.Second
+	endpointFailureMaxBackoff = 10 * time.Minute
+)
+
+// endpointHealth is what an endpointHealthTracker knows about a single endpoint.
+type endpointHealth struct {
+	latency             time.Duration // Moving average of the time to access an image, or 0 if unknown
+	consecutiveFailures int
+	lastFailure         time.Time // Only valid if consecutiveFailures > 0
+}
+
+// endpointHealthTracker records the latency and recent failures of registry endpoints within a process,
+// so that mirrors which work, and respond quickly, can be tried first.
+type endpointHealthTracker struct {
+	mutex     sync.Mutex
+	endpoints map[string]*endpointHealth // Keyed by sysregistriesv2.Endpoint.Location
+	now       func() time.Time           // time.Now, can be replaced in tests
+}
+
+// processEndpointHealth is shared by all image sources in this process.
+var processEndpointHealth = newEndpointHealthTracker()
+
+// newEndpointHealthTracker returns an endpointHealthTracker with no recorded data.
+func newEndpointHealthTracker() *endpointHealthTracker {
+	return &endpointHealthTracker{
+		endpoints: map[string]*endpointHealth{},
+		now:       time.Now,
+	}
+}
+
+// healthLocked returns the record for location, creating it if necessary. It must be called with t.mutex held.
+func (t *endpointHealthTracker) healthLocked(location string) *endpointHealth {
+	h, ok := t.endpoints[location]
+	if !ok {
+		h = &endpointHealth{}
+		t.endpoints[location] = h
+	}
+	return h
+}
+
+// recordSuccess records that accessing an image at location succeeded, and took latency.
+func (t *endpointHealthTracker) recordSuccess(location string, latency time.Duration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	h := t.healthLocked(location)
+	if h.latency == 0 {
+		h.latency = latency
+	} else {
+		h.latency = (endpointLatencyWeightPercent*latency + (100-endpointLatencyWeightPercent)*h.latency) / 100
+	}
+	h.consecutiveFailures = 0
+}
+
+// recordFailure records that accessing an image at location failed.
+func (t *endpointHealthTracker) recordFailure(location string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	h := t.healthLocked(location)
+	h.consecutiveFailures++
+	h.lastFailure = t.now()
+}
+
+// backingOffLocked returns true if the endpoint with h has failed recently enough to be tried only after other endpoints.
+// It must be called with t.mutex held.
+func (t *endpointHealthTracker) backingOffLocked(h *endpointHealth) bool {
+	if h.consecutiveFailures == 0 {
+		return false
+	}
+	backoff := endpointFailureBackoff << min(h.consecutiveFailures-1, 16)
+	return t.now().Before(h.lastFailure.Add(min(backoff, endpointFailureMaxBackoff)))
+}
+
+// orderPullSources returns pullSources, as returned by sysregistriesv2.Registry.PullSourcesFromReference, with the mirrors
+// reordered so that mirrors which did not fail recently are tried first, and among those, the ones with the lowest latency.
+// Mirrors with no recorded latency are tried in the configured order before those with a known latency, so that they are
+// eventually measured as well. The primary location is always the last item, as in the input.
+func (t *endpointHealthTracker) orderPullSources(pullSources []sysregistriesv2.PullSource) []sysregistriesv2.PullSource {
+	if len(pullSources) < 3 { // At most one mirror
+		return pullSources
+	}
+	type sortKey struct {
+		backingOff bool
+		latency    time.Duration
+	}
+	t.mutex.Lock()
+	keys := map[string]sortKey{}
+	for _, ps := range pullSources {
+		if h, ok := t.endpoints[ps.Endpoint.Location]; ok {
+			keys[ps.Endpoint.Location] = sortKey{backingOff: t.backingOffLocked(h), latency: h.latency}
+		}
+	}
+	t.mutex.Unlock()
+
+	res := slices.Clone(pullSources)
+	mirrors := res[:len(res)-1]
+	slices.SortStableFunc(mirrors, func(a, b sysregistriesv2.PullSource) int {
+		ka, kb := keys[a.Endpoint.Location], keys[b.Endpoint.Location]
+		if ka.backingOff != kb.backingOff {
+			if ka.backingOff {
+				return 1
+			}
+			return -1
+		}
+		return cmp.Compare(ka.latency, kb.latency)
+	})
+	return res
+}
@@ -4,18 +4,27 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/pem"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/containers/image/v5/internal/useragent"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/pkg/blobinfocache/none"
+	"github.com/containers/image/v5/pkg/sysregistriesv2"
+	"github.com/containers/image/v5/pkg/tlsclientconfig"
 	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -170,6 +179,8 @@ func TestUserAgent(t *testing.T) {
 		// {nil, defaultUA},
 		{&types.SystemContext{}, useragent.DefaultUserAgent},
 		{&types.SystemContext{DockerRegistryUserAgent: sentinelUA}, sentinelUA},
+		{&types.SystemContext{DockerRegistryUserAgentProducts: []string{"tool/2.0", "lib/1.1"}}, "tool/2.0 lib/1.1 " + useragent.DefaultUserAgent},
+		{&types.SystemContext{DockerRegistryUserAgent: sentinelUA, DockerRegistryUserAgentProducts: []string{"tool/2.0"}}, "tool/2.0 " + sentinelUA},
 	} {
 		// For this test against localhost, we don't care.
 		tc.sys.DockerInsecureSkipTLSVerify = types.OptionalBoolTrue
@@ -183,6 +194,339 @@ func TestUserAgent(t *testing.T) {
 	}
 }
 
+type stubRequestSigner struct{}
+
+func (stubRequestSigner) SignRequest(req *http.Request) error {
+	req.Header.Set("Authorization", "signed "+req.Method+" "+req.URL.Path)
+	return nil
+}
+
+func TestRequestSigner(t *testing.T) {
+	var signedRequests atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "signed "+r.Method+" "+r.URL.Path {
+			w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		signedRequests.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer s.Close()
+	registry := strings.TrimPrefix(s.URL, "http://")
+
+	sys := &types.SystemContext{
+		DockerInsecureSkipTLSVerify: types.OptionalBoolTrue,
+		DockerRequestSigner:         stubRequestSigner{},
+	}
+	err := CheckAuth(context.Background(), sys, "user", "password", registry)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), signedRequests.Load()) // The ping, and the authenticated request
+
+	// Without the signer, the basic credentials are rejected.
+	sys.DockerRequestSigner = nil
+	err = CheckAuth(context.Background(), sys, "user", "password", registry)
+	var unauthorized ErrUnauthorizedForCredentials
+	assert.ErrorAs(t, err, &unauthorized)
+}
+
+func TestStrictTLS(t *testing.T) {
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer plain.Close()
+	plainRegistry := strings.TrimPrefix(plain.URL, "http://")
+	redirectToPlain := false
+	secure := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if redirectToPlain && r.URL.Path != "/v2/" {
+			http.Redirect(w, r, plain.URL+r.URL.Path, http.StatusTemporaryRedirect)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer secure.Close()
+	secureRegistry := strings.TrimPrefix(secure.URL, "https://")
+
+	tmpDir := t.TempDir()
+	registriesConf := filepath.Join(tmpDir, "registries.conf")
+	err := os.WriteFile(registriesConf, []byte(fmt.Sprintf("[[registry]]\nlocation = %q\ninsecure = true\n", plainRegistry)), 0o600)
+	require.NoError(t, err)
+	certDir := filepath.Join(tmpDir, "certs")
+	err = os.Mkdir(certDir, 0o700)
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(certDir, "ca.crt"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: secure.Certificate().Raw}), 0o600)
+	require.NoError(t, err)
+
+	// A registry configured as insecure in registries.conf can be accessed over HTTP, unless DockerStrictTLS is set.
+	sys := &types.SystemContext{SystemRegistriesConfPath: registriesConf}
+	err = CheckAuth(context.Background(), sys, "", "", plainRegistry)
+	require.NoError(t, err)
+	sys.DockerStrictTLS = true
+	err = CheckAuth(context.Background(), sys, "", "", plainRegistry)
+	assert.Error(t, err)
+
+	// DockerStrictTLS can not be combined with DockerInsecureSkipTLSVerify.
+	sys.DockerInsecureSkipTLSVerify = types.OptionalBoolTrue
+	err = CheckAuth(context.Background(), sys, "", "", plainRegistry)
+	assert.ErrorContains(t, err, "DockerStrictTLS")
+
+	// Verified HTTPS connections work, but redirects to HTTP are refused.
+	sys = &types.SystemContext{SystemRegistriesConfPath: registriesConf, DockerCertPath: certDir, DockerStrictTLS: true}
+	client, err := newDockerClient(sys, secureRegistry, secureRegistry)
+	require.NoError(t, err)
+	defer client.Close()
+	res, err := client.makeRequest(context.Background(), http.MethodGet, "/v2/repo/blobs/sha256:"+strings.Repeat("0", 64), nil, nil, noAuth, nil)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	redirectToPlain = true
+	_, err = client.makeRequest(context.Background(), http.MethodGet, "/v2/repo/blobs/sha256:"+strings.Repeat("0", 64), nil, nil, noAuth, nil)
+	assert.ErrorContains(t, err, "only HTTPS is allowed")
+}
+
+type stubRateLimiter struct {
+	registries *[]string
+	err        error
+}
+
+func (l stubRateLimiter) Wait(ctx context.Context, registry, repository string) error {
+	*l.registries = append(*l.registries, registry)
+	return l.err
+}
+
+func TestRequestRateLimiter(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer s.Close()
+	registry := strings.TrimPrefix(s.URL, "http://")
+
+	registries := []string{}
+	sys := &types.SystemContext{
+		DockerInsecureSkipTLSVerify: types.OptionalBoolTrue,
+		DockerRequestRateLimiter:    stubRateLimiter{registries: &registries},
+	}
+	err := CheckAuth(context.Background(), sys, "", "", registry)
+	require.NoError(t, err)
+	require.NotEmpty(t, registries)
+	for _, r := range registries {
+		assert.Equal(t, registry, r)
+	}
+
+	// Rate limiter failures abort the request.
+	sys.DockerRequestRateLimiter = stubRateLimiter{registries: &registries, err: context.Canceled}
+	err = CheckAuth(context.Background(), sys, "", "", registry)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+// stubRegistryBackend is a types.DockerRegistryBackend serving requests using handler, without any network access.
+type stubRegistryBackend struct {
+	registries *[]string
+	handler    http.Handler
+}
+
+func (b stubRegistryBackend) Do(registry string, req *http.Request) (*http.Response, error) {
+	*b.registries = append(*b.registries, registry)
+	rec := httptest.NewRecorder()
+	b.handler.ServeHTTP(rec, req)
+	return rec.Result(), nil
+}
+
+func TestRegistryBackend(t *testing.T) {
+	manifestBody := []byte(`{"schemaVersion":2}`)
+	registries := []string{}
+	sys := &types.SystemContext{
+		DockerAuthConfig: &types.DockerAuthConfig{Username: "user", Password: "password"},
+		DockerRegistryBackend: stubRegistryBackend{
+			registries: &registries,
+			handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Empty(t, r.Header.Values("Authorization"))
+				switch {
+				case r.Method == http.MethodGet && r.URL.Path == "/v2/":
+					w.WriteHeader(http.StatusOK)
+				case r.Method == http.MethodGet && r.URL.Path == "/v2/repo/manifests/tag":
+					w.Header().Set("Content-Type", imgspecv1.MediaTypeImageManifest)
+					_, err := w.Write(manifestBody)
+					assert.NoError(t, err)
+				default:
+					assert.Failf(t, "Unexpected request", "%v %v", r.Method, r.URL.Path)
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}),
+		},
+	}
+	// The registry host does not need to exist, all requests are handled by the backend.
+	ref, err := ParseReference("//registry.invalid/repo:tag")
+	require.NoError(t, err)
+	dr, ok := ref.(dockerReference)
+	require.True(t, ok)
+	client, err := newDockerClient(sys, "registry.invalid", "registry.invalid")
+	require.NoError(t, err)
+	err = client.detectProperties(context.Background())
+	require.NoError(t, err)
+	m, mimeType, err := client.fetchManifest(context.Background(), dr, "tag")
+	require.NoError(t, err)
+	assert.Equal(t, manifestBody, m)
+	assert.Equal(t, imgspecv1.MediaTypeImageManifest, mimeType)
+	require.NotEmpty(t, registries)
+	for _, r := range registries {
+		assert.Equal(t, "registry.invalid", r)
+	}
+}
+
+func TestGetBlobRedirect(t *testing.T) {
+	blob := []byte("blob contents")
+	blobDigest := digest.FromBytes(blob)
+	var storageFailures, storageRequests, blobRequests atomic.Int32
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		storageRequests.Add(1)
+		assert.Empty(t, r.Header.Get("Authorization"))
+		if storageFailures.Load() > 0 {
+			storageFailures.Add(-1)
+			w.WriteHeader(http.StatusForbidden)
+			_, err := w.Write([]byte("<Error><Code>AccessDenied</Code><Message>Request has expired</Message></Error>"))
+			assert.NoError(t, err)
+			return
+		}
+		_, err := w.Write(blob)
+		assert.NoError(t, err)
+	}))
+	defer storage.Close()
+	storageURL, err := url.Parse(storage.URL)
+	require.NoError(t, err)
+
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "user" || password != "password" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodGet && r.URL.Path == "/v2/repo/manifests/latest":
+			w.WriteHeader(http.StatusOK) // Empty body is good enough for this test
+		case r.Method == http.MethodGet && r.URL.Path == "/v2/repo/blobs/"+blobDigest.String():
+			blobRequests.Add(1)
+			http.Redirect(w, r, storage.URL+"/bucket/blob?X-Signature=secret", http.StatusTemporaryRedirect)
+		default:
+			assert.Failf(t, "Unexpected request", "%v %v", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer registry.Close()
+	registryURL, err := url.Parse(registry.URL)
+	require.NoError(t, err)
+
+	ref, err := ParseReference("//" + registryURL.Host + "/repo:latest")
+	require.NoError(t, err)
+	src, err := ref.NewImageSource(context.Background(), &types.SystemContext{
+		DockerInsecureSkipTLSVerify: types.OptionalBoolTrue,
+		DockerAuthConfig:            &types.DockerAuthConfig{Username: "user", Password: "password"},
+	})
+	require.NoError(t, err)
+	defer src.Close()
+
+	for _, c := range []struct {
+		name                    string
+		storageFailures         int32
+		expectedBlobRequests    int32
+		expectedStorageRequests int32
+		success                 bool
+	}{
+		{"success", 0, 1, 1, true},
+		{"retried after an expired redirect", 1, 2, 2, true},
+		{"redirect target failure", 2, 2, 2, false},
+	} {
+		storageFailures.Store(c.storageFailures)
+		blobRequests.Store(0)
+		storageRequests.Store(0)
+		reader, _, err := src.GetBlob(context.Background(), types.BlobInfo{Digest: blobDigest, Size: -1}, none.NoCache)
+		if c.success {
+			require.NoError(t, err, c.name)
+			contents, err := io.ReadAll(reader)
+			require.NoError(t, err, c.name)
+			assert.Equal(t, blob, contents, c.name)
+			reader.Close()
+		} else {
+			var redirectErr BlobRedirectError
+			require.ErrorAs(t, err, &redirectErr, c.name)
+			assert.Equal(t, storageURL.Host, redirectErr.Host, c.name)
+			assert.Equal(t, http.StatusForbidden, redirectErr.StatusCode, c.name)
+			assert.ErrorContains(t, err, "AccessDenied", c.name)
+		}
+		assert.Equal(t, c.expectedBlobRequests, blobRequests.Load(), c.name)
+		assert.Equal(t, c.expectedStorageRequests, storageRequests.Load(), c.name)
+	}
+}
+
+func TestExtraHeaders(t *testing.T) {
+	blob := []byte("blob contents")
+	blobDigest := digest.FromBytes(blob)
+	var storageRequests, tokenRequests atomic.Int32
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		storageRequests.Add(1)
+		assert.Equal(t, []string{"trace-1"}, r.Header.Values("X-Correlation-Id"))
+		assert.Empty(t, r.Header.Values("X-Tenant-Token"))
+		assert.Equal(t, []string{"shared"}, r.Header.Values("X-Shared"))
+		_, err := w.Write(blob)
+		assert.NoError(t, err)
+	}))
+	defer storage.Close()
+
+	var registry *httptest.Server
+	registry = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, []string{"trace-1"}, r.Header.Values("X-Correlation-Id"))
+		if r.URL.Path == "/token" {
+			tokenRequests.Add(1)
+			assert.Empty(t, r.Header.Values("X-Tenant-Token"))
+			_, err := w.Write([]byte(`{"token":"headers-token","expires_in":300}`))
+			assert.NoError(t, err)
+			return
+		}
+		assert.Equal(t, []string{"tenant"}, r.Header.Values("X-Tenant-Token"))
+		assert.ElementsMatch(t, []string{"shared", "shared"}, r.Header.Values("X-Shared"))
+		if r.Header.Get("Authorization") != "Bearer headers-token" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+registry.URL+`/token",service="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.URL.Path == "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case r.URL.Path == "/v2/repo/manifests/latest":
+			w.WriteHeader(http.StatusOK) // Empty body is good enough for this test
+		case r.URL.Path == "/v2/repo/blobs/"+blobDigest.String():
+			http.Redirect(w, r, storage.URL+"/bucket/blob", http.StatusTemporaryRedirect)
+		default:
+			assert.Failf(t, "Unexpected request", "%v %v", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer registry.Close()
+	registryURL, err := url.Parse(registry.URL)
+	require.NoError(t, err)
+
+	ref, err := ParseReference("//" + registryURL.Host + "/repo:latest")
+	require.NoError(t, err)
+	src, err := ref.NewImageSource(context.Background(), &types.SystemContext{
+		DockerInsecureSkipTLSVerify:   types.OptionalBoolTrue,
+		DockerDisableSharedTokenCache: true,
+		DockerRegistryHeaders:         http.Header{"X-Tenant-Token": {"tenant"}, "X-Shared": {"shared"}},
+		DockerTraceHeaders:            http.Header{"X-Correlation-Id": {"trace-1"}, "X-Shared": {"shared"}},
+	})
+	require.NoError(t, err)
+	defer src.Close()
+	reader, _, err := src.GetBlob(context.Background(), types.BlobInfo{Digest: blobDigest, Size: -1}, none.NoCache)
+	require.NoError(t, err)
+	contents, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	reader.Close()
+	assert.Equal(t, blob, contents)
+	assert.Equal(t, int32(1), storageRequests.Load())
+	assert.NotZero(t, tokenRequests.Load())
+}
+
 var registrySuseComResp = http.Response{
 	Status:     "401 Unauthorized",
 	StatusCode: http.StatusUnauthorized,
@@ -442,3 +786,184 @@ func TestIsManifestUnknownError(t *testing.T) {
 		assert.True(t, res, "%s: %#v", c.name, err)
 	}
 }
+
+func TestFetchManifestRequestedMIMETypes(t *testing.T) {
+	manifestBody := []byte(`{"schemaVersion":1}`)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v2/":
+			rw.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodGet && r.URL.Path == "/v2/repo/manifests/schema1":
+			rw.Header().Set("Content-Type", "application/json")
+			_, err := rw.Write(manifestBody)
+			assert.NoError(t, err)
+		case r.Method == http.MethodGet && r.URL.Path == "/v2/repo/manifests/accept":
+			rw.Header().Set("Content-Type", r.Header.Values("Accept")[0])
+			_, err := rw.Write(manifestBody)
+			assert.NoError(t, err)
+		case r.Method == http.MethodGet && r.URL.Path == "/v2/repo/manifests/not-acceptable":
+			rw.WriteHeader(http.StatusNotAcceptable)
+		default:
+			assert.Failf(t, "Unexpected request", "%v %v", r.Method, r.URL.Path)
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	registryURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	ref, err := ParseReference("//" + registryURL.Host + "/repo:latest")
+	require.NoError(t, err)
+	dr, ok := ref.(dockerReference)
+	require.True(t, ok)
+
+	for _, c := range []struct {
+		requested   []string
+		tag         string
+		expectedErr *UnacceptableManifestMIMETypeError
+		expected    string
+	}{
+		// By default, anything is accepted
+		{nil, "schema1", nil, "application/json"},
+		{nil, "accept", nil, manifest.DefaultRequestedManifestMIMETypes[0]},
+		// The Accept header follows the requested order
+		{[]string{imgspecv1.MediaTypeImageIndex, imgspecv1.MediaTypeImageManifest}, "accept", nil, imgspecv1.MediaTypeImageIndex},
+		// Schema1 is refused unless requested; either of the schema1 MIME types is sufficient
+		{
+			[]string{imgspecv1.MediaTypeImageManifest}, "schema1",
+			&UnacceptableManifestMIMETypeError{MIMEType: manifest.DockerV2Schema1SignedMediaType, Requested: []string{imgspecv1.MediaTypeImageManifest}}, "",
+		},
+		{[]string{manifest.DockerV2Schema1MediaType}, "schema1", nil, "application/json"},
+		// The registry refuses all requested types
+		{
+			[]string{imgspecv1.MediaTypeImageManifest}, "not-acceptable",
+			&UnacceptableManifestMIMETypeError{Requested: []string{imgspecv1.MediaTypeImageManifest}}, "",
+		},
+	} {
+		client, err := newDockerClient(&types.SystemContext{
+			DockerPerHostCertDirPath:         "/this/does/not/exist",
+			DockerInsecureSkipTLSVerify:      types.OptionalBoolTrue,
+			DockerRequestedManifestMIMETypes: c.requested,
+		}, registryURL.Host, registryURL.Host)
+		require.NoError(t, err)
+		m, mimeType, err := client.fetchManifest(context.Background(), dr, c.tag)
+		if c.expectedErr != nil {
+			var e UnacceptableManifestMIMETypeError
+			require.ErrorAs(t, err, &e, "%#v", c)
+			assert.Equal(t, *c.expectedErr, e)
+		} else {
+			require.NoError(t, err, "%#v", c)
+			assert.Equal(t, manifestBody, m)
+			assert.Equal(t, c.expected, mimeType)
+		}
+		client.Close()
+	}
+}
+
+func TestEndpointHealthTrackerOrderPullSources(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker := newEndpointHealthTracker()
+	tracker.now = func() time.Time { return now }
+
+	pullSources := []sysregistriesv2.PullSource{}
+	for _, location := range []string{"mirror1.example.com", "mirror2.example.com", "mirror3.example.com", "primary.example.com"} {
+		pullSources = append(pullSources, sysregistriesv2.PullSource{Endpoint: sysregistriesv2.Endpoint{Location: location}})
+	}
+	order := func() []string {
+		res := []string{}
+		for _, ps := range tracker.orderPullSources(pullSources) {
+			res = append(res, ps.Endpoint.Location)
+		}
+		return res
+	}
+
+	// Nothing is known: the configured order is used.
+	assert.Equal(t, []string{"mirror1.example.com", "mirror2.example.com", "mirror3.example.com", "primary.example.com"}, order())
+
+	// Mirrors with a known latency are ordered by latency, after mirrors with no data.
+	tracker.recordSuccess("mirror1.example.com", 3*time.Second)
+	tracker.recordSuccess("mirror3.example.com", time.Second)
+	assert.Equal(t, []string{"mirror2.example.com", "mirror3.example.com", "mirror1.example.com", "primary.example.com"}, order())
+	// Latency is a moving average.
+	tracker.recordSuccess("mirror1.example.com", 0)
+	assert.Equal(t, 2100*time.Millisecond, tracker.endpoints["mirror1.example.com"].latency)
+
+	// Recently failing mirrors are tried after other mirrors; the primary location is always tried last.
+	tracker.recordSuccess("mirror2.example.com", 2*time.Second)
+	tracker.recordFailure("mirror3.example.com")
+	tracker.recordFailure("primary.example.com")
+	assert.Equal(t, []string{"mirror2.example.com", "mirror1.example.com", "mirror3.example.com", "primary.example.com"}, order())
+	// … until the backoff period expires.
+	now = now.Add(endpointFailureBackoff + time.Second)
+	assert.Equal(t, []string{"mirror3.example.com", "mirror2.example.com", "mirror1.example.com", "primary.example.com"}, order())
+	// Consecutive failures increase the backoff period, up to a limit.
+	for range 10 {
+		tracker.recordFailure("mirror3.example.com")
+	}
+	now = now.Add(endpointFailureMaxBackoff - time.Second)
+	assert.Equal(t, []string{"mirror2.example.com", "mirror1.example.com", "mirror3.example.com", "primary.example.com"}, order())
+	now = now.Add(2 * time.Second)
+	assert.Equal(t, []string{"mirror3.example.com", "mirror2.example.com", "mirror1.example.com", "primary.example.com"}, order())
+	// A success resets the failure count.
+	tracker.recordSuccess("mirror3.example.com", time.Second)
+	tracker.recordFailure("mirror3.example.com")
+	now = now.Add(endpointFailureBackoff + time.Second)
+	assert.Equal(t, []string{"mirror3.example.com", "mirror2.example.com", "mirror1.example.com", "primary.example.com"}, order())
+
+	// The input is not modified.
+	assert.Equal(t, "mirror1.example.com", pullSources[0].Endpoint.Location)
+	// With at most one mirror, there is nothing to reorder.
+	assert.Equal(t, pullSources[2:], tracker.orderPullSources(pullSources[2:]))
+}
+
+func TestConfigureConnectionPool(t *testing.T) {
+	defaults := tlsclientconfig.NewTransport()
+
+	tr := tlsclientconfig.NewTransport()
+	configureConnectionPool(tr, nil)
+	assert.Equal(t, defaults.MaxConnsPerHost, tr.MaxConnsPerHost)
+	assert.Equal(t, defaults.MaxIdleConnsPerHost, tr.MaxIdleConnsPerHost)
+	assert.Equal(t, defaults.IdleConnTimeout, tr.IdleConnTimeout)
+	assert.False(t, tr.ForceAttemptHTTP2)
+	assert.Nil(t, tr.TLSNextProto)
+
+	tr = tlsclientconfig.NewTransport()
+	configureConnectionPool(tr, &types.SystemContext{DockerMaxConnsPerHost: 16, DockerIdleConnTimeout: time.Minute})
+	assert.Equal(t, 16, tr.MaxConnsPerHost)
+	assert.Equal(t, 16, tr.MaxIdleConnsPerHost)
+	assert.Equal(t, defaults.MaxIdleConns, tr.MaxIdleConns)
+	assert.Equal(t, time.Minute, tr.IdleConnTimeout)
+
+	tr = tlsclientconfig.NewTransport()
+	configureConnectionPool(tr, &types.SystemContext{DockerMaxConnsPerHost: 16, DockerMaxIdleConnsPerHost: 200})
+	assert.Equal(t, 16, tr.MaxConnsPerHost)
+	assert.Equal(t, 200, tr.MaxIdleConnsPerHost)
+	assert.Equal(t, 200, tr.MaxIdleConns)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(r.Proto))
+		assert.NoError(t, err)
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+	for _, c := range []struct {
+		attemptHTTP2 types.OptionalBool
+		expected     string
+	}{
+		{types.OptionalBoolUndefined, "HTTP/1.1"},
+		{types.OptionalBoolTrue, "HTTP/2.0"},
+		{types.OptionalBoolFalse, "HTTP/1.1"},
+	} {
+		tr := tlsclientconfig.NewTransport()
+		tr.TLSClientConfig = server.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+		configureConnectionPool(tr, &types.SystemContext{DockerAttemptHTTP2: c.attemptHTTP2})
+		client := &http.Client{Transport: tr}
+		resp, err := client.Get(server.URL)
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err)
+		assert.Equal(t, c.expected, string(body), c.attemptHTTP2)
+		tr.CloseIdleConnections()
+	}
+}
@@ -6,8 +6,6 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"net/url"
-	"strings"
 
 	"github.com/containers/image/v5/docker/reference"
 	"github.com/containers/image/v5/internal/image"
@@ -15,6 +13,7 @@ import (
 	"github.com/containers/image/v5/types"
 	"github.com/opencontainers/go-digest"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
 )
 
 // Image is a Docker-specific implementation of types.ImageCloser with a few extra methods
@@ -64,72 +63,71 @@ func GetRepositoryTags(ctx context.Context, sys *types.SystemContext, ref types.
 	if err != nil {
 		return nil, err
 	}
-	path := fmt.Sprintf(tagsPath, reference.Path(dr.ref))
 	client, err := newDockerClientFromRef(sys, dr, registryConfig, false, "pull")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create client: %w", err)
 	}
 	defer client.Close()
 
-	tags := make([]string, 0)
+	return client.getRepositoryTags(ctx, dr)
+}
 
-	for {
-		res, err := client.makeRequest(ctx, http.MethodGet, path, nil, nil, v2Auth, nil)
+// getRepositoryTags lists all tags available in the repository of ref.
+func (c *dockerClient) getRepositoryTags(ctx context.Context, dr dockerReference) ([]string, error) {
+	tags := make([]string, 0)
+	for tag, err := range c.listRepositoryTags(ctx, dr, ListOptions{}) {
 		if err != nil {
 			return nil, err
 		}
-		defer res.Body.Close()
-		if res.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("fetching tags list: %w", registryHTTPResponseToError(res))
-		}
-
-		var tagsHolder struct {
-			Tags []string
-		}
-		if err = json.NewDecoder(res.Body).Decode(&tagsHolder); err != nil {
-			return nil, err
-		}
-		for _, tag := range tagsHolder.Tags {
-			if _, err := reference.WithTag(dr.ref, tag); err != nil { // Ensure the tag does not contain unexpected values
-				// Per https://github.com/containers/skopeo/issues/2409 , Sonatype Nexus 3.58, contrary
-				// to the spec, may include JSON null values in the list; and Go silently parses them as "".
-				if tag == "" {
-					logrus.Debugf("Ignoring invalid empty tag")
-					continue
-				}
-				// Per https://github.com/containers/skopeo/issues/2346 , unknown versions of JFrog Artifactory,
-				// contrary to the tag format specified in
-				// https://github.com/opencontainers/distribution-spec/blob/8a871c8234977df058f1a14e299fe0a673853da2/spec.md?plain=1#L160 ,
-				// include digests in the list.
-				if _, err := digest.Parse(tag); err == nil {
-					logrus.Debugf("Ignoring invalid tag %q matching a digest format", tag)
-					continue
-				}
-				return nil, fmt.Errorf("registry returned invalid tag %q: %w", tag, err)
-			}
-			tags = append(tags, tag)
-		}
-
-		link := res.Header.Get("Link")
-		if link == "" {
-			break
-		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
 
-		linkURLPart, _, _ := strings.Cut(link, ";")
-		linkURL, err := url.Parse(strings.Trim(linkURLPart, "<>"))
-		if err != nil {
-			return tags, err
-		}
+// getTagsPage fetches a single page of the tag list of the repository of dr at path,
+// and returns the tags, and the path of the next page, or "" if this is the last page.
+func (c *dockerClient) getTagsPage(ctx context.Context, dr dockerReference, path string) ([]string, string, error) {
+	res, err := c.makeRequest(ctx, http.MethodGet, path, nil, nil, v2Auth, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("fetching tags list: %w", registryHTTPResponseToError(res))
+	}
 
-		// can be relative or absolute, but we only want the path (and I
-		// guess we're in trouble if it forwards to a new place...)
-		path = linkURL.Path
-		if linkURL.RawQuery != "" {
-			path += "?"
-			path += linkURL.RawQuery
+	var tagsHolder struct {
+		Tags []string
+	}
+	if err = json.NewDecoder(res.Body).Decode(&tagsHolder); err != nil {
+		return nil, "", err
+	}
+	tags := make([]string, 0, len(tagsHolder.Tags))
+	for _, tag := range tagsHolder.Tags {
+		if _, err := reference.WithTag(dr.ref, tag); err != nil { // Ensure the tag does not contain unexpected values
+			// Per https://github.com/containers/skopeo/issues/2409 , Sonatype Nexus 3.58, contrary
+			// to the spec, may include JSON null values in the list; and Go silently parses them as "".
+			if tag == "" {
+				logrus.Debugf("Ignoring invalid empty tag")
+				continue
+			}
+			// Per https://github.com/containers/skopeo/issues/2346 , unknown versions of JFrog Artifactory,
+			// contrary to the tag format specified in
+			// https://github.com/opencontainers/distribution-spec/blob/8a871c8234977df058f1a14e299fe0a673853da2/spec.md?plain=1#L160 ,
+			// include digests in the list.
+			if _, err := digest.Parse(tag); err == nil {
+				logrus.Debugf("Ignoring invalid tag %q matching a digest format", tag)
+				continue
+			}
+			return nil, "", fmt.Errorf("registry returned invalid tag %q: %w", tag, err)
 		}
+		tags = append(tags, tag)
 	}
-	return tags, nil
+	next, err := nextPagePath(res)
+	if err != nil {
+		return nil, "", err
+	}
+	return tags, next, nil
 }
 
 // GetDigest returns the image's digest
@@ -162,17 +160,42 @@ func GetDigest(ctx context.Context, sys *types.SystemContext, ref types.ImageRef
 	}
 	defer client.Close()
 
+	return client.getManifestDigest(ctx, dr, tagOrDigest)
+}
+
+// getManifestDigest returns the digest of the manifest for (the repo of dr) + tagOrDigest, without downloading the manifest
+// (unless it is already cached).
+// The caller is responsible for ensuring tagOrDigest uses the expected format.
+func (c *dockerClient) getManifestDigest(ctx context.Context, dr dockerReference, tagOrDigest string) (digest.Digest, error) {
 	path := fmt.Sprintf(manifestPath, reference.Path(dr.ref), tagOrDigest)
 	headers := map[string][]string{
-		"Accept": manifest.DefaultRequestedManifestMIMETypes,
+		"Accept": c.requestedManifestMIMETypes(),
 	}
 
-	res, err := client.makeRequest(ctx, http.MethodHead, path, headers, nil, v2Auth, nil)
+	// The HEAD request does not return a body, so it is never recorded in the manifest cache;
+	// but if a manifest was fetched earlier, we can revalidate it instead of relying on Docker-Content-Digest.
+	var cached manifestCacheEntry
+	haveCached := false
+	if c.useManifestCache() {
+		if err := c.detectProperties(ctx); err != nil { // Sets c.scheme, used in the cache key
+			return "", err
+		}
+		cached, haveCached = processManifestCache.get(c.manifestCacheKey(dr, tagOrDigest))
+		if haveCached {
+			headers["If-None-Match"] = []string{cached.etag}
+		}
+	}
+
+	res, err := c.makeRequest(ctx, http.MethodHead, path, headers, nil, v2Auth, nil)
 	if err != nil {
 		return "", err
 	}
 
 	defer res.Body.Close()
+	if haveCached && res.StatusCode == http.StatusNotModified {
+		logrus.Debugf("Manifest %s in %s not modified, using digest of cached copy", tagOrDigest, dr.ref.Name())
+		return manifest.Digest(cached.manifest)
+	}
 	if res.StatusCode != http.StatusOK {
 		return "", fmt.Errorf("reading digest %s in %s: %w", tagOrDigest, dr.ref.Name(), registryHTTPResponseToError(res))
 	}
@@ -184,3 +207,73 @@ func GetDigest(ctx context.Context, sys *types.SystemContext, ref types.ImageRef
 
 	return dig, nil
 }
+
+// TagDigest is a tag, and the digest of the manifest it refers to.
+type TagDigest struct {
+	Tag    string
+	Digest digest.Digest
+}
+
+// defaultMaxConcurrentTagDigestRequests is the default limit of concurrent requests in GetRepositoryTagDigests.
+const defaultMaxConcurrentTagDigestRequests = 6
+
+// GetRepositoryTagDigests lists all tags available in the repository, with the digests of the manifests they refer to,
+// in the order returned by the registry. The tag provided inside the ImageReference will be ignored.
+//
+// The digests are determined using HEAD requests, reusing a single client (and its authentication state),
+// with at most maxConcurrentRequests requests in flight; if maxConcurrentRequests <= 0, a default is used.
+// Tags which are removed from the repository while the digests are being determined are omitted.
+// NOTE: As with GetDigest, mirror configuration is ignored.
+func GetRepositoryTagDigests(ctx context.Context, sys *types.SystemContext, ref types.ImageReference, maxConcurrentRequests int) ([]TagDigest, error) {
+	dr, ok := ref.(dockerReference)
+	if !ok {
+		return nil, errors.New("ref must be a dockerReference")
+	}
+	if maxConcurrentRequests <= 0 {
+		maxConcurrentRequests = defaultMaxConcurrentTagDigestRequests
+	}
+
+	registryConfig, err := loadRegistryConfiguration(sys)
+	if err != nil {
+		return nil, err
+	}
+	client, err := newDockerClientFromRef(sys, dr, registryConfig, false, "pull")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %w", err)
+	}
+	defer client.Close()
+
+	tags, err := client.getRepositoryTags(ctx, dr)
+	if err != nil {
+		return nil, err
+	}
+
+	digests := make([]digest.Digest, len(tags))
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(maxConcurrentRequests)
+	for i, tag := range tags {
+		group.Go(func() error {
+			d, err := client.getManifestDigest(groupCtx, dr, tag)
+			if err != nil {
+				if isManifestUnknownError(err) {
+					logrus.Debugf("Tag %q was removed while listing tag digests, ignoring it", tag)
+					return nil
+				}
+				return fmt.Errorf("determining digest of tag %q: %w", tag, err)
+			}
+			digests[i] = d
+			return nil
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return nil, err
+	}
+
+	res := make([]TagDigest, 0, len(tags))
+	for i, tag := range tags {
+		if digests[i] != "" {
+			res = append(res, TagDigest{Tag: tag, Digest: digests[i]})
+		}
+	}
+	return res, nil
+}
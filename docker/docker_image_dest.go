@@ -15,6 +15,7 @@ import (
 	"path/filepath"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/containers/image/v5/docker/reference"
 	"github.com/containers/image/v5/internal/blobinfocache"
@@ -46,8 +47,11 @@ type dockerImageDestination struct {
 
 	ref dockerReference
 	c   *dockerClient
+
+	blobAbsenceTTL time.Duration // types.SystemContext.DockerBlobAbsenceCacheTTL
 	// State
-	manifestDigest digest.Digest // or "" if not yet known.
+	manifestDigest       digest.Digest // or "" if not yet known.
+	rejectedManifestSize int           // The size of the smallest manifest the registry rejected as too large, or 0.
 }
 
 // newImageDestination creates a new ImageDestination for the specified image reference.
@@ -77,12 +81,20 @@ func newImageDestination(sys *types.SystemContext, ref dockerReference) (private
 			MustMatchRuntimeOS:             false,
 			IgnoresEmbeddedDockerReference: false, // We do want the manifest updated; older registry versions refuse manifests if the embedded reference does not match.
 			HasThreadSafePutBlob:           true,
+			Capabilities: private.DestinationCapabilities{
+				Referrers:       types.OptionalBoolUndefined, // Depends on the registry.
+				Deletion:        types.OptionalBoolUndefined, // Depends on the registry and on permissions.
+				MaxManifestSize: iolimits.MaxManifestBodySize,
+			},
 		}),
 		NoPutBlobPartialInitialize: stubs.NoPutBlobPartial(ref),
 
 		ref: ref,
 		c:   c,
 	}
+	if sys != nil {
+		dest.blobAbsenceTTL = sys.DockerBlobAbsenceCacheTTL
+	}
 	dest.Compat = impl.AddCompat(dest)
 	return dest, nil
 }
@@ -161,7 +173,7 @@ func (d *dockerImageDestination) PutBlobWithOptions(ctx context.Context, stream
 		}
 	}
 
-	// FIXME? Chunked upload, progress reporting, etc.
+	// FIXME? Progress reporting, etc.
 	uploadPath := fmt.Sprintf(blobUploadPath, reference.Path(d.ref.ref))
 	logrus.Debugf("Uploading %s", uploadPath)
 	res, err := d.c.makeRequest(ctx, http.MethodPost, uploadPath, nil, nil, v2Auth, nil)
@@ -177,31 +189,21 @@ func (d *dockerImageDestination) PutBlobWithOptions(ctx context.Context, stream
 	if err != nil {
 		return private.UploadedBlob{}, fmt.Errorf("determining upload URL: %w", err)
 	}
+	configuredChunkSize := int64(0)
+	if d.c.sys != nil {
+		configuredChunkSize = d.c.sys.DockerUploadChunkSize
+	}
+	chunkSize := uploadChunkSize(res.Header, inputInfo.Size, configuredChunkSize)
 
 	digester, stream := putblobdigest.DigestIfCanonicalUnknown(stream, inputInfo)
 	sizeCounter := &sizeCounter{}
 	stream = io.TeeReader(stream, sizeCounter)
 
-	uploadLocation, err = func() (*url.URL, error) { // A scope for defer
-		uploadReader := uploadreader.NewUploadReader(stream)
-		// This error text should never be user-visible, we terminate only after makeRequestToResolvedURL
-		// returns, so there isn’t a way for the error text to be provided to any of our callers.
-		defer uploadReader.Terminate(errors.New("Reading data from an already terminated upload"))
-		res, err = d.c.makeRequestToResolvedURL(ctx, http.MethodPatch, uploadLocation, map[string][]string{"Content-Type": {"application/octet-stream"}}, uploadReader, inputInfo.Size, v2Auth, nil)
-		if err != nil {
-			logrus.Debugf("Error uploading layer chunked %v", err)
-			return nil, err
-		}
-		defer res.Body.Close()
-		if !successStatus(res.StatusCode) {
-			return nil, fmt.Errorf("uploading layer chunked: %w", registryHTTPResponseToError(res))
-		}
-		uploadLocation, err := res.Location()
-		if err != nil {
-			return nil, fmt.Errorf("determining upload URL: %w", err)
-		}
-		return uploadLocation, nil
-	}()
+	if chunkSize != 0 {
+		uploadLocation, err = d.uploadBlobInChunks(ctx, uploadLocation, stream, chunkSize)
+	} else {
+		uploadLocation, err = d.uploadBlobInSingleRequest(ctx, uploadLocation, stream, inputInfo.Size)
+	}
 	if err != nil {
 		return private.UploadedBlob{}, err
 	}
@@ -227,6 +229,29 @@ func (d *dockerImageDestination) PutBlobWithOptions(ctx context.Context, stream
 	return private.UploadedBlob{Digest: blobDigest, Size: sizeCounter.size}, nil
 }
 
+// uploadBlobInSingleRequest uploads the contents of stream, of size (-1 if unknown), to uploadLocation using a single PATCH request,
+// and returns the location to use for completing the upload.
+func (d *dockerImageDestination) uploadBlobInSingleRequest(ctx context.Context, uploadLocation *url.URL, stream io.Reader, size int64) (*url.URL, error) {
+	uploadReader := uploadreader.NewUploadReader(stream)
+	// This error text should never be user-visible, we terminate only after makeRequestToResolvedURL
+	// returns, so there isn’t a way for the error text to be provided to any of our callers.
+	defer uploadReader.Terminate(errors.New("Reading data from an already terminated upload"))
+	res, err := d.c.makeRequestToResolvedURL(ctx, http.MethodPatch, uploadLocation, map[string][]string{"Content-Type": {"application/octet-stream"}}, uploadReader, size, v2Auth, nil)
+	if err != nil {
+		logrus.Debugf("Error uploading layer chunked %v", err)
+		return nil, err
+	}
+	defer res.Body.Close()
+	if !successStatus(res.StatusCode) {
+		return nil, fmt.Errorf("uploading layer chunked: %w", registryHTTPResponseToError(res))
+	}
+	uploadLocation, err = res.Location()
+	if err != nil {
+		return nil, fmt.Errorf("determining upload URL: %w", err)
+	}
+	return uploadLocation, nil
+}
+
 // blobExists returns true iff repo contains a blob with digest, and if so, also its size.
 // If the destination does not contain the blob, or it is unknown, blobExists ordinarily returns (false, -1, nil);
 // it returns a non-nil error only on an unexpected failure.
@@ -260,6 +285,24 @@ func (d *dockerImageDestination) blobExists(ctx context.Context, repo reference.
 	}
 }
 
+// blobExistsCached is blobExists, but if d.blobAbsenceTTL is set, it uses cache to avoid checking for blobs recently
+// found missing, and records blobs found missing in cache.
+func (d *dockerImageDestination) blobExistsCached(ctx context.Context, cache blobinfocache.BlobInfoCache2, repo reference.Named, digest digest.Digest, extraScope *authScope) (bool, int64, error) {
+	if d.blobAbsenceTTL == 0 {
+		return d.blobExists(ctx, repo, digest, extraScope)
+	}
+	location := types.BICLocationReference{Opaque: repo.Name()} // As in newBICLocationReference
+	if cache.BlobKnownAbsent(d.ref.Transport(), bicTransportScope(d.ref), digest, location, d.blobAbsenceTTL) {
+		logrus.Debugf("Blob %s was recently found missing in %s, not checking again", digest, repo.Name())
+		return false, -1, nil
+	}
+	exists, size, err := d.blobExists(ctx, repo, digest, extraScope)
+	if err == nil && !exists {
+		cache.RecordBlobAbsence(d.ref.Transport(), bicTransportScope(d.ref), digest, location)
+	}
+	return exists, size, err
+}
+
 // mountBlob tries to mount blob srcDigest from srcRepo to the current destination.
 func (d *dockerImageDestination) mountBlob(ctx context.Context, srcRepo reference.Named, srcDigest digest.Digest, extraScope *authScope) error {
 	u := url.URL{
@@ -306,10 +349,11 @@ func (d *dockerImageDestination) mountBlob(ctx context.Context, srcRepo referenc
 }
 
 // tryReusingExactBlob is a subset of TryReusingBlob which _only_ looks for exactly the specified
-// blob in the current repository, with no cross-repo reuse or mounting; cache may be updated, it is not read.
+// blob in the current repository, with no cross-repo reuse or mounting; cache may be updated, it is only read for
+// recorded absence of the blob (see types.SystemContext.DockerBlobAbsenceCacheTTL).
 // The caller must ensure info.Digest is set.
 func (d *dockerImageDestination) tryReusingExactBlob(ctx context.Context, info types.BlobInfo, cache blobinfocache.BlobInfoCache2) (bool, private.ReusedBlob, error) {
-	exists, size, err := d.blobExists(ctx, d.ref.ref, info.Digest, nil)
+	exists, size, err := d.blobExistsCached(ctx, cache, d.ref.ref, info.Digest, nil)
 	if err != nil {
 		return false, private.ReusedBlob{}, err
 	}
@@ -361,16 +405,28 @@ func (d *dockerImageDestination) TryReusingBlobWithOptions(ctx context.Context,
 	}
 
 	// Then try reusing blobs from other locations.
+	triedRepos := set.New[string]() // Repositories already checked for info.Digest
 	candidates := options.Cache.CandidateLocations2(d.ref.Transport(), bicTransportScope(d.ref), info.Digest, blobinfocache.CandidateLocations2Options{
 		CanSubstitute:           options.CanSubstitute,
 		PossibleManifestFormats: options.PossibleManifestFormats,
 		RequiredCompression:     options.RequiredCompression,
 	})
 	for _, candidate := range candidates {
+		// The cache is stored on disk and shared with other processes; don’t let a corrupted entry inject arbitrary data into request paths.
+		if err := candidate.Digest.Validate(); err != nil {
+			logrus.Debugf("Ignoring BlobInfoCache candidate with invalid digest %q: %v", candidate.Digest, err)
+			continue
+		}
 		var candidateRepo reference.Named
 		if !candidate.UnknownLocation {
 			var err error
-			candidateRepo, err = parseBICLocationReference(candidate.Location)
+			// parseBICLocationReference also verifies that the location is within the destination registry.
+			// OCI distribution spec 1.1 allows mounting blobs without specifying the source repo
+			// (the "from" parameter); in that case we might try to use candidates from other registries as well.
+			//
+			// OTOH that would mean we can’t do the “blobExists” check, and if there is no match
+			// we could get an upload request that we would have to cancel.
+			candidateRepo, err = parseBICLocationReference(bicTransportScope(d.ref), candidate.Location)
 			if err != nil {
 				logrus.Debugf("Error parsing BlobInfoCache location reference: %s", err)
 				continue
@@ -380,16 +436,6 @@ func (d *dockerImageDestination) TryReusingBlobWithOptions(ctx context.Context,
 			} else {
 				logrus.Debugf("Trying to reuse blob with cached digest %s in destination repo %s", candidate.Digest.String(), candidateRepo.Name())
 			}
-			// Sanity checks:
-			if reference.Domain(candidateRepo) != reference.Domain(d.ref.ref) {
-				// OCI distribution spec 1.1 allows mounting blobs without specifying the source repo
-				// (the "from" parameter); in that case we might try to use these candidates as well.
-				//
-				// OTOH that would mean we can’t do the “blobExists” check, and if there is no match
-				// we could get an upload request that we would have to cancel.
-				logrus.Debugf("... Internal error: domain %s does not match destination %s", reference.Domain(candidateRepo), reference.Domain(d.ref.ref))
-				continue
-			}
 		} else {
 			if candidate.CompressionAlgorithm != nil {
 				logrus.Debugf("Trying to reuse blob with cached digest %s compressed with %s with no location match, checking current repo", candidate.Digest.String(), candidate.CompressionAlgorithm.Name())
@@ -407,36 +453,12 @@ func (d *dockerImageDestination) TryReusingBlobWithOptions(ctx context.Context,
 			continue
 		}
 
-		// Whatever happens here, don't abort the entire operation.  It's likely we just don't have permissions, and if it is a critical network error, we will find out soon enough anyway.
-
-		// Checking candidateRepo, and mounting from it, requires an
-		// expanded token scope.
-		extraScope := &authScope{
-			resourceType: "repository",
-			remoteName:   reference.Path(candidateRepo),
-			actions:      "pull",
-		}
-		// This existence check is not, strictly speaking, necessary: We only _really_ need it to get the blob size, and we could record that in the cache instead.
-		// But a "failed" d.mountBlob currently leaves around an unterminated server-side upload, which we would try to cancel.
-		// So, without this existence check, it would be 1 request on success, 2 requests on failure; with it, it is 2 requests on success, 1 request on failure.
-		// On success we avoid the actual costly upload; so, in a sense, the success case is "free", but failures are always costly.
-		// Even worse, docker/distribution does not actually reasonably implement canceling uploads
-		// (it would require a "delete" action in the token, and Quay does not give that to anyone, so we can't ask);
-		// so, be a nice client and don't create unnecessary upload sessions on the server.
-		exists, size, err := d.blobExists(ctx, candidateRepo, candidate.Digest, extraScope)
-		if err != nil {
-			logrus.Debugf("... Failed: %v", err)
-			continue
+		if candidate.Digest == info.Digest {
+			triedRepos.Add(candidateRepo.Name())
 		}
+		exists, size := d.tryMountingBlob(ctx, options.Cache, candidateRepo, candidate.Digest)
 		if !exists {
-			// FIXME? Should we drop the blob from cache here (and elsewhere?)?
-			continue // logrus.Debug() already happened in blobExists
-		}
-		if candidateRepo.Name() != d.ref.ref.Name() {
-			if err := d.mountBlob(ctx, candidateRepo, candidate.Digest, extraScope); err != nil {
-				logrus.Debugf("... Mount failed: %v", err)
-				continue
-			}
+			continue
 		}
 
 		options.Cache.RecordKnownLocation(d.ref.Transport(), bicTransportScope(d.ref), candidate.Digest, newBICLocationReference(d.ref))
@@ -450,9 +472,69 @@ func (d *dockerImageDestination) TryReusingBlobWithOptions(ctx context.Context,
 		}, nil
 	}
 
+	// Finally, try repositories suggested by the caller. Only the original blob is looked for there,
+	// and only if it is acceptable; the destination repository itself has already been checked in that case.
+	if len(options.CandidateRepositories) != 0 && impl.OriginalCandidateMatchesTryReusingBlobOptions(options) {
+		for _, candidateRepo := range options.CandidateRepositories {
+			if reference.Domain(candidateRepo) != reference.Domain(d.ref.ref) {
+				logrus.Debugf("Ignoring candidate repository %s, not on registry %s", candidateRepo.Name(), reference.Domain(d.ref.ref))
+				continue
+			}
+			candidateRepo = reference.TrimNamed(candidateRepo)
+			if candidateRepo.Name() == d.ref.ref.Name() || triedRepos.Contains(candidateRepo.Name()) {
+				continue
+			}
+			triedRepos.Add(candidateRepo.Name())
+			logrus.Debugf("Trying to reuse blob %s from candidate repository %s", info.Digest.String(), candidateRepo.Name())
+			exists, size := d.tryMountingBlob(ctx, options.Cache, candidateRepo, info.Digest)
+			if !exists {
+				continue
+			}
+			options.Cache.RecordKnownLocation(d.ref.Transport(), bicTransportScope(d.ref), info.Digest, newBICLocationReference(d.ref))
+			return true, private.ReusedBlob{Digest: info.Digest, Size: size}, nil
+		}
+	}
+
 	return false, private.ReusedBlob{}, nil
 }
 
+// tryMountingBlob checks whether candidateRepo contains a blob with blobDigest, and if so, mounts it into the destination repository
+// (unless candidateRepo is the destination repository); it returns true and the size of the blob on success.
+func (d *dockerImageDestination) tryMountingBlob(ctx context.Context, cache blobinfocache.BlobInfoCache2, candidateRepo reference.Named, blobDigest digest.Digest) (bool, int64) {
+	// Whatever happens here, don't abort the entire operation.  It's likely we just don't have permissions, and if it is a critical network error, we will find out soon enough anyway.
+
+	// Checking candidateRepo, and mounting from it, requires an
+	// expanded token scope.
+	extraScope := &authScope{
+		resourceType: "repository",
+		remoteName:   reference.Path(candidateRepo),
+		actions:      "pull",
+	}
+	// This existence check is not, strictly speaking, necessary: We only _really_ need it to get the blob size, and we could record that in the cache instead.
+	// But a "failed" d.mountBlob currently leaves around an unterminated server-side upload, which we would try to cancel.
+	// So, without this existence check, it would be 1 request on success, 2 requests on failure; with it, it is 2 requests on success, 1 request on failure.
+	// On success we avoid the actual costly upload; so, in a sense, the success case is "free", but failures are always costly.
+	// Even worse, docker/distribution does not actually reasonably implement canceling uploads
+	// (it would require a "delete" action in the token, and Quay does not give that to anyone, so we can't ask);
+	// so, be a nice client and don't create unnecessary upload sessions on the server.
+	exists, size, err := d.blobExistsCached(ctx, cache, candidateRepo, blobDigest, extraScope)
+	if err != nil {
+		logrus.Debugf("... Failed: %v", err)
+		return false, -1
+	}
+	if !exists {
+		// FIXME? Should we drop the blob from cache here (and elsewhere?)?
+		return false, -1 // logrus.Debug() already happened in blobExists
+	}
+	if candidateRepo.Name() != d.ref.ref.Name() {
+		if err := d.mountBlob(ctx, candidateRepo, blobDigest, extraScope); err != nil {
+			logrus.Debugf("... Mount failed: %v", err)
+			return false, -1
+		}
+	}
+	return true, size
+}
+
 // PutManifest writes manifest to the destination.
 // When the primary manifest is a manifest list, if instanceDigest is nil, we're saving the list
 // itself, else instanceDigest contains a digest of the specific manifest instance to overwrite the
@@ -510,6 +592,10 @@ func (d *dockerImageDestination) PutManifest(ctx context.Context, m []byte, inst
 // uploadManifest writes manifest to tagOrDigest.
 func (d *dockerImageDestination) uploadManifest(ctx context.Context, m []byte, tagOrDigest string) error {
 	path := fmt.Sprintf(manifestPath, reference.Path(d.ref.ref), tagOrDigest)
+	if d.rejectedManifestSize != 0 && len(m) >= d.rejectedManifestSize {
+		return fmt.Errorf("uploading manifest %s to %s: manifest size %d exceeds the registry limit, which rejected a manifest of size %d",
+			tagOrDigest, d.ref.ref.Name(), len(m), d.rejectedManifestSize)
+	}
 
 	headers := map[string][]string{}
 	mimeType := manifest.GuessMIMEType(m)
@@ -524,7 +610,12 @@ func (d *dockerImageDestination) uploadManifest(ctx context.Context, m []byte, t
 	if !successStatus(res.StatusCode) {
 		rawErr := registryHTTPResponseToError(res)
 		err := fmt.Errorf("uploading manifest %s to %s: %w", tagOrDigest, d.ref.ref.Name(), rawErr)
-		if isManifestInvalidError(rawErr) {
+		if res.StatusCode == http.StatusRequestEntityTooLarge && (d.rejectedManifestSize == 0 || len(m) < d.rejectedManifestSize) {
+			// Don’t send other manifests which are at least as large; the registry would reject them the same way.
+			d.rejectedManifestSize = len(m)
+		}
+		// Some registries reject manifest media types they don’t support with an otherwise unexplained 415 Unsupported Media Type.
+		if isManifestInvalidError(rawErr) || res.StatusCode == http.StatusUnsupportedMediaType {
 			err = types.ManifestTypeRejectedError{Err: err}
 		}
 		return err
@@ -880,6 +971,10 @@ func (d *dockerImageDestination) putSignaturesToAPIExtension(ctx context.Context
 		if !ok {
 			return signature.UnsupportedFormatError(newSigWithFormat)
 		}
+		if newSigSimple.UntrustedTimestampToken() != nil {
+			// The X-Registry-Supports-Signatures API only stores the signature itself.
+			return signature.ErrUnsupportedTimestampToken
+		}
 		newSig := newSigSimple.UntrustedSignature()
 
 		if slices.ContainsFunc(existingSignatures.Signatures, func(existingSig extensionSignature) bool {
@@ -3,10 +3,21 @@ package docker
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/internal/blobinfocache"
 	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/pkg/blobinfocache/memory"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -34,3 +45,172 @@ func TestIsManifestInvalidError(t *testing.T) {
 	res := isManifestInvalidError(err)
 	assert.True(t, res, "%#v", err)
 }
+
+func TestTryReusingBlobAbsenceCache(t *testing.T) {
+	blobDigest := digest.FromString("blob")
+	var blobPresent atomic.Bool
+	var headRequests atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodHead && r.URL.Path == "/v2/repo/blobs/"+blobDigest.String():
+			headRequests.Add(1)
+			if !blobPresent.Load() {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Length", "4")
+			w.WriteHeader(http.StatusOK)
+		default:
+			assert.Failf(t, "Unexpected request", "%v %v", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+	ref, err := ParseReference("//" + strings.TrimPrefix(s.URL, "http://") + "/repo:latest")
+	require.NoError(t, err)
+
+	for _, c := range []struct {
+		ttl                  time.Duration
+		expectedHeadRequests int32
+	}{
+		{0, 2},
+		{time.Hour, 1},
+	} {
+		blobPresent.Store(false)
+		headRequests.Store(0)
+		cache := blobinfocache.FromBlobInfoCache(memory.New())
+		dest, err := newImageDestination(&types.SystemContext{
+			DockerInsecureSkipTLSVerify: types.OptionalBoolTrue,
+			DockerBlobAbsenceCacheTTL:   c.ttl,
+		}, ref.(dockerReference))
+		require.NoError(t, err)
+		defer dest.Close()
+
+		// A missing blob is only checked once within the TTL.
+		for range 2 {
+			reused, _, err := dest.TryReusingBlobWithOptions(context.Background(), types.BlobInfo{Digest: blobDigest, Size: -1}, private.TryReusingBlobOptions{Cache: cache})
+			require.NoError(t, err)
+			assert.False(t, reused)
+		}
+		assert.Equal(t, c.expectedHeadRequests, headRequests.Load(), c.ttl)
+
+		// A successful push invalidates the record.
+		blobPresent.Store(true)
+		cache.RecordKnownLocation(ref.Transport(), bicTransportScope(ref.(dockerReference)), blobDigest, newBICLocationReference(ref.(dockerReference)))
+		reused, blob, err := dest.TryReusingBlobWithOptions(context.Background(), types.BlobInfo{Digest: blobDigest, Size: -1}, private.TryReusingBlobOptions{Cache: cache})
+		require.NoError(t, err)
+		assert.True(t, reused)
+		assert.Equal(t, int64(4), blob.Size)
+	}
+}
+
+func TestTryReusingBlobCandidateRepositories(t *testing.T) {
+	blobDigest := digest.FromString("blob")
+	var mutex sync.Mutex
+	var requests []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodHead && strings.HasSuffix(r.URL.Path, "/blobs/"+blobDigest.String()):
+			requests = append(requests, "HEAD "+r.URL.Path)
+			if r.URL.Path != "/v2/ns/present/blobs/"+blobDigest.String() {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Length", "4")
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodPost && r.URL.Path == "/v2/ns/dest/blobs/uploads/":
+			requests = append(requests, "MOUNT from "+r.URL.Query().Get("from"))
+			assert.Equal(t, blobDigest.String(), r.URL.Query().Get("mount"))
+			w.WriteHeader(http.StatusCreated)
+		default:
+			assert.Failf(t, "Unexpected request", "%v %v", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+	registry := strings.TrimPrefix(s.URL, "http://")
+	ref, err := ParseReference("//" + registry + "/ns/dest:latest")
+	require.NoError(t, err)
+	dest, err := newImageDestination(&types.SystemContext{DockerInsecureSkipTLSVerify: types.OptionalBoolTrue}, ref.(dockerReference))
+	require.NoError(t, err)
+	defer dest.Close()
+
+	candidates := []reference.Named{}
+	for _, name := range []string{"other.example.com/ns/present", registry + "/ns/dest", registry + "/ns/missing", registry + "/ns/present:tag", registry + "/ns/later"} {
+		named, err := reference.ParseNormalizedNamed(name)
+		require.NoError(t, err)
+		candidates = append(candidates, named)
+	}
+	cache := blobinfocache.FromBlobInfoCache(memory.New())
+	reused, blob, err := dest.TryReusingBlobWithOptions(context.Background(), types.BlobInfo{Digest: blobDigest, Size: -1}, private.TryReusingBlobOptions{
+		Cache:                 cache,
+		CandidateRepositories: candidates,
+	})
+	require.NoError(t, err)
+	assert.True(t, reused)
+	assert.Equal(t, private.ReusedBlob{Digest: blobDigest, Size: 4}, blob)
+	assert.Equal(t, []string{
+		"HEAD /v2/ns/dest/blobs/" + blobDigest.String(),
+		"HEAD /v2/ns/missing/blobs/" + blobDigest.String(),
+		"HEAD /v2/ns/present/blobs/" + blobDigest.String(),
+		"MOUNT from ns/present",
+	}, requests)
+}
+
+func TestPutManifestTooLarge(t *testing.T) {
+	const sizeLimit = 100
+	var manifestRequests atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodPut && r.URL.Path == "/v2/repo/manifests/latest":
+			manifestRequests.Add(1)
+			if r.ContentLength > sizeLimit {
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+		default:
+			assert.Failf(t, "Unexpected request", "%v %v", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+	ref, err := ParseReference("//" + strings.TrimPrefix(s.URL, "http://") + "/repo:latest")
+	require.NoError(t, err)
+	dest, err := newImageDestination(&types.SystemContext{DockerInsecureSkipTLSVerify: types.OptionalBoolTrue}, ref.(dockerReference))
+	require.NoError(t, err)
+	defer dest.Close()
+
+	manifestOfSize := func(size int) []byte {
+		prefix := `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","annotations":{"a":"`
+		suffix := `"}}`
+		return []byte(prefix + strings.Repeat("x", size-len(prefix)-len(suffix)) + suffix)
+	}
+	for _, c := range []struct {
+		size             int
+		success          bool
+		expectedRequests int32
+	}{
+		{sizeLimit + 10, false, 1},
+		{sizeLimit + 20, false, 1}, // Rejected without sending it
+		{sizeLimit + 5, false, 2},  // Smaller than the previously rejected manifest, so sent
+		{sizeLimit + 6, false, 2},  // Rejected without sending it
+		{sizeLimit, true, 3},
+	} {
+		err := dest.PutManifest(context.Background(), manifestOfSize(c.size), nil)
+		if c.success {
+			assert.NoError(t, err, c.size)
+		} else {
+			assert.Error(t, err, c.size)
+		}
+		assert.Equal(t, c.expectedRequests, manifestRequests.Load(), c.size)
+	}
+}
@@ -14,8 +14,10 @@ import (
 	"net/url"
 	"os"
 	"os/exec"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/containers/image/v5/docker/reference"
 	"github.com/containers/image/v5/internal/imagesource/impl"
@@ -44,10 +46,14 @@ type dockerImageSource struct {
 
 	logicalRef  dockerReference // The reference the user requested. This must satisfy !isUnknownDigest
 	physicalRef dockerReference // The actual reference we are accessing (possibly a mirror). This must satisfy !isUnknownDigest
+	isMirror    bool            // physicalRef is a mirror, not the primary location of logicalRef
 	c           *dockerClient
 	// State
 	cachedManifest         []byte // nil if not loaded yet
 	cachedManifestMIMEType string // Only valid if cachedManifest != nil
+
+	originsMutex sync.Mutex              // Protects origins
+	origins      []private.ContentOrigin // Where content read so far came from; see ContentOrigins
 }
 
 // newImageSource creates a new ImageSource for the specified image reference.
@@ -86,22 +92,44 @@ func newImageSource(ctx context.Context, sys *types.SystemContext, ref dockerRef
 	if err != nil {
 		return nil, err
 	}
+	if sys == nil || !sys.DockerDisableMirrorReordering {
+		pullSources = processEndpointHealth.orderPullSources(pullSources)
+	}
 	type attempt struct {
 		ref reference.Named
 		err error
 	}
 	attempts := []attempt{}
-	for _, pullSource := range pullSources {
+	for i, pullSource := range pullSources {
 		if sys != nil && sys.DockerLogMirrorChoice {
 			logrus.Infof("Trying to access %q", pullSource.Reference)
 		} else {
 			logrus.Debugf("Trying to access %q", pullSource.Reference)
 		}
+		start := time.Now()
 		s, err := newImageSourceAttempt(ctx, sys, ref, pullSource, registryConfig)
 		if err == nil {
+			processEndpointHealth.recordSuccess(pullSource.Endpoint.Location, time.Since(start))
+			s.isMirror = i != len(pullSources)-1
+			failures := make([]private.EndpointFailure, 0, len(attempts))
+			for _, attempt := range attempts {
+				failures = append(failures, private.EndpointFailure{Endpoint: attempt.ref.Name(), Err: attempt.err})
+			}
+			if manifestDigest, err := manifest.Digest(s.cachedManifest); err == nil {
+				s.recordOrigin(private.ContentOrigin{
+					Digest:          manifestDigest,
+					IsManifest:      true,
+					Endpoint:        s.physicalRef.ref.Name(),
+					FailedEndpoints: failures,
+				})
+			}
 			return s, nil
 		}
 		logrus.Debugf("Accessing %q failed: %v", pullSource.Reference, err)
+		// Don’t hold a missing image, or our own cancellation, against the endpoint.
+		if ctx.Err() == nil && !isManifestUnknownError(err) {
+			processEndpointHealth.recordFailure(pullSource.Endpoint.Location)
+		}
 		attempts = append(attempts, attempt{
 			ref: pullSource.Reference,
 			err: err,
@@ -229,7 +257,12 @@ func (s *dockerImageSource) GetManifest(ctx context.Context, instanceDigest *dig
 		if err := instanceDigest.Validate(); err != nil { // Make sure instanceDigest.String() does not contain any unexpected characters
 			return nil, "", err
 		}
-		return s.fetchManifest(ctx, instanceDigest.String())
+		m, mimeType, err := s.fetchManifest(ctx, instanceDigest.String())
+		if err != nil {
+			return nil, "", err
+		}
+		s.recordOrigin(private.ContentOrigin{Digest: *instanceDigest, IsManifest: true, Endpoint: s.physicalRef.ref.Name()})
+		return m, mimeType, nil
 	}
 	err := s.ensureManifestIsLoaded(ctx)
 	if err != nil {
@@ -271,24 +304,80 @@ func (s *dockerImageSource) ensureManifestIsLoaded(ctx context.Context) error {
 	return nil
 }
 
-// splitHTTP200ResponseToPartial splits a 200 response in multiple streams as specified by the chunks
-func splitHTTP200ResponseToPartial(streams chan io.ReadCloser, errs chan error, body io.ReadCloser, chunks []private.ImageSourceChunk) {
-	defer close(streams)
-	defer close(errs)
-	currentOffset := uint64(0)
+// blobChunkRange is a single HTTP range of a blob, containing one or more of the chunks requested by GetBlobAt.
+type blobChunkRange struct {
+	offset uint64
+	length uint64 // math.MaxUint64 if the range extends to the end of the blob
+	chunks []private.ImageSourceChunk
+}
+
+// rangeSpec returns the range in the format used in a Range: header.
+func (r blobChunkRange) rangeSpec() string {
+	if r.length == math.MaxUint64 {
+		return fmt.Sprintf("%d-", r.offset)
+	}
+	return fmt.Sprintf("%d-%d", r.offset, r.offset+r.length-1)
+}
+
+// coalesceChunks groups chunks (sorted and non-overlapping) into HTTP ranges, merging chunks separated by at most maxGap
+// bytes into a single range. Adjacent chunks are always merged.
+func coalesceChunks(chunks []private.ImageSourceChunk, maxGap uint64) ([]blobChunkRange, error) {
+	ranges := []blobChunkRange{}
+	for i, c := range chunks {
+		if i > 0 && chunks[i-1].Length == math.MaxUint64 {
+			return nil, fmt.Errorf("internal error: another chunk requested after an util-EOF chunk")
+		}
+		if len(ranges) > 0 {
+			last := &ranges[len(ranges)-1]
+			end := last.offset + last.length
+			if c.Offset < end {
+				return nil, fmt.Errorf("invalid chunk offset specified %v (expected >= %v)", c.Offset, end)
+			}
+			if c.Offset-end <= maxGap {
+				if c.Length == math.MaxUint64 {
+					last.length = math.MaxUint64
+				} else {
+					last.length = c.Offset + c.Length - last.offset
+				}
+				last.chunks = append(last.chunks, c)
+				continue
+			}
+		}
+		ranges = append(ranges, blobChunkRange{
+			offset: c.Offset,
+			length: c.Length,
+			chunks: []private.ImageSourceChunk{c},
+		})
+	}
+	return ranges, nil
+}
 
-	body = makeBufferedNetworkReader(body, 64, 16384)
-	defer body.Close()
+// batchRanges splits ranges into batches of at most maxRanges, each to be fetched using a single HTTP request.
+// If maxRanges is 0, all ranges are fetched using a single request.
+func batchRanges(ranges []blobChunkRange, maxRanges int) [][]blobChunkRange {
+	if maxRanges <= 0 || len(ranges) <= maxRanges {
+		return [][]blobChunkRange{ranges}
+	}
+	batches := [][]blobChunkRange{}
+	for len(ranges) > maxRanges {
+		batches = append(batches, ranges[:maxRanges])
+		ranges = ranges[maxRanges:]
+	}
+	return append(batches, ranges)
+}
+
+// splitStreamToChunks sends the data of chunks, read from body which starts at baseOffset within the blob, as separate streams to the streams chan.
+// It returns only after all of the streams have been closed by the consumer.
+func splitStreamToChunks(streams chan io.ReadCloser, body io.Reader, baseOffset uint64, chunks []private.ImageSourceChunk) error {
+	currentOffset := baseOffset
 	for _, c := range chunks {
 		if c.Offset != currentOffset {
 			if c.Offset < currentOffset {
-				errs <- fmt.Errorf("invalid chunk offset specified %v (expected >= %v)", c.Offset, currentOffset)
-				break
+				return fmt.Errorf("invalid chunk offset specified %v (expected >= %v)", c.Offset, currentOffset)
 			}
 			toSkip := c.Offset - currentOffset
 			if _, err := io.Copy(io.Discard, io.LimitReader(body, int64(toSkip))); err != nil {
-				errs <- err
-				break
+				return err
 			}
 			currentOffset += toSkip
 		}
@@ -309,21 +398,47 @@ func splitHTTP200ResponseToPartial(streams chan io.ReadCloser, errs chan error,
 		<-s.closed
 		currentOffset += c.Length
 	}
+	return nil
+}
+
+// splitHTTP200ResponseToPartial splits a 200 response in multiple streams as specified by the chunks
+func splitHTTP200ResponseToPartial(streams chan io.ReadCloser, errs chan error, body io.ReadCloser, chunks []private.ImageSourceChunk) {
+	defer close(streams)
+	defer close(errs)
+	if err := splitHTTP200Response(streams, body, chunks); err != nil {
+		errs <- err
+	}
+}
+
+// splitHTTP200Response is the core of splitHTTP200ResponseToPartial; it does not close the channels.
+func splitHTTP200Response(streams chan io.ReadCloser, body io.ReadCloser, chunks []private.ImageSourceChunk) error {
+	buffered := makeBufferedNetworkReader(body, 64, 16384)
+	defer buffered.Close()
+	return splitStreamToChunks(streams, buffered, 0, chunks)
 }
 
 // handle206Response reads a 206 response and send each part as a separate ReadCloser to the streams chan.
-func handle206Response(streams chan io.ReadCloser, errs chan error, body io.ReadCloser, chunks []private.ImageSourceChunk, mediaType string, params map[string]string) {
+func handle206Response(streams chan io.ReadCloser, errs chan error, body io.ReadCloser, ranges []blobChunkRange, mediaType string, params map[string]string) {
 	defer close(streams)
 	defer close(errs)
+	if err := read206Response(streams, body, ranges, mediaType, params); err != nil {
+		errs <- err
+	}
+}
+
+// read206Response is the core of handle206Response; it does not close the channels.
+func read206Response(streams chan io.ReadCloser, body io.ReadCloser, ranges []blobChunkRange, mediaType string, params map[string]string) error {
 	if !strings.HasPrefix(mediaType, "multipart/") {
-		streams <- body
-		return
+		defer body.Close()
+		if len(ranges) != 1 {
+			return fmt.Errorf("expected a multipart response for %d ranges, got %q", len(ranges), mediaType)
+		}
+		return splitStreamToChunks(streams, body, ranges[0].offset, ranges[0].chunks)
 	}
 	boundary, found := params["boundary"]
 	if !found {
-		errs <- errors.New("could not find boundary")
 		body.Close()
-		return
+		return errors.New("could not find boundary")
 	}
 	buffered := makeBufferedNetworkReader(body, 64, 16384)
 	defer buffered.Close()
@@ -333,25 +448,21 @@ func handle206Response(streams chan io.ReadCloser, errs chan error, body io.Read
 		p, err := mr.NextPart()
 		if err != nil {
 			if err != io.EOF {
-				errs <- err
+				return err
 			}
-			if parts != len(chunks) {
-				errs <- errors.New("invalid number of chunks returned by the server")
+			if parts != len(ranges) {
+				return errors.New("invalid number of chunks returned by the server")
 			}
-			return
+			return nil
 		}
-		if parts >= len(chunks) {
-			errs <- errors.New("too many parts returned by the server")
-			break
-		}
-		s := signalCloseReader{
-			closed: make(chan struct{}),
-			stream: p,
+		if parts >= len(ranges) {
+			return errors.New("too many parts returned by the server")
 		}
-		streams <- s
 		// NextPart() cannot be called while the current part
-		// is being read, so wait until it is closed
-		<-s.closed
+		// is being read, so this waits until all of the chunks are consumed.
+		if err := splitStreamToChunks(streams, p, ranges[parts].offset, ranges[parts].chunks); err != nil {
+			return err
+		}
 		parts++
 	}
 }
@@ -387,25 +498,21 @@ func parseMediaType(contentType string) (string, map[string]string, error) {
 // to read the next chunk.
 // If the Length for the last chunk is set to math.MaxUint64, then it
 // fully fetches the remaining data from the offset to the end of the blob.
+//
+// Chunks are coalesced into HTTP ranges, and the ranges are fetched using one or more requests,
+// as configured by SystemContext.DockerBlobChunkCoalesceGap and SystemContext.DockerBlobChunkMaxRangesPerRequest.
 func (s *dockerImageSource) GetBlobAt(ctx context.Context, info types.BlobInfo, chunks []private.ImageSourceChunk) (chan io.ReadCloser, chan error, error) {
-	headers := make(map[string][]string)
-
-	rangeVals := make([]string, 0, len(chunks))
-	lastFound := false
-	for _, c := range chunks {
-		if lastFound {
-			return nil, nil, fmt.Errorf("internal error: another chunk requested after an util-EOF chunk")
-		}
-		// If the Length is set to -1, then request anything after the specified offset.
-		if c.Length == math.MaxUint64 {
-			lastFound = true
-			rangeVals = append(rangeVals, fmt.Sprintf("%d-", c.Offset))
-		} else {
-			rangeVals = append(rangeVals, fmt.Sprintf("%d-%d", c.Offset, c.Offset+c.Length-1))
-		}
+	maxGap := uint64(0)
+	maxRanges := 0
+	if s.c.sys != nil {
+		maxGap = s.c.sys.DockerBlobChunkCoalesceGap
+		maxRanges = s.c.sys.DockerBlobChunkMaxRangesPerRequest
 	}
-
-	headers["Range"] = []string{fmt.Sprintf("bytes=%s", strings.Join(rangeVals, ","))}
+	ranges, err := coalesceChunks(chunks, maxGap)
+	if err != nil {
+		return nil, nil, err
+	}
+	batches := batchRanges(ranges, maxRanges)
 
 	if len(info.URLs) != 0 {
 		return nil, nil, fmt.Errorf("external URLs not supported with GetBlobAt")
@@ -415,30 +522,21 @@ func (s *dockerImageSource) GetBlobAt(ctx context.Context, info types.BlobInfo,
 		return nil, nil, err
 	}
 	path := fmt.Sprintf(blobsPath, reference.Path(s.physicalRef.ref), info.Digest.String())
-	logrus.Debugf("Downloading %s", path)
-	res, err := s.c.makeRequest(ctx, http.MethodGet, path, headers, nil, v2Auth, nil)
+	if len(batches) > 1 {
+		logrus.Debugf("Downloading %s using %d ranges in %d requests", path, len(ranges), len(batches))
+	} else {
+		logrus.Debugf("Downloading %s", path)
+	}
+	res, err := s.getBlobRanges(ctx, path, batches[0])
 	if err != nil {
 		return nil, nil, err
 	}
-
 	switch res.StatusCode {
-	case http.StatusOK:
-		// if the server replied with a 200 status code, convert the full body response to a series of
-		// streams as it would have been done with 206.
+	case http.StatusOK, http.StatusPartialContent:
+		s.recordOrigin(private.ContentOrigin{Digest: info.Digest, Endpoint: s.physicalRef.ref.Name()})
 		streams := make(chan io.ReadCloser)
 		errs := make(chan error)
-		go splitHTTP200ResponseToPartial(streams, errs, res.Body, chunks)
-		return streams, errs, nil
-	case http.StatusPartialContent:
-		mediaType, params, err := parseMediaType(res.Header.Get("Content-Type"))
-		if err != nil {
-			return nil, nil, err
-		}
-
-		streams := make(chan io.ReadCloser)
-		errs := make(chan error)
-
-		go handle206Response(streams, errs, res.Body, chunks, mediaType, params)
+		go s.streamBlobChunks(ctx, path, streams, errs, res, batches)
 		return streams, errs, nil
 	case http.StatusBadRequest:
 		res.Body.Close()
@@ -450,11 +548,105 @@ func (s *dockerImageSource) GetBlobAt(ctx context.Context, info types.BlobInfo,
 	}
 }
 
+// getBlobRanges starts a GET request for ranges of the blob at path.
+func (s *dockerImageSource) getBlobRanges(ctx context.Context, path string, ranges []blobChunkRange) (*http.Response, error) {
+	rangeVals := make([]string, 0, len(ranges))
+	for _, r := range ranges {
+		rangeVals = append(rangeVals, r.rangeSpec())
+	}
+	headers := map[string][]string{
+		"Range": {fmt.Sprintf("bytes=%s", strings.Join(rangeVals, ","))},
+	}
+	return s.c.makeRequest(ctx, http.MethodGet, path, headers, nil, v2Auth, nil)
+}
+
+// streamBlobChunks sends the chunks of all batches as separate streams to the streams chan,
+// starting with res, the already-received response to the request for batches[0];
+// the remaining batches are requested sequentially as the preceding ones are consumed.
+func (s *dockerImageSource) streamBlobChunks(ctx context.Context, path string, streams chan io.ReadCloser, errs chan error, res *http.Response, batches [][]blobChunkRange) {
+	defer close(streams)
+	defer close(errs)
+	for i, batch := range batches {
+		if i > 0 {
+			var err error
+			res, err = s.getBlobRanges(ctx, path, batch)
+			if err != nil {
+				errs <- err
+				return
+			}
+		}
+		switch res.StatusCode {
+		case http.StatusOK:
+			// if the server replied with a 200 status code, convert the full body response to a series of
+			// streams as it would have been done with 206; this covers all of the remaining batches.
+			chunks := []private.ImageSourceChunk{}
+			for _, b := range batches[i:] {
+				for _, r := range b {
+					chunks = append(chunks, r.chunks...)
+				}
+			}
+			if err := splitHTTP200Response(streams, res.Body, chunks); err != nil {
+				errs <- err
+			}
+			return
+		case http.StatusPartialContent:
+			mediaType, params, err := parseMediaType(res.Header.Get("Content-Type"))
+			if err != nil {
+				res.Body.Close()
+				errs <- err
+				return
+			}
+			if err := read206Response(streams, res.Body, batch, mediaType, params); err != nil {
+				errs <- err
+				return
+			}
+		case http.StatusBadRequest:
+			res.Body.Close()
+			errs <- private.BadPartialRequestError{Status: res.Status}
+			return
+		default:
+			err := registryHTTPResponseToError(res)
+			res.Body.Close()
+			errs <- fmt.Errorf("fetching partial blob: %w", err)
+			return
+		}
+	}
+}
+
 // GetBlob returns a stream for the specified blob, and the blob’s size (or -1 if unknown).
 // The Digest field in BlobInfo is guaranteed to be provided, Size may be -1 and MediaType may be optionally provided.
 // May update BlobInfoCache, preferably after it knows for certain that a blob truly exists at a specific location.
 func (s *dockerImageSource) GetBlob(ctx context.Context, info types.BlobInfo, cache types.BlobInfoCache) (io.ReadCloser, int64, error) {
-	return s.c.getBlob(ctx, s.physicalRef, info, cache)
+	origin := private.ContentOrigin{Digest: info.Digest}
+	r, size, err := s.c.getBlob(ctx, s.physicalRef, info, cache, &origin)
+	if err != nil {
+		return nil, 0, err
+	}
+	s.recordOrigin(origin)
+	return r, size, nil
+}
+
+// recordOrigin records origin for ContentOrigins, unless the same content has already been recorded.
+func (s *dockerImageSource) recordOrigin(origin private.ContentOrigin) {
+	if origin.Endpoint == s.physicalRef.ref.Name() {
+		origin.IsMirror = s.isMirror
+	}
+	s.originsMutex.Lock()
+	defer s.originsMutex.Unlock()
+	if slices.ContainsFunc(s.origins, func(o private.ContentOrigin) bool {
+		return o.Digest == origin.Digest && o.IsManifest == origin.IsManifest
+	}) {
+		return
+	}
+	s.origins = append(s.origins, origin)
+}
+
+// ContentOrigins returns records of where each manifest and blob successfully read so far came from,
+// in the order they were first read.
+func (s *dockerImageSource) ContentOrigins() []private.ContentOrigin {
+	s.originsMutex.Lock()
+	defer s.originsMutex.Unlock()
+	return slices.Clone(s.origins)
 }
 
 // GetSignaturesWithFormat returns the image's signatures.  It may use a remote (= slow) service.
@@ -560,6 +752,9 @@ func (s *dockerImageSource) getOneSignature(ctx context.Context, sigURL *url.URL
 		if err != nil {
 			return nil, false, err
 		}
+		if s.c.sys != nil {
+			addHeaders(req.Header, s.c.sys.DockerTraceHeaders)
+		}
 		res, err := s.c.client.Do(req)
 		if err != nil {
 			return nil, false, err
@@ -634,11 +829,24 @@ func (s *dockerImageSource) appendSignaturesFromSigstoreAttachments(ctx context.
 	if err != nil {
 		return err
 	}
+	attachments, err := s.getSigstoreAttachments(ctx, ociManifest)
+	if err != nil {
+		return err
+	}
+	for _, a := range attachments {
+		*dest = append(*dest, a)
+	}
+	return nil
+}
+
+// getSigstoreAttachments returns the sigstore attachments stored as layers of ociManifest, which may be nil.
+func (s *dockerImageSource) getSigstoreAttachments(ctx context.Context, ociManifest *manifest.OCI1) ([]signature.Sigstore, error) {
 	if ociManifest == nil {
-		return nil
+		return nil, nil
 	}
 
 	logrus.Debugf("Found a sigstore attachment manifest with %d layers", len(ociManifest.Layers))
+	res := []signature.Sigstore{}
 	for layerIndex, layer := range ociManifest.Layers {
 		// Note that this copies all kinds of attachments: attestations, and whatever else is there,
 		// not just signatures. We leave the signature consumers to decide based on the MIME type.
@@ -649,11 +857,38 @@ func (s *dockerImageSource) appendSignaturesFromSigstoreAttachments(ctx context.
 		payload, err := s.c.getOCIDescriptorContents(ctx, s.physicalRef, layer, iolimits.MaxSignatureBodySize,
 			none.NoCache)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		*dest = append(*dest, signature.SigstoreFromComponents(layer.MediaType, payload, layer.Annotations))
+		res = append(res, signature.SigstoreFromComponents(layer.MediaType, payload, layer.Annotations))
 	}
-	return nil
+	return res, nil
+}
+
+// GetAttestations returns the attestations attached to the image using the sigstore tag convention, if enabled.
+// If instanceDigest is not nil, it contains a digest of the specific manifest instance to retrieve attestations for
+// (when the primary manifest is a manifest list); this never happens if the primary manifest is not a manifest list
+// (e.g. if the source never returns manifest lists).
+func (s *dockerImageSource) GetAttestations(ctx context.Context, instanceDigest *digest.Digest) ([]signature.Sigstore, error) {
+	if err := s.c.detectProperties(ctx); err != nil {
+		return nil, err
+	}
+	if !s.c.useSigstoreAttachments {
+		logrus.Debugf("Not looking for sigstore attestations: disabled by configuration")
+		return nil, nil
+	}
+	manifestDigest, err := s.manifestDigest(ctx, instanceDigest)
+	if err != nil {
+		return nil, err
+	}
+	tag, err := sigstoreAttestationTag(manifestDigest)
+	if err != nil {
+		return nil, err
+	}
+	ociManifest, err := s.c.getSigstoreAttachmentManifestForTag(ctx, s.physicalRef, tag)
+	if err != nil {
+		return nil, err
+	}
+	return s.getSigstoreAttachments(ctx, ociManifest)
 }
 
 // deleteImage deletes the named image from the registry, if supported.
@@ -662,79 +897,27 @@ func deleteImage(ctx context.Context, sys *types.SystemContext, ref dockerRefere
 		return fmt.Errorf("Docker reference without a tag or digest cannot be deleted")
 	}
 
-	registryConfig, err := loadRegistryConfiguration(sys)
-	if err != nil {
-		return err
-	}
-	// docker/distribution does not document what action should be used for deleting images.
-	//
-	// Current docker/distribution requires "pull" for reading the manifest and "delete" for deleting it.
-	// quay.io requires "push" (an explicit "pull" is unnecessary), does not grant any token (fails parsing the request) if "delete" is included.
-	// OpenShift ignores the action string (both the password and the token is an OpenShift API token identifying a user).
-	//
-	// We have to hard-code a single string, luckily both docker/distribution and quay.io support "*" to mean "everything".
-	c, err := newDockerClientFromRef(sys, ref, registryConfig, true, "*")
+	c, err := newDeleteClient(sys, ref)
 	if err != nil {
 		return err
 	}
 	defer c.Close()
 
-	headers := map[string][]string{
-		"Accept": manifest.DefaultRequestedManifestMIMETypes,
-	}
 	refTail, err := ref.tagOrDigest()
 	if err != nil {
 		return err
 	}
-	getPath := fmt.Sprintf(manifestPath, reference.Path(ref.ref), refTail)
-	get, err := c.makeRequest(ctx, http.MethodGet, getPath, headers, nil, v2Auth, nil)
+	manifestBody, _, err := c.getManifestForDelete(ctx, ref, refTail)
 	if err != nil {
 		return err
 	}
-	defer get.Body.Close()
-	switch get.StatusCode {
-	case http.StatusOK:
-	case http.StatusNotFound:
-		return fmt.Errorf("Unable to delete %v. Image may not exist or is not stored with a v2 Schema in a v2 registry", ref.ref)
-	default:
-		return fmt.Errorf("deleting %v: %w", ref.ref, registryHTTPResponseToError(get))
-	}
-	manifestBody, err := iolimits.ReadAtMost(get.Body, iolimits.MaxManifestBodySize)
-	if err != nil {
-		return err
-	}
-
 	manifestDigest, err := manifest.Digest(manifestBody)
 	if err != nil {
 		return fmt.Errorf("computing manifest digest: %w", err)
 	}
-	deletePath := fmt.Sprintf(manifestPath, reference.Path(ref.ref), manifestDigest)
-
-	// When retrieving the digest from a registry >= 2.3 use the following header:
-	//   "Accept": "application/vnd.docker.distribution.manifest.v2+json"
-	delete, err := c.makeRequest(ctx, http.MethodDelete, deletePath, headers, nil, v2Auth, nil)
-	if err != nil {
-		return err
-	}
-	defer delete.Body.Close()
-	if delete.StatusCode != http.StatusAccepted {
-		return fmt.Errorf("deleting %v: %w", ref.ref, registryHTTPResponseToError(delete))
+	if err := c.deleteManifestAndSignatures(ctx, ref, manifestDigest); err != nil {
+		return fmt.Errorf("deleting %v: %w", ref.ref, err)
 	}
-
-	for i := 0; ; i++ {
-		sigURL, err := lookasideStorageURL(c.signatureBase, manifestDigest, i)
-		if err != nil {
-			return err
-		}
-		missing, err := c.deleteOneSignature(sigURL)
-		if err != nil {
-			return err
-		}
-		if missing {
-			break
-		}
-	}
-
 	return nil
 }
 
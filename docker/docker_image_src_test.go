@@ -4,21 +4,27 @@ import (
 	"bytes"
 	"context"
 	"io"
+	"math"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"testing"
 
 	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/pkg/blobinfocache/none"
 	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 var _ private.ImageSource = (*dockerImageSource)(nil)
+var _ private.ImageSourceWithAttestations = (*dockerImageSource)(nil)
+var _ private.ImageSourceWithContentOrigins = (*dockerImageSource)(nil)
 
 func TestDockerImageSourceReference(t *testing.T) {
 	manifestPathRegex := regexp.MustCompile("^/v2/.*/manifests/latest$")
@@ -84,6 +90,90 @@ location = "@REGISTRY@/with-mirror"
 	}
 }
 
+func TestDockerImageSourceContentOrigins(t *testing.T) {
+	manifestBody := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json"}`)
+	manifestDigest := digest.FromBytes(manifestBody)
+	blob := []byte("blob")
+	blobDigest := digest.FromBytes(blob)
+	foreignBlob := []byte("foreign blob")
+	foreignBlobDigest := digest.FromBytes(foreignBlob)
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/":
+			rw.WriteHeader(http.StatusOK)
+		case "/v2/working-mirror/busybox/manifests/latest":
+			rw.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+			_, err := rw.Write(manifestBody)
+			assert.NoError(t, err)
+		case "/v2/working-mirror/busybox/blobs/" + blobDigest.String():
+			_, err := rw.Write(blob)
+			assert.NoError(t, err)
+		case "/foreign":
+			_, err := rw.Write(foreignBlob)
+			assert.NoError(t, err)
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	registryURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	registry := registryURL.Host
+
+	registriesConf := filepath.Join(t.TempDir(), "registries.conf")
+	err = os.WriteFile(registriesConf, []byte(strings.ReplaceAll(`[[registry]]
+location = "with-mirror.example.com"
+
+[[registry.mirror]]
+location = "@REGISTRY@/broken-mirror"
+
+[[registry.mirror]]
+location = "@REGISTRY@/working-mirror"
+`, "@REGISTRY@", registry)), 0600)
+	require.NoError(t, err)
+
+	ref, err := ParseReference("//with-mirror.example.com/busybox:latest")
+	require.NoError(t, err)
+	src, err := ref.NewImageSource(context.Background(), &types.SystemContext{
+		RegistriesDirPath:           "/this/does/not/exist",
+		DockerPerHostCertDirPath:    "/this/does/not/exist",
+		SystemRegistriesConfPath:    registriesConf,
+		DockerInsecureSkipTLSVerify: types.OptionalBoolTrue,
+	})
+	require.NoError(t, err)
+	defer src.Close()
+
+	for _, info := range []types.BlobInfo{
+		{Digest: blobDigest, Size: -1},
+		{Digest: foreignBlobDigest, Size: -1, URLs: []string{server.URL + "/missing", server.URL + "/foreign"}},
+		{Digest: blobDigest, Size: -1}, // Reading the same blob again is only reported once.
+	} {
+		r, _, err := src.GetBlob(context.Background(), info, none.NoCache)
+		require.NoError(t, err)
+		r.Close()
+	}
+	_, _, err = src.GetBlob(context.Background(), types.BlobInfo{Digest: digest.FromString("missing"), Size: -1}, none.NoCache)
+	require.Error(t, err)
+
+	origins := src.(private.ImageSourceWithContentOrigins).ContentOrigins()
+	require.Len(t, origins, 3)
+	assert.Equal(t, manifestDigest, origins[0].Digest)
+	assert.True(t, origins[0].IsManifest)
+	assert.Equal(t, registry+"/working-mirror/busybox", origins[0].Endpoint)
+	assert.True(t, origins[0].IsMirror)
+	require.Len(t, origins[0].FailedEndpoints, 1)
+	assert.Equal(t, registry+"/broken-mirror/busybox", origins[0].FailedEndpoints[0].Endpoint)
+	assert.Error(t, origins[0].FailedEndpoints[0].Err)
+	assert.Equal(t, private.ContentOrigin{Digest: blobDigest, Endpoint: registry + "/working-mirror/busybox", IsMirror: true}, origins[1])
+	assert.Equal(t, foreignBlobDigest, origins[2].Digest)
+	assert.False(t, origins[2].IsManifest)
+	assert.Equal(t, server.URL+"/foreign", origins[2].Endpoint)
+	assert.False(t, origins[2].IsMirror)
+	require.Len(t, origins[2].FailedEndpoints, 1)
+	assert.Equal(t, server.URL+"/missing", origins[2].FailedEndpoints[0].Endpoint)
+}
+
 func TestSimplifyContentType(t *testing.T) {
 	for _, c := range []struct{ input, expected string }{
 		{"", ""},
@@ -160,7 +250,9 @@ func TestHandle206Response(t *testing.T) {
 	params := map[string]string{
 		"boundary": "AAA",
 	}
-	go handle206Response(streams, errs, body, chunks, mediaType, params)
+	ranges, err := coalesceChunks(chunks, 0)
+	require.NoError(t, err)
+	go handle206Response(streams, errs, body, ranges, mediaType, params)
 
 	expected := []verifyGetBlobAtData{
 		{[]byte("23"), nil},
@@ -176,7 +268,9 @@ func TestHandle206Response(t *testing.T) {
 	chunks = []private.ImageSourceChunk{{Offset: 100, Length: 5}}
 	mediaType = "text/plain"
 	params = map[string]string{}
-	go handle206Response(streams, errs, body, chunks, mediaType, params)
+	ranges, err = coalesceChunks(chunks, 0)
+	require.NoError(t, err)
+	go handle206Response(streams, errs, body, ranges, mediaType, params)
 
 	expected = []verifyGetBlobAtData{
 		{[]byte("HELLO"), nil},
@@ -185,6 +279,91 @@ func TestHandle206Response(t *testing.T) {
 	verifyGetBlobAtOutput(t, streams, errs, expected)
 }
 
+func TestHandle206ResponseCoalesced(t *testing.T) {
+	// The blob is "0123456789"; chunks 1-2, 4, 8 were requested as ranges 1-4 and 8-9.
+	body := io.NopCloser(bytes.NewReader([]byte("--AAA\r\n\r\n1234\r\n--AAA\r\n\r\n89\r\n--AAA--")))
+	defer body.Close()
+	streams := make(chan io.ReadCloser)
+	errs := make(chan error)
+	chunks := []private.ImageSourceChunk{
+		{Offset: 1, Length: 2},
+		{Offset: 4, Length: 1},
+		{Offset: 8, Length: math.MaxUint64},
+	}
+	ranges, err := coalesceChunks(chunks, 1)
+	require.NoError(t, err)
+	require.Len(t, ranges, 2)
+	go handle206Response(streams, errs, body, ranges, "multipart/byteranges", map[string]string{"boundary": "AAA"})
+
+	expected := []verifyGetBlobAtData{
+		{[]byte("12"), nil},
+		{[]byte("4"), nil},
+		{[]byte("89"), nil},
+		{[]byte(nil), nil},
+	}
+	verifyGetBlobAtOutput(t, streams, errs, expected)
+}
+
+func TestCoalesceChunks(t *testing.T) {
+	chunks := []private.ImageSourceChunk{
+		{Offset: 0, Length: 10},
+		{Offset: 10, Length: 5}, // adjacent
+		{Offset: 20, Length: 5}, // gap of 5
+		{Offset: 100, Length: 1},
+		{Offset: 102, Length: math.MaxUint64},
+	}
+	for _, c := range []struct {
+		maxGap   uint64
+		expected []string
+	}{
+		{0, []string{"0-14", "20-24", "100-100", "102-"}},
+		{1, []string{"0-14", "20-24", "100-"}},
+		{5, []string{"0-24", "100-"}},
+		{100, []string{"0-"}},
+	} {
+		ranges, err := coalesceChunks(chunks, c.maxGap)
+		require.NoError(t, err)
+		specs := []string{}
+		allChunks := []private.ImageSourceChunk{}
+		for _, r := range ranges {
+			specs = append(specs, r.rangeSpec())
+			allChunks = append(allChunks, r.chunks...)
+		}
+		assert.Equal(t, c.expected, specs, c.maxGap)
+		assert.Equal(t, chunks, allChunks, c.maxGap)
+	}
+
+	// Invalid inputs
+	for _, c := range [][]private.ImageSourceChunk{
+		{{Offset: 10, Length: 5}, {Offset: 12, Length: 5}},
+		{{Offset: 10, Length: math.MaxUint64}, {Offset: 100, Length: 5}},
+	} {
+		_, err := coalesceChunks(c, 0)
+		assert.Error(t, err, c)
+	}
+}
+
+func TestBatchRanges(t *testing.T) {
+	ranges := []blobChunkRange{{offset: 0}, {offset: 1}, {offset: 2}, {offset: 3}, {offset: 4}}
+	for _, c := range []struct {
+		maxRanges int
+		expected  []int
+	}{
+		{0, []int{5}},
+		{1, []int{1, 1, 1, 1, 1}},
+		{2, []int{2, 2, 1}},
+		{5, []int{5}},
+		{10, []int{5}},
+	} {
+		batches := batchRanges(ranges, c.maxRanges)
+		sizes := []int{}
+		for _, b := range batches {
+			sizes = append(sizes, len(b))
+		}
+		assert.Equal(t, c.expected, sizes, c.maxRanges)
+	}
+}
+
 func TestParseMediaType(t *testing.T) {
 	mediaType, params, err := parseMediaType("multipart/byteranges; boundary=CloudFront:3F750DE0752BEDE3882F7DBE80010D31")
 	require.NoError(t, err)
@@ -211,3 +390,119 @@ func TestParseMediaType(t *testing.T) {
 	_, _, err = parseMediaType("multipart/byteranges; boundary=@")
 	require.Error(t, err)
 }
+
+func TestNewImageSourceMirrorOrdering(t *testing.T) {
+	manifestBody := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json"}`)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/":
+			rw.WriteHeader(http.StatusOK)
+		case "/v2/working-mirror/busybox/manifests/latest":
+			rw.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+			_, err := rw.Write(manifestBody)
+			assert.NoError(t, err)
+		case "/v2/broken-mirror/busybox/manifests/latest":
+			rw.WriteHeader(http.StatusInternalServerError)
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	registryURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	registry := registryURL.Host
+
+	registriesConf := filepath.Join(t.TempDir(), "registries.conf")
+	err = os.WriteFile(registriesConf, []byte(strings.ReplaceAll(`[[registry]]
+location = "with-mirror.example.com"
+
+[[registry.mirror]]
+location = "@REGISTRY@/broken-mirror"
+
+[[registry.mirror]]
+location = "@REGISTRY@/working-mirror"
+`, "@REGISTRY@", registry)), 0600)
+	require.NoError(t, err)
+
+	ref, err := ParseReference("//with-mirror.example.com/busybox:latest")
+	require.NoError(t, err)
+	for _, c := range []struct {
+		disableReordering bool
+		failedEndpoints   int
+	}{
+		{false, 1}, // The first attempt uses the configured order, and records the failure
+		{false, 0}, // The failing mirror is now tried after the working one
+		{true, 1},  // Reordering is disabled
+	} {
+		src, err := ref.NewImageSource(context.Background(), &types.SystemContext{
+			RegistriesDirPath:             "/this/does/not/exist",
+			DockerPerHostCertDirPath:      "/this/does/not/exist",
+			SystemRegistriesConfPath:      registriesConf,
+			DockerInsecureSkipTLSVerify:   types.OptionalBoolTrue,
+			DockerDisableMirrorReordering: c.disableReordering,
+		})
+		require.NoError(t, err)
+		origins := src.(private.ImageSourceWithContentOrigins).ContentOrigins()
+		src.Close()
+		require.Len(t, origins, 1)
+		assert.Equal(t, registry+"/working-mirror/busybox", origins[0].Endpoint)
+		assert.True(t, origins[0].IsMirror)
+		assert.Len(t, origins[0].FailedEndpoints, c.failedEndpoints, "%#v", c)
+	}
+}
+
+func TestGetBlobAtBadRequestInLaterBatch(t *testing.T) {
+	blob := []byte("0123456789")
+	blobDigest := digest.FromBytes(blob)
+	manifestBody := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json"}`)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/":
+			rw.WriteHeader(http.StatusOK)
+		case "/v2/busybox/manifests/latest":
+			rw.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+			_, err := rw.Write(manifestBody)
+			assert.NoError(t, err)
+		case "/v2/busybox/blobs/" + blobDigest.String():
+			if r.Header.Get("Range") != "bytes=1-2" { // Only the first batch is accepted
+				rw.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			rw.Header().Set("Content-Type", "application/octet-stream")
+			rw.WriteHeader(http.StatusPartialContent)
+			_, err := rw.Write(blob[1:3])
+			assert.NoError(t, err)
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	registryURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	registriesConf := filepath.Join(t.TempDir(), "registries.conf")
+	err = os.WriteFile(registriesConf, []byte{}, 0600)
+	require.NoError(t, err)
+
+	ref, err := ParseReference("//" + registryURL.Host + "/busybox:latest")
+	require.NoError(t, err)
+	src, err := ref.NewImageSource(context.Background(), &types.SystemContext{
+		RegistriesDirPath:                  "/this/does/not/exist",
+		DockerPerHostCertDirPath:           "/this/does/not/exist",
+		SystemRegistriesConfPath:           registriesConf,
+		DockerInsecureSkipTLSVerify:        types.OptionalBoolTrue,
+		DockerBlobChunkMaxRangesPerRequest: 1,
+	})
+	require.NoError(t, err)
+	defer src.Close()
+
+	streams, errs, err := src.(private.ImageSource).GetBlobAt(context.Background(), types.BlobInfo{Digest: blobDigest, Size: int64(len(blob))},
+		[]private.ImageSourceChunk{{Offset: 1, Length: 2}, {Offset: 6, Length: 2}})
+	require.NoError(t, err)
+	data, err := readNextStream(streams, errs)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("12"), data)
+	_, err = readNextStream(streams, errs)
+	var badRequestErr private.BadPartialRequestError
+	assert.ErrorAs(t, err, &badRequestErr)
+}
@@ -0,0 +1,81 @@
+package docker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetRepositoryTagDigests(t *testing.T) {
+	tagDigests := map[string]digest.Digest{
+		"v1": digest.FromString("v1"),
+		"v2": digest.FromString("v2"),
+		"v3": digest.FromString("v1"),
+	}
+	var inFlight, maxInFlight atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v2/":
+			rw.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodGet && r.URL.Path == "/v2/repo/tags/list":
+			if r.URL.Query().Get("last") == "" {
+				rw.Header().Set("Link", `</v2/repo/tags/list?last=v2>; rel="next"`)
+				_, err := rw.Write([]byte(`{"name":"repo","tags":["v1","v2"]}`))
+				assert.NoError(t, err)
+				return
+			}
+			_, err := rw.Write([]byte(`{"name":"repo","tags":["v3","removed"]}`))
+			assert.NoError(t, err)
+		case r.Method == http.MethodHead && strings.HasPrefix(r.URL.Path, "/v2/repo/manifests/"):
+			current := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				peak := maxInFlight.Load()
+				if current <= peak || maxInFlight.CompareAndSwap(peak, current) {
+					break
+				}
+			}
+			d, ok := tagDigests[strings.TrimPrefix(r.URL.Path, "/v2/repo/manifests/")]
+			if !ok {
+				rw.WriteHeader(http.StatusNotFound)
+				return
+			}
+			rw.Header().Set("Docker-Content-Digest", d.String())
+			rw.WriteHeader(http.StatusOK)
+		default:
+			assert.Failf(t, "Unexpected request", "%v %v", r.Method, r.URL.Path)
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	registryURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	ref, err := ParseReference("//" + registryURL.Host + "/repo:ignored")
+	require.NoError(t, err)
+	sys := &types.SystemContext{
+		DockerPerHostCertDirPath:    "/this/does/not/exist",
+		DockerInsecureSkipTLSVerify: types.OptionalBoolTrue,
+		DockerDisableManifestCache:  true,
+	}
+	res, err := GetRepositoryTagDigests(context.Background(), sys, ref, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []TagDigest{
+		{Tag: "v1", Digest: tagDigests["v1"]},
+		{Tag: "v2", Digest: tagDigests["v2"]},
+		{Tag: "v3", Digest: tagDigests["v3"]},
+	}, res)
+	assert.LessOrEqual(t, maxInFlight.Load(), int32(2))
+
+	_, err = GetRepositoryTagDigests(context.Background(), sys, nil, 0)
+	assert.Error(t, err)
+}
@@ -46,6 +46,19 @@ func (t dockerTransport) ValidatePolicyConfigurationScope(scope string) error {
 	return nil
 }
 
+// PolicyConfigurationScopeIsPattern returns true if scope, a key of signature.PolicyTransportScopes,
+// is a pattern to be matched using PolicyConfigurationScopePatternMatches instead of an exact
+// PolicyConfigurationIdentity() or PolicyConfigurationNamespaces() value.
+func (t dockerTransport) PolicyConfigurationScopeIsPattern(scope string) bool {
+	return policyconfiguration.DockerReferenceScopeIsPattern(scope)
+}
+
+// PolicyConfigurationScopePatternMatches returns true if pattern, for which PolicyConfigurationScopeIsPattern is true,
+// matches identity, a PolicyConfigurationIdentity() value.
+func (t dockerTransport) PolicyConfigurationScopePatternMatches(pattern, identity string) bool {
+	return policyconfiguration.DockerReferenceScopePatternMatches(pattern, identity)
+}
+
 // dockerReference is an ImageReference for Docker images.
 type dockerReference struct {
 	ref             reference.Named // By construction we know that !reference.IsNameOnly(ref) unless isUnknownDigest=true
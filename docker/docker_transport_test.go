@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/internal/private"
 	"github.com/containers/image/v5/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -17,6 +18,8 @@ const (
 	unknownDigestSuffixTest = "@@unknown-digest@@"
 )
 
+var _ private.ImageTransportWithScopePatterns = Transport
+
 func TestTransportName(t *testing.T) {
 	assert.Equal(t, "docker", Transport.Name())
 }
@@ -25,6 +28,14 @@ func TestTransportParseReference(t *testing.T) {
 	testParseReference(t, Transport.ParseReference)
 }
 
+func TestTransportPolicyConfigurationScopePatterns(t *testing.T) {
+	assert.False(t, Transport.PolicyConfigurationScopeIsPattern("quay.io/org/app:release-1"))
+	assert.False(t, Transport.PolicyConfigurationScopeIsPattern("*.quay.io"))
+	assert.True(t, Transport.PolicyConfigurationScopeIsPattern("quay.io/org/*:release-*"))
+	assert.True(t, Transport.PolicyConfigurationScopePatternMatches("quay.io/org/*:release-*", "quay.io/org/app:release-1"))
+	assert.False(t, Transport.PolicyConfigurationScopePatternMatches("quay.io/org/*:release-*", "quay.io/org/app:dev-1"))
+}
+
 func TestTransportValidatePolicyConfigurationScope(t *testing.T) {
 	for _, scope := range []string{
 		"docker.io/library/busybox" + sha256digest,
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/docker/distribution/registry/api/errcode"
 	"github.com/sirupsen/logrus"
@@ -16,6 +17,10 @@ var (
 	ErrV1NotSupported = errors.New("can't talk to a V1 container registry")
 	// ErrTooManyRequests is returned when the status code returned is 429
 	ErrTooManyRequests = errors.New("too many requests to registry")
+	// ErrCatalogUnsupported is returned when the registry does not provide a catalog of its repositories.
+	ErrCatalogUnsupported = errors.New("registry does not support listing repositories")
+	// ErrTagDeletionUnsupported is returned when the registry does not support deleting tags without deleting the manifest.
+	ErrTagDeletionUnsupported = errors.New("registry does not support deleting tags")
 )
 
 // ErrUnauthorizedForCredentials is returned when the status code returned is 401
@@ -27,6 +32,43 @@ func (e ErrUnauthorizedForCredentials) Error() string {
 	return fmt.Sprintf("unable to retrieve auth token: invalid username/password: %s", e.Err.Error())
 }
 
+// BlobRedirectError is returned when fetching a blob fails after the registry redirected the request to a different host,
+// typically object storage or a CDN.
+type BlobRedirectError struct {
+	Host       string // The host the registry redirected to
+	StatusCode int    // The HTTP status code returned by Host
+	Err        error
+}
+
+func (e BlobRedirectError) Error() string {
+	return fmt.Sprintf("redirected by the registry to %s: %s", e.Host, e.Err.Error())
+}
+
+func (e BlobRedirectError) Unwrap() error {
+	return e.Err
+}
+
+// UnacceptableManifestMIMETypeError is returned when fetching a manifest, if the registry only has a manifest
+// of a MIME type not included in types.SystemContext.DockerRequestedManifestMIMETypes.
+type UnacceptableManifestMIMETypeError struct {
+	MIMEType  string   // The MIME type of the manifest returned by the registry; "" if the registry did not return a manifest
+	Requested []string // The requested MIME types
+}
+
+func (e UnacceptableManifestMIMETypeError) Error() string {
+	if e.MIMEType == "" {
+		return fmt.Sprintf("registry has no manifest of the requested MIME types %s", strings.Join(e.Requested, ", "))
+	}
+	return fmt.Sprintf("registry returned a manifest of MIME type %q, which is not one of the requested MIME types %s", e.MIMEType, strings.Join(e.Requested, ", "))
+}
+
+// isRetryableRedirectedBlobStatus returns true if statusCode, returned by a server the registry redirected a blob request to,
+// might succeed with a fresh redirect from the registry.
+func isRetryableRedirectedBlobStatus(statusCode int) bool {
+	// Pre-signed object storage URLs typically return 403 once they expire.
+	return statusCode == http.StatusForbidden || statusCode >= http.StatusInternalServerError
+}
+
 // httpResponseToError translates the https.Response into an error, possibly prefixing it with the supplied context. It returns
 // nil if the response is not considered an error.
 // NOTE: Almost all callers in this package should use registryHTTPResponseToError instead.
@@ -0,0 +1,265 @@
+package docker
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/types"
+	"github.com/containers/storage/pkg/ioutils"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/sirupsen/logrus"
+)
+
+// httpDiskCacheMaxBlobSize is the maximum size of blobs (typically configs) stored in httpDiskCache.
+const httpDiskCacheMaxBlobSize = 4 * 1024 * 1024
+
+// httpDiskCache is an on-disk cache of manifests and small blobs fetched from registries,
+// shared across process invocations; see types.SystemContext.DockerHTTPCacheDir.
+//
+// Manifests and blobs are stored by digest, and are immutable, so they are used without contacting the registry.
+// Manifests fetched by tag are recorded with the response ETag and Cache-Control freshness, and are revalidated
+// using If-None-Match once they are no longer fresh.
+//
+// Entries are namespaced by registry and repository, so that access to each repository is authorized by the registry
+// at least once. Failures to read or write the cache are logged and otherwise ignored.
+type httpDiskCache struct {
+	dir string
+}
+
+// httpDiskCacheManifest is the on-disk format of a cached manifest.
+type httpDiskCacheManifest struct {
+	MIMEType string `json:"mimeType"`
+	Manifest []byte `json:"manifest"`
+}
+
+// httpDiskCacheTag is the on-disk format of a cached manifest GET by tag.
+type httpDiskCacheTag struct {
+	ETag    string        `json:"etag"`
+	Digest  digest.Digest `json:"digest"`
+	Expires time.Time     `json:"expires"` // The response may be used without revalidation until this time
+}
+
+// newHTTPDiskCache returns a httpDiskCache configured by sys, or nil if the cache is not enabled.
+func newHTTPDiskCache(sys *types.SystemContext) *httpDiskCache {
+	if sys == nil || sys.DockerHTTPCacheDir == "" {
+		return nil
+	}
+	return &httpDiskCache{dir: sys.DockerHTTPCacheDir}
+}
+
+// repoDir returns the directory containing entries for the repository identified by key.
+func (hc *httpDiskCache) repoDir(key manifestCacheKey) string {
+	return filepath.Join(hc.dir, digest.FromString(key.scheme+"://"+key.registry+"/"+key.repo).Encoded())
+}
+
+// manifestPath returns the path of the cached manifest with manifestDigest, which must be valid.
+func (hc *httpDiskCache) manifestPath(key manifestCacheKey, manifestDigest digest.Digest) string {
+	return filepath.Join(hc.repoDir(key), "manifests", manifestDigest.Algorithm().String()+"-"+manifestDigest.Encoded()+".json")
+}
+
+// blobPath returns the path of the cached blob with blobDigest, which must be valid.
+func (hc *httpDiskCache) blobPath(key manifestCacheKey, blobDigest digest.Digest) string {
+	return filepath.Join(hc.repoDir(key), "blobs", blobDigest.Algorithm().String()+"-"+blobDigest.Encoded())
+}
+
+// tagPath returns the path of the cached manifest GET for key, which uses a tag.
+// The Accept header is included, because it affects the response.
+func (hc *httpDiskCache) tagPath(key manifestCacheKey) string {
+	return filepath.Join(hc.repoDir(key), "tags", digest.FromString(key.tagOrDigest+"\n"+key.accept).Encoded()+".json")
+}
+
+// getManifest returns a cached manifest with manifestDigest, if any.
+func (hc *httpDiskCache) getManifest(key manifestCacheKey, manifestDigest digest.Digest) (httpDiskCacheManifest, bool) {
+	if manifestDigest.Validate() != nil {
+		return httpDiskCacheManifest{}, false
+	}
+	var res httpDiskCacheManifest
+	if !hc.readJSON(hc.manifestPath(key, manifestDigest), &res) {
+		return httpDiskCacheManifest{}, false
+	}
+	if matches, err := manifest.MatchesDigest(res.Manifest, manifestDigest); err != nil || !matches {
+		logrus.Debugf("Ignoring corrupt cached manifest %s", manifestDigest.String())
+		return httpDiskCacheManifest{}, false
+	}
+	return res, true
+}
+
+// putManifest records m, with mimeType, as the manifest with manifestDigest.
+// The caller must ensure that m matches manifestDigest.
+func (hc *httpDiskCache) putManifest(key manifestCacheKey, manifestDigest digest.Digest, m []byte, mimeType string) {
+	if manifestDigest.Validate() != nil {
+		return
+	}
+	hc.writeJSON(hc.manifestPath(key, manifestDigest), httpDiskCacheManifest{MIMEType: mimeType, Manifest: m})
+}
+
+// getTag returns the cached manifest GET for key, which uses a tag, along with the manifest, if any.
+func (hc *httpDiskCache) getTag(key manifestCacheKey) (httpDiskCacheTag, httpDiskCacheManifest, bool) {
+	var tag httpDiskCacheTag
+	if !hc.readJSON(hc.tagPath(key), &tag) {
+		return httpDiskCacheTag{}, httpDiskCacheManifest{}, false
+	}
+	m, ok := hc.getManifest(key, tag.Digest)
+	if !ok {
+		return httpDiskCacheTag{}, httpDiskCacheManifest{}, false
+	}
+	return tag, m, true
+}
+
+// putTag records the response to a manifest GET for key, which uses a tag.
+func (hc *httpDiskCache) putTag(key manifestCacheKey, tag httpDiskCacheTag) {
+	hc.writeJSON(hc.tagPath(key), tag)
+}
+
+// recordTagResponse records a response to a manifest GET for key, which uses a tag, with header, etag, and contents m of mimeType,
+// as allowed by header.
+func (hc *httpDiskCache) recordTagResponse(key manifestCacheKey, header http.Header, etag string, m []byte, mimeType string) {
+	storable, maxAge := httpCacheControl(header)
+	if !storable || (etag == "" && maxAge == 0) { // Nothing we could ever use the entry for
+		hc.removeTag(key)
+		return
+	}
+	manifestDigest, err := manifest.Digest(m)
+	if err != nil {
+		hc.removeTag(key)
+		return
+	}
+	hc.putManifest(key, manifestDigest, slices.Clone(m), mimeType)
+	hc.putTag(key, httpDiskCacheTag{
+		ETag:    etag,
+		Digest:  manifestDigest,
+		Expires: time.Now().Add(maxAge),
+	})
+}
+
+// removeTag drops any cached manifest GET for key, which uses a tag.
+func (hc *httpDiskCache) removeTag(key manifestCacheKey) {
+	if err := os.Remove(hc.tagPath(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		logrus.Debugf("Error removing HTTP cache entry: %v", err)
+	}
+}
+
+// getBlob returns the contents of a cached blob with blobDigest, if any.
+func (hc *httpDiskCache) getBlob(key manifestCacheKey, blobDigest digest.Digest) ([]byte, bool) {
+	if blobDigest.Validate() != nil || !blobDigest.Algorithm().Available() {
+		return nil, false
+	}
+	data, err := os.ReadFile(hc.blobPath(key, blobDigest))
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			logrus.Debugf("Error reading HTTP cache entry: %v", err)
+		}
+		return nil, false
+	}
+	if blobDigest.Algorithm().FromBytes(data) != blobDigest {
+		logrus.Debugf("Ignoring corrupt cached blob %s", blobDigest.String())
+		return nil, false
+	}
+	return data, true
+}
+
+// putBlob records data as the blob with blobDigest.
+// The caller must ensure that data matches blobDigest.
+func (hc *httpDiskCache) putBlob(key manifestCacheKey, blobDigest digest.Digest, data []byte) {
+	if blobDigest.Validate() != nil {
+		return
+	}
+	hc.writeFile(hc.blobPath(key, blobDigest), data)
+}
+
+// readJSON parses the JSON file at path into dest, and returns true on success.
+func (hc *httpDiskCache) readJSON(path string, dest any) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			logrus.Debugf("Error reading HTTP cache entry: %v", err)
+		}
+		return false
+	}
+	if err := json.Unmarshal(data, dest); err != nil {
+		logrus.Debugf("Error parsing HTTP cache entry %q: %v", path, err)
+		return false
+	}
+	return true
+}
+
+// writeJSON atomically writes value, encoded as JSON, to path.
+func (hc *httpDiskCache) writeJSON(path string, value any) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		logrus.Debugf("Error encoding HTTP cache entry: %v", err)
+		return
+	}
+	hc.writeFile(path, data)
+}
+
+// writeFile atomically writes data to path.
+func (hc *httpDiskCache) writeFile(path string, data []byte) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		logrus.Debugf("Error creating HTTP cache directory: %v", err)
+		return
+	}
+	if err := ioutils.AtomicWriteFile(path, data, 0o600); err != nil {
+		logrus.Debugf("Error writing HTTP cache entry: %v", err)
+	}
+}
+
+// httpCacheControl returns whether the response with header may be stored, and for how long it is fresh,
+// based on its Cache-Control header.
+func httpCacheControl(header http.Header) (storable bool, maxAge time.Duration) {
+	storable = true
+	noCache := false
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			switch strings.ToLower(name) {
+			case "no-store":
+				storable = false
+			case "no-cache":
+				noCache = true
+			case "max-age":
+				if seconds, err := strconv.ParseInt(strings.Trim(arg, `"`), 10, 64); err == nil && seconds > 0 {
+					maxAge = time.Duration(seconds) * time.Second
+				}
+			}
+		}
+	}
+	if noCache {
+		maxAge = 0
+	}
+	return storable, maxAge
+}
+
+// readCacheableBlob reads body, a blob with blobDigest expected to be at most httpDiskCacheMaxBlobSize bytes,
+// records it in hc if it matches blobDigest and header allows it, and returns a stream with the same contents.
+func (hc *httpDiskCache) readCacheableBlob(key manifestCacheKey, blobDigest digest.Digest, header http.Header, body io.ReadCloser) (io.ReadCloser, error) {
+	data, err := io.ReadAll(io.LimitReader(body, httpDiskCacheMaxBlobSize+1))
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	if len(data) > httpDiskCacheMaxBlobSize { // Larger than expected; don’t cache it, just return the full stream.
+		return struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(data), body), body}, nil
+	}
+	body.Close()
+	// If the blob does not match, don’t record it, and let the consumer detect the mismatch.
+	if storable, _ := httpCacheControl(header); storable &&
+		blobDigest.Algorithm().Available() && blobDigest.Algorithm().FromBytes(data) == blobDigest {
+		hc.putBlob(key, blobDigest, data)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
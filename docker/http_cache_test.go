@@ -0,0 +1,156 @@
+package docker
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/containers/image/v5/pkg/blobinfocache/memory"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPCacheControl(t *testing.T) {
+	for _, c := range []struct {
+		values   []string
+		storable bool
+		maxAge   time.Duration
+	}{
+		{nil, true, 0},
+		{[]string{"max-age=60"}, true, time.Minute},
+		{[]string{"public, MAX-AGE=\"60\""}, true, time.Minute},
+		{[]string{"max-age=60, no-cache"}, true, 0},
+		{[]string{"private", "max-age=10"}, true, 10 * time.Second},
+		{[]string{"max-age=0, no-cache, no-store"}, false, 0},
+		{[]string{"max-age=invalid"}, true, 0},
+		{[]string{"max-age=-5"}, true, 0},
+	} {
+		header := http.Header{}
+		for _, v := range c.values {
+			header.Add("Cache-Control", v)
+		}
+		storable, maxAge := httpCacheControl(header)
+		assert.Equal(t, c.storable, storable, "%#v", c.values)
+		assert.Equal(t, c.maxAge, maxAge, "%#v", c.values)
+	}
+}
+
+func TestHTTPDiskCache(t *testing.T) {
+	const etag = `"v1"`
+	manifestBody := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json"}`)
+	manifestDigest := digest.FromBytes(manifestBody)
+	configBody := []byte(`{"architecture":"amd64"}`)
+	configDigest := digest.FromBytes(configBody)
+	badBlobDigest := digest.FromString("something else")
+
+	var tagCacheControl atomic.Value
+	var requests, notModified atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			rw.WriteHeader(http.StatusOK)
+			return
+		}
+		requests.Add(1)
+		switch r.URL.Path {
+		case "/v2/repo/manifests/latest":
+			rw.Header().Set("Cache-Control", tagCacheControl.Load().(string))
+			rw.Header().Set("ETag", etag)
+			if r.Header.Get("If-None-Match") == etag {
+				notModified.Add(1)
+				rw.WriteHeader(http.StatusNotModified)
+				return
+			}
+			rw.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+			_, err := rw.Write(manifestBody)
+			assert.NoError(t, err)
+		case "/v2/repo/manifests/" + manifestDigest.String():
+			rw.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+			_, err := rw.Write(manifestBody)
+			assert.NoError(t, err)
+		case "/v2/repo/blobs/" + configDigest.String():
+			_, err := rw.Write(configBody)
+			assert.NoError(t, err)
+		case "/v2/repo/blobs/" + badBlobDigest.String():
+			_, err := rw.Write(configBody)
+			assert.NoError(t, err)
+		default:
+			assert.Failf(t, "Unexpected request", "%v %v", r.Method, r.URL.Path)
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	registryURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	ref, err := ParseReference("//" + registryURL.Host + "/repo:latest")
+	require.NoError(t, err)
+	dr, ok := ref.(dockerReference)
+	require.True(t, ok)
+
+	sys := &types.SystemContext{
+		DockerPerHostCertDirPath:    "/this/does/not/exist",
+		DockerInsecureSkipTLSVerify: types.OptionalBoolTrue,
+		DockerDisableManifestCache:  true, // Make sure only the disk cache is used
+		DockerHTTPCacheDir:          t.TempDir(),
+	}
+	// fetchManifest and fetchBlob use a new client, as a new process would, and return the number of requests made.
+	fetchManifest := func(tagOrDigest string) int32 {
+		requests.Store(0)
+		client, err := newDockerClient(sys, registryURL.Host, registryURL.Host)
+		require.NoError(t, err)
+		defer client.Close()
+		m, mimeType, err := client.fetchManifest(context.Background(), dr, tagOrDigest)
+		require.NoError(t, err)
+		assert.Equal(t, manifestBody, m)
+		assert.Equal(t, "application/vnd.oci.image.manifest.v1+json", mimeType)
+		return requests.Load()
+	}
+	fetchBlob := func(blobDigest digest.Digest) int32 {
+		requests.Store(0)
+		client, err := newDockerClient(sys, registryURL.Host, registryURL.Host)
+		require.NoError(t, err)
+		defer client.Close()
+		r, size, err := client.getBlob(context.Background(), dr, types.BlobInfo{Digest: blobDigest, Size: int64(len(configBody))}, memory.New(), nil)
+		require.NoError(t, err)
+		defer r.Close()
+		data, err := io.ReadAll(r)
+		require.NoError(t, err)
+		assert.Equal(t, configBody, data)
+		assert.Equal(t, int64(len(configBody)), size)
+		return requests.Load()
+	}
+
+	// Content fetched by digest is not fetched again.
+	assert.Equal(t, int32(1), fetchManifest(manifestDigest.String()))
+	assert.Equal(t, int32(0), fetchManifest(manifestDigest.String()))
+	assert.Equal(t, int32(1), fetchBlob(configDigest))
+	assert.Equal(t, int32(0), fetchBlob(configDigest))
+	// Blobs which don’t match their digest are not recorded.
+	assert.Equal(t, int32(1), fetchBlob(badBlobDigest))
+	assert.Equal(t, int32(1), fetchBlob(badBlobDigest))
+
+	// A fresh response to a tag is used without revalidation.
+	tagCacheControl.Store("max-age=3600")
+	assert.Equal(t, int32(1), fetchManifest("latest"))
+	assert.Equal(t, int32(0), fetchManifest("latest"))
+
+	// A response which must not be stored is not used.
+	sys.DockerHTTPCacheDir = t.TempDir()
+	tagCacheControl.Store("no-store")
+	assert.Equal(t, int32(1), fetchManifest("latest"))
+	assert.Equal(t, int32(1), fetchManifest("latest"))
+	assert.Equal(t, int32(0), notModified.Load())
+
+	// A stale response is revalidated using the ETag.
+	sys.DockerHTTPCacheDir = t.TempDir()
+	tagCacheControl.Store("no-cache")
+	assert.Equal(t, int32(1), fetchManifest("latest"))
+	assert.Equal(t, int32(1), fetchManifest("latest"))
+	assert.Equal(t, int32(1), notModified.Load())
+}
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"slices"
 
 	"github.com/containers/image/v5/docker/reference"
 	"github.com/containers/image/v5/internal/imagedestination/impl"
@@ -15,8 +16,11 @@ import (
 	"github.com/containers/image/v5/internal/private"
 	"github.com/containers/image/v5/internal/streamdigest"
 	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/pkg/compression"
+	compressiontypes "github.com/containers/image/v5/pkg/compression/types"
 	"github.com/containers/image/v5/types"
 	"github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/sirupsen/logrus"
 )
 
@@ -34,6 +38,9 @@ type Destination struct {
 	// Other state.
 	config []byte
 	sysCtx *types.SystemContext
+	// layerExists is set by SkipExistingLayers; nil if all layers are written.
+	layerExists  func(ctx context.Context, chainID digest.Digest) (bool, error)
+	layerDiffIDs map[int]digest.Digest // Uncompressed digests of layers seen so far, by layer index; only used with layerExists.
 }
 
 // NewDestination returns a tarfile.Destination adding images to the specified Writer.
@@ -44,19 +51,48 @@ func NewDestination(sys *types.SystemContext, archive *Writer, transportName str
 	if ref != nil {
 		repoTags = append(repoTags, ref)
 	}
+	supportedManifestMIMETypes := []string{
+		manifest.DockerV2Schema2MediaType, // We rely on the types.Image.UpdatedImage schema conversion capabilities.
+	}
+	desiredLayerCompression := types.Decompress
+	var layerCompressionFormat *compressiontypes.Algorithm
+	switch archive.options.LayerCompression {
+	case LayerCompressionPreserve:
+		// Schema2 can’t represent zstd layers.
+		supportedManifestMIMETypes = append(supportedManifestMIMETypes, imgspecv1.MediaTypeImageManifest)
+		desiredLayerCompression = types.PreserveOriginal
+	case LayerCompressionGzip:
+		desiredLayerCompression = types.Compress
+		layerCompressionFormat = &compression.Gzip
+	}
+	if archive.options.PreserveOCIManifests && !slices.Contains(supportedManifestMIMETypes, imgspecv1.MediaTypeImageManifest) {
+		supportedManifestMIMETypes = append(supportedManifestMIMETypes, imgspecv1.MediaTypeImageManifest)
+	}
+	if archive.acceptsManifestLists() {
+		supportedManifestMIMETypes = append(supportedManifestMIMETypes, manifest.DockerV2ListMediaType, imgspecv1.MediaTypeImageIndex)
+		if !slices.Contains(supportedManifestMIMETypes, imgspecv1.MediaTypeImageManifest) {
+			// Instances of OCI indexes must be OCI manifests.
+			supportedManifestMIMETypes = append(supportedManifestMIMETypes, imgspecv1.MediaTypeImageManifest)
+		}
+	}
 	dest := &Destination{
 		PropertyMethodsInitialize: impl.PropertyMethods(impl.Properties{
-			SupportedManifestMIMETypes: []string{
-				manifest.DockerV2Schema2MediaType, // We rely on the types.Image.UpdatedImage schema conversion capabilities.
-			},
-			DesiredLayerCompression:        types.Decompress,
+			SupportedManifestMIMETypes:     supportedManifestMIMETypes,
+			DesiredLayerCompression:        desiredLayerCompression,
 			AcceptsForeignLayerURLs:        false,
 			MustMatchRuntimeOS:             false,
 			IgnoresEmbeddedDockerReference: false, // N/A, we only accept schema2 images where EmbeddedDockerReferenceConflicts() is always false.
 			// The code _is_ actually thread-safe, but apart from computing sizes/digests of layers where
 			// this is unknown in advance, the actual copy is serialized by d.archive, so there probably isn’t
 			// much benefit from concurrency, mostly just extra CPU, memory and I/O contention.
-			HasThreadSafePutBlob: false,
+			// With WriterOptions.StageLayers, layers are read concurrently into temporary files, and only
+			// copying them into the archive is serialized.
+			HasThreadSafePutBlob: archive.options.StageLayers,
+			Capabilities: private.DestinationCapabilities{
+				Referrers:              types.OptionalBoolFalse,
+				Deletion:               types.OptionalBoolFalse,
+				LayerCompressionFormat: layerCompressionFormat,
+			},
 		}),
 		NoPutBlobPartialInitialize: stubs.NoPutBlobPartialRaw(transportName),
 		NoSignaturesInitialize:     stubs.NoSignatures("Storing signatures for docker tar files is not supported"),
@@ -75,6 +111,45 @@ func (d *Destination) AddRepoTags(tags []reference.NamedTagged) {
 	d.repoTags = append(d.repoTags, tags...)
 }
 
+// SkipExistingLayers makes d omit layers from the archive if layerExists reports that the consumer of the archive
+// already contains a layer with the specified chain ID (as computed by ChainID), so that it does not need to read the layer file.
+// This only works for layers written in order, with a known layer index, and only with LayerCompressionNone
+// (otherwise the DiffIDs of layers are not known when they are written); other layers are always written.
+// NOTE: The resulting archive is not a complete image; use this only if the archive is consumed immediately.
+func (d *Destination) SkipExistingLayers(layerExists func(ctx context.Context, chainID digest.Digest) (bool, error)) {
+	d.layerExists = layerExists
+	d.layerDiffIDs = map[int]digest.Digest{}
+}
+
+// recordLayerDiffIDLocked records that the layer at layerIndex (if not nil) has the uncompressed digest diffID.
+// The caller must have locked the Writer.
+func (d *Destination) recordLayerDiffIDLocked(layerIndex *int, diffID digest.Digest) {
+	if d.layerExists != nil && layerIndex != nil && d.archive.options.LayerCompression == LayerCompressionNone {
+		d.layerDiffIDs[*layerIndex] = diffID
+	}
+}
+
+// layerCanBeOmittedLocked returns true if the layer at layerIndex (if not nil), which must have already been recorded
+// using recordLayerDiffIDLocked, does not need to be included in the archive.
+// The caller must have locked the Writer.
+func (d *Destination) layerCanBeOmittedLocked(ctx context.Context, layerIndex *int) (bool, error) {
+	if d.layerExists == nil || layerIndex == nil || d.archive.options.LayerCompression != LayerCompressionNone {
+		return false, nil
+	}
+	chainID := digest.Digest("")
+	for i := 0; i <= *layerIndex; i++ {
+		diffID, ok := d.layerDiffIDs[i]
+		if !ok { // Layers were not written in order, we can’t tell.
+			return false, nil
+		}
+		if err := diffID.Validate(); err != nil { // Make sure the chainID computation is unambiguous.
+			return false, nil
+		}
+		chainID = ChainID(chainID, diffID)
+	}
+	return d.layerExists(ctx, chainID)
+}
+
 // PutBlobWithOptions writes contents of stream and returns data representing the result.
 // inputInfo.Digest can be optionally provided if known; if provided, and stream is read to the end without error, the digest MUST match the stream contents.
 // inputInfo.Size is the expected length of stream, if known.
@@ -83,15 +158,40 @@ func (d *Destination) AddRepoTags(tags []reference.NamedTagged) {
 // to any other readers for download using the supplied digest.
 // If stream.Read() at any time, ESPECIALLY at end of input, returns an error, PutBlobWithOptions MUST 1) fail, and 2) delete any data stored so far.
 func (d *Destination) PutBlobWithOptions(ctx context.Context, stream io.Reader, inputInfo types.BlobInfo, options private.PutBlobOptions) (private.UploadedBlob, error) {
+	// With WriterOptions.StageLayers, stage layers in temporary files without holding the lock, so that
+	// multiple layers can be read concurrently.
+	stage := d.archive.options.StageLayers && !options.IsConfig
+	if stage && inputInfo.Digest != "" {
+		// Don’t bother staging a blob which has already been sent.
+		reused, reusedInfo, err := d.TryReusingBlobWithOptions(ctx, inputInfo, private.TryReusingBlobOptions{Cache: options.Cache, LayerIndex: options.LayerIndex})
+		if err != nil {
+			return private.UploadedBlob{}, err
+		}
+		if reused {
+			// Read the rest of the stream so that the caller can validate its digest.
+			if _, err := io.Copy(io.Discard, stream); err != nil {
+				return private.UploadedBlob{}, err
+			}
+			return private.UploadedBlob{Digest: reusedInfo.Digest, Size: reusedInfo.Size}, nil
+		}
+	}
 	// Ouch, we need to stream the blob into a temporary file just to determine the size.
 	// When the layer is decompressed, we also have to generate the digest on uncompressed data.
-	if inputInfo.Size == -1 || inputInfo.Digest == "" {
-		logrus.Debugf("docker tarfile: input with unknown size, streaming to disk first ...")
+	if inputInfo.Size == -1 || inputInfo.Digest == "" || stage {
+		if stage {
+			logrus.Debugf("docker tarfile: staging layer on disk first ...")
+		} else {
+			logrus.Debugf("docker tarfile: input with unknown size, streaming to disk first ...")
+		}
+		expectedSize := inputInfo.Size
 		streamCopy, cleanup, err := streamdigest.ComputeBlobInfo(d.sysCtx, stream, &inputInfo)
 		if err != nil {
 			return private.UploadedBlob{}, err
 		}
 		defer cleanup()
+		if expectedSize != -1 && inputInfo.Size != expectedSize {
+			return private.UploadedBlob{}, fmt.Errorf("Size mismatch when copying %s, expected %d, got %d", inputInfo.Digest, expectedSize, inputInfo.Size)
+		}
 		stream = streamCopy
 		logrus.Debugf("... streaming done")
 	}
@@ -107,6 +207,17 @@ func (d *Destination) PutBlobWithOptions(ctx context.Context, stream io.Reader,
 		return private.UploadedBlob{}, err
 	}
 	if ok {
+		if options.IsConfig {
+			// The config was written for a different image with the same config (e.g. with a different tag, or already
+			// present in an archive we are appending to); we still need its contents for the legacy metadata.
+			buf, err := iolimits.ReadAtMost(stream, iolimits.MaxConfigBodySize)
+			if err != nil {
+				return private.UploadedBlob{}, fmt.Errorf("reading Config file stream: %w", err)
+			}
+			d.config = buf
+		} else {
+			d.recordLayerDiffIDLocked(options.LayerIndex, reusedInfo.Digest)
+		}
 		return private.UploadedBlob{Digest: reusedInfo.Digest, Size: reusedInfo.Size}, nil
 	}
 
@@ -120,15 +231,29 @@ func (d *Destination) PutBlobWithOptions(ctx context.Context, stream io.Reader,
 		if err != nil {
 			return private.UploadedBlob{}, err
 		}
-		if err := d.archive.sendFileLocked(configPath, inputInfo.Size, bytes.NewReader(buf)); err != nil {
+		if err := d.archive.sendBlobLocked(ctx, configPath, inputInfo.Digest, inputInfo.Size, bytes.NewReader(buf), !options.SkipDigestVerification); err != nil {
 			return private.UploadedBlob{}, fmt.Errorf("writing Config file: %w", err)
 		}
 	} else {
+		d.recordLayerDiffIDLocked(options.LayerIndex, inputInfo.Digest)
+		omit, err := d.layerCanBeOmittedLocked(ctx, options.LayerIndex)
+		if err != nil {
+			return private.UploadedBlob{}, err
+		}
+		if omit {
+			logrus.Debugf("docker tarfile: omitting layer %s, it already exists in the destination", inputInfo.Digest)
+			// Read the rest of the stream so that the caller can validate its digest.
+			if _, err := io.Copy(io.Discard, stream); err != nil {
+				return private.UploadedBlob{}, err
+			}
+			// Don’t record the blob, so that it can be written if it is used again in a position where it is not known to exist.
+			return private.UploadedBlob{Digest: inputInfo.Digest, Size: inputInfo.Size}, nil
+		}
 		layerPath, err := d.archive.physicalLayerPath(inputInfo.Digest)
 		if err != nil {
 			return private.UploadedBlob{}, err
 		}
-		if err := d.archive.sendFileLocked(layerPath, inputInfo.Size, stream); err != nil {
+		if err := d.archive.sendBlobLocked(ctx, layerPath, inputInfo.Digest, inputInfo.Size, stream, !options.SkipDigestVerification); err != nil {
 			return private.UploadedBlob{}, err
 		}
 	}
@@ -150,27 +275,37 @@ func (d *Destination) TryReusingBlobWithOptions(ctx context.Context, info types.
 	}
 	defer d.archive.unlock()
 
-	return d.archive.tryReusingBlobLocked(info)
+	reused, reusedInfo, err := d.archive.tryReusingBlobLocked(info)
+	if err != nil || !reused {
+		return reused, reusedInfo, err
+	}
+	d.recordLayerDiffIDLocked(options.LayerIndex, reusedInfo.Digest)
+	return true, reusedInfo, nil
 }
 
 // PutManifest writes manifest to the destination.
-// The instanceDigest value is expected to always be nil, because this transport does not support manifest lists, so
-// there can be no secondary manifests.
+// If instanceDigest is not nil, it contains a digest of the specific manifest instance to write the manifest for
+// (when the primary manifest is a manifest list); this should always be nil if the primary manifest is not a manifest list.
+// Manifest lists are only supported with WriterOptions.MultiPlatform.
 // FIXME? This should also receive a MIME type if known, to differentiate between schema versions.
 // If the destination is in principle available, refuses this manifest type (e.g. it does not recognize the schema),
 // but may accept a different manifest type, the returned error must be an ManifestTypeRejectedError.
 func (d *Destination) PutManifest(ctx context.Context, m []byte, instanceDigest *digest.Digest) error {
-	if instanceDigest != nil {
+	if instanceDigest == nil && manifest.MIMETypeIsMultiImage(manifest.GuessMIMEType(m)) {
+		return d.putManifestList(ctx, m)
+	}
+	if instanceDigest != nil && !d.archive.acceptsManifestLists() {
 		return errors.New(`Manifest lists are not supported for docker tar files`)
 	}
-	// We do not bother with types.ManifestTypeRejectedError; our .SupportedManifestMIMETypes() above is already providing only one alternative,
-	// so the caller trying a different manifest kind would be pointless.
-	var man manifest.Schema2
-	if err := json.Unmarshal(m, &man); err != nil {
-		return fmt.Errorf("parsing manifest: %w", err)
+	// We do not bother with types.ManifestTypeRejectedError; our .SupportedManifestMIMETypes() above is already providing
+	// the only alternatives we can accept, so the caller trying a different manifest kind would be pointless.
+	configDescriptor, layerDescriptors, err := d.parseManifest(m)
+	if err != nil {
+		return err
 	}
-	if man.SchemaVersion != 2 || man.MediaType != manifest.DockerV2Schema2MediaType {
-		return errors.New("Unsupported manifest type, need a Docker schema 2 manifest")
+	diffIDs, err := d.manifestDiffIDs(layerDescriptors)
+	if err != nil {
+		return err
 	}
 
 	if err := d.archive.lock(); err != nil {
@@ -178,11 +313,100 @@ func (d *Destination) PutManifest(ctx context.Context, m []byte, instanceDigest
 	}
 	defer d.archive.unlock()
 
-	if err := d.archive.writeLegacyMetadataLocked(man.LayersDescriptors, d.config, d.repoTags); err != nil {
+	if instanceDigest != nil {
+		matches, err := manifest.MatchesDigest(m, *instanceDigest)
+		if err != nil {
+			return err
+		}
+		if !matches {
+			return fmt.Errorf("Manifest does not match expected digest %s", instanceDigest.String())
+		}
+		return d.archive.ensureInstanceManifestLocked(ctx, *instanceDigest, m, configDescriptor, layerDescriptors, diffIDs, d.config)
+	}
+
+	if d.archive.writesLegacyMetadata() {
+		if err := d.archive.writeLegacyMetadataLocked(ctx, layerDescriptors, diffIDs, d.config, d.repoTags); err != nil {
+			return err
+		}
+	}
+	if d.archive.writesManifestJSON() {
+		if err := d.archive.ensureManifestItemLocked(layerDescriptors, diffIDs, configDescriptor.Digest, d.repoTags); err != nil {
+			return err
+		}
+	}
+	if d.archive.writesOCILayout() {
+		if d.archive.options.PreserveOCIManifests && manifest.GuessMIMEType(m) == imgspecv1.MediaTypeImageManifest {
+			return d.archive.ensurePreservedOCIManifestLocked(ctx, m, d.repoTags)
+		}
+		if err := d.archive.ensureOCIManifestLocked(ctx, configDescriptor, layerDescriptors, d.repoTags); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putManifestList writes a manifest list m, all instances of which have already been written, to the destination.
+func (d *Destination) putManifestList(ctx context.Context, m []byte) error {
+	if !d.archive.acceptsManifestLists() {
+		return errors.New(`Manifest lists are not supported for docker tar files`)
+	}
+	if err := d.archive.lock(); err != nil {
 		return err
 	}
+	defer d.archive.unlock()
+
+	return d.archive.ensureManifestListLocked(ctx, m, manifest.GuessMIMEType(m), d.repoTags)
+}
 
-	return d.archive.ensureManifestItemLocked(man.LayersDescriptors, man.ConfigDescriptor.Digest, d.repoTags)
+// parseManifest parses m, which must be one of d.SupportedManifestMIMETypes(), and returns descriptors of its config and layers.
+func (d *Destination) parseManifest(m []byte) (manifest.Schema2Descriptor, []manifest.Schema2Descriptor, error) {
+	mimeType := manifest.GuessMIMEType(m)
+	if !slices.Contains(d.SupportedManifestMIMETypes(), mimeType) {
+		if slices.Contains(d.SupportedManifestMIMETypes(), imgspecv1.MediaTypeImageManifest) {
+			return manifest.Schema2Descriptor{}, nil, errors.New("Unsupported manifest type, need a Docker schema 2 or OCI manifest")
+		}
+		return manifest.Schema2Descriptor{}, nil, errors.New("Unsupported manifest type, need a Docker schema 2 manifest")
+	}
+	parsed, err := manifest.FromBlob(m, mimeType)
+	if err != nil {
+		return manifest.Schema2Descriptor{}, nil, fmt.Errorf("parsing manifest: %w", err)
+	}
+	configInfo := parsed.ConfigInfo()
+	configDescriptor := manifest.Schema2Descriptor{
+		MediaType: configInfo.MediaType,
+		Size:      configInfo.Size,
+		Digest:    configInfo.Digest,
+	}
+	layerDescriptors := []manifest.Schema2Descriptor{}
+	for _, l := range parsed.LayerInfos() {
+		layerDescriptors = append(layerDescriptors, manifest.Schema2Descriptor{
+			MediaType: l.MediaType,
+			Size:      l.Size,
+			Digest:    l.Digest,
+			URLs:      l.URLs,
+		})
+	}
+	return configDescriptor, layerDescriptors, nil
+}
+
+// manifestDiffIDs returns the uncompressed digests of layerDescriptors, layers of the image with config d.config.
+func (d *Destination) manifestDiffIDs(layerDescriptors []manifest.Schema2Descriptor) ([]digest.Digest, error) {
+	if d.archive.options.LayerCompression == LayerCompressionNone {
+		// The layers are uncompressed, so their digests are the DiffIDs.
+		res := make([]digest.Digest, 0, len(layerDescriptors))
+		for _, l := range layerDescriptors {
+			res = append(res, l.Digest)
+		}
+		return res, nil
+	}
+	var config imgspecv1.Image // There's a lot of info there, but we only really care about layer DiffIDs.
+	if err := json.Unmarshal(d.config, &config); err != nil {
+		return nil, fmt.Errorf("parsing config: %w", err)
+	}
+	if len(config.RootFS.DiffIDs) != len(layerDescriptors) {
+		return nil, fmt.Errorf("Inconsistent layer count: %d in manifest, %d in config", len(layerDescriptors), len(config.RootFS.DiffIDs))
+	}
+	return config.RootFS.DiffIDs, nil
 }
 
 // CommitWithOptions marks the process of storing the image as successful and asks for the image to be persisted.
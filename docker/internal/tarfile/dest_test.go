@@ -0,0 +1,209 @@
+package tarfile
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/containers/image/v5/internal/blobinfocache"
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/internal/set"
+	"github.com/containers/image/v5/pkg/blobinfocache/memory"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChainID(t *testing.T) {
+	base := digest.Digest("sha256:0000000000000000000000000000000000000000000000000000000000000001")
+	top := digest.Digest("sha256:0000000000000000000000000000000000000000000000000000000000000002")
+	assert.Equal(t, base, ChainID("", base))
+	assert.Equal(t, digest.FromString(base.String()+" "+top.String()), ChainID(base, top))
+}
+
+func TestDestinationSkipExistingLayers(t *testing.T) {
+	ctx := context.Background()
+	cache := blobinfocache.FromBlobInfoCache(memory.New())
+	layers := [][]byte{[]byte("layer 0"), []byte("layer 1"), []byte("layer 2"), []byte("layer 3")}
+	diffIDs := []digest.Digest{}
+	for _, l := range layers {
+		diffIDs = append(diffIDs, digest.FromBytes(l))
+	}
+	existing := set.NewWithValues(
+		ChainID("", diffIDs[0]),
+		ChainID(ChainID("", diffIDs[0]), diffIDs[1]),
+		ChainID("", diffIDs[2]), // Exists, but not on top of the previous layers
+	)
+
+	archive := bytes.Buffer{}
+	writer := NewWriter(&archive)
+	dest := NewDestination(nil, writer, "transport name", nil, nil)
+	dest.SkipExistingLayers(func(ctx context.Context, chainID digest.Digest) (bool, error) {
+		return existing.Contains(chainID), nil
+	})
+	for i, l := range layers {
+		layerIndex := i
+		options := private.PutBlobOptions{Cache: cache, LayerIndex: &layerIndex}
+		if i == 3 {
+			options.LayerIndex = nil // Unknown layer index
+			existing.Add(ChainID(ChainID(ChainID(ChainID("", diffIDs[0]), diffIDs[1]), diffIDs[2]), diffIDs[3]))
+		}
+		res, err := dest.PutBlobWithOptions(ctx, bytes.NewReader(l), types.BlobInfo{Digest: diffIDs[i], Size: int64(len(l))}, options)
+		require.NoError(t, err)
+		assert.Equal(t, private.UploadedBlob{Digest: diffIDs[i], Size: int64(len(l))}, res)
+	}
+	// A layer omitted at one position is written if it is used at a position where it does not exist.
+	layerIndex := 4
+	_, err := dest.PutBlobWithOptions(ctx, bytes.NewReader(layers[0]), types.BlobInfo{Digest: diffIDs[0], Size: int64(len(layers[0]))},
+		private.PutBlobOptions{Cache: cache, LayerIndex: &layerIndex})
+	require.NoError(t, err)
+	err = writer.Close()
+	require.NoError(t, err)
+
+	layerFiles := []string{}
+	tr := tar.NewReader(bytes.NewReader(archive.Bytes()))
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		if strings.HasSuffix(hdr.Name, ".tar") {
+			layerFiles = append(layerFiles, hdr.Name)
+		}
+	}
+	expected := []string{}
+	for _, i := range []int{2, 3, 0} {
+		path, err := writer.physicalLayerPath(diffIDs[i])
+		require.NoError(t, err)
+		expected = append(expected, path)
+	}
+	assert.Equal(t, expected, layerFiles)
+}
+
+func TestDestinationVerifiesDigests(t *testing.T) {
+	ctx := context.Background()
+	cache := blobinfocache.FromBlobInfoCache(memory.New())
+	layer := []byte("layer")
+	corrupted := []byte("LAYER")
+
+	// With SkipDigestVerification, the caller is trusted.
+	writer := NewWriter(io.Discard)
+	dest := NewDestination(nil, writer, "transport name", nil, nil)
+	_, err := dest.PutBlobWithOptions(ctx, bytes.NewReader(corrupted), types.BlobInfo{Digest: digest.FromBytes(layer), Size: int64(len(layer))},
+		private.PutBlobOptions{Cache: cache, SkipDigestVerification: true})
+	require.NoError(t, err)
+
+	// By default, a digest mismatch fails the write, and the blob is not recorded.
+	for _, options := range []WriterOptions{{}, {Deterministic: true}} {
+		writer, err = NewWriterWithOptions(io.Discard, options)
+		require.NoError(t, err)
+		dest = NewDestination(nil, writer, "transport name", nil, nil)
+		_, err = dest.PutBlobWithOptions(ctx, bytes.NewReader(corrupted), types.BlobInfo{Digest: digest.FromBytes(layer), Size: int64(len(layer))},
+			private.PutBlobOptions{Cache: cache})
+		assert.ErrorContains(t, err, "Digest mismatch")
+		reused, _, err := dest.TryReusingBlobWithOptions(ctx, types.BlobInfo{Digest: digest.FromBytes(layer), Size: int64(len(layer))},
+			private.TryReusingBlobOptions{Cache: cache})
+		require.NoError(t, err)
+		assert.False(t, reused)
+	}
+}
+
+// barrierReader is an io.Reader which, on the first read, waits until all readers sharing barrier have started reading.
+type barrierReader struct {
+	reader  io.Reader
+	barrier *sync.WaitGroup
+	once    sync.Once
+}
+
+func (r *barrierReader) Read(p []byte) (int, error) {
+	var err error
+	r.once.Do(func() {
+		r.barrier.Done()
+		done := make(chan struct{})
+		go func() {
+			r.barrier.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			err = errors.New("timed out waiting for concurrent reads")
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+	return r.reader.Read(p)
+}
+
+func TestDestinationStageLayers(t *testing.T) {
+	ctx := context.Background()
+	cache := blobinfocache.FromBlobInfoCache(memory.New())
+	layers := [][]byte{[]byte("layer 0"), []byte("layer 1"), []byte("layer 2")}
+
+	archive := bytes.Buffer{}
+	writer, err := NewWriterWithOptions(&archive, WriterOptions{StageLayers: true})
+	require.NoError(t, err)
+	dest := NewDestination(nil, writer, "transport name", nil, nil)
+	assert.True(t, dest.HasThreadSafePutBlob())
+
+	// All layers are read concurrently.
+	barrier := sync.WaitGroup{}
+	barrier.Add(len(layers))
+	wg := sync.WaitGroup{}
+	errs := make([]error, len(layers))
+	for i, l := range layers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			layerIndex := i
+			_, errs[i] = dest.PutBlobWithOptions(ctx, &barrierReader{reader: bytes.NewReader(l), barrier: &barrier},
+				types.BlobInfo{Digest: digest.FromBytes(l), Size: int64(len(l))}, private.PutBlobOptions{Cache: cache, LayerIndex: &layerIndex})
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+	// A size mismatch is detected.
+	_, err = dest.PutBlobWithOptions(ctx, bytes.NewReader([]byte("short")), types.BlobInfo{Digest: digest.FromString("short"), Size: 100},
+		private.PutBlobOptions{Cache: cache})
+	assert.Error(t, err)
+	// An already written layer is reused.
+	res, err := dest.PutBlobWithOptions(ctx, bytes.NewReader(layers[0]), types.BlobInfo{Digest: digest.FromBytes(layers[0]), Size: int64(len(layers[0]))},
+		private.PutBlobOptions{Cache: cache})
+	require.NoError(t, err)
+	assert.Equal(t, private.UploadedBlob{Digest: digest.FromBytes(layers[0]), Size: int64(len(layers[0]))}, res)
+	err = writer.Close()
+	require.NoError(t, err)
+
+	layerContents := map[string][]byte{}
+	tr := tar.NewReader(bytes.NewReader(archive.Bytes()))
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		if strings.HasSuffix(hdr.Name, ".tar") {
+			contents, err := io.ReadAll(tr)
+			require.NoError(t, err)
+			layerContents[hdr.Name] = contents
+		}
+	}
+	expected := map[string][]byte{}
+	for _, l := range layers {
+		path, err := writer.physicalLayerPath(digest.FromBytes(l))
+		require.NoError(t, err)
+		expected[path] = l
+	}
+	assert.Equal(t, expected, layerContents)
+}
@@ -0,0 +1,158 @@
+package tarfile
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const (
+	// entryIndexFileName is the last entry of archives created with WriterOptions.EntryIndex.
+	// It contains entryIndexData as JSON, padded with whitespace, and followed by entryIndexFooterLen bytes of
+	// entryIndexMagic and the offset of the tar header of the entry itself, as 16 hexadecimal digits, on a separate line;
+	// the footer ends exactly at the end of a tar block, so it is found at a fixed offset from the end of the archive.
+	entryIndexFileName  = "containers-entry-index.json"
+	entryIndexMagic     = "tar-index-v1: "
+	entryIndexFooterLen = 1 + len(entryIndexMagic) + 16 + 1
+	tarBlockSize        = 512
+	tarTrailerLen       = 2 * tarBlockSize // The end-of-archive marker written by tar.Writer.Close
+	// maxEntryIndexSize is the maximum accepted size of the entry index.
+	maxEntryIndexSize = 16 * 1024 * 1024
+)
+
+// entryIndexData is the JSON representation of the entry index.
+type entryIndexData struct {
+	Entries []entryIndexEntry `json:"entries"`
+}
+
+type entryIndexEntry struct {
+	Name   string `json:"name"`   // path.Clean-ed
+	Offset int64  `json:"offset"` // Of the (first) tar header of the entry, from the start of the archive
+}
+
+// recordEntryOffsetLocked records, with WriterOptions.EntryIndex, that an entry called name starts at the current position.
+// The caller must have locked the Writer.
+func (w *Writer) recordEntryOffsetLocked(name string) error {
+	if w.entryOffsets == nil {
+		return nil
+	}
+	if err := w.tar.Flush(); err != nil { // Write the padding of the previous entry, so that the new entry starts at its header.
+		return err
+	}
+	w.entryOffsets = append(w.entryOffsets, entryIndexEntry{Name: path.Clean(name), Offset: w.entryIndexBase + w.written})
+	return nil
+}
+
+// writeEntryIndexLocked writes the entry index, as the last entry of the archive.
+// The caller must have locked the Writer.
+func (w *Writer) writeEntryIndexLocked(ctx context.Context) error {
+	if err := w.tar.Flush(); err != nil {
+		return err
+	}
+	headerOffset := w.entryIndexBase + w.written
+	data, err := json.Marshal(entryIndexData{Entries: w.entryOffsets})
+	if err != nil {
+		return fmt.Errorf("marshaling the entry index: %w", err)
+	}
+	size := len(data) + entryIndexFooterLen
+	if rem := size % tarBlockSize; rem != 0 {
+		data = append(data, bytes.Repeat([]byte{' '}, tarBlockSize-rem)...)
+	}
+	data = fmt.Appendf(data, "\n%s%016x\n", entryIndexMagic, headerOffset)
+
+	hdr, err := tar.FileInfoHeader(&tarFI{path: entryIndexFileName, size: int64(len(data))}, "")
+	if err != nil {
+		return err
+	}
+	w.entryOffsets = nil // Don’t record the index itself.
+	return w.writeFileEntryLocked(ctx, hdr, bytes.NewReader(data))
+}
+
+// readEntryIndex returns the entry index of the archive in file, if the archive was created with WriterOptions.EntryIndex,
+// as a map from path.Clean-ed entry names to offsets of their tar headers; or nil if the archive does not contain an index.
+func readEntryIndex(file *os.File) (map[string]int64, error) {
+	fi, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := fi.Size()
+	footerEnd := size - tarTrailerLen
+	if !fi.Mode().IsRegular() || footerEnd < int64(tarBlockSize+entryIndexFooterLen) {
+		return nil, nil
+	}
+	footer := make([]byte, entryIndexFooterLen)
+	if _, err := file.ReadAt(footer, footerEnd-int64(entryIndexFooterLen)); err != nil {
+		return nil, err
+	}
+	offsetHex, ok := strings.CutPrefix(string(footer), "\n"+entryIndexMagic)
+	if !ok || !strings.HasSuffix(offsetHex, "\n") {
+		return nil, nil
+	}
+	headerOffset, err := strconv.ParseInt(strings.TrimSuffix(offsetHex, "\n"), 16, 64)
+	if err != nil || headerOffset < 0 || headerOffset >= footerEnd || headerOffset%tarBlockSize != 0 {
+		return nil, nil // Not an index written by us
+	}
+
+	tr := tar.NewReader(io.NewSectionReader(file, headerOffset, footerEnd-headerOffset))
+	hdr, err := tr.Next()
+	if err != nil || path.Clean(hdr.Name) != entryIndexFileName || hdr.Typeflag != tar.TypeReg {
+		return nil, nil // Not an index written by us
+	}
+	if hdr.Size > maxEntryIndexSize {
+		return nil, fmt.Errorf("entry index is too large (%d bytes)", hdr.Size)
+	}
+	data, err := io.ReadAll(tr)
+	if err != nil {
+		return nil, fmt.Errorf("reading entry index: %w", err)
+	}
+	if !bytes.HasSuffix(data, footer) {
+		return nil, errors.New("entry index does not end at the end of the archive")
+	}
+	var index entryIndexData
+	if err := json.Unmarshal(data[:len(data)-entryIndexFooterLen], &index); err != nil {
+		return nil, fmt.Errorf("parsing entry index: %w", err)
+	}
+	res := make(map[string]int64, len(index.Entries))
+	for _, e := range index.Entries {
+		if e.Offset < 0 || e.Offset >= headerOffset {
+			return nil, fmt.Errorf("invalid offset %d of entry %q in the entry index", e.Offset, e.Name)
+		}
+		res[e.Name] = e.Offset
+	}
+	return res, nil
+}
+
+// findIndexedTarComponent returns a header and a reader of the entry at offset within inputFile,
+// which must be called componentPath.
+func findIndexedTarComponent(inputFile io.ReaderAt, offset int64, componentPath string) (*tar.Reader, *tar.Header, error) {
+	t := tar.NewReader(io.NewSectionReader(inputFile, offset, 1<<63-1-offset))
+	h, err := t.Next()
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading entry %q using the entry index: %w", componentPath, err)
+	}
+	if path.Clean(h.Name) != componentPath {
+		return nil, nil, fmt.Errorf("entry index refers to %q instead of %q", h.Name, componentPath)
+	}
+	return t, h, nil
+}
+
+// findTarComponentInFile is findTarComponent for the archive file f, using r.entryIndex if available.
+func (r *Reader) findTarComponentInFile(f *os.File, componentPath string) (*tar.Reader, *tar.Header, error) {
+	if r.entryIndex == nil {
+		return findTarComponent(f, componentPath)
+	}
+	componentPath = path.Clean(componentPath)
+	offset, ok := r.entryIndex[componentPath]
+	if !ok {
+		return nil, nil, nil
+	}
+	return findIndexedTarComponent(f, offset, componentPath)
+}
@@ -0,0 +1,178 @@
+package tarfile
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/pkg/compression"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEntryIndex(t *testing.T) {
+	firstLayer := []byte("first layer")
+	secondLayer := []byte("second layer")
+
+	// assertIndexComplete checks that the archive at path has an entry index, and that it describes all entries.
+	assertIndexComplete := func(path string) *Reader {
+		reader, err := NewReaderFromFile(nil, path)
+		require.NoError(t, err)
+		require.NotNil(t, reader.entryIndex)
+		entries, err := reader.scanEntries()
+		require.NoError(t, err)
+		names := []string{}
+		for _, e := range entries {
+			if e.path != entryIndexFileName {
+				names = append(names, e.path)
+			}
+		}
+		indexed := []string{}
+		for name := range reader.entryIndex {
+			indexed = append(indexed, name)
+		}
+		assert.ElementsMatch(t, names, indexed)
+		f, err := os.Open(path)
+		require.NoError(t, err)
+		defer f.Close()
+		for name, offset := range reader.entryIndex {
+			_, _, err := findIndexedTarComponent(f, offset, name)
+			assert.NoError(t, err, name)
+		}
+		return reader
+	}
+
+	for _, options := range []WriterOptions{
+		{EntryIndex: true},
+		{EntryIndex: true, Format: FormatDockerSaveAndOCILayout},
+		{EntryIndex: true, Deterministic: true},
+	} {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "archive.tar")
+		f, err := os.Create(path)
+		require.NoError(t, err)
+		writer, err := NewWriterWithOptions(f, options)
+		require.NoError(t, err)
+		writeTestImage(t, writer, "example.com/first:tag", "first", firstLayer)
+		err = writer.Close()
+		require.NoError(t, err)
+		err = f.Close()
+		require.NoError(t, err)
+
+		reader := assertIndexComplete(path)
+		require.Len(t, reader.Manifest, 1)
+		stream, err := reader.openTarComponent(reader.Manifest[0].Layers[0])
+		require.NoError(t, err)
+		contents, err := io.ReadAll(stream)
+		require.NoError(t, err)
+		stream.Close()
+		assert.Equal(t, firstLayer, contents)
+		_, err = reader.openTarComponent("does-not-exist")
+		assert.ErrorIs(t, err, os.ErrNotExist)
+		err = reader.Close()
+		require.NoError(t, err)
+
+		if options.Deterministic {
+			continue
+		}
+		// Appending to the archive updates the index.
+		appendOptions := options
+		appendOptions.Format = FormatDockerSave
+		writer, err = OpenWriterForAppendWithOptions(path, appendOptions)
+		require.NoError(t, err)
+		writeTestImage(t, writer, "example.com/second:tag", "second", firstLayer, secondLayer)
+		err = writer.Close()
+		require.NoError(t, err)
+		reader = assertIndexComplete(path)
+		require.Len(t, reader.Manifest, 2)
+		stream, err = reader.openTarComponent(reader.Manifest[1].Layers[1])
+		require.NoError(t, err)
+		contents, err = io.ReadAll(stream)
+		require.NoError(t, err)
+		stream.Close()
+		assert.Equal(t, secondLayer, contents)
+
+		// The index is not copied by WriteWithoutImages.
+		named, err := reference.ParseNormalizedNamed("example.com/first:tag")
+		require.NoError(t, err)
+		prunedPath := filepath.Join(dir, "pruned.tar")
+		pruned, err := os.Create(prunedPath)
+		require.NoError(t, err)
+		err = reader.WriteWithoutImages(pruned, []reference.NamedTagged{named.(reference.NamedTagged)}, nil)
+		require.NoError(t, err)
+		err = pruned.Close()
+		require.NoError(t, err)
+		err = reader.Close()
+		require.NoError(t, err)
+		prunedReader, err := NewReaderFromFile(nil, prunedPath)
+		require.NoError(t, err)
+		assert.Nil(t, prunedReader.entryIndex)
+		require.Len(t, prunedReader.Manifest, 1)
+		err = prunedReader.Close()
+		require.NoError(t, err)
+	}
+
+	// Archives without an index are read by scanning them.
+	path := filepath.Join(t.TempDir(), "archive.tar")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	writer := NewWriter(f)
+	writeTestImage(t, writer, "example.com/first:tag", "first", firstLayer)
+	err = writer.Close()
+	require.NoError(t, err)
+	err = f.Close()
+	require.NoError(t, err)
+	reader, err := NewReaderFromFile(nil, path)
+	require.NoError(t, err)
+	defer reader.Close()
+	assert.Nil(t, reader.entryIndex)
+	require.Len(t, reader.Manifest, 1)
+
+	// An index is rejected with compressed archives.
+	_, err = NewWriterWithOptions(io.Discard, WriterOptions{EntryIndex: true, Compression: &compression.Gzip})
+	assert.Error(t, err)
+}
+
+func TestReadEntryIndexInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.tar")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	writer, err := NewWriterWithOptions(f, WriterOptions{EntryIndex: true})
+	require.NoError(t, err)
+	writeTestImage(t, writer, "example.com/first:tag", "first", []byte("layer"))
+	err = writer.Close()
+	require.NoError(t, err)
+	err = f.Close()
+	require.NoError(t, err)
+	orig, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	footerStart := len(orig) - tarTrailerLen - entryIndexFooterLen
+	for _, c := range []struct {
+		name   string
+		offset int
+		value  byte
+	}{
+		{"magic", footerStart + 1, 'X'},
+		{"offset", footerStart + 1 + len(entryIndexMagic) + 15, 'z'},
+		{"offset alignment", footerStart + 1 + len(entryIndexMagic) + 15, '1'},
+	} {
+		data := append([]byte{}, orig...)
+		data[c.offset] = c.value
+		err := os.WriteFile(path, data, 0o644)
+		require.NoError(t, err)
+		f, err := os.Open(path)
+		require.NoError(t, err)
+		index, err := readEntryIndex(f)
+		f.Close()
+		assert.NoError(t, err, c.name)
+		assert.Nil(t, index, c.name)
+		// The archive can still be read by scanning it.
+		reader, err := NewReaderFromFile(nil, path)
+		require.NoError(t, err, c.name)
+		assert.Len(t, reader.Manifest, 1)
+		reader.Close()
+	}
+}
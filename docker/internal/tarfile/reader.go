@@ -8,12 +8,19 @@ import (
 	"io"
 	"os"
 	"path"
+	"slices"
 
 	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/internal/archiveprogress"
 	"github.com/containers/image/v5/internal/iolimits"
+	"github.com/containers/image/v5/internal/seekablezstd"
 	"github.com/containers/image/v5/internal/tmpdir"
+	"github.com/containers/image/v5/manifest"
 	"github.com/containers/image/v5/pkg/compression"
 	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sirupsen/logrus"
 )
 
 // Reader is a ((docker save)-formatted) tar archive that allows random access to any component.
@@ -23,6 +30,15 @@ type Reader struct {
 	path          string         // "" if the archive has already been closed.
 	removeOnClose bool           // Remove file on close if true
 	Manifest      []ManifestItem // Guaranteed to exist after the archive is created.
+	// Manifest lists in the OCI index.json, if any; created with WriterOptions.MultiPlatform, or by (docker save) of multi-platform images.
+	ManifestLists []ManifestListItem
+	// If the archive is a seekable zstd stream with an index, components are read using seekable
+	// instead of decompressing the whole archive; seekableFile is the backing file.
+	seekable     *seekablezstd.Reader
+	seekableFile *os.File
+	// If the archive was created with WriterOptions.EntryIndex, the offsets of tar headers of all entries, indexed by path.Clean-ed names;
+	// components are then read without scanning the archive.
+	entryIndex map[string]int64
 }
 
 // NewReaderFromFile returns a Reader for the specified path.
@@ -32,7 +48,28 @@ func NewReaderFromFile(sys *types.SystemContext, path string) (*Reader, error) {
 	if err != nil {
 		return nil, fmt.Errorf("opening file %q: %w", path, err)
 	}
-	defer file.Close()
+	fileOwned := false // true if the file is used by the returned Reader
+	defer func() {
+		if !fileOwned {
+			file.Close()
+		}
+	}()
+
+	// A seekable zstd archive with an index allows reading individual components without decompressing the whole archive.
+	if fi, err := file.Stat(); err == nil && fi.Mode().IsRegular() {
+		seekable, err := seekablezstd.NewReader(file, fi.Size())
+		switch {
+		case err == nil && seekable.HasIndex():
+			fileOwned = true // Even on failure, initReader closes the Reader, which closes the file.
+			return initReader(&Reader{
+				path:         path,
+				seekable:     seekable,
+				seekableFile: file,
+			})
+		case err != nil && !errors.Is(err, seekablezstd.ErrNotSeekable):
+			return nil, fmt.Errorf("reading seekable zstd archive %q: %w", path, err)
+		}
+	}
 
 	// If the file is seekable and already not compressed we can just return the file itself
 	// as a source. Otherwise we pass the stream to NewReaderFromStream.
@@ -45,7 +82,15 @@ func NewReaderFromFile(sys *types.SystemContext, path string) (*Reader, error) {
 		defer decompressed.Close()
 		stream = decompressed
 		if !isCompressed {
-			return newReader(path, false)
+			entryIndex, err := readEntryIndex(file)
+			if err != nil {
+				logrus.Debugf("Ignoring the entry index of %q: %v", path, err)
+				entryIndex = nil
+			}
+			return initReader(&Reader{
+				path:       path,
+				entryIndex: entryIndex,
+			})
 		}
 	}
 	return NewReaderFromStream(sys, stream)
@@ -83,9 +128,11 @@ func NewReaderFromStream(sys *types.SystemContext, inputStream io.Reader) (*Read
 	//
 	// TODO: This can take quite some time, and should ideally be cancellable
 	//       using a context.Context.
-	if _, err := io.Copy(tarCopyFile, uncompressedStream); err != nil {
+	progress := archiveprogress.NewUnpacking(sys)
+	if _, err := io.Copy(tarCopyFile, progress.Reader(uncompressedStream)); err != nil {
 		return nil, fmt.Errorf("copying contents to temporary file %q: %w", tarCopyFile.Name(), err)
 	}
+	progress.Done()
 	succeeded = true
 
 	return newReader(tarCopyFile.Name(), true)
@@ -95,10 +142,15 @@ func NewReaderFromStream(sys *types.SystemContext, inputStream io.Reader) (*Read
 // The caller should call .Close() on the returned archive when done.
 func newReader(path string, removeOnClose bool) (*Reader, error) {
 	// This is a valid enough archive, except Manifest is not yet filled.
-	r := Reader{
+	return initReader(&Reader{
 		path:          path,
 		removeOnClose: removeOnClose,
-	}
+	})
+}
+
+// initReader fills r.Manifest, and returns r.
+// On failure, it closes r.
+func initReader(r *Reader) (*Reader, error) {
 	succeeded := false
 	defer func() {
 		if !succeeded {
@@ -119,15 +171,23 @@ func newReader(path string, removeOnClose bool) (*Reader, error) {
 	if err := json.Unmarshal(bytes, &r.Manifest); err != nil {
 		return nil, fmt.Errorf("decoding tar manifest.json: %w", err)
 	}
+	manifestLists, err := r.readManifestLists()
+	if err != nil {
+		return nil, err
+	}
+	r.ManifestLists = manifestLists
 
 	succeeded = true
-	return &r, nil
+	return r, nil
 }
 
 // Close removes resources associated with an initialized Reader, if any.
 func (r *Reader) Close() error {
 	path := r.path
 	r.path = "" // Mark the archive as closed
+	if r.seekableFile != nil {
+		return r.seekableFile.Close()
+	}
 	if r.removeOnClose {
 		return os.Remove(path)
 	}
@@ -174,10 +234,140 @@ func (r *Reader) ChooseManifestItem(ref reference.NamedTagged, sourceIndex int)
 	}
 }
 
+// readManifestLists returns the manifest lists listed in the OCI index.json of the archive, if any, grouping their tags.
+func (r *Reader) readManifestLists() ([]ManifestListItem, error) {
+	indexBytes, err := r.readTarComponent(imgspecv1.ImageIndexFile, iolimits.MaxTarFileManifestSize)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var index imgspecv1.Index
+	if err := json.Unmarshal(indexBytes, &index); err != nil {
+		return nil, fmt.Errorf("decoding tar %s: %w", imgspecv1.ImageIndexFile, err)
+	}
+	res := []ManifestListItem{}
+	for _, desc := range index.Manifests {
+		if !manifest.MIMETypeIsMultiImage(desc.MediaType) {
+			continue
+		}
+		if err := desc.Digest.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid manifest list digest %q in %s: %w", desc.Digest, imgspecv1.ImageIndexFile, err)
+		}
+		i := slices.IndexFunc(res, func(item ManifestListItem) bool { return item.Digest == desc.Digest })
+		if i == -1 {
+			i = len(res)
+			res = append(res, ManifestListItem{MediaType: desc.MediaType, Digest: desc.Digest, RepoTags: []string{}})
+		}
+		if name, ok := desc.Annotations[containerdImageNameAnnotation]; ok && !slices.Contains(res[i].RepoTags, name) {
+			res[i].RepoTags = append(res[i].RepoTags, name)
+		}
+	}
+	if len(res) == 0 {
+		return nil, nil
+	}
+	return res, nil
+}
+
+// ociManifestForConfig returns an image manifest from the OCI layout of the archive, listed in index.json directly
+// or as an instance of a listed manifest list, which refers to configDigest; or nil if there is no such manifest.
+func (r *Reader) ociManifestForConfig(configDigest digest.Digest) ([]byte, error) {
+	indexBytes, err := r.readTarComponent(imgspecv1.ImageIndexFile, iolimits.MaxTarFileManifestSize)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var index imgspecv1.Index
+	if err := json.Unmarshal(indexBytes, &index); err != nil {
+		return nil, fmt.Errorf("decoding tar %s: %w", imgspecv1.ImageIndexFile, err)
+	}
+	candidates := []digest.Digest{}
+	for _, desc := range index.Manifests {
+		switch {
+		case desc.MediaType == imgspecv1.MediaTypeImageManifest:
+			candidates = append(candidates, desc.Digest)
+		case manifest.MIMETypeIsMultiImage(desc.MediaType):
+			listBlob, err := r.readManifestBlob(desc.Digest)
+			if err != nil {
+				if errors.Is(err, os.ErrNotExist) { // E.g. (docker save) of only some platforms of an image
+					continue
+				}
+				return nil, err
+			}
+			list, err := manifest.ListFromBlob(listBlob, desc.MediaType)
+			if err != nil {
+				return nil, fmt.Errorf("parsing manifest list %s: %w", desc.Digest, err)
+			}
+			candidates = append(candidates, list.Instances()...)
+		}
+	}
+	for _, candidate := range candidates {
+		blob, err := r.readManifestBlob(candidate)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) { // An instance of a list which was not exported
+				continue
+			}
+			return nil, err
+		}
+		if manifest.GuessMIMEType(blob) != imgspecv1.MediaTypeImageManifest {
+			continue
+		}
+		m, err := manifest.OCI1FromManifest(blob)
+		if err != nil {
+			return nil, fmt.Errorf("parsing manifest %s: %w", candidate, err)
+		}
+		if m.Config.Digest == configDigest {
+			return blob, nil
+		}
+	}
+	return nil, nil
+}
+
+// readManifestBlob returns the manifest with manifestDigest from the OCI layout of the archive, verifying its digest.
+func (r *Reader) readManifestBlob(manifestDigest digest.Digest) ([]byte, error) {
+	blobPath, err := digestPath(manifestDigest)
+	if err != nil {
+		return nil, err
+	}
+	blob, err := r.readTarComponent(blobPath, iolimits.MaxManifestBodySize)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := manifest.MatchesDigest(blob, manifestDigest)
+	if err != nil {
+		return nil, err
+	}
+	if !matches {
+		return nil, fmt.Errorf("manifest %q does not match its digest", blobPath)
+	}
+	return blob, nil
+}
+
+// ChooseManifestList returns the item of r.ManifestLists tagged with ref, and the index of the matching tag,
+// or (nil, -1, nil) if ref does not refer to a manifest list.
+func (r *Reader) ChooseManifestList(ref reference.NamedTagged) (*ManifestListItem, int, error) {
+	refString := ref.String()
+	for i := range r.ManifestLists {
+		for tagIndex, tag := range r.ManifestLists[i].RepoTags {
+			parsedTag, err := reference.ParseNormalizedNamed(tag)
+			if err != nil {
+				return nil, -1, fmt.Errorf("Invalid tag %#v of manifest list %s: %w", tag, r.ManifestLists[i].Digest.String(), err)
+			}
+			if parsedTag.String() == refString {
+				return &r.ManifestLists[i], tagIndex, nil
+			}
+		}
+	}
+	return nil, -1, nil
+}
+
 // tarReadCloser is a way to close the backing file of a tar.Reader when the user no longer needs the tar component.
 type tarReadCloser struct {
 	*tar.Reader
-	backingFile *os.File
+	backingFile io.Closer
 }
 
 func (t *tarReadCloser) Close() error {
@@ -185,20 +375,30 @@ func (t *tarReadCloser) Close() error {
 }
 
 // openTarComponent returns a ReadCloser for the specific file within the archive.
-// This is linear scan; we assume that the tar file will have a fairly small amount of files (~layers),
-// and that filesystem caching will make the repeated seeking over the (uncompressed) tarPath cheap enough.
+// Unless the archive has an entry index (see WriterOptions.EntryIndex), this is linear scan; we assume that the tar file
+// will have a fairly small amount of files (~layers), and that filesystem caching will make the repeated seeking over
+// the (uncompressed) tarPath cheap enough.
 // It is safe to call this method from multiple goroutines simultaneously.
 // The caller should call .Close() on the returned stream.
 func (r *Reader) openTarComponent(componentPath string) (io.ReadCloser, error) {
+	stream, _, err := r.openTarComponentWithHeader(componentPath)
+	return stream, err
+}
+
+// openTarComponentWithHeader is openTarComponent, also returning the tar header of the component.
+func (r *Reader) openTarComponentWithHeader(componentPath string) (io.ReadCloser, *tar.Header, error) {
 	// This is only a sanity check; if anyone did concurrently close ra, this access is technically
 	// racy against the write in .Close().
 	if r.path == "" {
-		return nil, errors.New("Internal error: trying to read an already closed tarfile.Reader")
+		return nil, nil, errors.New("Internal error: trying to read an already closed tarfile.Reader")
+	}
+	if r.seekable != nil {
+		return r.openSeekableTarComponent(componentPath)
 	}
 
 	f, err := os.Open(r.path)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	succeeded := false
 	defer func() {
@@ -207,34 +407,77 @@ func (r *Reader) openTarComponent(componentPath string) (io.ReadCloser, error) {
 		}
 	}()
 
-	tarReader, header, err := findTarComponent(f, componentPath)
+	tarReader, header, err := r.findTarComponentInFile(f, componentPath)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if header == nil {
-		return nil, os.ErrNotExist
+		return nil, nil, os.ErrNotExist
 	}
-	if header.FileInfo().Mode()&os.ModeType == os.ModeSymlink { // FIXME: untested
-		// We follow only one symlink; so no loops are possible.
+	if linkTarget, ok := tarLinkTarget(componentPath, header); ok {
+		// We follow only one link; so no loops are possible.
 		if _, err := f.Seek(0, io.SeekStart); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		// The new path could easily point "outside" the archive, but we only compare it to existing tar headers without extracting the archive,
 		// so we don't care.
-		tarReader, header, err = findTarComponent(f, path.Join(path.Dir(componentPath), header.Linkname))
+		tarReader, header, err = r.findTarComponentInFile(f, linkTarget)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		if header == nil {
-			return nil, os.ErrNotExist
+			return nil, nil, os.ErrNotExist
 		}
 	}
 
 	if !header.FileInfo().Mode().IsRegular() {
-		return nil, fmt.Errorf("Error reading tar archive component %q: not a regular file", header.Name)
+		return nil, nil, fmt.Errorf("Error reading tar archive component %q: not a regular file", header.Name)
 	}
 	succeeded = true
-	return &tarReadCloser{Reader: tarReader, backingFile: f}, nil
+	return &tarReadCloser{Reader: tarReader, backingFile: f}, header, nil
+}
+
+// openSeekableTarComponent is openTarComponentWithHeader for archives where r.seekable is set.
+func (r *Reader) openSeekableTarComponent(componentPath string) (io.ReadCloser, *tar.Header, error) {
+	componentPath = path.Clean(componentPath)
+	if _, ok := r.seekable.EntryOffset(componentPath); !ok {
+		return nil, nil, os.ErrNotExist
+	}
+	tarReader, header, closer, err := r.seekable.OpenTarEntry(componentPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if linkTarget, ok := tarLinkTarget(componentPath, header); ok {
+		closer.Close()
+		// We follow only one link; so no loops are possible.
+		componentPath = linkTarget
+		if _, ok := r.seekable.EntryOffset(componentPath); !ok {
+			return nil, nil, os.ErrNotExist
+		}
+		tarReader, header, closer, err = r.seekable.OpenTarEntry(componentPath)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if !header.FileInfo().Mode().IsRegular() {
+		closer.Close()
+		return nil, nil, fmt.Errorf("Error reading tar archive component %q: not a regular file", header.Name)
+	}
+	return &tarReadCloser{Reader: tarReader, backingFile: closer}, header, nil
+}
+
+// tarLinkTarget returns the path of the entry a symbolic or hard link at componentPath, with header, refers to,
+// or ("", false) if header is not a link.
+func tarLinkTarget(componentPath string, header *tar.Header) (string, bool) {
+	switch {
+	case header.FileInfo().Mode()&os.ModeType == os.ModeSymlink:
+		return path.Join(path.Dir(componentPath), header.Linkname), true
+	case header.Typeflag == tar.TypeLink: // e.g. blobs/<algorithm>/<encoded digest> with WriterOptions.DigestPathLinks
+		return path.Clean(header.Linkname), true
+	default:
+		return "", false
+	}
 }
 
 // findTarComponent returns a header and a reader matching componentPath within inputFile,
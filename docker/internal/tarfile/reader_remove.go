@@ -0,0 +1,496 @@
+package tarfile
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/internal/iolimits"
+	"github.com/containers/image/v5/internal/set"
+	"github.com/containers/image/v5/manifest"
+	digest "github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// WriteWithoutImages writes to dest an uncompressed copy of the archive, without the tags in removeTags,
+// and without the images at the indexes in removeImages (indexes into r.Manifest).
+// Images (and manifest lists) which have all of their tags removed are removed as well; so are the per-platform images
+// of removed manifest lists, unless they are used by another manifest list.
+// Files used only by removed images are not copied; files shared with the remaining images are preserved.
+// manifest.json, repositories and the OCI index.json, if present, are updated accordingly.
+func (r *Reader) WriteWithoutImages(dest io.Writer, removeTags []reference.NamedTagged, removeImages []int) error {
+	p, err := r.planImageRemoval(removeTags, removeImages)
+	if err != nil {
+		return err
+	}
+	entries, err := r.scanEntries()
+	if err != nil {
+		return err
+	}
+	dropped := p.droppedPaths(entries)
+	dropped.Add(entryIndexFileName) // An entry index of this archive would not describe the copy.
+	replaced := map[string][]byte{}
+	if replaced[manifestFileName], err = json.Marshal(p.manifest); err != nil {
+		return fmt.Errorf("marshaling %s: %w", manifestFileName, err)
+	}
+	if p.repositories != nil {
+		if replaced[legacyRepositoriesFileName], err = json.Marshal(p.repositories); err != nil {
+			return fmt.Errorf("marshaling %s: %w", legacyRepositoriesFileName, err)
+		}
+	}
+	if p.index != nil {
+		if replaced[imgspecv1.ImageIndexFile], err = json.Marshal(p.index); err != nil {
+			return fmt.Errorf("marshaling %s: %w", imgspecv1.ImageIndexFile, err)
+		}
+	}
+	return r.copyEntries(dest, dropped, replaced)
+}
+
+// imageRemovalPlan describes the result of removing images from an archive, as computed by planImageRemoval.
+type imageRemovalPlan struct {
+	manifest     []ManifestItem               // The new contents of manifest.json
+	repositories map[string]map[string]string // The new contents of the repositories file, or nil if the archive does not contain it
+	index        *imgspecv1.Index             // The new contents of index.json, or nil if the archive does not contain it
+	keptPaths    *set.Set[string]             // Paths used by the remaining images
+	removedPaths *set.Set[string]             // Paths used by the removed images; the ones in keptPaths are preserved
+}
+
+// planImageRemoval computes the effect of WriteWithoutImages(…, removeTags, removeImages).
+func (r *Reader) planImageRemoval(removeTags []reference.NamedTagged, removeImages []int) (*imageRemovalPlan, error) {
+	removedTags := set.New[string]()
+	for _, tag := range removeTags {
+		refString := tag.String()
+		_, _, itemErr := r.ChooseManifestItem(tag, -1)
+		list, _, err := r.ChooseManifestList(tag)
+		if err != nil {
+			return nil, err
+		}
+		if itemErr != nil && list == nil {
+			return nil, fmt.Errorf("Tag %#v not found", refString)
+		}
+		removedTags.Add(refString)
+	}
+	isRemovedTag := func(tag string) (bool, error) {
+		parsedTag, err := reference.ParseNormalizedNamed(tag)
+		if err != nil {
+			return false, fmt.Errorf("Invalid tag %#v: %w", tag, err)
+		}
+		return removedTags.Contains(parsedTag.String()), nil
+	}
+	removedItems := set.New[int]()
+	for _, i := range removeImages {
+		if i < 0 || i >= len(r.Manifest) {
+			return nil, fmt.Errorf("Invalid source index @%d, only %d manifest items available", i, len(r.Manifest))
+		}
+		removedItems.Add(i)
+	}
+
+	res := &imageRemovalPlan{
+		manifest:     []ManifestItem{},
+		keptPaths:    set.NewWithValues(manifestFileName, legacyRepositoriesFileName, imgspecv1.ImageIndexFile, imgspecv1.ImageLayoutFile),
+		removedPaths: set.New[string](),
+	}
+
+	// Manifest lists which lose all of their tags are removed, together with their instances.
+	removedLists := set.New[digest.Digest]()
+	for _, list := range r.ManifestLists {
+		remaining := 0
+		for _, tag := range list.RepoTags {
+			removed, err := isRemovedTag(tag)
+			if err != nil {
+				return nil, err
+			}
+			if !removed {
+				remaining++
+			}
+		}
+		if len(list.RepoTags) != 0 && remaining == 0 {
+			removedLists.Add(list.Digest)
+		}
+	}
+	keptInstanceConfigs, removedInstanceConfigs := set.New[digest.Digest](), set.New[digest.Digest]()
+	for _, list := range r.ManifestLists {
+		configs := keptInstanceConfigs
+		if removedLists.Contains(list.Digest) {
+			configs = removedInstanceConfigs
+		}
+		if err := r.addListInstanceConfigs(configs, list.Digest, list.MediaType); err != nil {
+			return nil, err
+		}
+	}
+
+	removedConfigs := set.New[digest.Digest]()
+	for i, item := range r.Manifest {
+		configDigest, err := r.itemConfigDigest(&item)
+		if err != nil {
+			return nil, fmt.Errorf("reading config of manifest item @%d: %w", i, err)
+		}
+		newItem := item
+		newItem.RepoTags = []string{}
+		for _, tag := range item.RepoTags {
+			removed, err := isRemovedTag(tag)
+			if err != nil {
+				return nil, fmt.Errorf("manifest item @%d: %w", i, err)
+			}
+			if !removed {
+				newItem.RepoTags = append(newItem.RepoTags, tag)
+			}
+		}
+		remove := removedItems.Contains(i) || (len(item.RepoTags) != 0 && len(newItem.RepoTags) == 0) ||
+			(len(item.RepoTags) == 0 && removedInstanceConfigs.Contains(configDigest) && !keptInstanceConfigs.Contains(configDigest))
+		paths := res.keptPaths
+		if remove {
+			removedConfigs.Add(configDigest)
+			paths = res.removedPaths
+			// Also remove the tags of images removed using removeImages from the other metadata files.
+			for _, tag := range item.RepoTags {
+				parsedTag, err := reference.ParseNormalizedNamed(tag)
+				if err != nil {
+					return nil, fmt.Errorf("Invalid tag %#v in manifest item @%d: %w", tag, i, err)
+				}
+				removedTags.Add(parsedTag.String())
+			}
+		} else {
+			res.manifest = append(res.manifest, newItem)
+		}
+		paths.Add(path.Clean(item.Config))
+		for _, l := range item.Layers {
+			paths.Add(path.Clean(l))
+		}
+	}
+
+	repositories, err := r.updatedRepositories(isRemovedTag)
+	if err != nil {
+		return nil, err
+	}
+	res.repositories = repositories
+
+	index, err := r.readIndex()
+	if err != nil {
+		return nil, err
+	}
+	if index != nil {
+		newManifests := []imgspecv1.Descriptor{}
+		for _, desc := range index.Manifests {
+			remove := removedLists.Contains(desc.Digest)
+			if name, ok := desc.Annotations[containerdImageNameAnnotation]; ok && !remove {
+				if remove, err = isRemovedTag(name); err != nil {
+					return nil, fmt.Errorf("%s entry %s: %w", imgspecv1.ImageIndexFile, desc.Digest.String(), err)
+				}
+			}
+			if !remove && !manifest.MIMETypeIsMultiImage(desc.MediaType) {
+				configDigest, err := r.blobManifestConfig(desc.Digest, desc.MediaType)
+				if err != nil {
+					return nil, err
+				}
+				remove = removedConfigs.Contains(configDigest)
+			}
+			paths := res.keptPaths
+			if remove {
+				paths = res.removedPaths
+			} else {
+				newManifests = append(newManifests, desc)
+			}
+			if err := r.addBlobPaths(paths, desc.Digest, desc.MediaType); err != nil {
+				return nil, err
+			}
+		}
+		index.Manifests = newManifests
+		res.index = index
+	}
+	return res, nil
+}
+
+// itemConfigDigest returns the digest of the config of item.
+func (r *Reader) itemConfigDigest(item *ManifestItem) (digest.Digest, error) {
+	configBytes, err := r.readTarComponent(item.Config, iolimits.MaxConfigBodySize)
+	if err != nil {
+		return "", err
+	}
+	return digest.FromBytes(configBytes), nil
+}
+
+// readBlobManifest reads the manifest or manifest list with manifestDigest from the OCI layout.
+func (r *Reader) readBlobManifest(manifestDigest digest.Digest) ([]byte, error) {
+	p, err := digestPath(manifestDigest)
+	if err != nil {
+		return nil, err
+	}
+	return r.readTarComponent(p, iolimits.MaxManifestBodySize)
+}
+
+// blobManifestConfig returns the config digest of the manifest with manifestDigest and mimeType in the OCI layout.
+func (r *Reader) blobManifestConfig(manifestDigest digest.Digest, mimeType string) (digest.Digest, error) {
+	manifestBlob, err := r.readBlobManifest(manifestDigest)
+	if err != nil {
+		return "", err
+	}
+	m, err := manifest.FromBlob(manifestBlob, manifest.NormalizedMIMEType(mimeType))
+	if err != nil {
+		return "", fmt.Errorf("parsing manifest %s: %w", manifestDigest.String(), err)
+	}
+	return m.ConfigInfo().Digest, nil
+}
+
+// addListInstanceConfigs adds the config digests of the instances of the manifest list with listDigest and mimeType to configs.
+func (r *Reader) addListInstanceConfigs(configs *set.Set[digest.Digest], listDigest digest.Digest, mimeType string) error {
+	listBlob, err := r.readBlobManifest(listDigest)
+	if err != nil {
+		return err
+	}
+	list, err := manifest.ListFromBlob(listBlob, manifest.NormalizedMIMEType(mimeType))
+	if err != nil {
+		return fmt.Errorf("parsing manifest list %s: %w", listDigest.String(), err)
+	}
+	for _, instanceDigest := range list.Instances() {
+		instance, err := list.Instance(instanceDigest)
+		if err != nil {
+			return err
+		}
+		configDigest, err := r.blobManifestConfig(instanceDigest, instance.MediaType)
+		if err != nil {
+			return err
+		}
+		configs.Add(configDigest)
+	}
+	return nil
+}
+
+// addBlobPaths adds the OCI layout paths of the manifest or manifest list with manifestDigest and mimeType,
+// and of all blobs it refers to, to paths.
+func (r *Reader) addBlobPaths(paths *set.Set[string], manifestDigest digest.Digest, mimeType string) error {
+	p, err := digestPath(manifestDigest)
+	if err != nil {
+		return err
+	}
+	paths.Add(p)
+	manifestBlob, err := r.readBlobManifest(manifestDigest)
+	if err != nil {
+		return err
+	}
+	mimeType = manifest.NormalizedMIMEType(mimeType)
+	if manifest.MIMETypeIsMultiImage(mimeType) {
+		list, err := manifest.ListFromBlob(manifestBlob, mimeType)
+		if err != nil {
+			return fmt.Errorf("parsing manifest list %s: %w", manifestDigest.String(), err)
+		}
+		for _, instanceDigest := range list.Instances() {
+			instance, err := list.Instance(instanceDigest)
+			if err != nil {
+				return err
+			}
+			if err := r.addBlobPaths(paths, instanceDigest, instance.MediaType); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	m, err := manifest.FromBlob(manifestBlob, mimeType)
+	if err != nil {
+		return fmt.Errorf("parsing manifest %s: %w", manifestDigest.String(), err)
+	}
+	blobs := []digest.Digest{m.ConfigInfo().Digest}
+	for _, layer := range m.LayerInfos() {
+		blobs = append(blobs, layer.Digest)
+	}
+	for _, blobDigest := range blobs {
+		p, err := digestPath(blobDigest)
+		if err != nil {
+			return err
+		}
+		paths.Add(p)
+	}
+	return nil
+}
+
+// updatedRepositories returns the contents of the repositories file without the tags for which isRemovedTag returns true,
+// or nil if the archive does not contain the file.
+func (r *Reader) updatedRepositories(isRemovedTag func(string) (bool, error)) (map[string]map[string]string, error) {
+	b, err := r.readTarComponent(legacyRepositoriesFileName, iolimits.MaxTarFileManifestSize)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	repositories := map[string]map[string]string{}
+	if err := json.Unmarshal(b, &repositories); err != nil {
+		return nil, fmt.Errorf("decoding tar %s: %w", legacyRepositoriesFileName, err)
+	}
+	for repo, tags := range repositories {
+		for tag := range tags {
+			removed, err := isRemovedTag(repo + ":" + tag)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", legacyRepositoriesFileName, err)
+			}
+			if removed {
+				delete(tags, tag)
+			}
+		}
+		if len(tags) == 0 {
+			delete(repositories, repo)
+		}
+	}
+	return repositories, nil
+}
+
+// readIndex returns the contents of the OCI index.json, or nil if the archive does not contain it.
+func (r *Reader) readIndex() (*imgspecv1.Index, error) {
+	b, err := r.readTarComponent(imgspecv1.ImageIndexFile, iolimits.MaxTarFileManifestSize)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	index := imgspecv1.Index{}
+	if err := json.Unmarshal(b, &index); err != nil {
+		return nil, fmt.Errorf("decoding tar %s: %w", imgspecv1.ImageIndexFile, err)
+	}
+	return &index, nil
+}
+
+// archiveEntry is a summary of an entry of the archive, as returned by scanEntries.
+type archiveEntry struct {
+	path       string // path.Clean-ed
+	linkTarget string // "" if the entry is not a link
+}
+
+// scanEntries returns the entries of the archive, in order.
+func (r *Reader) scanEntries() ([]archiveEntry, error) {
+	stream, err := r.openArchiveStream()
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+	res := []archiveEntry{}
+	t := tar.NewReader(stream)
+	for {
+		h, err := t.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		e := archiveEntry{path: path.Clean(h.Name)}
+		if target, ok := tarLinkTarget(e.path, h); ok {
+			e.linkTarget = target
+		}
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+// droppedPaths returns the paths of entries which should not be copied: paths used only by the removed images,
+// links to them, and legacy layer directories of removed layers.
+func (p *imageRemovalPlan) droppedPaths(entries []archiveEntry) *set.Set[string] {
+	links := map[string]string{}
+	for _, e := range entries {
+		if e.linkTarget != "" {
+			links[e.path] = e.linkTarget
+		}
+	}
+	// Files referenced by kept links are kept as well.
+	kept := set.New[string]()
+	for name := range p.keptPaths.All() {
+		for !kept.Contains(name) {
+			kept.Add(name)
+			target, ok := links[name]
+			if !ok {
+				break
+			}
+			name = target
+		}
+	}
+
+	dropped := set.New[string]()
+	for name := range p.removedPaths.All() {
+		if !kept.Contains(name) {
+			dropped.Add(name)
+		}
+	}
+	// Links to dropped files are dropped as well; repeat until no more links are dropped, to handle chains of links.
+	for changed := true; changed; {
+		changed = false
+		for link, target := range links {
+			if dropped.Contains(target) && !kept.Contains(link) && !dropped.Contains(link) {
+				dropped.Add(link)
+				changed = true
+			}
+		}
+	}
+	// The legacy metadata of layers is in the directory containing legacyLayerFileName.
+	for _, e := range entries {
+		if path.Base(e.path) != legacyLayerFileName || !dropped.Contains(e.path) {
+			continue
+		}
+		dir := path.Dir(e.path)
+		if dir == "." {
+			continue
+		}
+		for _, name := range []string{dir, path.Join(dir, legacyConfigFileName), path.Join(dir, legacyVersionFileName)} {
+			if !kept.Contains(name) {
+				dropped.Add(name)
+			}
+		}
+	}
+	return dropped
+}
+
+// openArchiveStream returns the whole uncompressed tar stream of the archive.
+// The caller must call Close() on the returned stream.
+func (r *Reader) openArchiveStream() (io.ReadCloser, error) {
+	if r.path == "" {
+		return nil, errors.New("Internal error: trying to read an already closed tarfile.Reader")
+	}
+	if r.seekable != nil {
+		return r.seekable.StreamAt(0)
+	}
+	return os.Open(r.path)
+}
+
+// copyEntries copies the entries of the archive to dest as a tar stream, except for the paths in dropped,
+// replacing the contents of the paths in replaced.
+func (r *Reader) copyEntries(dest io.Writer, dropped *set.Set[string], replaced map[string][]byte) error {
+	stream, err := r.openArchiveStream()
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	t := tar.NewReader(stream)
+	tw := tar.NewWriter(dest)
+	for {
+		h, err := t.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		name := path.Clean(h.Name)
+		if dropped.Contains(name) {
+			continue
+		}
+		var contents io.Reader = t
+		if b, ok := replaced[name]; ok && h.Typeflag == tar.TypeReg {
+			hdr := *h
+			hdr.Size = int64(len(b))
+			h = &hdr
+			contents = bytes.NewReader(b)
+		}
+		if err := tw.WriteHeader(h); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, contents); err != nil {
+			return fmt.Errorf("copying tar entry %q: %w", h.Name, err)
+		}
+	}
+	return tw.Close()
+}
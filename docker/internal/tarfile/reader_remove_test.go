@@ -0,0 +1,162 @@
+package tarfile
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containers/image/v5/docker/reference"
+	digest "github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReaderWriteWithoutImages(t *testing.T) {
+	sharedLayer := []byte("shared layer")
+	firstLayer := []byte("first layer")
+	secondLayer := []byte("second layer")
+
+	parseTag := func(s string) reference.NamedTagged {
+		named, err := reference.ParseNormalizedNamed(s)
+		require.NoError(t, err)
+		tagged, ok := named.(reference.NamedTagged)
+		require.True(t, ok)
+		return tagged
+	}
+	// readArchive returns the entry names and regular file contents of the archive at path.
+	readArchive := func(path string) ([]string, map[string][]byte) {
+		f, err := os.Open(path)
+		require.NoError(t, err)
+		defer f.Close()
+		names := []string{}
+		files := map[string][]byte{}
+		tr := tar.NewReader(f)
+		for {
+			hdr, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			require.NoError(t, err)
+			names = append(names, hdr.Name)
+			if hdr.Typeflag == tar.TypeReg {
+				data, err := io.ReadAll(tr)
+				require.NoError(t, err)
+				files[hdr.Name] = data
+			}
+		}
+		return names, files
+	}
+
+	for _, format := range []ArchiveFormat{FormatDockerSave, FormatDockerSaveAndOCILayout} {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "archive.tar")
+		f, err := os.Create(path)
+		require.NoError(t, err)
+		writer, err := NewWriterWithOptions(f, WriterOptions{Format: format})
+		require.NoError(t, err)
+		writeTestImage(t, writer, "example.com/first:tag", "first", sharedLayer, firstLayer)
+		writeTestImage(t, writer, "example.com/first:other", "first", sharedLayer, firstLayer)
+		writeTestImage(t, writer, "example.com/second:tag", "second", sharedLayer, secondLayer)
+		err = writer.Close()
+		require.NoError(t, err)
+		err = f.Close()
+		require.NoError(t, err)
+
+		reader, err := NewReaderFromFile(nil, path)
+		require.NoError(t, err)
+		defer reader.Close()
+		require.Len(t, reader.Manifest, 2)
+
+		// Unknown tags and indexes are rejected.
+		err = reader.WriteWithoutImages(io.Discard, []reference.NamedTagged{parseTag("example.com/unknown:tag")}, nil)
+		assert.Error(t, err)
+		err = reader.WriteWithoutImages(io.Discard, nil, []int{2})
+		assert.Error(t, err)
+
+		// Removing one of the tags of an image preserves the image.
+		untaggedPath := filepath.Join(dir, "untagged.tar")
+		f, err = os.Create(untaggedPath)
+		require.NoError(t, err)
+		err = reader.WriteWithoutImages(f, []reference.NamedTagged{parseTag("example.com/first:other")}, nil)
+		require.NoError(t, err)
+		err = f.Close()
+		require.NoError(t, err)
+		untagged, err := NewReaderFromFile(nil, untaggedPath)
+		require.NoError(t, err)
+		defer untagged.Close()
+		require.Len(t, untagged.Manifest, 2)
+		assert.Equal(t, []string{"example.com/first:tag"}, untagged.Manifest[0].RepoTags)
+		assert.Equal(t, reader.Manifest[0].Layers, untagged.Manifest[0].Layers)
+		origNames, _ := readArchive(path)
+		untaggedNames, untaggedFiles := readArchive(untaggedPath)
+		assert.Equal(t, origNames, untaggedNames)
+		var repositories map[string]map[string]string
+		err = json.Unmarshal(untaggedFiles[legacyRepositoriesFileName], &repositories)
+		require.NoError(t, err)
+		assert.Len(t, repositories["example.com/first"], 1)
+
+		// Removing all tags of an image removes the image, but not the layers shared with other images.
+		for _, c := range []struct {
+			tags    []reference.NamedTagged
+			indexes []int
+		}{
+			{tags: []reference.NamedTagged{parseTag("example.com/second:tag")}},
+			{indexes: []int{1}},
+		} {
+			prunedPath := filepath.Join(dir, "pruned.tar")
+			f, err = os.Create(prunedPath)
+			require.NoError(t, err)
+			err = reader.WriteWithoutImages(f, c.tags, c.indexes)
+			require.NoError(t, err)
+			err = f.Close()
+			require.NoError(t, err)
+
+			pruned, err := NewReaderFromFile(nil, prunedPath)
+			require.NoError(t, err)
+			require.Len(t, pruned.Manifest, 1)
+			assert.Equal(t, reader.Manifest[0], pruned.Manifest[0])
+			for _, l := range pruned.Manifest[0].Layers {
+				stream, err := pruned.openTarComponent(l)
+				require.NoError(t, err)
+				stream.Close()
+			}
+			err = pruned.Close()
+			require.NoError(t, err)
+
+			names, files := readArchive(prunedPath)
+			secondLayerPath := digest.FromBytes(secondLayer).Encoded() + ".tar"
+			assert.NotContains(t, names, secondLayerPath)
+			assert.NotContains(t, names, reader.Manifest[1].Config)
+			assert.Contains(t, names, digest.FromBytes(sharedLayer).Encoded()+".tar")
+			// Legacy layer directories of removed layers are removed as well.
+			for _, name := range names {
+				if filepath.Base(name) == legacyLayerFileName {
+					stream, err := reader.openTarComponent(name)
+					require.NoError(t, err)
+					data, err := io.ReadAll(stream)
+					require.NoError(t, err)
+					stream.Close()
+					assert.NotEqual(t, secondLayer, data, name)
+				}
+			}
+			repositories = nil
+			err = json.Unmarshal(files[legacyRepositoriesFileName], &repositories)
+			require.NoError(t, err)
+			assert.NotContains(t, repositories, "example.com/second")
+			if format == FormatDockerSaveAndOCILayout {
+				var index imgspecv1.Index
+				err = json.Unmarshal(files[imgspecv1.ImageIndexFile], &index)
+				require.NoError(t, err)
+				assert.Len(t, index.Manifests, 2)
+				for _, desc := range index.Manifests {
+					assert.Contains(t, []string{"example.com/first:tag", "example.com/first:other"}, desc.Annotations[containerdImageNameAnnotation])
+				}
+			}
+		}
+	}
+}
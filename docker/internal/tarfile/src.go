@@ -20,6 +20,7 @@ import (
 	"github.com/containers/image/v5/pkg/compression"
 	"github.com/containers/image/v5/types"
 	digest "github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
 // Source is a partial implementation of types.ImageSource for reading from tarPath.
@@ -41,6 +42,12 @@ type Source struct {
 	configDigest      digest.Digest
 	orderedDiffIDList []digest.Digest
 	knownLayers       map[digest.Digest]*layerInfo
+	// Set by ensureCachedDataIsPresent() if the source refers to a manifest list, in which case the fields above are not set.
+	manifestList *ManifestListItem
+	// If useOCIManifests, set by ensureCachedDataIsPresent() to the manifest of the image in the OCI layout, if any;
+	// then blobs are read from the OCI layout, and knownLayers is not set.
+	useOCIManifests bool
+	ociManifest     []byte
 	// Other state
 	generatedManifest []byte    // Private cache for GetManifest(), nil if not set yet.
 	cacheDataLock     sync.Once // Private state for ensureCachedDataIsPresent to make it concurrency-safe
@@ -85,6 +92,17 @@ func (s *Source) ensureCachedDataIsPresent() error {
 func (s *Source) ensureCachedDataIsPresentPrivate() error {
 	tarManifest, _, err := s.archive.ChooseManifestItem(s.ref, s.sourceIndex)
 	if err != nil {
+		if s.ref != nil {
+			// Tags of multi-platform images refer to manifest lists, not to items in manifest.json.
+			list, _, listErr := s.archive.ChooseManifestList(s.ref)
+			if listErr != nil {
+				return listErr
+			}
+			if list != nil {
+				s.manifestList = list
+				return nil
+			}
+		}
 		return err
 	}
 
@@ -101,20 +119,40 @@ func (s *Source) ensureCachedDataIsPresentPrivate() error {
 		return fmt.Errorf("Invalid image config (rootFS is not set): %q", tarManifest.Config)
 	}
 
-	knownLayers, err := s.prepareLayerData(tarManifest, &parsedConfig)
-	if err != nil {
-		return err
+	configDigest := digest.FromBytes(configBytes)
+	var ociManifest []byte
+	var knownLayers map[digest.Digest]*layerInfo
+	if s.useOCIManifests {
+		ociManifest, err = s.archive.ociManifestForConfig(configDigest)
+		if err != nil {
+			return err
+		}
+	}
+	if ociManifest == nil {
+		knownLayers, err = s.prepareLayerData(tarManifest, &parsedConfig)
+		if err != nil {
+			return err
+		}
 	}
 
 	// Success; commit.
 	s.tarManifest = tarManifest
 	s.configBytes = configBytes
-	s.configDigest = digest.FromBytes(configBytes)
+	s.configDigest = configDigest
 	s.orderedDiffIDList = parsedConfig.RootFS.DiffIDs
 	s.knownLayers = knownLayers
+	s.ociManifest = ociManifest
 	return nil
 }
 
+// UseOCIManifests makes the source return the manifest of the image stored in the OCI layout of the archive, if any,
+// as created by recent versions of (docker save), preserving its media types and annotations; the image is otherwise
+// converted to a Docker schema2 manifest referring to uncompressed layers.
+// It must be called before any other methods of the source.
+func (s *Source) UseOCIManifests() {
+	s.useOCIManifests = true
+}
+
 // Close removes resources associated with an initialized Source, if any.
 func (s *Source) Close() error {
 	if s.closeArchive {
@@ -154,44 +192,51 @@ func (s *Source) prepareLayerData(tarManifest *ManifestItem, parsedConfig *manif
 		unknownLayerSizes[layerPath] = li
 	}
 
-	// Scan the tar file to collect layer sizes.
-	file, err := os.Open(s.archive.path)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-	t := tar.NewReader(file)
-	for {
-		h, err := t.Next()
-		if err == io.EOF {
-			break
+	// Collect layer sizes.
+	if s.archive.seekable != nil {
+		// Read only the layers; the seekable archive allows that without reading the rest.
+		for layerPath, li := range unknownLayerSizes {
+			stream, h, err := s.archive.openTarComponentWithHeader(layerPath)
+			if err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					continue // Reported below
+				}
+				return nil, err
+			}
+			size, err := tarComponentUncompressedSize(stream, h, layerPath)
+			stream.Close()
+			if err != nil {
+				return nil, err
+			}
+			li.size = size
+			delete(unknownLayerSizes, layerPath)
 		}
+	} else {
+		// Scan the tar file.
+		file, err := os.Open(s.archive.path)
 		if err != nil {
 			return nil, err
 		}
-		layerPath := path.Clean(h.Name)
-		// FIXME: Cache this data across images in Reader.
-		if li, ok := unknownLayerSizes[layerPath]; ok {
-			// Since GetBlob will decompress layers that are compressed we need
-			// to do the decompression here as well, otherwise we will
-			// incorrectly report the size. Pretty critical, since tools like
-			// umoci always compress layer blobs. Obviously we only bother with
-			// the slower method of checking if it's compressed.
-			uncompressedStream, isCompressed, err := compression.AutoDecompress(t)
+		defer file.Close()
+		t := tar.NewReader(file)
+		for {
+			h, err := t.Next()
+			if err == io.EOF {
+				break
+			}
 			if err != nil {
-				return nil, fmt.Errorf("auto-decompressing %q to determine its size: %w", layerPath, err)
+				return nil, err
 			}
-			defer uncompressedStream.Close()
-
-			uncompressedSize := h.Size
-			if isCompressed {
-				uncompressedSize, err = io.Copy(io.Discard, uncompressedStream)
+			layerPath := path.Clean(h.Name)
+			// FIXME: Cache this data across images in Reader.
+			if li, ok := unknownLayerSizes[layerPath]; ok {
+				size, err := tarComponentUncompressedSize(t, h, layerPath)
 				if err != nil {
-					return nil, fmt.Errorf("reading %q to find its size: %w", layerPath, err)
+					return nil, err
 				}
+				li.size = size
+				delete(unknownLayerSizes, layerPath)
 			}
-			li.size = uncompressedSize
-			delete(unknownLayerSizes, layerPath)
 		}
 	}
 	if len(unknownLayerSizes) != 0 {
@@ -201,21 +246,61 @@ func (s *Source) prepareLayerData(tarManifest *ManifestItem, parsedConfig *manif
 	return knownLayers, nil
 }
 
+// tarComponentUncompressedSize returns the size of the data of layerPath, with header h, after decompression,
+// reading it from stream if necessary.
+func tarComponentUncompressedSize(stream io.Reader, h *tar.Header, layerPath string) (int64, error) {
+	// Since GetBlob will decompress layers that are compressed we need
+	// to do the decompression here as well, otherwise we will
+	// incorrectly report the size. Pretty critical, since tools like
+	// umoci always compress layer blobs. Obviously we only bother with
+	// the slower method of checking if it's compressed.
+	uncompressedStream, isCompressed, err := compression.AutoDecompress(stream)
+	if err != nil {
+		return -1, fmt.Errorf("auto-decompressing %q to determine its size: %w", layerPath, err)
+	}
+	defer uncompressedStream.Close()
+
+	uncompressedSize := h.Size
+	if isCompressed {
+		uncompressedSize, err = io.Copy(io.Discard, uncompressedStream)
+		if err != nil {
+			return -1, fmt.Errorf("reading %q to find its size: %w", layerPath, err)
+		}
+	}
+	return uncompressedSize, nil
+}
+
 // GetManifest returns the image's manifest along with its MIME type (which may be empty when it can't be determined but the manifest is available).
 // It may use a remote (= slow) service.
 // If instanceDigest is not nil, it contains a digest of the specific manifest instance to retrieve (when the primary manifest is a manifest list);
 // this never happens if the primary manifest is not a manifest list (e.g. if the source never returns manifest lists).
-// This source implementation does not support manifest lists, so the passed-in instanceDigest should always be nil,
-// as the primary manifest can not be a list, so there can be no secondary instances.
+// Manifest lists are only returned for tags referring to manifest lists in the OCI index.json of the archive.
 func (s *Source) GetManifest(ctx context.Context, instanceDigest *digest.Digest) ([]byte, string, error) {
+	if err := s.ensureCachedDataIsPresent(); err != nil {
+		return nil, "", err
+	}
+	if s.manifestList != nil {
+		if instanceDigest == nil {
+			blob, err := s.archive.readManifestBlob(s.manifestList.Digest)
+			if err != nil {
+				return nil, "", err
+			}
+			return blob, s.manifestList.MediaType, nil
+		}
+		blob, err := s.archive.readManifestBlob(*instanceDigest)
+		if err != nil {
+			return nil, "", err
+		}
+		return blob, manifest.GuessMIMEType(blob), nil
+	}
 	if instanceDigest != nil {
-		// How did we even get here? GetManifest(ctx, nil) has returned a manifest.DockerV2Schema2MediaType.
+		// How did we even get here? GetManifest(ctx, nil) has returned a single-image manifest.
 		return nil, "", errors.New(`Manifest lists are not supported by "docker-daemon:"`)
 	}
+	if s.ociManifest != nil {
+		return s.ociManifest, imgspecv1.MediaTypeImageManifest, nil
+	}
 	if s.generatedManifest == nil {
-		if err := s.ensureCachedDataIsPresent(); err != nil {
-			return nil, "", err
-		}
 		m := manifest.Schema2{
 			SchemaVersion: 2,
 			MediaType:     manifest.DockerV2Schema2MediaType,
@@ -272,6 +357,22 @@ func (s *Source) GetBlob(ctx context.Context, info types.BlobInfo, cache types.B
 		return nil, 0, err
 	}
 
+	if s.manifestList != nil || s.ociManifest != nil {
+		// Manifests in the OCI layout refer to blobs using their actual digests.
+		blobPath, err := digestPath(info.Digest)
+		if err != nil {
+			return nil, 0, err
+		}
+		stream, h, err := s.archive.openTarComponentWithHeader(blobPath)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil, 0, fmt.Errorf("Unknown blob %s", info.Digest)
+			}
+			return nil, 0, err
+		}
+		return stream, h.Size, nil
+	}
+
 	if info.Digest == s.configDigest { // FIXME? Implement a more general algorithm matching instead of assuming sha256.
 		return io.NopCloser(bytes.NewReader(s.configBytes)), int64(len(s.configBytes)), nil
 	}
@@ -4,12 +4,17 @@ import (
 	"bytes"
 	"context"
 	"io"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/containers/image/v5/internal/seekablezstd"
 	"github.com/containers/image/v5/manifest"
 	"github.com/containers/image/v5/pkg/blobinfocache/memory"
 	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -64,3 +69,161 @@ func TestSourcePrepareLayerData(t *testing.T) {
 		}
 	}
 }
+
+func TestSourceSeekableZstd(t *testing.T) {
+	cache := memory.New()
+	ctx := context.Background()
+	layer := bytes.Repeat([]byte("layer data"), 1000)
+	layerDigest := digest.FromBytes(layer)
+	config := `{"rootfs":{"type":"layers","diff_ids":["` + layerDigest.String() + `"]}}`
+
+	archivePath := filepath.Join(t.TempDir(), "archive.tar.zst")
+	file, err := os.Create(archivePath)
+	require.NoError(t, err)
+	compressor, err := seekablezstd.NewWriter(file)
+	require.NoError(t, err)
+	writer := NewWriter(compressor)
+	dest := NewDestination(nil, writer, "transport name", nil, nil)
+	configInfo, err := dest.PutBlob(ctx, strings.NewReader(config), types.BlobInfo{Size: -1}, cache, true)
+	require.NoError(t, err)
+	layerInfo, err := dest.PutBlob(ctx, bytes.NewReader(layer), types.BlobInfo{Digest: layerDigest, Size: int64(len(layer))}, cache, false)
+	require.NoError(t, err)
+	manifestBlob, err := manifest.Schema2FromComponents(
+		manifest.Schema2Descriptor{
+			MediaType: manifest.DockerV2Schema2ConfigMediaType,
+			Size:      configInfo.Size,
+			Digest:    configInfo.Digest,
+		}, []manifest.Schema2Descriptor{{
+			MediaType: manifest.DockerV2Schema2LayerMediaType,
+			Size:      layerInfo.Size,
+			Digest:    layerInfo.Digest,
+		}}).Serialize()
+	require.NoError(t, err)
+	err = dest.PutManifest(ctx, manifestBlob, nil)
+	require.NoError(t, err)
+	err = writer.Close()
+	require.NoError(t, err)
+	err = compressor.Close()
+	require.NoError(t, err)
+	err = file.Close()
+	require.NoError(t, err)
+
+	reader, err := NewReaderFromFile(nil, archivePath)
+	require.NoError(t, err)
+	assert.NotNil(t, reader.seekable)
+	src := NewSource(reader, true, "transport name", nil, -1)
+	defer src.Close()
+
+	for _, c := range []struct {
+		digest   digest.Digest
+		expected []byte
+	}{
+		{configInfo.Digest, []byte(config)},
+		{layerDigest, layer},
+	} {
+		stream, size, err := src.GetBlob(ctx, types.BlobInfo{Digest: c.digest, Size: -1}, cache)
+		require.NoError(t, err)
+		data, err := io.ReadAll(stream)
+		require.NoError(t, err)
+		stream.Close()
+		assert.Equal(t, c.expected, data)
+		assert.Equal(t, int64(len(c.expected)), size)
+	}
+}
+
+func TestSourceUseOCIManifests(t *testing.T) {
+	cache := memory.New()
+	ctx := context.Background()
+	layer := []byte("layer data")
+	layerDigest := digest.FromBytes(layer)
+	config := `{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":["` + layerDigest.String() + `"]}}`
+
+	// Preserving OCI manifests requires an OCI layout.
+	_, err := NewWriterWithOptions(io.Discard, WriterOptions{PreserveOCIManifests: true})
+	assert.Error(t, err)
+
+	var archive bytes.Buffer
+	writer, err := NewWriterWithOptions(&archive, WriterOptions{Format: FormatDockerSaveAndOCILayout, PreserveOCIManifests: true})
+	require.NoError(t, err)
+	dest := NewDestination(nil, writer, "transport name", nil, nil)
+	assert.Contains(t, dest.SupportedManifestMIMETypes(), imgspecv1.MediaTypeImageManifest)
+	configInfo, err := dest.PutBlob(ctx, strings.NewReader(config), types.BlobInfo{Size: -1}, cache, true)
+	require.NoError(t, err)
+	_, err = dest.PutBlob(ctx, bytes.NewReader(layer), types.BlobInfo{Digest: layerDigest, Size: int64(len(layer))}, cache, false)
+	require.NoError(t, err)
+	m := manifest.OCI1FromComponents(imgspecv1.Descriptor{
+		MediaType: imgspecv1.MediaTypeImageConfig,
+		Size:      configInfo.Size,
+		Digest:    configInfo.Digest,
+	}, []imgspecv1.Descriptor{{
+		MediaType: imgspecv1.MediaTypeImageLayer,
+		Size:      int64(len(layer)),
+		Digest:    layerDigest,
+	}})
+	m.Annotations = map[string]string{"org.opencontainers.image.title": "preserved"}
+	manifestBlob, err := m.Serialize()
+	require.NoError(t, err)
+	err = dest.PutManifest(ctx, manifestBlob, nil)
+	require.NoError(t, err)
+	err = writer.Close()
+	require.NoError(t, err)
+	archiveBytes := archive.Bytes()
+
+	for _, useOCIManifests := range []bool{false, true} {
+		reader, err := NewReaderFromStream(nil, bytes.NewReader(archiveBytes))
+		require.NoError(t, err)
+		src := NewSource(reader, true, "transport name", nil, -1)
+		defer src.Close()
+		if useOCIManifests {
+			src.UseOCIManifests()
+		}
+		m, mimeType, err := src.GetManifest(ctx, nil)
+		require.NoError(t, err)
+		if useOCIManifests {
+			assert.Equal(t, manifestBlob, m)
+			assert.Equal(t, imgspecv1.MediaTypeImageManifest, mimeType)
+		} else {
+			assert.Equal(t, manifest.DockerV2Schema2MediaType, mimeType)
+		}
+		for _, blob := range [][]byte{[]byte(config), layer} {
+			stream, _, err := src.GetBlob(ctx, types.BlobInfo{Digest: digest.FromBytes(blob), Size: -1}, cache)
+			require.NoError(t, err)
+			contents, err := io.ReadAll(stream)
+			require.NoError(t, err)
+			stream.Close()
+			assert.Equal(t, blob, contents)
+		}
+	}
+
+	// Archives without an OCI layout use the generated schema2 manifest.
+	archive.Reset()
+	writer = NewWriter(&archive)
+	dest = NewDestination(nil, writer, "transport name", nil, nil)
+	assert.NotContains(t, dest.SupportedManifestMIMETypes(), imgspecv1.MediaTypeImageManifest)
+	configInfo, err = dest.PutBlob(ctx, strings.NewReader(config), types.BlobInfo{Size: -1}, cache, true)
+	require.NoError(t, err)
+	_, err = dest.PutBlob(ctx, bytes.NewReader(layer), types.BlobInfo{Digest: layerDigest, Size: int64(len(layer))}, cache, false)
+	require.NoError(t, err)
+	schema2Blob, err := manifest.Schema2FromComponents(manifest.Schema2Descriptor{
+		MediaType: manifest.DockerV2Schema2ConfigMediaType,
+		Size:      configInfo.Size,
+		Digest:    configInfo.Digest,
+	}, []manifest.Schema2Descriptor{{
+		MediaType: manifest.DockerV2Schema2LayerMediaType,
+		Size:      int64(len(layer)),
+		Digest:    layerDigest,
+	}}).Serialize()
+	require.NoError(t, err)
+	err = dest.PutManifest(ctx, schema2Blob, nil)
+	require.NoError(t, err)
+	err = writer.Close()
+	require.NoError(t, err)
+	reader, err := NewReaderFromStream(nil, &archive)
+	require.NoError(t, err)
+	src := NewSource(reader, true, "transport name", nil, -1)
+	defer src.Close()
+	src.UseOCIManifests()
+	_, mimeType, err := src.GetManifest(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, manifest.DockerV2Schema2MediaType, mimeType)
+}
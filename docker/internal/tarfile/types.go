@@ -14,6 +14,10 @@ const (
 	legacyConfigFileName       = "json"
 	legacyVersionFileName      = "VERSION"
 	legacyRepositoriesFileName = "repositories"
+	blobsDirName               = "blobs" // Used by OCI layouts, and by archives created by recent versions of Docker
+	// containerdImageNameAnnotation is the annotation of OCI index entries recording the full image name,
+	// as written by (docker save) and used by (docker load) and containerd.
+	containerdImageNameAnnotation = "io.containerd.image.name"
 )
 
 // ManifestItem is an element of the array stored in the top-level manifest.json file.
@@ -26,3 +30,10 @@ type ManifestItem struct { // NOTE: This is visible as docker/tarfile.ManifestIt
 }
 
 type imageID string
+
+// ManifestListItem is a manifest list (or an OCI image index) listed in the OCI index.json file, with all of its tags.
+type ManifestListItem struct {
+	MediaType string
+	Digest    digest.Digest
+	RepoTags  []string
+}
@@ -3,6 +3,7 @@ package tarfile
 import (
 	"archive/tar"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -17,8 +18,11 @@ import (
 	"github.com/containers/image/v5/internal/private"
 	"github.com/containers/image/v5/internal/set"
 	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/pkg/compression"
 	"github.com/containers/image/v5/types"
 	"github.com/opencontainers/go-digest"
+	imgspec "github.com/opencontainers/image-spec/specs-go"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/sirupsen/logrus"
 )
 
@@ -28,26 +32,226 @@ type Writer struct {
 	// ALL of the following members can only be accessed with the mutex held.
 	// Use Writer.lock() to obtain the mutex.
 	writer io.Writer
+	closer io.Closer   // If not nil, closed when the Writer is closed (i.e. the Writer owns writer).
 	tar    *tar.Writer // nil if the Writer has already been closed.
+	// If not nil, writing an entry has failed with this error, leaving the tar stream incomplete; no more entries can be written.
+	failed error
 	// Other state.
 	blobs            map[digest.Digest]types.BlobInfo // list of already-sent blobs
 	repositories     map[string]map[string]string
 	legacyLayers     *set.Set[string] // A set of IDs of legacy layers that have been already sent.
 	manifest         []ManifestItem
-	manifestByConfig map[digest.Digest]int // A map from config digest to an entry index in manifest above.
+	manifestByConfig map[digest.Digest]int   // A map from config digest to an entry index in manifest above.
+	ociManifests     *set.Set[digest.Digest] // A set of digests of OCI manifests that have already been sent.
+	ociIndex         []imgspecv1.Descriptor  // Entries of the OCI index.json.
+	pending          *pendingEntries         // With WriterOptions.Deterministic, entries not yet written to tar.
+	written          int64                   // Number of bytes written to writer by tar
+	compressor       io.WriteCloser          // With WriterOptions.Compression, the compressor writer writes to; nil if already closed.
+	compressed       *compressedCounter      // With WriterOptions.Compression, counts the compressed bytes.
+	additionalFiles  []AdditionalFilesCallback
+	entryOffsets     []entryIndexEntry // With WriterOptions.EntryIndex, offsets of the entries written so far; nil otherwise.
+	entryIndexBase   int64             // With WriterOptions.EntryIndex, the offset in the archive at which this Writer started writing.
+	options          WriterOptions
+}
+
+// ArchiveFormat selects the metadata describing images in an archive created by a Writer.
+type ArchiveFormat int
+
+const (
+	// FormatDockerSave writes the (docker save) metadata: manifest.json, repositories, and legacy per-layer metadata
+	// (see WriterOptions.LegacyMetadata).
+	FormatDockerSave ArchiveFormat = iota
+	// FormatDockerSaveAndOCILayout writes the (docker save) metadata, and also an OCI image layout
+	// (oci-layout, index.json, and OCI manifests), with every blob also available at blobsDirName/<algorithm>/<encoded digest>.
+	// This is what recent versions of (docker save) create; the archive can be consumed both by (docker load) and by OCI tooling.
+	FormatDockerSaveAndOCILayout
+	// FormatOCILayout writes only an OCI image layout, with every blob stored at blobsDirName/<algorithm>/<encoded digest>.
+	// Such archives can be loaded by recent versions of Docker, but not read by Reader.
+	FormatOCILayout
+)
+
+// LayerCompressionMode selects how layers are stored in an archive created by a Writer.
+type LayerCompressionMode int
+
+const (
+	// LayerCompressionNone stores layers uncompressed, like (docker save); this is compatible with all consumers.
+	LayerCompressionNone LayerCompressionMode = iota
+	// LayerCompressionPreserve stores layers as they are provided, including zstd and zstd:chunked layers
+	// (images with such layers are accepted using OCI manifests).
+	// Only recent versions of Docker can load archives containing zstd layers.
+	LayerCompressionPreserve
+	// LayerCompressionGzip stores layers compressed using gzip; layers compressed using other algorithms are recompressed.
+	// This is compatible with older versions of Docker, while still making the archive smaller.
+	LayerCompressionGzip
+)
+
+// LegacyMetadataMode selects whether an archive created by a Writer contains the legacy (docker save) metadata:
+// a directory per layer (with VERSION, json, and a layer.tar link) and the repositories file.
+// Only versions of Docker before 1.10 need that metadata; all later versions use manifest.json.
+type LegacyMetadataMode int
+
+const (
+	// LegacyMetadataInclude writes the legacy metadata in addition to manifest.json, like (docker save); this is compatible with all consumers.
+	LegacyMetadataInclude LegacyMetadataMode = iota
+	// LegacyMetadataOmit writes only manifest.json, which significantly reduces the number of entries in the archive.
+	LegacyMetadataOmit
+	// LegacyMetadataOnly writes only the legacy metadata, without manifest.json, for consumers which can not handle manifest.json.
+	// It requires FormatDockerSave; such archives can not be read by Reader.
+	LegacyMetadataOnly
+)
+
+// WriterOptions contains options for NewWriterWithOptions.
+type WriterOptions struct {
+	// Format selects the metadata written to the archive; the default is FormatDockerSave.
+	Format ArchiveFormat
+	// LayerCompression selects how layers are stored; the default is LayerCompressionNone.
+	LayerCompression LayerCompressionMode
+	// LegacyMetadata selects whether the legacy (docker save) metadata is written; the default is LegacyMetadataInclude.
+	// It is ignored with FormatOCILayout.
+	LegacyMetadata LegacyMetadataMode
+	// DigestPathLinks, if set, makes every blob also available at blobsDirName/<algorithm>/<encoded digest>, as a hard link;
+	// this is the path used by OCI layouts and by archives created by recent versions of Docker, and it allows e.g.
+	// containerd to import the archive without processing the legacy layout.
+	DigestPathLinks bool
+	// StageLayers, if set, makes destinations using this Writer first write layers to temporary files, concurrently,
+	// so that only copying the layers into the archive is serialized; this uses more temporary disk space.
+	StageLayers bool
+	// Deterministic, if set, makes the archive contents depend only on the images added and their order, not on the order
+	// in which blobs are provided: all entries are held back (large ones in temporary files) until the Writer is closed,
+	// and then written sorted by path, with normalized tar headers.
+	// The JSON metadata is always written with sorted object keys; images are listed in the order they were added.
+	Deterministic bool
+	// MultiPlatform, if set, makes destinations using this Writer accept manifest lists, storing all of their instances
+	// and the list itself in the OCI layout, with tags referring to the list; this is what (docker save) of a multi-platform image
+	// creates with recent versions of Docker. It requires a Format which writes an OCI layout. With FormatDockerSaveAndOCILayout,
+	// the per-platform images are also listed in manifest.json, without tags.
+	MultiPlatform bool
+	// ForeignLayerSources, if set, records the descriptors of non-distributable (“foreign”) layers, including their URLs,
+	// in the LayerSources field of manifest.json, like (docker save) does, so that (docker load) keeps treating them as foreign layers.
+	// The URLs are only known if the layer is stored as provided in the manifest, i.e. not decompressed or recompressed
+	// (e.g. with LayerCompressionPreserve).
+	ForeignLayerSources bool
+	// Compression, if not nil, compresses the whole archive using this algorithm (e.g. compression.Gzip or compression.Zstd),
+	// as if it were piped through the compression tool; (docker load) accepts such archives, and Reader decompresses them automatically.
+	// CompressionLevel, if not nil, is the compression level to use.
+	Compression      *compression.Algorithm
+	CompressionLevel *int
+	// PreserveOCIManifests, if set, makes destinations using this Writer accept OCI manifests, and store them in the OCI layout
+	// unmodified (instead of a manifest generated from the config and layer descriptors), so that annotations and media types are preserved
+	// and images do not need to be converted to Docker schema2. It requires a Format which writes an OCI layout.
+	PreserveOCIManifests bool
+	// EntryIndex, if set, adds an index of the offsets of all entries as the last entry of the archive, so that Reader
+	// can open individual components without scanning the whole archive; (docker load) ignores the index.
+	// This only makes sense if the archive is written to the start of a regular file; it can not be used with Compression.
+	EntryIndex bool
+	// BigFilesTemporaryDir is the directory used for temporary files with Deterministic; if "", the default directory for big files is used.
+	BigFilesTemporaryDir string
+	// Progress, if not nil, is called as entries are written to the archive, see WriterProgress.
+	// It is called with the Writer locked, so it must not call any Writer methods, and it should return quickly.
+	Progress func(WriterProgress)
+}
+
+// entryIndexer is implemented by io.Writer destinations which record the start of tar entries
+// (e.g. *seekablezstd.Writer).
+type entryIndexer interface {
+	// StartEntry records that a tar entry called name starts at the current position.
+	StartEntry(name string) error
 }
 
 // NewWriter returns a Writer for the specified io.Writer.
 // The caller must eventually call .Close() on the returned object to create a valid archive.
 func NewWriter(dest io.Writer) *Writer {
-	return &Writer{
+	return newWriter(dest, WriterOptions{})
+}
+
+// NewWriterWithOptions returns a Writer for the specified io.Writer, using options.
+// The caller must eventually call .Close() on the returned object to create a valid archive.
+func NewWriterWithOptions(dest io.Writer, options WriterOptions) (*Writer, error) {
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+	w := newWriter(dest, options)
+	if options.Compression != nil {
+		if err := w.startCompression(); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// newWriter returns a Writer for dest, using options, which must have already been validated.
+// It does not set up options.Compression.
+func newWriter(dest io.Writer, options WriterOptions) *Writer {
+	w := &Writer{
 		writer:           dest,
-		tar:              tar.NewWriter(dest),
 		blobs:            make(map[digest.Digest]types.BlobInfo),
 		repositories:     map[string]map[string]string{},
 		legacyLayers:     set.New[string](),
 		manifestByConfig: map[digest.Digest]int{},
+		ociManifests:     set.New[digest.Digest](),
+		options:          options,
+	}
+	w.tar = tar.NewWriter(&countingWriter{writer: w})
+	if options.EntryIndex {
+		w.entryOffsets = []entryIndexEntry{}
+	}
+	if options.Deterministic {
+		w.pending = &pendingEntries{tempDirParent: options.BigFilesTemporaryDir}
+	}
+	return w
+}
+
+// validate returns an error if options can not be used to create a Writer.
+func (options *WriterOptions) validate() error {
+	switch options.Format {
+	case FormatDockerSave, FormatDockerSaveAndOCILayout, FormatOCILayout:
+	default:
+		return fmt.Errorf("unknown archive format %d", options.Format)
+	}
+	switch options.LayerCompression {
+	case LayerCompressionNone, LayerCompressionPreserve, LayerCompressionGzip:
+	default:
+		return fmt.Errorf("unknown layer compression mode %d", options.LayerCompression)
+	}
+	switch options.LegacyMetadata {
+	case LegacyMetadataInclude, LegacyMetadataOmit:
+	case LegacyMetadataOnly:
+		if options.Format != FormatDockerSave {
+			return errors.New("an archive with only legacy metadata can not contain an OCI layout")
+		}
+	default:
+		return fmt.Errorf("unknown legacy metadata mode %d", options.LegacyMetadata)
+	}
+	if options.MultiPlatform && options.Format == FormatDockerSave {
+		return errors.New("multi-platform images can not be stored in an archive without an OCI layout")
 	}
+	if options.PreserveOCIManifests && options.Format == FormatDockerSave {
+		return errors.New("OCI manifests can not be preserved in an archive without an OCI layout")
+	}
+	if options.EntryIndex && options.Compression != nil {
+		return errors.New("an entry index can not be written to a compressed archive")
+	}
+	return nil
+}
+
+// writesDockerSave returns true if w writes the (docker save) metadata.
+func (w *Writer) writesDockerSave() bool {
+	return w.options.Format != FormatOCILayout
+}
+
+// writesManifestJSON returns true if w writes the (docker save) manifest.json.
+func (w *Writer) writesManifestJSON() bool {
+	return w.writesDockerSave() && w.options.LegacyMetadata != LegacyMetadataOnly
+}
+
+// writesLegacyMetadata returns true if w writes the legacy (docker save) per-layer directories and the repositories file.
+func (w *Writer) writesLegacyMetadata() bool {
+	return w.writesDockerSave() && w.options.LegacyMetadata != LegacyMetadataOmit
+}
+
+// writesOCILayout returns true if w writes an OCI image layout.
+func (w *Writer) writesOCILayout() bool {
+	return w.options.Format == FormatDockerSaveAndOCILayout || w.options.Format == FormatOCILayout
 }
 
 // lock does some sanity checks and locks the Writer.
@@ -89,9 +293,35 @@ func (w *Writer) recordBlobLocked(info types.BlobInfo) {
 	w.blobs[info.Digest] = info
 }
 
+// sendBlobLocked sends a blob with blobDigest, of expectedSize, into the tar stream at path,
+// and, if requested by the options, a hard link to it at its digest path.
+// If verifyDigest, the contents of stream are verified to match blobDigest.
+// The caller must have locked the Writer.
+func (w *Writer) sendBlobLocked(ctx context.Context, path string, blobDigest digest.Digest, expectedSize int64, stream io.Reader, verifyDigest bool) error {
+	expectedDigest := digest.Digest("")
+	if verifyDigest {
+		expectedDigest = blobDigest
+	}
+	if err := w.sendFileLocked(ctx, path, expectedSize, expectedDigest, stream); err != nil {
+		return err
+	}
+	if w.options.DigestPathLinks || w.writesOCILayout() {
+		linkPath, err := digestPath(blobDigest)
+		if err != nil {
+			return err
+		}
+		if linkPath != path {
+			if err := w.sendHardLinkLocked(linkPath, path); err != nil {
+				return fmt.Errorf("creating digest path link: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
 // ensureSingleLegacyLayerLocked writes legacy VERSION and configuration files for a single layer
 // The caller must have locked the Writer.
-func (w *Writer) ensureSingleLegacyLayerLocked(layerID string, layerDigest digest.Digest, configBytes []byte) error {
+func (w *Writer) ensureSingleLegacyLayerLocked(ctx context.Context, layerID string, layerDigest digest.Digest, configBytes []byte) error {
 	if !w.legacyLayers.Contains(layerID) {
 		// Create a symlink for the legacy format, where there is one subdirectory per layer ("image").
 		// See also the comment in physicalLayerPath.
@@ -104,11 +334,11 @@ func (w *Writer) ensureSingleLegacyLayerLocked(layerID string, layerDigest diges
 		}
 
 		b := []byte("1.0")
-		if err := w.sendBytesLocked(filepath.Join(layerID, legacyVersionFileName), b); err != nil {
+		if err := w.sendBytesLocked(ctx, filepath.Join(layerID, legacyVersionFileName), b); err != nil {
 			return fmt.Errorf("writing VERSION file: %w", err)
 		}
 
-		if err := w.sendBytesLocked(filepath.Join(layerID, legacyConfigFileName), configBytes); err != nil {
+		if err := w.sendBytesLocked(ctx, filepath.Join(layerID, legacyConfigFileName), configBytes); err != nil {
 			return fmt.Errorf("writing config json file: %w", err)
 		}
 
@@ -118,7 +348,12 @@ func (w *Writer) ensureSingleLegacyLayerLocked(layerID string, layerDigest diges
 }
 
 // writeLegacyMetadataLocked writes legacy layer metadata and records tags for a single image.
-func (w *Writer) writeLegacyMetadataLocked(layerDescriptors []manifest.Schema2Descriptor, configBytes []byte, repoTags []reference.NamedTagged) error {
+// diffIDs contains the uncompressed digests of the layers in layerDescriptors.
+// The caller must have locked the Writer.
+func (w *Writer) writeLegacyMetadataLocked(ctx context.Context, layerDescriptors []manifest.Schema2Descriptor, diffIDs []digest.Digest, configBytes []byte, repoTags []reference.NamedTagged) error {
+	if len(diffIDs) != len(layerDescriptors) {
+		return fmt.Errorf("Internal error: %d layers, but %d DiffIDs", len(layerDescriptors), len(diffIDs))
+	}
 	var chainID digest.Digest
 	lastLayerID := ""
 	for i, l := range layerDescriptors {
@@ -142,14 +377,10 @@ func (w *Writer) writeLegacyMetadataLocked(layerDescriptors []manifest.Schema2De
 		}
 
 		// This chainID value matches the computation in docker/docker/layer.CreateChainID …
-		if err := l.Digest.Validate(); err != nil { // This should never fail on this code path, still: make sure the chainID computation is unambiguous.
+		if err := diffIDs[i].Validate(); err != nil { // This should never fail on this code path, still: make sure the chainID computation is unambiguous.
 			return err
 		}
-		if chainID == "" {
-			chainID = l.Digest
-		} else {
-			chainID = digest.Canonical.FromString(chainID.String() + " " + l.Digest.String())
-		}
+		chainID = ChainID(chainID, diffIDs[i])
 		// … but note that the image ID does not _exactly_ match docker/docker/image/v1.CreateID, primarily because
 		// we create the image configs differently in details. At least recent versions allocate new IDs on load,
 		// so this is fine as long as the IDs we use are unique / cannot loop.
@@ -172,7 +403,7 @@ func (w *Writer) writeLegacyMetadataLocked(layerDescriptors []manifest.Schema2De
 			return fmt.Errorf("marshaling layer config: %w", err)
 		}
 
-		if err := w.ensureSingleLegacyLayerLocked(layerID, l.Digest, configBytes); err != nil {
+		if err := w.ensureSingleLegacyLayerLocked(ctx, layerID, l.Digest, configBytes); err != nil {
 			return err
 		}
 
@@ -191,6 +422,15 @@ func (w *Writer) writeLegacyMetadataLocked(layerDescriptors []manifest.Schema2De
 	return nil
 }
 
+// ChainID returns the chain ID of a layer with diffID on top of a layer with parentChainID ("" for a base layer).
+// This value matches the computation in docker/docker/layer.CreateChainID.
+func ChainID(parentChainID, diffID digest.Digest) digest.Digest {
+	if parentChainID == "" {
+		return diffID
+	}
+	return digest.Canonical.FromString(parentChainID.String() + " " + diffID.String())
+}
+
 // checkManifestItemsMatch checks that a and b describe the same image,
 // and returns an error if that’s not the case (which should never happen).
 func checkManifestItemsMatch(a, b *ManifestItem) error {
@@ -201,20 +441,42 @@ func checkManifestItemsMatch(a, b *ManifestItem) error {
 		return fmt.Errorf("Internal error: Trying to reuse ManifestItem values with layers %#v vs. %#v", a.Layers, b.Layers)
 	}
 	// Ignore RepoTags, that will be built later.
-	// Ignore Parent and LayerSources, which we don’t set to anything meaningful.
+	// Ignore Parent, which we don’t set to anything meaningful, and LayerSources, which are determined by Layers.
 	return nil
 }
 
 // ensureManifestItemLocked ensures that there is a manifest item pointing to (layerDescriptors, configDigest) with repoTags
+// diffIDs contains the uncompressed digests of the layers in layerDescriptors.
 // The caller must have locked the Writer.
-func (w *Writer) ensureManifestItemLocked(layerDescriptors []manifest.Schema2Descriptor, configDigest digest.Digest, repoTags []reference.NamedTagged) error {
+func (w *Writer) ensureManifestItemLocked(layerDescriptors []manifest.Schema2Descriptor, diffIDs []digest.Digest, configDigest digest.Digest, repoTags []reference.NamedTagged) error {
+	if len(diffIDs) != len(layerDescriptors) {
+		return fmt.Errorf("Internal error: %d layers, but %d DiffIDs", len(layerDescriptors), len(diffIDs))
+	}
 	layerPaths := []string{}
-	for _, l := range layerDescriptors {
+	var layerSources map[digest.Digest]manifest.Schema2Descriptor
+	for i, l := range layerDescriptors {
 		p, err := w.physicalLayerPath(l.Digest)
 		if err != nil {
 			return err
 		}
 		layerPaths = append(layerPaths, p)
+		// Record the original descriptors of compressed layers, keyed by DiffID, so that consumers don’t need to
+		// determine how the layer file relates to the DiffID listed in the config.
+		foreign := w.options.ForeignLayerSources && isForeignLayerMediaType(l.MediaType)
+		if l.Digest != diffIDs[i] || foreign {
+			if layerSources == nil {
+				layerSources = map[digest.Digest]manifest.Schema2Descriptor{}
+			}
+			source := manifest.Schema2Descriptor{
+				MediaType: l.MediaType,
+				Size:      l.Size,
+				Digest:    l.Digest,
+			}
+			if foreign {
+				source.URLs = slices.Clone(l.URLs)
+			}
+			layerSources[diffIDs[i]] = source
+		}
 	}
 
 	var item *ManifestItem
@@ -227,7 +489,7 @@ func (w *Writer) ensureManifestItemLocked(layerDescriptors []manifest.Schema2Des
 		RepoTags:     []string{},
 		Layers:       layerPaths,
 		Parent:       "", // We don’t have this information
-		LayerSources: nil,
+		LayerSources: layerSources,
 	}
 	if i, ok := w.manifestByConfig[configDigest]; ok {
 		item = &w.manifest[i]
@@ -271,42 +533,256 @@ func (w *Writer) ensureManifestItemLocked(layerDescriptors []manifest.Schema2Des
 	return nil
 }
 
+// isForeignLayerMediaType returns true if mediaType is a schema2 or OCI media type of a non-distributable (“foreign”) layer.
+func isForeignLayerMediaType(mediaType string) bool {
+	switch mediaType {
+	case manifest.DockerV2Schema2ForeignLayerMediaType, manifest.DockerV2Schema2ForeignLayerMediaTypeGzip,
+		imgspecv1.MediaTypeImageLayerNonDistributable, imgspecv1.MediaTypeImageLayerNonDistributableGzip, imgspecv1.MediaTypeImageLayerNonDistributableZstd: //nolint:staticcheck // NonDistributable layers are deprecated, but we want to continue to support manipulating pre-existing images.
+		return true
+	default:
+		return false
+	}
+}
+
+// ociLayerMediaType returns the OCI media type corresponding to a layer with a schema2 or OCI mediaType.
+func ociLayerMediaType(mediaType string) (string, error) {
+	switch mediaType {
+	case imgspecv1.MediaTypeImageLayer, imgspecv1.MediaTypeImageLayerGzip, imgspecv1.MediaTypeImageLayerZstd:
+		return mediaType, nil
+	case manifest.DockerV2Schema2ForeignLayerMediaType:
+		return imgspecv1.MediaTypeImageLayerNonDistributable, nil //nolint:staticcheck // NonDistributable layers are deprecated, but we want to continue to support manipulating pre-existing images.
+	case manifest.DockerV2Schema2ForeignLayerMediaTypeGzip:
+		return imgspecv1.MediaTypeImageLayerNonDistributableGzip, nil //nolint:staticcheck // NonDistributable layers are deprecated, but we want to continue to support manipulating pre-existing images.
+	case manifest.DockerV2SchemaLayerMediaTypeUncompressed:
+		return imgspecv1.MediaTypeImageLayer, nil
+	case manifest.DockerV2Schema2LayerMediaType:
+		return imgspecv1.MediaTypeImageLayerGzip, nil
+	default:
+		return "", fmt.Errorf("Unknown layer media type %q, can not be represented in an OCI layout", mediaType)
+	}
+}
+
+// ensureOCIManifestLocked ensures that the OCI layout contains a manifest pointing to (configDescriptor, layerDescriptors),
+// listed in the index with repoTags.
+// The caller must have locked the Writer.
+func (w *Writer) ensureOCIManifestLocked(ctx context.Context, configDescriptor manifest.Schema2Descriptor, layerDescriptors []manifest.Schema2Descriptor, repoTags []reference.NamedTagged) error {
+	layers := make([]imgspecv1.Descriptor, 0, len(layerDescriptors))
+	for _, l := range layerDescriptors {
+		mediaType, err := ociLayerMediaType(l.MediaType)
+		if err != nil {
+			return err
+		}
+		layers = append(layers, imgspecv1.Descriptor{
+			MediaType: mediaType,
+			Digest:    l.Digest,
+			Size:      l.Size,
+		})
+	}
+	// The (docker save) config is used as is; the OCI config format is a subset of it.
+	m := manifest.OCI1FromComponents(imgspecv1.Descriptor{
+		MediaType: imgspecv1.MediaTypeImageConfig,
+		Digest:    configDescriptor.Digest,
+		Size:      configDescriptor.Size,
+	}, layers)
+	manifestBlob, err := m.Serialize()
+	if err != nil {
+		return err
+	}
+	desc := imgspecv1.Descriptor{
+		MediaType: imgspecv1.MediaTypeImageManifest,
+		Digest:    digest.FromBytes(manifestBlob),
+		Size:      int64(len(manifestBlob)),
+	}
+	if err := w.ensureManifestBlobLocked(ctx, desc.Digest, manifestBlob); err != nil {
+		return err
+	}
+	w.addOCIIndexEntriesLocked(desc, repoTags)
+	return nil
+}
+
+// ensurePreservedOCIManifestLocked ensures that the OCI layout contains manifestBlob, an OCI manifest, unmodified,
+// listed in the index with repoTags.
+// The caller must have locked the Writer.
+func (w *Writer) ensurePreservedOCIManifestLocked(ctx context.Context, manifestBlob []byte, repoTags []reference.NamedTagged) error {
+	desc := imgspecv1.Descriptor{
+		MediaType: imgspecv1.MediaTypeImageManifest,
+		Digest:    digest.FromBytes(manifestBlob),
+		Size:      int64(len(manifestBlob)),
+	}
+	if err := w.ensureManifestBlobLocked(ctx, desc.Digest, manifestBlob); err != nil {
+		return err
+	}
+	w.addOCIIndexEntriesLocked(desc, repoTags)
+	return nil
+}
+
+// ensureManifestBlobLocked ensures that the OCI layout contains manifestBlob, a manifest or a manifest list with manifestDigest.
+// The caller must have locked the Writer.
+func (w *Writer) ensureManifestBlobLocked(ctx context.Context, manifestDigest digest.Digest, manifestBlob []byte) error {
+	if w.ociManifests.Contains(manifestDigest) {
+		return nil
+	}
+	path, err := digestPath(manifestDigest)
+	if err != nil {
+		return err
+	}
+	if err := w.sendBytesLocked(ctx, path, manifestBlob); err != nil {
+		return fmt.Errorf("writing OCI manifest: %w", err)
+	}
+	w.ociManifests.Add(manifestDigest)
+	return nil
+}
+
+// addOCIIndexEntriesLocked ensures that the OCI index.json lists desc with each of repoTags, or at least once if there are no repoTags.
+// The caller must have locked the Writer.
+func (w *Writer) addOCIIndexEntriesLocked(desc imgspecv1.Descriptor, repoTags []reference.NamedTagged) {
+	hasEntry := func(name string) bool {
+		return slices.ContainsFunc(w.ociIndex, func(d imgspecv1.Descriptor) bool {
+			return d.Digest == desc.Digest && d.Annotations[containerdImageNameAnnotation] == name
+		})
+	}
+	for _, tag := range repoTags {
+		// See ensureManifestItemLocked for why the host name is included.
+		refString := fmt.Sprintf("%s:%s", tag.Name(), tag.Tag())
+		if hasEntry(refString) {
+			continue
+		}
+		entry := desc
+		entry.Annotations = map[string]string{
+			containerdImageNameAnnotation: refString,
+			imgspecv1.AnnotationRefName:   tag.Tag(),
+		}
+		w.ociIndex = append(w.ociIndex, entry)
+	}
+	if len(repoTags) == 0 && !slices.ContainsFunc(w.ociIndex, func(d imgspecv1.Descriptor) bool { return d.Digest == desc.Digest }) {
+		w.ociIndex = append(w.ociIndex, desc)
+	}
+}
+
+// writeOCILayoutMetadataLocked writes the oci-layout and index.json files of the OCI layout.
+// The caller must have locked the Writer.
+func (w *Writer) writeOCILayoutMetadataLocked(ctx context.Context) error {
+	b, err := json.Marshal(imgspecv1.ImageLayout{Version: imgspecv1.ImageLayoutVersion})
+	if err != nil {
+		return err
+	}
+	if err := w.sendBytesLocked(ctx, imgspecv1.ImageLayoutFile, b); err != nil {
+		return fmt.Errorf("writing %s: %w", imgspecv1.ImageLayoutFile, err)
+	}
+	b, err = json.Marshal(imgspecv1.Index{
+		Versioned: imgspec.Versioned{SchemaVersion: 2},
+		MediaType: imgspecv1.MediaTypeImageIndex,
+		Manifests: append([]imgspecv1.Descriptor{}, w.ociIndex...), // Never nil, so that this is not marshaled as null
+	})
+	if err != nil {
+		return err
+	}
+	if err := w.sendBytesLocked(ctx, imgspecv1.ImageIndexFile, b); err != nil {
+		return fmt.Errorf("writing %s: %w", imgspecv1.ImageIndexFile, err)
+	}
+	return nil
+}
+
 // Close writes all outstanding data about images to the archive, and finishes writing data
 // to the underlying io.Writer.
 // No more images can be added after this is called.
 func (w *Writer) Close() error {
+	return w.CloseWithContext(context.Background())
+}
+
+// CloseWithContext is like Close, but writing the outstanding data can be aborted by canceling ctx.
+// If writing any data to the archive has failed or has been canceled, the archive is incomplete,
+// and CloseWithContext only reports that error.
+// No more images can be added after this is called.
+func (w *Writer) CloseWithContext(ctx context.Context) error {
 	if err := w.lock(); err != nil {
 		return err
 	}
 	defer w.unlock()
 
-	b, err := json.Marshal(&w.manifest)
-	if err != nil {
-		return err
+	if w.pending != nil {
+		defer w.pending.cleanup()
+	}
+	if w.failed != nil {
+		w.tar = nil // Mark the Writer as closed; don’t even try to terminate the tar stream, it would only look valid.
+		w.finishCompressionLocked()
+		w.closeWriterLocked()
+		return fmt.Errorf("archive is incomplete: %w", w.failed)
 	}
-	if err := w.sendBytesLocked(manifestFileName, b); err != nil {
+
+	if err := w.writeAdditionalFilesLocked(ctx); err != nil {
 		return err
 	}
 
-	b, err = json.Marshal(w.repositories)
-	if err != nil {
-		return fmt.Errorf("marshaling repositories: %w", err)
+	if w.writesManifestJSON() {
+		b, err := json.Marshal(&w.manifest)
+		if err != nil {
+			return err
+		}
+		if err := w.sendBytesLocked(ctx, manifestFileName, b); err != nil {
+			return err
+		}
 	}
-	if err := w.sendBytesLocked(legacyRepositoriesFileName, b); err != nil {
-		return fmt.Errorf("writing config json file: %w", err)
+	if w.writesLegacyMetadata() {
+		b, err := json.Marshal(w.repositories)
+		if err != nil {
+			return fmt.Errorf("marshaling repositories: %w", err)
+		}
+		if err := w.sendBytesLocked(ctx, legacyRepositoriesFileName, b); err != nil {
+			return fmt.Errorf("writing config json file: %w", err)
+		}
 	}
 
-	if err := w.tar.Close(); err != nil {
-		return err
+	if w.writesOCILayout() {
+		if err := w.writeOCILayoutMetadataLocked(ctx); err != nil {
+			return err
+		}
 	}
+
+	if w.pending != nil {
+		if err := w.writePendingEntriesLocked(ctx); err != nil {
+			return err
+		}
+	}
+
+	if w.entryOffsets != nil {
+		if err := w.writeEntryIndexLocked(ctx); err != nil {
+			return err
+		}
+	}
+
+	err := w.tar.Close()
 	w.tar = nil // Mark the Writer as closed.
-	return nil
+	if err2 := w.finishCompressionLocked(); err == nil {
+		err = err2
+	}
+	if err == nil {
+		w.reportProgressLocked("", 0, 0)
+	}
+	if err2 := w.closeWriterLocked(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// closeWriterLocked closes w.closer, if any.
+// The caller must have locked the Writer.
+func (w *Writer) closeWriterLocked() error {
+	if w.closer == nil {
+		return nil
+	}
+	err := w.closer.Close()
+	w.closer = nil
+	return err
 }
 
 // configPath returns a path we choose for storing a config with the specified digest.
 // NOTE: This is an internal implementation detail, not a format property, and can change
 // any time.
 func (w *Writer) configPath(configDigest digest.Digest) (string, error) {
+	if !w.writesDockerSave() {
+		return digestPath(configDigest)
+	}
 	if err := configDigest.Validate(); err != nil { // digest.Digest.Encoded() panics on failure, and could possibly result in unexpected paths, so validate explicitly.
 		return "", err
 	}
@@ -318,6 +794,9 @@ func (w *Writer) configPath(configDigest digest.Digest) (string, error) {
 // NOTE: This is an internal implementation detail, not a format property, and can change
 // any time.
 func (w *Writer) physicalLayerPath(layerDigest digest.Digest) (string, error) {
+	if !w.writesDockerSave() {
+		return digestPath(layerDigest)
+	}
 	if err := layerDigest.Validate(); err != nil { // digest.Digest.Encoded() panics on failure, and could possibly result in unexpected paths, so validate explicitly.
 		return "", err
 	}
@@ -329,6 +808,14 @@ func (w *Writer) physicalLayerPath(layerDigest digest.Digest) (string, error) {
 	return layerDigest.Encoded() + ".tar", nil
 }
 
+// digestPath returns the path of a blob with blobDigest in an OCI image layout.
+func digestPath(blobDigest digest.Digest) (string, error) {
+	if err := blobDigest.Validate(); err != nil { // digest.Digest.Encoded() panics on failure, and could possibly result in unexpected paths, so validate explicitly.
+		return "", err
+	}
+	return filepath.Join(blobsDirName, blobDigest.Algorithm().String(), blobDigest.Encoded()), nil
+}
+
 type tarFI struct {
 	path      string
 	size      int64
@@ -357,6 +844,35 @@ func (t *tarFI) Sys() any {
 	return nil
 }
 
+// startEntryLocked notifies w.writer, if it is an entryIndexer, that a tar entry called path is about to be written.
+// The caller must have locked the Writer.
+func (w *Writer) startEntryLocked(path string) error {
+	indexer, ok := w.writer.(entryIndexer)
+	if !ok {
+		return nil
+	}
+	if err := w.tar.Flush(); err != nil { // Write the padding of the previous entry, so that the new entry starts at its header.
+		return err
+	}
+	return indexer.StartEntry(path)
+}
+
+// writeHeaderLocked writes hdr into the tar stream, notifying w.writer and w.options.Progress as appropriate.
+// The caller must have locked the Writer.
+func (w *Writer) writeHeaderLocked(hdr *tar.Header) error {
+	if err := w.startEntryLocked(hdr.Name); err != nil {
+		return err
+	}
+	if err := w.recordEntryOffsetLocked(hdr.Name); err != nil {
+		return err
+	}
+	if err := w.tar.WriteHeader(hdr); err != nil {
+		return err
+	}
+	w.reportProgressLocked(hdr.Name, 0, hdr.Size)
+	return nil
+}
+
 // sendSymlinkLocked sends a symlink into the tar stream.
 // The caller must have locked the Writer.
 func (w *Writer) sendSymlinkLocked(path string, target string) error {
@@ -365,33 +881,123 @@ func (w *Writer) sendSymlinkLocked(path string, target string) error {
 		return err
 	}
 	logrus.Debugf("Sending as tar link %s -> %s", path, target)
-	return w.tar.WriteHeader(hdr)
+	if w.pending != nil {
+		w.pending.addHeader(hdr)
+		return nil
+	}
+	return w.writeHeaderLocked(hdr)
+}
+
+// sendHardLinkLocked sends a hard link to target, another entry in the tar stream, into the tar stream.
+// The caller must have locked the Writer.
+func (w *Writer) sendHardLinkLocked(path string, target string) error {
+	hdr := &tar.Header{
+		Typeflag: tar.TypeLink,
+		Name:     path,
+		Linkname: target,
+		Mode:     0444,
+		ModTime:  time.Unix(0, 0),
+	}
+	logrus.Debugf("Sending as tar hard link %s -> %s", path, target)
+	if w.pending != nil {
+		w.pending.addHeader(hdr)
+		return nil
+	}
+	return w.writeHeaderLocked(hdr)
 }
 
 // sendBytesLocked sends a path into the tar stream.
 // The caller must have locked the Writer.
-func (w *Writer) sendBytesLocked(path string, b []byte) error {
-	return w.sendFileLocked(path, int64(len(b)), bytes.NewReader(b))
+func (w *Writer) sendBytesLocked(ctx context.Context, path string, b []byte) error {
+	return w.sendFileLocked(ctx, path, int64(len(b)), "", bytes.NewReader(b))
 }
 
 // sendFileLocked sends a file into the tar stream.
+// If expectedDigest is not "", the contents of stream must match it.
+// Copying stream is aborted if ctx is canceled; if that, or any other failure to write the file contents
+// (including a digest mismatch), happens, the tar stream is left incomplete, and the Writer refuses to write any more entries.
 // The caller must have locked the Writer.
-func (w *Writer) sendFileLocked(path string, expectedSize int64, stream io.Reader) error {
+func (w *Writer) sendFileLocked(ctx context.Context, path string, expectedSize int64, expectedDigest digest.Digest, stream io.Reader) error {
+	if w.failed != nil {
+		return fmt.Errorf("archive is incomplete: %w", w.failed)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if expectedDigest != "" {
+		if err := expectedDigest.Validate(); err != nil { // Make sure expectedDigest.Algorithm().Digester() does not panic.
+			return err
+		}
+		stream = &digestVerifyingReader{source: stream, path: path, expected: expectedDigest, digester: expectedDigest.Algorithm().Digester()}
+	}
 	hdr, err := tar.FileInfoHeader(&tarFI{path: path, size: expectedSize}, "")
 	if err != nil {
 		return err
 	}
 	logrus.Debugf("Sending as tar file %s", path)
-	if err := w.tar.WriteHeader(hdr); err != nil {
+	if w.pending != nil {
+		return w.pending.addFile(ctx, hdr, stream)
+	}
+	return w.writeFileEntryLocked(ctx, hdr, stream)
+}
+
+// digestVerifyingReader is an io.Reader which fails at EOF if the contents of source don’t match expected.
+type digestVerifyingReader struct {
+	source   io.Reader
+	path     string
+	expected digest.Digest
+	digester digest.Digester
+}
+
+func (r *digestVerifyingReader) Read(p []byte) (int, error) {
+	n, err := r.source.Read(p)
+	if n > 0 {
+		if _, err := r.digester.Hash().Write(p[:n]); err != nil {
+			return 0, err // Coverage: This should not happen, hash.Hash.Write never fails.
+		}
+	}
+	if errors.Is(err, io.EOF) {
+		if actual := r.digester.Digest(); actual != r.expected {
+			return n, fmt.Errorf("Digest mismatch when copying %s, expected %s, got %s", r.path, r.expected, actual)
+		}
+	}
+	return n, err
+}
+
+// writeFileEntryLocked writes a regular file with hdr and contents from stream into the tar stream.
+// Copying stream is aborted if ctx is canceled; if that, or any other failure to write the file contents, happens,
+// the tar stream is left incomplete, and the Writer refuses to write any more entries.
+// The caller must have locked the Writer.
+func (w *Writer) writeFileEntryLocked(ctx context.Context, hdr *tar.Header, stream io.Reader) error {
+	if err := w.writeHeaderLocked(hdr); err != nil {
 		return err
 	}
-	// TODO: This can take quite some time, and should ideally be cancellable using a context.Context.
-	size, err := io.Copy(w.tar, stream)
+	var dest io.Writer = w.tar
+	if w.options.Progress != nil {
+		dest = &entryProgressWriter{writer: w, hdr: hdr}
+	}
+	size, err := io.Copy(dest, contextReader{ctx: ctx, reader: stream})
 	if err != nil {
+		w.failed = fmt.Errorf("writing %s: %w", hdr.Name, err)
 		return err
 	}
-	if size != expectedSize {
-		return fmt.Errorf("Size mismatch when copying %s, expected %d, got %d", path, expectedSize, size)
+	if size != hdr.Size {
+		err := fmt.Errorf("Size mismatch when copying %s, expected %d, got %d", hdr.Name, hdr.Size, size)
+		w.failed = err
+		return err
 	}
 	return nil
 }
+
+// contextReader is an io.Reader which fails with ctx.Err() once ctx is canceled.
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.reader.Read(p)
+}
@@ -0,0 +1,82 @@
+package tarfile
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+)
+
+// AdditionalFilesCallback is called by Writer.CloseWithContext, after all images have been added, to add custom files
+// (e.g. a LICENSE, or a list of checksums) to the archive by calling addFile.
+// addFile writes a regular file at path (relative to the archive root, using forward slashes), with size bytes read from contents.
+// The callback is called with the Writer locked, so it must not call any Writer methods.
+type AdditionalFilesCallback func(ctx context.Context, addFile func(path string, size int64, contents io.Reader) error) error
+
+// AddFilesOnClose registers callback to add custom files to the archive when the Writer is closed.
+// Callbacks are called in the order they were registered; the files are written before the archive metadata.
+// The paths must not conflict with files written by the Writer itself.
+func (w *Writer) AddFilesOnClose(callback AdditionalFilesCallback) error {
+	if err := w.lock(); err != nil {
+		return err
+	}
+	defer w.unlock()
+
+	w.additionalFiles = append(w.additionalFiles, callback)
+	return nil
+}
+
+// writeAdditionalFilesLocked calls the callbacks registered by AddFilesOnClose.
+// The caller must have locked the Writer.
+func (w *Writer) writeAdditionalFilesLocked(ctx context.Context) error {
+	written := map[string]struct{}{}
+	addFile := func(filePath string, size int64, contents io.Reader) error {
+		if err := validateAdditionalFilePath(filePath); err != nil {
+			return err
+		}
+		if _, ok := written[filePath]; ok {
+			return fmt.Errorf("additional file %q was already added", filePath)
+		}
+		if size < 0 {
+			return fmt.Errorf("invalid size %d of additional file %q", size, filePath)
+		}
+		if err := w.sendFileLocked(ctx, filePath, size, "", contents); err != nil {
+			return fmt.Errorf("writing additional file %q: %w", filePath, err)
+		}
+		written[filePath] = struct{}{}
+		return nil
+	}
+	for _, callback := range w.additionalFiles {
+		if err := callback(ctx, addFile); err != nil {
+			return fmt.Errorf("adding additional files: %w", err)
+		}
+	}
+	return nil
+}
+
+// validateAdditionalFilePath returns an error if filePath can not be used for a file added by AddFilesOnClose,
+// because it is not a canonical relative path, or because it might conflict with files written by the Writer.
+func validateAdditionalFilePath(filePath string) error {
+	if filePath == "" || path.Clean(filePath) != filePath || path.IsAbs(filePath) ||
+		filePath == ".." || strings.HasPrefix(filePath, "../") {
+		return fmt.Errorf("invalid additional file path %q", filePath)
+	}
+	if isArchiveMetadata(filePath) {
+		return fmt.Errorf("additional file path %q conflicts with the archive metadata", filePath)
+	}
+	first, _, _ := strings.Cut(filePath, "/")
+	if first == blobsDirName {
+		return fmt.Errorf("additional file path %q conflicts with the blobs directory", filePath)
+	}
+	// Layers, configs and legacy layer directories use paths based on hexadecimal IDs.
+	base, _, _ := strings.Cut(first, ".")
+	for _, algorithm := range []digest.Algorithm{digest.SHA256, digest.SHA384, digest.SHA512} {
+		if algorithm.Validate(base) == nil {
+			return fmt.Errorf("additional file path %q may conflict with image contents", filePath)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package tarfile
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterAddFilesOnClose(t *testing.T) {
+	archive := bytes.Buffer{}
+	writer := NewWriter(&archive)
+	writeTestImage(t, writer, "example.com/repo:tag", "config", []byte("layer"))
+	calls := []string{}
+	err := writer.AddFilesOnClose(func(ctx context.Context, addFile func(path string, size int64, contents io.Reader) error) error {
+		calls = append(calls, "first")
+		if err := addFile("LICENSE", 7, strings.NewReader("license")); err != nil {
+			return err
+		}
+		return addFile("meta/checksums.txt", 4, strings.NewReader("sums"))
+	})
+	require.NoError(t, err)
+	err = writer.AddFilesOnClose(func(ctx context.Context, addFile func(path string, size int64, contents io.Reader) error) error {
+		calls = append(calls, "second")
+		return addFile("metadata.json", 2, strings.NewReader("{}"))
+	})
+	require.NoError(t, err)
+	err = writer.Close()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first", "second"}, calls)
+
+	names := []string{}
+	files := map[string]string{}
+	tr := tar.NewReader(&archive)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, hdr.Name)
+		contents, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[hdr.Name] = string(contents)
+	}
+	assert.Equal(t, "license", files["LICENSE"])
+	assert.Equal(t, "sums", files["meta/checksums.txt"])
+	assert.Equal(t, "{}", files["metadata.json"])
+	// The additional files precede the archive metadata, so that the archive can be appended to.
+	assert.Equal(t, []string{"LICENSE", "meta/checksums.txt", "metadata.json", manifestFileName, legacyRepositoriesFileName}, names[len(names)-5:])
+
+	for _, c := range []struct {
+		path string
+		size int64
+	}{
+		{"", 1},
+		{"/abs", 1},
+		{"../outside", 1},
+		{"dir/../file", 1},
+		{"dir/", 1},
+		{manifestFileName, 1},
+		{legacyRepositoriesFileName, 1},
+		{blobsDirName + "/sha256/file", 1},
+		{digest.FromString("x").Encoded() + ".tar", 1},
+		{digest.FromString("x").Encoded() + "/VERSION", 1},
+		{"negative-size", -1},
+		{"short", 2},
+	} {
+		writer := NewWriter(io.Discard)
+		err := writer.AddFilesOnClose(func(ctx context.Context, addFile func(path string, size int64, contents io.Reader) error) error {
+			return addFile(c.path, c.size, strings.NewReader("x"))
+		})
+		require.NoError(t, err)
+		err = writer.Close()
+		assert.Error(t, err, c.path)
+	}
+
+	// The same path can not be added twice.
+	writer = NewWriter(io.Discard)
+	err = writer.AddFilesOnClose(func(ctx context.Context, addFile func(path string, size int64, contents io.Reader) error) error {
+		if err := addFile("file", 1, strings.NewReader("x")); err != nil {
+			return err
+		}
+		return addFile("file", 1, strings.NewReader("x"))
+	})
+	require.NoError(t, err)
+	err = writer.Close()
+	assert.Error(t, err)
+}
@@ -0,0 +1,283 @@
+package tarfile
+
+import (
+	"archive/tar"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/containers/image/v5/internal/iolimits"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// appendEntry is a tar entry of an existing archive opened by OpenWriterForAppend.
+type appendEntry struct {
+	header       *tar.Header
+	headerOffset int64 // The offset of the (first) tar header of the entry in the archive file
+	dataOffset   int64 // The offset of the entry contents in the archive file
+}
+
+// OpenWriterForAppend returns a Writer which adds images to an existing, uncompressed (docker save)-formatted archive at path,
+// e.g. one created by a previous Writer.
+// The images, tags and blobs already in the archive are preserved; the archive metadata (manifest.json, repositories, and,
+// if the archive contains an OCI image layout, index.json) is rewritten, including the new images, when the Writer is closed.
+//
+// The archive is modified in place: the caller must eventually call .Close() on the returned object to create a valid archive,
+// and if adding an image fails, the archive is left incomplete.
+func OpenWriterForAppend(path string) (*Writer, error) {
+	return OpenWriterForAppendWithOptions(path, WriterOptions{})
+}
+
+// OpenWriterForAppendWithOptions is like OpenWriterForAppend, but uses options.
+// options.Format, options.DigestPathLinks and options.LegacyMetadata are ignored; they are determined by the contents of the existing archive.
+// options.MultiPlatform and options.PreserveOCIManifests require the existing archive to contain an OCI image layout.
+// options.Deterministic is not supported.
+func OpenWriterForAppendWithOptions(path string, options WriterOptions) (*Writer, error) {
+	if options.Deterministic {
+		return nil, errors.New("deterministic archives can not be created by appending to an existing archive")
+	}
+	if options.Compression != nil {
+		return nil, errors.New("compressed archives can not be created by appending to an existing archive")
+	}
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("opening archive %q: %w", path, err)
+	}
+	succeeded := false
+	defer func() {
+		if !succeeded {
+			f.Close()
+		}
+	}()
+
+	entries, metadataOffset, err := scanArchiveForAppend(f)
+	if err != nil {
+		return nil, fmt.Errorf("reading archive %q: %w", path, err)
+	}
+	options.Format = FormatDockerSave
+	if _, ok := entries[imgspecv1.ImageIndexFile]; ok {
+		options.Format = FormatDockerSaveAndOCILayout
+	}
+	options.LegacyMetadata = LegacyMetadataOmit
+	if _, ok := entries[legacyRepositoriesFileName]; ok {
+		options.LegacyMetadata = LegacyMetadataInclude
+	}
+	options.DigestPathLinks = false
+	for name, e := range entries {
+		if e.header.Typeflag == tar.TypeLink && strings.HasPrefix(name, blobsDirName+"/") {
+			options.DigestPathLinks = true
+			break
+		}
+	}
+
+	w, err := NewWriterWithOptions(f, options)
+	if err != nil {
+		return nil, fmt.Errorf("adding to archive %q: %w", path, err)
+	}
+	w.closer = f
+	w.entryIndexBase = metadataOffset
+	if options.EntryIndex {
+		for name, e := range entries {
+			if !isArchiveMetadata(name) {
+				w.entryOffsets = append(w.entryOffsets, entryIndexEntry{Name: name, Offset: e.headerOffset})
+			}
+		}
+		slices.SortFunc(w.entryOffsets, func(a, b entryIndexEntry) int { return cmp.Compare(a.Offset, b.Offset) })
+	}
+	if err := w.loadArchiveState(f, entries); err != nil {
+		return nil, fmt.Errorf("reading archive %q: %w", path, err)
+	}
+	// Drop the metadata and the end-of-archive marker; new entries are written in their place.
+	if err := f.Truncate(metadataOffset); err != nil {
+		return nil, fmt.Errorf("truncating archive %q: %w", path, err)
+	}
+	if _, err := f.Seek(metadataOffset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	succeeded = true
+	return w, nil
+}
+
+// isArchiveMetadata returns true if name is a file written by Writer.CloseWithContext, describing all images in the archive.
+func isArchiveMetadata(name string) bool {
+	switch name {
+	case manifestFileName, legacyRepositoriesFileName, imgspecv1.ImageLayoutFile, imgspecv1.ImageIndexFile, entryIndexFileName:
+		return true
+	default:
+		return false
+	}
+}
+
+// scanArchiveForAppend reads the tar entries of f, and returns them, along with the offset of the archive metadata,
+// which must follow all other entries.
+func scanArchiveForAppend(f *os.File) (map[string]appendEntry, int64, error) {
+	entries := map[string]appendEntry{}
+	metadataOffset := int64(-1)
+	entryOffset := int64(0) // The offset of the (first header of the) entry returned by the next tr.Next() call.
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, -1, err
+		}
+		dataOffset, err := f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, -1, err
+		}
+		name := path.Clean(hdr.Name)
+		if isArchiveMetadata(name) {
+			if metadataOffset == -1 {
+				metadataOffset = entryOffset
+			}
+		} else if metadataOffset != -1 {
+			return nil, -1, fmt.Errorf("unexpected entry %q after the archive metadata; archives not created by this library are not supported", hdr.Name)
+		}
+		if _, ok := entries[name]; ok {
+			return nil, -1, fmt.Errorf("duplicate entry %q", hdr.Name)
+		}
+		entries[name] = appendEntry{header: hdr, headerOffset: entryOffset, dataOffset: dataOffset}
+		entryOffset = dataOffset
+		if hdr.Typeflag == tar.TypeReg {
+			entryOffset += (hdr.Size + 511) &^ 511 // Entry contents are padded to 512-byte blocks.
+		}
+	}
+	if _, ok := entries[manifestFileName]; !ok {
+		return nil, -1, fmt.Errorf("%s not found; only (docker save)-formatted archives are supported", manifestFileName)
+	}
+	return entries, metadataOffset, nil
+}
+
+// readAppendEntry returns the contents of the regular file called name in f, scanned into entries, which must be at most limit bytes.
+func readAppendEntry(f *os.File, entries map[string]appendEntry, name string, limit int) ([]byte, error) {
+	e, ok := entries[path.Clean(name)]
+	if !ok {
+		return nil, fmt.Errorf("%q not found", name)
+	}
+	if e.header.Typeflag != tar.TypeReg {
+		return nil, fmt.Errorf("%q is not a regular file", name)
+	}
+	if e.header.Size > int64(limit) {
+		return nil, fmt.Errorf("%q is too large", name)
+	}
+	data := make([]byte, e.header.Size)
+	if _, err := f.ReadAt(data, e.dataOffset); err != nil {
+		return nil, fmt.Errorf("reading %q: %w", name, err)
+	}
+	return data, nil
+}
+
+// loadArchiveState records the images, tags and blobs of an existing archive in f, scanned into entries, in w.
+// It is only used during construction of w, so it does not lock it.
+func (w *Writer) loadArchiveState(f *os.File, entries map[string]appendEntry) error {
+	data, err := readAppendEntry(f, entries, manifestFileName, iolimits.MaxTarFileManifestSize)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &w.manifest); err != nil {
+		return fmt.Errorf("parsing %s: %w", manifestFileName, err)
+	}
+	if _, ok := entries[legacyRepositoriesFileName]; ok {
+		data, err := readAppendEntry(f, entries, legacyRepositoriesFileName, iolimits.MaxTarFileManifestSize)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &w.repositories); err != nil {
+			return fmt.Errorf("parsing %s: %w", legacyRepositoriesFileName, err)
+		}
+		if w.repositories == nil {
+			w.repositories = map[string]map[string]string{}
+		}
+	}
+	if w.writesOCILayout() {
+		data, err := readAppendEntry(f, entries, imgspecv1.ImageIndexFile, iolimits.MaxTarFileManifestSize)
+		if err != nil {
+			return err
+		}
+		var index imgspecv1.Index
+		if err := json.Unmarshal(data, &index); err != nil {
+			return fmt.Errorf("parsing %s: %w", imgspecv1.ImageIndexFile, err)
+		}
+		w.ociIndex = index.Manifests
+		for _, desc := range index.Manifests {
+			w.ociManifests.Add(desc.Digest)
+			if manifest.MIMETypeIsMultiImage(desc.MediaType) {
+				// Instances of manifest lists, written with WriterOptions.MultiPlatform, are not listed in the index.
+				listPath, err := digestPath(desc.Digest)
+				if err != nil {
+					return err
+				}
+				listBlob, err := readAppendEntry(f, entries, listPath, iolimits.MaxManifestBodySize)
+				if err != nil {
+					return err
+				}
+				list, err := manifest.ListFromBlob(listBlob, desc.MediaType)
+				if err != nil {
+					return fmt.Errorf("parsing manifest list %s: %w", desc.Digest.String(), err)
+				}
+				w.ociManifests.AddSeq(slices.Values(list.Instances()))
+			}
+		}
+	}
+
+	for name, e := range entries {
+		if e.header.Typeflag == tar.TypeSymlink && path.Base(name) == legacyLayerFileName {
+			w.legacyLayers.Add(path.Dir(name))
+		}
+	}
+
+	for i := range w.manifest {
+		item := &w.manifest[i]
+		configBytes, err := readAppendEntry(f, entries, item.Config, iolimits.MaxConfigBodySize)
+		if err != nil {
+			return err
+		}
+		configDigest := digest.Canonical.FromBytes(configBytes)
+		if p, err := w.configPath(configDigest); err != nil || p != path.Clean(item.Config) {
+			return fmt.Errorf("config %q does not match its digest %s", item.Config, configDigest.String())
+		}
+		if _, ok := w.manifestByConfig[configDigest]; ok {
+			return fmt.Errorf("duplicate %s entries for config %s", manifestFileName, configDigest.String())
+		}
+		w.manifestByConfig[configDigest] = i
+		w.recordBlobLocked(types.BlobInfo{Digest: configDigest, Size: int64(len(configBytes))})
+
+		var config struct {
+			RootFS struct {
+				DiffIDs []digest.Digest `json:"diff_ids"`
+			} `json:"rootfs"`
+		}
+		if err := json.Unmarshal(configBytes, &config); err != nil {
+			return fmt.Errorf("parsing config %s: %w", configDigest.String(), err)
+		}
+		if len(config.RootFS.DiffIDs) != len(item.Layers) {
+			return fmt.Errorf("config %s lists %d layers, but %s lists %d", configDigest.String(), len(config.RootFS.DiffIDs), manifestFileName, len(item.Layers))
+		}
+		for j, layerPath := range item.Layers {
+			layerDigest := config.RootFS.DiffIDs[j]
+			if source, ok := item.LayerSources[layerDigest]; ok {
+				layerDigest = source.Digest
+			}
+			if p, err := w.physicalLayerPath(layerDigest); err != nil || p != path.Clean(layerPath) {
+				return fmt.Errorf("unexpected path %q of layer %s", layerPath, layerDigest.String())
+			}
+			e, ok := entries[path.Clean(layerPath)]
+			if !ok || e.header.Typeflag != tar.TypeReg {
+				return fmt.Errorf("layer %q not found", layerPath)
+			}
+			w.recordBlobLocked(types.BlobInfo{Digest: layerDigest, Size: e.header.Size})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package tarfile
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/pkg/blobinfocache/memory"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestImage writes an image with layers, and a config distinguished by configComment, to writer, tagged with ref.
+func writeTestImage(t *testing.T, writer *Writer, ref string, configComment string, layers ...[]byte) {
+	ctx := context.Background()
+	cache := memory.New()
+	named, err := reference.ParseNormalizedNamed(ref)
+	require.NoError(t, err)
+	tagged, ok := named.(reference.NamedTagged)
+	require.True(t, ok)
+
+	diffIDs := []string{}
+	descriptors := []manifest.Schema2Descriptor{}
+	for _, layer := range layers {
+		layerDigest := digest.FromBytes(layer)
+		diffIDs = append(diffIDs, `"`+layerDigest.String()+`"`)
+		descriptors = append(descriptors, manifest.Schema2Descriptor{
+			MediaType: manifest.DockerV2Schema2LayerMediaType,
+			Size:      int64(len(layer)),
+			Digest:    layerDigest,
+		})
+	}
+	config := `{"comment":"` + configComment + `","rootfs":{"type":"layers","diff_ids":[` + strings.Join(diffIDs, ",") + `]}}`
+
+	dest := NewDestination(nil, writer, "transport name", tagged, nil)
+	configInfo, err := dest.PutBlob(ctx, strings.NewReader(config), types.BlobInfo{Size: -1}, cache, true)
+	require.NoError(t, err)
+	for _, layer := range layers {
+		layerDigest := digest.FromBytes(layer)
+		reused, _, err := dest.TryReusingBlob(ctx, types.BlobInfo{Digest: layerDigest, Size: int64(len(layer))}, cache, false)
+		require.NoError(t, err)
+		if !reused {
+			_, err = dest.PutBlob(ctx, bytes.NewReader(layer), types.BlobInfo{Digest: layerDigest, Size: int64(len(layer))}, cache, false)
+			require.NoError(t, err)
+		}
+	}
+	manifestBlob, err := manifest.Schema2FromComponents(manifest.Schema2Descriptor{
+		MediaType: manifest.DockerV2Schema2ConfigMediaType,
+		Size:      configInfo.Size,
+		Digest:    configInfo.Digest,
+	}, descriptors).Serialize()
+	require.NoError(t, err)
+	err = dest.PutManifest(ctx, manifestBlob, nil)
+	require.NoError(t, err)
+}
+
+func TestOpenWriterForAppend(t *testing.T) {
+	layer1 := []byte("layer 1")
+	layer2 := []byte("layer 2")
+
+	for _, format := range []ArchiveFormat{FormatDockerSave, FormatDockerSaveAndOCILayout} {
+		path := filepath.Join(t.TempDir(), "archive.tar")
+		f, err := os.Create(path)
+		require.NoError(t, err)
+		writer, err := NewWriterWithOptions(f, WriterOptions{Format: format})
+		require.NoError(t, err)
+		writeTestImage(t, writer, "example.com/first:tag", "first", layer1)
+		err = writer.Close()
+		require.NoError(t, err)
+		err = f.Close()
+		require.NoError(t, err)
+
+		writer, err = OpenWriterForAppend(path)
+		require.NoError(t, err, format)
+		assert.Equal(t, format, writer.options.Format)
+		writeTestImage(t, writer, "example.com/second:tag", "second", layer1, layer2)
+		writeTestImage(t, writer, "example.com/first:other", "first", layer1) // An existing image with a new tag
+		err = writer.Close()
+		require.NoError(t, err, format)
+
+		// Every entry is present exactly once, and the shared layer was not added again.
+		f, err = os.Open(path)
+		require.NoError(t, err)
+		defer f.Close()
+		names := map[string]int{}
+		files := map[string][]byte{}
+		tr := tar.NewReader(f)
+		for {
+			hdr, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			require.NoError(t, err, format)
+			names[hdr.Name]++
+			if hdr.Typeflag == tar.TypeReg {
+				data, err := io.ReadAll(tr)
+				require.NoError(t, err)
+				files[hdr.Name] = data
+			}
+		}
+		for name, count := range names {
+			assert.Equal(t, 1, count, "%v: %s", format, name)
+		}
+		assert.Contains(t, names, digest.FromBytes(layer2).Encoded()+".tar")
+
+		var items []ManifestItem
+		err = json.Unmarshal(files[manifestFileName], &items)
+		require.NoError(t, err)
+		require.Len(t, items, 2)
+		assert.Equal(t, []string{"example.com/first:tag", "example.com/first:other"}, items[0].RepoTags)
+		assert.Len(t, items[0].Layers, 1)
+		assert.Equal(t, []string{"example.com/second:tag"}, items[1].RepoTags)
+		assert.Len(t, items[1].Layers, 2)
+		var repositories map[string]map[string]string
+		err = json.Unmarshal(files[legacyRepositoriesFileName], &repositories)
+		require.NoError(t, err)
+		assert.Len(t, repositories["example.com/first"], 2)
+		assert.Len(t, repositories["example.com/second"], 1)
+		if format == FormatDockerSaveAndOCILayout {
+			var index imgspecv1.Index
+			err = json.Unmarshal(files[imgspecv1.ImageIndexFile], &index)
+			require.NoError(t, err)
+			assert.Len(t, index.Manifests, 3)
+		}
+
+		// The result can be read.
+		reader, err := NewReaderFromFile(nil, path)
+		require.NoError(t, err)
+		assert.Len(t, reader.Manifest, 2)
+		err = reader.Close()
+		require.NoError(t, err)
+	}
+
+	// Archives without (docker save) metadata are rejected.
+	path := filepath.Join(t.TempDir(), "archive.tar")
+	err := os.WriteFile(path, []byte{}, 0o600)
+	require.NoError(t, err)
+	_, err = OpenWriterForAppend(path)
+	assert.Error(t, err)
+}
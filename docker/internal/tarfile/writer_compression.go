@@ -0,0 +1,55 @@
+package tarfile
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"github.com/containers/image/v5/pkg/compression"
+)
+
+// startCompression makes w compress the archive written to w.writer, as requested by w.options.Compression.
+// It must be called before any data is written.
+func (w *Writer) startCompression() error {
+	counter := &compressedCounter{dest: w.writer}
+	compressor, err := compression.CompressStream(counter, *w.options.Compression, w.options.CompressionLevel)
+	if err != nil {
+		return fmt.Errorf("compressing the archive using %s: %w", w.options.Compression.Name(), err)
+	}
+	w.compressed = counter
+	w.compressor = compressor
+	w.writer = compressor
+	return nil
+}
+
+// finishCompressionLocked finishes the compressed stream, if any. It does not close the underlying io.Writer.
+// The caller must have locked the Writer.
+func (w *Writer) finishCompressionLocked() error {
+	if w.compressor == nil {
+		return nil
+	}
+	err := w.compressor.Close()
+	w.compressor = nil
+	return err
+}
+
+// compressedSize returns the number of compressed bytes written so far, or 0 if the archive is not compressed.
+func (w *Writer) compressedSize() int64 {
+	if w.compressed == nil {
+		return 0
+	}
+	return w.compressed.count.Load()
+}
+
+// compressedCounter writes to dest, and counts the written bytes.
+// The compressor might write from other goroutines, so the count is accessed atomically.
+type compressedCounter struct {
+	dest  io.Writer
+	count atomic.Int64
+}
+
+func (c *compressedCounter) Write(p []byte) (int, error) {
+	n, err := c.dest.Write(p)
+	c.count.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,68 @@
+package tarfile
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containers/image/v5/pkg/compression"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterCompression(t *testing.T) {
+	layer := bytes.Repeat([]byte("layer data"), 10000)
+
+	for _, algo := range []compression.Algorithm{compression.Gzip, compression.Zstd} {
+		path := filepath.Join(t.TempDir(), "archive.tar")
+		f, err := os.Create(path)
+		require.NoError(t, err)
+		var last WriterProgress
+		writer, err := NewWriterWithOptions(f, WriterOptions{
+			Compression: &algo,
+			Progress: func(p WriterProgress) {
+				last = p
+			},
+		})
+		require.NoError(t, err)
+		writeTestImage(t, writer, "example.com/repo:tag", "config", layer)
+		err = writer.Close()
+		require.NoError(t, err)
+		err = f.Close()
+		require.NoError(t, err)
+
+		fi, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, fi.Size(), last.CompressedSize, algo.Name())
+		assert.Greater(t, last.TotalSize, last.CompressedSize, algo.Name())
+
+		f, err = os.Open(path)
+		require.NoError(t, err)
+		detected, _, _, err := compression.DetectCompressionFormat(f)
+		require.NoError(t, err)
+		assert.Equal(t, algo.Name(), detected.Name())
+		f.Close()
+
+		reader, err := NewReaderFromFile(nil, path)
+		require.NoError(t, err)
+		defer reader.Close()
+		require.Len(t, reader.Manifest, 1)
+		assert.Equal(t, []string{"example.com/repo:tag"}, reader.Manifest[0].RepoTags)
+		stream, err := reader.openTarComponent(reader.Manifest[0].Layers[0])
+		require.NoError(t, err)
+		contents, err := io.ReadAll(stream)
+		require.NoError(t, err)
+		stream.Close()
+		assert.Equal(t, layer, contents)
+	}
+
+	// Unsupported compression algorithms are reported.
+	_, err := NewWriterWithOptions(io.Discard, WriterOptions{Compression: &compression.Bzip2})
+	assert.Error(t, err)
+
+	// Appending to compressed archives is not supported.
+	_, err = OpenWriterForAppendWithOptions(filepath.Join(t.TempDir(), "archive.tar"), WriterOptions{Compression: &compression.Gzip})
+	assert.Error(t, err)
+}
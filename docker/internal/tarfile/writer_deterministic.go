@@ -0,0 +1,149 @@
+package tarfile
+
+import (
+	"archive/tar"
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/containers/image/v5/internal/tmpdir"
+	"github.com/containers/image/v5/types"
+	"github.com/sirupsen/logrus"
+)
+
+// pendingEntryMemoryLimit is the maximum size of pendingEntries contents kept in memory; larger files are stored in temporary files.
+const pendingEntryMemoryLimit = 1024 * 1024
+
+// pendingEntries holds tar entries until they are written, sorted, with WriterOptions.Deterministic.
+type pendingEntries struct {
+	tempDirParent string // Passed as types.SystemContext.BigFilesTemporaryDir
+	tempDir       string // "" if not created yet
+	entries       []pendingEntry
+}
+
+// pendingEntry is a single entry of pendingEntries.
+type pendingEntry struct {
+	header *tar.Header
+	data   []byte // Contents of a regular file, if file is ""
+	file   string // If not "", a temporary file containing the contents of a regular file
+}
+
+// addHeader records a tar entry without contents, e.g. a link.
+func (p *pendingEntries) addHeader(hdr *tar.Header) {
+	p.entries = append(p.entries, pendingEntry{header: hdr})
+}
+
+// addFile records a regular file with hdr, reading its contents from stream.
+// Reading stream is aborted if ctx is canceled.
+func (p *pendingEntries) addFile(ctx context.Context, hdr *tar.Header, stream io.Reader) error {
+	stream = contextReader{ctx: ctx, reader: stream}
+	if hdr.Size <= pendingEntryMemoryLimit {
+		data, err := io.ReadAll(io.LimitReader(stream, hdr.Size+1))
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", hdr.Name, err)
+		}
+		if int64(len(data)) != hdr.Size {
+			return fmt.Errorf("Size mismatch when copying %s, expected %d, got %d", hdr.Name, hdr.Size, len(data))
+		}
+		p.entries = append(p.entries, pendingEntry{header: hdr, data: data})
+		return nil
+	}
+
+	if p.tempDir == "" {
+		dir, err := tmpdir.MkDirBigFileTemp(&types.SystemContext{BigFilesTemporaryDir: p.tempDirParent}, "docker-tarfile-deterministic")
+		if err != nil {
+			return err
+		}
+		p.tempDir = dir
+	}
+	path := filepath.Join(p.tempDir, strconv.Itoa(len(p.entries)))
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	size, err := io.Copy(f, stream)
+	if err2 := f.Close(); err2 != nil && err == nil {
+		err = err2
+	}
+	if err == nil && size != hdr.Size {
+		err = fmt.Errorf("Size mismatch when copying %s, expected %d, got %d", hdr.Name, hdr.Size, size)
+	}
+	if err != nil {
+		if err2 := os.Remove(path); err2 != nil {
+			logrus.Debugf("Error removing temporary file %q: %v", path, err2)
+		}
+		return fmt.Errorf("staging %s: %w", hdr.Name, err)
+	}
+	p.entries = append(p.entries, pendingEntry{header: hdr, file: path})
+	return nil
+}
+
+// cleanup removes all temporary files used by p.
+func (p *pendingEntries) cleanup() {
+	p.entries = nil
+	if p.tempDir != "" {
+		if err := os.RemoveAll(p.tempDir); err != nil {
+			logrus.Debugf("Error removing temporary directory %q: %v", p.tempDir, err)
+		}
+		p.tempDir = ""
+	}
+}
+
+// writePendingEntriesLocked writes all of w.pending into the tar stream, sorted by path, with normalized headers.
+// Hard links are written after all other entries, so that their targets are always written first.
+// The caller must have locked the Writer.
+func (w *Writer) writePendingEntriesLocked(ctx context.Context) error {
+	entries := slices.Clone(w.pending.entries)
+	slices.SortFunc(entries, func(a, b pendingEntry) int {
+		aLink, bLink := a.header.Typeflag == tar.TypeLink, b.header.Typeflag == tar.TypeLink
+		if aLink != bLink {
+			if aLink {
+				return 1
+			}
+			return -1
+		}
+		return cmp.Compare(a.header.Name, b.header.Name)
+	})
+	for _, e := range entries {
+		hdr := e.header
+		hdr.Uid, hdr.Gid = 0, 0
+		hdr.Uname, hdr.Gname = "", ""
+		hdr.PAXRecords = nil
+		hdr.AccessTime, hdr.ChangeTime = time.Time{}, time.Time{}
+
+		if hdr.Typeflag != tar.TypeReg {
+			if err := w.writeHeaderLocked(hdr); err != nil {
+				return err
+			}
+			continue
+		}
+		if e.file == "" {
+			if err := w.writeFileEntryLocked(ctx, hdr, bytes.NewReader(e.data)); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := w.writePendingFileLocked(ctx, hdr, e.file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writePendingFileLocked writes a regular file with hdr, with contents in a temporary file at path, into the tar stream.
+// The caller must have locked the Writer.
+func (w *Writer) writePendingFileLocked(ctx context.Context, hdr *tar.Header, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return w.writeFileEntryLocked(ctx, hdr, f)
+}
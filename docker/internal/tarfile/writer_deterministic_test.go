@@ -0,0 +1,102 @@
+package tarfile
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/pkg/blobinfocache/memory"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterDeterministic(t *testing.T) {
+	ctx := context.Background()
+	cache := memory.New()
+	layers := [][]byte{[]byte("small layer"), bytes.Repeat([]byte("large layer"), pendingEntryMemoryLimit/5)}
+	config := `{"rootfs":{"type":"layers","diff_ids":["` + digest.FromBytes(layers[0]).String() + `","` + digest.FromBytes(layers[1]).String() + `"]}}`
+	ref, err := reference.ParseNormalizedNamed("example.com/repo:tag")
+	require.NoError(t, err)
+	tagged, ok := ref.(reference.NamedTagged)
+	require.True(t, ok)
+
+	// createArchive returns an archive containing the image, with layers written in layerOrder.
+	createArchive := func(options WriterOptions, layerOrder []int) []byte {
+		archive := bytes.Buffer{}
+		writer, err := NewWriterWithOptions(&archive, options)
+		require.NoError(t, err)
+		dest := NewDestination(nil, writer, "transport name", tagged, nil)
+		for _, i := range layerOrder {
+			_, err := dest.PutBlob(ctx, bytes.NewReader(layers[i]), types.BlobInfo{Digest: digest.FromBytes(layers[i]), Size: int64(len(layers[i]))}, cache, false)
+			require.NoError(t, err)
+		}
+		configInfo, err := dest.PutBlob(ctx, strings.NewReader(config), types.BlobInfo{Size: -1}, cache, true)
+		require.NoError(t, err)
+		layerDescriptors := []manifest.Schema2Descriptor{}
+		for _, layer := range layers {
+			layerDescriptors = append(layerDescriptors, manifest.Schema2Descriptor{
+				MediaType: manifest.DockerV2Schema2LayerMediaType,
+				Size:      int64(len(layer)),
+				Digest:    digest.FromBytes(layer),
+			})
+		}
+		manifestBlob, err := manifest.Schema2FromComponents(manifest.Schema2Descriptor{
+			MediaType: manifest.DockerV2Schema2ConfigMediaType,
+			Size:      configInfo.Size,
+			Digest:    configInfo.Digest,
+		}, layerDescriptors).Serialize()
+		require.NoError(t, err)
+		err = dest.PutManifest(ctx, manifestBlob, nil)
+		require.NoError(t, err)
+		err = writer.Close()
+		require.NoError(t, err)
+		return archive.Bytes()
+	}
+
+	// Without Deterministic, the archive depends on the order of layers.
+	assert.NotEqual(t, createArchive(WriterOptions{}, []int{0, 1}), createArchive(WriterOptions{}, []int{1, 0}))
+
+	for _, format := range []ArchiveFormat{FormatDockerSave, FormatDockerSaveAndOCILayout} {
+		tempDir := t.TempDir()
+		options := WriterOptions{Format: format, Deterministic: true, BigFilesTemporaryDir: tempDir}
+		archive := createArchive(options, []int{0, 1})
+		assert.Equal(t, archive, createArchive(options, []int{1, 0}), format)
+		// Temporary files have been removed.
+		entries, err := os.ReadDir(tempDir)
+		require.NoError(t, err)
+		assert.Empty(t, entries, format)
+
+		// Entries are sorted, and hard links follow their targets.
+		names := []string{}
+		seenLink := false
+		tr := tar.NewReader(bytes.NewReader(archive))
+		for {
+			hdr, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			require.NoError(t, err, format)
+			if hdr.Typeflag == tar.TypeLink {
+				seenLink = true
+				continue
+			}
+			assert.False(t, seenLink, "%v: %s after a hard link", format, hdr.Name)
+			names = append(names, hdr.Name)
+		}
+		assert.IsIncreasing(t, names, format)
+		// The result can be read.
+		reader, err := NewReaderFromStream(nil, bytes.NewReader(archive))
+		require.NoError(t, err, format)
+		err = reader.Close()
+		require.NoError(t, err, format)
+	}
+}
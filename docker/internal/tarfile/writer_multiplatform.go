@@ -0,0 +1,70 @@
+package tarfile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/manifest"
+	"github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// acceptsManifestLists returns true if w can store manifest lists, with WriterOptions.MultiPlatform.
+func (w *Writer) acceptsManifestLists() bool {
+	return w.options.MultiPlatform && w.writesOCILayout()
+}
+
+// ensureInstanceManifestLocked ensures that the archive contains an image with manifestBlob (with manifestDigest),
+// described by (configDescriptor, layerDescriptors), as an instance of a manifest list.
+// diffIDs contains the uncompressed digests of the layers in layerDescriptors; configBytes is the image config.
+// The image is not tagged; tags refer to the manifest list, see ensureManifestListLocked.
+// The caller must have locked the Writer.
+func (w *Writer) ensureInstanceManifestLocked(ctx context.Context, manifestDigest digest.Digest, manifestBlob []byte,
+	configDescriptor manifest.Schema2Descriptor, layerDescriptors []manifest.Schema2Descriptor, diffIDs []digest.Digest, configBytes []byte) error {
+	if !w.acceptsManifestLists() {
+		return errors.New("Internal error: writing a manifest list instance without MultiPlatform and an OCI layout")
+	}
+	if w.writesLegacyMetadata() {
+		if err := w.writeLegacyMetadataLocked(ctx, layerDescriptors, diffIDs, configBytes, nil); err != nil {
+			return err
+		}
+	}
+	if w.writesManifestJSON() {
+		if err := w.ensureManifestItemLocked(layerDescriptors, diffIDs, configDescriptor.Digest, nil); err != nil {
+			return err
+		}
+	}
+	// The instance manifest is stored as is, not using ensureOCIManifestLocked, so that it matches the digest in the list.
+	return w.ensureManifestBlobLocked(ctx, manifestDigest, manifestBlob)
+}
+
+// ensureManifestListLocked ensures that the OCI layout contains listBlob, a manifest list with mimeType,
+// listed in the index with repoTags. All instances of the list must have already been written
+// using ensureInstanceManifestLocked.
+// The caller must have locked the Writer.
+func (w *Writer) ensureManifestListLocked(ctx context.Context, listBlob []byte, mimeType string, repoTags []reference.NamedTagged) error {
+	if !w.acceptsManifestLists() {
+		return errors.New("Internal error: writing a manifest list without MultiPlatform and an OCI layout")
+	}
+	list, err := manifest.ListFromBlob(listBlob, mimeType)
+	if err != nil {
+		return fmt.Errorf("parsing manifest list: %w", err)
+	}
+	for _, instance := range list.Instances() {
+		if !w.ociManifests.Contains(instance) {
+			return fmt.Errorf("manifest list refers to instance %s which was not written to the archive (consider copying all instances, or stripping the rest from the list)", instance.String())
+		}
+	}
+	desc := imgspecv1.Descriptor{
+		MediaType: mimeType,
+		Digest:    digest.FromBytes(listBlob),
+		Size:      int64(len(listBlob)),
+	}
+	if err := w.ensureManifestBlobLocked(ctx, desc.Digest, listBlob); err != nil {
+		return err
+	}
+	w.addOCIIndexEntriesLocked(desc, repoTags)
+	return nil
+}
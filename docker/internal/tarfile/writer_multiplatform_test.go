@@ -0,0 +1,144 @@
+package tarfile
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/pkg/blobinfocache/memory"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterMultiPlatform(t *testing.T) {
+	ctx := context.Background()
+	cache := memory.New()
+	ref, err := reference.ParseNormalizedNamed("example.com/repo:tag")
+	require.NoError(t, err)
+	tagged, ok := ref.(reference.NamedTagged)
+	require.True(t, ok)
+
+	// putInstance writes an image for arch to dest, and returns its manifest and its descriptor in a list.
+	putInstance := func(dest *Destination, arch string) ([]byte, imgspecv1.Descriptor) {
+		layer := []byte("layer for " + arch)
+		layerDigest := digest.FromBytes(layer)
+		_, err := dest.PutBlob(ctx, bytes.NewReader(layer), types.BlobInfo{Digest: layerDigest, Size: int64(len(layer))}, cache, false)
+		require.NoError(t, err)
+		config := `{"architecture":"` + arch + `","os":"linux","rootfs":{"type":"layers","diff_ids":["` + layerDigest.String() + `"]}}`
+		configInfo, err := dest.PutBlob(ctx, strings.NewReader(config), types.BlobInfo{Size: -1}, cache, true)
+		require.NoError(t, err)
+		manifestBlob, err := manifest.OCI1FromComponents(imgspecv1.Descriptor{
+			MediaType: imgspecv1.MediaTypeImageConfig,
+			Size:      configInfo.Size,
+			Digest:    configInfo.Digest,
+		}, []imgspecv1.Descriptor{{
+			MediaType: imgspecv1.MediaTypeImageLayer,
+			Size:      int64(len(layer)),
+			Digest:    layerDigest,
+		}}).Serialize()
+		require.NoError(t, err)
+		manifestDigest := digest.FromBytes(manifestBlob)
+		err = dest.PutManifest(ctx, manifestBlob, &manifestDigest)
+		require.NoError(t, err)
+		return manifestBlob, imgspecv1.Descriptor{
+			MediaType: imgspecv1.MediaTypeImageManifest,
+			Digest:    manifestDigest,
+			Size:      int64(len(manifestBlob)),
+			Platform:  &imgspecv1.Platform{OS: "linux", Architecture: arch},
+		}
+	}
+
+	archivePath := filepath.Join(t.TempDir(), "archive.tar")
+	f, err := os.Create(archivePath)
+	require.NoError(t, err)
+	defer f.Close()
+	writer, err := NewWriterWithOptions(f, WriterOptions{Format: FormatDockerSaveAndOCILayout, MultiPlatform: true})
+	require.NoError(t, err)
+	dest := NewDestination(nil, writer, "transport name", tagged, nil)
+	assert.Contains(t, dest.SupportedManifestMIMETypes(), imgspecv1.MediaTypeImageIndex)
+	amd64Manifest, amd64Desc := putInstance(dest, "amd64")
+	_, arm64Desc := putInstance(dest, "arm64")
+	listBlob, err := manifest.OCI1IndexFromComponents([]imgspecv1.Descriptor{amd64Desc, arm64Desc}, nil).Serialize()
+	require.NoError(t, err)
+	// A list referring to instances which were not written is rejected.
+	missingListBlob, err := manifest.OCI1IndexFromComponents([]imgspecv1.Descriptor{amd64Desc, {
+		MediaType: imgspecv1.MediaTypeImageManifest,
+		Digest:    digest.FromString("missing"),
+		Size:      1,
+	}}, nil).Serialize()
+	require.NoError(t, err)
+	err = dest.PutManifest(ctx, missingListBlob, nil)
+	assert.Error(t, err)
+	err = dest.PutManifest(ctx, listBlob, nil)
+	require.NoError(t, err)
+	err = writer.Close()
+	require.NoError(t, err)
+
+	reader, err := NewReaderFromFile(nil, archivePath)
+	require.NoError(t, err)
+	// The per-platform images are listed in manifest.json without tags; the tag refers to the list.
+	require.Len(t, reader.Manifest, 2)
+	for _, item := range reader.Manifest {
+		assert.Empty(t, item.RepoTags)
+	}
+	assert.Equal(t, []ManifestListItem{{
+		MediaType: imgspecv1.MediaTypeImageIndex,
+		Digest:    digest.FromBytes(listBlob),
+		RepoTags:  []string{"example.com/repo:tag"},
+	}}, reader.ManifestLists)
+
+	src := NewSource(reader, true, "transport name", tagged, -1)
+	defer src.Close()
+	m, mimeType, err := src.GetManifest(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, listBlob, m)
+	assert.Equal(t, imgspecv1.MediaTypeImageIndex, mimeType)
+	m, mimeType, err = src.GetManifest(ctx, &amd64Desc.Digest)
+	require.NoError(t, err)
+	assert.Equal(t, amd64Manifest, m)
+	assert.Equal(t, imgspecv1.MediaTypeImageManifest, mimeType)
+	layer := []byte("layer for amd64")
+	stream, size, err := src.GetBlob(ctx, types.BlobInfo{Digest: digest.FromBytes(layer), Size: -1}, cache)
+	require.NoError(t, err)
+	defer stream.Close()
+	contents, err := io.ReadAll(stream)
+	require.NoError(t, err)
+	assert.Equal(t, layer, contents)
+	assert.Equal(t, int64(len(layer)), size)
+	_, _, err = src.GetBlob(ctx, types.BlobInfo{Digest: digest.FromString("unknown"), Size: -1}, cache)
+	assert.Error(t, err)
+
+	// Removing the tag of the list removes the per-platform images as well.
+	prunedPath := filepath.Join(t.TempDir(), "pruned.tar")
+	pruned, err := os.Create(prunedPath)
+	require.NoError(t, err)
+	defer pruned.Close()
+	err = reader.WriteWithoutImages(pruned, []reference.NamedTagged{tagged}, nil)
+	require.NoError(t, err)
+	prunedReader, err := NewReaderFromFile(nil, prunedPath)
+	require.NoError(t, err)
+	defer prunedReader.Close()
+	assert.Empty(t, prunedReader.Manifest)
+	assert.Empty(t, prunedReader.ManifestLists)
+	_, err = prunedReader.openTarComponent(digest.FromBytes(layer).Encoded() + ".tar")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// Without MultiPlatform, manifest lists are rejected.
+	writer, err = NewWriterWithOptions(io.Discard, WriterOptions{Format: FormatDockerSaveAndOCILayout})
+	require.NoError(t, err)
+	dest = NewDestination(nil, writer, "transport name", tagged, nil)
+	assert.NotContains(t, dest.SupportedManifestMIMETypes(), imgspecv1.MediaTypeImageIndex)
+	err = dest.PutManifest(ctx, listBlob, nil)
+	assert.Error(t, err)
+	err = dest.PutManifest(ctx, amd64Manifest, &amd64Desc.Digest)
+	assert.Error(t, err)
+}
@@ -0,0 +1,68 @@
+package tarfile
+
+import (
+	"fmt"
+
+	"github.com/containers/image/v5/types"
+)
+
+// WriterOptionsFromSystemContext returns WriterOptions for a (docker save)-formatted archive, as configured
+// by the DockerArchive* fields of sys which describe the archive format.
+// Options which depend on how the archive is stored (DockerArchiveAppend, DockerArchiveEntryIndex, ArchiveSeekableZstd)
+// and progress reporting are left to the caller.
+// Combinations of options which can not be used together are rejected by NewWriterWithOptions.
+func WriterOptionsFromSystemContext(sys *types.SystemContext) (WriterOptions, error) {
+	if sys == nil {
+		return WriterOptions{}, nil
+	}
+
+	var format ArchiveFormat
+	switch sys.DockerArchiveFormat {
+	case types.DockerArchiveFormatDockerSave:
+		format = FormatDockerSave
+	case types.DockerArchiveFormatDockerSaveAndOCILayout:
+		format = FormatDockerSaveAndOCILayout
+	case types.DockerArchiveFormatOCILayout:
+		format = FormatOCILayout
+	default:
+		return WriterOptions{}, fmt.Errorf("unknown DockerArchiveFormat %d", sys.DockerArchiveFormat)
+	}
+
+	var legacyMetadata LegacyMetadataMode
+	switch sys.DockerArchiveLegacyMetadata {
+	case types.DockerArchiveLegacyMetadataInclude:
+		legacyMetadata = LegacyMetadataInclude
+	case types.DockerArchiveLegacyMetadataOmit:
+		legacyMetadata = LegacyMetadataOmit
+	case types.DockerArchiveLegacyMetadataOnly:
+		legacyMetadata = LegacyMetadataOnly
+	default:
+		return WriterOptions{}, fmt.Errorf("unknown DockerArchiveLegacyMetadata %d", sys.DockerArchiveLegacyMetadata)
+	}
+
+	var layerCompression LayerCompressionMode
+	switch sys.DockerArchiveLayerCompression {
+	case types.DockerArchiveLayerCompressionNone:
+		layerCompression = LayerCompressionNone
+	case types.DockerArchiveLayerCompressionPreserve:
+		layerCompression = LayerCompressionPreserve
+	case types.DockerArchiveLayerCompressionGzip:
+		layerCompression = LayerCompressionGzip
+	default:
+		return WriterOptions{}, fmt.Errorf("unknown DockerArchiveLayerCompression %d", sys.DockerArchiveLayerCompression)
+	}
+
+	return WriterOptions{
+		Format:               format,
+		LayerCompression:     layerCompression,
+		LegacyMetadata:       legacyMetadata,
+		DigestPathLinks:      sys.DockerArchiveDigestPathLinks,
+		StageLayers:          sys.DockerArchiveStageLayers,
+		Deterministic:        sys.DockerArchiveDeterministic,
+		MultiPlatform:        sys.DockerArchiveMultiPlatform,
+		ForeignLayerSources:  sys.DockerArchiveForeignLayerSources,
+		Compression:          sys.DockerArchiveCompression,
+		CompressionLevel:     sys.DockerArchiveCompressionLevel,
+		BigFilesTemporaryDir: sys.BigFilesTemporaryDir,
+	}, nil
+}
@@ -0,0 +1,75 @@
+package tarfile
+
+import (
+	"testing"
+
+	"github.com/containers/image/v5/pkg/compression"
+	"github.com/containers/image/v5/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterOptionsFromSystemContext(t *testing.T) {
+	level := 5
+	for _, c := range []struct {
+		sys      *types.SystemContext
+		expected WriterOptions
+	}{
+		{nil, WriterOptions{}},
+		{&types.SystemContext{}, WriterOptions{}},
+		{
+			&types.SystemContext{
+				DockerArchiveLayerCompression: types.DockerArchiveLayerCompressionPreserve,
+				DockerArchiveFormat:           types.DockerArchiveFormatDockerSaveAndOCILayout,
+			},
+			WriterOptions{LayerCompression: LayerCompressionPreserve, Format: FormatDockerSaveAndOCILayout},
+		},
+		{
+			&types.SystemContext{
+				DockerArchiveLayerCompression: types.DockerArchiveLayerCompressionGzip,
+				DockerArchiveFormat:           types.DockerArchiveFormatOCILayout,
+				DockerArchiveMultiPlatform:    true,
+			},
+			WriterOptions{LayerCompression: LayerCompressionGzip, Format: FormatOCILayout, MultiPlatform: true},
+		},
+		{
+			&types.SystemContext{DockerArchiveLegacyMetadata: types.DockerArchiveLegacyMetadataOmit},
+			WriterOptions{LegacyMetadata: LegacyMetadataOmit},
+		},
+		{
+			&types.SystemContext{DockerArchiveLegacyMetadata: types.DockerArchiveLegacyMetadataOnly, DockerArchiveDigestPathLinks: true},
+			WriterOptions{LegacyMetadata: LegacyMetadataOnly, DigestPathLinks: true},
+		},
+		{
+			&types.SystemContext{
+				DockerArchiveStageLayers:         true,
+				DockerArchiveDeterministic:       true,
+				DockerArchiveForeignLayerSources: true,
+				DockerArchiveCompression:         &compression.Zstd,
+				DockerArchiveCompressionLevel:    &level,
+				BigFilesTemporaryDir:             "/var/tmp/big",
+			},
+			WriterOptions{
+				StageLayers:          true,
+				Deterministic:        true,
+				ForeignLayerSources:  true,
+				Compression:          &compression.Zstd,
+				CompressionLevel:     &level,
+				BigFilesTemporaryDir: "/var/tmp/big",
+			},
+		},
+	} {
+		res, err := WriterOptionsFromSystemContext(c.sys)
+		require.NoError(t, err)
+		assert.Equal(t, c.expected, res)
+	}
+
+	for _, sys := range []*types.SystemContext{
+		{DockerArchiveFormat: types.DockerArchiveFormat(-1)},
+		{DockerArchiveLegacyMetadata: types.DockerArchiveLegacyMetadata(-1)},
+		{DockerArchiveLayerCompression: types.DockerArchiveLayerCompression(-1)},
+	} {
+		_, err := WriterOptionsFromSystemContext(sys)
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,66 @@
+package tarfile
+
+import (
+	"archive/tar"
+)
+
+// WriterProgress describes progress of writing an archive, as reported to WriterOptions.Progress.
+//
+// The callback is called after the header of every entry is written (with EntryOffset 0), as contents of regular files
+// are written, and finally once with Path == "" after the archive has been completed.
+// With WriterOptions.Deterministic, entries are only written when the Writer is closed.
+type WriterProgress struct {
+	Path        string // The path of the entry being written, or "" if the archive has been completed
+	EntryOffset int64  // The number of bytes of the entry contents written so far
+	EntrySize   int64  // The size of the entry contents; 0 for links
+	// The number of bytes of the tar stream written by this Writer so far, including tar headers and padding.
+	// When adding images to an existing archive, the pre-existing contents are not included.
+	TotalSize int64
+	// With WriterOptions.Compression, the number of compressed bytes written to the underlying io.Writer so far
+	// (this can lag behind TotalSize, because the compressor buffers data); 0 otherwise.
+	CompressedSize int64
+}
+
+// reportProgressLocked calls w.options.Progress, if any, with the current state.
+// The caller must have locked the Writer.
+func (w *Writer) reportProgressLocked(path string, entryOffset, entrySize int64) {
+	if w.options.Progress == nil {
+		return
+	}
+	w.options.Progress(WriterProgress{
+		Path:           path,
+		EntryOffset:    entryOffset,
+		EntrySize:      entrySize,
+		TotalSize:      w.written,
+		CompressedSize: w.compressedSize(),
+	})
+}
+
+// countingWriter is the io.Writer used by Writer.tar; it writes to Writer.writer, and counts the written bytes.
+// Only used with the Writer locked.
+type countingWriter struct {
+	writer *Writer
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.writer.writer.Write(p)
+	c.writer.written += int64(n)
+	return n, err
+}
+
+// entryProgressWriter writes contents of the entry described by hdr to writer.tar, and reports progress.
+// Only used with the Writer locked.
+type entryProgressWriter struct {
+	writer *Writer
+	hdr    *tar.Header
+	offset int64
+}
+
+func (e *entryProgressWriter) Write(p []byte) (int, error) {
+	n, err := e.writer.tar.Write(p)
+	e.offset += int64(n)
+	if n > 0 {
+		e.writer.reportProgressLocked(e.hdr.Name, e.offset, e.hdr.Size)
+	}
+	return n, err
+}
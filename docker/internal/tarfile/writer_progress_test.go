@@ -0,0 +1,77 @@
+package tarfile
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/pkg/blobinfocache/memory"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterProgress(t *testing.T) {
+	cache := memory.New()
+	ctx := context.Background()
+	layer := bytes.Repeat([]byte("layer data"), 100000)
+	layerDigest := digest.FromBytes(layer)
+	config := `{"rootfs":{"type":"layers","diff_ids":["` + layerDigest.String() + `"]}}`
+
+	archive := bytes.Buffer{}
+	reports := []WriterProgress{}
+	writer, err := NewWriterWithOptions(&archive, WriterOptions{
+		Progress: func(p WriterProgress) {
+			reports = append(reports, p)
+		},
+	})
+	require.NoError(t, err)
+	dest := NewDestination(nil, writer, "transport name", nil, nil)
+	configInfo, err := dest.PutBlob(ctx, strings.NewReader(config), types.BlobInfo{Size: -1}, cache, true)
+	require.NoError(t, err)
+	_, err = dest.PutBlob(ctx, bytes.NewReader(layer), types.BlobInfo{Digest: layerDigest, Size: int64(len(layer))}, cache, false)
+	require.NoError(t, err)
+	manifestBlob, err := manifest.Schema2FromComponents(
+		manifest.Schema2Descriptor{
+			MediaType: manifest.DockerV2Schema2ConfigMediaType,
+			Size:      configInfo.Size,
+			Digest:    configInfo.Digest,
+		}, []manifest.Schema2Descriptor{{
+			MediaType: manifest.DockerV2Schema2LayerMediaType,
+			Size:      int64(len(layer)),
+			Digest:    layerDigest,
+		}}).Serialize()
+	require.NoError(t, err)
+	err = dest.PutManifest(ctx, manifestBlob, nil)
+	require.NoError(t, err)
+	err = writer.Close()
+	require.NoError(t, err)
+
+	require.NotEmpty(t, reports)
+	layerPath, err := writer.physicalLayerPath(layerDigest)
+	require.NoError(t, err)
+	layerReports := 0
+	lastTotal := int64(0)
+	for _, r := range reports {
+		assert.GreaterOrEqual(t, r.TotalSize, lastTotal)
+		lastTotal = r.TotalSize
+		assert.LessOrEqual(t, r.EntryOffset, r.EntrySize)
+		if r.Path == layerPath {
+			assert.Equal(t, int64(len(layer)), r.EntrySize)
+			layerReports++
+		}
+	}
+	assert.Greater(t, layerReports, 2) // The header, and at least two chunks of contents
+	last := reports[len(reports)-1]
+	assert.Equal(t, WriterProgress{Path: "", TotalSize: int64(archive.Len())}, last)
+	var lastLayerReport WriterProgress
+	for _, r := range reports {
+		if r.Path == layerPath {
+			lastLayerReport = r
+		}
+	}
+	assert.Equal(t, int64(len(layer)), lastLayerReport.EntryOffset)
+}
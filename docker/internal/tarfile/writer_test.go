@@ -0,0 +1,360 @@
+package tarfile
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/pkg/blobinfocache/memory"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterDigestPathLinks(t *testing.T) {
+	cache := memory.New()
+	ctx := context.Background()
+	layer := []byte("layer data")
+	layerDigest := digest.FromBytes(layer)
+	config := `{"rootfs":{"type":"layers","diff_ids":["` + layerDigest.String() + `"]}}`
+
+	for _, links := range []bool{false, true} {
+		archive := bytes.Buffer{}
+		writer, err := NewWriterWithOptions(&archive, WriterOptions{DigestPathLinks: links})
+		require.NoError(t, err)
+		dest := NewDestination(nil, writer, "transport name", nil, nil)
+		configInfo, err := dest.PutBlob(ctx, strings.NewReader(config), types.BlobInfo{Size: -1}, cache, true)
+		require.NoError(t, err)
+		_, err = dest.PutBlob(ctx, bytes.NewReader(layer), types.BlobInfo{Digest: layerDigest, Size: int64(len(layer))}, cache, false)
+		require.NoError(t, err)
+		manifestBlob, err := manifest.Schema2FromComponents(
+			manifest.Schema2Descriptor{
+				MediaType: manifest.DockerV2Schema2ConfigMediaType,
+				Size:      configInfo.Size,
+				Digest:    configInfo.Digest,
+			}, []manifest.Schema2Descriptor{{
+				MediaType: manifest.DockerV2Schema2LayerMediaType,
+				Size:      int64(len(layer)),
+				Digest:    layerDigest,
+			}}).Serialize()
+		require.NoError(t, err)
+		err = dest.PutManifest(ctx, manifestBlob, nil)
+		require.NoError(t, err)
+		err = writer.Close()
+		require.NoError(t, err)
+
+		hardLinks := map[string]string{}
+		tr := tar.NewReader(bytes.NewReader(archive.Bytes()))
+		for {
+			hdr, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			require.NoError(t, err)
+			if hdr.Typeflag == tar.TypeLink {
+				hardLinks[hdr.Name] = hdr.Linkname
+			}
+		}
+		if !links {
+			assert.Empty(t, hardLinks)
+			continue
+		}
+		layerPath, err := writer.physicalLayerPath(layerDigest)
+		require.NoError(t, err)
+		configPath, err := writer.configPath(configInfo.Digest)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{
+			filepath.Join("blobs", "sha256", layerDigest.Encoded()):       layerPath,
+			filepath.Join("blobs", "sha256", configInfo.Digest.Encoded()): configPath,
+		}, hardLinks)
+
+		// The archive is still readable by us.
+		reader, err := NewReaderFromStream(nil, bytes.NewReader(archive.Bytes()))
+		require.NoError(t, err)
+		src := NewSource(reader, true, "transport name", nil, -1)
+		stream, _, err := src.GetBlob(ctx, types.BlobInfo{Digest: layerDigest, Size: -1}, cache)
+		require.NoError(t, err)
+		data, err := io.ReadAll(stream)
+		require.NoError(t, err)
+		stream.Close()
+		assert.Equal(t, layer, data)
+		err = src.Close()
+		require.NoError(t, err)
+	}
+}
+
+// cancelingReader is an io.Reader which returns data, and cancels a context after the first read.
+type cancelingReader struct {
+	cancel context.CancelFunc
+}
+
+func (r cancelingReader) Read(p []byte) (int, error) {
+	r.cancel()
+	return len(p), nil
+}
+
+func TestWriterCancellation(t *testing.T) {
+	cache := memory.New()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	layerDigest := digest.FromString("not actually verified")
+
+	archive := bytes.Buffer{}
+	writer := NewWriter(&archive)
+	dest := NewDestination(nil, writer, "transport name", nil, nil)
+	_, err := dest.PutBlob(ctx, cancelingReader{cancel: cancel}, types.BlobInfo{Digest: layerDigest, Size: 1 << 40}, cache, false)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, archive.Len(), 1<<20)
+
+	// The archive is incomplete, so nothing more can be written.
+	layer := []byte("layer data")
+	_, err = dest.PutBlob(context.Background(), bytes.NewReader(layer), types.BlobInfo{Digest: digest.FromBytes(layer), Size: int64(len(layer))}, cache, false)
+	assert.ErrorIs(t, err, context.Canceled)
+	err = writer.Close()
+	assert.ErrorIs(t, err, context.Canceled)
+	err = writer.Close()
+	assert.Error(t, err)
+}
+
+func TestWriterOCILayout(t *testing.T) {
+	cache := memory.New()
+	ctx := context.Background()
+	layer := []byte("layer data")
+	layerDigest := digest.FromBytes(layer)
+	config := `{"rootfs":{"type":"layers","diff_ids":["` + layerDigest.String() + `"]}}`
+	ref, err := reference.ParseNormalizedNamed("example.com/repo:tag")
+	require.NoError(t, err)
+	tagged, ok := ref.(reference.NamedTagged)
+	require.True(t, ok)
+
+	for _, format := range []ArchiveFormat{FormatDockerSaveAndOCILayout, FormatOCILayout} {
+		archive := bytes.Buffer{}
+		writer, err := NewWriterWithOptions(&archive, WriterOptions{Format: format})
+		require.NoError(t, err)
+		dest := NewDestination(nil, writer, "transport name", tagged, nil)
+		configInfo, err := dest.PutBlob(ctx, strings.NewReader(config), types.BlobInfo{Size: -1}, cache, true)
+		require.NoError(t, err)
+		_, err = dest.PutBlob(ctx, bytes.NewReader(layer), types.BlobInfo{Digest: layerDigest, Size: int64(len(layer))}, cache, false)
+		require.NoError(t, err)
+		manifestBlob, err := manifest.Schema2FromComponents(
+			manifest.Schema2Descriptor{
+				MediaType: manifest.DockerV2Schema2ConfigMediaType,
+				Size:      configInfo.Size,
+				Digest:    configInfo.Digest,
+			}, []manifest.Schema2Descriptor{{
+				MediaType: manifest.DockerV2Schema2LayerMediaType,
+				Size:      int64(len(layer)),
+				Digest:    layerDigest,
+			}}).Serialize()
+		require.NoError(t, err)
+		err = dest.PutManifest(ctx, manifestBlob, nil)
+		require.NoError(t, err)
+		err = writer.Close()
+		require.NoError(t, err)
+
+		// Collect the contents of all files, resolving hard links.
+		files := map[string][]byte{}
+		tr := tar.NewReader(bytes.NewReader(archive.Bytes()))
+		for {
+			hdr, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			require.NoError(t, err)
+			switch hdr.Typeflag {
+			case tar.TypeReg:
+				data, err := io.ReadAll(tr)
+				require.NoError(t, err)
+				files[hdr.Name] = data
+			case tar.TypeLink:
+				data, ok := files[hdr.Linkname]
+				require.True(t, ok, hdr.Linkname)
+				files[hdr.Name] = data
+			}
+		}
+
+		_, hasManifestJSON := files[manifestFileName]
+		assert.Equal(t, format == FormatDockerSaveAndOCILayout, hasManifestJSON, format)
+		assert.JSONEq(t, `{"imageLayoutVersion":"1.0.0"}`, string(files["oci-layout"]), format)
+		var index imgspecv1.Index
+		err = json.Unmarshal(files["index.json"], &index)
+		require.NoError(t, err, format)
+		require.Len(t, index.Manifests, 1, format)
+		desc := index.Manifests[0]
+		assert.Equal(t, imgspecv1.MediaTypeImageManifest, desc.MediaType, format)
+		assert.Equal(t, map[string]string{
+			"io.containerd.image.name":          "example.com/repo:tag",
+			"org.opencontainers.image.ref.name": "tag",
+		}, desc.Annotations, format)
+
+		blob := func(d digest.Digest) []byte {
+			data, ok := files[filepath.Join("blobs", d.Algorithm().String(), d.Encoded())]
+			require.True(t, ok, d.String())
+			assert.Equal(t, d, digest.FromBytes(data))
+			return data
+		}
+		var m imgspecv1.Manifest
+		err = json.Unmarshal(blob(desc.Digest), &m)
+		require.NoError(t, err, format)
+		assert.Equal(t, imgspecv1.MediaTypeImageConfig, m.Config.MediaType, format)
+		assert.Equal(t, []byte(config), blob(m.Config.Digest), format)
+		require.Len(t, m.Layers, 1, format)
+		assert.Equal(t, imgspecv1.MediaTypeImageLayerGzip, m.Layers[0].MediaType, format)
+		assert.Equal(t, layer, blob(m.Layers[0].Digest), format)
+	}
+}
+
+func TestWriterForeignLayerSources(t *testing.T) {
+	cache := memory.New()
+	ctx := context.Background()
+	foreignLayer := []byte("foreign layer")
+	foreignDigest := digest.FromBytes(foreignLayer)
+	layer := []byte("layer data")
+	layerDigest := digest.FromBytes(layer)
+	config := `{"rootfs":{"type":"layers","diff_ids":["` + foreignDigest.String() + `","` + layerDigest.String() + `"]}}`
+	ref, err := reference.ParseNormalizedNamed("example.com/repo:tag")
+	require.NoError(t, err)
+	tagged, ok := ref.(reference.NamedTagged)
+	require.True(t, ok)
+
+	for _, foreignLayerSources := range []bool{false, true} {
+		archive := bytes.Buffer{}
+		writer, err := NewWriterWithOptions(&archive, WriterOptions{ForeignLayerSources: foreignLayerSources})
+		require.NoError(t, err)
+		dest := NewDestination(nil, writer, "transport name", tagged, nil)
+		configInfo, err := dest.PutBlob(ctx, strings.NewReader(config), types.BlobInfo{Size: -1}, cache, true)
+		require.NoError(t, err)
+		for _, l := range [][]byte{foreignLayer, layer} {
+			_, err = dest.PutBlob(ctx, bytes.NewReader(l), types.BlobInfo{Digest: digest.FromBytes(l), Size: int64(len(l))}, cache, false)
+			require.NoError(t, err)
+		}
+		manifestBlob, err := manifest.Schema2FromComponents(
+			manifest.Schema2Descriptor{
+				MediaType: manifest.DockerV2Schema2ConfigMediaType,
+				Size:      configInfo.Size,
+				Digest:    configInfo.Digest,
+			}, []manifest.Schema2Descriptor{{
+				MediaType: manifest.DockerV2Schema2ForeignLayerMediaType,
+				Size:      int64(len(foreignLayer)),
+				Digest:    foreignDigest,
+				URLs:      []string{"https://example.com/foreign-layer"},
+			}, {
+				MediaType: manifest.DockerV2SchemaLayerMediaTypeUncompressed,
+				Size:      int64(len(layer)),
+				Digest:    layerDigest,
+			}}).Serialize()
+		require.NoError(t, err)
+		err = dest.PutManifest(ctx, manifestBlob, nil)
+		require.NoError(t, err)
+		err = writer.Close()
+		require.NoError(t, err)
+
+		reader, err := NewReaderFromStream(nil, &archive)
+		require.NoError(t, err)
+		defer reader.Close()
+		require.Len(t, reader.Manifest, 1)
+		if !foreignLayerSources {
+			assert.Nil(t, reader.Manifest[0].LayerSources)
+			continue
+		}
+		assert.Equal(t, map[digest.Digest]manifest.Schema2Descriptor{
+			foreignDigest: {
+				MediaType: manifest.DockerV2Schema2ForeignLayerMediaType,
+				Size:      int64(len(foreignLayer)),
+				Digest:    foreignDigest,
+				URLs:      []string{"https://example.com/foreign-layer"},
+			},
+		}, reader.Manifest[0].LayerSources)
+	}
+}
+
+func TestWriterLegacyMetadata(t *testing.T) {
+	layer := []byte("layer data")
+
+	for _, mode := range []LegacyMetadataMode{LegacyMetadataInclude, LegacyMetadataOmit, LegacyMetadataOnly} {
+		archive := bytes.Buffer{}
+		writer, err := NewWriterWithOptions(&archive, WriterOptions{LegacyMetadata: mode})
+		require.NoError(t, err)
+		writeTestImage(t, writer, "example.com/repo:tag", "config", layer)
+		err = writer.Close()
+		require.NoError(t, err, mode)
+
+		names := map[string]struct{}{}
+		legacyEntries := 0
+		tr := tar.NewReader(bytes.NewReader(archive.Bytes()))
+		for {
+			hdr, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			require.NoError(t, err, mode)
+			names[hdr.Name] = struct{}{}
+			switch filepath.Base(hdr.Name) {
+			case legacyLayerFileName, legacyConfigFileName, legacyVersionFileName:
+				legacyEntries++
+			}
+		}
+		_, hasManifestJSON := names[manifestFileName]
+		assert.Equal(t, mode != LegacyMetadataOnly, hasManifestJSON, mode)
+		_, hasRepositories := names[legacyRepositoriesFileName]
+		assert.Equal(t, mode != LegacyMetadataOmit, hasRepositories, mode)
+		if mode == LegacyMetadataOmit {
+			assert.Equal(t, 0, legacyEntries, mode)
+		} else {
+			assert.Equal(t, 3, legacyEntries, mode)
+		}
+		// The layer is stored in the same place in all cases.
+		assert.Contains(t, names, digest.FromBytes(layer).Encoded()+".tar", mode)
+
+		if mode != LegacyMetadataOnly {
+			reader, err := NewReaderFromStream(nil, bytes.NewReader(archive.Bytes()))
+			require.NoError(t, err, mode)
+			assert.Len(t, reader.Manifest, 1, mode)
+			err = reader.Close()
+			require.NoError(t, err)
+		}
+	}
+
+	// Appending preserves the mode of the existing archive.
+	for _, mode := range []LegacyMetadataMode{LegacyMetadataInclude, LegacyMetadataOmit} {
+		path := filepath.Join(t.TempDir(), "archive.tar")
+		f, err := os.Create(path)
+		require.NoError(t, err)
+		writer, err := NewWriterWithOptions(f, WriterOptions{LegacyMetadata: mode})
+		require.NoError(t, err)
+		writeTestImage(t, writer, "example.com/first:tag", "first", layer)
+		err = writer.Close()
+		require.NoError(t, err)
+		err = f.Close()
+		require.NoError(t, err)
+
+		writer, err = OpenWriterForAppendWithOptions(path, WriterOptions{LegacyMetadata: LegacyMetadataOnly})
+		require.NoError(t, err, mode)
+		assert.Equal(t, mode, writer.options.LegacyMetadata)
+		err = writer.Close()
+		require.NoError(t, err, mode)
+	}
+
+	for _, options := range []WriterOptions{
+		{LegacyMetadata: LegacyMetadataOnly, Format: FormatDockerSaveAndOCILayout},
+		{LegacyMetadata: LegacyMetadataOnly, Format: FormatOCILayout},
+		{LegacyMetadata: LegacyMetadataMode(-1)},
+		{Format: ArchiveFormat(-1)},
+		{LayerCompression: LayerCompressionMode(-1)},
+		{MultiPlatform: true},
+	} {
+		_, err := NewWriterWithOptions(io.Discard, options)
+		assert.Error(t, err, options)
+	}
+}
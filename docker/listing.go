@@ -0,0 +1,229 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/internal/set"
+	"github.com/containers/image/v5/pkg/docker/config"
+	"github.com/containers/image/v5/types"
+	"github.com/docker/distribution/registry/api/errcode"
+	"github.com/sirupsen/logrus"
+)
+
+// catalogScope is the authorization scope required to list repositories of a registry.
+var catalogScope = authScope{resourceType: "registry", remoteName: "catalog", actions: "*"}
+
+// ListOptions configures ListRepositoryTags and ListRepositories.
+type ListOptions struct {
+	// PageSize, if not 0, asks the registry to return at most this many items per request.
+	// Registries may ignore it, or use a smaller limit.
+	PageSize int
+	// Last, if set, asks the registry to only list items after Last, e.g. to resume listing after the last item seen previously.
+	// Registries typically return items in lexical order; the client relies on the registry for the order, and does not
+	// filter the items itself, so registries which ignore Last return all items.
+	Last string
+	// Filter, if set, is called for each item, and only items for which it returns true are listed.
+	Filter func(item string) bool
+}
+
+// ListRepositoryTags returns an iterator over tags in the repository of ref, fetching them from the registry one page
+// at a time, as the iteration proceeds. The tag provided inside the ImageReference will be ignored.
+// If fetching a page fails, the iterator yields the error, and ends.
+func ListRepositoryTags(ctx context.Context, sys *types.SystemContext, ref types.ImageReference, options ListOptions) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		dr, ok := ref.(dockerReference)
+		if !ok {
+			yield("", errors.New("ref must be a dockerReference"))
+			return
+		}
+		registryConfig, err := loadRegistryConfiguration(sys)
+		if err != nil {
+			yield("", err)
+			return
+		}
+		client, err := newDockerClientFromRef(sys, dr, registryConfig, false, "pull")
+		if err != nil {
+			yield("", fmt.Errorf("failed to create client: %w", err))
+			return
+		}
+		defer client.Close()
+
+		client.listRepositoryTags(ctx, dr, options)(yield)
+	}
+}
+
+// listRepositoryTags returns an iterator over tags in the repository of dr, as ListRepositoryTags.
+func (c *dockerClient) listRepositoryTags(ctx context.Context, dr dockerReference, options ListOptions) iter.Seq2[string, error] {
+	return listPaginated(fmt.Sprintf(tagsPath, reference.Path(dr.ref)), options, func(path string) ([]string, string, error) {
+		return c.getTagsPage(ctx, dr, path)
+	})
+}
+
+// ListRepositories returns an iterator over repositories in registry (a host[:port] value), using the registry’s catalog API,
+// fetching them one page at a time, as the iteration proceeds.
+// If the registry does not provide a catalog (which is common for public registries, including Docker Hub),
+// the iterator yields ErrCatalogUnsupported and ends; use CatalogSupported to check in advance.
+// If fetching a page fails, the iterator yields the error, and ends.
+// NOTE: Mirror configuration is ignored.
+func ListRepositories(ctx context.Context, sys *types.SystemContext, registry string, options ListOptions) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		if registry == dockerHostname {
+			yield("", ErrCatalogUnsupported)
+			return
+		}
+		client, err := newCatalogClient(sys, registry)
+		if err != nil {
+			yield("", err)
+			return
+		}
+		defer client.Close()
+
+		listPaginated(catalogPath, options, func(path string) ([]string, string, error) {
+			return client.getCatalogPage(ctx, path)
+		})(yield)
+	}
+}
+
+// CatalogSupported returns true if registry (a host[:port] value) provides a catalog of its repositories,
+// and the credentials configured in sys allow listing it; see ListRepositories.
+func CatalogSupported(ctx context.Context, sys *types.SystemContext, registry string) (bool, error) {
+	for _, err := range ListRepositories(ctx, sys, registry, ListOptions{PageSize: 1}) {
+		if errors.Is(err, ErrCatalogUnsupported) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		break
+	}
+	return true, nil
+}
+
+// newCatalogClient returns a client for accessing the catalog of registry.
+// The caller must call Close() on the returned client.
+func newCatalogClient(sys *types.SystemContext, registry string) (*dockerClient, error) {
+	// We can't use GetCredentialsForRef here because we want to access the whole registry.
+	auth, err := config.GetCredentials(sys, registry)
+	if err != nil {
+		return nil, fmt.Errorf("getting username and password: %w", err)
+	}
+	client, err := newDockerClient(sys, registry, registry)
+	if err != nil {
+		return nil, fmt.Errorf("creating new docker client: %w", err)
+	}
+	client.auth = auth
+	if sys != nil {
+		client.registryToken = sys.DockerBearerRegistryToken
+	}
+	return client, nil
+}
+
+// getCatalogPage fetches a single page of the repository catalog at path,
+// and returns the repositories, and the path of the next page, or "" if this is the last page.
+func (c *dockerClient) getCatalogPage(ctx context.Context, path string) ([]string, string, error) {
+	scope := catalogScope
+	res, err := c.makeRequest(ctx, http.MethodGet, path, nil, nil, v2Auth, &scope)
+	if err != nil {
+		return nil, "", err
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusMethodNotAllowed:
+		return nil, "", fmt.Errorf("%w (%s)", ErrCatalogUnsupported, res.Status)
+	default:
+		err := registryHTTPResponseToError(res)
+		var ec errcode.ErrorCoder
+		if errors.As(err, &ec) && ec.ErrorCode() == errcode.ErrorCodeUnsupported {
+			return nil, "", fmt.Errorf("%w: %w", ErrCatalogUnsupported, err)
+		}
+		return nil, "", fmt.Errorf("fetching repository catalog: %w", err)
+	}
+
+	var catalog struct {
+		Repositories []string `json:"repositories"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&catalog); err != nil {
+		return nil, "", fmt.Errorf("parsing repository catalog: %w", err)
+	}
+	next, err := nextPagePath(res)
+	if err != nil {
+		return nil, "", err
+	}
+	return catalog.Repositories, next, nil
+}
+
+// listPaginated returns an iterator over items of a paginated list starting at path, using getPage to fetch each page.
+// getPage returns the items on a page, and the path of the next page, or "" if there are no more pages.
+func listPaginated(path string, options ListOptions, getPage func(path string) ([]string, string, error)) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		query := url.Values{}
+		if options.PageSize > 0 {
+			query.Set("n", strconv.Itoa(options.PageSize))
+		}
+		if options.Last != "" {
+			query.Set("last", options.Last)
+		}
+		if len(query) != 0 {
+			path += "?" + query.Encode()
+		}
+		listed := set.New[string]() // Items already listed (or filtered out), in case the registry repeats them on later pages
+		for path != "" {
+			items, next, err := getPage(path)
+			if err != nil {
+				yield("", err)
+				return
+			}
+			newItems := false
+			for _, item := range items {
+				if listed.Contains(item) {
+					continue
+				}
+				listed.Add(item)
+				newItems = true
+				if options.Filter != nil && !options.Filter(item) {
+					continue
+				}
+				if !yield(item, nil) {
+					return
+				}
+			}
+			if len(items) != 0 && !newItems {
+				// The registry is not making progress, e.g. because it ignores the "last" parameter in the Link header.
+				logrus.Debugf("Page %s contains only items already listed, ending the listing", path)
+				return
+			}
+			path = next
+		}
+	}
+}
+
+// nextPagePath returns the path (and query) of the next page of a paginated list, as indicated by the Link header of res,
+// or "" if there is no next page.
+func nextPagePath(res *http.Response) (string, error) {
+	link := res.Header.Get("Link")
+	if link == "" {
+		return "", nil
+	}
+	linkURLPart, _, _ := strings.Cut(link, ";")
+	linkURL, err := url.Parse(strings.Trim(linkURLPart, "<>"))
+	if err != nil {
+		return "", err
+	}
+	// can be relative or absolute, but we only want the path (and I
+	// guess we're in trouble if it forwards to a new place...)
+	path := linkURL.Path
+	if linkURL.RawQuery != "" {
+		path += "?" + linkURL.RawQuery
+	}
+	return path, nil
+}
@@ -0,0 +1,182 @@
+package docker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/containers/image/v5/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// paginatedListHandler responds to a list request with a page of items, respecting the "n" and "last" parameters
+// unless ignoreLast, adding a Link header if there are more items.
+// Items are returned in the order of items, which does not have to be sorted.
+func paginatedListHandler(t *testing.T, w http.ResponseWriter, r *http.Request, field string, items []string, ignoreLast bool) {
+	query := r.URL.Query()
+	start := 0
+	if last := query.Get("last"); last != "" && !ignoreLast {
+		start = slices.Index(items, last) + 1
+		if start == 0 { // last is not one of items
+			start = len(items)
+			for i, item := range items {
+				if item > last {
+					start = i
+					break
+				}
+			}
+		}
+	}
+	n := 2 // A default page size, so that pagination is always exercised
+	if s := query.Get("n"); s != "" {
+		var err error
+		n, err = strconv.Atoi(s)
+		require.NoError(t, err)
+	}
+	end := min(start+n, len(items))
+	page := items[start:end]
+	if end < len(items) {
+		next := url.Values{}
+		next.Set("n", strconv.Itoa(n))
+		next.Set("last", items[end-1])
+		w.Header().Set("Link", `<`+r.URL.Path+"?"+next.Encode()+`>; rel="next"`)
+	}
+	_, err := w.Write([]byte(`{"` + field + `":["` + strings.Join(page, `","`) + `"]}`))
+	require.NoError(t, err)
+}
+
+func TestListRepositoryTags(t *testing.T) {
+	tags := []string{"1.0", "1.1", "2.0", "2.1", "latest"}
+	ignoreLast := false
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/":
+		case "/v2/repo/tags/list":
+			requests++
+			paginatedListHandler(t, w, r, "tags", tags, ignoreLast)
+		default:
+			assert.Failf(t, "Unexpected request", "%s %s", r.Method, r.URL)
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	sys := &types.SystemContext{DockerInsecureSkipTLSVerify: types.OptionalBoolTrue}
+	ref, err := ParseReference("//" + strings.TrimPrefix(server.URL, "http://") + "/repo")
+	require.NoError(t, err)
+
+	collect := func(options ListOptions) []string {
+		res := []string{}
+		for tag, err := range ListRepositoryTags(context.Background(), sys, ref, options) {
+			require.NoError(t, err)
+			res = append(res, tag)
+		}
+		return res
+	}
+	for _, c := range []struct {
+		options  ListOptions
+		expected []string
+	}{
+		{ListOptions{}, tags},
+		{ListOptions{PageSize: 10}, tags},
+		{ListOptions{Last: "1.1"}, []string{"2.0", "2.1", "latest"}},
+		{ListOptions{Filter: func(tag string) bool { return strings.HasPrefix(tag, "2.") }}, []string{"2.0", "2.1"}},
+	} {
+		assert.Equal(t, c.expected, collect(c.options), "%#v", c.options)
+	}
+
+	// If the registry ignores "last", all items are returned.
+	ignoreLast = true
+	assert.Equal(t, tags, collect(ListOptions{Last: "1.1", PageSize: 10}))
+	// If the registry ignores "last" in the Link header, repeated items are not returned again, and the listing ends.
+	assert.Equal(t, []string{"1.0", "1.1"}, collect(ListOptions{}))
+	ignoreLast = false
+
+	// Items are not assumed to be sorted.
+	unsortedTags := []string{"latest", "2.0", "1.0", "2.1", "1.1"}
+	savedTags := tags
+	tags = unsortedTags
+	assert.Equal(t, unsortedTags, collect(ListOptions{}))
+	assert.Equal(t, []string{"1.0", "2.1", "1.1"}, collect(ListOptions{Last: "2.0"}))
+	tags = savedTags
+
+	// Pages are only fetched as needed
+	requests = 0
+	for tag, err := range ListRepositoryTags(context.Background(), sys, ref, ListOptions{}) {
+		require.NoError(t, err)
+		if tag == "1.1" {
+			break
+		}
+	}
+	assert.Equal(t, 1, requests)
+
+	// The legacy API returns all tags
+	allTags, err := GetRepositoryTags(context.Background(), sys, ref)
+	require.NoError(t, err)
+	assert.Equal(t, tags, allTags)
+}
+
+func TestListRepositories(t *testing.T) {
+	repos := []string{"a/one", "a/two", "b/one", "c"}
+	catalogStatus := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/":
+		case "/v2/_catalog":
+			if catalogStatus != http.StatusOK {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(catalogStatus)
+				_, err := w.Write([]byte(`{"errors":[{"code":"UNSUPPORTED","message":"catalog is disabled"}]}`))
+				require.NoError(t, err)
+				return
+			}
+			paginatedListHandler(t, w, r, "repositories", repos, false)
+		default:
+			assert.Failf(t, "Unexpected request", "%s %s", r.Method, r.URL)
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	sys := &types.SystemContext{DockerInsecureSkipTLSVerify: types.OptionalBoolTrue, AuthFilePath: filepath.Join(t.TempDir(), "auth.json")}
+	registry := strings.TrimPrefix(server.URL, "http://")
+
+	res := []string{}
+	for repo, err := range ListRepositories(context.Background(), sys, registry, ListOptions{Filter: func(repo string) bool {
+		return strings.HasPrefix(repo, "a/") || repo == "c"
+	}}) {
+		require.NoError(t, err)
+		res = append(res, repo)
+	}
+	assert.Equal(t, []string{"a/one", "a/two", "c"}, res)
+	supported, err := CatalogSupported(context.Background(), sys, registry)
+	require.NoError(t, err)
+	assert.True(t, supported)
+
+	for _, status := range []int{http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusBadRequest} {
+		catalogStatus = status
+		errs := []error{}
+		for _, err := range ListRepositories(context.Background(), sys, registry, ListOptions{}) {
+			errs = append(errs, err)
+		}
+		require.Len(t, errs, 1)
+		assert.ErrorIs(t, errs[0], ErrCatalogUnsupported, status)
+		supported, err := CatalogSupported(context.Background(), sys, registry)
+		require.NoError(t, err)
+		assert.False(t, supported, status)
+	}
+
+	// Docker Hub does not provide a catalog; no requests are made
+	errs := []error{}
+	for _, err := range ListRepositories(context.Background(), sys, dockerHostname, ListOptions{}) {
+		errs = append(errs, err)
+	}
+	require.Len(t, errs, 1)
+	assert.ErrorIs(t, errs[0], ErrCatalogUnsupported)
+}
@@ -0,0 +1,113 @@
+package docker
+
+import (
+	"container/list"
+	"sync"
+)
+
+const (
+	// manifestCacheMaxEntries is the maximum number of manifests held by the process-wide manifest cache.
+	manifestCacheMaxEntries = 256
+	// manifestCacheMaxBytes is the maximum total size of manifests held by the process-wide manifest cache.
+	manifestCacheMaxBytes = 16 * 1024 * 1024
+)
+
+// manifestCacheKey identifies a manifest GET request whose response may be revalidated.
+type manifestCacheKey struct {
+	scheme      string
+	registry    string
+	repo        string // reference.Path of the repository
+	tagOrDigest string
+	accept      string // The Accept header of the request
+}
+
+// manifestCacheEntry is a cached manifest GET response.
+type manifestCacheEntry struct {
+	key      manifestCacheKey
+	etag     string
+	manifest []byte
+	mimeType string
+}
+
+// manifestCache is a bounded, process-wide cache of manifest GET responses.
+//
+// Entries are never returned without revalidation: callers must send the recorded ETag in an
+// If-None-Match header, and only use the cached data if the registry responds with 304 Not Modified.
+// That ensures that the registry still authorizes every access, and that tag updates are noticed.
+type manifestCache struct {
+	mutex     sync.Mutex
+	entries   map[manifestCacheKey]*list.Element // Values are *manifestCacheEntry
+	lru       *list.List                         // Most recently used entries are at the front
+	totalSize int
+	maxItems  int
+	maxBytes  int
+}
+
+// processManifestCache is shared by all dockerClient instances in this process.
+var processManifestCache = newManifestCache(manifestCacheMaxEntries, manifestCacheMaxBytes)
+
+// newManifestCache returns an empty manifestCache holding at most maxItems entries with at most maxBytes of manifest data.
+func newManifestCache(maxItems, maxBytes int) *manifestCache {
+	return &manifestCache{
+		entries:  map[manifestCacheKey]*list.Element{},
+		lru:      list.New(),
+		maxItems: maxItems,
+		maxBytes: maxBytes,
+	}
+}
+
+// get returns a cached entry for key, if any.
+func (mc *manifestCache) get(key manifestCacheKey) (manifestCacheEntry, bool) {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	e, ok := mc.entries[key]
+	if !ok {
+		return manifestCacheEntry{}, false
+	}
+	mc.lru.MoveToFront(e)
+	return *e.Value.(*manifestCacheEntry), true
+}
+
+// put records manifest, with mimeType and etag, as the response for key.
+func (mc *manifestCache) put(key manifestCacheKey, etag string, manifest []byte, mimeType string) {
+	if etag == "" || len(manifest) > mc.maxBytes {
+		return
+	}
+
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	mc.removeLocked(key)
+	entry := &manifestCacheEntry{
+		key:      key,
+		etag:     etag,
+		manifest: manifest,
+		mimeType: mimeType,
+	}
+	mc.entries[key] = mc.lru.PushFront(entry)
+	mc.totalSize += len(manifest)
+	for mc.lru.Len() > mc.maxItems || mc.totalSize > mc.maxBytes {
+		oldest := mc.lru.Back().Value.(*manifestCacheEntry)
+		mc.removeLocked(oldest.key)
+	}
+}
+
+// remove drops any entry for key.
+func (mc *manifestCache) remove(key manifestCacheKey) {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	mc.removeLocked(key)
+}
+
+// removeLocked drops any entry for key.
+// The caller must hold mc.mutex.
+func (mc *manifestCache) removeLocked(key manifestCacheKey) {
+	e, ok := mc.entries[key]
+	if !ok {
+		return
+	}
+	mc.lru.Remove(e)
+	delete(mc.entries, key)
+	mc.totalSize -= len(e.Value.(*manifestCacheEntry).manifest)
+}
@@ -0,0 +1,140 @@
+package docker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifestCache(t *testing.T) {
+	keyA := manifestCacheKey{registry: "a.example.com", repo: "ns/repo", tagOrDigest: "latest"}
+	keyB := manifestCacheKey{registry: "b.example.com", repo: "ns/repo", tagOrDigest: "latest"}
+	keyC := manifestCacheKey{registry: "c.example.com", repo: "ns/repo", tagOrDigest: "latest"}
+
+	mc := newManifestCache(2, 10)
+	_, ok := mc.get(keyA)
+	assert.False(t, ok)
+
+	// Entries without an ETag are not recorded
+	mc.put(keyA, "", []byte("a"), "mime/a")
+	_, ok = mc.get(keyA)
+	assert.False(t, ok)
+
+	mc.put(keyA, `"etag-a"`, []byte("a"), "mime/a")
+	e, ok := mc.get(keyA)
+	require.True(t, ok)
+	assert.Equal(t, manifestCacheEntry{key: keyA, etag: `"etag-a"`, manifest: []byte("a"), mimeType: "mime/a"}, e)
+
+	// Item count limit: the least recently used entry is evicted.
+	mc.put(keyB, `"etag-b"`, []byte("b"), "mime/b")
+	_, ok = mc.get(keyA) // Makes keyB the least recently used one
+	require.True(t, ok)
+	mc.put(keyC, `"etag-c"`, []byte("c"), "mime/c")
+	_, ok = mc.get(keyB)
+	assert.False(t, ok)
+	_, ok = mc.get(keyA)
+	assert.True(t, ok)
+
+	// Size limit
+	mc.put(keyB, `"etag-b"`, []byte("bbbbbbbbbb"), "mime/b")
+	assert.Equal(t, 10, mc.totalSize)
+	assert.Equal(t, 1, mc.lru.Len())
+	mc.put(keyA, `"etag-a"`, []byte("too large to be cached"), "mime/a")
+	_, ok = mc.get(keyA)
+	assert.False(t, ok)
+
+	mc.remove(keyB)
+	assert.Equal(t, 0, mc.totalSize)
+	assert.Equal(t, 0, mc.lru.Len())
+}
+
+func TestFetchManifestRevalidation(t *testing.T) {
+	const etag = `"v1"`
+	manifestBody := []byte(`{"schemaVersion":2}`)
+	var requests, fullResponses, headRequests, notModifiedHeadRequests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v2/":
+			rw.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodGet && r.URL.Path == "/v2/cached/manifests/latest":
+			requests.Add(1)
+			if r.Header.Get("If-None-Match") == etag {
+				rw.WriteHeader(http.StatusNotModified)
+				return
+			}
+			fullResponses.Add(1)
+			rw.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+			rw.Header().Set("ETag", etag)
+			_, err := rw.Write(manifestBody)
+			assert.NoError(t, err)
+		case r.Method == http.MethodHead && r.URL.Path == "/v2/cached/manifests/latest":
+			headRequests.Add(1)
+			if r.Header.Get("If-None-Match") == etag {
+				notModifiedHeadRequests.Add(1)
+				rw.WriteHeader(http.StatusNotModified)
+				return
+			}
+			rw.Header().Set("Docker-Content-Digest", digest.FromBytes(manifestBody).String())
+			rw.WriteHeader(http.StatusOK)
+		default:
+			assert.Failf(t, "Unexpected request", "%v %v", r.Method, r.URL.Path)
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	registryURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	ref, err := ParseReference("//" + registryURL.Host + "/cached:latest")
+	require.NoError(t, err)
+	dr, ok := ref.(dockerReference)
+	require.True(t, ok)
+
+	for _, c := range []struct {
+		disableCache             bool
+		expectedFullResponses    int32
+		expectedNotModifiedHEADs int32
+	}{
+		{false, 1, 1}, // The first fetch populates the cache, the second one, and GetDigest, are revalidated
+		{true, 2, 0},  // Every fetch is a full response
+	} {
+		requests.Store(0)
+		fullResponses.Store(0)
+		headRequests.Store(0)
+		notModifiedHeadRequests.Store(0)
+		processManifestCache.remove(manifestCacheKey{scheme: "http", registry: registryURL.Host, repo: "cached", tagOrDigest: "latest",
+			accept: strings.Join(manifest.DefaultRequestedManifestMIMETypes, ", ")})
+		sys := &types.SystemContext{
+			DockerPerHostCertDirPath:    "/this/does/not/exist",
+			DockerInsecureSkipTLSVerify: types.OptionalBoolTrue,
+			DockerDisableManifestCache:  c.disableCache,
+		}
+		for range 2 {
+			client, err := newDockerClient(sys, registryURL.Host, registryURL.Host)
+			require.NoError(t, err)
+			m, mimeType, err := client.fetchManifest(context.Background(), dr, "latest")
+			require.NoError(t, err)
+			assert.Equal(t, manifestBody, m)
+			assert.Equal(t, "application/vnd.oci.image.manifest.v1+json", mimeType)
+			client.Close()
+		}
+		assert.Equal(t, int32(2), requests.Load())
+		assert.Equal(t, c.expectedFullResponses, fullResponses.Load())
+
+		dig, err := GetDigest(context.Background(), sys, ref)
+		require.NoError(t, err)
+		assert.Equal(t, digest.FromBytes(manifestBody), dig)
+		assert.Equal(t, int32(1), headRequests.Load())
+		assert.Equal(t, c.expectedNotModifiedHEADs, notModifiedHeadRequests.Load())
+	}
+}
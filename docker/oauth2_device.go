@@ -0,0 +1,183 @@
+package docker
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/containers/image/v5/internal/iolimits"
+	"github.com/containers/image/v5/pkg/tlsclientconfig"
+	"github.com/containers/image/v5/types"
+	"github.com/docker/go-connections/tlsconfig"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// oauth2DeviceDefaultInterval is the polling interval, in seconds, used if the authorization server does not specify one.
+	oauth2DeviceDefaultInterval = 5
+	// oauth2DeviceGrantType is the grant_type value of the device authorization grant.
+	oauth2DeviceGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+)
+
+// oauth2DeviceIntervalUnit is the unit of polling intervals specified by authorization servers; it is only changed by tests.
+var oauth2DeviceIntervalUnit = time.Second
+
+// OAuth2DeviceFlow describes how to obtain registry credentials using the OAuth2 device authorization grant (RFC 8628),
+// which allows logging in on machines without a web browser.
+type OAuth2DeviceFlow struct {
+	DeviceAuthorizationURL string   // The device authorization endpoint of the authorization server
+	TokenURL               string   // The token endpoint of the authorization server
+	ClientID               string   // The OAuth2 client ID registered with the authorization server
+	Scopes                 []string // OAuth2 scopes to request, if any
+	// Prompt is called with the URL the user should visit (and, if the server provides one, a URL which already includes the code),
+	// and the code the user should enter; the login waits for the user to authorize the device afterwards.
+	// If it returns an error, the login fails with that error.
+	Prompt func(verificationURI, verificationURIComplete, userCode string) error
+}
+
+// oauth2DeviceAuthorization is a response from a device authorization endpoint.
+type oauth2DeviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// oauth2TokenResponse is a response from a token endpoint, either successful or an error.
+type oauth2TokenResponse struct {
+	RefreshToken     string `json:"refresh_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// OAuth2DeviceLogin performs flow, waiting until the user authorizes the device (or ctx is canceled), and returns credentials
+// containing the refresh token issued by the authorization server as an identity token.
+// The credentials can be used as types.SystemContext.DockerAuthConfig, or returned by a types.DockerTokenProvider;
+// the docker transport exchanges the refresh token for registry access tokens at the registry’s token server.
+func OAuth2DeviceLogin(ctx context.Context, sys *types.SystemContext, flow OAuth2DeviceFlow) (types.DockerAuthConfig, error) {
+	if flow.DeviceAuthorizationURL == "" || flow.TokenURL == "" || flow.ClientID == "" || flow.Prompt == nil {
+		return types.DockerAuthConfig{}, errors.New("incomplete OAuth2 device flow configuration")
+	}
+	client := newOAuth2HTTPClient(sys)
+
+	params := url.Values{}
+	params.Set("client_id", flow.ClientID)
+	if len(flow.Scopes) != 0 {
+		params.Set("scope", strings.Join(flow.Scopes, " "))
+	}
+	var authorization oauth2DeviceAuthorization
+	if _, err := postOAuth2Form(ctx, sys, client, flow.DeviceAuthorizationURL, params, &authorization); err != nil {
+		return types.DockerAuthConfig{}, fmt.Errorf("requesting device authorization: %w", err)
+	}
+	if authorization.DeviceCode == "" || authorization.UserCode == "" || authorization.VerificationURI == "" {
+		return types.DockerAuthConfig{}, errors.New("invalid device authorization response: missing device code, user code, or verification URI")
+	}
+	if err := flow.Prompt(authorization.VerificationURI, authorization.VerificationURIComplete, authorization.UserCode); err != nil {
+		return types.DockerAuthConfig{}, err
+	}
+
+	interval := oauth2DeviceDefaultInterval
+	if authorization.Interval > 0 {
+		interval = authorization.Interval
+	}
+	if authorization.ExpiresIn > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, time.Duration(authorization.ExpiresIn)*oauth2DeviceIntervalUnit,
+			errors.New("the device code has expired before the device was authorized"))
+		defer cancel()
+	}
+	params = url.Values{}
+	params.Set("grant_type", oauth2DeviceGrantType)
+	params.Set("device_code", authorization.DeviceCode)
+	params.Set("client_id", flow.ClientID)
+	for {
+		select {
+		case <-ctx.Done():
+			return types.DockerAuthConfig{}, context.Cause(ctx)
+		case <-time.After(time.Duration(interval) * oauth2DeviceIntervalUnit):
+		}
+
+		var token oauth2TokenResponse
+		status, err := postOAuth2Form(ctx, sys, client, flow.TokenURL, params, &token)
+		if err != nil && status != http.StatusBadRequest { // Pending authorization is reported as 400 Bad Request
+			return types.DockerAuthConfig{}, fmt.Errorf("polling for device authorization: %w", err)
+		}
+		switch token.Error {
+		case "":
+			if err != nil {
+				return types.DockerAuthConfig{}, fmt.Errorf("polling for device authorization: %w", err)
+			}
+			if token.RefreshToken == "" {
+				return types.DockerAuthConfig{}, errors.New("the authorization server did not issue a refresh token")
+			}
+			return types.DockerAuthConfig{IdentityToken: token.RefreshToken}, nil
+		case "authorization_pending":
+			logrus.Debugf("Waiting for the device to be authorized")
+		case "slow_down":
+			interval += oauth2DeviceDefaultInterval
+			logrus.Debugf("Authorization server requested slower polling, polling every %d seconds", interval)
+		default: // Including "access_denied" and "expired_token"
+			if token.ErrorDescription != "" {
+				return types.DockerAuthConfig{}, fmt.Errorf("device authorization failed: %s: %s", token.Error, token.ErrorDescription)
+			}
+			return types.DockerAuthConfig{}, fmt.Errorf("device authorization failed: %s", token.Error)
+		}
+	}
+}
+
+// newOAuth2HTTPClient returns a HTTP client for accessing OAuth2 endpoints, configured by sys.
+func newOAuth2HTTPClient(sys *types.SystemContext) *http.Client {
+	tlsClientConfig := &tls.Config{
+		CipherSuites: tlsconfig.DefaultServerAcceptedCiphers,
+	}
+	tr := tlsclientconfig.NewTransport()
+	tr.TLSClientConfig = tlsClientConfig
+	if sys != nil {
+		tlsClientConfig.InsecureSkipVerify = sys.DockerInsecureSkipTLSVerify == types.OptionalBoolTrue && !sys.DockerStrictTLS
+		if sys.DockerProxyURL != nil {
+			tr.Proxy = http.ProxyURL(sys.DockerProxyURL)
+		}
+	}
+	return &http.Client{Transport: tr}
+}
+
+// postOAuth2Form posts params to endpoint, and parses the JSON response into dest.
+// It returns the HTTP status; if it is not 200, it also returns an error, but dest may be filled with the error details.
+func postOAuth2Form(ctx context.Context, sys *types.SystemContext, client *http.Client, endpoint string, params url.Values, dest any) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(params.Encode()))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", registryUserAgent(sys))
+	if sys != nil {
+		addHeaders(req.Header, sys.DockerTraceHeaders)
+	}
+	logrus.Debugf("%s %s", req.Method, req.URL.Redacted())
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	body, err := iolimits.ReadAtMost(res.Body, iolimits.MaxAuthTokenBodySize)
+	if err != nil {
+		return res.StatusCode, err
+	}
+	parseErr := json.Unmarshal(body, dest)
+	if res.StatusCode != http.StatusOK {
+		return res.StatusCode, fmt.Errorf("unexpected HTTP status %s", res.Status)
+	}
+	if parseErr != nil {
+		return res.StatusCode, fmt.Errorf("parsing response: %w", parseErr)
+	}
+	return res.StatusCode, nil
+}
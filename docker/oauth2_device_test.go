@@ -0,0 +1,115 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/containers/image/v5/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// deviceFlowTestServer returns a server implementing the OAuth2 device authorization grant, responding to the first token
+// requests with pollResponses (as 400 Bad Request errors), and then with finalResponse.
+func deviceFlowTestServer(t *testing.T, pollResponses []string, finalStatus int, finalResponse string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		require.NoError(t, err)
+		assert.Equal(t, "test-client", r.PostForm.Get("client_id"))
+		switch r.URL.Path {
+		case "/device":
+			assert.Equal(t, "registry offline", r.PostForm.Get("scope"))
+			_, err := w.Write([]byte(`{"device_code":"device-code","user_code":"USER-CODE","verification_uri":"https://example.com/device",` +
+				`"verification_uri_complete":"https://example.com/device?code=USER-CODE","expires_in":1000,"interval":1}`))
+			require.NoError(t, err)
+		case "/token":
+			assert.Equal(t, oauth2DeviceGrantType, r.PostForm.Get("grant_type"))
+			assert.Equal(t, "device-code", r.PostForm.Get("device_code"))
+			if len(pollResponses) > 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				_, err := w.Write([]byte(`{"error":"` + pollResponses[0] + `"}`))
+				require.NoError(t, err)
+				pollResponses = pollResponses[1:]
+				return
+			}
+			w.WriteHeader(finalStatus)
+			_, err := w.Write([]byte(finalResponse))
+			require.NoError(t, err)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOAuth2DeviceLogin(t *testing.T) {
+	oauth2DeviceIntervalUnit = time.Millisecond
+	defer func() { oauth2DeviceIntervalUnit = time.Second }()
+
+	newFlow := func(server *httptest.Server, prompts *[]string) OAuth2DeviceFlow {
+		return OAuth2DeviceFlow{
+			DeviceAuthorizationURL: server.URL + "/device",
+			TokenURL:               server.URL + "/token",
+			ClientID:               "test-client",
+			Scopes:                 []string{"registry", "offline"},
+			Prompt: func(verificationURI, verificationURIComplete, userCode string) error {
+				*prompts = append(*prompts, verificationURI, verificationURIComplete, userCode)
+				return nil
+			},
+		}
+	}
+
+	// Success, after waiting
+	server := deviceFlowTestServer(t, []string{"authorization_pending", "slow_down", "authorization_pending"}, http.StatusOK,
+		`{"access_token":"access","refresh_token":"refresh","token_type":"Bearer"}`)
+	prompts := []string{}
+	auth, err := OAuth2DeviceLogin(context.Background(), nil, newFlow(server, &prompts))
+	require.NoError(t, err)
+	assert.Equal(t, types.DockerAuthConfig{IdentityToken: "refresh"}, auth)
+	assert.Equal(t, []string{"https://example.com/device", "https://example.com/device?code=USER-CODE", "USER-CODE"}, prompts)
+
+	// Failures
+	for _, c := range []struct {
+		pollResponses []string
+		status        int
+		response      string
+	}{
+		{[]string{"authorization_pending", "access_denied"}, http.StatusOK, `{}`},
+		{[]string{"expired_token"}, http.StatusOK, `{}`},
+		{nil, http.StatusOK, `{"access_token":"access"}`}, // No refresh token
+		{nil, http.StatusInternalServerError, `{}`},
+		{nil, http.StatusOK, `this is invalid`},
+	} {
+		server := deviceFlowTestServer(t, c.pollResponses, c.status, c.response)
+		_, err := OAuth2DeviceLogin(context.Background(), nil, newFlow(server, &prompts))
+		assert.Error(t, err, "%#v", c)
+	}
+
+	// Prompt failures
+	server = deviceFlowTestServer(t, nil, http.StatusOK, `{"refresh_token":"refresh"}`)
+	flow := newFlow(server, &prompts)
+	promptErr := errors.New("prompt failed")
+	flow.Prompt = func(verificationURI, verificationURIComplete, userCode string) error { return promptErr }
+	_, err = OAuth2DeviceLogin(context.Background(), nil, flow)
+	assert.ErrorIs(t, err, promptErr)
+
+	// Incomplete configuration
+	_, err = OAuth2DeviceLogin(context.Background(), nil, OAuth2DeviceFlow{TokenURL: server.URL + "/token", ClientID: "test-client"})
+	assert.Error(t, err)
+
+	// Cancellation while waiting
+	server = deviceFlowTestServer(t, []string{"authorization_pending", "authorization_pending", "authorization_pending"}, http.StatusOK, `{"refresh_token":"refresh"}`)
+	ctx, cancel := context.WithCancel(context.Background())
+	flow = newFlow(server, &prompts)
+	flow.Prompt = func(verificationURI, verificationURIComplete, userCode string) error {
+		cancel()
+		return nil
+	}
+	_, err = OAuth2DeviceLogin(ctx, nil, flow)
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -76,3 +76,43 @@ func DockerReferenceNamespaces(ref reference.Named) []string {
 	}
 	return res
 }
+
+// DockerReferenceScopeIsPattern returns true if scope is a pattern scope, to be matched using DockerReferenceScopePatternMatches,
+// as a backend for private.ImageTransportWithScopePatterns.PolicyConfigurationScopeIsPattern.
+// The "*.domain" wildcarded domains returned by DockerReferenceNamespaces are not pattern scopes.
+func DockerReferenceScopeIsPattern(scope string) bool {
+	if rest, ok := strings.CutPrefix(scope, "*."); ok {
+		return strings.Contains(rest, "*")
+	}
+	return strings.Contains(scope, "*")
+}
+
+// DockerReferenceScopePatternMatches returns true if pattern matches identity, a DockerReferenceIdentity value,
+// as a backend for private.ImageTransportWithScopePatterns.PolicyConfigurationScopePatternMatches.
+// "*" in pattern matches any sequence of characters other than "/", so it can stand for a part of a single path
+// component, the tag, or the digest value; all other characters of pattern must match literally.
+func DockerReferenceScopePatternMatches(pattern, identity string) bool {
+	// This is a simple backtracking matcher; only the most recent "*" needs to be retried,
+	// because a "*" never matches a "/".
+	p, i := 0, 0
+	starP, starI := -1, -1
+	for i < len(identity) {
+		switch {
+		case p < len(pattern) && pattern[p] == '*':
+			starP, starI = p, i
+			p++
+		case p < len(pattern) && pattern[p] == identity[i]:
+			p++
+			i++
+		case starP != -1 && identity[starI] != '/':
+			starI++
+			p, i = starP+1, starI
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
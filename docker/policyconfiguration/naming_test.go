@@ -84,3 +84,51 @@ func TestDockerReferenceIdentity(t *testing.T) {
 	assert.Equal(t, "", id)
 	assert.Error(t, err)
 }
+
+func TestDockerReferenceScopeIsPattern(t *testing.T) {
+	for _, c := range []struct {
+		scope    string
+		expected bool
+	}{
+		{"example.com/ns/repo:tag", false},
+		{"example.com", false},
+		{"*.example.com", false},
+		{"example.com/ns/*", true},
+		{"example.com/ns/repo:release-*", true},
+		{"example.com/ns/repo@*", true},
+		{"*.example.com/ns/*:release-*", true},
+	} {
+		assert.Equal(t, c.expected, DockerReferenceScopeIsPattern(c.scope), c.scope)
+	}
+}
+
+func TestDockerReferenceScopePatternMatches(t *testing.T) {
+	for _, c := range []struct {
+		pattern, identity string
+		expected          bool
+	}{
+		{"quay.io/org/*:release-*", "quay.io/org/app:release-1", true},
+		{"quay.io/org/*:release-*", "quay.io/org/app:release-", true},
+		{"quay.io/org/*:release-*", "quay.io/org/app:dev-1", false},
+		{"quay.io/org/*:release-*", "quay.io/org/sub/app:release-1", false},
+		{"quay.io/org/*:release-*", "quay.io/other/app:release-1", false},
+		{"quay.io/org/*", "quay.io/org/app:latest", true},
+		{"quay.io/org/*", "quay.io/org/sub/app:latest", false},
+		{"quay.io/*/app:*", "quay.io/org/app:latest", true},
+		{"quay.io/*/app:*", "quay.io/org/app@sha256:0123", false},
+		{"quay.io/org/app@*", "quay.io/org/app@sha256:0123", true},
+		{"quay.io/org/app@sha256:*", "quay.io/org/app@sha256:0123", true},
+		{"quay.io/org/app@sha256:*", "quay.io/org/app:latest", false},
+		{"quay.io/org/*@sha256:0123", "quay.io/org/app@sha256:0123", true},
+		{"quay.io/org/*@sha256:0123", "quay.io/org/app@sha256:01234", false},
+		{"*.example.com/app:*", "registry.example.com/app:latest", true},
+		{"*.example.com/app:*", "example.com/app:latest", false},
+		{"quay.io/org/*a*b*", "quay.io/org/xaybz:tag", true},
+		{"quay.io/org/*a*b*", "quay.io/org/xay/bz:tag", false},
+		{"quay.io/org/**", "quay.io/org/app:tag", true},
+		{"quay.io/org/app:tag*", "quay.io/org/app:tag", true},
+	} {
+		res := DockerReferenceScopePatternMatches(c.pattern, c.identity)
+		assert.Equal(t, c.expected, res, "%s vs. %s", c.pattern, c.identity)
+	}
+}
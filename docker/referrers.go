@@ -0,0 +1,235 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/internal/iolimits"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultReferrerTreeDepth is the nesting depth used by GetReferrerTree if the caller does not specify one.
+const defaultReferrerTreeDepth = 16
+
+// ReferrerNode is a node of a tree of referrers, as returned by GetReferrerTree.
+type ReferrerNode struct {
+	// Descriptor of the manifest. For referrers, this is the descriptor listed by the registry,
+	// including ArtifactType and Annotations.
+	Descriptor imgspecv1.Descriptor
+	// Referrers lists manifests which refer to this one using their “subject” field, each with its own referrers.
+	Referrers []ReferrerNode
+}
+
+// GetReferrerTree returns the manifest ref refers to, along with all manifests which (directly or indirectly) refer to it
+// using the OCI “subject” field, e.g. signatures, SBOMs, and attestations.
+// If the registry does not support the OCI referrers API, the referrers tag schema is used instead.
+// Referrers are followed up to maxDepth levels deep; if maxDepth <= 0, a default limit is used.
+func GetReferrerTree(ctx context.Context, sys *types.SystemContext, ref types.ImageReference, maxDepth int) (*ReferrerNode, error) {
+	dr, ok := ref.(dockerReference)
+	if !ok {
+		return nil, errors.New("ref must be a dockerReference")
+	}
+	if dr.isUnknownDigest {
+		return nil, fmt.Errorf("docker: reference %q is for unknown digest case; cannot get referrers", dr.StringWithinTransport())
+	}
+	if maxDepth <= 0 {
+		maxDepth = defaultReferrerTreeDepth
+	}
+
+	tagOrDigest, err := dr.tagOrDigest()
+	if err != nil {
+		return nil, err
+	}
+
+	registryConfig, err := loadRegistryConfiguration(sys)
+	if err != nil {
+		return nil, err
+	}
+	client, err := newDockerClientFromRef(sys, dr, registryConfig, false, "pull")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %w", err)
+	}
+	defer client.Close()
+
+	manifestBlob, mimeType, err := client.fetchManifest(ctx, dr, tagOrDigest)
+	if err != nil {
+		return nil, err
+	}
+	manifestDigest, err := manifest.Digest(manifestBlob)
+	if err != nil {
+		return nil, err
+	}
+	if canonical, ok := dr.ref.(reference.Canonical); ok {
+		matches, err := manifest.MatchesDigest(manifestBlob, canonical.Digest())
+		if err != nil {
+			return nil, err
+		}
+		if !matches {
+			return nil, fmt.Errorf("manifest for %s does not match the expected digest", dr.ref.String())
+		}
+		manifestDigest = canonical.Digest()
+	}
+	root := ReferrerNode{Descriptor: imgspecv1.Descriptor{
+		MediaType: mimeType,
+		Digest:    manifestDigest,
+		Size:      int64(len(manifestBlob)),
+	}}
+	if mimeType == imgspecv1.MediaTypeImageManifest {
+		var m imgspecv1.Manifest
+		if err := json.Unmarshal(manifestBlob, &m); err != nil {
+			return nil, fmt.Errorf("parsing manifest %s: %w", dr.ref.String(), err)
+		}
+		root.Descriptor.ArtifactType = m.ArtifactType
+		root.Descriptor.Annotations = m.Annotations
+	}
+
+	visited := map[digest.Digest]struct{}{manifestDigest: {}}
+	if err := client.walkReferrers(ctx, dr, &root, maxDepth, visited); err != nil {
+		return nil, err
+	}
+	return &root, nil
+}
+
+// walkReferrers fills node.Referrers with referrers of node.Descriptor.Digest, recursively up to depth levels deep.
+// Manifests already present in visited are not walked again.
+func (c *dockerClient) walkReferrers(ctx context.Context, dr dockerReference, node *ReferrerNode, depth int, visited map[digest.Digest]struct{}) error {
+	if depth == 0 {
+		return nil
+	}
+	descriptors, err := c.getReferrers(ctx, dr, node.Descriptor.Digest)
+	if err != nil {
+		return err
+	}
+	for _, desc := range descriptors {
+		if _, ok := visited[desc.Digest]; ok {
+			logrus.Debugf("Referrer %s of %s was already listed, not following it again", desc.Digest.String(), node.Descriptor.Digest.String())
+			continue
+		}
+		visited[desc.Digest] = struct{}{}
+		child := ReferrerNode{Descriptor: desc}
+		if err := c.walkReferrers(ctx, dr, &child, depth-1, visited); err != nil {
+			return err
+		}
+		node.Referrers = append(node.Referrers, child)
+	}
+	return nil
+}
+
+// getReferrers returns descriptors of manifests in the repo of dr which refer to subject.
+func (c *dockerClient) getReferrers(ctx context.Context, dr dockerReference, subject digest.Digest) ([]imgspecv1.Descriptor, error) {
+	if err := subject.Validate(); err != nil { // Make sure subject.String() doesn’t contain any unexpected characters
+		return nil, err
+	}
+	if err := c.detectProperties(ctx); err != nil {
+		return nil, err
+	}
+
+	path := fmt.Sprintf(referrersPath, reference.Path(dr.ref), subject.String())
+	headers := map[string][]string{
+		"Accept": {imgspecv1.MediaTypeImageIndex},
+	}
+	res := []imgspecv1.Descriptor{}
+	for {
+		index, link, supported, err := c.getReferrersPage(ctx, path, headers)
+		if err != nil {
+			return nil, fmt.Errorf("fetching referrers of %s: %w", subject.String(), err)
+		}
+		if !supported {
+			if len(res) != 0 {
+				return nil, fmt.Errorf("fetching referrers of %s: referrers API failed after returning a partial result", subject.String())
+			}
+			return c.getReferrersFromTagSchema(ctx, dr, subject)
+		}
+		res = append(res, index.Manifests...)
+
+		if link == "" {
+			break
+		}
+		linkURLPart, _, _ := strings.Cut(link, ";")
+		linkURL, err := url.Parse(strings.Trim(linkURLPart, "<>"))
+		if err != nil {
+			return nil, err
+		}
+		// can be relative or absolute, but we only want the path (and I
+		// guess we're in trouble if it forwards to a new place...)
+		path = linkURL.Path
+		if linkURL.RawQuery != "" {
+			path += "?"
+			path += linkURL.RawQuery
+		}
+	}
+	return res, nil
+}
+
+// getReferrersPage fetches a single page of the referrers API at path, and returns the page contents and the value of the Link header.
+// It returns supported == false if the registry does not implement the referrers API.
+func (c *dockerClient) getReferrersPage(ctx context.Context, path string, headers map[string][]string) (index *imgspecv1.Index, link string, supported bool, err error) {
+	res, err := c.makeRequest(ctx, http.MethodGet, path, headers, nil, v2Auth, nil)
+	if err != nil {
+		return nil, "", false, err
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		logrus.Debugf("Referrers API is not supported by the registry (%s), falling back to the tag schema", res.Status)
+		return nil, "", false, nil
+	default:
+		return nil, "", false, registryHTTPResponseToError(res)
+	}
+
+	body, err := iolimits.ReadAtMost(res.Body, iolimits.MaxManifestBodySize)
+	if err != nil {
+		return nil, "", false, err
+	}
+	index = &imgspecv1.Index{}
+	if err := json.Unmarshal(body, index); err != nil {
+		return nil, "", false, fmt.Errorf("parsing referrers index: %w", err)
+	}
+	return index, res.Header.Get("Link"), true, nil
+}
+
+// getReferrersFromTagSchema returns descriptors of manifests referring to subject, as recorded by the referrers tag schema.
+func (c *dockerClient) getReferrersFromTagSchema(ctx context.Context, dr dockerReference, subject digest.Digest) ([]imgspecv1.Descriptor, error) {
+	tag := referrersTag(subject)
+	manifestBlob, mimeType, err := c.fetchManifest(ctx, dr, tag)
+	if err != nil {
+		if isManifestUnknownError(err) {
+			logrus.Debugf("Fetching referrers tag %s failed, assuming there are no referrers: %v", tag, err)
+			return []imgspecv1.Descriptor{}, nil
+		}
+		return nil, err
+	}
+	if mimeType != imgspecv1.MediaTypeImageIndex {
+		return nil, fmt.Errorf("unexpected MIME type for referrers tag %s: %q", tag, mimeType)
+	}
+	var index imgspecv1.Index
+	if err := json.Unmarshal(manifestBlob, &index); err != nil {
+		return nil, fmt.Errorf("parsing referrers tag %s: %w", tag, err)
+	}
+	return index.Manifests, nil
+}
+
+// referrersTag returns the tag used by the referrers tag schema for manifests referring to d.
+// The caller is responsible for ensuring d is valid.
+func referrersTag(d digest.Digest) string {
+	alg := d.Algorithm().String()
+	if len(alg) > 32 {
+		alg = alg[:32]
+	}
+	encoded := d.Encoded()
+	if len(encoded) > 64 {
+		encoded = encoded[:64]
+	}
+	return alg + "-" + encoded
+}
@@ -0,0 +1,146 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReferrersTag(t *testing.T) {
+	d := digest.Digest("sha256:" + strings.Repeat("a", 64))
+	assert.Equal(t, "sha256-"+strings.Repeat("a", 64), referrersTag(d))
+	d = digest.Digest("sha512:" + strings.Repeat("b", 128))
+	assert.Equal(t, "sha512-"+strings.Repeat("b", 64), referrersTag(d))
+}
+
+func TestGetReferrerTree(t *testing.T) {
+	rootManifest := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json",` +
+		`"config":{"mediaType":"application/vnd.oci.empty.v1+json","digest":"sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a","size":2},` +
+		`"layers":[],"annotations":{"org.example":"root"}}`)
+	rootDigest := digest.FromBytes(rootManifest)
+	signature := imgspecv1.Descriptor{
+		MediaType:    imgspecv1.MediaTypeImageManifest,
+		ArtifactType: "application/vnd.example.signature",
+		Digest:       digest.FromString("signature"),
+		Size:         10,
+	}
+	sbom := imgspecv1.Descriptor{
+		MediaType:    imgspecv1.MediaTypeImageManifest,
+		ArtifactType: "application/vnd.example.sbom",
+		Digest:       digest.FromString("sbom"),
+		Size:         20,
+		Annotations:  map[string]string{"org.example.format": "spdx"},
+	}
+	sbomSignature := imgspecv1.Descriptor{
+		MediaType:    imgspecv1.MediaTypeImageManifest,
+		ArtifactType: "application/vnd.example.signature",
+		Digest:       digest.FromString("sbom signature"),
+		Size:         30,
+	}
+	referrers := map[digest.Digest][][]imgspecv1.Descriptor{ // Pages of referrers
+		rootDigest:           {{signature}, {sbom}},
+		sbom.Digest:          {{sbomSignature, signature}}, // signature refers to both root and sbom; it is only listed once.
+		signature.Digest:     {{}},
+		sbomSignature.Digest: {{}},
+	}
+	expected := &ReferrerNode{
+		Descriptor: imgspecv1.Descriptor{
+			MediaType:   imgspecv1.MediaTypeImageManifest,
+			Digest:      rootDigest,
+			Size:        int64(len(rootManifest)),
+			Annotations: map[string]string{"org.example": "root"},
+		},
+		Referrers: []ReferrerNode{
+			{Descriptor: signature},
+			{Descriptor: sbom, Referrers: []ReferrerNode{{Descriptor: sbomSignature}}},
+		},
+	}
+	writeIndex := func(rw http.ResponseWriter, descriptors []imgspecv1.Descriptor) {
+		index, err := json.Marshal(imgspecv1.Index{
+			Versioned: specs.Versioned{SchemaVersion: 2},
+			MediaType: imgspecv1.MediaTypeImageIndex,
+			Manifests: descriptors,
+		})
+		require.NoError(t, err)
+		rw.Header().Set("Content-Type", imgspecv1.MediaTypeImageIndex)
+		_, err = rw.Write(index)
+		assert.NoError(t, err)
+	}
+
+	for _, referrersAPI := range []bool{true, false} {
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.Method == http.MethodGet && r.URL.Path == "/v2/":
+				rw.WriteHeader(http.StatusOK)
+			case r.Method == http.MethodGet && r.URL.Path == "/v2/repo/manifests/tag":
+				rw.Header().Set("Content-Type", imgspecv1.MediaTypeImageManifest)
+				_, err := rw.Write(rootManifest)
+				assert.NoError(t, err)
+			case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/v2/repo/referrers/"):
+				if !referrersAPI {
+					rw.WriteHeader(http.StatusNotFound)
+					return
+				}
+				pages, ok := referrers[digest.Digest(strings.TrimPrefix(r.URL.Path, "/v2/repo/referrers/"))]
+				require.True(t, ok)
+				page := 0
+				if r.URL.Query().Get("page") != "" {
+					page = 1
+				}
+				if page+1 < len(pages) {
+					rw.Header().Set("Link", "<"+r.URL.Path+`?page=1>; rel="next"`)
+				}
+				writeIndex(rw, pages[page])
+			case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/v2/repo/manifests/sha256-"):
+				assert.False(t, referrersAPI)
+				subject := digest.Digest(strings.Replace(strings.TrimPrefix(r.URL.Path, "/v2/repo/manifests/"), "-", ":", 1))
+				pages, ok := referrers[subject]
+				if !ok || len(pages[0]) == 0 {
+					rw.Header().Set("Content-Type", "application/json")
+					rw.WriteHeader(http.StatusNotFound)
+					_, err := rw.Write([]byte(`{"errors":[{"code":"MANIFEST_UNKNOWN","message":"manifest unknown"}]}`))
+					assert.NoError(t, err)
+					return
+				}
+				all := []imgspecv1.Descriptor{}
+				for _, page := range pages {
+					all = append(all, page...)
+				}
+				writeIndex(rw, all)
+			default:
+				assert.Failf(t, "Unexpected request", "%v %v", r.Method, r.URL.Path)
+				rw.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer server.Close()
+		registryURL, err := url.Parse(server.URL)
+		require.NoError(t, err)
+
+		ref, err := ParseReference("//" + registryURL.Host + "/repo:tag")
+		require.NoError(t, err)
+		sys := &types.SystemContext{
+			DockerPerHostCertDirPath:    "/this/does/not/exist",
+			DockerInsecureSkipTLSVerify: types.OptionalBoolTrue,
+			DockerDisableManifestCache:  true,
+		}
+		res, err := GetReferrerTree(context.Background(), sys, ref, 0)
+		require.NoError(t, err, referrersAPI)
+		assert.Equal(t, expected, res, referrersAPI)
+
+		// maxDepth limits the nesting of referrers.
+		res, err = GetReferrerTree(context.Background(), sys, ref, 1)
+		require.NoError(t, err, referrersAPI)
+		assert.Equal(t, []ReferrerNode{{Descriptor: signature}, {Descriptor: sbom}}, res.Referrers, referrersAPI)
+	}
+}
@@ -1,6 +1,7 @@
 package docker
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io/fs"
@@ -12,6 +13,7 @@ import (
 
 	"github.com/containers/image/v5/docker/reference"
 	"github.com/containers/image/v5/internal/rootless"
+	"github.com/containers/image/v5/pkg/tlsclientconfig"
 	"github.com/containers/image/v5/types"
 	"github.com/containers/storage/pkg/fileutils"
 	"github.com/containers/storage/pkg/homedir"
@@ -53,6 +55,9 @@ type registryNamespace struct {
 	SigStore               string `yaml:"sigstore"`          // For compatibility, deprecated in favor of Lookaside.
 	SigStoreStaging        string `yaml:"sigstore-staging"`  // For compatibility, deprecated in favor of LookasideStaging.
 	UseSigstoreAttachments *bool  `yaml:"use-sigstore-attachments,omitempty"`
+	// Additional CA certificates trusted when connecting to the registry, in addition to the system ones and those in certs.d.
+	TLSCACertificates     []string `yaml:"tls-ca-certificates,omitempty"`      // Absolute paths to PEM files
+	TLSCACertificatesData string   `yaml:"tls-ca-certificates-data,omitempty"` // Inline PEM data
 }
 
 // lookasideStorageBase is an "opaque" type representing a lookaside Docker signature storage.
@@ -266,6 +271,61 @@ func (config *registryConfiguration) useSigstoreAttachments(ref dockerReference)
 	return false
 }
 
+// config.setupTLSCACertificates adds the CA certificates configured in config for ref to tlsc.
+func (config *registryConfiguration) setupTLSCACertificates(ref dockerReference, tlsc *tls.Config) error {
+	if config.Docker != nil {
+		// Look for a full match.
+		identity := ref.PolicyConfigurationIdentity()
+		if ns, ok := config.Docker[identity]; ok && ns.hasTLSCACertificates() {
+			logrus.Debugf(` TLS CA certificates: using "docker" namespace %s`, identity)
+			return ns.setupTLSCACertificates(tlsc)
+		}
+
+		// Look for a match of the possible parent namespaces.
+		for _, name := range ref.PolicyConfigurationNamespaces() {
+			if ns, ok := config.Docker[name]; ok && ns.hasTLSCACertificates() {
+				logrus.Debugf(` TLS CA certificates: using "docker" namespace %s`, name)
+				return ns.setupTLSCACertificates(tlsc)
+			}
+		}
+	}
+	// Look for a default location
+	if config.DefaultDocker != nil && config.DefaultDocker.hasTLSCACertificates() {
+		logrus.Debugf(` TLS CA certificates: using "default-docker" configuration`)
+		return config.DefaultDocker.setupTLSCACertificates(tlsc)
+	}
+	return nil
+}
+
+// ns.hasTLSCACertificates returns true if ns configures any CA certificates.
+func (ns registryNamespace) hasTLSCACertificates() bool {
+	return len(ns.TLSCACertificates) != 0 || ns.TLSCACertificatesData != ""
+}
+
+// ns.setupTLSCACertificates adds the CA certificates configured in ns to tlsc.
+func (ns registryNamespace) setupTLSCACertificates(tlsc *tls.Config) error {
+	for _, path := range ns.TLSCACertificates {
+		if !filepath.IsAbs(path) {
+			return fmt.Errorf(`"tls-ca-certificates" path %q is not absolute`, path)
+		}
+		logrus.Debugf(`  Using "tls-ca-certificates" %s`, path)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("reading CA certificates: %w", err)
+		}
+		if err := tlsclientconfig.AppendCACertificates(data, tlsc); err != nil {
+			return fmt.Errorf("loading CA certificates from %s: %w", path, err)
+		}
+	}
+	if ns.TLSCACertificatesData != "" {
+		logrus.Debugf(`  Using "tls-ca-certificates-data"`)
+		if err := tlsclientconfig.AppendCACertificates([]byte(ns.TLSCACertificatesData), tlsc); err != nil {
+			return fmt.Errorf(`loading "tls-ca-certificates-data": %w`, err)
+		}
+	}
+	return nil
+}
+
 // ns.signatureTopLevel returns an URL string configured in ns for ref, for write access if “write”.
 // or "" if nothing has been configured.
 func (ns registryNamespace) signatureTopLevel(write bool) string {
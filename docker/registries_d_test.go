@@ -1,6 +1,8 @@
 package docker
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"net/url"
 	"os"
@@ -271,6 +273,63 @@ func TestRegistryConfigurationSignatureTopLevel(t *testing.T) {
 	assert.Equal(t, "", res)
 }
 
+func TestRegistryConfigurationSetupTLSCACertificates(t *testing.T) {
+	ca1Path, err := filepath.Abs("../pkg/tlsclientconfig/testdata/full/ca-cert-1.crt")
+	require.NoError(t, err)
+	ca1, err := os.ReadFile(ca1Path)
+	require.NoError(t, err)
+	ca2, err := os.ReadFile("../pkg/tlsclientconfig/testdata/full/ca-cert-2.crt")
+	require.NoError(t, err)
+
+	config := registryConfiguration{
+		DefaultDocker: &registryNamespace{TLSCACertificates: []string{ca1Path}},
+		Docker: map[string]registryNamespace{
+			"example.com":         {Lookaside: "https://lookaside.example.com"}, // No certificates, does not affect the result
+			"example.com/ns1":     {TLSCACertificatesData: string(ca2)},
+			"example.com/ns1/ns2": {TLSCACertificates: []string{ca1Path}, TLSCACertificatesData: string(ca2)},
+		},
+	}
+	for _, c := range []struct {
+		input    string
+		expected [][]byte
+	}{
+		{"example.com/ns1/ns2/repo", [][]byte{ca1, ca2}},
+		{"example.com/ns1/repo", [][]byte{ca2}},
+		{"example.com/repo", [][]byte{ca1}},
+		{"unknown.example.com/repo", [][]byte{ca1}},
+	} {
+		tlsc := tls.Config{}
+		err := config.setupTLSCACertificates(dockerRefFromString(t, "//"+c.input), &tlsc)
+		require.NoError(t, err, c.input)
+		expected, err := x509.SystemCertPool()
+		require.NoError(t, err)
+		for _, cert := range c.expected {
+			ok := expected.AppendCertsFromPEM(cert)
+			require.True(t, ok)
+		}
+		assert.True(t, expected.Equal(tlsc.RootCAs), c.input)
+	}
+
+	// Nothing configured
+	config = registryConfiguration{Docker: map[string]registryNamespace{}}
+	tlsc := tls.Config{}
+	err = config.setupTLSCACertificates(dockerRefFromString(t, "//example.com/repo"), &tlsc)
+	require.NoError(t, err)
+	assert.Nil(t, tlsc.RootCAs)
+
+	// Invalid configuration
+	for _, ns := range []registryNamespace{
+		{TLSCACertificates: []string{"relative/path.crt"}},
+		{TLSCACertificates: []string{"/this/does/not/exist.crt"}},
+		{TLSCACertificatesData: "this is not PEM"},
+	} {
+		config = registryConfiguration{DefaultDocker: &ns}
+		tlsc := tls.Config{}
+		err := config.setupTLSCACertificates(dockerRefFromString(t, "//example.com/repo"), &tlsc)
+		assert.Error(t, err, fmt.Sprintf("%#v", ns))
+	}
+}
+
 func TestRegistryNamespaceSignatureTopLevel(t *testing.T) {
 	for _, c := range []struct {
 		ns         registryNamespace
@@ -0,0 +1,50 @@
+package docker
+
+import (
+	"net/http"
+
+	"github.com/containers/image/v5/types"
+)
+
+// registryBackend executes requests of the Docker Registry HTTP API V2 for a single registry.
+type registryBackend interface {
+	// do executes req, and returns the response.
+	do(req *http.Request) (*http.Response, error)
+	// authenticatesRequests returns true if the backend authenticates requests itself, so they must be sent without credentials.
+	authenticatesRequests() bool
+}
+
+// httpRegistryBackend is the default registryBackend, sending requests over HTTP(S).
+type httpRegistryBackend struct {
+	client *http.Client
+}
+
+func (b httpRegistryBackend) do(req *http.Request) (*http.Response, error) {
+	return b.client.Do(req)
+}
+
+func (b httpRegistryBackend) authenticatesRequests() bool {
+	return false
+}
+
+// externalRegistryBackend is a registryBackend using a types.DockerRegistryBackend provided by the caller.
+type externalRegistryBackend struct {
+	registry string
+	backend  types.DockerRegistryBackend
+}
+
+func (b externalRegistryBackend) do(req *http.Request) (*http.Response, error) {
+	return b.backend.Do(b.registry, req)
+}
+
+func (b externalRegistryBackend) authenticatesRequests() bool {
+	return true
+}
+
+// newRegistryBackend returns a registryBackend for registry, as configured by sys, using client for HTTP(S) requests.
+func newRegistryBackend(sys *types.SystemContext, registry string, client *http.Client) registryBackend {
+	if sys != nil && sys.DockerRegistryBackend != nil {
+		return externalRegistryBackend{registry: registry, backend: sys.DockerRegistryBackend}
+	}
+	return httpRegistryBackend{client: client}
+}
@@ -0,0 +1,261 @@
+package docker
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/containers/image/v5/types"
+	"github.com/containers/storage/pkg/ioutils"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/sirupsen/logrus"
+)
+
+// tokenCacheMaxEntries is the maximum number of tokens held by a memoryTokenCache.
+const tokenCacheMaxEntries = 1024
+
+// cachedToken is a token recorded in a memoryTokenCache, or on disk by a dirTokenCache.
+type cachedToken struct {
+	Token      string    `json:"token"`
+	Expiration time.Time `json:"expiration"`
+}
+
+// memoryTokenCache is a bounded in-memory types.DockerTokenCache.
+type memoryTokenCache struct {
+	mutex      sync.Mutex
+	entries    map[string]cachedToken
+	maxEntries int
+}
+
+// processTokenCache is shared by all dockerClient instances in this process, unless configured otherwise.
+var processTokenCache = newMemoryTokenCache(tokenCacheMaxEntries)
+
+// newMemoryTokenCache returns an empty memoryTokenCache holding at most maxEntries tokens.
+func newMemoryTokenCache(maxEntries int) *memoryTokenCache {
+	return &memoryTokenCache{
+		entries:    map[string]cachedToken{},
+		maxEntries: maxEntries,
+	}
+}
+
+// GetToken returns the token recorded for key, and the time it expires, if any.
+func (mc *memoryTokenCache) GetToken(key string) (string, time.Time, bool) {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	t, ok := mc.entries[key]
+	if !ok {
+		return "", time.Time{}, false
+	}
+	return t.Token, t.Expiration, true
+}
+
+// PutToken records token, which expires at expiration, for key.
+func (mc *memoryTokenCache) PutToken(key string, token string, expiration time.Time) {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+
+	mc.entries[key] = cachedToken{Token: token, Expiration: expiration}
+	if len(mc.entries) <= mc.maxEntries {
+		return
+	}
+	now := time.Now()
+	for k, t := range mc.entries {
+		if now.After(t.Expiration) {
+			delete(mc.entries, k)
+		}
+	}
+	// If there are still too many unexpired tokens, drop the ones expiring soonest.
+	for len(mc.entries) > mc.maxEntries {
+		var soonest string
+		for k, t := range mc.entries {
+			if soonest == "" || t.Expiration.Before(mc.entries[soonest].Expiration) {
+				soonest = k
+			}
+		}
+		delete(mc.entries, soonest)
+	}
+}
+
+// dirTokenCache is a types.DockerTokenCache which records tokens in a directory, shared across process invocations,
+// in addition to a memory cache; see types.SystemContext.DockerTokenCacheDir.
+// Failures to read or write the directory are logged and otherwise ignored.
+type dirTokenCache struct {
+	dir    string
+	memory types.DockerTokenCache
+
+	secretMutex sync.Mutex // Protects secret
+	secret      []byte     // The contents of dirTokenCacheSecretFile, or nil if not loaded yet
+}
+
+const (
+	// dirTokenCacheSecretFile is the name of the file within a dirTokenCache directory containing a random secret,
+	// used to derive file names from keys.
+	dirTokenCacheSecretFile = "secret"
+	// dirTokenCacheSecretSize is the size of the contents of dirTokenCacheSecretFile.
+	dirTokenCacheSecretSize = 32
+)
+
+// loadSecret returns the secret used to derive file names in dc.dir, creating it if necessary.
+func (dc *dirTokenCache) loadSecret() ([]byte, error) {
+	dc.secretMutex.Lock()
+	defer dc.secretMutex.Unlock()
+
+	if dc.secret != nil {
+		return dc.secret, nil
+	}
+	path := filepath.Join(dc.dir, dirTokenCacheSecretFile)
+	secret, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		secret = make([]byte, dirTokenCacheSecretSize)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("generating token cache secret: %w", err)
+		}
+		if err := os.MkdirAll(dc.dir, 0o700); err != nil {
+			return nil, fmt.Errorf("creating token cache directory: %w", err)
+		}
+		// Don’t use AtomicWriteFile: if another process has created the secret concurrently, we must use that one
+		// instead of replacing it. So, write a complete file under a temporary name, and only link it if path does not exist.
+		tmpPath := path + ".tmp." + hex.EncodeToString(secret[:8])
+		if err := os.WriteFile(tmpPath, secret, 0o600); err != nil {
+			return nil, fmt.Errorf("writing token cache secret: %w", err)
+		}
+		err = os.Link(tmpPath, path)
+		if removeErr := os.Remove(tmpPath); removeErr != nil {
+			logrus.Debugf("Error removing temporary token cache secret: %v", removeErr)
+		}
+		if err != nil && !errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("writing token cache secret: %w", err)
+		}
+		secret, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading token cache secret: %w", err)
+	}
+	if len(secret) != dirTokenCacheSecretSize {
+		return nil, fmt.Errorf("invalid token cache secret %q: unexpected size %d", path, len(secret))
+	}
+	dc.secret = secret
+	return secret, nil
+}
+
+// tokenPath returns the path of the file recording the token for key.
+// Keys are derived from credentials, so file names are derived from keys using a HMAC with a secret, to prevent
+// guessing the credentials by anyone who can list the directory.
+func (dc *dirTokenCache) tokenPath(key string) (string, error) {
+	secret, err := dc.loadSecret()
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(key)) // hash.Hash.Write never fails
+	return filepath.Join(dc.dir, hex.EncodeToString(mac.Sum(nil))+".json"), nil
+}
+
+// GetToken returns the token recorded for key, and the time it expires, if any.
+func (dc *dirTokenCache) GetToken(key string) (string, time.Time, bool) {
+	if token, expiration, ok := dc.memory.GetToken(key); ok && time.Now().Before(expiration) {
+		return token, expiration, true
+	}
+	path, err := dc.tokenPath(key)
+	if err != nil {
+		logrus.Debugf("Error reading token cache entry: %v", err)
+		return "", time.Time{}, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			logrus.Debugf("Error reading token cache entry: %v", err)
+		}
+		return "", time.Time{}, false
+	}
+	var t cachedToken
+	if err := json.Unmarshal(data, &t); err != nil {
+		logrus.Debugf("Error parsing token cache entry %q: %v", path, err)
+		return "", time.Time{}, false
+	}
+	if time.Now().After(t.Expiration) {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			logrus.Debugf("Error removing expired token cache entry: %v", err)
+		}
+		return "", time.Time{}, false
+	}
+	dc.memory.PutToken(key, t.Token, t.Expiration)
+	return t.Token, t.Expiration, true
+}
+
+// PutToken records token, which expires at expiration, for key.
+func (dc *dirTokenCache) PutToken(key string, token string, expiration time.Time) {
+	dc.memory.PutToken(key, token, expiration)
+	data, err := json.Marshal(cachedToken{Token: token, Expiration: expiration})
+	if err != nil {
+		logrus.Debugf("Error encoding token cache entry: %v", err)
+		return
+	}
+	path, err := dc.tokenPath(key) // This creates dc.dir if necessary
+	if err != nil {
+		logrus.Debugf("Error writing token cache entry: %v", err)
+		return
+	}
+	if err := ioutils.AtomicWriteFile(path, data, 0o600); err != nil {
+		logrus.Debugf("Error writing token cache entry: %v", err)
+	}
+}
+
+// sharedTokenCache returns the token cache configured by sys, or nil if tokens should not be shared.
+func sharedTokenCache(sys *types.SystemContext) types.DockerTokenCache {
+	switch {
+	case sys == nil:
+		return processTokenCache
+	case sys.DockerDisableSharedTokenCache:
+		return nil
+	case sys.DockerTokenCache != nil:
+		return sys.DockerTokenCache
+	case sys.DockerTokenCacheDir != "":
+		return &dirTokenCache{dir: sys.DockerTokenCacheDir, memory: processTokenCache}
+	default:
+		return processTokenCache
+	}
+}
+
+// sharedTokenCacheKey returns a key identifying a token for scopes obtained from challenge, using auth.
+// The credentials are included, so that tokens are only shared by clients using the same credentials.
+func (c *dockerClient) sharedTokenCacheKey(auth types.DockerAuthConfig, challenge challenge, scopes []authScope) string {
+	scopeStrings := []string{}
+	for _, scope := range scopes {
+		if scope.resourceType != "" && scope.remoteName != "" && scope.actions != "" {
+			scopeStrings = append(scopeStrings, scope.resourceType+":"+scope.remoteName+":"+scope.actions)
+		}
+	}
+	data, err := json.Marshal(struct {
+		Registry      string   `json:"registry"`
+		Realm         string   `json:"realm"`
+		Service       string   `json:"service"`
+		Username      string   `json:"username"`
+		Password      string   `json:"password"`
+		IdentityToken string   `json:"identityToken"`
+		Scopes        []string `json:"scopes"`
+	}{
+		Registry:      c.registry,
+		Realm:         challenge.Parameters["realm"],
+		Service:       challenge.Parameters["service"],
+		Username:      auth.Username,
+		Password:      auth.Password,
+		IdentityToken: auth.IdentityToken,
+		Scopes:        scopeStrings,
+	})
+	if err != nil { // Should never happen
+		return ""
+	}
+	// Hash the data, so that the credentials are not stored in the key.
+	return digest.FromBytes(data).Encoded()
+}
@@ -0,0 +1,193 @@
+package docker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/containers/image/v5/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryTokenCache(t *testing.T) {
+	now := time.Now()
+	mc := newMemoryTokenCache(2)
+	_, _, ok := mc.GetToken("a")
+	assert.False(t, ok)
+
+	mc.PutToken("a", "token-a", now.Add(-time.Minute)) // Already expired
+	mc.PutToken("b", "token-b", now.Add(2*time.Minute))
+	token, expiration, ok := mc.GetToken("a")
+	require.True(t, ok)
+	assert.Equal(t, "token-a", token)
+	assert.True(t, expiration.Equal(now.Add(-time.Minute)))
+
+	// Expired tokens are dropped first
+	mc.PutToken("c", "token-c", now.Add(time.Minute))
+	_, _, ok = mc.GetToken("a")
+	assert.False(t, ok)
+	for _, key := range []string{"b", "c"} {
+		_, _, ok = mc.GetToken(key)
+		assert.True(t, ok, key)
+	}
+	// Then the tokens expiring soonest
+	mc.PutToken("d", "token-d", now.Add(3*time.Minute))
+	_, _, ok = mc.GetToken("c")
+	assert.False(t, ok)
+	for _, key := range []string{"b", "d"} {
+		_, _, ok = mc.GetToken(key)
+		assert.True(t, ok, key)
+	}
+}
+
+func TestDirTokenCache(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "tokens")
+	now := time.Now()
+	dc := &dirTokenCache{dir: dir, memory: newMemoryTokenCache(tokenCacheMaxEntries)}
+	_, _, ok := dc.GetToken("a")
+	assert.False(t, ok)
+
+	dc.PutToken("a", "token-a", now.Add(time.Minute))
+	dc.PutToken("b", "token-b", now.Add(-time.Minute))
+	pathA, err := dc.tokenPath("a")
+	require.NoError(t, err)
+	fi, err := os.Stat(pathA)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+	fi, err = os.Stat(filepath.Join(dir, dirTokenCacheSecretFile))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 3) // The secret, and two tokens
+
+	// A fresh instance (e.g. in another process) reads tokens from disk
+	dc2 := &dirTokenCache{dir: dir, memory: newMemoryTokenCache(tokenCacheMaxEntries)}
+	token, expiration, ok := dc2.GetToken("a")
+	require.True(t, ok)
+	assert.Equal(t, "token-a", token)
+	assert.True(t, expiration.Equal(now.Add(time.Minute)))
+	// Expired tokens are not returned, and are removed
+	_, _, ok = dc2.GetToken("b")
+	assert.False(t, ok)
+	pathB, err := dc.tokenPath("b")
+	require.NoError(t, err)
+	_, err = os.Stat(pathB)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// Invalid entries are ignored
+	pathC, err := dc.tokenPath("c")
+	require.NoError(t, err)
+	err = os.WriteFile(pathC, []byte("this is invalid"), 0o600)
+	require.NoError(t, err)
+	_, _, ok = dc2.GetToken("c")
+	assert.False(t, ok)
+
+	// A different secret (e.g. in a different directory) results in different file names
+	dc3 := &dirTokenCache{dir: filepath.Join(t.TempDir(), "tokens"), memory: newMemoryTokenCache(tokenCacheMaxEntries)}
+	path3A, err := dc3.tokenPath("a")
+	require.NoError(t, err)
+	assert.NotEqual(t, filepath.Base(pathA), filepath.Base(path3A))
+
+	// An invalid secret disables the cache
+	dc4 := &dirTokenCache{dir: t.TempDir(), memory: newMemoryTokenCache(tokenCacheMaxEntries)}
+	err = os.WriteFile(filepath.Join(dc4.dir, dirTokenCacheSecretFile), []byte("short"), 0o600)
+	require.NoError(t, err)
+	dc4.PutToken("a", "token-a", now.Add(time.Minute))
+	entries, err = os.ReadDir(dc4.dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestSharedTokenCache(t *testing.T) {
+	custom := newMemoryTokenCache(1)
+	assert.Equal(t, processTokenCache, sharedTokenCache(nil))
+	assert.Equal(t, processTokenCache, sharedTokenCache(&types.SystemContext{}))
+	assert.Nil(t, sharedTokenCache(&types.SystemContext{DockerDisableSharedTokenCache: true, DockerTokenCache: custom}))
+	assert.Equal(t, custom, sharedTokenCache(&types.SystemContext{DockerTokenCache: custom, DockerTokenCacheDir: "/dir"}))
+	assert.Equal(t, &dirTokenCache{dir: "/dir", memory: processTokenCache}, sharedTokenCache(&types.SystemContext{DockerTokenCacheDir: "/dir"}))
+}
+
+func TestSharedTokenCacheKey(t *testing.T) {
+	ch := challenge{Scheme: "bearer", Parameters: map[string]string{"realm": "https://auth.example.com/token", "service": "registry"}}
+	scopes := []authScope{{resourceType: "repository", remoteName: "repo", actions: "pull"}}
+	c := &dockerClient{registry: "registry.example.com"}
+	auth := types.DockerAuthConfig{Username: "user", Password: "pass"}
+	key := c.sharedTokenCacheKey(auth, ch, scopes)
+	assert.Regexp(t, "^[0-9a-f]{64}$", key)
+	assert.NotContains(t, key, "pass")
+	assert.Equal(t, key, c.sharedTokenCacheKey(auth, ch, scopes))
+
+	for _, c2 := range []struct {
+		registry string
+		auth     types.DockerAuthConfig
+		ch       challenge
+		scopes   []authScope
+	}{
+		{"other.example.com", auth, ch, scopes},
+		{c.registry, types.DockerAuthConfig{Username: "user", Password: "other"}, ch, scopes},
+		{c.registry, types.DockerAuthConfig{Username: "other", Password: "pass"}, ch, scopes},
+		{c.registry, types.DockerAuthConfig{IdentityToken: "pass"}, ch, scopes},
+		{c.registry, types.DockerAuthConfig{}, ch, scopes},
+		{c.registry, auth, challenge{Scheme: "bearer", Parameters: map[string]string{"realm": "https://other.example.com/token", "service": "registry"}}, scopes},
+		{c.registry, auth, challenge{Scheme: "bearer", Parameters: map[string]string{"realm": "https://auth.example.com/token", "service": "other"}}, scopes},
+		{c.registry, auth, ch, []authScope{{resourceType: "repository", remoteName: "repo", actions: "pull,push"}}},
+		{c.registry, auth, ch, append(scopes, authScope{resourceType: "repository", remoteName: "other", actions: "pull"})},
+	} {
+		client := &dockerClient{registry: c2.registry}
+		assert.NotEqual(t, key, client.sharedTokenCacheKey(c2.auth, c2.ch, c2.scopes))
+	}
+}
+
+func TestTokenSharing(t *testing.T) {
+	tokenRequests := 0
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			tokenRequests++
+			_, err := w.Write([]byte(`{"token":"shared-token","expires_in":300}`))
+			require.NoError(t, err)
+		case r.Header.Get("Authorization") != "Bearer shared-token":
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+server.URL+`/token",service="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Path == "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case strings.HasSuffix(r.URL.Path, "/tags/list"):
+			_, err := w.Write([]byte(`{"tags":["latest"]}`))
+			require.NoError(t, err)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	registry := strings.TrimPrefix(server.URL, "http://")
+
+	for _, c := range []struct {
+		name             string
+		sys              types.SystemContext
+		expectedRequests int
+	}{
+		{"process-wide", types.SystemContext{}, 1},
+		{"custom", types.SystemContext{DockerTokenCache: newMemoryTokenCache(tokenCacheMaxEntries)}, 1},
+		{"disabled", types.SystemContext{DockerDisableSharedTokenCache: true}, 2},
+	} {
+		tokenRequests = 0
+		sys := c.sys
+		sys.DockerInsecureSkipTLSVerify = types.OptionalBoolTrue
+		ref, err := ParseReference("//" + registry + "/" + c.name)
+		require.NoError(t, err)
+		for range 2 {
+			tags, err := GetRepositoryTags(context.Background(), &sys, ref)
+			require.NoError(t, err, c.name)
+			assert.Equal(t, []string{"latest"}, tags, c.name)
+		}
+		assert.Equal(t, c.expectedRequests, tokenRequests, c.name)
+	}
+}
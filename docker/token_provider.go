@@ -0,0 +1,50 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/containers/image/v5/types"
+	"github.com/sirupsen/logrus"
+)
+
+// requestAuth returns the credentials to use for requests to the registry: credentials obtained from
+// c.sys.DockerTokenProvider, if set, or c.auth; in both cases, with an OAuth2 refresh token rotated by the token server, if any.
+func (c *dockerClient) requestAuth(ctx context.Context) (types.DockerAuthConfig, error) {
+	c.authLock.Lock()
+	defer c.authLock.Unlock()
+
+	if c.sys != nil && c.sys.DockerTokenProvider != nil &&
+		(c.authOverride == nil || (!c.authOverrideExpiration.IsZero() && !time.Now().Before(c.authOverrideExpiration))) {
+		auth, expiration, err := c.sys.DockerTokenProvider.RegistryCredentials(ctx, c.registry)
+		if err != nil {
+			return types.DockerAuthConfig{}, fmt.Errorf("obtaining credentials for %s: %w", c.registry, err)
+		}
+		logrus.Debugf("Obtained credentials for %s from the token provider, expiring at %v", c.registry, expiration)
+		c.authOverride = &auth
+		c.authOverrideExpiration = expiration
+	}
+	if c.authOverride != nil {
+		return *c.authOverride, nil
+	}
+	return c.auth, nil
+}
+
+// rotateIdentityToken records that the token server has replaced the OAuth2 refresh token oldToken with newToken,
+// so that newToken is used for later requests.
+func (c *dockerClient) rotateIdentityToken(oldToken, newToken string) {
+	c.authLock.Lock()
+	defer c.authLock.Unlock()
+
+	current := c.auth
+	if c.authOverride != nil {
+		current = *c.authOverride
+	}
+	if current.IdentityToken != oldToken { // Credentials have changed in the meantime, e.g. the token provider was called again.
+		return
+	}
+	logrus.Debugf("Using a refresh token rotated by the token server for %s", c.registry)
+	current.IdentityToken = newToken
+	c.authOverride = &current
+}
@@ -0,0 +1,144 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/containers/image/v5/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubTokenProvider is a types.DockerTokenProvider returning auth, expiring after lifetime (if not 0), and counting calls.
+type stubTokenProvider struct {
+	auth     types.DockerAuthConfig
+	lifetime time.Duration
+	err      error
+
+	mutex sync.Mutex
+	calls int
+}
+
+func (p *stubTokenProvider) RegistryCredentials(ctx context.Context, registry string) (types.DockerAuthConfig, time.Time, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.calls++
+	var expiration time.Time
+	if p.lifetime != 0 {
+		expiration = time.Now().Add(p.lifetime)
+	}
+	return p.auth, expiration, p.err
+}
+
+func TestTokenProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "provided" || password != "secret" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.URL.Path == "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case strings.HasSuffix(r.URL.Path, "/tags/list"):
+			_, err := w.Write([]byte(`{"tags":["latest"]}`))
+			require.NoError(t, err)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	registry := strings.TrimPrefix(server.URL, "http://")
+	ref, err := ParseReference("//" + registry + "/repo")
+	require.NoError(t, err)
+
+	// Provided credentials are used instead of DockerAuthConfig
+	provider := &stubTokenProvider{auth: types.DockerAuthConfig{Username: "provided", Password: "secret"}}
+	sys := &types.SystemContext{
+		DockerInsecureSkipTLSVerify: types.OptionalBoolTrue,
+		DockerAuthConfig:            &types.DockerAuthConfig{Username: "static", Password: "wrong"},
+		DockerTokenProvider:         provider,
+	}
+	tags, err := GetRepositoryTags(context.Background(), sys, ref)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"latest"}, tags)
+	assert.Equal(t, 1, provider.calls)
+
+	// Credentials are requested again only after they expire
+	for _, c := range []struct {
+		lifetime      time.Duration
+		expectedCalls int
+	}{
+		{0, 1},
+		{time.Hour, 1},
+		{-time.Second, 3},
+	} {
+		provider := &stubTokenProvider{auth: types.DockerAuthConfig{Username: "provided", Password: "secret"}, lifetime: c.lifetime}
+		client, err := newDockerClient(&types.SystemContext{DockerTokenProvider: provider}, "registry.example.com", "registry.example.com")
+		require.NoError(t, err)
+		for range 3 {
+			auth, err := client.requestAuth(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, provider.auth, auth)
+		}
+		client.Close()
+		assert.Equal(t, c.expectedCalls, provider.calls, c.lifetime)
+	}
+
+	// Provider failures
+	providerErr := errors.New("provider failed")
+	sys.DockerTokenProvider = &stubTokenProvider{err: providerErr}
+	_, err = GetRepositoryTags(context.Background(), sys, ref)
+	assert.ErrorIs(t, err, providerErr)
+}
+
+func TestIdentityTokenRotation(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			err := r.ParseForm()
+			require.NoError(t, err)
+			require.Equal(t, http.MethodPost, r.Method)
+			assert.Equal(t, "refresh_token", r.PostForm.Get("grant_type"))
+			switch r.PostForm.Get("refresh_token") {
+			case "refresh-1":
+				_, err = w.Write([]byte(`{"access_token":"access-1","refresh_token":"refresh-2","expires_in":300}`))
+			case "refresh-2":
+				_, err = w.Write([]byte(`{"access_token":"access-2","expires_in":300}`))
+			default:
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+			require.NoError(t, err)
+		default:
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+server.URL+`/token",service="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+	registry := strings.TrimPrefix(server.URL, "http://")
+
+	client, err := newDockerClient(&types.SystemContext{DockerInsecureSkipTLSVerify: types.OptionalBoolTrue, DockerDisableSharedTokenCache: true},
+		registry, registry)
+	require.NoError(t, err)
+	defer client.Close()
+	client.auth = types.DockerAuthConfig{IdentityToken: "refresh-1"}
+	err = client.detectProperties(context.Background())
+	require.NoError(t, err)
+	ch := challenge{Scheme: "bearer", Parameters: map[string]string{"realm": server.URL + "/token", "service": "test"}}
+
+	for _, expected := range []string{"access-1", "access-2", "access-2"} {
+		auth, err := client.requestAuth(context.Background())
+		require.NoError(t, err)
+		token, err := client.obtainBearerToken(context.Background(), auth, ch, nil)
+		require.NoError(t, err)
+		assert.Equal(t, expected, token.token)
+	}
+	assert.Equal(t, "refresh-1", client.auth.IdentityToken) // The original value is not modified
+}
@@ -0,0 +1,230 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// Headers in a response initiating a blob upload, which advertise the registry’s limits on the length of uploaded chunks.
+	// All chunks except the last one must be at least chunkMinLengthHeader bytes long; no chunk may be longer than chunkMaxLengthHeader bytes.
+	chunkMinLengthHeader = "OCI-Chunk-Min-Length"
+	chunkMaxLengthHeader = "OCI-Chunk-Max-Length"
+
+	// maxBufferedChunkSize is the largest chunk we buffer in memory, unless the registry requires larger chunks.
+	maxBufferedChunkSize = 64 * 1024 * 1024
+)
+
+// uploadChunkSize returns the size of chunks to use for uploading a blob of size (-1 if unknown), given header of the response
+// initiating the upload and the configured chunk size (0 if not configured); or 0 if the blob should be uploaded in a single request.
+func uploadChunkSize(header http.Header, size int64, configured int64) int64 {
+	minLength, maxLength, err := chunkLengthLimits(header)
+	if err != nil {
+		logrus.Debugf("Ignoring chunk length limits: %v", err)
+		minLength, maxLength = 0, 0
+	}
+	if configured <= 0 {
+		if maxLength == 0 || (size != -1 && size <= maxLength) {
+			return 0
+		}
+		return max(min(maxLength, maxBufferedChunkSize), minLength)
+	}
+	chunkSize := configured
+	if maxLength != 0 {
+		chunkSize = min(chunkSize, maxLength)
+	}
+	chunkSize = max(chunkSize, minLength)
+	if size != -1 && size <= chunkSize {
+		return 0
+	}
+	return chunkSize
+}
+
+// chunkLengthLimits returns the minimum and maximum chunk length advertised in header, with 0 meaning no limit.
+func chunkLengthLimits(header http.Header) (int64, int64, error) {
+	maxLength, err := parseChunkLengthHeader(header, chunkMaxLengthHeader)
+	if err != nil {
+		return 0, 0, err
+	}
+	minLength, err := parseChunkLengthHeader(header, chunkMinLengthHeader)
+	if err != nil {
+		return 0, 0, err
+	}
+	if maxLength != 0 && minLength > maxLength {
+		return 0, 0, fmt.Errorf("inconsistent chunk length limits, minimum %d > maximum %d", minLength, maxLength)
+	}
+	return minLength, maxLength, nil
+}
+
+// parseChunkLengthHeader returns the value of the name header in header, or 0 if it is not present.
+func parseChunkLengthHeader(header http.Header, name string) (int64, error) {
+	value := header.Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	res, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || res < 0 {
+		return 0, fmt.Errorf("invalid %s header value %q", name, value)
+	}
+	return res, nil
+}
+
+// uploadBlobInChunks uploads the contents of stream to uploadLocation using a sequence of PATCH requests,
+// each with at most chunkSize bytes, and returns the location to use for completing the upload.
+func (d *dockerImageDestination) uploadBlobInChunks(ctx context.Context, uploadLocation *url.URL, stream io.Reader, chunkSize int64) (*url.URL, error) {
+	buf := make([]byte, chunkSize)
+	offset := int64(0)
+	for {
+		n, err := io.ReadFull(stream, buf)
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, err
+		}
+		if n == 0 {
+			return uploadLocation, nil
+		}
+		uploadLocation, err = d.uploadChunk(ctx, uploadLocation, buf[:n], offset)
+		if err != nil {
+			return nil, err
+		}
+		offset += int64(n)
+		if n < len(buf) {
+			return uploadLocation, nil
+		}
+	}
+}
+
+// uploadChunk uploads chunk, which starts at offset in the blob, to uploadLocation, and returns the location to use for continuing the upload.
+// Failed uploads are retried according to sys.DockerUploadRetryPolicy, resuming from the data the registry has received.
+func (d *dockerImageDestination) uploadChunk(ctx context.Context, uploadLocation *url.URL, chunk []byte, offset int64) (*url.URL, error) {
+	maxAttempts, delay, maxDelay := 1, backoffInitialDelay, backoffMaxDelay
+	if d.c.sys != nil && d.c.sys.DockerUploadRetryPolicy != nil {
+		policy := d.c.sys.DockerUploadRetryPolicy
+		maxAttempts = max(policy.MaxAttempts, 1)
+		if policy.InitialDelay != 0 {
+			delay = policy.InitialDelay
+		}
+		if policy.MaxDelay != 0 {
+			maxDelay = policy.MaxDelay
+		}
+	}
+	end := offset + int64(len(chunk))
+	start := offset // The first byte the registry has not received yet
+	for attempt := 1; ; attempt++ {
+		location, retryDelay, err := d.uploadChunkOnce(ctx, uploadLocation, chunk[start-offset:], start, delay)
+		if err == nil {
+			return location, nil
+		}
+		if retryDelay == 0 || attempt >= maxAttempts {
+			return nil, err
+		}
+		retryDelay = min(retryDelay, maxDelay)
+		logrus.Debugf("Uploading layer chunk at offset %d failed, retrying in %s: %v", start, retryDelay, err)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(retryDelay):
+		}
+		delay = min(delay*2, maxDelay)
+
+		received, location, statusErr := d.uploadStatus(ctx, uploadLocation)
+		if statusErr != nil {
+			logrus.Debugf("Error determining upload status, retrying the chunk: %v", statusErr)
+			continue
+		}
+		if received < offset || received > end {
+			return nil, fmt.Errorf("resuming upload of layer chunk at offset %d: the registry has received %d bytes: %w", offset, received, err)
+		}
+		uploadLocation = location
+		if received == end {
+			return uploadLocation, nil
+		}
+		start = received
+	}
+}
+
+// uploadChunkOnce uploads chunk, which starts at offset in the blob, to uploadLocation, and returns the location to use for continuing the upload.
+// On failure, it also returns a non-zero delay (based on delay, or the registry’s request) if the upload should be retried.
+func (d *dockerImageDestination) uploadChunkOnce(ctx context.Context, uploadLocation *url.URL, chunk []byte, offset int64, delay time.Duration) (*url.URL, time.Duration, error) {
+	logrus.Debugf("Uploading layer chunk at offset %d, length %d", offset, len(chunk))
+	headers := map[string][]string{
+		"Content-Type":  {"application/octet-stream"},
+		"Content-Range": {fmt.Sprintf("%d-%d", offset, offset+int64(len(chunk))-1)},
+	}
+	res, err := d.c.makeRequestToResolvedURL(ctx, http.MethodPatch, uploadLocation, headers, bytes.NewReader(chunk), int64(len(chunk)), v2Auth, nil)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, 0, err
+		}
+		return nil, delay, err
+	}
+	defer res.Body.Close()
+	if !successStatus(res.StatusCode) {
+		err := fmt.Errorf("uploading layer chunk at offset %d: %w", offset, registryHTTPResponseToError(res))
+		switch res.StatusCode {
+		case http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusInternalServerError,
+			http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return nil, parseRetryAfter(res, delay), err
+		default:
+			return nil, 0, err
+		}
+	}
+	location, err := res.Location()
+	if err != nil {
+		return nil, 0, fmt.Errorf("determining upload URL: %w", err)
+	}
+	return location, 0, nil
+}
+
+// uploadStatus returns the number of bytes the registry has received for the upload at uploadLocation,
+// and the location to use for continuing the upload.
+func (d *dockerImageDestination) uploadStatus(ctx context.Context, uploadLocation *url.URL) (int64, *url.URL, error) {
+	res, err := d.c.makeRequestToResolvedURL(ctx, http.MethodGet, uploadLocation, nil, nil, -1, v2Auth, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusNoContent {
+		return 0, nil, fmt.Errorf("determining upload status: %w", registryHTTPResponseToError(res))
+	}
+	location := uploadLocation
+	if res.Header.Get("Location") != "" {
+		if location, err = res.Location(); err != nil {
+			return 0, nil, fmt.Errorf("determining upload URL: %w", err)
+		}
+	}
+	received, err := parseUploadRange(res.Header.Get("Range"))
+	if err != nil {
+		return 0, nil, err
+	}
+	return received, location, nil
+}
+
+// parseUploadRange returns the number of bytes received by the registry, as reported by a Range header value of an upload status response.
+// Note that registries report "0-0" both for an empty upload and for a single received byte; we assume the former.
+func parseUploadRange(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	first, last, ok := strings.Cut(strings.TrimPrefix(value, "bytes="), "-")
+	if !ok || first != "0" {
+		return 0, fmt.Errorf("invalid upload range %q", value)
+	}
+	end, err := strconv.ParseInt(last, 10, 64)
+	if err != nil || end < 0 {
+		return 0, fmt.Errorf("invalid upload range %q", value)
+	}
+	if end == 0 {
+		return 0, nil
+	}
+	return end + 1, nil
+}
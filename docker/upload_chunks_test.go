@@ -0,0 +1,258 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/pkg/blobinfocache/none"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadChunkSize(t *testing.T) {
+	for _, c := range []struct {
+		minLength, maxLength string
+		size                 int64
+		configured           int64
+		expected             int64
+	}{
+		{"", "", -1, 0, 0},                                    // No limits
+		{"", "100", 100, 0, 0},                                // Small enough for a single request
+		{"", "100", 101, 0, 100},                              // Too large
+		{"", "100", -1, 0, 100},                               // Unknown size
+		{"10", "100", -1, 0, 100},                             // Minimum is satisfied
+		{"", "1000000000", -1, 0, maxBufferedChunkSize},       // Chunks we would not buffer
+		{"100000000", "1000000000", -1, 0, 100000000},         // Chunks we would not buffer, but the registry requires
+		{"200", "100", -1, 0, 0},                              // Inconsistent
+		{"", "invalid", -1, 0, 0},                             // Invalid
+		{"invalid", "100", -1, 0, 0},                          // Invalid
+		{"", "-1", -1, 0, 0},                                  // Invalid
+		{"", "0", -1, 0, 0},                                   // No limit
+		{"", "9223372036854775807", 1024 * 1024 * 1024, 0, 0}, // Large limit
+		{"", "", -1, 10, 10},                                  // Configured, unknown size
+		{"", "", 10, 10, 0},                                   // Configured, small enough for a single request
+		{"", "", 11, 10, 10},                                  // Configured, too large
+		{"", "5", -1, 10, 5},                                  // Configured, limited by the registry
+		{"20", "100", -1, 10, 20},                             // Configured, increased to the registry’s minimum
+		{"", "invalid", -1, 10, 10},                           // Configured, invalid limits
+		{"", "", -1, 1000000000, 1000000000},                  // Configured chunks are buffered even if large
+	} {
+		header := http.Header{}
+		if c.minLength != "" {
+			header.Set(chunkMinLengthHeader, c.minLength)
+		}
+		if c.maxLength != "" {
+			header.Set(chunkMaxLengthHeader, c.maxLength)
+		}
+		res := uploadChunkSize(header, c.size, c.configured)
+		assert.Equal(t, c.expected, res, "%#v", c)
+	}
+}
+
+func TestParseUploadRange(t *testing.T) {
+	for _, c := range []struct {
+		value    string
+		expected int64 // -1 if an error is expected
+	}{
+		{"", 0},
+		{"0-0", 0},
+		{"0-9", 10},
+		{"bytes=0-9", 10},
+		{"1-9", -1},
+		{"0-", -1},
+		{"0--1", -1},
+		{"0-x", -1},
+		{"invalid", -1},
+	} {
+		res, err := parseUploadRange(c.value)
+		if c.expected == -1 {
+			assert.Error(t, err, c.value)
+		} else {
+			require.NoError(t, err, c.value)
+			assert.Equal(t, c.expected, res, c.value)
+		}
+	}
+}
+
+// newChunkLimitTestRegistry returns a registry host:port which accepts blob uploads to repo,
+// in chunks of at most chunkMaxLength bytes, and returns the uploaded contents after the upload is completed.
+func newChunkLimitTestRegistry(t *testing.T, chunkMaxLength int) (string, func() ([]byte, []string)) {
+	var mutex sync.Mutex
+	var uploaded bytes.Buffer
+	var ranges []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodPost && r.URL.Path == "/v2/repo/blobs/uploads/":
+			w.Header().Set("Location", "/upload")
+			w.Header().Set(chunkMaxLengthHeader, strconv.Itoa(chunkMaxLength))
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == http.MethodPatch && r.URL.Path == "/upload":
+			body, err := io.ReadAll(r.Body)
+			assert.NoError(t, err)
+			if len(body) > chunkMaxLength {
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+				return
+			}
+			ranges = append(ranges, r.Header.Get("Content-Range"))
+			uploaded.Write(body)
+			w.Header().Set("Location", "/upload")
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == http.MethodPut && r.URL.Path == "/upload":
+			assert.Equal(t, digest.FromBytes(uploaded.Bytes()).String(), r.URL.Query().Get("digest"))
+			w.WriteHeader(http.StatusCreated)
+		default:
+			assert.Failf(t, "Unexpected request", "%v %v", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return strings.TrimPrefix(s.URL, "http://"), func() ([]byte, []string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return uploaded.Bytes(), ranges
+	}
+}
+
+func TestPutBlobWithChunkLimits(t *testing.T) {
+	for _, c := range []struct {
+		blob     string
+		size     int64
+		expected []string
+	}{
+		{"0123456789", -1, []string{"0-3", "4-7", "8-9"}},
+		{"0123456789", 10, []string{"0-3", "4-7", "8-9"}},
+		{"01234567", -1, []string{"0-3", "4-7"}},
+		{"0123", 4, []string{""}}, // Small enough for a single request without a Content-Range
+	} {
+		registry, uploaded := newChunkLimitTestRegistry(t, 4)
+		ref, err := ParseReference("//" + registry + "/repo:latest")
+		require.NoError(t, err)
+		dest, err := newImageDestination(&types.SystemContext{DockerInsecureSkipTLSVerify: types.OptionalBoolTrue}, ref.(dockerReference))
+		require.NoError(t, err)
+		defer dest.Close()
+
+		res, err := dest.PutBlobWithOptions(context.Background(), strings.NewReader(c.blob), types.BlobInfo{Size: c.size}, private.PutBlobOptions{Cache: none.NoCache})
+		require.NoError(t, err, c.blob)
+		assert.Equal(t, digest.FromString(c.blob), res.Digest)
+		assert.Equal(t, int64(len(c.blob)), res.Size)
+		contents, ranges := uploaded()
+		assert.Equal(t, []byte(c.blob), contents)
+		assert.Equal(t, c.expected, ranges)
+	}
+}
+
+// newFlakyUploadTestRegistry returns a registry host:port which accepts chunked blob uploads to repo, but fails the PATCH requests
+// listed in failures (counted from 1) with failureStatus, after receiving only the first half of their data.
+// It returns the uploaded contents, and the Content-Range values of all PATCH requests, after the upload is completed.
+func newFlakyUploadTestRegistry(t *testing.T, failures []int, failureStatus int) (string, func() ([]byte, []string)) {
+	var mutex sync.Mutex
+	var uploaded bytes.Buffer
+	var ranges []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodPost && r.URL.Path == "/v2/repo/blobs/uploads/":
+			w.Header().Set("Location", "/upload")
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == http.MethodGet && r.URL.Path == "/upload":
+			w.Header().Set("Location", "/upload")
+			if uploaded.Len() != 0 {
+				w.Header().Set("Range", "0-"+strconv.Itoa(uploaded.Len()-1))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodPatch && r.URL.Path == "/upload":
+			body, err := io.ReadAll(r.Body)
+			assert.NoError(t, err)
+			contentRange := r.Header.Get("Content-Range")
+			ranges = append(ranges, contentRange)
+			if !strings.HasPrefix(contentRange, strconv.Itoa(uploaded.Len())+"-") {
+				w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+				return
+			}
+			if slices.Contains(failures, len(ranges)) {
+				uploaded.Write(body[:len(body)/2])
+				w.WriteHeader(failureStatus)
+				return
+			}
+			uploaded.Write(body)
+			w.Header().Set("Location", "/upload")
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == http.MethodPut && r.URL.Path == "/upload":
+			assert.Equal(t, digest.FromBytes(uploaded.Bytes()).String(), r.URL.Query().Get("digest"))
+			w.WriteHeader(http.StatusCreated)
+		default:
+			assert.Failf(t, "Unexpected request", "%v %v", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return strings.TrimPrefix(s.URL, "http://"), func() ([]byte, []string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return uploaded.Bytes(), ranges
+	}
+}
+
+func TestPutBlobWithRetries(t *testing.T) {
+	const blob = "0123456789"
+	for _, c := range []struct {
+		name          string
+		failures      []int
+		failureStatus int
+		policy        *types.DockerRetryPolicy
+		success       bool
+		expected      []string
+	}{
+		{"no failures", nil, http.StatusServiceUnavailable, nil, true, []string{"0-3", "4-7", "8-9"}},
+		{"no retries", []int{2}, http.StatusServiceUnavailable, nil, false, []string{"0-3", "4-7"}},
+		{"resumed", []int{2}, http.StatusServiceUnavailable, &types.DockerRetryPolicy{MaxAttempts: 3, InitialDelay: time.Millisecond},
+			true, []string{"0-3", "4-7", "6-7", "8-9"}},
+		{"resumed twice", []int{2, 3}, http.StatusBadGateway, &types.DockerRetryPolicy{MaxAttempts: 3, InitialDelay: time.Millisecond},
+			true, []string{"0-3", "4-7", "6-7", "7-7", "8-9"}},
+		{"too many failures", []int{2, 3, 4}, http.StatusServiceUnavailable, &types.DockerRetryPolicy{MaxAttempts: 3, InitialDelay: time.Millisecond},
+			false, []string{"0-3", "4-7", "6-7", "7-7"}},
+		{"not retryable", []int{2}, http.StatusBadRequest, &types.DockerRetryPolicy{MaxAttempts: 3, InitialDelay: time.Millisecond},
+			false, []string{"0-3", "4-7"}},
+	} {
+		registry, uploaded := newFlakyUploadTestRegistry(t, c.failures, c.failureStatus)
+		ref, err := ParseReference("//" + registry + "/repo:latest")
+		require.NoError(t, err)
+		dest, err := newImageDestination(&types.SystemContext{
+			DockerInsecureSkipTLSVerify: types.OptionalBoolTrue,
+			DockerUploadChunkSize:       4,
+			DockerUploadRetryPolicy:     c.policy,
+		}, ref.(dockerReference))
+		require.NoError(t, err)
+		defer dest.Close()
+
+		res, err := dest.PutBlobWithOptions(context.Background(), strings.NewReader(blob), types.BlobInfo{Size: -1}, private.PutBlobOptions{Cache: none.NoCache})
+		contents, ranges := uploaded()
+		if c.success {
+			require.NoError(t, err, c.name)
+			assert.Equal(t, digest.FromString(blob), res.Digest, c.name)
+			assert.Equal(t, []byte(blob), contents, c.name)
+		} else {
+			assert.Error(t, err, c.name)
+		}
+		assert.Equal(t, c.expected, ranges, c.name)
+	}
+}
@@ -0,0 +1,29 @@
+package image
+
+import (
+	"context"
+
+	"github.com/containers/image/v5/internal/image"
+	"github.com/containers/image/v5/types"
+)
+
+// ArtifactInfo describes a non-image OCI artifact, e.g. a Helm chart, a WASM module, or an SBOM.
+type ArtifactInfo = image.ArtifactInfo
+
+// InspectArtifact returns information about unparsed, if it is a non-image OCI artifact:
+// an OCI manifest with a config which is not an OCI image config, and layers which are opaque blobs.
+// For such artifacts, image-specific operations of types.Image (e.g. OCIConfig or Inspect) fail with manifest.NonImageArtifactError;
+// InspectArtifact does not read the config or check any image-specific data (e.g. platform or history).
+//
+// The first return value is nil if unparsed is a container image.
+// If unparsed is a manifest list, the instance is chosen as in FromUnparsedImage.
+func InspectArtifact(ctx context.Context, sys *types.SystemContext, unparsed *UnparsedImage) (*ArtifactInfo, error) {
+	img, err := image.FromUnparsedImage(ctx, sys, unparsed)
+	if err != nil {
+		return nil, err
+	}
+	if !img.IsArtifact() {
+		return nil, nil
+	}
+	return img.ArtifactInfo()
+}
@@ -20,6 +20,18 @@ func UnparsedInstance(src types.ImageSource, instanceDigest *digest.Digest) *Unp
 	return image.UnparsedInstance(src, instanceDigest)
 }
 
+// ManifestRepair is a deviation from the manifest format repaired by LenientUnparsedInstance.
+type ManifestRepair = image.ManifestRepair
+
+// LenientUnparsedInstance is like UnparsedInstance, but the manifest returned by Manifest has well-understood deviations
+// from the manifest format (e.g. emitted by some old registries) repaired; the repairs can be listed using ManifestRepairs.
+//
+// The manifest digest is verified against the original manifest, but the repaired manifest has a different digest,
+// so signatures of the original manifest can not be verified against the UnparsedImage.
+func LenientUnparsedInstance(src types.ImageSource, instanceDigest *digest.Digest) *UnparsedImage {
+	return image.LenientUnparsedInstance(src, instanceDigest)
+}
+
 // unparsedWithRef wraps a private.UnparsedImage, claiming another replacementRef
 type unparsedWithRef struct {
 	private.UnparsedImage
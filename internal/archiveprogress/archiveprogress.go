@@ -0,0 +1,135 @@
+// Package archiveprogress reports progress of writing and extracting tar archives,
+// as requested by types.SystemContext.ArchiveProgress.
+package archiveprogress
+
+import (
+	"io"
+	"time"
+
+	"github.com/containers/image/v5/types"
+)
+
+// Reporter reports progress of writing or extracting a single tar archive.
+// A nil *Reporter is valid, and does not report anything.
+type Reporter struct {
+	channel       chan<- types.ProgressProperties
+	interval      time.Duration
+	progressEvent types.ProgressEvent
+	doneEvent     types.ProgressEvent
+
+	lastUpdate   time.Time
+	offset       uint64
+	offsetUpdate uint64
+	entries      tarEntryCounter
+	entryPath    string // Set by SetEntry
+	entryOffset  uint64
+	entrySize    uint64
+}
+
+// NewPacking returns a Reporter for writing an archive, or nil if sys does not request reporting progress.
+func NewPacking(sys *types.SystemContext) *Reporter {
+	return newReporter(sys, types.ProgressEventArchivePacking, types.ProgressEventArchivePacked)
+}
+
+// NewUnpacking returns a Reporter for extracting an archive, or nil if sys does not request reporting progress.
+func NewUnpacking(sys *types.SystemContext) *Reporter {
+	return newReporter(sys, types.ProgressEventArchiveUnpacking, types.ProgressEventArchiveUnpacked)
+}
+
+// newReporter returns a Reporter using progressEvent and doneEvent, or nil if sys does not request reporting progress.
+func newReporter(sys *types.SystemContext, progressEvent, doneEvent types.ProgressEvent) *Reporter {
+	if sys == nil || sys.ArchiveProgress == nil || sys.ArchiveProgressInterval <= 0 {
+		return nil
+	}
+	return &Reporter{
+		channel:       sys.ArchiveProgress,
+		interval:      sys.ArchiveProgressInterval,
+		progressEvent: progressEvent,
+		doneEvent:     doneEvent,
+		lastUpdate:    time.Now(),
+	}
+}
+
+// Reader returns a reader which reads the uncompressed tar stream from source, and reports its progress.
+func (r *Reporter) Reader(source io.Reader) io.Reader {
+	if r == nil {
+		return source
+	}
+	return &reader{reporter: r, source: source}
+}
+
+// Writer returns a writer which writes the uncompressed tar stream to dest, and reports its progress.
+func (r *Reporter) Writer(dest io.Writer) io.Writer {
+	if r == nil {
+		return dest
+	}
+	return &writer{reporter: r, dest: dest}
+}
+
+// Done reports that processing the archive has finished.
+func (r *Reporter) Done() {
+	if r == nil {
+		return
+	}
+	r.send(r.doneEvent)
+}
+
+// SetEntry records that offset bytes of contents of an archive entry at path, of size, have been processed,
+// for writers which can provide this information; the values are included in the following reports.
+func (r *Reporter) SetEntry(path string, offset, size uint64) {
+	if r == nil {
+		return
+	}
+	r.entryPath = path
+	r.entryOffset = offset
+	r.entrySize = size
+}
+
+// processed records that data was processed, and reports progress if r.interval has passed.
+func (r *Reporter) processed(data []byte) {
+	r.entries.update(data)
+	r.offset += uint64(len(data))
+	r.offsetUpdate += uint64(len(data))
+	if time.Since(r.lastUpdate) > r.interval {
+		r.send(r.progressEvent)
+	}
+}
+
+// send sends event with the current state to r.channel.
+func (r *Reporter) send(event types.ProgressEvent) {
+	r.channel <- types.ProgressProperties{
+		Event:              event,
+		Offset:             r.offset,
+		OffsetUpdate:       r.offsetUpdate,
+		ArchiveEntries:     r.entries.entries,
+		ArchiveEntryPath:   r.entryPath,
+		ArchiveEntryOffset: r.entryOffset,
+		ArchiveEntrySize:   r.entrySize,
+	}
+	r.lastUpdate = time.Now()
+	r.offsetUpdate = 0
+}
+
+// reader is an io.Reader returned by Reporter.Reader.
+type reader struct {
+	reporter *Reporter
+	source   io.Reader
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	n, err := r.source.Read(p)
+	r.reporter.processed(p[:n])
+	return n, err
+}
+
+// writer is an io.Writer returned by Reporter.Writer.
+type writer struct {
+	reporter *Reporter
+	dest     io.Writer
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	n, err := w.dest.Write(p)
+	w.reporter.processed(p[:n])
+	return n, err
+}
@@ -0,0 +1,138 @@
+package archiveprogress
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/containers/image/v5/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// makeTar returns a tar archive with the specified entries, and their contents.
+func makeTar(t *testing.T, entries map[string]string) []byte {
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	for name, contents := range entries {
+		err := w.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(contents))})
+		require.NoError(t, err)
+		_, err = w.Write([]byte(contents))
+		require.NoError(t, err)
+	}
+	err := w.WriteHeader(&tar.Header{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0o755})
+	require.NoError(t, err)
+	err = w.Close()
+	require.NoError(t, err)
+	return buf.Bytes()
+}
+
+func TestTarEntryCounter(t *testing.T) {
+	archive := makeTar(t, map[string]string{
+		"empty":                      "",
+		"small":                      "contents",
+		"block":                      strings.Repeat("x", tarBlockSize),
+		strings.Repeat("long-", 100): strings.Repeat("y", 3*tarBlockSize+1), // Uses a PAX header
+	})
+	for _, chunkSize := range []int{1, 7, tarBlockSize, len(archive)} {
+		c := tarEntryCounter{}
+		for data := archive; len(data) > 0; {
+			n := min(chunkSize, len(data))
+			c.update(data[:n])
+			data = data[n:]
+		}
+		assert.Equal(t, uint64(5), c.entries, chunkSize)
+		assert.True(t, c.ended, chunkSize)
+	}
+
+	// Data which is not a tar stream is not counted
+	c := tarEntryCounter{}
+	c.update(bytes.Repeat([]byte("not a tar archive"), 100))
+	assert.Equal(t, uint64(0), c.entries)
+	assert.True(t, c.ended)
+}
+
+func TestParseTarNumber(t *testing.T) {
+	for _, c := range []struct {
+		input    []byte
+		expected int64
+		ok       bool
+	}{
+		{[]byte("00000000000\x00"), 0, true},
+		{[]byte("00000001750\x00"), 1000, true},
+		{[]byte("     1750 \x00\x00"), 1000, true},
+		{[]byte("\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"), 0, true},
+		{[]byte{0x80, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x03, 0xe8}, 1000, true},
+		{[]byte{0xff, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x03, 0xe8}, 0, false}, // Negative
+		{[]byte("invalid\x00\x00\x00\x00\x00"), 0, false},
+	} {
+		res, ok := parseTarNumber(c.input)
+		assert.Equal(t, c.ok, ok, "%q", c.input)
+		if c.ok {
+			assert.Equal(t, c.expected, res, "%q", c.input)
+		}
+	}
+}
+
+func TestReporter(t *testing.T) {
+	// Not requested
+	for _, sys := range []*types.SystemContext{
+		nil,
+		{},
+		{ArchiveProgress: make(chan types.ProgressProperties)},
+		{ArchiveProgressInterval: time.Second},
+	} {
+		r := NewPacking(sys)
+		assert.Nil(t, r)
+		source := bytes.NewReader(nil)
+		assert.Equal(t, source, r.Reader(source))
+		var dest bytes.Buffer
+		assert.Equal(t, &dest, r.Writer(&dest))
+		r.Done() // Does not crash
+	}
+
+	archive := makeTar(t, map[string]string{"file": "contents"})
+	channel := make(chan types.ProgressProperties, 100)
+	sys := &types.SystemContext{ArchiveProgress: channel, ArchiveProgressInterval: time.Nanosecond}
+
+	r := NewUnpacking(sys)
+	res, err := io.ReadAll(r.Reader(bytes.NewReader(archive)))
+	require.NoError(t, err)
+	assert.Equal(t, archive, res)
+	r.Done()
+
+	r = NewPacking(sys)
+	var dest bytes.Buffer
+	_, err = io.Copy(r.Writer(&dest), bytes.NewReader(archive))
+	require.NoError(t, err)
+	assert.Equal(t, archive, dest.Bytes())
+	r.Done()
+	close(channel)
+
+	events := []types.ProgressProperties{}
+	for e := range channel {
+		events = append(events, e)
+	}
+	unpackedIndex := -1
+	for i, e := range events {
+		switch {
+		case unpackedIndex == -1 && e.Event == types.ProgressEventArchiveUnpacking:
+		case unpackedIndex == -1 && e.Event == types.ProgressEventArchiveUnpacked:
+			unpackedIndex = i
+			assert.Equal(t, uint64(len(archive)), e.Offset)
+			assert.Equal(t, uint64(2), e.ArchiveEntries)
+		case unpackedIndex != -1 && e.Event == types.ProgressEventArchivePacking:
+		case unpackedIndex != -1 && e.Event == types.ProgressEventArchivePacked:
+			assert.Equal(t, len(events)-1, i)
+			assert.Equal(t, uint64(len(archive)), e.Offset)
+			assert.Equal(t, uint64(2), e.ArchiveEntries)
+		default:
+			assert.Failf(t, "Unexpected event", "%d: %#v", i, e)
+		}
+	}
+	assert.NotEqual(t, -1, unpackedIndex)
+	assert.Equal(t, types.ProgressEventArchivePacked, events[len(events)-1].Event)
+}
@@ -0,0 +1,87 @@
+package archiveprogress
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// tarBlockSize is the size of tar headers, and the unit of padding of entry contents.
+const tarBlockSize = 512
+
+// tarEntryCounter counts entries of a tar stream as it is processed, without buffering the entry contents.
+type tarEntryCounter struct {
+	entries   uint64
+	header    [tarBlockSize]byte
+	headerLen int   // Number of bytes of the current header collected in header
+	skip      int64 // Number of bytes of entry contents, including padding, remaining to be skipped
+	ended     bool  // The end-of-archive marker, or data we don’t understand, was found; we don’t count anything any more.
+}
+
+// update processes the next data of the tar stream.
+func (c *tarEntryCounter) update(data []byte) {
+	for len(data) > 0 && !c.ended {
+		if c.skip > 0 {
+			n := min(c.skip, int64(len(data)))
+			c.skip -= n
+			data = data[n:]
+			continue
+		}
+		n := copy(c.header[c.headerLen:], data)
+		c.headerLen += n
+		data = data[n:]
+		if c.headerLen < tarBlockSize {
+			return
+		}
+		c.headerLen = 0
+		c.processHeader()
+	}
+}
+
+// processHeader processes a complete header in c.header.
+func (c *tarEntryCounter) processHeader() {
+	if c.header == [tarBlockSize]byte{} {
+		c.ended = true
+		return
+	}
+	size, ok := parseTarNumber(c.header[124:136])
+	if !ok {
+		c.ended = true
+		return
+	}
+	c.skip = (size + tarBlockSize - 1) / tarBlockSize * tarBlockSize
+	switch c.header[156] { // Typeflag
+	case 'x', 'g', 'L', 'K': // PAX and GNU headers describing the following entry, not entries by themselves
+	default:
+		c.entries++
+	}
+}
+
+// parseTarNumber parses a numeric field of a tar header, returning false if it is invalid or negative.
+func parseTarNumber(field []byte) (int64, bool) {
+	if len(field) > 0 && field[0]&0x80 != 0 { // GNU base-256 encoding
+		if field[0]&0x40 != 0 {
+			return 0, false // Negative
+		}
+		v := int64(0)
+		for i, b := range field {
+			if i == 0 {
+				b &= 0x7f
+			}
+			if v > math.MaxInt64>>8 {
+				return 0, false
+			}
+			v = v<<8 | int64(b)
+		}
+		return v, true
+	}
+	s := strings.Trim(string(field), " \x00")
+	if s == "" {
+		return 0, true
+	}
+	v, err := strconv.ParseInt(s, 8, 64)
+	if err != nil || v < 0 {
+		return 0, false
+	}
+	return v, true
+}
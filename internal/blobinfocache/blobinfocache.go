@@ -1,6 +1,9 @@
 package blobinfocache
 
 import (
+	"errors"
+	"time"
+
 	"github.com/containers/image/v5/types"
 	digest "github.com/opencontainers/go-digest"
 )
@@ -37,10 +40,35 @@ func (bic *v1OnlyBlobInfoCache) RecordTOCUncompressedPair(tocDigest digest.Diges
 func (bic *v1OnlyBlobInfoCache) RecordDigestCompressorData(anyDigest digest.Digest, data DigestCompressorData) {
 }
 
+func (bic *v1OnlyBlobInfoCache) DigestCompressorData(anyDigest digest.Digest) DigestCompressorData {
+	return DigestCompressorData{
+		BaseVariantCompressor:      UnknownCompression,
+		SpecificVariantCompressor:  UnknownCompression,
+		SpecificVariantAnnotations: nil,
+	}
+}
+
 func (bic *v1OnlyBlobInfoCache) CandidateLocations2(transport types.ImageTransport, scope types.BICTransportScope, digest digest.Digest, options CandidateLocations2Options) []BICReplacementCandidate2 {
 	return nil
 }
 
+func (bic *v1OnlyBlobInfoCache) RecordBlobAbsence(transport types.ImageTransport, scope types.BICTransportScope, digest digest.Digest, location types.BICLocationReference) {
+}
+
+func (bic *v1OnlyBlobInfoCache) BlobKnownAbsent(transport types.ImageTransport, scope types.BICTransportScope, digest digest.Digest, location types.BICLocationReference, maxAge time.Duration) bool {
+	return false
+}
+
+func (bic *v1OnlyBlobInfoCache) PurgeScope(transport types.ImageTransport, scope types.BICTransportScope) error {
+	// A cache may implement purging without implementing the rest of BlobInfoCache2 (e.g. the "none" cache).
+	if purger, ok := bic.BlobInfoCache.(interface {
+		PurgeScope(transport types.ImageTransport, scope types.BICTransportScope) error
+	}); ok {
+		return purger.PurgeScope(transport, scope)
+	}
+	return errors.New("purging records is not supported by this BlobInfoCache implementation")
+}
+
 // CandidateLocationsFromV2 converts a slice of BICReplacementCandidate2 to a slice of
 // types.BICReplacementCandidate, dropping compression information.
 func CandidateLocationsFromV2(v2candidates []BICReplacementCandidate2) []types.BICReplacementCandidate {
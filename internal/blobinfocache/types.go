@@ -1,6 +1,8 @@
 package blobinfocache
 
 import (
+	"time"
+
 	compressiontypes "github.com/containers/image/v5/pkg/compression/types"
 	"github.com/containers/image/v5/types"
 	digest "github.com/opencontainers/go-digest"
@@ -45,10 +47,27 @@ type BlobInfoCache2 interface {
 	// otherwise the cache could be poisoned and cause us to make incorrect edits to type
 	// information in a manifest.
 	RecordDigestCompressorData(anyDigest digest.Digest, data DigestCompressorData)
+	// DigestCompressorData returns data recorded by RecordDigestCompressorData for the blob with the specified digest.
+	// If nothing is known, BaseVariantCompressor and SpecificVariantCompressor are UnknownCompression.
+	DigestCompressorData(anyDigest digest.Digest) DigestCompressorData
 	// CandidateLocations2 returns a prioritized, limited, number of blobs and their locations (if known)
 	// that could possibly be reused within the specified (transport scope) (if they still
 	// exist, which is not guaranteed).
 	CandidateLocations2(transport types.ImageTransport, scope types.BICTransportScope, digest digest.Digest, options CandidateLocations2Options) []BICReplacementCandidate2
+
+	// RecordBlobAbsence records that a blob with the specified digest was found NOT to exist at location
+	// within the specified (transport, scope) scope.
+	// The record is discarded by a later RecordKnownLocation for the same location.
+	RecordBlobAbsence(transport types.ImageTransport, scope types.BICTransportScope, digest digest.Digest, location types.BICLocationReference)
+	// BlobKnownAbsent returns true if a blob with the specified digest was recorded by RecordBlobAbsence as not existing at location
+	// within the specified (transport, scope) scope at most maxAge ago, and its existence was not recorded since.
+	// Implementations which don’t record absence always return false.
+	BlobKnownAbsent(transport types.ImageTransport, scope types.BICTransportScope, digest digest.Digest, location types.BICLocationReference, maxAge time.Duration) bool
+
+	// PurgeScope removes all known locations and absences recorded within the specified (transport, scope) scope,
+	// e.g. after a registry was found to be misconfigured or untrustworthy, so that its records are not used by future copies.
+	// Other information about blobs (e.g. uncompressed digests), which is only recorded for locally verified data, is kept.
+	PurgeScope(transport types.ImageTransport, scope types.BICTransportScope) error
 }
 
 // DigestCompressorData is information known about how a blob is compressed.
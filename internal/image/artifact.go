@@ -0,0 +1,66 @@
+package image
+
+import (
+	"errors"
+	"maps"
+	"time"
+
+	"github.com/containers/image/v5/types"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// ArtifactInfo describes a non-image OCI artifact, e.g. a Helm chart, a WASM module, or an SBOM:
+// an OCI manifest with a config which is not an OCI image config, and layers which are opaque blobs.
+//
+// This is publicly visible as c/image/image.ArtifactInfo.
+type ArtifactInfo struct {
+	// ArtifactType is the artifactType value of the manifest if set, otherwise the media type of the config.
+	ArtifactType string
+	// Config is the config descriptor; for artifacts without a config, it typically refers to the empty descriptor
+	// (imgspecv1.DescriptorEmptyJSON).
+	Config types.BlobInfo
+	// Layers are the blobs of the artifact, in the order they appear in the manifest.
+	Layers []types.BlobInfo
+	// Annotations are the annotations of the manifest.
+	Annotations map[string]string
+	// Created is the creation time in the imgspecv1.AnnotationCreated manifest annotation, or nil if not present or invalid.
+	Created *time.Time
+}
+
+// artifactInfo returns information about m if it is a non-image artifact, or nil if it is an image.
+func artifactInfo(m genericManifest) *ArtifactInfo {
+	oci, ok := m.(*manifestOCI1)
+	if !ok || oci.m.Config.MediaType == imgspecv1.MediaTypeImageConfig {
+		return nil
+	}
+	res := &ArtifactInfo{
+		ArtifactType: oci.m.ArtifactType,
+		Config:       oci.ConfigInfo(),
+		Layers:       oci.LayerInfos(),
+		Annotations:  maps.Clone(oci.m.Annotations),
+	}
+	if res.ArtifactType == "" {
+		res.ArtifactType = oci.m.Config.MediaType
+	}
+	if created, ok := oci.m.Annotations[imgspecv1.AnnotationCreated]; ok {
+		if t, err := time.Parse(time.RFC3339, created); err == nil {
+			res.Created = &t
+		}
+	}
+	return res
+}
+
+// IsArtifact returns true if i is a non-image artifact, for which image-specific operations
+// (e.g. OCIConfig, Inspect, or conversion to other manifest formats) fail with manifest.NonImageArtifactError.
+func (i *SourcedImage) IsArtifact() bool {
+	return artifactInfo(i.genericManifest) != nil
+}
+
+// ArtifactInfo returns information about i, which must be a non-image artifact (see IsArtifact).
+func (i *SourcedImage) ArtifactInfo() (*ArtifactInfo, error) {
+	res := artifactInfo(i.genericManifest)
+	if res == nil {
+		return nil, errors.New("the image is a container image, not a non-image artifact")
+	}
+	return res, nil
+}
@@ -0,0 +1,54 @@
+package image
+
+import (
+	"testing"
+	"time"
+
+	"github.com/containers/image/v5/internal/testing/mocks"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArtifactInfo(t *testing.T) {
+	// Images
+	for _, m := range []genericManifest{
+		manifestOCI1FromFixture(t, mocks.ForbiddenImageSource{}, "oci1.json"),
+		manifestSchema2FromFixture(t, mocks.ForbiddenImageSource{}, "schema2.json", false),
+		manifestSchema1FromFixture(t, "schema1.json"),
+	} {
+		assert.Nil(t, artifactInfo(m))
+		img := &SourcedImage{genericManifest: m}
+		assert.False(t, img.IsArtifact())
+		_, err := img.ArtifactInfo()
+		assert.Error(t, err)
+	}
+
+	// An artifact
+	m := manifestOCI1FromFixture(t, mocks.ForbiddenImageSource{}, "oci1-artifact.json")
+	img := &SourcedImage{genericManifest: m}
+	assert.True(t, img.IsArtifact())
+	info, err := img.ArtifactInfo()
+	require.NoError(t, err)
+	assert.Equal(t, "application/vnd.oci.custom.artifact.config.v1+json", info.ArtifactType)
+	assert.Equal(t, m.ConfigInfo(), info.Config)
+	assert.Equal(t, m.LayerInfos(), info.Layers)
+	assert.Nil(t, info.Annotations)
+	assert.Nil(t, info.Created)
+
+	// artifactType and annotations
+	oci := m.(*manifestOCI1)
+	oci.m.ArtifactType = "application/vnd.example.sbom"
+	oci.m.Annotations = map[string]string{imgspecv1.AnnotationCreated: "2024-01-01T00:00:00Z", "other": "value"}
+	info = artifactInfo(m)
+	require.NotNil(t, info)
+	assert.Equal(t, "application/vnd.example.sbom", info.ArtifactType)
+	assert.Equal(t, oci.m.Annotations, info.Annotations)
+	expectedCreated := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NotNil(t, info.Created)
+	assert.True(t, expectedCreated.Equal(*info.Created))
+	oci.m.Annotations[imgspecv1.AnnotationCreated] = "invalid"
+	info = artifactInfo(m)
+	require.NotNil(t, info)
+	assert.Nil(t, info.Created)
+}
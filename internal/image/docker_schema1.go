@@ -125,6 +125,9 @@ func (m *manifestSchema1) UpdatedImage(ctx context.Context, options types.Manife
 	}
 
 	// No conversion required, update manifest
+	if options.Config != nil {
+		return nil, fmt.Errorf("editing the config of %s images is not supported", manifest.DockerV2Schema1MediaType)
+	}
 	if options.LayerInfos != nil {
 		if err := copy.m.UpdateLayerInfos(options.LayerInfos); err != nil {
 			return nil, err
@@ -225,6 +228,8 @@ func (m *manifestSchema1) convertToManifestSchema2(_ context.Context, options *t
 	if options.LayerInfos != nil {
 		options.LayerInfos = convertedLayerUpdates
 	}
+	// The config was built from InformationOnly.LayerDiffIDs, which already reflect any modified layer contents.
+	options.LayerDiffIDs = nil
 	return manifestSchema2FromComponents(configDescriptor, nil, configJSON, layers), nil
 }
 
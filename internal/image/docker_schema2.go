@@ -167,6 +167,18 @@ func (m *manifestSchema2) UpdatedImage(ctx context.Context, options types.Manife
 		configBlob: m.configBlob,
 		m:          manifest.Schema2Clone(m.m),
 	}
+	if options.LayerDiffIDs != nil {
+		if err := copy.updateLayerDiffIDs(ctx, options.LayerDiffIDs); err != nil {
+			return nil, err
+		}
+		options.LayerDiffIDs = nil // Already done, don’t repeat this after a conversion.
+	}
+	if options.Config != nil {
+		if err := copy.updateConfig(ctx, options.Config); err != nil {
+			return nil, err
+		}
+		options.Config = nil // Already done, don’t repeat this after a conversion.
+	}
 
 	converted, err := convertManifestIfRequiredWithUpdate(ctx, options, map[string]manifestConvertFn{
 		manifest.DockerV2Schema1MediaType:       copy.convertToManifestSchema1,
@@ -192,6 +204,42 @@ func (m *manifestSchema2) UpdatedImage(ctx context.Context, options types.Manife
 	return memoryImageFromManifest(&copy), nil
 }
 
+// updateLayerDiffIDs replaces the DiffIDs in the config of m, which must be a private copy, as requested by types.ManifestUpdateOptions.LayerDiffIDs.
+func (m *manifestSchema2) updateLayerDiffIDs(ctx context.Context, diffIDs []digest.Digest) error {
+	configBlob, err := m.ConfigBlob(ctx)
+	if err != nil {
+		return err
+	}
+	updated, err := configWithUpdatedDiffIDs(configBlob, diffIDs)
+	if err != nil {
+		return err
+	}
+	m.configBlob = updated
+	m.m.ConfigDescriptor.Digest = digest.FromBytes(updated)
+	m.m.ConfigDescriptor.Size = int64(len(updated))
+	return nil
+}
+
+// updateConfig edits the config of m, which must be a private copy, as requested by types.ManifestUpdateOptions.Config.
+func (m *manifestSchema2) updateConfig(ctx context.Context, edited *imgspecv1.Image) error {
+	original, err := m.OCIConfig(ctx)
+	if err != nil {
+		return err
+	}
+	configBlob, err := m.ConfigBlob(ctx)
+	if err != nil {
+		return err
+	}
+	updated, err := configWithEdits(configBlob, original, edited)
+	if err != nil {
+		return err
+	}
+	m.configBlob = updated
+	m.m.ConfigDescriptor.Digest = digest.FromBytes(updated)
+	m.m.ConfigDescriptor.Size = int64(len(updated))
+	return nil
+}
+
 func oci1DescriptorFromSchema2Descriptor(d manifest.Schema2Descriptor) imgspecv1.Descriptor {
 	return imgspecv1.Descriptor{
 		MediaType: d.MediaType,
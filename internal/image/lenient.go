@@ -0,0 +1,200 @@
+package image
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/pkg/blobinfocache/none"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// ManifestRepair is a deviation from the manifest format repaired by lenient manifest parsing.
+//
+// This is publicly visible as c/image/image.ManifestRepair.
+type ManifestRepair struct {
+	ManifestDigest digest.Digest // The digest of the original manifest, as provided by the source
+	Field          string        // The repaired field, e.g. "layers[1].size"
+	Description    string        // A human-readable description of the repair
+}
+
+// knownMIMETypes are the MIME types lenient manifest parsing recognizes regardless of their casing.
+var knownMIMETypes = []string{
+	manifest.DockerV2Schema2MediaType,
+	manifest.DockerV2ListMediaType,
+	manifest.DockerV2Schema2ConfigMediaType,
+	manifest.DockerV2Schema2LayerMediaType,
+	manifest.DockerV2SchemaLayerMediaTypeUncompressed,
+	manifest.DockerV2Schema2ForeignLayerMediaType,
+	manifest.DockerV2Schema2ForeignLayerMediaTypeGzip,
+	imgspecv1.MediaTypeImageManifest,
+	imgspecv1.MediaTypeImageIndex,
+	imgspecv1.MediaTypeImageConfig,
+	imgspecv1.MediaTypeImageLayer,
+	imgspecv1.MediaTypeImageLayerGzip,
+	imgspecv1.MediaTypeImageLayerZstd,
+	imgspecv1.MediaTypeImageLayerNonDistributable,     //nolint:staticcheck // NonDistributable layers are deprecated, but we want to continue to support manipulating pre-existing images.
+	imgspecv1.MediaTypeImageLayerNonDistributableGzip, //nolint:staticcheck // NonDistributable layers are deprecated, but we want to continue to support manipulating pre-existing images.
+	imgspecv1.MediaTypeImageLayerNonDistributableZstd, //nolint:staticcheck // NonDistributable layers are deprecated, but we want to continue to support manipulating pre-existing images.
+}
+
+// canonicalMIMEType returns the recognized MIME type equal to mimeType except for casing, if any.
+func canonicalMIMEType(mimeType string) (string, bool) {
+	for _, t := range knownMIMETypes {
+		if strings.EqualFold(t, mimeType) {
+			return t, true
+		}
+	}
+	return "", false
+}
+
+// manifestRepairer repairs a single manifest.
+type manifestRepairer struct {
+	src            private.ImageSource
+	manifestDigest digest.Digest
+	repairs        []ManifestRepair
+}
+
+// repairManifest returns a version of m, with mimeType, from src, with well-understood deviations from the manifest format repaired:
+//   - media types which differ from a recognized value only in casing
+//   - missing size fields of descriptors, determined by reading the referenced manifest or blob from src
+//
+// If there is nothing to repair, it returns the original m and mimeType, and no repairs.
+func repairManifest(ctx context.Context, src private.ImageSource, m []byte, mimeType string) ([]byte, string, []ManifestRepair, error) {
+	manifestDigest, err := manifest.Digest(m)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	r := manifestRepairer{src: src, manifestDigest: manifestDigest}
+
+	if canonical, ok := canonicalMIMEType(mimeType); ok && canonical != mimeType {
+		r.record("MIME type", "corrected MIME type %q to %q", mimeType, canonical)
+		mimeType = canonical
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(m))
+	decoder.UseNumber()
+	var parsed map[string]any
+	if err := decoder.Decode(&parsed); err != nil {
+		return m, mimeType, r.repairs, nil // Not something we know how to repair; leave reporting the error to the manifest parser.
+	}
+	if schemaVersion, ok := parsed["schemaVersion"].(json.Number); !ok || schemaVersion.String() != "2" {
+		return m, mimeType, r.repairs, nil // Schema 1, or unknown
+	}
+	manifestRepairs := len(r.repairs)
+	r.repairMediaType(parsed, "mediaType")
+	if config, ok := parsed["config"].(map[string]any); ok {
+		if err := r.repairDescriptor(ctx, config, "config", false); err != nil {
+			return nil, "", nil, err
+		}
+	}
+	for _, field := range []string{"layers", "manifests"} {
+		descriptors, ok := parsed[field].([]any)
+		if !ok {
+			continue
+		}
+		for i, d := range descriptors {
+			if d, ok := d.(map[string]any); ok {
+				if err := r.repairDescriptor(ctx, d, fmt.Sprintf("%s[%d]", field, i), field == "manifests"); err != nil {
+					return nil, "", nil, err
+				}
+			}
+		}
+	}
+	if len(r.repairs) == manifestRepairs {
+		return m, mimeType, r.repairs, nil
+	}
+
+	res, err := json.Marshal(parsed)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return res, mimeType, r.repairs, nil
+}
+
+// record records a repair of field.
+func (r *manifestRepairer) record(field string, format string, a ...any) {
+	r.repairs = append(r.repairs, ManifestRepair{
+		ManifestDigest: r.manifestDigest,
+		Field:          field,
+		Description:    fmt.Sprintf(format, a...),
+	})
+}
+
+// repairMediaType repairs the casing of the "mediaType" field of object, if present; path identifies the field in repair records.
+func (r *manifestRepairer) repairMediaType(object map[string]any, path string) {
+	value, ok := object["mediaType"].(string)
+	if !ok {
+		return
+	}
+	if canonical, ok := canonicalMIMEType(value); ok && canonical != value {
+		object["mediaType"] = canonical
+		r.record(path, "corrected media type %q to %q", value, canonical)
+	}
+}
+
+// repairDescriptor repairs descriptor, at path, which refers to a manifest if isManifest, or to a blob otherwise.
+func (r *manifestRepairer) repairDescriptor(ctx context.Context, descriptor map[string]any, path string, isManifest bool) error {
+	r.repairMediaType(descriptor, path+".mediaType")
+
+	if _, ok := descriptor["size"]; ok {
+		return nil
+	}
+	digestString, ok := descriptor["digest"].(string)
+	if !ok {
+		return nil // Not something we know how to repair; leave reporting the error to the manifest parser.
+	}
+	d, err := digest.Parse(digestString)
+	if err != nil {
+		return nil // Not something we know how to repair; leave reporting the error to the manifest parser.
+	}
+	var size int64
+	if isManifest {
+		size, err = r.manifestSize(ctx, d)
+	} else {
+		size, err = r.blobSize(ctx, d)
+	}
+	if err != nil {
+		return fmt.Errorf("determining the missing size of %s: %w", path, err)
+	}
+	descriptor["size"] = size
+	r.record(path+".size", "added missing size %d of %s", size, d)
+	return nil
+}
+
+// manifestSize returns the size of manifest d in r.src.
+func (r *manifestRepairer) manifestSize(ctx context.Context, d digest.Digest) (int64, error) {
+	m, _, err := r.src.GetManifest(ctx, &d)
+	if err != nil {
+		return -1, err
+	}
+	matches, err := manifest.MatchesDigest(m, d)
+	if err != nil {
+		return -1, err
+	}
+	if !matches {
+		return -1, fmt.Errorf("manifest does not match digest %s", d)
+	}
+	return int64(len(m)), nil
+}
+
+// blobSize returns the size of blob d in r.src.
+func (r *manifestRepairer) blobSize(ctx context.Context, d digest.Digest) (int64, error) {
+	stream, size, err := r.src.GetBlob(ctx, types.BlobInfo{Digest: d, Size: -1}, none.NoCache)
+	if err != nil {
+		return -1, err
+	}
+	defer stream.Close()
+	if size != -1 {
+		return size, nil
+	}
+	// The source does not know the size without reading the blob; this can be expensive for layers, but lenient parsing is opt-in.
+	return io.Copy(io.Discard, stream)
+}
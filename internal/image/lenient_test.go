@@ -0,0 +1,110 @@
+package image
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/internal/testing/mocks"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lenientTestImageSource serves a single manifest, and blobs without a known size.
+type lenientTestImageSource struct {
+	mocks.ForbiddenImageSource // We inherit almost all of the methods, which just panic()
+	manifest                   []byte
+	mimeType                   string
+	blobs                      map[digest.Digest][]byte
+}
+
+func (s lenientTestImageSource) Reference() types.ImageReference {
+	return lenientTestImageReference{}
+}
+
+// lenientTestImageReference is a mock of types.ImageReference which only has a transport, and no Docker reference.
+type lenientTestImageReference struct {
+	mocks.ForbiddenImageReference // We inherit almost all of the methods, which just panic()
+}
+
+func (ref lenientTestImageReference) Transport() types.ImageTransport {
+	return mocks.NameImageTransport("==lenient-test")
+}
+
+func (ref lenientTestImageReference) DockerReference() reference.Named {
+	return nil
+}
+
+func (s lenientTestImageSource) GetManifest(_ context.Context, instanceDigest *digest.Digest) ([]byte, string, error) {
+	if instanceDigest != nil {
+		panic("Unexpected instance digest in GetManifest")
+	}
+	return s.manifest, s.mimeType, nil
+}
+
+func (s lenientTestImageSource) GetBlob(_ context.Context, info types.BlobInfo, _ types.BlobInfoCache) (io.ReadCloser, int64, error) {
+	blob, ok := s.blobs[info.Digest]
+	if !ok {
+		panic("Unexpected digest in GetBlob")
+	}
+	return io.NopCloser(bytes.NewReader(blob)), -1, nil
+}
+
+func TestLenientUnparsedInstance(t *testing.T) {
+	config := []byte(`{"architecture":"amd64","os":"linux"}`)
+	layer := []byte("layer contents")
+	configDigest, layerDigest := digest.FromBytes(config), digest.FromBytes(layer)
+	original := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.docker.distribution.manifest.v2+JSON",` +
+		`"config":{"mediaType":"application/vnd.docker.container.image.v1+json","digest":"` + configDigest.String() + `"},` +
+		`"layers":[{"mediaType":"Application/vnd.docker.image.rootfs.diff.tar.gzip","size":14,"digest":"` + layerDigest.String() + `"}]}`)
+	src := lenientTestImageSource{
+		manifest: original,
+		mimeType: "application/vnd.docker.distribution.manifest.v2+JSON",
+		blobs:    map[digest.Digest][]byte{configDigest: config, layerDigest: layer},
+	}
+	originalDigest := digest.FromBytes(original)
+
+	// Without lenient parsing, the manifest is returned unmodified.
+	unparsed := UnparsedInstance(src, nil)
+	m, mimeType, err := unparsed.Manifest(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, original, m)
+	assert.Equal(t, src.mimeType, mimeType)
+	assert.Empty(t, unparsed.ManifestRepairs())
+
+	unparsed = LenientUnparsedInstance(src, nil)
+	m, mimeType, err = unparsed.Manifest(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, manifest.DockerV2Schema2MediaType, mimeType)
+	var parsed manifest.Schema2
+	err = json.Unmarshal(m, &parsed)
+	require.NoError(t, err)
+	assert.Equal(t, manifest.DockerV2Schema2MediaType, parsed.MediaType)
+	assert.Equal(t, int64(len(config)), parsed.ConfigDescriptor.Size)
+	require.Len(t, parsed.LayersDescriptors, 1)
+	assert.Equal(t, manifest.DockerV2Schema2LayerMediaType, parsed.LayersDescriptors[0].MediaType)
+	assert.Equal(t, int64(len(layer)), parsed.LayersDescriptors[0].Size)
+	repairs := unparsed.ManifestRepairs()
+	fields := []string{}
+	for _, r := range repairs {
+		assert.Equal(t, originalDigest, r.ManifestDigest)
+		assert.NotEmpty(t, r.Description)
+		fields = append(fields, r.Field)
+	}
+	assert.Equal(t, []string{"MIME type", "mediaType", "config.size", "layers[0].mediaType"}, fields)
+
+	// A manifest which needs no repairs is returned unmodified.
+	m2, _, err := UnparsedInstance(lenientTestImageSource{manifest: m, mimeType: mimeType}, nil).Manifest(context.Background())
+	require.NoError(t, err)
+	unparsed = LenientUnparsedInstance(lenientTestImageSource{manifest: m2, mimeType: mimeType}, nil)
+	m3, _, err := unparsed.Manifest(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, m2, m3)
+	assert.Empty(t, unparsed.ManifestRepairs())
+}
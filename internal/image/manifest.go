@@ -1,12 +1,15 @@
 package image
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 
 	"github.com/containers/image/v5/docker/reference"
 	"github.com/containers/image/v5/manifest"
 	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
 	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
@@ -119,3 +122,122 @@ func convertManifestIfRequiredWithUpdate(ctx context.Context, options types.Mani
 	optionsCopy.ManifestMIMEType = ""
 	return convertedImage.UpdatedImage(ctx, optionsCopy)
 }
+
+// configWithUpdatedDiffIDs returns configBlob with the rootfs.diff_ids values replaced by the non-empty values of diffIDs,
+// as requested by types.ManifestUpdateOptions.LayerDiffIDs. Other fields of the config are preserved.
+func configWithUpdatedDiffIDs(configBlob []byte, diffIDs []digest.Digest) ([]byte, error) {
+	var config map[string]json.RawMessage
+	if err := json.Unmarshal(configBlob, &config); err != nil {
+		return nil, fmt.Errorf("parsing image config: %w", err)
+	}
+	var rootFS map[string]json.RawMessage
+	if err := json.Unmarshal(config["rootfs"], &rootFS); err != nil || rootFS == nil {
+		return nil, fmt.Errorf("parsing rootfs of image config: %w", err)
+	}
+	var configDiffIDs []digest.Digest
+	if err := json.Unmarshal(rootFS["diff_ids"], &configDiffIDs); err != nil {
+		return nil, fmt.Errorf("parsing DiffIDs of image config: %w", err)
+	}
+	if len(configDiffIDs) != len(diffIDs) {
+		return nil, fmt.Errorf("updating DiffIDs of image config: %d DiffID updates vs. %d existing DiffIDs", len(diffIDs), len(configDiffIDs))
+	}
+	for i, d := range diffIDs {
+		if d != "" {
+			configDiffIDs[i] = d
+		}
+	}
+	updated, err := json.Marshal(configDiffIDs)
+	if err != nil {
+		return nil, err
+	}
+	rootFS["diff_ids"] = updated
+	if config["rootfs"], err = json.Marshal(rootFS); err != nil {
+		return nil, err
+	}
+	return json.Marshal(config)
+}
+
+// configWithEdits returns configBlob, parsed by the caller as original, with the values edited in edited,
+// as requested by types.ManifestUpdateOptions.Config. Other fields of the config, including those not represented
+// in imgspecv1.Image, are preserved; RootFS is never modified.
+func configWithEdits(configBlob []byte, original, edited *imgspecv1.Image) ([]byte, error) {
+	var config map[string]json.RawMessage
+	if err := json.Unmarshal(configBlob, &config); err != nil {
+		return nil, fmt.Errorf("parsing image config: %w", err)
+	}
+	originalFields, err := jsonFields(original)
+	if err != nil {
+		return nil, err
+	}
+	editedFields, err := jsonFields(edited)
+	if err != nil {
+		return nil, err
+	}
+	delete(originalFields, "rootfs")
+	delete(editedFields, "rootfs")
+
+	var runtimeConfig map[string]json.RawMessage // nil if config["config"] is missing or null
+	if err := json.Unmarshal(config["config"], &runtimeConfig); err != nil && config["config"] != nil {
+		return nil, fmt.Errorf("parsing runtime configuration of image config: %w", err)
+	}
+	originalRuntimeConfig, err := jsonFields(&original.Config)
+	if err != nil {
+		return nil, err
+	}
+	editedRuntimeConfig, err := jsonFields(&edited.Config)
+	if err != nil {
+		return nil, err
+	}
+	delete(originalFields, "config")
+	delete(editedFields, "config")
+	changed := false
+	if applyJSONFieldEdits(&runtimeConfig, originalRuntimeConfig, editedRuntimeConfig) {
+		if config["config"], err = json.Marshal(runtimeConfig); err != nil {
+			return nil, err
+		}
+		changed = true
+	}
+	if applyJSONFieldEdits(&config, originalFields, editedFields) {
+		changed = true
+	}
+	if !changed { // Don’t change the digest by reformatting the config.
+		return configBlob, nil
+	}
+	return json.Marshal(config)
+}
+
+// jsonFields returns the top-level fields of value, encoded as JSON.
+func jsonFields(value any) (map[string]json.RawMessage, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var res map[string]json.RawMessage
+	if err := json.Unmarshal(encoded, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// applyJSONFieldEdits updates *dest with fields which differ between original and edited (adding, replacing or removing them),
+// and returns true if there were any such fields.
+func applyJSONFieldEdits(dest *map[string]json.RawMessage, original, edited map[string]json.RawMessage) bool {
+	changed := false
+	for name := range original {
+		if _, ok := edited[name]; !ok {
+			delete(*dest, name)
+			changed = true
+		}
+	}
+	for name, editedValue := range edited {
+		if originalValue, ok := original[name]; ok && bytes.Equal(originalValue, editedValue) {
+			continue
+		}
+		if *dest == nil {
+			*dest = map[string]json.RawMessage{}
+		}
+		(*dest)[name] = editedValue
+		changed = true
+	}
+	return changed
+}
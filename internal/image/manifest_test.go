@@ -1,11 +1,15 @@
 package image
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/containers/image/v5/manifest"
 	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestManifestLayerInfosToBlobInfos(t *testing.T) {
@@ -69,3 +73,92 @@ func TestManifestLayerInfosToBlobInfos(t *testing.T) {
 		},
 	}, blobs)
 }
+
+func TestConfigWithUpdatedDiffIDs(t *testing.T) {
+	original := []byte(`{"architecture":"amd64","unknown":{"x":1},"rootfs":{"type":"layers","diff_ids":["sha256:1111111111111111111111111111111111111111111111111111111111111111","sha256:2222222222222222222222222222222222222222222222222222222222222222"]}}`)
+	updatedDiffID := digest.Digest("sha256:3333333333333333333333333333333333333333333333333333333333333333")
+	updated, err := configWithUpdatedDiffIDs(original, []digest.Digest{"", updatedDiffID})
+	require.NoError(t, err)
+	var config map[string]any
+	err = json.Unmarshal(updated, &config)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"architecture": "amd64",
+		"unknown":      map[string]any{"x": float64(1)},
+		"rootfs": map[string]any{
+			"type": "layers",
+			"diff_ids": []any{
+				"sha256:1111111111111111111111111111111111111111111111111111111111111111",
+				updatedDiffID.String(),
+			},
+		},
+	}, config)
+
+	// The number of DiffIDs must match
+	_, err = configWithUpdatedDiffIDs(original, []digest.Digest{updatedDiffID})
+	assert.Error(t, err)
+	// Configs without DiffIDs are rejected
+	_, err = configWithUpdatedDiffIDs([]byte(`{"architecture":"amd64"}`), []digest.Digest{updatedDiffID})
+	assert.Error(t, err)
+}
+
+func TestConfigWithEdits(t *testing.T) {
+	original := []byte(`{"architecture":"amd64","os":"linux","unknown":{"x":1},` +
+		`"config":{"Env":["PATH=/usr/bin"],"Cmd":["sh"],"Healthcheck":{"Test":["CMD","true"]}},` +
+		`"rootfs":{"type":"layers","diff_ids":["sha256:1111111111111111111111111111111111111111111111111111111111111111"]}}`)
+	var parsed imgspecv1.Image
+	err := json.Unmarshal(original, &parsed)
+	require.NoError(t, err)
+
+	// No edits
+	edited := parsed
+	updated, err := configWithEdits(original, &parsed, &edited)
+	require.NoError(t, err)
+	assert.Equal(t, original, updated)
+
+	edited.Author = "new author"
+	edited.Config.Env = []string{"PATH=/usr/bin", "FOO=bar"}
+	edited.Config.Cmd = nil
+	edited.Config.Labels = map[string]string{"label": "value"}
+	edited.RootFS.DiffIDs = nil // Ignored
+	updated, err = configWithEdits(original, &parsed, &edited)
+	require.NoError(t, err)
+	var config map[string]any
+	err = json.Unmarshal(updated, &config)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"architecture": "amd64",
+		"os":           "linux",
+		"author":       "new author",
+		"unknown":      map[string]any{"x": float64(1)},
+		"config": map[string]any{
+			"Env":         []any{"PATH=/usr/bin", "FOO=bar"},
+			"Labels":      map[string]any{"label": "value"},
+			"Healthcheck": map[string]any{"Test": []any{"CMD", "true"}},
+		},
+		"rootfs": map[string]any{
+			"type":     "layers",
+			"diff_ids": []any{"sha256:1111111111111111111111111111111111111111111111111111111111111111"},
+		},
+	}, config)
+
+	// A config without a runtime configuration
+	original = []byte(`{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":[]}}`)
+	parsed = imgspecv1.Image{}
+	err = json.Unmarshal(original, &parsed)
+	require.NoError(t, err)
+	edited = parsed
+	edited.Config.Entrypoint = []string{"/bin/app"}
+	updated, err = configWithEdits(original, &parsed, &edited)
+	require.NoError(t, err)
+	config = nil
+	err = json.Unmarshal(updated, &config)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"Entrypoint": []any{"/bin/app"}}, config["config"])
+
+	// Invalid input
+	_, err = configWithEdits([]byte("this is invalid"), &parsed, &edited)
+	assert.Error(t, err)
+	_, err = configWithEdits([]byte(`{"config":"this is invalid"}`), &parsed, &edited)
+	assert.Error(t, err)
+}
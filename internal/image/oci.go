@@ -151,6 +151,18 @@ func (m *manifestOCI1) UpdatedImage(ctx context.Context, options types.ManifestU
 		configBlob: m.configBlob,
 		m:          manifest.OCI1Clone(m.m),
 	}
+	if options.LayerDiffIDs != nil {
+		if err := copy.updateLayerDiffIDs(ctx, options.LayerDiffIDs); err != nil {
+			return nil, err
+		}
+		options.LayerDiffIDs = nil // Already done, don’t repeat this after a conversion.
+	}
+	if options.Config != nil {
+		if err := copy.updateConfig(ctx, options.Config); err != nil {
+			return nil, err
+		}
+		options.Config = nil // Already done, don’t repeat this after a conversion.
+	}
 
 	converted, err := convertManifestIfRequiredWithUpdate(ctx, options, map[string]manifestConvertFn{
 		manifest.DockerV2Schema2MediaType:       copy.convertToManifestSchema2Generic,
@@ -176,6 +188,48 @@ func (m *manifestOCI1) UpdatedImage(ctx context.Context, options types.ManifestU
 	return memoryImageFromManifest(&copy), nil
 }
 
+// updateLayerDiffIDs replaces the DiffIDs in the config of m, which must be a private copy, as requested by types.ManifestUpdateOptions.LayerDiffIDs.
+func (m *manifestOCI1) updateLayerDiffIDs(ctx context.Context, diffIDs []digest.Digest) error {
+	if m.m.Config.MediaType != imgspecv1.MediaTypeImageConfig {
+		return internalManifest.NewNonImageArtifactError(&m.m.Manifest)
+	}
+	configBlob, err := m.ConfigBlob(ctx)
+	if err != nil {
+		return err
+	}
+	updated, err := configWithUpdatedDiffIDs(configBlob, diffIDs)
+	if err != nil {
+		return err
+	}
+	m.configBlob = updated
+	m.m.Config.Digest = digest.FromBytes(updated)
+	m.m.Config.Size = int64(len(updated))
+	return nil
+}
+
+// updateConfig edits the config of m, which must be a private copy, as requested by types.ManifestUpdateOptions.Config.
+func (m *manifestOCI1) updateConfig(ctx context.Context, edited *imgspecv1.Image) error {
+	if m.m.Config.MediaType != imgspecv1.MediaTypeImageConfig {
+		return internalManifest.NewNonImageArtifactError(&m.m.Manifest)
+	}
+	original, err := m.OCIConfig(ctx)
+	if err != nil {
+		return err
+	}
+	configBlob, err := m.ConfigBlob(ctx)
+	if err != nil {
+		return err
+	}
+	updated, err := configWithEdits(configBlob, original, edited)
+	if err != nil {
+		return err
+	}
+	m.configBlob = updated
+	m.m.Config.Digest = digest.FromBytes(updated)
+	m.m.Config.Size = int64(len(updated))
+	return nil
+}
+
 func schema2DescriptorFromOCI1Descriptor(d imgspecv1.Descriptor) manifest.Schema2Descriptor {
 	return manifest.Schema2Descriptor{
 		MediaType: d.MediaType,
@@ -291,7 +345,14 @@ func (m *manifestOCI1) convertToManifestSchema2(_ context.Context, options *type
 			return nil, fmt.Errorf("Error during manifest conversion: %q: zstd compression is not supported for docker images", layers[idx].MediaType)
 		case ociencspec.MediaTypeLayerEnc, ociencspec.MediaTypeLayerGzipEnc, ociencspec.MediaTypeLayerZstdEnc,
 			ociencspec.MediaTypeLayerNonDistributableEnc, ociencspec.MediaTypeLayerNonDistributableGzipEnc, ociencspec.MediaTypeLayerNonDistributableZstdEnc:
-			return nil, fmt.Errorf("during manifest conversion: encrypted layers (%q) are not supported in docker images", layers[idx].MediaType)
+			destMIMEType := manifest.DockerV2Schema2MediaType
+			if options != nil && options.ManifestMIMEType != "" { // Possibly converting to schema1 via schema2
+				destMIMEType = options.ManifestMIMEType
+			}
+			return nil, fmt.Errorf("during manifest conversion: %w", manifest.ManifestLayerEncryptionIncompatibilityError{
+				ManifestMIMETypes: []string{destMIMEType},
+				LayerMIMEType:     layers[idx].MediaType,
+			})
 		default:
 			return nil, fmt.Errorf("Unknown media type during manifest conversion: %q", layers[idx].MediaType)
 		}
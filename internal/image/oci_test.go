@@ -566,7 +566,9 @@ func TestManifestOCI1ConvertToManifestSchema1(t *testing.T) {
 			Destination: memoryDest,
 		},
 	})
-	assert.Error(t, err)
+	var encryptionErr manifest.ManifestLayerEncryptionIncompatibilityError
+	require.ErrorAs(t, err, &encryptionErr)
+	assert.Equal(t, []string{manifest.DockerV2Schema1SignedMediaType}, encryptionErr.ManifestMIMETypes)
 
 	// Conversion to schema1 with encryption fails
 	_, err = original.UpdatedImage(context.Background(), types.ManifestUpdateOptions{
@@ -703,7 +705,9 @@ func TestConvertToManifestSchema2(t *testing.T) {
 	_, err = encrypted.UpdatedImage(context.Background(), types.ManifestUpdateOptions{
 		ManifestMIMEType: manifest.DockerV2Schema2MediaType,
 	})
-	assert.Error(t, err)
+	var encryptionErr manifest.ManifestLayerEncryptionIncompatibilityError
+	require.ErrorAs(t, err, &encryptionErr)
+	assert.Equal(t, []string{manifest.DockerV2Schema2MediaType}, encryptionErr.ManifestMIMETypes)
 
 	// Conversion to schema2 with encryption fails
 	_, err = original.UpdatedImage(context.Background(), types.ManifestUpdateOptions{
@@ -3,6 +3,7 @@ package image
 import (
 	"context"
 	"fmt"
+	"slices"
 
 	"github.com/containers/image/v5/docker/reference"
 	"github.com/containers/image/v5/internal/imagesource"
@@ -25,6 +26,10 @@ type UnparsedImage struct {
 	// Valid iff cachedManifest is not nil.
 	cachedManifestMIMEType string
 	cachedSignatures       []signature.Signature // A private cache for Signatures(); nil if not yet known.
+	cachedAttestations     []signature.Sigstore  // A private cache for UntrustedAttestations(); nil if not yet known.
+
+	lenient         bool             // Repair well-understood deviations from the manifest format; see LenientUnparsedInstance.
+	manifestRepairs []ManifestRepair // Valid iff cachedManifest is not nil.
 }
 
 // UnparsedInstance returns a types.UnparsedImage implementation for (source, instanceDigest).
@@ -40,6 +45,19 @@ func UnparsedInstance(src types.ImageSource, instanceDigest *digest.Digest) *Unp
 	}
 }
 
+// LenientUnparsedInstance is like UnparsedInstance, but the manifest returned by Manifest has well-understood deviations
+// from the manifest format (e.g. emitted by some old registries) repaired; the repairs can be listed using ManifestRepairs.
+//
+// The manifest digest is verified against the original manifest, but the repaired manifest has a different digest,
+// so signatures of the original manifest can not be verified against the UnparsedImage.
+//
+// This is publicly visible as c/image/image.LenientUnparsedInstance.
+func LenientUnparsedInstance(src types.ImageSource, instanceDigest *digest.Digest) *UnparsedImage {
+	res := UnparsedInstance(src, instanceDigest)
+	res.lenient = true
+	return res
+}
+
 // Reference returns the reference used to set up this source, _as specified by the user_
 // (not as the image itself, or its underlying storage, claims).  This can be used e.g. to determine which public keys are trusted for this image.
 func (i *UnparsedImage) Reference() types.ImageReference {
@@ -67,12 +85,27 @@ func (i *UnparsedImage) Manifest(ctx context.Context) ([]byte, string, error) {
 			}
 		}
 
+		var repairs []ManifestRepair
+		if i.lenient {
+			m, mt, repairs, err = repairManifest(ctx, i.src, m, mt)
+			if err != nil {
+				return nil, "", fmt.Errorf("repairing manifest: %w", err)
+			}
+		}
+
 		i.cachedManifest = m
 		i.cachedManifestMIMEType = mt
+		i.manifestRepairs = repairs
 	}
 	return i.cachedManifest, i.cachedManifestMIMEType, nil
 }
 
+// ManifestRepairs returns the repairs made to the manifest returned by Manifest, if the UnparsedImage was created
+// using LenientUnparsedInstance. It returns nil if Manifest has not been called successfully yet.
+func (i *UnparsedImage) ManifestRepairs() []ManifestRepair {
+	return slices.Clone(i.manifestRepairs)
+}
+
 // expectedManifestDigest returns a the expected value of the manifest digest, and an indicator whether it is known.
 // The bool return value seems redundant with digest != ""; it is used explicitly
 // to refuse (unexpected) situations when the digest exists but is "".
@@ -117,3 +150,23 @@ func (i *UnparsedImage) UntrustedSignatures(ctx context.Context) ([]signature.Si
 	}
 	return i.cachedSignatures, nil
 }
+
+// UntrustedAttestations is like ImageSourceWithAttestations.GetAttestations, but the result is cached; it is OK to call this however often you need.
+// It returns nil if the source does not support attestations.
+func (i *UnparsedImage) UntrustedAttestations(ctx context.Context) ([]signature.Sigstore, error) {
+	if i.cachedAttestations == nil {
+		src, ok := i.src.(private.ImageSourceWithAttestations)
+		if !ok {
+			return nil, nil
+		}
+		attestations, err := src.GetAttestations(ctx, i.instanceDigest)
+		if err != nil {
+			return nil, err
+		}
+		if attestations == nil {
+			attestations = []signature.Sigstore{}
+		}
+		i.cachedAttestations = attestations
+	}
+	return i.cachedAttestations, nil
+}
@@ -1,6 +1,9 @@
 package impl
 
-import "github.com/containers/image/v5/types"
+import (
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/types"
+)
 
 // Properties collects properties of an ImageDestination that are constant throughout its lifetime
 // (but might differ across instances).
@@ -21,6 +24,10 @@ type Properties struct {
 	IgnoresEmbeddedDockerReference bool
 	// HasThreadSafePutBlob indicates that PutBlob can be executed concurrently.
 	HasThreadSafePutBlob bool
+	// Capabilities are the capabilities of the destination not described by the other fields.
+	// This is only available to transports within this module; pkg/transportstubs.DestinationProperties deliberately
+	// does not expose it, because external code can't use internal types.
+	Capabilities private.DestinationCapabilities
 }
 
 // PropertyMethodsInitialize implements parts of private.ImageDestination corresponding to Properties.
@@ -70,3 +77,8 @@ func (o PropertyMethodsInitialize) IgnoresEmbeddedDockerReference() bool {
 func (o PropertyMethodsInitialize) HasThreadSafePutBlob() bool {
 	return o.vals.HasThreadSafePutBlob
 }
+
+// Capabilities returns the capabilities of the destination which are not reported by other methods.
+func (o PropertyMethodsInitialize) Capabilities() private.DestinationCapabilities {
+	return o.vals.Capabilities
+}
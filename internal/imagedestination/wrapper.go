@@ -41,6 +41,11 @@ func FromPublic(dest types.ImageDestination) private.ImageDestination {
 	}
 }
 
+// Capabilities returns the capabilities of the destination which are not reported by other methods.
+func (w *wrapped) Capabilities() private.DestinationCapabilities {
+	return private.DestinationCapabilities{} // We know nothing about the destination.
+}
+
 // PutBlobWithOptions writes contents of stream and returns data representing the result.
 // inputInfo.Digest can be optionally provided if known; if provided, and stream is read to the end without error, the digest MUST match the stream contents.
 // inputInfo.Size is the expected length of stream, if known.
@@ -94,6 +99,9 @@ func (w *wrapped) PutSignaturesWithFormat(ctx context.Context, signatures []sign
 		if !ok {
 			return signature.UnsupportedFormatError(sig)
 		}
+		if simpleSig.UntrustedTimestampToken() != nil {
+			return signature.ErrUnsupportedTimestampToken
+		}
 		simpleSigs = append(simpleSigs, simpleSig.UntrustedSignature())
 	}
 	return w.PutSignatures(ctx, simpleSigs, instanceDigest)
@@ -1,6 +1,7 @@
 package manifest
 
 import (
+	"cmp"
 	"encoding/json"
 	"fmt"
 	"slices"
@@ -89,6 +90,7 @@ func (list *Schema2ListPublic) UpdateInstances(updates []ListUpdate) error {
 
 func (list *Schema2ListPublic) editInstances(editInstances []ListEdit) error {
 	addedEntries := []Schema2ManifestDescriptor{}
+	sortInstances := false
 	for i, editInstance := range editInstances {
 		switch editInstance.ListOperation {
 		case ListOpUpdate:
@@ -128,6 +130,20 @@ func (list *Schema2ListPublic) editInstances(editInstances []ListEdit) error {
 				},
 				schema2PlatformSpecFromOCIPlatform(*editInstance.AddPlatform),
 			})
+		case ListOpRemove:
+			if err := editInstance.RemoveDigest.Validate(); err != nil {
+				return fmt.Errorf("Schema2List.EditInstances: Attempting to remove %s which is an invalid digest: %w", editInstance.RemoveDigest, err)
+			}
+			// slices.Clone() here to ensure a private backing array, as in the ListOpAdd case below.
+			remaining := slices.DeleteFunc(slices.Clone(list.Manifests), func(m Schema2ManifestDescriptor) bool {
+				return m.Digest == editInstance.RemoveDigest
+			})
+			if len(remaining) == len(list.Manifests) {
+				return fmt.Errorf("Schema2List.EditInstances: digest %s not found", editInstance.RemoveDigest)
+			}
+			list.Manifests = remaining
+		case ListOpSort:
+			sortInstances = true
 		default:
 			return fmt.Errorf("internal error: invalid operation: %d", editInstance.ListOperation)
 		}
@@ -137,6 +153,16 @@ func (list *Schema2ListPublic) editInstances(editInstances []ListEdit) error {
 		// an external caller could have manually created Schema2ListPublic with a slice with extra capacity.
 		list.Manifests = append(slices.Clone(list.Manifests), addedEntries...)
 	}
+	if sortInstances {
+		list.Manifests = slices.Clone(list.Manifests) // Ensure a private backing array, as in the ListOpAdd case above.
+		slices.SortFunc(list.Manifests, func(a, b Schema2ManifestDescriptor) int {
+			aPlatform, bPlatform := ociPlatformFromSchema2PlatformSpec(a.Platform), ociPlatformFromSchema2PlatformSpec(b.Platform)
+			return cmp.Or(
+				compareInstancePlatforms(&aPlatform, &bPlatform),
+				cmp.Compare(a.Digest, b.Digest),
+			)
+		})
+	}
 	return nil
 }
 
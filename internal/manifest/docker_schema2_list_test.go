@@ -87,6 +87,14 @@ func TestSchema2ListEditInstances(t *testing.T) {
 		digest.Digest("sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"),
 		digest.Digest("sha256:cccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccc"),
 	), list.Instances())
+
+	// Remove an instance
+	err = list.EditInstances([]ListEdit{{ListOperation: ListOpRemove, RemoveDigest: originalListOrder[1]}})
+	require.NoError(t, err)
+	assert.NotContains(t, list.Instances(), originalListOrder[1])
+	assert.Len(t, list.Instances(), len(originalListOrder)+1)
+	err = list.EditInstances([]ListEdit{{ListOperation: ListOpRemove, RemoveDigest: originalListOrder[1]}})
+	assert.Error(t, err)
 }
 
 func TestSchema2ListFromManifest(t *testing.T) {
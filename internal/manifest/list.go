@@ -1,7 +1,9 @@
 package manifest
 
 import (
+	"cmp"
 	"fmt"
+	"slices"
 
 	compression "github.com/containers/image/v5/pkg/compression/types"
 	"github.com/containers/image/v5/types"
@@ -57,7 +59,7 @@ type List interface {
 	// when configured to OptionalBoolTrue and chooses best available compression when it is OptionalBoolFalse or left OptionalBoolUndefined.
 	ChooseInstanceByCompression(ctx *types.SystemContext, preferGzip types.OptionalBool) (digest.Digest, error)
 	// Edit information about the list's instances. Contains Slice of ListEdit where each element
-	// is responsible for either Modifying or Adding a new instance to the Manifest. Operation is
+	// is responsible for either Modifying, Adding, or Removing an instance of the Manifest. Operation is
 	// selected on the basis of configured ListOperation field.
 	EditInstances([]ListEdit) error
 }
@@ -83,6 +85,11 @@ const (
 	listOpInvalid ListOp = iota
 	ListOpAdd
 	ListOpUpdate
+	ListOpRemove
+	// ListOpSort sorts instances into a canonical order, after all other edits have been applied, so that lists with the same
+	// instances are serialized identically regardless of the original order: by platform, with zstd-compressed instances after other
+	// instances of the same platform, and with attestations and other artifacts last. It uses no other ListEdit fields.
+	ListOpSort
 )
 
 // ListEdit includes the fields which a List's EditInstances() method will modify.
@@ -106,6 +113,9 @@ type ListEdit struct {
 	AddPlatform              *imgspecv1.Platform
 	AddAnnotations           map[string]string
 	AddCompressionAlgorithms []compression.Algorithm
+
+	// If Op = ListEditRemove. All instances with RemoveDigest are removed.
+	RemoveDigest digest.Digest
 }
 
 // ListPublicFromBlob parses a list of manifests.
@@ -131,3 +141,23 @@ func ListFromBlob(manifest []byte, manifestMIMEType string) (List, error) {
 	}
 	return nil, fmt.Errorf("Unimplemented manifest list MIME type %q (normalized as %q)", manifestMIMEType, normalized)
 }
+
+// compareInstancePlatforms compares platforms of list instances, for ListOpSort.
+// Instances with no platform are sorted after those with a platform.
+func compareInstancePlatforms(a, b *imgspecv1.Platform) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+	return cmp.Or(
+		cmp.Compare(a.OS, b.OS),
+		cmp.Compare(a.Architecture, b.Architecture),
+		cmp.Compare(a.Variant, b.Variant),
+		cmp.Compare(a.OSVersion, b.OSVersion),
+		slices.Compare(a.OSFeatures, b.OSFeatures),
+	)
+}
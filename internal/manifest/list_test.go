@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 
 	"github.com/containers/image/v5/types"
@@ -159,3 +160,82 @@ func TestChooseInstance(t *testing.T) {
 		}
 	}
 }
+
+func TestListOpSort(t *testing.T) {
+	const (
+		linuxAmd64      = digest.Digest("sha256:1111111111111111111111111111111111111111111111111111111111111111")
+		linuxAmd64Zstd  = digest.Digest("sha256:2222222222222222222222222222222222222222222222222222222222222222")
+		linuxArm64      = digest.Digest("sha256:3333333333333333333333333333333333333333333333333333333333333333")
+		linuxArm64V8    = digest.Digest("sha256:4444444444444444444444444444444444444444444444444444444444444444")
+		windowsAmd64    = digest.Digest("sha256:5555555555555555555555555555555555555555555555555555555555555555")
+		noPlatform      = digest.Digest("sha256:6666666666666666666666666666666666666666666666666666666666666666")
+		attestationA    = digest.Digest("sha256:7777777777777777777777777777777777777777777777777777777777777777")
+		attestationB    = digest.Digest("sha256:8888888888888888888888888888888888888888888888888888888888888888")
+		linuxAmd64Other = digest.Digest("sha256:0000000000000000000000000000000000000000000000000000000000000000")
+	)
+	descriptor := func(d digest.Digest, os, arch, variant string, annotations map[string]string) imgspecv1.Descriptor {
+		res := imgspecv1.Descriptor{MediaType: imgspecv1.MediaTypeImageManifest, Digest: d, Size: 1, Annotations: annotations}
+		if os != "" {
+			res.Platform = &imgspecv1.Platform{OS: os, Architecture: arch, Variant: variant}
+		}
+		return res
+	}
+	attestation := map[string]string{"vnd.docker.reference.type": "attestation-manifest"}
+	zstd := map[string]string{OCI1InstanceAnnotationCompressionZSTD: "true"}
+	components := []imgspecv1.Descriptor{
+		descriptor(attestationB, "unknown", "unknown", "", attestation),
+		descriptor(windowsAmd64, "windows", "amd64", "", nil),
+		descriptor(linuxAmd64Zstd, "linux", "amd64", "", zstd),
+		descriptor(noPlatform, "", "", "", nil),
+		descriptor(linuxArm64V8, "linux", "arm64", "v8", nil),
+		descriptor(attestationA, "unknown", "unknown", "", attestation),
+		descriptor(linuxAmd64, "linux", "amd64", "", nil),
+		descriptor(linuxArm64, "linux", "arm64", "", nil),
+		descriptor(linuxAmd64Other, "linux", "amd64", "", nil),
+	}
+	expected := []digest.Digest{linuxAmd64Other, linuxAmd64, linuxAmd64Zstd, linuxArm64, linuxArm64V8, windowsAmd64, noPlatform, attestationA, attestationB}
+
+	var serialized []byte
+	for i := range components { // Rotate the input to verify the result does not depend on the original order
+		rotated := append(slices.Clone(components[i:]), components[:i]...)
+		index := oci1IndexFromPublic(OCI1IndexPublicFromComponents(rotated, nil))
+		err := index.EditInstances([]ListEdit{{ListOperation: ListOpSort}})
+		require.NoError(t, err)
+		assert.Equal(t, expected, index.Instances())
+		s, err := index.Serialize()
+		require.NoError(t, err)
+		if serialized == nil {
+			serialized = s
+		} else {
+			assert.Equal(t, serialized, s)
+		}
+	}
+
+	// Sorting happens after other edits, regardless of the order of the edits.
+	index := oci1IndexFromPublic(OCI1IndexPublicFromComponents(components[1:3], nil))
+	err := index.EditInstances([]ListEdit{
+		{ListOperation: ListOpSort},
+		{
+			ListOperation: ListOpAdd,
+			AddDigest:     linuxAmd64,
+			AddSize:       1,
+			AddMediaType:  imgspecv1.MediaTypeImageManifest,
+			AddPlatform:   &imgspecv1.Platform{OS: "linux", Architecture: "amd64"},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []digest.Digest{linuxAmd64, linuxAmd64Zstd, windowsAmd64}, index.Instances())
+
+	// Schema2 lists are sorted by platform.
+	schema2Components := []Schema2ManifestDescriptor{}
+	for _, c := range []imgspecv1.Descriptor{components[1], components[4], components[6], components[7], components[8]} {
+		schema2Components = append(schema2Components, Schema2ManifestDescriptor{
+			Schema2Descriptor: Schema2Descriptor{MediaType: DockerV2Schema2MediaType, Size: c.Size, Digest: c.Digest},
+			Platform:          schema2PlatformSpecFromOCIPlatform(*c.Platform),
+		})
+	}
+	list := schema2ListFromPublic(Schema2ListPublicFromComponents(schema2Components))
+	err = list.EditInstances([]ListEdit{{ListOperation: ListOpSort}})
+	require.NoError(t, err)
+	assert.Equal(t, []digest.Digest{linuxAmd64Other, linuxAmd64, linuxArm64, linuxArm64V8, windowsAmd64}, list.Instances())
+}
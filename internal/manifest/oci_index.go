@@ -2,6 +2,7 @@ package manifest
 
 import (
 	"bytes"
+	"cmp"
 	"encoding/json"
 	"fmt"
 	"maps"
@@ -116,6 +117,7 @@ func addCompressionAnnotations(compressionAlgorithms []compression.Algorithm, an
 func (index *OCI1IndexPublic) editInstances(editInstances []ListEdit) error {
 	addedEntries := []imgspecv1.Descriptor{}
 	updatedAnnotations := false
+	sortInstances := false
 	for i, editInstance := range editInstances {
 		switch editInstance.ListOperation {
 		case ListOpUpdate:
@@ -166,6 +168,20 @@ func (index *OCI1IndexPublic) editInstances(editInstances []ListEdit) error {
 				Platform:     editInstance.AddPlatform,
 				Annotations:  annotations,
 			})
+		case ListOpRemove:
+			if err := editInstance.RemoveDigest.Validate(); err != nil {
+				return fmt.Errorf("OCI1Index.EditInstances: Attempting to remove %s which is an invalid digest: %w", editInstance.RemoveDigest, err)
+			}
+			// slices.Clone() here to ensure a private backing array, as in the ListOpAdd case below.
+			remaining := slices.DeleteFunc(slices.Clone(index.Manifests), func(m imgspecv1.Descriptor) bool {
+				return m.Digest == editInstance.RemoveDigest
+			})
+			if len(remaining) == len(index.Manifests) {
+				return fmt.Errorf("OCI1Index.EditInstances: digest %s not found", editInstance.RemoveDigest)
+			}
+			index.Manifests = remaining
+		case ListOpSort:
+			sortInstances = true
 		default:
 			return fmt.Errorf("internal error: invalid operation: %d", editInstance.ListOperation)
 		}
@@ -191,9 +207,42 @@ func (index *OCI1IndexPublic) editInstances(editInstances []ListEdit) error {
 			}
 		})
 	}
+	if sortInstances {
+		index.Manifests = slices.Clone(index.Manifests) // Ensure a private backing array, as in the ListOpAdd case above.
+		slices.SortFunc(index.Manifests, compareOCI1IndexInstances)
+	}
 	return nil
 }
 
+// compareOCI1IndexInstances compares instances of an OCI index, for ListOpSort.
+func compareOCI1IndexInstances(a, b imgspecv1.Descriptor) int {
+	return cmp.Or(
+		compareBools(instanceIsArtifact(a), instanceIsArtifact(b)),
+		compareInstancePlatforms(a.Platform, b.Platform),
+		compareBools(instanceIsZstd(a), instanceIsZstd(b)),
+		cmp.Compare(a.Digest, b.Digest),
+	)
+}
+
+// compareBools compares a and b, ordering false before true.
+func compareBools(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case !a:
+		return -1
+	default:
+		return 1
+	}
+}
+
+// instanceIsArtifact returns true if instance is not an image to run, but e.g. an attestation or a signature.
+func instanceIsArtifact(instance imgspecv1.Descriptor) bool {
+	return instance.ArtifactType != "" ||
+		instance.Annotations["vnd.docker.reference.type"] != "" || // e.g. BuildKit attestations
+		(instance.Platform != nil && instance.Platform.OS == "unknown" && instance.Platform.Architecture == "unknown")
+}
+
 func (index *OCI1Index) EditInstances(editInstances []ListEdit) error {
 	return index.editInstances(editInstances)
 }
@@ -188,6 +188,13 @@ func TestOCI1EditInstances(t *testing.T) {
 	instance, err = list.Instance(digest.Digest("sha256:ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"))
 	require.NoError(t, err)
 	assert.Equal(t, "application/x-tar", instance.ReadOnly.ArtifactType)
+
+	// Remove an instance
+	err = list.EditInstances([]ListEdit{{ListOperation: ListOpRemove, RemoveDigest: "sha256:ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"}})
+	require.NoError(t, err)
+	assert.NotContains(t, list.Instances(), digest.Digest("sha256:ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"))
+	err = list.EditInstances([]ListEdit{{ListOperation: ListOpRemove, RemoveDigest: "sha256:ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"}})
+	assert.Error(t, err)
 }
 
 func TestOCI1IndexChooseInstanceByCompression(t *testing.T) {
@@ -40,6 +40,8 @@ type ImageSource interface {
 type ImageDestinationInternalOnly interface {
 	// SupportsPutBlobPartial returns true if PutBlobPartial is supported.
 	SupportsPutBlobPartial() bool
+	// Capabilities returns the capabilities of the destination which are not reported by other methods.
+	Capabilities() DestinationCapabilities
 	// FIXME: Add SupportsSignaturesWithFormat or something like that, to allow early failures
 	// on unsupported formats.
 
@@ -93,6 +95,22 @@ type ImageDestination interface {
 	ImageDestinationInternalOnly
 }
 
+// DestinationCapabilities are capabilities of an ImageDestination which are not reported by other methods.
+type DestinationCapabilities struct {
+	// Referrers is true if the destination can store artifacts referring to an image using the OCI “subject” field,
+	// and OptionalBoolUndefined if that can only be determined by trying (e.g. it depends on the registry).
+	Referrers types.OptionalBool
+	// Deletion is true if images can be deleted using ImageReference.DeleteImage,
+	// and OptionalBoolUndefined if that can only be determined by trying.
+	Deletion types.OptionalBool
+	// MaxManifestSize is the maximum size of a manifest the destination is expected to accept, or 0 if unknown or unlimited.
+	MaxManifestSize int64
+	// LayerCompressionFormat, if not nil, is the compression algorithm the destination needs layers to use,
+	// unless the user explicitly chooses one. It is only relevant if DesiredLayerCompression() is types.Compress;
+	// layers compressed using a different algorithm are then recompressed.
+	LayerCompressionFormat *compression.Algorithm
+}
+
 // UploadedBlob is information about a blob written to a destination.
 // It is the subset of types.BlobInfo fields the transport is responsible for setting; all fields must be provided.
 type UploadedBlob struct {
@@ -113,6 +131,9 @@ type PutBlobOptions struct {
 
 	EmptyLayer bool // True if the blob is an "empty"/"throwaway" layer, and may not necessarily be physically represented.
 	LayerIndex *int // If the blob is a layer, a zero-based index of the layer within the image; nil otherwise.
+	// If true, the caller verifies that the stream matches the provided digest, and reading the stream fails (at the latest, when reaching EOF)
+	// if it does not; so the transport does not need to verify it again while writing it. Transports which verify digests do so by default.
+	SkipDigestVerification bool
 }
 
 // PutBlobPartialOptions are used in PutBlobPartial.
@@ -140,6 +161,9 @@ type TryReusingBlobOptions struct {
 	RequiredCompression     *compression.Algorithm // If set, reuse blobs with a matching algorithm as per implementations in internal/imagedestination/impl.helpers.go
 	OriginalCompression     *compression.Algorithm // May be nil to indicate “uncompressed” or “unknown”.
 	TOCDigest               digest.Digest          // If specified, the blob can be looked up in the destination also by its TOC digest.
+	// Additional repositories which may contain the blob (e.g. other repositories in the same namespace), which the destination
+	// may check and reuse the blob from (e.g. using a cross-repository mount); transports which can’t use them ignore them.
+	CandidateRepositories []reference.Named
 }
 
 // ReusedBlob is information about a blob reused in a destination.
@@ -202,7 +226,7 @@ type BlobChunkAccessor interface {
 	GetBlobAt(ctx context.Context, info types.BlobInfo, chunks []ImageSourceChunk) (chan io.ReadCloser, chan error, error)
 }
 
-// BadPartialRequestError is returned by BlobChunkAccessor.GetBlobAt on an invalid request.
+// BadPartialRequestError is returned by BlobChunkAccessor.GetBlobAt, or sent to the error channel it returns, on an invalid request.
 type BadPartialRequestError struct {
 	Status string
 }
@@ -236,3 +260,59 @@ func (c ErrFallbackToOrdinaryLayerDownload) Unwrap() error {
 func NewErrFallbackToOrdinaryLayerDownload(err error) error {
 	return ErrFallbackToOrdinaryLayerDownload{err: err}
 }
+
+// ImageSourceWithAttestations is an optional extension of ImageSource, for sources which can return attestations
+// (e.g. in-toto statements in DSSE envelopes) attached to an image separately from its signatures.
+type ImageSourceWithAttestations interface {
+	ImageSource
+	// GetAttestations returns the attestations attached to the image, as sigstore attachments.
+	// If instanceDigest is not nil, it contains a digest of the specific manifest instance to retrieve attestations for
+	// (when the primary manifest is a manifest list); this never happens if the primary manifest is not a manifest list
+	// (e.g. if the source never returns manifest lists).
+	GetAttestations(ctx context.Context, instanceDigest *digest.Digest) ([]signature.Sigstore, error)
+}
+
+// UnparsedImageWithAttestations is an optional extension of UnparsedImage, for images which can have attestations.
+type UnparsedImageWithAttestations interface {
+	UnparsedImage
+	// UntrustedAttestations is like ImageSourceWithAttestations.GetAttestations, but the result is cached;
+	// it returns nil if the source does not support attestations.
+	UntrustedAttestations(ctx context.Context) ([]signature.Sigstore, error)
+}
+
+// ImageTransportWithScopePatterns is an optional extension of types.ImageTransport,
+// for transports which support pattern scopes in signature.PolicyTransportScopes.
+type ImageTransportWithScopePatterns interface {
+	types.ImageTransport
+	// PolicyConfigurationScopeIsPattern returns true if scope, a key of signature.PolicyTransportScopes,
+	// is a pattern to be matched using PolicyConfigurationScopePatternMatches instead of an exact
+	// PolicyConfigurationIdentity() or PolicyConfigurationNamespaces() value.
+	PolicyConfigurationScopeIsPattern(scope string) bool
+	// PolicyConfigurationScopePatternMatches returns true if pattern, for which PolicyConfigurationScopeIsPattern is true,
+	// matches identity, a PolicyConfigurationIdentity() value.
+	PolicyConfigurationScopePatternMatches(pattern, identity string) bool
+}
+
+// ContentOrigin records where an image source read a manifest or a blob from.
+type ContentOrigin struct {
+	Digest          digest.Digest
+	IsManifest      bool              // true for manifests, false for blobs
+	Endpoint        string            // The endpoint the content was read from, e.g. a registry repository or a URL
+	IsMirror        bool              // Endpoint is a registry mirror, not the primary location of the image
+	FailedEndpoints []EndpointFailure // Endpoints which were tried before Endpoint, in order
+}
+
+// EndpointFailure records a failure to read content from an endpoint.
+type EndpointFailure struct {
+	Endpoint string
+	Err      error
+}
+
+// ImageSourceWithContentOrigins is an optional extension of ImageSource, for sources which can read content
+// from one of several endpoints (e.g. registry mirrors), and can report which ones were used.
+type ImageSourceWithContentOrigins interface {
+	ImageSource
+	// ContentOrigins returns records of where each manifest and blob successfully read so far came from,
+	// in the order they were first read.
+	ContentOrigins() []ContentOrigin
+}
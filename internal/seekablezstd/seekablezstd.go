@@ -0,0 +1,381 @@
+// Package seekablezstd implements a zstd-compressed stream which allows random access to named entries
+// (typically tar archive members) without decompressing the whole stream.
+//
+// The stream consists of independent zstd frames, each starting at an entry boundary or at most
+// maxFrameDecompressedSize bytes after the previous frame, followed by:
+//   - a skippable frame containing a JSON index mapping entry names to offsets in the decompressed data
+//   - a skippable frame containing the seek table, as defined by the zstd “seekable format”
+//     (https://github.com/facebook/zstd/blob/dev/contrib/seekable_format/zstd_seekable_compression_format.md).
+//
+// The result is a valid zstd stream, so consumers which don’t know about this format can decompress
+// it as usual; skippable frames are ignored by zstd decoders.
+package seekablezstd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	seekTableMagic     = 0x184D2A5E // The skippable frame magic used by the seek table
+	indexMagic         = 0x184D2A5D // The skippable frame magic used by our entry index
+	seekableMagic      = 0x8F92EAB1 // The magic number at the very end of the seek table
+	skippableHeaderLen = 8          // Magic + frame size
+	seekTableFooterLen = 9          // Number_Of_Frames + Seek_Table_Descriptor + Seekable_Magic_Number
+	seekTableEntryLen  = 8          // Compressed_Size + Decompressed_Size, without checksums
+	checksumFlag       = 0x80       // Seek_Table_Descriptor bit indicating checksums in the seek table entries
+	reservedBitsMask   = 0x7C       // Seek_Table_Descriptor bits which must be zero
+
+	// maxIndexSize is the maximum accepted size of the entry index.
+	maxIndexSize = 16 * 1024 * 1024
+)
+
+// maxFrameDecompressedSize is the maximum amount of uncompressed data in a frame;
+// the seek table format requires frame sizes to fit in uint32.
+// This is a variable only to allow overriding it in tests.
+var maxFrameDecompressedSize int64 = 64 * 1024 * 1024
+
+// ErrNotSeekable is returned by NewReader if the input does not end with a seek table.
+var ErrNotSeekable = errors.New("not a seekable zstd stream")
+
+// indexData is the JSON representation of the entry index.
+type indexData struct {
+	Entries []indexEntry `json:"entries"`
+}
+
+type indexEntry struct {
+	Name   string `json:"name"`
+	Offset int64  `json:"offset"` // In the decompressed data
+}
+
+// frame describes a single zstd frame.
+type frame struct {
+	compressedSize   uint32
+	decompressedSize uint32
+}
+
+// countingWriter counts the bytes written to dest.
+type countingWriter struct {
+	dest  io.Writer
+	count int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.dest.Write(p)
+	w.count += int64(n)
+	return n, err
+}
+
+// Writer creates a seekable zstd stream.
+type Writer struct {
+	dest    *countingWriter
+	encoder *zstd.Encoder
+	closed  bool
+
+	frames                []frame
+	inFrame               bool  // true if a frame has been started and not finished
+	frameStart            int64 // Compressed offset of the current frame
+	frameDecompressedSize int64 // Decompressed size of the current frame so far
+	decompressedOffset    int64 // Decompressed offset of the end of the data written so far
+	entries               []indexEntry
+}
+
+// NewWriter returns a Writer which writes a seekable zstd stream to dest.
+// The caller must call Close() to finish the stream; that does not close dest.
+func NewWriter(dest io.Writer) (*Writer, error) {
+	cw := &countingWriter{dest: dest}
+	encoder, err := zstd.NewWriter(cw, zstd.WithEncoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{
+		dest:    cw,
+		encoder: encoder,
+	}, nil
+}
+
+// StartEntry ends the current frame, and records that an entry called name starts at the current offset.
+// Entries must have unique names.
+func (w *Writer) StartEntry(name string) error {
+	if w.closed {
+		return errors.New("Internal error: StartEntry on a closed seekablezstd.Writer")
+	}
+	if err := w.endFrame(); err != nil {
+		return err
+	}
+	w.entries = append(w.entries, indexEntry{Name: name, Offset: w.decompressedOffset})
+	return nil
+}
+
+// Write compresses p into the stream.
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("Internal error: Write on a closed seekablezstd.Writer")
+	}
+	written := 0
+	for len(p) > 0 {
+		if w.frameDecompressedSize == maxFrameDecompressedSize {
+			if err := w.endFrame(); err != nil {
+				return written, err
+			}
+		}
+		if !w.inFrame {
+			w.encoder.Reset(w.dest)
+			w.inFrame = true
+			w.frameStart = w.dest.count
+			w.frameDecompressedSize = 0
+		}
+		chunk := p[:min(int64(len(p)), maxFrameDecompressedSize-w.frameDecompressedSize)]
+		n, err := w.encoder.Write(chunk)
+		written += n
+		w.frameDecompressedSize += int64(n)
+		w.decompressedOffset += int64(n)
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// endFrame finishes the current frame, if any.
+func (w *Writer) endFrame() error {
+	if !w.inFrame {
+		return nil
+	}
+	if err := w.encoder.Close(); err != nil {
+		return err
+	}
+	w.inFrame = false
+	compressedSize := w.dest.count - w.frameStart
+	if compressedSize > int64(^uint32(0)) {
+		return fmt.Errorf("Internal error: compressed zstd frame size %d does not fit into the seek table", compressedSize)
+	}
+	w.frames = append(w.frames, frame{
+		compressedSize:   uint32(compressedSize),
+		decompressedSize: uint32(w.frameDecompressedSize),
+	})
+	return nil
+}
+
+// Close finishes the stream, writing the entry index and the seek table.
+// It does not close the underlying io.Writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if err := w.endFrame(); err != nil {
+		return err
+	}
+
+	index, err := json.Marshal(indexData{Entries: w.entries})
+	if err != nil {
+		return err
+	}
+	if err := writeSkippableFrame(w.dest, indexMagic, index); err != nil {
+		return err
+	}
+
+	seekTable := make([]byte, 0, len(w.frames)*seekTableEntryLen+seekTableFooterLen)
+	for _, f := range w.frames {
+		seekTable = binary.LittleEndian.AppendUint32(seekTable, f.compressedSize)
+		seekTable = binary.LittleEndian.AppendUint32(seekTable, f.decompressedSize)
+	}
+	seekTable = binary.LittleEndian.AppendUint32(seekTable, uint32(len(w.frames)))
+	seekTable = append(seekTable, 0) // Seek_Table_Descriptor: no checksums
+	seekTable = binary.LittleEndian.AppendUint32(seekTable, seekableMagic)
+	return writeSkippableFrame(w.dest, seekTableMagic, seekTable)
+}
+
+// writeSkippableFrame writes a zstd skippable frame with magic and contents to dest.
+func writeSkippableFrame(dest io.Writer, magic uint32, contents []byte) error {
+	if int64(len(contents)) > int64(^uint32(0)) {
+		return fmt.Errorf("Internal error: skippable frame size %d too large", len(contents))
+	}
+	header := binary.LittleEndian.AppendUint32(nil, magic)
+	header = binary.LittleEndian.AppendUint32(header, uint32(len(contents)))
+	if _, err := dest.Write(header); err != nil {
+		return err
+	}
+	_, err := dest.Write(contents)
+	return err
+}
+
+// Reader allows random access to a seekable zstd stream.
+// It is safe to use from multiple goroutines simultaneously.
+type Reader struct {
+	ra                  io.ReaderAt
+	compressedOffsets   []int64 // The compressed offset of each frame
+	decompressedOffsets []int64 // The decompressed offset of each frame
+	dataEnd             int64   // The compressed offset of the end of the last frame
+	decompressedSize    int64
+	entries             map[string]int64 // nil if the stream does not contain an index
+}
+
+// NewReader returns a Reader for the size bytes of ra.
+// It returns an error wrapping ErrNotSeekable if ra does not contain a seekable zstd stream.
+func NewReader(ra io.ReaderAt, size int64) (*Reader, error) {
+	if size < skippableHeaderLen+seekTableFooterLen {
+		return nil, ErrNotSeekable
+	}
+	footer := make([]byte, seekTableFooterLen)
+	if _, err := ra.ReadAt(footer, size-seekTableFooterLen); err != nil {
+		return nil, fmt.Errorf("reading seek table footer: %w", err)
+	}
+	if binary.LittleEndian.Uint32(footer[5:9]) != seekableMagic {
+		return nil, ErrNotSeekable
+	}
+	descriptor := footer[4]
+	if descriptor&reservedBitsMask != 0 {
+		return nil, fmt.Errorf("invalid seek table descriptor %#x", descriptor)
+	}
+	entryLen := int64(seekTableEntryLen)
+	if descriptor&checksumFlag != 0 {
+		entryLen += 4
+	}
+	numFrames := int64(binary.LittleEndian.Uint32(footer[0:4]))
+	seekTableSize := numFrames*entryLen + seekTableFooterLen
+	seekTableStart := size - seekTableSize - skippableHeaderLen
+	if seekTableStart < 0 {
+		return nil, fmt.Errorf("seek table with %d frames does not fit in a %d-byte stream", numFrames, size)
+	}
+	seekTable := make([]byte, skippableHeaderLen+seekTableSize)
+	if _, err := ra.ReadAt(seekTable, seekTableStart); err != nil {
+		return nil, fmt.Errorf("reading seek table: %w", err)
+	}
+	if binary.LittleEndian.Uint32(seekTable[0:4]) != seekTableMagic || int64(binary.LittleEndian.Uint32(seekTable[4:8])) != seekTableSize {
+		return nil, errors.New("invalid seek table header")
+	}
+
+	r := &Reader{
+		ra:                  ra,
+		compressedOffsets:   make([]int64, 0, numFrames),
+		decompressedOffsets: make([]int64, 0, numFrames),
+	}
+	for i := int64(0); i < numFrames; i++ {
+		entry := seekTable[skippableHeaderLen+i*entryLen:]
+		r.compressedOffsets = append(r.compressedOffsets, r.dataEnd)
+		r.decompressedOffsets = append(r.decompressedOffsets, r.decompressedSize)
+		r.dataEnd += int64(binary.LittleEndian.Uint32(entry[0:4]))
+		r.decompressedSize += int64(binary.LittleEndian.Uint32(entry[4:8]))
+	}
+	if r.dataEnd > seekTableStart {
+		return nil, fmt.Errorf("seek table describes %d bytes of frames, but only %d are available", r.dataEnd, seekTableStart)
+	}
+
+	if r.dataEnd < seekTableStart {
+		if err := r.readIndex(seekTableStart); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// readIndex reads the entry index, if any, between r.dataEnd and seekTableStart.
+func (r *Reader) readIndex(seekTableStart int64) error {
+	if seekTableStart-r.dataEnd < skippableHeaderLen {
+		return nil
+	}
+	header := make([]byte, skippableHeaderLen)
+	if _, err := r.ra.ReadAt(header, r.dataEnd); err != nil {
+		return fmt.Errorf("reading entry index header: %w", err)
+	}
+	if binary.LittleEndian.Uint32(header[0:4]) != indexMagic {
+		return nil // Something else, not an index we know how to use
+	}
+	indexSize := int64(binary.LittleEndian.Uint32(header[4:8]))
+	if indexSize > maxIndexSize || r.dataEnd+skippableHeaderLen+indexSize != seekTableStart {
+		return fmt.Errorf("invalid entry index size %d", indexSize)
+	}
+	indexBytes := make([]byte, indexSize)
+	if _, err := r.ra.ReadAt(indexBytes, r.dataEnd+skippableHeaderLen); err != nil {
+		return fmt.Errorf("reading entry index: %w", err)
+	}
+	var index indexData
+	if err := json.Unmarshal(indexBytes, &index); err != nil {
+		return fmt.Errorf("parsing entry index: %w", err)
+	}
+	r.entries = make(map[string]int64, len(index.Entries))
+	for _, e := range index.Entries {
+		if e.Offset < 0 || e.Offset > r.decompressedSize {
+			return fmt.Errorf("entry %q offset %d out of range", e.Name, e.Offset)
+		}
+		if _, ok := r.entries[e.Name]; ok {
+			return fmt.Errorf("duplicate entry %q in the index", e.Name)
+		}
+		r.entries[e.Name] = e.Offset
+	}
+	return nil
+}
+
+// DecompressedSize returns the size of the decompressed data.
+func (r *Reader) DecompressedSize() int64 {
+	return r.decompressedSize
+}
+
+// EntryOffset returns the decompressed offset of the entry called name, and true;
+// or (-1, false) if the index does not contain name, or there is no index.
+func (r *Reader) EntryOffset(name string) (int64, bool) {
+	offset, ok := r.entries[name]
+	if !ok {
+		return -1, false
+	}
+	return offset, true
+}
+
+// EntryNames returns the names of all entries in the index, sorted.
+func (r *Reader) EntryNames() []string {
+	res := make([]string, 0, len(r.entries))
+	for name := range r.entries {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// HasIndex returns true if the stream contains an entry index.
+func (r *Reader) HasIndex() bool {
+	return r.entries != nil
+}
+
+// decoderReadCloser closes a zstd.Decoder.
+type decoderReadCloser struct {
+	*zstd.Decoder
+}
+
+func (d decoderReadCloser) Close() error {
+	d.Decoder.Close()
+	return nil
+}
+
+// StreamAt returns a stream of the decompressed data starting at offset.
+// Only the frames containing offset and later data are decompressed.
+// The caller must call Close() on the returned stream.
+func (r *Reader) StreamAt(offset int64) (io.ReadCloser, error) {
+	if offset < 0 || offset > r.decompressedSize {
+		return nil, fmt.Errorf("offset %d out of range", offset)
+	}
+	if offset == r.decompressedSize {
+		return io.NopCloser(bytes.NewReader(nil)), nil
+	}
+	// Find the last frame starting at or before offset.
+	i := sort.Search(len(r.decompressedOffsets), func(i int) bool { return r.decompressedOffsets[i] > offset }) - 1
+	compressed := io.NewSectionReader(r.ra, r.compressedOffsets[i], r.dataEnd-r.compressedOffsets[i])
+	decoder, err := zstd.NewReader(compressed, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.CopyN(io.Discard, decoder, offset-r.decompressedOffsets[i]); err != nil {
+		decoder.Close()
+		return nil, fmt.Errorf("seeking to offset %d: %w", offset, err)
+	}
+	return decoderReadCloser{decoder}, nil
+}
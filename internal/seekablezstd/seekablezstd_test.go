@@ -0,0 +1,216 @@
+package seekablezstd
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterReader(t *testing.T) {
+	savedMax := maxFrameDecompressedSize
+	maxFrameDecompressedSize = 100
+	defer func() { maxFrameDecompressedSize = savedMax }()
+
+	entries := []struct {
+		name string
+		data []byte
+	}{
+		{"a", []byte("first entry")},
+		{"big", bytes.Repeat([]byte("0123456789"), 35)}, // Split into several frames
+		{"empty", nil},
+		{"c", []byte("last entry")},
+	}
+	buf := bytes.Buffer{}
+	w, err := NewWriter(&buf)
+	require.NoError(t, err)
+	expected := []byte{}
+	for _, e := range entries {
+		err := w.StartEntry(e.name)
+		require.NoError(t, err)
+		n, err := w.Write(e.data)
+		require.NoError(t, err)
+		assert.Equal(t, len(e.data), n)
+		expected = append(expected, e.data...)
+	}
+	err = w.Close()
+	require.NoError(t, err)
+	assert.Len(t, w.frames, 6) // a, 4*big, c; empty has no frame
+
+	// The result is a valid zstd stream
+	decoder, err := zstd.NewReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	decompressed, err := io.ReadAll(decoder)
+	decoder.Close()
+	require.NoError(t, err)
+	assert.Equal(t, expected, decompressed)
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	assert.True(t, r.HasIndex())
+	assert.Equal(t, int64(len(expected)), r.DecompressedSize())
+	offset := int64(0)
+	for i, e := range entries {
+		entryOffset, ok := r.EntryOffset(e.name)
+		require.True(t, ok, e.name)
+		assert.Equal(t, offset, entryOffset, e.name)
+		offset += int64(len(e.data))
+
+		stream, err := r.StreamAt(entryOffset)
+		require.NoError(t, err)
+		data, err := io.ReadAll(stream)
+		require.NoError(t, err)
+		assert.Equal(t, expected[entryOffset:], data, e.name)
+		err = stream.Close()
+		require.NoError(t, err)
+
+		if i == 1 { // An offset in the middle of a frame
+			stream, err := r.StreamAt(entryOffset + 150)
+			require.NoError(t, err)
+			data, err := io.ReadAll(stream)
+			require.NoError(t, err)
+			assert.Equal(t, expected[entryOffset+150:], data)
+			stream.Close()
+		}
+	}
+	_, ok := r.EntryOffset("missing")
+	assert.False(t, ok)
+	_, err = r.StreamAt(-1)
+	assert.Error(t, err)
+	_, err = r.StreamAt(r.DecompressedSize() + 1)
+	assert.Error(t, err)
+}
+
+func TestNewReaderNotSeekable(t *testing.T) {
+	// Too short
+	_, err := NewReader(bytes.NewReader([]byte{1, 2, 3}), 3)
+	assert.ErrorIs(t, err, ErrNotSeekable)
+
+	// A plain zstd stream
+	buf := bytes.Buffer{}
+	encoder, err := zstd.NewWriter(&buf)
+	require.NoError(t, err)
+	_, err = encoder.Write(bytes.Repeat([]byte("data"), 100))
+	require.NoError(t, err)
+	err = encoder.Close()
+	require.NoError(t, err)
+	_, err = NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.ErrorIs(t, err, ErrNotSeekable)
+
+	// A corrupt seek table
+	buf = bytes.Buffer{}
+	w, err := NewWriter(&buf)
+	require.NoError(t, err)
+	_, err = w.Write([]byte("data"))
+	require.NoError(t, err)
+	err = w.Close()
+	require.NoError(t, err)
+	corrupt := bytes.Clone(buf.Bytes())
+	corrupt[len(corrupt)-9] = 200 // Number_Of_Frames
+	_, err = NewReader(bytes.NewReader(corrupt), int64(len(corrupt)))
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrNotSeekable)
+}
+
+func TestCompressTar(t *testing.T) {
+	tarBuf := bytes.Buffer{}
+	tw := tar.NewWriter(&tarBuf)
+	files := map[string][]byte{
+		"manifest.json":   []byte("[]"),
+		"dir/layer.tar":   bytes.Repeat([]byte("layer"), 1000),
+		"config.json":     []byte("{}"),
+		"empty-file.json": {},
+	}
+	for _, name := range []string{"manifest.json", "./dir/layer.tar", "config.json", "empty-file.json"} {
+		data := files[name]
+		if name == "./dir/layer.tar" {
+			data = files["dir/layer.tar"]
+		}
+		err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), Typeflag: tar.TypeReg})
+		require.NoError(t, err)
+		_, err = tw.Write(data)
+		require.NoError(t, err)
+	}
+	err := tw.Close()
+	require.NoError(t, err)
+
+	compressed := bytes.Buffer{}
+	err = CompressTar(&compressed, bytes.NewReader(tarBuf.Bytes()))
+	require.NoError(t, err)
+
+	r, err := NewReader(bytes.NewReader(compressed.Bytes()), int64(compressed.Len()))
+	require.NoError(t, err)
+	for name, expected := range files {
+		tr, hdr, closer, err := r.OpenTarEntry(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, int64(len(expected)), hdr.Size)
+		data, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		assert.Equal(t, expected, data, name)
+		err = closer.Close()
+		require.NoError(t, err)
+	}
+	_, _, _, err = r.OpenTarEntry("missing")
+	assert.Error(t, err)
+}
+
+func TestLookupTarEntry(t *testing.T) {
+	savedMax := maxFrameDecompressedSize
+	maxFrameDecompressedSize = 1000
+	defer func() { maxFrameDecompressedSize = savedMax }()
+
+	tarBuf := bytes.Buffer{}
+	tw := tar.NewWriter(&tarBuf)
+	layer := bytes.Repeat([]byte("0123456789"), 500) // Split into several frames
+	err := tw.WriteHeader(&tar.Header{Name: "dir/", Mode: 0o755, Typeflag: tar.TypeDir})
+	require.NoError(t, err)
+	// A long name requires a PAX header before the data.
+	longName := "dir/" + string(bytes.Repeat([]byte("x"), 200))
+	for _, name := range []string{"dir/small", longName} {
+		err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(layer)), Typeflag: tar.TypeReg})
+		require.NoError(t, err)
+		_, err = tw.Write(layer)
+		require.NoError(t, err)
+	}
+	err = tw.Close()
+	require.NoError(t, err)
+	compressed := bytes.Buffer{}
+	err = CompressTar(&compressed, bytes.NewReader(tarBuf.Bytes()))
+	require.NoError(t, err)
+	r, err := NewReader(bytes.NewReader(compressed.Bytes()), int64(compressed.Len()))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dir", "dir/small", longName}, r.EntryNames())
+
+	for _, name := range []string{"dir/small", longName} {
+		e, err := r.LookupTarEntry(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, int64(len(layer)), e.Header.Size)
+
+		stream, err := e.Open()
+		require.NoError(t, err)
+		data, err := io.ReadAll(stream)
+		require.NoError(t, err)
+		assert.Equal(t, layer, data)
+		err = stream.Close()
+		require.NoError(t, err)
+
+		buf := make([]byte, 15)
+		n, err := e.ReadAt(buf, 1995)
+		require.NoError(t, err)
+		assert.Equal(t, layer[1995:2010], buf[:n])
+		n, err = e.ReadAt(buf, int64(len(layer))-5)
+		assert.ErrorIs(t, err, io.EOF)
+		assert.Equal(t, layer[len(layer)-5:], buf[:n])
+		_, err = e.ReadAt(buf, int64(len(layer)))
+		assert.ErrorIs(t, err, io.EOF)
+	}
+
+	_, err = r.LookupTarEntry("dir") // Not a regular file
+	assert.Error(t, err)
+	_, err = r.LookupTarEntry("missing")
+	assert.Error(t, err)
+}
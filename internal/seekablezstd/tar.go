@@ -0,0 +1,165 @@
+package seekablezstd
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+)
+
+// CompressTar reads a tar archive from src, and writes it to dest as a seekable zstd stream,
+// with an index of the entries by their path.Clean-ed names.
+// It does not close dest.
+func CompressTar(dest io.Writer, src io.Reader) error {
+	w, err := NewWriter(dest)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(src)
+	tw := tar.NewWriter(w)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("reading tar archive: %w", err)
+		}
+		if err := tw.Flush(); err != nil { // Terminate the previous entry, so that the new frame starts at the header
+			return err
+		}
+		if err := w.StartEntry(path.Clean(hdr.Name)); err != nil {
+			return err
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return fmt.Errorf("copying tar entry %q: %w", hdr.Name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// OpenTarEntry returns a tar reader positioned at the data of the tar entry called name (as recorded in the index),
+// and its header.
+// The caller must call Close() on the returned io.Closer when done reading.
+func (r *Reader) OpenTarEntry(name string) (*tar.Reader, *tar.Header, io.Closer, error) {
+	offset, ok := r.EntryOffset(name)
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("tar entry %q not found in the index", name)
+	}
+	stream, err := r.StreamAt(offset)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	tr := tar.NewReader(stream)
+	hdr, err := tr.Next()
+	if err != nil {
+		stream.Close()
+		return nil, nil, nil, fmt.Errorf("reading tar header of %q: %w", name, err)
+	}
+	if path.Clean(hdr.Name) != name {
+		stream.Close()
+		return nil, nil, nil, fmt.Errorf("index entry %q points at tar entry %q", name, hdr.Name)
+	}
+	return tr, hdr, stream, nil
+}
+
+// TarEntry is a regular file in a tar archive stored in a seekable stream, allowing random access to its data.
+type TarEntry struct {
+	Header     *tar.Header
+	r          *Reader
+	dataOffset int64 // The decompressed offset of the file data
+}
+
+// countingReader counts the bytes read from src.
+type countingReader struct {
+	src   io.Reader
+	count int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.src.Read(p)
+	c.count += int64(n)
+	return n, err
+}
+
+// LookupTarEntry returns the regular tar entry called name (as recorded in the index).
+// Only the header of the entry is read.
+func (r *Reader) LookupTarEntry(name string) (*TarEntry, error) {
+	offset, ok := r.EntryOffset(name)
+	if !ok {
+		return nil, fmt.Errorf("tar entry %q not found in the index", name)
+	}
+	stream, err := r.StreamAt(offset)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+	// tar.Reader.Next reads exactly the header blocks, so the count is the offset of the data within the entry.
+	counter := &countingReader{src: stream}
+	hdr, err := tar.NewReader(counter).Next()
+	if err != nil {
+		return nil, fmt.Errorf("reading tar header of %q: %w", name, err)
+	}
+	if path.Clean(hdr.Name) != name {
+		return nil, fmt.Errorf("index entry %q points at tar entry %q", name, hdr.Name)
+	}
+	if hdr.Typeflag != tar.TypeReg {
+		return nil, fmt.Errorf("tar entry %q is not a regular file", name)
+	}
+	return &TarEntry{
+		Header:     hdr,
+		r:          r,
+		dataOffset: offset + counter.count,
+	}, nil
+}
+
+// Open returns a stream of the data of e.
+// The caller must call Close() on the returned stream.
+func (e *TarEntry) Open() (io.ReadCloser, error) {
+	stream, err := e.r.StreamAt(e.dataOffset)
+	if err != nil {
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(stream, e.Header.Size), stream}, nil
+}
+
+// ReadAt implements io.ReaderAt for the data of e.
+// Each call decompresses data starting at the frame containing off, so callers should prefer large reads.
+func (e *TarEntry) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("invalid offset %d", off)
+	}
+	if off >= e.Header.Size {
+		return 0, io.EOF
+	}
+	toRead := p
+	if remaining := e.Header.Size - off; int64(len(toRead)) > remaining {
+		toRead = toRead[:remaining]
+	}
+	stream, err := e.r.StreamAt(e.dataOffset + off)
+	if err != nil {
+		return 0, err
+	}
+	defer stream.Close()
+	n, err := io.ReadFull(stream, toRead)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return n, err
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
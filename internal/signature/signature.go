@@ -16,6 +16,10 @@ const (
 	// Update also UnsupportedFormatError below
 )
 
+// simpleSigningWithTimestampBlobFormat is the format name used by Blob() for a SimpleSigning signature with a timestamp token.
+// It is only a storage format: such signatures still have FormatID() == SimpleSigningFormat.
+const simpleSigningWithTimestampBlobFormat = "simple-signing-timestamped"
+
 // Signature is an image signature of some kind.
 type Signature interface {
 	FormatID() FormatID
@@ -31,9 +35,13 @@ func Blob(sig Signature) ([]byte, error) {
 		return nil, err
 	}
 
-	format := sig.FormatID()
+	format := string(sig.FormatID())
+	if s, ok := sig.(SimpleSigning); ok && s.untrustedTimestampToken != nil {
+		// The compatibility format below has no space for the timestamp token.
+		format = simpleSigningWithTimestampBlobFormat
+	}
 	switch format {
-	case SimpleSigningFormat:
+	case string(SimpleSigningFormat):
 		// For compatibility with old dir formats:
 		return chunk, nil
 	default:
@@ -78,6 +86,8 @@ func FromBlob(blob []byte) (Signature, error) {
 		switch {
 		case bytes.Equal(formatBytes, []byte(SimpleSigningFormat)):
 			return SimpleSigningFromBlob(blobChunk), nil
+		case bytes.Equal(formatBytes, []byte(simpleSigningWithTimestampBlobFormat)):
+			return simpleSigningFromTimestampedBlobChunk(blobChunk)
 		case bytes.Equal(formatBytes, []byte(SigstoreFormat)):
 			return sigstoreFromBlobChunk(blobChunk)
 		default:
@@ -90,6 +100,11 @@ func FromBlob(blob []byte) (Signature, error) {
 
 }
 
+// ErrUnsupportedTimestampToken is returned by destinations which can store SimpleSigning signatures,
+// but not their RFC 3161 timestamp tokens.
+// Dropping the token would create signatures that are rejected by policies which require a timestamp.
+var ErrUnsupportedTimestampToken = errors.New("storing simple signing signatures with a timestamp token is not supported by this destination")
+
 // UnsupportedFormatError returns an error complaining about sig having an unsupported format.
 func UnsupportedFormatError(sig Signature) error {
 	formatID := sig.FormatID()
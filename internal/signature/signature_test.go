@@ -32,6 +32,18 @@ func TestBlobSimpleSigning(t *testing.T) {
 	require.True(t, ok)
 	assert.Equal(t, simpleSigData, fromBlobSimple.UntrustedSignature())
 
+	// A signature with a timestamp token uses a separate storage format, but is still a SimpleSigning signature.
+	timestampedSig := SimpleSigningWithTimestampToken(simpleSig, []byte("timestamp token"))
+	timestampedBlob, err := Blob(timestampedSig)
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(timestampedBlob, []byte("\x00simple-signing-timestamped\n{")))
+	fromBlob, err = FromBlob(timestampedBlob)
+	require.NoError(t, err)
+	fromBlobSimple, ok = fromBlob.(SimpleSigning)
+	require.True(t, ok)
+	assert.Equal(t, SimpleSigningFormat, fromBlobSimple.FormatID())
+	assert.Equal(t, simpleSigData, fromBlobSimple.UntrustedSignature())
+	assert.Equal(t, []byte("timestamp token"), fromBlobSimple.UntrustedTimestampToken())
 }
 
 func TestBlobSigstore(t *testing.T) {
@@ -54,11 +66,12 @@ func TestBlobSigstore(t *testing.T) {
 func TestFromBlobInvalid(t *testing.T) {
 	// Round-tripping valid data has been tested in TestBlobSimpleSigning and TestBlobSigstore above.
 	for _, c := range []string{
-		"",                          // Empty
-		"\xFFsimple-signing\nhello", // Invalid first byte
-		"\x00simple-signing",        // No newline
-		"\x00format\xFFname\ndata",  // Non-ASCII format value
-		"\x00unknown-format\ndata",  // Unknown format
+		"",                                  // Empty
+		"\xFFsimple-signing\nhello",         // Invalid first byte
+		"\x00simple-signing",                // No newline
+		"\x00format\xFFname\ndata",          // Non-ASCII format value
+		"\x00unknown-format\ndata",          // Unknown format
+		"\x00simple-signing-timestamped\n{", // Invalid JSON
 	} {
 		_, err := FromBlob([]byte(c))
 		assert.Error(t, err, fmt.Sprintf("%#v", c))
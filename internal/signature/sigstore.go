@@ -9,6 +9,8 @@ import (
 const (
 	// from sigstore/cosign/pkg/types.SimpleSigningMediaType
 	SigstoreSignatureMIMEType = "application/vnd.dev.cosign.simplesigning.v1+json"
+	// from sigstore/cosign/pkg/types.DssePayloadType; used for attestations
+	SigstoreDSSEEnvelopeMIMEType = "application/vnd.dsse.envelope.v1+json"
 	// from sigstore/cosign/pkg/oci/static.SignatureAnnotationKey
 	SigstoreSignatureAnnotationKey = "dev.cosignproject.cosign/signature"
 	// from sigstore/cosign/pkg/oci/static.BundleAnnotationKey
@@ -17,6 +19,9 @@ const (
 	SigstoreCertificateAnnotationKey = "dev.sigstore.cosign/certificate"
 	// from sigstore/cosign/pkg/oci/static.ChainAnnotationKey
 	SigstoreIntermediateCertificateChainAnnotationKey = "dev.sigstore.cosign/chain"
+	// A DER-encoded OCSP response for the certificate in SigstoreCertificateAnnotationKey, base64-encoded.
+	// This is not defined by cosign.
+	SigstoreOCSPResponseAnnotationKey = "io.github.containers.ocsp-response"
 )
 
 // Sigstore is a github.com/cosign/cosign signature.
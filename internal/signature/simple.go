@@ -1,10 +1,21 @@
 package signature
 
-import "bytes"
+import (
+	"bytes"
+	"encoding/json"
+)
 
 // SimpleSigning is a “simple signing” signature.
 type SimpleSigning struct {
-	untrustedSignature []byte
+	untrustedSignature      []byte
+	untrustedTimestampToken []byte // An RFC 3161 timestamp token of untrustedSignature, or nil
+}
+
+// simpleSigningWithTimestampJSONRepresentation needs the files to be public, which we don’t want for
+// the main SimpleSigning type.
+type simpleSigningWithTimestampJSONRepresentation struct {
+	UntrustedSignature      []byte `json:"signature"`
+	UntrustedTimestampToken []byte `json:"timestampToken"`
 }
 
 // SimpleSigningFromBlob converts a “simple signing” signature into a SimpleSigning object.
@@ -14,6 +25,24 @@ func SimpleSigningFromBlob(blobChunk []byte) SimpleSigning {
 	}
 }
 
+// SimpleSigningWithTimestampToken returns a copy of s with an attached RFC 3161 timestamp token of s.UntrustedSignature().
+func SimpleSigningWithTimestampToken(s SimpleSigning, untrustedTimestampToken []byte) SimpleSigning {
+	return SimpleSigning{
+		untrustedSignature:      bytes.Clone(s.untrustedSignature),
+		untrustedTimestampToken: bytes.Clone(untrustedTimestampToken),
+	}
+}
+
+// simpleSigningFromTimestampedBlobChunk converts a SimpleSigning signature with a timestamp token,
+// as returned by SimpleSigning.blobChunk, into a SimpleSigning object.
+func simpleSigningFromTimestampedBlobChunk(blobChunk []byte) (SimpleSigning, error) {
+	var v simpleSigningWithTimestampJSONRepresentation
+	if err := json.Unmarshal(blobChunk, &v); err != nil {
+		return SimpleSigning{}, err
+	}
+	return SimpleSigningWithTimestampToken(SimpleSigningFromBlob(v.UntrustedSignature), v.UntrustedTimestampToken), nil
+}
+
 func (s SimpleSigning) FormatID() FormatID {
 	return SimpleSigningFormat
 }
@@ -21,9 +50,20 @@ func (s SimpleSigning) FormatID() FormatID {
 // blobChunk returns a representation of signature as a []byte, suitable for long-term storage.
 // Almost everyone should use signature.Blob() instead.
 func (s SimpleSigning) blobChunk() ([]byte, error) {
-	return bytes.Clone(s.untrustedSignature), nil
+	if s.untrustedTimestampToken == nil {
+		return bytes.Clone(s.untrustedSignature), nil
+	}
+	return json.Marshal(simpleSigningWithTimestampJSONRepresentation{
+		UntrustedSignature:      s.UntrustedSignature(),
+		UntrustedTimestampToken: s.UntrustedTimestampToken(),
+	})
 }
 
 func (s SimpleSigning) UntrustedSignature() []byte {
 	return bytes.Clone(s.untrustedSignature)
 }
+
+// UntrustedTimestampToken returns the RFC 3161 timestamp token of UntrustedSignature(), or nil if there is none.
+func (s SimpleSigning) UntrustedTimestampToken() []byte {
+	return bytes.Clone(s.untrustedTimestampToken)
+}
@@ -14,6 +14,14 @@ func TestSimpleSigningFromBlob(t *testing.T) {
 	assert.Equal(t, SimpleSigning{untrustedSignature: data}, sig)
 }
 
+func TestSimpleSigningWithTimestampToken(t *testing.T) {
+	var data = []byte("some contents")
+	var token = []byte("timestamp token")
+
+	sig := SimpleSigningWithTimestampToken(SimpleSigningFromBlob(data), token)
+	assert.Equal(t, SimpleSigning{untrustedSignature: data, untrustedTimestampToken: token}, sig)
+}
+
 func TestSimpleSigningFormatID(t *testing.T) {
 	sig := SimpleSigningFromBlob([]byte("some contents"))
 	assert.Equal(t, SimpleSigningFormat, sig.FormatID())
@@ -26,6 +34,13 @@ func TestSimpleSigningBlobChunk(t *testing.T) {
 	chunk, err := sig.blobChunk()
 	require.NoError(t, err)
 	assert.Equal(t, data, chunk)
+
+	sig = SimpleSigningWithTimestampToken(sig, []byte("timestamp token"))
+	chunk, err = sig.blobChunk()
+	require.NoError(t, err)
+	sig2, err := simpleSigningFromTimestampedBlobChunk(chunk)
+	require.NoError(t, err)
+	assert.Equal(t, sig, sig2)
 }
 
 func TestSimpleSigningUntrustedSignature(t *testing.T) {
@@ -34,3 +49,11 @@ func TestSimpleSigningUntrustedSignature(t *testing.T) {
 	sig := SimpleSigningFromBlob(data)
 	assert.Equal(t, data, sig.UntrustedSignature())
 }
+
+func TestSimpleSigningUntrustedTimestampToken(t *testing.T) {
+	sig := SimpleSigningFromBlob([]byte("some contents"))
+	assert.Nil(t, sig.UntrustedTimestampToken())
+
+	sig = SimpleSigningWithTimestampToken(sig, []byte("timestamp token"))
+	assert.Equal(t, []byte("timestamp token"), sig.UntrustedTimestampToken())
+}
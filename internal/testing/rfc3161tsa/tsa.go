@@ -0,0 +1,194 @@
+// Package rfc3161tsa implements a minimal RFC 3161 time-stamping authority, for use in tests.
+package rfc3161tsa
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/smallstep/pkcs7"
+)
+
+var (
+	oidTSTInfo   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+	oidSHA256    = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidTSAPolicy = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 1} // An arbitrary value, only for tests
+)
+
+type messageImprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+type timeStampReq struct {
+	Version        int
+	MessageImprint messageImprint
+	ReqPolicy      asn1.ObjectIdentifier `asn1:"optional"`
+	Nonce          *big.Int              `asn1:"optional"`
+	CertReq        bool                  `asn1:"optional"`
+}
+
+type pkiStatusInfo struct {
+	Status int
+}
+
+type timeStampResp struct {
+	Status         pkiStatusInfo
+	TimeStampToken asn1.RawValue `asn1:"optional"`
+}
+
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint messageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time `asn1:"generalized"`
+	Nonce          *big.Int  `asn1:"optional"`
+}
+
+// TSA is a time-stamping authority with a freshly generated root CA and signing certificate.
+type TSA struct {
+	RootCertificatePEM []byte // The root CA certificate, in PEM format
+
+	key         crypto.Signer
+	certificate *x509.Certificate
+	serial      atomic.Int64
+}
+
+// New returns a new TSA.
+func New() (*TSA, error) {
+	now := time.Now()
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	rootTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test TSA root"},
+		NotBefore:             now.Add(-24 * time.Hour),
+		NotAfter:              now.Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	rootDER, err := x509.CreateCertificate(rand.Reader, rootTemplate, rootTemplate, rootKey.Public(), rootKey)
+	if err != nil {
+		return nil, err
+	}
+	root, err := x509.ParseCertificate(rootDER)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "Test TSA"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, root, key.Public(), rootKey)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TSA{
+		RootCertificatePEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: rootDER}),
+		key:                key,
+		certificate:        cert,
+	}, nil
+}
+
+// Token returns a timestamp token asserting that data existed at genTime.
+func (tsa *TSA) Token(data []byte, genTime time.Time) ([]byte, error) {
+	digest := crypto.SHA256.New()
+	digest.Write(data)
+	return tsa.token(messageImprint{
+		HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue},
+		HashedMessage: digest.Sum(nil),
+	}, genTime, nil)
+}
+
+// token returns a timestamp token for imprint at genTime, with an optional nonce.
+func (tsa *TSA) token(imprint messageImprint, genTime time.Time, nonce *big.Int) ([]byte, error) {
+	info, err := asn1.Marshal(tstInfo{
+		Version:        1,
+		Policy:         oidTSAPolicy,
+		MessageImprint: imprint,
+		SerialNumber:   big.NewInt(tsa.serial.Add(1)),
+		GenTime:        genTime.UTC().Truncate(time.Second),
+		Nonce:          nonce,
+	})
+	if err != nil {
+		return nil, err
+	}
+	sd, err := pkcs7.NewSignedData(info)
+	if err != nil {
+		return nil, err
+	}
+	sd.GetSignedData().ContentInfo.ContentType = oidTSTInfo
+	sd.SetDigestAlgorithm(pkcs7.OIDDigestAlgorithmSHA256)
+	if err := sd.AddSigner(tsa.certificate, tsa.key, pkcs7.SignerInfoConfig{}); err != nil {
+		return nil, err
+	}
+	return sd.Finish()
+}
+
+// Respond returns a DER-encoded TimeStampResp for a DER-encoded TimeStampReq.
+func (tsa *TSA) Respond(request []byte) ([]byte, error) {
+	var req timeStampReq
+	rest, err := asn1.Unmarshal(request, &req)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 || req.Version != 1 || !req.MessageImprint.HashAlgorithm.Algorithm.Equal(oidSHA256) {
+		return asn1.Marshal(timeStampResp{Status: pkiStatusInfo{Status: 2}}) // rejection
+	}
+	token, err := tsa.token(req.MessageImprint, time.Now(), req.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(timeStampResp{
+		Status:         pkiStatusInfo{Status: 0}, // granted
+		TimeStampToken: asn1.RawValue{FullBytes: token},
+	})
+}
+
+// ServeHTTP implements http.Handler, serving RFC 3161 requests over HTTP.
+func (tsa *TSA) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/timestamp-query" {
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+		return
+	}
+	req, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp, err := tsa.Respond(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/timestamp-reply")
+	_, _ = w.Write(resp)
+}
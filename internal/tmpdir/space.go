@@ -0,0 +1,36 @@
+package tmpdir
+
+import (
+	"fmt"
+
+	"github.com/containers/image/v5/types"
+	"github.com/sirupsen/logrus"
+)
+
+// InsufficientSpaceError is returned when the directory for temporary big files
+// does not have enough free space for data which would be stored there.
+type InsufficientSpaceError struct {
+	Directory string // The directory for temporary big files
+	Required  uint64 // The estimated number of bytes required
+	Available uint64 // The number of bytes available to the current user
+}
+
+func (e InsufficientSpaceError) Error() string {
+	return fmt.Sprintf("insufficient space in temporary directory %q: about %d bytes required, %d available", e.Directory, e.Required, e.Available)
+}
+
+// CheckSpaceForBigFiles returns an InsufficientSpaceError if the directory for temporary big files
+// does not have at least required bytes available.
+// If the available space can not be determined, it only logs the failure and succeeds.
+func CheckSpaceForBigFiles(sys *types.SystemContext, required uint64) error {
+	dir := temporaryDirectoryForBigFiles(sys)
+	available, err := availableSpace(dir)
+	if err != nil {
+		logrus.Debugf("Not checking available space in %q: %v", dir, err)
+		return nil
+	}
+	if available < required {
+		return InsufficientSpaceError{Directory: dir, Required: required, Available: available}
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+package tmpdir
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// availableSpace returns the number of bytes available to the current user in the filesystem containing dir.
+func availableSpace(dir string) (uint64, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(dir, &st); err != nil {
+		return 0, err
+	}
+	return st.Bavail * uint64(st.Bsize), nil
+}
@@ -0,0 +1,34 @@
+package tmpdir
+
+import (
+	"math"
+	"runtime"
+	"testing"
+
+	"github.com/containers/image/v5/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckSpaceForBigFiles(t *testing.T) {
+	dir := t.TempDir()
+	sys := &types.SystemContext{BigFilesTemporaryDir: dir}
+
+	err := CheckSpaceForBigFiles(sys, 0)
+	assert.NoError(t, err)
+
+	err = CheckSpaceForBigFiles(sys, math.MaxUint64)
+	if runtime.GOOS != "linux" {
+		assert.NoError(t, err) // The check is not implemented.
+		return
+	}
+	var spaceErr InsufficientSpaceError
+	require.ErrorAs(t, err, &spaceErr)
+	assert.Equal(t, dir, spaceErr.Directory)
+	assert.Equal(t, uint64(math.MaxUint64), spaceErr.Required)
+	assert.Less(t, spaceErr.Available, spaceErr.Required)
+
+	// A nonexistent directory is not checked; creating the files will fail instead.
+	err = CheckSpaceForBigFiles(&types.SystemContext{BigFilesTemporaryDir: "/this/does/not/exist"}, math.MaxUint64)
+	assert.NoError(t, err)
+}
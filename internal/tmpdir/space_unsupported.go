@@ -0,0 +1,12 @@
+//go:build !linux
+
+package tmpdir
+
+import (
+	"errors"
+)
+
+// availableSpace returns the number of bytes available to the current user in the filesystem containing dir.
+func availableSpace(dir string) (uint64, error) {
+	return 0, errors.New("determining available space is not supported on this platform")
+}
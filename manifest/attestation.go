@@ -0,0 +1,39 @@
+package manifest
+
+import (
+	digest "github.com/opencontainers/go-digest"
+)
+
+// Annotations used by BuildKit to attach attestation manifests (SBOMs, provenance) to images in an image index,
+// without using the OCI “subject” field.
+const (
+	// DockerReferenceTypeAnnotation is the annotation of an index entry which identifies the kind of the referring manifest.
+	DockerReferenceTypeAnnotation = "vnd.docker.reference.type"
+	// DockerReferenceDigestAnnotation is the annotation of an index entry which contains the digest of the referred-to image manifest.
+	DockerReferenceDigestAnnotation = "vnd.docker.reference.digest"
+	// DockerReferenceTypeAttestationManifest is the value of DockerReferenceTypeAnnotation for attestation manifests.
+	DockerReferenceTypeAttestationManifest = "attestation-manifest"
+)
+
+// DockerAttestationManifestAnnotations returns the annotations of an index entry for an attestation manifest
+// which refers to the image manifest with digest subject.
+func DockerAttestationManifestAnnotations(subject digest.Digest) map[string]string {
+	return map[string]string{
+		DockerReferenceTypeAnnotation:   DockerReferenceTypeAttestationManifest,
+		DockerReferenceDigestAnnotation: subject.String(),
+	}
+}
+
+// DockerAttestationManifestSubject returns the digest of the image manifest referred to by an index entry with annotations,
+// and true, if the entry is an attestation manifest.
+// It returns false if the entry is not an attestation manifest, or if the referred-to digest is missing or invalid.
+func DockerAttestationManifestSubject(annotations map[string]string) (digest.Digest, bool) {
+	if annotations[DockerReferenceTypeAnnotation] != DockerReferenceTypeAttestationManifest {
+		return "", false
+	}
+	subject, err := digest.Parse(annotations[DockerReferenceDigestAnnotation])
+	if err != nil {
+		return "", false
+	}
+	return subject, true
+}
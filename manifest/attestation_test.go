@@ -0,0 +1,32 @@
+package manifest
+
+import (
+	"testing"
+
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDockerAttestationManifestAnnotations(t *testing.T) {
+	subject := digest.Digest("sha256:e692418e4cbaf90ca69d05a66403747baa33ee08806650b51fab815ad7fc331f")
+	annotations := DockerAttestationManifestAnnotations(subject)
+	assert.Equal(t, map[string]string{
+		"vnd.docker.reference.type":   "attestation-manifest",
+		"vnd.docker.reference.digest": subject.String(),
+	}, annotations)
+	res, ok := DockerAttestationManifestSubject(annotations)
+	assert.True(t, ok)
+	assert.Equal(t, subject, res)
+
+	for _, c := range []map[string]string{
+		nil,
+		{},
+		{"org.opencontainers.image.title": "x"},
+		{DockerReferenceTypeAnnotation: "something-else", DockerReferenceDigestAnnotation: subject.String()},
+		{DockerReferenceTypeAnnotation: DockerReferenceTypeAttestationManifest},
+		{DockerReferenceTypeAnnotation: DockerReferenceTypeAttestationManifest, DockerReferenceDigestAnnotation: "sha256:invalid"},
+	} {
+		_, ok := DockerAttestationManifestSubject(c)
+		assert.False(t, ok, c)
+	}
+}
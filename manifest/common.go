@@ -2,6 +2,7 @@ package manifest
 
 import (
 	"fmt"
+	"strings"
 
 	compressiontypes "github.com/containers/image/v5/pkg/compression/types"
 	"github.com/containers/image/v5/types"
@@ -128,6 +129,27 @@ func (m ManifestLayerCompressionIncompatibilityError) Error() string {
 	return m.text
 }
 
+// ManifestLayerEncryptionIncompatibilityError indicates that encrypted layers can not be represented
+// using a manifest MIME type.  A caller that receives this should either decrypt the layers, or attempt to use
+// a different manifest type (see MIMETypeSupportsEncryption).
+type ManifestLayerEncryptionIncompatibilityError struct {
+	ManifestMIMETypes []string // The manifest MIME types which were considered; none of them support encryption
+	LayerMIMEType     string   // The MIME type of the encrypted layer, or "" if the layers were only going to be encrypted
+}
+
+func (m ManifestLayerEncryptionIncompatibilityError) Error() string {
+	var formats string
+	if len(m.ManifestMIMETypes) == 1 {
+		formats = fmt.Sprintf("format %s, which does not support encryption", m.ManifestMIMETypes[0])
+	} else {
+		formats = fmt.Sprintf("MIME types [%s], none of which support encryption", strings.Join(m.ManifestMIMETypes, ", "))
+	}
+	if m.LayerMIMEType != "" {
+		return fmt.Sprintf("encrypted layers (%q) can not be represented using %s", m.LayerMIMEType, formats)
+	}
+	return fmt.Sprintf("encryption required together with %s", formats)
+}
+
 // compressionVariantsRecognizeMIMEType returns true if variantTable contains data about compressing/decompressing layers with mimeType
 // Note that the caller still needs to worry about a specific algorithm not being supported.
 func compressionVariantsRecognizeMIMEType(variantTable []compressionMIMETypeSet, mimeType string) bool {
@@ -150,3 +172,36 @@ func imgInspectLayersFromLayerInfos(infos []LayerInfo) []types.ImageInspectLayer
 	}
 	return layers
 }
+
+// layerMediaTypeProperties returns the compression variant (a compressionMIMETypeSet key) of a layer mimeType,
+// whether it is a “nondistributable” (“foreign”) layer type, and true; or false if mimeType is not a recognized layer type.
+func layerMediaTypeProperties(mimeType string) (string, bool, bool) {
+	if mimeType == mtsUnsupportedMIMEType {
+		return "", false, false
+	}
+	for _, variantTable := range [][]compressionMIMETypeSet{schema2CompressionMIMETypeSets, oci1CompressionMIMETypeSets} {
+		for i, variants := range variantTable {
+			for algo, mt := range variants {
+				if mt == mimeType {
+					return algo, i == 0, true // The first set in each table contains the nondistributable layer types
+				}
+			}
+		}
+	}
+	return "", false, false
+}
+
+// LayerMediaTypesEquivalent returns true if a and b are recognized layer media types which only differ in the manifest format
+// they are associated with, or in whether they are “nondistributable” (e.g. DockerV2Schema2LayerMediaType and
+// imgspecv1.MediaTypeImageLayerGzip), i.e. if a layer can be relabeled from one to the other without modifying its contents.
+func LayerMediaTypesEquivalent(a, b string) bool {
+	aAlgo, _, aOK := layerMediaTypeProperties(a)
+	bAlgo, _, bOK := layerMediaTypeProperties(b)
+	return aOK && bOK && aAlgo == bAlgo
+}
+
+// IsNondistributableLayerMediaType returns true if mimeType is a recognized “nondistributable” (“foreign”) layer media type.
+func IsNondistributableLayerMediaType(mimeType string) bool {
+	_, nondistributable, ok := layerMediaTypeProperties(mimeType)
+	return ok && nondistributable
+}
@@ -326,3 +326,68 @@ func TestUpdatedMIMEType(t *testing.T) {
 
 	require.Equalf(t, len(preserve), len(compressZstdSuccess)+len(compressZstdFailure), "missing some zstd compression tests")
 }
+
+func TestLayerMediaTypesEquivalent(t *testing.T) {
+	for _, c := range []struct {
+		a, b     string
+		expected bool
+	}{
+		{DockerV2Schema2LayerMediaType, imgspecv1.MediaTypeImageLayerGzip, true},
+		{imgspecv1.MediaTypeImageLayerGzip, DockerV2Schema2LayerMediaType, true},
+		{DockerV2SchemaLayerMediaTypeUncompressed, imgspecv1.MediaTypeImageLayer, true},
+		{imgspecv1.MediaTypeImageLayerNonDistributableZstd, imgspecv1.MediaTypeImageLayerZstd, true}, //nolint:staticcheck // NonDistributable layers are deprecated, but we want to continue to support manipulating pre-existing images.
+		{DockerV2Schema2ForeignLayerMediaTypeGzip, imgspecv1.MediaTypeImageLayerGzip, true},
+		{imgspecv1.MediaTypeImageLayerGzip, imgspecv1.MediaTypeImageLayerGzip, true},
+		{imgspecv1.MediaTypeImageLayerGzip, imgspecv1.MediaTypeImageLayerZstd, false},
+		{DockerV2Schema2LayerMediaType, DockerV2SchemaLayerMediaTypeUncompressed, false},
+		{imgspecv1.MediaTypeImageLayerGzip, "application/vnd.example.unknown", false},
+		{imgspecv1.MediaTypeImageLayerZstd, "", false},
+		{"application/vnd.example.unknown", "application/vnd.example.unknown", false},
+	} {
+		res := LayerMediaTypesEquivalent(c.a, c.b)
+		assert.Equal(t, c.expected, res, "%q vs. %q", c.a, c.b)
+	}
+}
+
+func TestIsNondistributableLayerMediaType(t *testing.T) {
+	for _, c := range []struct {
+		mimeType string
+		expected bool
+	}{
+		{DockerV2Schema2ForeignLayerMediaType, true},
+		{DockerV2Schema2ForeignLayerMediaTypeGzip, true},
+		{imgspecv1.MediaTypeImageLayerNonDistributableGzip, true}, //nolint:staticcheck // NonDistributable layers are deprecated, but we want to continue to support manipulating pre-existing images.
+		{DockerV2Schema2LayerMediaType, false},
+		{imgspecv1.MediaTypeImageLayerZstd, false},
+		{"application/vnd.example.unknown", false},
+		{"", false},
+	} {
+		res := IsNondistributableLayerMediaType(c.mimeType)
+		assert.Equal(t, c.expected, res, c.mimeType)
+	}
+}
+
+func TestManifestLayerEncryptionIncompatibilityError(t *testing.T) {
+	for _, c := range []struct {
+		err      ManifestLayerEncryptionIncompatibilityError
+		expected string
+	}{
+		{
+			ManifestLayerEncryptionIncompatibilityError{ManifestMIMETypes: []string{DockerV2Schema2MediaType}},
+			"encryption required together with format " + DockerV2Schema2MediaType + ", which does not support encryption",
+		},
+		{
+			ManifestLayerEncryptionIncompatibilityError{ManifestMIMETypes: []string{DockerV2Schema1SignedMediaType, DockerV2Schema2MediaType}},
+			"encryption required together with MIME types [" + DockerV2Schema1SignedMediaType + ", " + DockerV2Schema2MediaType + "], none of which support encryption",
+		},
+		{
+			ManifestLayerEncryptionIncompatibilityError{
+				ManifestMIMETypes: []string{DockerV2Schema2MediaType},
+				LayerMIMEType:     "application/vnd.oci.image.layer.v1.tar+gzip+encrypted",
+			},
+			`encrypted layers ("application/vnd.oci.image.layer.v1.tar+gzip+encrypted") can not be represented using format ` + DockerV2Schema2MediaType + ", which does not support encryption",
+		},
+	} {
+		assert.Equal(t, c.expected, c.err.Error())
+	}
+}
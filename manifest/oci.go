@@ -79,6 +79,59 @@ func OCI1FromComponents(config imgspecv1.Descriptor, layers []imgspecv1.Descript
 	}
 }
 
+// OCI1ArtifactFromComponents creates an OCI1 manifest instance for a config-less artifact of artifactType
+// (which should not be ""), using the imgspecv1.MediaTypeEmptyJSON config convention.
+// The config blob is OCI1EmptyJSONConfigBlob(); it is also embedded in the config descriptor, but
+// it must still be written to the destination.
+func OCI1ArtifactFromComponents(artifactType string, layers []imgspecv1.Descriptor) *OCI1 {
+	m := OCI1FromComponents(imgspecv1.DescriptorEmptyJSON, layers)
+	m.Config.Data = slices.Clone(imgspecv1.DescriptorEmptyJSON.Data)
+	m.ArtifactType = artifactType
+	return m
+}
+
+// OCI1EmptyJSONConfigBlob returns the contents of the imgspecv1.MediaTypeEmptyJSON blob.
+func OCI1EmptyJSONConfigBlob() []byte {
+	return []byte("{}")
+}
+
+// IsOCI1EmptyJSONConfig returns true if info refers to a imgspecv1.MediaTypeEmptyJSON config.
+// It does not check that info is valid; use ValidateOCI1EmptyJSONConfig for that.
+func IsOCI1EmptyJSONConfig(info types.BlobInfo) bool {
+	return info.MediaType == imgspecv1.MediaTypeEmptyJSON
+}
+
+// ValidateOCI1EmptyJSONConfig returns an error if info, a imgspecv1.MediaTypeEmptyJSON descriptor, and blob, the
+// corresponding blob (or nil if not available) don’t match the constraints of the empty config convention.
+func ValidateOCI1EmptyJSONConfig(info types.BlobInfo, blob []byte) error {
+	if info.MediaType != imgspecv1.MediaTypeEmptyJSON {
+		return fmt.Errorf("config media type is %q, not %q", info.MediaType, imgspecv1.MediaTypeEmptyJSON)
+	}
+	if info.Digest != imgspecv1.DescriptorEmptyJSON.Digest || info.Size != imgspecv1.DescriptorEmptyJSON.Size {
+		return fmt.Errorf("%q config must have digest %s and size %d, not %s and %d", imgspecv1.MediaTypeEmptyJSON,
+			imgspecv1.DescriptorEmptyJSON.Digest, imgspecv1.DescriptorEmptyJSON.Size, info.Digest, info.Size)
+	}
+	if blob != nil && string(blob) != string(OCI1EmptyJSONConfigBlob()) {
+		return fmt.Errorf("%q config contents %q are not %q", imgspecv1.MediaTypeEmptyJSON, string(blob), string(OCI1EmptyJSONConfigBlob()))
+	}
+	return nil
+}
+
+// ValidateEmptyJSONConfig returns an error if m uses the imgspecv1.MediaTypeEmptyJSON config convention,
+// but does not match its constraints.
+func (m *OCI1) ValidateEmptyJSONConfig() error {
+	if !IsOCI1EmptyJSONConfig(m.ConfigInfo()) {
+		return nil
+	}
+	if err := ValidateOCI1EmptyJSONConfig(m.ConfigInfo(), m.Config.Data); err != nil {
+		return err
+	}
+	if m.ArtifactType == "" {
+		return fmt.Errorf("artifactType must be set in manifests with a %q config", imgspecv1.MediaTypeEmptyJSON)
+	}
+	return nil
+}
+
 // OCI1Clone creates a copy of the supplied OCI1 manifest.
 func OCI1Clone(src *OCI1) *OCI1 {
 	return &OCI1{
@@ -135,7 +188,7 @@ func (m *OCI1) UpdateLayerInfos(layerInfos []types.BlobInfo) error {
 	m.Layers = make([]imgspecv1.Descriptor, len(layerInfos))
 	for i, info := range layerInfos {
 		mimeType := original[i].MediaType
-		if info.CryptoOperation == types.Decrypt {
+		if info.CryptoOperation == types.Decrypt || info.CryptoOperation == types.Reencrypt {
 			decMimeType, err := getDecryptedMediaType(mimeType)
 			if err != nil {
 				return fmt.Errorf("error preparing updated manifest: decryption specified but original mediatype is not encrypted: %q", mimeType)
@@ -146,7 +199,7 @@ func (m *OCI1) UpdateLayerInfos(layerInfos []types.BlobInfo) error {
 		if err != nil {
 			return fmt.Errorf("preparing updated manifest, layer %q: %w", info.Digest, err)
 		}
-		if info.CryptoOperation == types.Encrypt {
+		if info.CryptoOperation == types.Encrypt || info.CryptoOperation == types.Reencrypt {
 			encMediaType, err := getEncryptedMediaType(mimeType)
 			if err != nil {
 				return fmt.Errorf("error preparing updated manifest: encryption specified but no counterpart for mediatype: %q", mimeType)
@@ -76,6 +76,71 @@ func TestOCI1Clone(t *testing.T) {
 	assert.Equal(t, m.Manifest, clone.Manifest)
 }
 
+func TestOCI1ArtifactFromComponents(t *testing.T) {
+	layer := imgspecv1.Descriptor{
+		MediaType: "application/vnd.example.data",
+		Digest:    digest.FromString("data"),
+		Size:      4,
+	}
+	m := OCI1ArtifactFromComponents("application/vnd.example.artifact", []imgspecv1.Descriptor{layer})
+	assert.Equal(t, "application/vnd.example.artifact", m.ArtifactType)
+	assert.Equal(t, []imgspecv1.Descriptor{layer}, m.Layers)
+	assert.True(t, IsOCI1EmptyJSONConfig(m.ConfigInfo()))
+	assert.Equal(t, digest.FromBytes(OCI1EmptyJSONConfigBlob()), m.Config.Digest)
+	assert.Equal(t, int64(len(OCI1EmptyJSONConfigBlob())), m.Config.Size)
+	assert.NoError(t, m.ValidateEmptyJSONConfig())
+
+	// The result round-trips through the serialized form
+	blob, err := m.Serialize()
+	require.NoError(t, err)
+	m2, err := OCI1FromManifest(blob)
+	require.NoError(t, err)
+	assert.Equal(t, m, m2)
+
+	// Modifying the embedded data does not affect imgspecv1.DescriptorEmptyJSON
+	m.Config.Data[0] = 'x'
+	assert.Equal(t, []byte("{}"), imgspecv1.DescriptorEmptyJSON.Data)
+}
+
+func TestValidateOCI1EmptyJSONConfig(t *testing.T) {
+	valid := BlobInfoFromOCI1Descriptor(imgspecv1.DescriptorEmptyJSON)
+	err := ValidateOCI1EmptyJSONConfig(valid, nil)
+	assert.NoError(t, err)
+	err = ValidateOCI1EmptyJSONConfig(valid, []byte("{}"))
+	assert.NoError(t, err)
+
+	for _, c := range []struct {
+		info types.BlobInfo
+		blob []byte
+	}{
+		{types.BlobInfo{MediaType: imgspecv1.MediaTypeImageConfig, Digest: valid.Digest, Size: valid.Size}, nil},
+		{types.BlobInfo{MediaType: valid.MediaType, Digest: digest.FromString("{ }"), Size: 3}, nil},
+		{types.BlobInfo{MediaType: valid.MediaType, Digest: valid.Digest, Size: 3}, nil},
+		{valid, []byte("{ }")},
+		{valid, []byte{}},
+	} {
+		err := ValidateOCI1EmptyJSONConfig(c.info, c.blob)
+		assert.Error(t, err, "%#v, %q", c.info, string(c.blob))
+	}
+}
+
+func TestOCI1ValidateEmptyJSONConfig(t *testing.T) {
+	// Not using the empty config convention
+	m := manifestOCI1FromFixture(t, "ociv1.manifest.json")
+	assert.NoError(t, m.ValidateEmptyJSONConfig())
+
+	m = OCI1ArtifactFromComponents("", nil)
+	assert.Error(t, m.ValidateEmptyJSONConfig())
+
+	m = OCI1ArtifactFromComponents("application/vnd.example.artifact", nil)
+	m.Config.Data = []byte("[]")
+	assert.Error(t, m.ValidateEmptyJSONConfig())
+
+	m = OCI1ArtifactFromComponents("application/vnd.example.artifact", nil)
+	m.Config.Size = 3
+	assert.Error(t, m.ValidateEmptyJSONConfig())
+}
+
 func TestOCI1UpdateLayerInfos(t *testing.T) {
 	customCompression := compression.Algorithm{}
 
@@ -350,6 +415,58 @@ func TestOCI1UpdateLayerInfos(t *testing.T) {
 			},
 			expectedFixture: "ociv1.uncompressed.manifest.json",
 		},
+		{
+			name:          "gzip encrypted → gzip re-encrypted",
+			sourceFixture: "ociv1.encrypted.manifest.json",
+			updates: []types.BlobInfo{
+				{
+					Digest:          "sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+					Size:            32654,
+					Annotations:     map[string]string{"org.opencontainers.image.enc.…": "layer1"},
+					MediaType:       "application/vnd.oci.image.layer.v1.tar+gzip+encrypted",
+					CryptoOperation: types.Reencrypt,
+				},
+				{
+					Digest:          "sha256:bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb",
+					Size:            16724,
+					Annotations:     map[string]string{"org.opencontainers.image.enc.…": "layer2"},
+					MediaType:       "application/vnd.oci.image.layer.v1.tar+gzip+encrypted",
+					CryptoOperation: types.Reencrypt,
+				},
+				{
+					Digest:          "sha256:cccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccc",
+					Size:            73109,
+					Annotations:     map[string]string{"org.opencontainers.image.enc.…": "layer2"},
+					MediaType:       "application/vnd.oci.image.layer.v1.tar+gzip+encrypted",
+					CryptoOperation: types.Reencrypt,
+				},
+			},
+			expectedFixture: "ociv1.encrypted.manifest.json",
+		},
+		{
+			name:          "uncompressed → re-encrypted",
+			sourceFixture: "ociv1.uncompressed.manifest.json",
+			updates: []types.BlobInfo{
+				{
+					Digest:          "sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+					Size:            32654,
+					MediaType:       imgspecv1.MediaTypeImageLayer,
+					CryptoOperation: types.Reencrypt,
+				},
+				{
+					Digest:          "sha256:bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb",
+					Size:            16724,
+					MediaType:       imgspecv1.MediaTypeImageLayer,
+					CryptoOperation: types.Reencrypt,
+				},
+				{
+					Digest:          "sha256:cccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccc",
+					Size:            73109,
+					MediaType:       imgspecv1.MediaTypeImageLayer,
+					CryptoOperation: types.Reencrypt,
+				},
+			},
+		},
 	} {
 		manifest := manifestOCI1FromFixture(t, c.sourceFixture)
 
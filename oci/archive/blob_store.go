@@ -0,0 +1,359 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containers/image/v5/internal/archiveprogress"
+	"github.com/containers/image/v5/internal/seekablezstd"
+	"github.com/containers/image/v5/internal/set"
+	digest "github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sirupsen/logrus"
+)
+
+// archiveBlobStore is a types.OCILayoutBlobStore which writes blobs directly into an oci-archive file,
+// as requested by types.SystemContext.OCIArchiveDirectWrite.
+// Blobs can only be written, not read back; index.json and oci-layout are added by finish.
+type archiveBlobStore struct {
+	path        string
+	file        *os.File
+	regularFile bool                      // path is a regular file, so it can be removed if the archive is not completed
+	compressor  *seekablezstd.Writer      // nil if the archive is not compressed
+	progress    *archiveprogress.Reporter // nil if progress of writing the archive should not be reported
+
+	mutex    sync.Mutex // Protects the fields below
+	tar      *tar.Writer
+	dirs     *set.Set[string]        // Directories already present in the archive
+	blobs    map[digest.Digest]int64 // Sizes of blobs already present in the archive
+	finished bool                    // finish or abort has been called
+}
+
+// newArchiveBlobStore creates the archive at path, and returns an archiveBlobStore writing to it.
+// if seekableZstd, the archive is compressed as a seekable zstd stream
+// progress, if not nil, is used to report progress of writing the archive
+func newArchiveBlobStore(path string, seekableZstd bool, progress *archiveprogress.Reporter) (*archiveBlobStore, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("creating tar file %q: %w", path, err)
+	}
+	s := &archiveBlobStore{
+		path:     path,
+		file:     file,
+		progress: progress,
+		dirs:     set.New[string](),
+		blobs:    map[digest.Digest]int64{},
+	}
+	if fi, err := file.Stat(); err == nil {
+		s.regularFile = fi.Mode().IsRegular()
+	}
+	var dest io.Writer = file
+	if seekableZstd {
+		compressor, err := seekablezstd.NewWriter(file)
+		if err != nil {
+			s.abort()
+			return nil, err
+		}
+		s.compressor = compressor
+		dest = compressor
+	}
+	s.tar = tar.NewWriter(progress.Writer(dest))
+	return s, nil
+}
+
+// GetBlob is not supported: the blobs are only written to the archive.
+func (s *archiveBlobStore) GetBlob(ctx context.Context, layoutDir string, blobDigest digest.Digest) (io.ReadCloser, int64, error) {
+	if _, err := s.BlobSize(ctx, layoutDir, blobDigest); err != nil {
+		return nil, -1, err
+	}
+	return nil, -1, fmt.Errorf("reading blob %s: blobs of an oci-archive being written can not be read", blobDigest.String())
+}
+
+// BlobSize returns the size of the blob with blobDigest, if it has already been written to the archive.
+func (s *archiveBlobStore) BlobSize(ctx context.Context, layoutDir string, blobDigest digest.Digest) (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	size, ok := s.blobs[blobDigest]
+	if !ok {
+		return -1, fmt.Errorf("blob %s: %w", blobDigest.String(), fs.ErrNotExist)
+	}
+	return size, nil
+}
+
+// PutBlob writes stream, of size bytes, to the archive as the blob with blobDigest.
+func (s *archiveBlobStore) PutBlob(ctx context.Context, layoutDir string, blobDigest digest.Digest, stream io.Reader, size int64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.finished {
+		return errors.New("Internal error: writing a blob to a closed oci-archive")
+	}
+	if _, ok := s.blobs[blobDigest]; ok {
+		return nil // Blobs are content-addressed, so the archive already contains the same data.
+	}
+	dir := path.Join(imgspecv1.ImageBlobsDir, blobDigest.Algorithm().String())
+	if err := s.ensureDirectoryLocked(imgspecv1.ImageBlobsDir); err != nil {
+		return err
+	}
+	if err := s.ensureDirectoryLocked(dir); err != nil {
+		return err
+	}
+	if err := s.writeFileLocked(path.Join(dir, blobDigest.Encoded()), stream, size); err != nil {
+		return fmt.Errorf("writing blob %s to the archive: %w", blobDigest.String(), err)
+	}
+	s.blobs[blobDigest] = size
+	return nil
+}
+
+// DeleteBlob is not supported: the blobs are only written to the archive.
+func (s *archiveBlobStore) DeleteBlob(ctx context.Context, layoutDir string, blobDigest digest.Digest) error {
+	return fmt.Errorf("deleting blob %s: blobs of an oci-archive being written can not be deleted", blobDigest.String())
+}
+
+// ensureDirectoryLocked adds a directory entry for dir to the archive, if it is not present yet.
+// The caller must hold s.mutex.
+func (s *archiveBlobStore) ensureDirectoryLocked(dir string) error {
+	if s.dirs.Contains(dir) {
+		return nil
+	}
+	if err := s.writeHeaderLocked(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     dir + "/",
+		Mode:     0o755,
+		ModTime:  time.Now(),
+	}); err != nil {
+		return err
+	}
+	s.dirs.Add(dir)
+	return nil
+}
+
+// writeFileLocked adds a regular file at path, with contents of size bytes, to the archive.
+// The caller must hold s.mutex.
+func (s *archiveBlobStore) writeFileLocked(path string, contents io.Reader, size int64) error {
+	if err := s.writeHeaderLocked(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     path,
+		Mode:     0o644,
+		Size:     size,
+		ModTime:  time.Now(),
+	}); err != nil {
+		return err
+	}
+	// TODO: This can take quite some time, and should ideally be cancellable using a context.Context.
+	n, err := io.Copy(s.tar, contents)
+	if err != nil {
+		return err
+	}
+	if n != size {
+		return fmt.Errorf("size mismatch, expected %d, got %d", size, n)
+	}
+	return nil
+}
+
+// writeHeaderLocked writes hdr to the archive, starting a new entry of the seekable zstd index if appropriate.
+// The caller must hold s.mutex.
+func (s *archiveBlobStore) writeHeaderLocked(hdr *tar.Header) error {
+	if s.compressor != nil {
+		if err := s.tar.Flush(); err != nil { // Terminate the previous entry, so that the new frame starts at the header
+			return err
+		}
+		if err := s.compressor.StartEntry(path.Clean(hdr.Name)); err != nil {
+			return err
+		}
+	}
+	return s.tar.WriteHeader(hdr)
+}
+
+// finish adds the oci-layout and index.json files from layoutDir to the archive, and completes it.
+func (s *archiveBlobStore) finish(layoutDir string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.finished {
+		return errors.New("Internal error: finishing a closed oci-archive")
+	}
+	// Per the OCI image specification, layouts MUST have a "blobs" subdirectory, even if it is empty.
+	if err := s.ensureDirectoryLocked(imgspecv1.ImageBlobsDir); err != nil {
+		return err
+	}
+	for _, name := range []string{imgspecv1.ImageLayoutFile, imgspecv1.ImageIndexFile} {
+		contents, err := os.ReadFile(filepath.Join(layoutDir, name))
+		if err != nil {
+			return err
+		}
+		if err := s.writeFileLocked(name, bytes.NewReader(contents), int64(len(contents))); err != nil {
+			return fmt.Errorf("writing %s to the archive: %w", name, err)
+		}
+	}
+	if err := s.tar.Close(); err != nil {
+		return err
+	}
+	if s.compressor != nil {
+		if err := s.compressor.Close(); err != nil {
+			return err
+		}
+	}
+	s.finished = true
+	if err := s.file.Close(); err != nil {
+		return fmt.Errorf("closing tar file %q: %w", s.path, err)
+	}
+	s.progress.Done()
+	return nil
+}
+
+// abort closes the archive, and removes it if it is a regular file, unless finish has succeeded.
+func (s *archiveBlobStore) abort() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.finished {
+		return
+	}
+	s.finished = true
+	if err := s.file.Close(); err != nil {
+		logrus.Debugf("Error closing incomplete archive %q: %v", s.path, err)
+	}
+	if !s.regularFile {
+		return
+	}
+	if err := os.Remove(s.path); err != nil {
+		logrus.Debugf("Error removing incomplete archive %q: %v", s.path, err)
+	}
+}
+
+// seekableArchiveBlobStore is a read-only types.OCILayoutBlobStore which reads blobs directly from an oci-archive
+// compressed as a seekable zstd stream with an entry index, without extracting them.
+type seekableArchiveBlobStore struct {
+	file   *os.File
+	reader *seekablezstd.Reader
+}
+
+// openSeekableArchiveBlobStore returns a seekableArchiveBlobStore for file, and true, if file is a seekable zstd archive
+// with an entry index; or (nil, false) if it is not, in which case it must be read sequentially.
+// On success, the returned store takes ownership of file.
+func openSeekableArchiveBlobStore(file *os.File) (*seekableArchiveBlobStore, bool, error) {
+	fi, err := file.Stat()
+	if err != nil || !fi.Mode().IsRegular() {
+		return nil, false, nil
+	}
+	reader, err := seekablezstd.NewReader(file, fi.Size())
+	if err != nil {
+		if errors.Is(err, seekablezstd.ErrNotSeekable) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("reading seekable zstd archive %q: %w", file.Name(), err)
+	}
+	if !reader.HasIndex() {
+		return nil, false, nil
+	}
+	return &seekableArchiveBlobStore{file: file, reader: reader}, true, nil
+}
+
+// extractMetadata extracts all regular files of the archive except for blobs, i.e. index.json and oci-layout, to dir.
+func (s *seekableArchiveBlobStore) extractMetadata(dir string) error {
+	for _, name := range s.reader.EntryNames() {
+		if name == "." || name == imgspecv1.ImageBlobsDir || strings.HasPrefix(name, imgspecv1.ImageBlobsDir+"/") {
+			continue
+		}
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("invalid archive entry %q", name)
+		}
+		entry, err := s.reader.LookupTarEntry(name)
+		if err != nil {
+			logrus.Debugf("Not extracting %q: %v", name, err) // Typically a directory
+			continue
+		}
+		stream, err := entry.Open()
+		if err != nil {
+			return err
+		}
+		err = func() error {
+			defer stream.Close()
+			dest := filepath.Join(dir, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			_, err = io.Copy(f, stream)
+			return err
+		}()
+		if err != nil {
+			return fmt.Errorf("extracting %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// blobEntry returns the archive entry of blobDigest.
+func (s *seekableArchiveBlobStore) blobEntry(blobDigest digest.Digest) (*seekablezstd.TarEntry, error) {
+	name := path.Join(imgspecv1.ImageBlobsDir, blobDigest.Algorithm().String(), blobDigest.Encoded())
+	if _, ok := s.reader.EntryOffset(name); !ok {
+		return nil, fmt.Errorf("blob %s: %w", blobDigest.String(), fs.ErrNotExist)
+	}
+	return s.reader.LookupTarEntry(name)
+}
+
+// seekableArchiveBlob is a stream of a blob in a seekable archive, which also supports random access.
+type seekableArchiveBlob struct {
+	io.ReadCloser
+	entry *seekablezstd.TarEntry
+}
+
+func (b seekableArchiveBlob) ReadAt(p []byte, off int64) (int, error) {
+	return b.entry.ReadAt(p, off)
+}
+
+// GetBlob returns a stream for the blob with blobDigest, read directly from the archive.
+// The stream implements io.ReaderAt, so partial pulls only decompress the relevant parts of the archive.
+func (s *seekableArchiveBlobStore) GetBlob(ctx context.Context, layoutDir string, blobDigest digest.Digest) (io.ReadCloser, int64, error) {
+	entry, err := s.blobEntry(blobDigest)
+	if err != nil {
+		return nil, -1, err
+	}
+	stream, err := entry.Open()
+	if err != nil {
+		return nil, -1, err
+	}
+	return seekableArchiveBlob{ReadCloser: stream, entry: entry}, entry.Header.Size, nil
+}
+
+// BlobSize returns the size of the blob with blobDigest.
+func (s *seekableArchiveBlobStore) BlobSize(ctx context.Context, layoutDir string, blobDigest digest.Digest) (int64, error) {
+	entry, err := s.blobEntry(blobDigest)
+	if err != nil {
+		return -1, err
+	}
+	return entry.Header.Size, nil
+}
+
+// PutBlob is not supported: the archive is only read.
+func (s *seekableArchiveBlobStore) PutBlob(ctx context.Context, layoutDir string, blobDigest digest.Digest, stream io.Reader, size int64) error {
+	return fmt.Errorf("writing blob %s: blobs of an oci-archive being read can not be written", blobDigest.String())
+}
+
+// DeleteBlob is not supported: the archive is only read.
+func (s *seekableArchiveBlobStore) DeleteBlob(ctx context.Context, layoutDir string, blobDigest digest.Digest) error {
+	return fmt.Errorf("deleting blob %s: blobs of an oci-archive being read can not be deleted", blobDigest.String())
+}
+
+// close closes the archive.
+func (s *seekableArchiveBlobStore) close() error {
+	return s.file.Close()
+}
@@ -2,14 +2,17 @@ package archive
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"time"
 
+	"github.com/containers/image/v5/internal/archiveprogress"
 	"github.com/containers/image/v5/internal/imagedestination"
 	"github.com/containers/image/v5/internal/imagedestination/impl"
 	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/internal/seekablezstd"
 	"github.com/containers/image/v5/internal/signature"
 	"github.com/containers/image/v5/types"
 	"github.com/containers/storage/pkg/archive"
@@ -25,6 +28,9 @@ type ociArchiveImageDestination struct {
 	ref          ociArchiveReference
 	unpackedDest private.ImageDestination
 	tempDirRef   tempDirOCIRef
+	seekableZstd bool                      // Compress the archive as a seekable zstd stream
+	progress     *archiveprogress.Reporter // nil if progress of writing the archive should not be reported
+	directWrite  *archiveBlobStore         // nil unless blobs are written directly into the archive, see types.SystemContext.OCIArchiveDirectWrite
 }
 
 // newImageDestination returns an ImageDestination for writing to an existing directory.
@@ -33,8 +39,28 @@ func newImageDestination(ctx context.Context, sys *types.SystemContext, ref ociA
 	if err != nil {
 		return nil, fmt.Errorf("creating oci reference: %w", err)
 	}
-	unpackedDest, err := tempDirRef.ociRefExtracted.NewImageDestination(ctx, sys)
+	seekableZstd := sys != nil && sys.ArchiveSeekableZstd
+	progress := archiveprogress.NewPacking(sys)
+	var directWrite *archiveBlobStore
+	layoutSys := sys
+	if sys != nil && sys.OCIArchiveDirectWrite {
+		directWrite, err = newArchiveBlobStore(ref.resolvedFile, seekableZstd, progress)
+		if err != nil {
+			if err := tempDirRef.deleteTempDir(); err != nil {
+				return nil, fmt.Errorf("deleting temp directory %q: %w", tempDirRef.tempDirectory, err)
+			}
+			return nil, err
+		}
+		// The temporary layout only contains index.json, oci-layout, and each blob while it is being verified.
+		sysCopy := *sys
+		sysCopy.OCILayoutBlobStore = directWrite
+		layoutSys = &sysCopy
+	}
+	unpackedDest, err := tempDirRef.ociRefExtracted.NewImageDestination(ctx, layoutSys)
 	if err != nil {
+		if directWrite != nil {
+			directWrite.abort()
+		}
 		if err := tempDirRef.deleteTempDir(); err != nil {
 			return nil, fmt.Errorf("deleting temp directory %q: %w", tempDirRef.tempDirectory, err)
 		}
@@ -44,6 +70,9 @@ func newImageDestination(ctx context.Context, sys *types.SystemContext, ref ociA
 		ref:          ref,
 		unpackedDest: imagedestination.FromPublic(unpackedDest),
 		tempDirRef:   tempDirRef,
+		seekableZstd: seekableZstd,
+		progress:     progress,
+		directWrite:  directWrite,
 	}
 	d.Compat = impl.AddCompat(d)
 	return d, nil
@@ -61,6 +90,9 @@ func (d *ociArchiveImageDestination) Close() error {
 		err := d.tempDirRef.deleteTempDir()
 		logrus.Debugf("Error deleting temporary directory: %v", err)
 	}()
+	if d.directWrite != nil {
+		defer d.directWrite.abort() // Does nothing if the image was committed.
+	}
 	return d.unpackedDest.Close()
 }
 
@@ -105,6 +137,13 @@ func (d *ociArchiveImageDestination) SupportsPutBlobPartial() bool {
 	return d.unpackedDest.SupportsPutBlobPartial()
 }
 
+// Capabilities returns the capabilities of the destination which are not reported by other methods.
+func (d *ociArchiveImageDestination) Capabilities() private.DestinationCapabilities {
+	res := d.unpackedDest.Capabilities()
+	res.Deletion = types.OptionalBoolFalse // See ociArchiveReference.DeleteImage.
+	return res
+}
+
 // NoteOriginalOCIConfig provides the config of the image, as it exists on the source, BUT converted to OCI format,
 // or an error obtaining that value (e.g. if the image is an artifact and not a container image).
 // The destination can use it in its TryReusingBlob/PutBlob implementations
@@ -165,20 +204,29 @@ func (d *ociArchiveImageDestination) PutSignaturesWithFormat(ctx context.Context
 // - Uploaded data MAY be visible to others before CommitWithOptions() is called
 // - Uploaded data MAY be removed or MAY remain around if Close() is called without CommitWithOptions() (i.e. rollback is allowed but not guaranteed)
 func (d *ociArchiveImageDestination) CommitWithOptions(ctx context.Context, options private.CommitOptions) error {
+	if d.directWrite != nil && options.Timestamp != nil {
+		// The blobs have already been written, using the time they were received.
+		return errors.New("setting a timestamp of the archive contents is not supported with OCIArchiveDirectWrite")
+	}
 	if err := d.unpackedDest.CommitWithOptions(ctx, options); err != nil {
 		return fmt.Errorf("storing image %q: %w", d.ref.image, err)
 	}
 
 	// path of directory to tar up
 	src := d.tempDirRef.tempDirectory
+	if d.directWrite != nil {
+		return d.directWrite.finish(src)
+	}
 	// path to save tarred up file
 	dst := d.ref.resolvedFile
-	return tarDirectory(src, dst, options.Timestamp)
+	return tarDirectory(src, dst, options.Timestamp, d.seekableZstd, d.progress)
 }
 
 // tar converts the directory at src and saves it to dst
 // if contentModTimes is non-nil, tar header entries times are set to this
-func tarDirectory(src, dst string, contentModTimes *time.Time) (retErr error) {
+// if seekableZstd, the archive is compressed as a seekable zstd stream
+// progress, if not nil, is used to report progress of writing the archive
+func tarDirectory(src, dst string, contentModTimes *time.Time, seekableZstd bool, progress *archiveprogress.Reporter) (retErr error) {
 	// input is a stream of bytes from the archive of the directory at path
 	input, err := archive.TarWithOptions(src, &archive.TarOptions{
 		Compression: archive.Uncompressed,
@@ -208,7 +256,15 @@ func tarDirectory(src, dst string, contentModTimes *time.Time) (retErr error) {
 
 	// copies the contents of the directory to the tar file
 	// TODO: This can take quite some time, and should ideally be cancellable using a context.Context.
-	_, err = io.Copy(outFile, input)
-
-	return err
+	stream := progress.Reader(input)
+	if seekableZstd {
+		err = seekablezstd.CompressTar(outFile, stream)
+	} else {
+		_, err = io.Copy(outFile, stream)
+	}
+	if err != nil {
+		return err
+	}
+	progress.Done()
+	return nil
 }
@@ -2,12 +2,24 @@ package archive
 
 import (
 	"archive/tar"
+	"bytes"
+	"context"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/containers/image/v5/internal/blobinfocache"
+	"github.com/containers/image/v5/internal/imagedestination"
 	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/internal/seekablezstd"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/pkg/blobinfocache/memory"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -20,7 +32,7 @@ func TestTarDirectory(t *testing.T) {
 	require.NoError(t, err)
 
 	dest := filepath.Join(t.TempDir(), "file.tar")
-	err = tarDirectory(srcDir, dest, nil)
+	err = tarDirectory(srcDir, dest, nil, false, nil)
 	require.NoError(t, err)
 
 	f, err := os.Open(dest)
@@ -43,3 +55,125 @@ func TestTarDirectory(t *testing.T) {
 	}
 	assert.Equal(t, 1, numItems)
 }
+
+func TestTarDirectorySeekableZstd(t *testing.T) {
+	srcDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(srcDir, "regular"), []byte("contents"), 0o600)
+	require.NoError(t, err)
+
+	dest := filepath.Join(t.TempDir(), "file.tar.zst")
+	err = tarDirectory(srcDir, dest, nil, true, nil)
+	require.NoError(t, err)
+
+	f, err := os.Open(dest)
+	require.NoError(t, err)
+	defer f.Close()
+	fi, err := f.Stat()
+	require.NoError(t, err)
+	reader, err := seekablezstd.NewReader(f, fi.Size())
+	require.NoError(t, err)
+	tarReader, _, closer, err := reader.OpenTarEntry("regular")
+	require.NoError(t, err)
+	defer closer.Close()
+	contents, err := io.ReadAll(tarReader)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("contents"), contents)
+}
+
+func TestDirectWrite(t *testing.T) {
+	ctx := context.Background()
+	cache := blobinfocache.FromBlobInfoCache(memory.New())
+	sys := &types.SystemContext{OCIArchiveDirectWrite: true, BigFilesTemporaryDir: t.TempDir()}
+	archivePath := filepath.Join(t.TempDir(), "archive.tar")
+	ref, err := NewReference(archivePath, "tag")
+	require.NoError(t, err)
+
+	publicDest, err := ref.NewImageDestination(ctx, sys)
+	require.NoError(t, err)
+	defer publicDest.Close()
+	dest := imagedestination.FromPublic(publicDest)
+	// The archive is created immediately.
+	_, err = os.Stat(archivePath)
+	require.NoError(t, err)
+
+	layer := bytes.Repeat([]byte("layer"), 1000)
+	layerInfo, err := dest.PutBlobWithOptions(ctx, bytes.NewReader(layer), types.BlobInfo{Size: -1}, private.PutBlobOptions{Cache: cache})
+	require.NoError(t, err)
+	// Blobs already in the archive can be reused.
+	reused, reusedInfo, err := dest.TryReusingBlobWithOptions(ctx, types.BlobInfo{Digest: layerInfo.Digest, Size: -1}, private.TryReusingBlobOptions{Cache: cache})
+	require.NoError(t, err)
+	assert.True(t, reused)
+	assert.Equal(t, int64(len(layer)), reusedInfo.Size)
+	config := `{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":["` + layerInfo.Digest.String() + `"]}}`
+	configInfo, err := dest.PutBlobWithOptions(ctx, strings.NewReader(config), types.BlobInfo{Size: -1}, private.PutBlobOptions{Cache: cache, IsConfig: true})
+	require.NoError(t, err)
+	manifestBlob, err := manifest.OCI1FromComponents(imgspecv1.Descriptor{
+		MediaType: imgspecv1.MediaTypeImageConfig,
+		Digest:    configInfo.Digest,
+		Size:      configInfo.Size,
+	}, []imgspecv1.Descriptor{{
+		MediaType: imgspecv1.MediaTypeImageLayer,
+		Digest:    layerInfo.Digest,
+		Size:      layerInfo.Size,
+	}}).Serialize()
+	require.NoError(t, err)
+	err = dest.PutManifest(ctx, manifestBlob, nil)
+	require.NoError(t, err)
+	// Setting a timestamp is rejected, because the blobs have already been written.
+	timestamp := time.Now()
+	err = dest.CommitWithOptions(ctx, private.CommitOptions{Timestamp: &timestamp})
+	assert.Error(t, err)
+	err = dest.CommitWithOptions(ctx, private.CommitOptions{})
+	require.NoError(t, err)
+	err = dest.Close()
+	require.NoError(t, err)
+	// The temporary layout is removed.
+	entries, err := os.ReadDir(sys.BigFilesTemporaryDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	f, err := os.Open(archivePath)
+	require.NoError(t, err)
+	defer f.Close()
+	names := []string{}
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, hdr.Name)
+	}
+	blobPath := func(d digest.Digest) string {
+		return "blobs/sha256/" + d.Encoded()
+	}
+	assert.ElementsMatch(t, []string{"blobs/", "blobs/sha256/", blobPath(layerInfo.Digest), blobPath(configInfo.Digest),
+		blobPath(digest.FromBytes(manifestBlob)), "oci-layout", "index.json"}, names)
+
+	src, err := ref.NewImageSource(ctx, nil)
+	require.NoError(t, err)
+	defer src.Close()
+	m, _, err := src.GetManifest(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, manifestBlob, m)
+	stream, _, err := src.GetBlob(ctx, types.BlobInfo{Digest: layerInfo.Digest, Size: -1}, cache)
+	require.NoError(t, err)
+	defer stream.Close()
+	contents, err := io.ReadAll(stream)
+	require.NoError(t, err)
+	assert.Equal(t, layer, contents)
+
+	// An archive which was not committed is removed.
+	abortedPath := filepath.Join(t.TempDir(), "aborted.tar")
+	abortedRef, err := NewReference(abortedPath, "")
+	require.NoError(t, err)
+	publicDest, err = abortedRef.NewImageDestination(ctx, sys)
+	require.NoError(t, err)
+	_, err = publicDest.PutBlob(ctx, bytes.NewReader(layer), types.BlobInfo{Size: -1}, cache, false)
+	require.NoError(t, err)
+	err = publicDest.Close()
+	require.NoError(t, err)
+	_, err = os.Stat(abortedPath)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
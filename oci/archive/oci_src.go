@@ -10,6 +10,7 @@ import (
 	"github.com/containers/image/v5/internal/imagesource/impl"
 	"github.com/containers/image/v5/internal/private"
 	"github.com/containers/image/v5/internal/signature"
+	"github.com/containers/image/v5/internal/tmpdir"
 	ocilayout "github.com/containers/image/v5/oci/layout"
 	"github.com/containers/image/v5/types"
 	digest "github.com/opencontainers/go-digest"
@@ -40,6 +41,10 @@ func (e ArchiveFileNotFoundError) Error() string {
 	return fmt.Sprintf("archive file not found: %q", e.path)
 }
 
+// InsufficientTemporarySpaceError is returned if the directory for temporary big files
+// (see types.SystemContext.BigFilesTemporaryDir) is estimated not to have enough space to extract the archive.
+type InsufficientTemporarySpaceError = tmpdir.InsufficientSpaceError
+
 type ociArchiveImageSource struct {
 	impl.Compat
 
@@ -49,14 +54,16 @@ type ociArchiveImageSource struct {
 }
 
 // newImageSource returns an ImageSource for reading from an existing directory.
-// newImageSource untars the file and saves it in a temp directory
+// newImageSource untars the file and saves it in a temp directory; for seekable zstd archives
+// (see types.SystemContext.ArchiveSeekableZstd), only index.json and oci-layout are extracted,
+// and blobs are read directly from the archive.
 func newImageSource(ctx context.Context, sys *types.SystemContext, ref ociArchiveReference) (private.ImageSource, error) {
 	tempDirRef, err := createUntarTempDir(sys, ref)
 	if err != nil {
 		return nil, fmt.Errorf("creating temp directory: %w", err)
 	}
 
-	unpackedSrc, err := tempDirRef.ociRefExtracted.NewImageSource(ctx, sys)
+	unpackedSrc, err := tempDirRef.ociRefExtracted.NewImageSource(ctx, tempDirRef.layoutSystemContext(sys))
 	if err != nil {
 		var notFound ocilayout.ImageNotFoundError
 		if errors.As(err, &notFound) {
@@ -1,11 +1,25 @@
 package archive
 
 import (
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"math"
+	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 
+	"github.com/containers/image/v5/internal/blobinfocache"
+	"github.com/containers/image/v5/internal/imagedestination"
+	"github.com/containers/image/v5/internal/imagesource"
 	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/pkg/blobinfocache/memory"
 	"github.com/containers/image/v5/types"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -24,3 +38,115 @@ func TestNewImageSourceNotFound(t *testing.T) {
 	assert.ErrorAs(t, err, &aerr)
 	assert.Equal(t, aerr.path, archivePath)
 }
+
+func TestNewImageSourceInsufficientSpace(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("checking available space is only implemented on Linux")
+	}
+	// A sparse file which claims to be larger than any filesystem we would run tests on.
+	archivePath := filepath.Join(t.TempDir(), "huge.ociarchive")
+	f, err := os.Create(archivePath)
+	require.NoError(t, err)
+	err = f.Truncate(1 << 60)
+	f.Close()
+	if err != nil {
+		t.Skipf("creating a sparse file: %v", err)
+	}
+	tmpDir := t.TempDir()
+	imgref, err := ParseReference(archivePath)
+	require.NoError(t, err)
+	_, err = LoadManifestDescriptorWithContext(&types.SystemContext{BigFilesTemporaryDir: tmpDir}, imgref)
+	var serr InsufficientTemporarySpaceError
+	require.ErrorAs(t, err, &serr)
+	assert.Equal(t, tmpDir, serr.Directory)
+	assert.Equal(t, uint64(1<<60), serr.Required)
+	entries, err := os.ReadDir(tmpDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries) // Nothing was extracted
+}
+
+func TestNewImageSourceSeekableZstd(t *testing.T) {
+	ctx := context.Background()
+	cache := blobinfocache.FromBlobInfoCache(memory.New())
+	archivePath := filepath.Join(t.TempDir(), "archive.tar.zst")
+	ref, err := NewReference(archivePath, "tag")
+	require.NoError(t, err)
+
+	publicDest, err := ref.NewImageDestination(ctx, &types.SystemContext{ArchiveSeekableZstd: true})
+	require.NoError(t, err)
+	defer publicDest.Close()
+	dest := imagedestination.FromPublic(publicDest)
+	layer := bytes.Repeat([]byte("layer"), 1000)
+	layerInfo, err := dest.PutBlobWithOptions(ctx, bytes.NewReader(layer), types.BlobInfo{Size: -1}, private.PutBlobOptions{Cache: cache})
+	require.NoError(t, err)
+	config := `{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":["` + layerInfo.Digest.String() + `"]}}`
+	configInfo, err := dest.PutBlobWithOptions(ctx, strings.NewReader(config), types.BlobInfo{Size: -1}, private.PutBlobOptions{Cache: cache, IsConfig: true})
+	require.NoError(t, err)
+	manifestBlob, err := manifest.OCI1FromComponents(imgspecv1.Descriptor{
+		MediaType: imgspecv1.MediaTypeImageConfig,
+		Digest:    configInfo.Digest,
+		Size:      configInfo.Size,
+	}, []imgspecv1.Descriptor{{
+		MediaType: imgspecv1.MediaTypeImageLayer,
+		Digest:    layerInfo.Digest,
+		Size:      layerInfo.Size,
+	}}).Serialize()
+	require.NoError(t, err)
+	err = dest.PutManifest(ctx, manifestBlob, nil)
+	require.NoError(t, err)
+	err = dest.CommitWithOptions(ctx, private.CommitOptions{})
+	require.NoError(t, err)
+	err = dest.Close()
+	require.NoError(t, err)
+
+	tmpDir := t.TempDir()
+	publicSrc, err := ref.NewImageSource(ctx, &types.SystemContext{BigFilesTemporaryDir: tmpDir})
+	require.NoError(t, err)
+	defer publicSrc.Close()
+	src := imagesource.FromPublic(publicSrc)
+	// Only the metadata is extracted.
+	extracted := []string{}
+	err = filepath.WalkDir(tmpDir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			extracted = append(extracted, d.Name())
+		}
+		return err
+	})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"index.json", "oci-layout"}, extracted)
+
+	m, _, err := src.GetManifest(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, manifestBlob, m)
+	stream, size, err := src.GetBlob(ctx, types.BlobInfo{Digest: layerInfo.Digest, Size: -1}, cache)
+	require.NoError(t, err)
+	contents, err := io.ReadAll(stream)
+	stream.Close()
+	require.NoError(t, err)
+	assert.Equal(t, layer, contents)
+	assert.Equal(t, int64(len(layer)), size)
+
+	require.True(t, src.SupportsGetBlobAt())
+	streams, errs, err := src.GetBlobAt(ctx, types.BlobInfo{Digest: layerInfo.Digest, Size: -1}, []private.ImageSourceChunk{
+		{Offset: 2, Length: 3},
+		{Offset: 4000, Length: math.MaxUint64},
+	})
+	require.NoError(t, err)
+	chunks := [][]byte{}
+	for s := range streams {
+		data, err := io.ReadAll(s)
+		s.Close()
+		require.NoError(t, err)
+		chunks = append(chunks, data)
+	}
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	assert.Equal(t, [][]byte{layer[2:5], layer[4000:]}, chunks)
+
+	err = src.Close()
+	require.NoError(t, err)
+	entries, err := os.ReadDir(tmpDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
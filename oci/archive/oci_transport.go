@@ -4,19 +4,23 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"strings"
 
 	"github.com/containers/image/v5/directory/explicitfilepath"
 	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/internal/archiveprogress"
 	"github.com/containers/image/v5/internal/image"
 	"github.com/containers/image/v5/internal/tmpdir"
 	"github.com/containers/image/v5/oci/internal"
 	ocilayout "github.com/containers/image/v5/oci/layout"
+	"github.com/containers/image/v5/pkg/compression"
 	"github.com/containers/image/v5/transports"
 	"github.com/containers/image/v5/types"
 	"github.com/containers/storage/pkg/archive"
+	"github.com/sirupsen/logrus"
 )
 
 func init() {
@@ -147,13 +151,34 @@ func (ref ociArchiveReference) DeleteImage(ctx context.Context, sys *types.Syste
 type tempDirOCIRef struct {
 	tempDirectory   string
 	ociRefExtracted types.ImageReference
+	// If not nil, blobs were not extracted to tempDirectory, and must be read using this store;
+	// see layoutSystemContext.
+	seekableBlobs *seekableArchiveBlobStore
 }
 
 // deletes the temporary directory created
 func (t *tempDirOCIRef) deleteTempDir() error {
+	if t.seekableBlobs != nil {
+		if err := t.seekableBlobs.close(); err != nil {
+			logrus.Debugf("Error closing archive: %v", err)
+		}
+	}
 	return os.RemoveAll(t.tempDirectory)
 }
 
+// layoutSystemContext returns the SystemContext to use for reading t.ociRefExtracted, based on sys.
+func (t *tempDirOCIRef) layoutSystemContext(sys *types.SystemContext) *types.SystemContext {
+	if t.seekableBlobs == nil {
+		return sys
+	}
+	sysCopy := types.SystemContext{}
+	if sys != nil {
+		sysCopy = *sys
+	}
+	sysCopy.OCILayoutBlobStore = t.seekableBlobs
+	return &sysCopy
+}
+
 // createOCIRef creates the oci reference of the image
 // If SystemContext.BigFilesTemporaryDir not "", overrides the temporary directory to use for storing big files
 func createOCIRef(sys *types.SystemContext, image string) (tempDirOCIRef, error) {
@@ -181,7 +206,40 @@ func createUntarTempDir(sys *types.SystemContext, ref ociArchiveReference) (temp
 			return tempDirOCIRef{}, err
 		}
 	}
-	defer arch.Close()
+	archOwned := false // true if arch is used by the returned tempDirOCIRef
+	defer func() {
+		if !archOwned {
+			arch.Close()
+		}
+	}()
+
+	// A seekable zstd archive with an index allows reading individual blobs without extracting the whole archive.
+	seekableBlobs, isSeekable, err := openSeekableArchiveBlobStore(arch)
+	if err != nil {
+		return tempDirOCIRef{}, err
+	}
+	if isSeekable {
+		tempDirRef, err := createOCIRef(sys, ref.image)
+		if err != nil {
+			return tempDirOCIRef{}, fmt.Errorf("creating oci reference: %w", err)
+		}
+		archOwned = true
+		tempDirRef.seekableBlobs = seekableBlobs
+		if err := seekableBlobs.extractMetadata(tempDirRef.tempDirectory); err != nil {
+			if err := tempDirRef.deleteTempDir(); err != nil {
+				return tempDirOCIRef{}, fmt.Errorf("deleting temp directory %q: %w", tempDirRef.tempDirectory, err)
+			}
+			return tempDirOCIRef{}, fmt.Errorf("extracting metadata of %q: %w", src, err)
+		}
+		return tempDirRef, nil
+	}
+
+	// The archive is an uncompressed tar file, so its size is a good estimate of the extracted size.
+	if fi, err := arch.Stat(); err == nil && fi.Mode().IsRegular() {
+		if err := tmpdir.CheckSpaceForBigFiles(sys, uint64(fi.Size())); err != nil {
+			return tempDirOCIRef{}, fmt.Errorf("extracting %q: %w", src, err)
+		}
+	}
 
 	tempDirRef, err := createOCIRef(sys, ref.image)
 	if err != nil {
@@ -190,7 +248,7 @@ func createUntarTempDir(sys *types.SystemContext, ref ociArchiveReference) (temp
 	dst := tempDirRef.tempDirectory
 
 	// TODO: This can take quite some time, and should ideally be cancellable using a context.Context.
-	if err := archive.NewDefaultArchiver().Untar(arch, dst, &archive.TarOptions{NoLchown: true}); err != nil {
+	if err := untar(sys, arch, dst); err != nil {
 		if err := tempDirRef.deleteTempDir(); err != nil {
 			return tempDirOCIRef{}, fmt.Errorf("deleting temp directory %q: %w", tempDirRef.tempDirectory, err)
 		}
@@ -198,3 +256,22 @@ func createUntarTempDir(sys *types.SystemContext, ref ociArchiveReference) (temp
 	}
 	return tempDirRef, nil
 }
+
+// untar extracts the (possibly compressed) tar archive in arch to dst, reporting progress if requested in sys.
+func untar(sys *types.SystemContext, arch io.Reader, dst string) error {
+	progress := archiveprogress.NewUnpacking(sys)
+	if progress == nil {
+		return archive.NewDefaultArchiver().Untar(arch, dst, &archive.TarOptions{NoLchown: true})
+	}
+	// Decompress the archive ourselves, so that progress counts the entries of the tar stream.
+	decompressed, _, err := compression.AutoDecompress(arch)
+	if err != nil {
+		return err
+	}
+	defer decompressed.Close()
+	if err := archive.NewDefaultArchiver().Untar(progress.Reader(decompressed), dst, &archive.TarOptions{NoLchown: true}); err != nil {
+		return err
+	}
+	progress.Done()
+	return nil
+}
@@ -166,7 +166,7 @@ func refToTempOCIArchive(t *testing.T, tarEntryTimestamp *time.Time) (ref types.
 	require.NoError(t, err)
 	tarFile, err := os.CreateTemp("", "oci-transport-test.tar")
 	require.NoError(t, err)
-	err = tarDirectory(tmpDir, tarFile.Name(), tarEntryTimestamp)
+	err = tarDirectory(tmpDir, tarFile.Name(), tarEntryTimestamp, false, nil)
 	require.NoError(t, err)
 	ref, err = NewReference(tarFile.Name(), "")
 	require.NoError(t, err)
@@ -0,0 +1,173 @@
+package layout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// AdoptVerification specifies when AdoptBlobs verifies that blob contents match their names.
+type AdoptVerification int
+
+const (
+	// AdoptVerifyEagerly computes the digest of every blob before adopting it, and fails if it does not match the file name.
+	AdoptVerifyEagerly AdoptVerification = iota
+	// AdoptVerifyLazily only checks that file names are valid digests; contents are verified
+	// only when the blobs are consumed (e.g. by copy.Image, which verifies digests of all blobs it reads).
+	// This is much faster for large stores, but a corrupt blob is not detected until it is used.
+	AdoptVerifyLazily
+)
+
+// AdoptBlobsOptions contains options for AdoptBlobs.
+type AdoptBlobsOptions struct {
+	Verification AdoptVerification // Defaults to AdoptVerifyEagerly
+	// If Move is set, blob files are renamed into the layout, and removed from the source directory.
+	// Otherwise, they are hard-linked, and the source directory is left unmodified.
+	// Either way, the source directory must be on the same filesystem as the layout.
+	Move bool
+}
+
+// AdoptBlobs makes the digest-named files in srcDir available as blobs in the OCI layout of dest, without copying their contents.
+// This can be used to migrate an existing content store into an OCI layout; the caller is expected to
+// add manifests referencing the blobs (e.g. using dest.PutManifest) and to call dest.Commit.
+//
+// Files in srcDir may be named "<algorithm>:<encoded>", or "<encoded>" (for sha256), or stored in
+// "<algorithm>/<encoded>" subdirectories, matching the blobs/ directory of an OCI layout.
+// Any other regular file is an error. Blobs already present in the layout are left unmodified.
+//
+// It returns the digests and sizes of all blobs found in srcDir.
+// The operation is not atomic: if it fails, some of the blobs might have already been adopted.
+func AdoptBlobs(ctx context.Context, dest types.ImageDestination, srcDir string, options *AdoptBlobsOptions) ([]types.BlobInfo, error) {
+	d, ok := dest.(*ociImageDestination)
+	if !ok {
+		return nil, errors.New("caller error: AdoptBlobs called with a non-oci: destination")
+	}
+	blobs, ok := d.blobs.(*filesystemBlobStore)
+	if !ok {
+		return nil, errors.New("AdoptBlobs is not supported with a custom OCILayoutBlobStore")
+	}
+	if options == nil {
+		options = &AdoptBlobsOptions{}
+	}
+	switch options.Verification {
+	case AdoptVerifyEagerly, AdoptVerifyLazily:
+	default:
+		return nil, fmt.Errorf("unknown blob verification mode %d", options.Verification)
+	}
+
+	res := []types.BlobInfo{}
+	err := filepath.WalkDir(srcDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return fmt.Errorf("%q is not a regular file", path)
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		blobDigest, err := adoptedBlobDigest(rel)
+		if err != nil {
+			return fmt.Errorf("adopting %q: %w", path, err)
+		}
+		info, err := adoptBlob(blobs, path, blobDigest, options)
+		if err != nil {
+			return fmt.Errorf("adopting %q: %w", path, err)
+		}
+		res = append(res, info)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// adoptedBlobDigest returns the digest corresponding to a file at rel, relative to the AdoptBlobs source directory.
+func adoptedBlobDigest(rel string) (digest.Digest, error) {
+	var d digest.Digest
+	switch components := strings.Split(filepath.ToSlash(rel), "/"); len(components) {
+	case 1:
+		if strings.Contains(components[0], ":") {
+			d = digest.Digest(components[0])
+		} else {
+			d = digest.NewDigestFromEncoded(digest.Canonical, components[0])
+		}
+	case 2:
+		d = digest.NewDigestFromEncoded(digest.Algorithm(components[0]), components[1])
+	default:
+		return "", errors.New("unexpected directory structure")
+	}
+	if err := d.Validate(); err != nil {
+		return "", fmt.Errorf("file name is not a valid digest: %w", err)
+	}
+	return d, nil
+}
+
+// adoptBlob links (or moves) the file at path into blobs as blob blobDigest.
+func adoptBlob(blobs *filesystemBlobStore, path string, blobDigest digest.Digest, options *AdoptBlobsOptions) (types.BlobInfo, error) {
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return types.BlobInfo{}, err
+	}
+	if options.Verification == AdoptVerifyEagerly {
+		if err := verifyBlobFile(path, blobDigest); err != nil {
+			return types.BlobInfo{}, err
+		}
+	}
+	info := types.BlobInfo{Digest: blobDigest, Size: fileInfo.Size()}
+
+	blobPath, err := blobs.blobPath(blobDigest)
+	if err != nil {
+		return types.BlobInfo{}, err
+	}
+	if _, err := os.Lstat(blobPath); err == nil {
+		return info, nil // Already present; don’t touch either the existing blob or the source.
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return types.BlobInfo{}, err
+	}
+	if err := ensureParentDirectoryExists(blobPath); err != nil {
+		return types.BlobInfo{}, err
+	}
+	if options.Move {
+		err = os.Rename(path, blobPath)
+	} else {
+		err = os.Link(path, blobPath)
+	}
+	if err != nil {
+		return types.BlobInfo{}, err
+	}
+	return info, nil
+}
+
+// verifyBlobFile fails if the contents of the file at path do not match expected.
+func verifyBlobFile(path string, expected digest.Digest) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	verifier := expected.Verifier()
+	if _, err := io.Copy(verifier, f); err != nil {
+		return err
+	}
+	if !verifier.Verified() {
+		return fmt.Errorf("contents do not match digest %s", expected)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package layout
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdoptBlobs(t *testing.T) {
+	blobA := []byte("blob A")
+	blobB := []byte("blob B")
+	blobC := []byte("blob C")
+	digestA := digest.FromBytes(blobA)
+	digestB := digest.FromBytes(blobB)
+	digestC := digest.FromBytes(blobC)
+
+	for _, options := range []*AdoptBlobsOptions{
+		nil,
+		{Verification: AdoptVerifyLazily},
+		{Move: true},
+	} {
+		srcDir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(srcDir, digestA.Encoded()), blobA, 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(srcDir, digestB.String()), blobB, 0o644))
+		require.NoError(t, os.MkdirAll(filepath.Join(srcDir, "sha256"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(srcDir, "sha256", digestC.Encoded()), blobC, 0o644))
+
+		ref, _ := refToTempOCI(t, false)
+		dest, err := ref.NewImageDestination(context.Background(), nil)
+		require.NoError(t, err)
+		defer dest.Close()
+		ociDest, ok := dest.(*ociImageDestination)
+		require.True(t, ok)
+
+		res, err := AdoptBlobs(context.Background(), dest, srcDir, options)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []types.BlobInfo{
+			{Digest: digestA, Size: int64(len(blobA))},
+			{Digest: digestB, Size: int64(len(blobB))},
+			{Digest: digestC, Size: int64(len(blobC))},
+		}, res)
+		for d, contents := range map[digest.Digest][]byte{digestA: blobA, digestB: blobB, digestC: blobC} {
+			blobPath, err := ociDest.blobs.(*filesystemBlobStore).blobPath(d)
+			require.NoError(t, err)
+			data, err := os.ReadFile(blobPath)
+			require.NoError(t, err)
+			assert.Equal(t, contents, data)
+		}
+		_, err = os.Stat(filepath.Join(srcDir, digestA.Encoded()))
+		if options != nil && options.Move {
+			assert.ErrorIs(t, err, os.ErrNotExist)
+		} else {
+			assert.NoError(t, err)
+		}
+
+		// Adopting the same blobs again is a no-op.
+		if options == nil || !options.Move {
+			_, err = AdoptBlobs(context.Background(), dest, srcDir, options)
+			assert.NoError(t, err)
+		}
+	}
+
+	// Invalid file names are rejected.
+	srcDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "not-a-digest"), blobA, 0o644))
+	ref, _ := refToTempOCI(t, false)
+	dest, err := ref.NewImageDestination(context.Background(), nil)
+	require.NoError(t, err)
+	defer dest.Close()
+	_, err = AdoptBlobs(context.Background(), dest, srcDir, nil)
+	assert.Error(t, err)
+
+	// Contents not matching the digest are rejected only with eager verification.
+	srcDir = t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, digestA.Encoded()), blobB, 0o644))
+	_, err = AdoptBlobs(context.Background(), dest, srcDir, nil)
+	assert.Error(t, err)
+	_, err = AdoptBlobs(context.Background(), dest, srcDir, &AdoptBlobsOptions{Verification: AdoptVerifyLazily})
+	assert.NoError(t, err)
+
+	// Unknown verification modes are rejected.
+	_, err = AdoptBlobs(context.Background(), dest, t.TempDir(), &AdoptBlobsOptions{Verification: -1})
+	assert.Error(t, err)
+}
+
+func TestAdoptedBlobDigest(t *testing.T) {
+	d := digest.FromString("x")
+	for _, c := range []struct {
+		rel      string
+		expected digest.Digest
+	}{
+		{d.Encoded(), d},
+		{d.String(), d},
+		{filepath.Join("sha256", d.Encoded()), d},
+		{"not-a-digest", ""},
+		{"sha256:" + d.Encoded()[1:], ""},
+		{filepath.Join("md5", d.Encoded()), ""},
+		{filepath.Join("a", "sha256", d.Encoded()), ""},
+	} {
+		res, err := adoptedBlobDigest(c.rel)
+		if c.expected == "" {
+			assert.Error(t, err, c.rel)
+		} else {
+			require.NoError(t, err, c.rel)
+			assert.Equal(t, c.expected, res)
+		}
+	}
+}
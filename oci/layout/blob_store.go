@@ -0,0 +1,235 @@
+package layout
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// blobStore stores blobs (layers, configs, manifests and indexes) of a layout.
+// index.json and the oci-layout file are not blobs, and are always stored directly in the layout directory.
+type blobStore interface {
+	// getBlob returns a stream for blobDigest, and the blob’s size (or -1 if unknown).
+	// If the blob does not exist, it returns an error satisfying errors.Is(err, fs.ErrNotExist).
+	getBlob(ctx context.Context, blobDigest digest.Digest) (io.ReadCloser, int64, error)
+	// blobSize returns the size of blobDigest.
+	// If the blob does not exist, it returns an error satisfying errors.Is(err, fs.ErrNotExist).
+	blobSize(ctx context.Context, blobDigest digest.Digest) (int64, error)
+	// putBlobFromFile stores the contents of the closed temporary file at path as blobDigest.
+	// The file is consumed: on success, it has been moved or removed; on failure, the caller must remove it.
+	putBlobFromFile(ctx context.Context, path string, blobDigest digest.Digest) error
+	// putBlob stores data as blobDigest.
+	putBlob(ctx context.Context, blobDigest digest.Digest, data []byte) error
+	// deleteBlob deletes blobDigest; it is not an error if the blob does not exist.
+	deleteBlob(ctx context.Context, blobDigest digest.Digest) error
+}
+
+// newBlobStore returns a blobStore for ref’s layout, as configured in sys.
+func newBlobStore(sys *types.SystemContext, ref ociReference) blobStore {
+	if sys != nil && sys.OCILayoutBlobStore != nil {
+		return &externalBlobStore{store: sys.OCILayoutBlobStore, dir: ref.dir}
+	}
+	res := &filesystemBlobStore{ref: ref}
+	if sys != nil {
+		res.sharedBlobDir = sys.OCISharedBlobDirPath
+		res.verity = sys.OCIBlobVerity
+	}
+	return res
+}
+
+// filesystemBlobStore stores blobs as files, in the layout’s blobs subdirectory or in a shared blob directory.
+type filesystemBlobStore struct {
+	ref           ociReference
+	sharedBlobDir string // If not "", use this directory instead of the blobs subdirectory of ref
+	verity        bool   // Protect written blobs using fs-verity, and verify read blobs; see types.SystemContext.OCIBlobVerity
+}
+
+// blobPath returns the path of the file storing blobDigest.
+func (s *filesystemBlobStore) blobPath(blobDigest digest.Digest) (string, error) {
+	return s.ref.blobPath(blobDigest, s.sharedBlobDir)
+}
+
+func (s *filesystemBlobStore) getBlob(ctx context.Context, blobDigest digest.Digest) (io.ReadCloser, int64, error) {
+	path, err := s.blobPath(blobDigest)
+	if err != nil {
+		return nil, 0, err
+	}
+	r, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	if s.verity {
+		if err := verifyBlobVerity(path, r); err != nil {
+			r.Close()
+			return nil, 0, err
+		}
+	}
+	fi, err := r.Stat()
+	if err != nil {
+		r.Close()
+		return nil, 0, err
+	}
+	return r, fi.Size(), nil
+}
+
+func (s *filesystemBlobStore) blobSize(ctx context.Context, blobDigest digest.Digest) (int64, error) {
+	path, err := s.blobPath(blobDigest)
+	if err != nil {
+		return -1, err
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return -1, err
+	}
+	return fi.Size(), nil
+}
+
+func (s *filesystemBlobStore) putBlobFromFile(ctx context.Context, path string, blobDigest digest.Digest) error {
+	blobPath, err := s.blobPath(blobDigest)
+	if err != nil {
+		return err
+	}
+	if err := ensureParentDirectoryExists(blobPath); err != nil {
+		return err
+	}
+	if s.verity {
+		// Enable fs-verity before the blob becomes visible, so that readers never see an unprotected file.
+		enableBlobVerity(path)
+	}
+	return os.Rename(path, blobPath)
+}
+
+func (s *filesystemBlobStore) putBlob(ctx context.Context, blobDigest digest.Digest, data []byte) error {
+	blobPath, err := s.blobPath(blobDigest)
+	if err != nil {
+		return err
+	}
+	if err := ensureParentDirectoryExists(blobPath); err != nil {
+		return err
+	}
+	// Write to a temporary file and rename it, instead of overwriting blobPath: an existing
+	// blob may be protected using fs-verity, and such files can’t be modified.
+	tmpFile, err := os.CreateTemp(filepath.Dir(blobPath), "oci-put-blob")
+	if err != nil {
+		return err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			os.Remove(tmpFile.Name())
+		}
+	}()
+	_, err = tmpFile.Write(data)
+	if err == nil && runtime.GOOS != "windows" { // See the comment in ociImageDestination.blobFileSyncAndCommit.
+		err = tmpFile.Chmod(0644)
+	}
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.putBlobFromFile(ctx, tmpFile.Name(), blobDigest); err != nil {
+		return err
+	}
+	committed = true
+	return nil
+}
+
+func (s *filesystemBlobStore) deleteBlob(ctx context.Context, blobDigest digest.Digest) error {
+	blobPath, err := s.blobPath(blobDigest)
+	if err != nil {
+		return err
+	}
+	return deleteBlob(blobPath)
+}
+
+// externalBlobStore stores blobs using a caller-provided types.OCILayoutBlobStore.
+type externalBlobStore struct {
+	store types.OCILayoutBlobStore
+	dir   string // The layout directory, as passed to store
+}
+
+func (s *externalBlobStore) getBlob(ctx context.Context, blobDigest digest.Digest) (io.ReadCloser, int64, error) {
+	if err := validateBlobDigest(blobDigest); err != nil {
+		return nil, 0, err
+	}
+	return s.store.GetBlob(ctx, s.dir, blobDigest)
+}
+
+func (s *externalBlobStore) blobSize(ctx context.Context, blobDigest digest.Digest) (int64, error) {
+	if err := validateBlobDigest(blobDigest); err != nil {
+		return -1, err
+	}
+	return s.store.BlobSize(ctx, s.dir, blobDigest)
+}
+
+func (s *externalBlobStore) putBlobFromFile(ctx context.Context, path string, blobDigest digest.Digest) error {
+	if err := validateBlobDigest(blobDigest); err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err := s.store.PutBlob(ctx, s.dir, blobDigest, f, fi.Size()); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+func (s *externalBlobStore) putBlob(ctx context.Context, blobDigest digest.Digest, data []byte) error {
+	if err := validateBlobDigest(blobDigest); err != nil {
+		return err
+	}
+	return s.store.PutBlob(ctx, s.dir, blobDigest, bytes.NewReader(data), int64(len(data)))
+}
+
+func (s *externalBlobStore) deleteBlob(ctx context.Context, blobDigest digest.Digest) error {
+	if err := validateBlobDigest(blobDigest); err != nil {
+		return err
+	}
+	err := s.store.DeleteBlob(ctx, s.dir, blobDigest)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// validateBlobDigest returns an error if blobDigest is not valid, consistently with ociReference.blobPath.
+func validateBlobDigest(blobDigest digest.Digest) error {
+	if err := blobDigest.Validate(); err != nil {
+		return fmt.Errorf("unexpected digest reference %s: %w", blobDigest, err)
+	}
+	return nil
+}
+
+// parseBlobJSON reads blobDigest from blobs, and parses it as JSON into T.
+func parseBlobJSON[T any](ctx context.Context, blobs blobStore, blobDigest digest.Digest) (*T, error) {
+	r, _, err := blobs.getBlob(ctx, blobDigest)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	obj := new(T)
+	if err := json.NewDecoder(r).Decode(obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
@@ -0,0 +1,172 @@
+package layout
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/pkg/blobinfocache/memory"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	_ blobStore = (*filesystemBlobStore)(nil)
+	_ blobStore = (*externalBlobStore)(nil)
+)
+
+// memoryBlobStore is a types.OCILayoutBlobStore storing blobs in memory.
+type memoryBlobStore struct {
+	mutex sync.Mutex
+	blobs map[string]map[digest.Digest][]byte // layoutDir -> blobs
+}
+
+// memoryBlob is a stream returned by memoryBlobStore.GetBlob.
+type memoryBlob struct {
+	*bytes.Reader
+}
+
+func (memoryBlob) Close() error {
+	return nil
+}
+
+func (s *memoryBlobStore) blob(layoutDir string, blobDigest digest.Digest) ([]byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	data, ok := s.blobs[layoutDir][blobDigest]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return data, nil
+}
+
+func (s *memoryBlobStore) GetBlob(ctx context.Context, layoutDir string, blobDigest digest.Digest) (io.ReadCloser, int64, error) {
+	data, err := s.blob(layoutDir, blobDigest)
+	if err != nil {
+		return nil, 0, err
+	}
+	return memoryBlob{bytes.NewReader(data)}, int64(len(data)), nil
+}
+
+func (s *memoryBlobStore) BlobSize(ctx context.Context, layoutDir string, blobDigest digest.Digest) (int64, error) {
+	data, err := s.blob(layoutDir, blobDigest)
+	if err != nil {
+		return -1, err
+	}
+	return int64(len(data)), nil
+}
+
+func (s *memoryBlobStore) PutBlob(ctx context.Context, layoutDir string, blobDigest digest.Digest, stream io.Reader, size int64) error {
+	data, err := io.ReadAll(stream)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.blobs == nil {
+		s.blobs = map[string]map[digest.Digest][]byte{}
+	}
+	if s.blobs[layoutDir] == nil {
+		s.blobs[layoutDir] = map[digest.Digest][]byte{}
+	}
+	s.blobs[layoutDir][blobDigest] = data
+	return nil
+}
+
+func (s *memoryBlobStore) DeleteBlob(ctx context.Context, layoutDir string, blobDigest digest.Digest) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.blobs[layoutDir][blobDigest]; !ok {
+		return fs.ErrNotExist
+	}
+	delete(s.blobs[layoutDir], blobDigest)
+	return nil
+}
+
+func TestExternalBlobStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store := &memoryBlobStore{}
+	sys := &types.SystemContext{OCILayoutBlobStore: store}
+	ref, err := NewReference(dir, "tag")
+	require.NoError(t, err)
+
+	layer := []byte("layer contents")
+	layerDigest := digest.FromBytes(layer)
+	config := []byte("config")
+	configDigest := digest.FromBytes(config)
+	m := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json",` +
+		`"config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":"` + configDigest.String() + `","size":6},` +
+		`"layers":[{"mediaType":"application/vnd.oci.image.layer.v1.tar","digest":"` + layerDigest.String() + `","size":14}]}`)
+	manifestDigest := digest.FromBytes(m)
+
+	dest, err := ref.NewImageDestination(ctx, sys)
+	require.NoError(t, err)
+	defer dest.Close()
+	cache := memory.New()
+	_, err = dest.PutBlob(ctx, bytes.NewReader(layer), types.BlobInfo{Digest: layerDigest, Size: -1}, cache, false)
+	require.NoError(t, err)
+	_, err = dest.PutBlob(ctx, bytes.NewReader(config), types.BlobInfo{Digest: configDigest, Size: -1}, cache, true)
+	require.NoError(t, err)
+	reused, info, err := dest.TryReusingBlob(ctx, types.BlobInfo{Digest: layerDigest, Size: -1}, cache, false)
+	require.NoError(t, err)
+	assert.True(t, reused)
+	assert.Equal(t, int64(len(layer)), info.Size)
+	reused, _, err = dest.TryReusingBlob(ctx, types.BlobInfo{Digest: digest.FromString("missing"), Size: -1}, cache, false)
+	require.NoError(t, err)
+	assert.False(t, reused)
+	err = dest.PutManifest(ctx, m, nil)
+	require.NoError(t, err)
+	err = dest.Commit(ctx, nil) // nil unparsedToplevel is invalid, we don’t currently use the value
+	require.NoError(t, err)
+
+	// Blobs are in the store, not in the layout; temporary files have been removed.
+	assert.Len(t, store.blobs[dir], 3)
+	entries, err := os.ReadDir(filepath.Join(dir, "blobs"))
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+	_, err = os.Stat(filepath.Join(dir, "index.json"))
+	require.NoError(t, err)
+	tempFiles, err := filepath.Glob(filepath.Join(dir, "oci-put-blob*"))
+	require.NoError(t, err)
+	assert.Empty(t, tempFiles)
+
+	src, err := ref.NewImageSource(ctx, sys)
+	require.NoError(t, err)
+	defer src.Close()
+	manifest, _, err := src.GetManifest(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, m, manifest)
+	r, size, err := src.GetBlob(ctx, types.BlobInfo{Digest: layerDigest, Size: -1}, cache)
+	require.NoError(t, err)
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	r.Close()
+	assert.Equal(t, layer, data)
+	assert.Equal(t, int64(len(layer)), size)
+	streams, errs, err := src.(private.ImageSource).GetBlobAt(ctx, types.BlobInfo{Digest: layerDigest, Size: -1},
+		[]private.ImageSourceChunk{{Offset: 0, Length: 5}, {Offset: 6, Length: math.MaxUint64}})
+	require.NoError(t, err)
+	chunks, err := readBlobChunks(t, streams, errs)
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("layer"), []byte("contents")}, chunks)
+	_, err = GetLocalBlobPath(ctx, src, layerDigest)
+	assert.Error(t, err)
+	_, err = AdoptBlobs(ctx, dest, t.TempDir(), nil)
+	assert.Error(t, err)
+
+	err = ref.DeleteImage(ctx, sys)
+	require.NoError(t, err)
+	assert.Empty(t, store.blobs[dir])
+	_, err = store.blob(dir, manifestDigest)
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
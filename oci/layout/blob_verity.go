@@ -0,0 +1,64 @@
+package layout
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/containers/storage/pkg/fsverity"
+	"github.com/containers/storage/pkg/system"
+	"github.com/sirupsen/logrus"
+)
+
+// blobVerityXattr is the extended attribute recording the fs-verity digest of a blob file, see types.SystemContext.OCIBlobVerity.
+const blobVerityXattr = "user.containers.image.fsverity"
+
+// enableBlobVerity enables fs-verity for the closed file at path, and records its fs-verity digest in blobVerityXattr.
+// This is best-effort: on filesystems or platforms which don’t support fs-verity or extended attributes, the file is left unprotected.
+func enableBlobVerity(path string) {
+	if err := enableVerityAndRecordDigest(path); err != nil {
+		logrus.Debugf("Not protecting %s using fs-verity: %v", path, err)
+	}
+}
+
+// enableVerityAndRecordDigest is the implementation of enableBlobVerity.
+func enableVerityAndRecordDigest(path string) error {
+	// fs-verity can only be enabled on files which are not open for writing.
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := fsverity.EnableVerity(path, int(f.Fd())); err != nil {
+		return err
+	}
+	verityDigest, err := fsverity.MeasureVerity(path, int(f.Fd()))
+	if err != nil {
+		return err
+	}
+	return system.Lsetxattr(path, blobVerityXattr, []byte(verityDigest), 0)
+}
+
+// verifyBlobVerity fails if an fs-verity digest was recorded for the blob file f, opened from path, by enableBlobVerity,
+// and it does not match the file’s current fs-verity digest (e.g. because the file was replaced, or copied without fs-verity).
+// If no digest was recorded, nothing is verified.
+//
+// The kernel verifies the contents of fs-verity files on every read, failing with EIO on a mismatch, so together this detects
+// corruption of the blob without hashing its full contents.
+func verifyBlobVerity(path string, f *os.File) error {
+	recorded, err := system.Lgetxattr(path, blobVerityXattr)
+	if err != nil {
+		logrus.Debugf("Reading fs-verity digest of %s: %v", path, err)
+		return nil // Extended attributes are not supported, so nothing could have been recorded.
+	}
+	if recorded == nil {
+		return nil
+	}
+	actual, err := fsverity.MeasureVerity(path, int(f.Fd()))
+	if err != nil {
+		return fmt.Errorf("blob %s was protected using fs-verity, but fs-verity is no longer enabled: %w", path, err)
+	}
+	if actual != string(recorded) {
+		return fmt.Errorf("fs-verity digest of blob %s is %s, expected %s", path, actual, string(recorded))
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package layout
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containers/image/v5/types"
+	"github.com/containers/storage/pkg/system"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilesystemBlobStoreVerity(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	ref, err := NewReference(dir, "tag")
+	require.NoError(t, err)
+	blobs := newBlobStore(&types.SystemContext{OCIBlobVerity: true}, ref.(ociReference))
+	require.True(t, blobs.(*filesystemBlobStore).verity)
+
+	// Writing and reading blobs works whether or not the filesystem supports fs-verity.
+	data := []byte("blob contents")
+	blobDigest := digest.FromBytes(data)
+	for range 2 { // Writing an existing blob again works even if it is protected
+		err = blobs.putBlob(ctx, blobDigest, data)
+		require.NoError(t, err)
+	}
+	tmpPath := filepath.Join(dir, "tmp")
+	otherData := []byte("other contents")
+	otherDigest := digest.FromBytes(otherData)
+	err = os.WriteFile(tmpPath, otherData, 0o644)
+	require.NoError(t, err)
+	err = blobs.putBlobFromFile(ctx, tmpPath, otherDigest)
+	require.NoError(t, err)
+	for d, expected := range map[digest.Digest][]byte{blobDigest: data, otherDigest: otherData} {
+		r, size, err := blobs.getBlob(ctx, d)
+		require.NoError(t, err)
+		contents, err := io.ReadAll(r)
+		r.Close()
+		require.NoError(t, err)
+		assert.Equal(t, expected, contents)
+		assert.Equal(t, int64(len(expected)), size)
+	}
+	// No temporary files are left behind.
+	entries, err := os.ReadDir(filepath.Join(dir, "blobs", "sha256"))
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	// A recorded digest which does not match the file is detected.
+	path, err := blobs.(*filesystemBlobStore).blobPath(blobDigest)
+	require.NoError(t, err)
+	if err := system.Lsetxattr(path, blobVerityXattr, []byte("0123456789abcdef"), 0); err != nil {
+		t.Skipf("extended attributes not supported: %v", err)
+	}
+	_, _, err = blobs.getBlob(ctx, blobDigest)
+	assert.Error(t, err)
+	// … but only if verifying is enabled.
+	_, _, err = newBlobStore(nil, ref.(ociReference)).getBlob(ctx, blobDigest)
+	assert.NoError(t, err)
+}
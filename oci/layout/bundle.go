@@ -0,0 +1,324 @@
+package layout
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/containers/image/v5/internal/iolimits"
+	"github.com/containers/image/v5/internal/set"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	// BundleMediaType is the media type of the BundleIndex in a bundle written by WriteBundle.
+	BundleMediaType = "application/vnd.containers.image.bundle.v1+json"
+	// BundleIndexFile is the name of the tar entry containing the BundleIndex, the first entry of a bundle.
+	BundleIndexFile = "bundle.json"
+)
+
+// BundleIndex describes the contents of a bundle written by WriteBundle.
+// Each referenced blob is stored in the bundle as blobs/<algorithm>/<encoded digest>, as in an OCI layout.
+type BundleIndex struct {
+	MediaType string `json:"mediaType"` // BundleMediaType
+	// Manifest is the descriptor of the image manifest.
+	Manifest imgspecv1.Descriptor `json:"manifest"`
+	// Config is the descriptor of the image config.
+	Config imgspecv1.Descriptor `json:"config"`
+	// Layers are the descriptors of the image layers, in the order of the manifest.
+	Layers []imgspecv1.Descriptor `json:"layers"`
+	// Referrers are the descriptors of manifests in the layout which refer to the image manifest using the "subject" field,
+	// sorted by digest. Their configs and layers are included in the bundle as well.
+	Referrers []imgspecv1.Descriptor `json:"referrers,omitempty"`
+}
+
+// WriteBundle writes the image at ref, which must be an oci: reference, to w, as a single self-describing bundle:
+// a tar stream containing a BundleIndexFile entry, followed by the manifest, the config, the layers, and the referrers
+// of the image (each referrer manifest followed by its config and layers), as blobs.
+// The order of the entries, and the tar headers, are deterministic, so the same image always produces the same bundle.
+//
+// If ref refers to a manifest list or an image index, the instance for the platform selected by sys is written.
+//
+// Referrers are only found if they are recorded in index.json, or stored as files in the layout (or in
+// sys.OCISharedBlobDirPath); referrers in a types.SystemContext.OCILayoutBlobStore are only found if recorded in index.json.
+func WriteBundle(ctx context.Context, sys *types.SystemContext, ref types.ImageReference, w io.Writer) error {
+	ociRef, ok := ref.(ociReference)
+	if !ok {
+		return errors.New("ref must be an OCI reference")
+	}
+	src, err := ociRef.NewImageSource(ctx, sys)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	manifestBlob, mimeType, err := src.GetManifest(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if manifest.MIMETypeIsMultiImage(mimeType) {
+		list, err := manifest.ListFromBlob(manifestBlob, mimeType)
+		if err != nil {
+			return err
+		}
+		instanceDigest, err := list.ChooseInstance(sys)
+		if err != nil {
+			return err
+		}
+		manifestBlob, mimeType, err = src.GetManifest(ctx, &instanceDigest)
+		if err != nil {
+			return err
+		}
+	}
+	m, err := manifest.FromBlob(manifestBlob, mimeType)
+	if err != nil {
+		return err
+	}
+	bundle := BundleIndex{
+		MediaType: BundleMediaType,
+		Manifest: imgspecv1.Descriptor{
+			MediaType: mimeType,
+			Digest:    digest.FromBytes(manifestBlob),
+			Size:      int64(len(manifestBlob)),
+		},
+		Config: blobInfoToDescriptor(m.ConfigInfo()),
+		Layers: []imgspecv1.Descriptor{},
+	}
+	for _, layer := range m.LayerInfos() {
+		bundle.Layers = append(bundle.Layers, blobInfoToDescriptor(layer.BlobInfo))
+	}
+	referrers, err := ociRef.findReferrers(sys, bundle.Manifest.Digest)
+	if err != nil {
+		return err
+	}
+	for _, r := range referrers {
+		bundle.Referrers = append(bundle.Referrers, r.descriptor)
+	}
+
+	bundleJSON, err := json.Marshal(bundle)
+	if err != nil {
+		return err
+	}
+	bw := bundleWriter{tw: tar.NewWriter(w), src: src, written: set.New[digest.Digest]()}
+	if err := bw.writeFile(BundleIndexFile, bundleJSON); err != nil {
+		return err
+	}
+	if err := bw.writeManifestAndBlobs(ctx, bundle.Manifest.Digest, manifestBlob, bundle.Config, bundle.Layers); err != nil {
+		return err
+	}
+	for _, r := range referrers {
+		if err := bw.writeManifestAndBlobs(ctx, r.descriptor.Digest, r.manifest, r.config, r.layers); err != nil {
+			return err
+		}
+	}
+	return bw.tw.Close()
+}
+
+// blobInfoToDescriptor returns a descriptor for info.
+func blobInfoToDescriptor(info types.BlobInfo) imgspecv1.Descriptor {
+	return imgspecv1.Descriptor{
+		MediaType:   info.MediaType,
+		Digest:      info.Digest,
+		Size:        info.Size,
+		URLs:        info.URLs,
+		Annotations: info.Annotations,
+	}
+}
+
+// bundleWriter writes entries of a bundle.
+type bundleWriter struct {
+	tw      *tar.Writer
+	src     types.ImageSource
+	written *set.Set[digest.Digest] // Blobs which have already been written
+}
+
+// bundleHeader returns a deterministic tar header for a file with name and size.
+func bundleHeader(name string, size int64) *tar.Header {
+	return &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     size,
+		Mode:     0o644,
+		ModTime:  time.Unix(0, 0).UTC(),
+		Format:   tar.FormatPAX,
+	}
+}
+
+// writeFile writes a file with name and contents.
+func (bw *bundleWriter) writeFile(name string, contents []byte) error {
+	if err := bw.tw.WriteHeader(bundleHeader(name, int64(len(contents)))); err != nil {
+		return err
+	}
+	_, err := bw.tw.Write(contents)
+	return err
+}
+
+// blobName returns the name of the tar entry for blobDigest.
+func blobName(blobDigest digest.Digest) string {
+	return path.Join(imgspecv1.ImageBlobsDir, blobDigest.Algorithm().String(), blobDigest.Encoded())
+}
+
+// writeManifestAndBlobs writes a manifest with manifestDigest and contents manifestBlob, followed by config and layers,
+// skipping blobs which have already been written.
+func (bw *bundleWriter) writeManifestAndBlobs(ctx context.Context, manifestDigest digest.Digest, manifestBlob []byte,
+	config imgspecv1.Descriptor, layers []imgspecv1.Descriptor) error {
+	if !bw.written.Contains(manifestDigest) {
+		if err := bw.writeFile(blobName(manifestDigest), manifestBlob); err != nil {
+			return err
+		}
+		bw.written.Add(manifestDigest)
+	}
+	for _, desc := range append([]imgspecv1.Descriptor{config}, layers...) {
+		if desc.Digest == "" || bw.written.Contains(desc.Digest) {
+			continue
+		}
+		if err := bw.writeBlob(ctx, desc); err != nil {
+			return err
+		}
+		bw.written.Add(desc.Digest)
+	}
+	return nil
+}
+
+// writeBlob writes the blob described by desc.
+func (bw *bundleWriter) writeBlob(ctx context.Context, desc imgspecv1.Descriptor) error {
+	stream, size, err := bw.src.GetBlob(ctx, types.BlobInfo{Digest: desc.Digest, Size: desc.Size, URLs: desc.URLs}, nil)
+	if err != nil {
+		return fmt.Errorf("reading blob %s: %w", desc.Digest, err)
+	}
+	defer stream.Close()
+	if size == -1 {
+		size = desc.Size
+	}
+	if size < 0 {
+		return fmt.Errorf("size of blob %s is unknown", desc.Digest)
+	}
+	if err := bw.tw.WriteHeader(bundleHeader(blobName(desc.Digest), size)); err != nil {
+		return err
+	}
+	verifier := desc.Digest.Verifier()
+	if _, err := io.CopyN(bw.tw, io.TeeReader(stream, verifier), size); err != nil {
+		return fmt.Errorf("writing blob %s: %w", desc.Digest, err)
+	}
+	if !verifier.Verified() {
+		return fmt.Errorf("blob %s does not match its digest", desc.Digest)
+	}
+	return nil
+}
+
+// bundleReferrer is a referrer manifest included in a bundle.
+type bundleReferrer struct {
+	descriptor imgspecv1.Descriptor
+	manifest   []byte
+	config     imgspecv1.Descriptor
+	layers     []imgspecv1.Descriptor
+}
+
+// findReferrers returns manifests in ref’s layout with a subject of subjectDigest, sorted by digest.
+func (ref ociReference) findReferrers(sys *types.SystemContext, subjectDigest digest.Digest) ([]bundleReferrer, error) {
+	candidates := set.New[digest.Digest]()
+	index, err := ref.getIndex()
+	if err != nil {
+		return nil, err
+	}
+	for _, desc := range index.Manifests {
+		if desc.MediaType == imgspecv1.MediaTypeImageManifest {
+			candidates.Add(desc.Digest)
+		}
+	}
+	if store, ok := newBlobStore(sys, ref).(*filesystemBlobStore); ok {
+		blobDir := filepath.Join(ref.dir, imgspecv1.ImageBlobsDir)
+		if store.sharedBlobDir != "" {
+			blobDir = store.sharedBlobDir
+		}
+		err := filepath.WalkDir(blobDir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			if info.Size() > iolimits.MaxManifestBodySize {
+				return nil
+			}
+			rel, err := filepath.Rel(blobDir, path)
+			if err != nil {
+				return err
+			}
+			if blobDigest, err := digest.Parse(strings.Replace(filepath.ToSlash(rel), "/", ":", 1)); err == nil {
+				candidates.Add(blobDigest)
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	blobs := newBlobStore(sys, ref)
+	res := []bundleReferrer{}
+	for _, candidate := range slices.Sorted(candidates.All()) {
+		r, ok, err := readReferrer(blobs, candidate, subjectDigest)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			res = append(res, r)
+		}
+	}
+	return res, nil
+}
+
+// readReferrer returns the manifest with candidateDigest from blobs if it is an OCI manifest with a subject of subjectDigest.
+func readReferrer(blobs blobStore, candidateDigest, subjectDigest digest.Digest) (bundleReferrer, bool, error) {
+	stream, _, err := blobs.getBlob(context.Background(), candidateDigest)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return bundleReferrer{}, false, nil
+		}
+		return bundleReferrer{}, false, err
+	}
+	defer stream.Close()
+	blob, err := iolimits.ReadAtMost(stream, iolimits.MaxManifestBodySize)
+	if err != nil {
+		return bundleReferrer{}, false, nil // Too large to be a manifest
+	}
+	var m imgspecv1.Manifest
+	if err := json.Unmarshal(blob, &m); err != nil || m.MediaType != imgspecv1.MediaTypeImageManifest ||
+		m.Subject == nil || m.Subject.Digest != subjectDigest {
+		return bundleReferrer{}, false, nil
+	}
+	artifactType := m.ArtifactType
+	if artifactType == "" {
+		artifactType = m.Config.MediaType
+	}
+	return bundleReferrer{
+		descriptor: imgspecv1.Descriptor{
+			MediaType:    imgspecv1.MediaTypeImageManifest,
+			ArtifactType: artifactType,
+			Digest:       candidateDigest,
+			Size:         int64(len(blob)),
+			Annotations:  m.Annotations,
+		},
+		manifest: blob,
+		config:   m.Config,
+		layers:   m.Layers,
+	}, true, nil
+}
@@ -0,0 +1,128 @@
+package layout
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/pkg/blobinfocache/memory"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// putBundleTestManifest writes blobs and an OCI manifest referencing them, with an optional subject, to ref.
+// The manifest is tagged if subject is nil, and stored only as a blob otherwise.
+func putBundleTestManifest(t *testing.T, ref types.ImageReference, configMediaType string, config []byte, layers [][]byte, subject *imgspecv1.Descriptor) ([]byte, []digest.Digest) {
+	dest, err := ref.NewImageDestination(context.Background(), nil)
+	require.NoError(t, err)
+	defer dest.Close()
+	cache := memory.New()
+	descriptors := []imgspecv1.Descriptor{}
+	blobDigests := []digest.Digest{}
+	for _, blob := range append([][]byte{config}, layers...) {
+		info, err := dest.PutBlob(context.Background(), bytes.NewReader(blob), types.BlobInfo{Size: int64(len(blob)), Digest: digest.FromBytes(blob)}, cache, false)
+		require.NoError(t, err)
+		descriptors = append(descriptors, imgspecv1.Descriptor{MediaType: imgspecv1.MediaTypeImageLayer, Digest: info.Digest, Size: info.Size})
+		blobDigests = append(blobDigests, info.Digest)
+	}
+	descriptors[0].MediaType = configMediaType
+	m := manifest.OCI1FromComponents(descriptors[0], descriptors[1:])
+	m.Subject = subject
+	manifestBlob, err := m.Serialize()
+	require.NoError(t, err)
+	var instanceDigest *digest.Digest
+	if subject != nil {
+		d := digest.FromBytes(manifestBlob)
+		instanceDigest = &d
+	}
+	err = dest.PutManifest(context.Background(), manifestBlob, instanceDigest)
+	require.NoError(t, err)
+	err = dest.Commit(context.Background(), nil) // nil unparsedToplevel is invalid, we don’t currently use the value
+	require.NoError(t, err)
+	return manifestBlob, blobDigests
+}
+
+func TestWriteBundle(t *testing.T) {
+	tmpDir := t.TempDir()
+	ref, err := NewReference(tmpDir, "bundle")
+	require.NoError(t, err)
+
+	manifestBlob, blobs := putBundleTestManifest(t, ref, imgspecv1.MediaTypeImageConfig, []byte(`{"architecture":"amd64","os":"linux"}`),
+		[][]byte{[]byte("layer 1"), []byte("layer 2")}, nil)
+	manifestDigest := digest.FromBytes(manifestBlob)
+	subject := &imgspecv1.Descriptor{MediaType: imgspecv1.MediaTypeImageManifest, Digest: manifestDigest, Size: int64(len(manifestBlob))}
+	referrerBlob, referrerBlobs := putBundleTestManifest(t, ref, "application/vnd.example.sbom", []byte("{}"),
+		[][]byte{[]byte("sbom"), []byte("layer 1")}, subject)
+	referrerDigest := digest.FromBytes(referrerBlob)
+	// An unrelated image is not included
+	otherRef, err := NewReference(tmpDir, "other")
+	require.NoError(t, err)
+	_, _ = putBundleTestManifest(t, otherRef, imgspecv1.MediaTypeImageConfig, []byte(`{"architecture":"arm64","os":"linux"}`),
+		[][]byte{[]byte("other layer")}, nil)
+
+	var bundle bytes.Buffer
+	err = WriteBundle(context.Background(), nil, ref, &bundle)
+	require.NoError(t, err)
+
+	names := []string{}
+	contents := map[string][]byte{}
+	tr := tar.NewReader(bytes.NewReader(bundle.Bytes()))
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), hdr.ModTime.Unix())
+		data, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		names = append(names, hdr.Name)
+		contents[hdr.Name] = data
+	}
+	assert.Equal(t, []string{
+		BundleIndexFile,
+		blobName(manifestDigest), blobName(blobs[0]), blobName(blobs[1]), blobName(blobs[2]),
+		blobName(referrerDigest), blobName(referrerBlobs[0]), blobName(referrerBlobs[1]),
+	}, names)
+	assert.Equal(t, manifestBlob, contents[blobName(manifestDigest)])
+	assert.Equal(t, []byte("layer 2"), contents[blobName(blobs[2])])
+	assert.Equal(t, referrerBlob, contents[blobName(referrerDigest)])
+
+	var index BundleIndex
+	err = json.Unmarshal(contents[BundleIndexFile], &index)
+	require.NoError(t, err)
+	assert.Equal(t, BundleMediaType, index.MediaType)
+	assert.Equal(t, *subject, index.Manifest)
+	assert.Equal(t, blobs[0], index.Config.Digest)
+	require.Len(t, index.Layers, 2)
+	assert.Equal(t, blobs[1], index.Layers[0].Digest)
+	assert.Equal(t, []imgspecv1.Descriptor{{
+		MediaType:    imgspecv1.MediaTypeImageManifest,
+		ArtifactType: "application/vnd.example.sbom",
+		Digest:       referrerDigest,
+		Size:         int64(len(referrerBlob)),
+	}}, index.Referrers)
+
+	// The output is deterministic
+	var bundle2 bytes.Buffer
+	err = WriteBundle(context.Background(), nil, ref, &bundle2)
+	require.NoError(t, err)
+	assert.Equal(t, bundle.Bytes(), bundle2.Bytes())
+
+	// Only oci: references are supported
+	err = WriteBundle(context.Background(), nil, nonOCIReference{}, io.Discard)
+	assert.Error(t, err)
+}
+
+// nonOCIReference is a types.ImageReference which is not an ociReference.
+type nonOCIReference struct {
+	types.ImageReference
+}
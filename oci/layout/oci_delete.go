@@ -17,27 +17,30 @@ import (
 
 // DeleteImage deletes the named image from the directory, if supported.
 func (ref ociReference) DeleteImage(ctx context.Context, sys *types.SystemContext) error {
-	sharedBlobsDir := ""
-	if sys != nil && sys.OCISharedBlobDirPath != "" {
-		sharedBlobsDir = sys.OCISharedBlobDirPath
-	}
+	blobs := newBlobStore(sys, ref)
 
 	descriptor, descriptorIndex, err := ref.getManifestDescriptor()
 	if err != nil {
 		return err
 	}
+	if sys != nil && sys.OCIIndexSnapshots {
+		// This must happen before computing blobsToDelete, so that the blobs of the deleted image are preserved for the snapshot.
+		if err := ref.snapshotIndex(nil); err != nil {
+			return fmt.Errorf("recording index snapshot: %w", err)
+		}
+	}
 
 	blobsUsedByImage := make(map[digest.Digest]int)
-	if err := ref.countBlobsForDescriptor(blobsUsedByImage, &descriptor, sharedBlobsDir); err != nil {
+	if err := ref.countBlobsForDescriptor(ctx, blobsUsedByImage, &descriptor, blobs); err != nil {
 		return err
 	}
 
-	blobsToDelete, err := ref.getBlobsToDelete(blobsUsedByImage, sharedBlobsDir)
+	blobsToDelete, err := ref.getBlobsToDelete(ctx, blobsUsedByImage, blobs)
 	if err != nil {
 		return err
 	}
 
-	err = ref.deleteBlobs(blobsToDelete)
+	err = ref.deleteBlobs(ctx, blobs, blobsToDelete)
 	if err != nil {
 		return err
 	}
@@ -46,16 +49,11 @@ func (ref ociReference) DeleteImage(ctx context.Context, sys *types.SystemContex
 }
 
 // countBlobsForDescriptor updates dest with usage counts of blobs required for descriptor, INCLUDING descriptor itself.
-func (ref ociReference) countBlobsForDescriptor(dest map[digest.Digest]int, descriptor *imgspecv1.Descriptor, sharedBlobsDir string) error {
-	blobPath, err := ref.blobPath(descriptor.Digest, sharedBlobsDir)
-	if err != nil {
-		return err
-	}
-
+func (ref ociReference) countBlobsForDescriptor(ctx context.Context, dest map[digest.Digest]int, descriptor *imgspecv1.Descriptor, blobs blobStore) error {
 	dest[descriptor.Digest]++
 	switch descriptor.MediaType {
 	case imgspecv1.MediaTypeImageManifest:
-		manifest, err := parseJSON[imgspecv1.Manifest](blobPath)
+		manifest, err := parseBlobJSON[imgspecv1.Manifest](ctx, blobs, descriptor.Digest)
 		if err != nil {
 			return err
 		}
@@ -64,11 +62,11 @@ func (ref ociReference) countBlobsForDescriptor(dest map[digest.Digest]int, desc
 			dest[layer.Digest]++
 		}
 	case imgspecv1.MediaTypeImageIndex:
-		index, err := parseIndex(blobPath)
+		index, err := parseBlobJSON[imgspecv1.Index](ctx, blobs, descriptor.Digest)
 		if err != nil {
 			return err
 		}
-		if err := ref.countBlobsReferencedByIndex(dest, index, sharedBlobsDir); err != nil {
+		if err := ref.countBlobsReferencedByIndex(ctx, dest, index, blobs); err != nil {
 			return err
 		}
 	default:
@@ -78,9 +76,9 @@ func (ref ociReference) countBlobsForDescriptor(dest map[digest.Digest]int, desc
 }
 
 // countBlobsReferencedByIndex updates dest with usage counts of blobs required for index, EXCLUDING the index itself.
-func (ref ociReference) countBlobsReferencedByIndex(destination map[digest.Digest]int, index *imgspecv1.Index, sharedBlobsDir string) error {
+func (ref ociReference) countBlobsReferencedByIndex(ctx context.Context, destination map[digest.Digest]int, index *imgspecv1.Index, blobs blobStore) error {
 	for _, descriptor := range index.Manifests {
-		if err := ref.countBlobsForDescriptor(destination, &descriptor, sharedBlobsDir); err != nil {
+		if err := ref.countBlobsForDescriptor(ctx, destination, &descriptor, blobs); err != nil {
 			return err
 		}
 	}
@@ -89,21 +87,25 @@ func (ref ociReference) countBlobsReferencedByIndex(destination map[digest.Diges
 
 // This takes in a map of the digest and their usage count in the manifest to be deleted
 // It will compare it to the digest usage in the root index, and return a set of the blobs that can be safely deleted
-func (ref ociReference) getBlobsToDelete(blobsUsedByDescriptorToDelete map[digest.Digest]int, sharedBlobsDir string) (*set.Set[digest.Digest], error) {
+func (ref ociReference) getBlobsToDelete(ctx context.Context, blobsUsedByDescriptorToDelete map[digest.Digest]int, blobs blobStore) (*set.Set[digest.Digest], error) {
 	rootIndex, err := ref.getIndex()
 	if err != nil {
 		return nil, err
 	}
 	blobsUsedInRootIndex := make(map[digest.Digest]int)
-	err = ref.countBlobsReferencedByIndex(blobsUsedInRootIndex, rootIndex, sharedBlobsDir)
+	err = ref.countBlobsReferencedByIndex(ctx, blobsUsedInRootIndex, rootIndex, blobs)
 	if err != nil {
 		return nil, err
 	}
+	blobsUsedBySnapshots := make(map[digest.Digest]int)
+	if err := ref.countBlobsReferencedBySnapshots(ctx, blobsUsedBySnapshots, blobs); err != nil {
+		return nil, err
+	}
 
 	blobsToDelete := set.New[digest.Digest]()
 
 	for digest, count := range blobsUsedInRootIndex {
-		if count-blobsUsedByDescriptorToDelete[digest] == 0 {
+		if count-blobsUsedByDescriptorToDelete[digest] == 0 && blobsUsedBySnapshots[digest] == 0 {
 			blobsToDelete.Add(digest)
 		}
 	}
@@ -121,15 +123,14 @@ func (ref ociReference) getBlobsToDelete(blobsUsedByDescriptorToDelete map[diges
 // in case the layout was created using some other tool or without OCISharedBlobDirPath set, so let's silently
 // check for local blobs (but we should make no noise if the blobs are actually in the shared directory).
 //
-// So, NOTE: the blobPath() call below hard-codes "" even in calls where OCISharedBlobDirPath is set
-func (ref ociReference) deleteBlobs(blobsToDelete *set.Set[digest.Digest]) error {
+// So, NOTE: for the filesystem, the code below deletes from the local directory even if OCISharedBlobDirPath is set.
+// A custom OCILayoutBlobStore is told which layout the blobs are deleted from, so it can decide for itself.
+func (ref ociReference) deleteBlobs(ctx context.Context, blobs blobStore, blobsToDelete *set.Set[digest.Digest]) error {
+	if _, ok := blobs.(*filesystemBlobStore); ok {
+		blobs = &filesystemBlobStore{ref: ref} // Only delete in the local directory, see comment above
+	}
 	for digest := range blobsToDelete.All() {
-		blobPath, err := ref.blobPath(digest, "") //Only delete in the local directory, see comment above
-		if err != nil {
-			return err
-		}
-		err = deleteBlob(blobPath)
-		if err != nil {
+		if err := blobs.deleteBlob(ctx, digest); err != nil {
 			return err
 		}
 	}
@@ -31,9 +31,10 @@ type ociImageDestination struct {
 	stubs.NoPutBlobPartialInitialize
 	stubs.NoSignaturesInitialize
 
-	ref           ociReference
-	index         imgspecv1.Index
-	sharedBlobDir string
+	ref            ociReference
+	index          imgspecv1.Index
+	blobs          blobStore
+	indexSnapshots bool // Record the previous index.json as a snapshot, see types.SystemContext.OCIIndexSnapshots
 }
 
 // newImageDestination returns an ImageDestination for writing to an existing directory.
@@ -73,16 +74,21 @@ func newImageDestination(sys *types.SystemContext, ref ociReference) (private.Im
 			MustMatchRuntimeOS:             false,
 			IgnoresEmbeddedDockerReference: false, // N/A, DockerReference() returns nil.
 			HasThreadSafePutBlob:           true,
+			Capabilities: private.DestinationCapabilities{
+				Referrers: types.OptionalBoolTrue,
+				Deletion:  types.OptionalBoolTrue,
+			},
 		}),
 		NoPutBlobPartialInitialize: stubs.NoPutBlobPartial(ref),
 		NoSignaturesInitialize:     stubs.NoSignatures("Pushing signatures for OCI images is not supported"),
 
 		ref:   ref,
 		index: *index,
+		blobs: newBlobStore(sys, ref),
 	}
 	d.Compat = impl.AddCompat(d)
 	if sys != nil {
-		d.sharedBlobDir = sys.OCISharedBlobDirPath
+		d.indexSnapshots = sys.OCIIndexSnapshots
 	}
 
 	if err := ensureDirectoryExists(d.ref.dir); err != nil {
@@ -145,17 +151,17 @@ func (d *ociImageDestination) PutBlobWithOptions(ctx context.Context, stream io.
 		return private.UploadedBlob{}, fmt.Errorf("Size mismatch when copying %s, expected %d, got %d", blobDigest, inputInfo.Size, size)
 	}
 
-	if err := d.blobFileSyncAndRename(blobFile, blobDigest, &explicitClosed); err != nil {
+	if err := d.blobFileSyncAndCommit(ctx, blobFile, blobDigest, &explicitClosed); err != nil {
 		return private.UploadedBlob{}, err
 	}
 	succeeded = true
 	return private.UploadedBlob{Digest: blobDigest, Size: size}, nil
 }
 
-// blobFileSyncAndRename syncs the specified blobFile on the filesystem and renames it to the
-// specific blob path determined by the blobDigest. The closed pointer indicates to the caller
+// blobFileSyncAndCommit syncs the specified blobFile on the filesystem and stores it in d.blobs
+// as blobDigest (for the filesystem, by renaming it to the blob path). The closed pointer indicates to the caller
 // whether blobFile has been closed or not.
-func (d *ociImageDestination) blobFileSyncAndRename(blobFile *os.File, blobDigest digest.Digest, closed *bool) error {
+func (d *ociImageDestination) blobFileSyncAndCommit(ctx context.Context, blobFile *os.File, blobDigest digest.Digest, closed *bool) error {
 	if err := blobFile.Sync(); err != nil {
 		return err
 	}
@@ -170,26 +176,13 @@ func (d *ociImageDestination) blobFileSyncAndRename(blobFile *os.File, blobDiges
 		}
 	}
 
-	blobPath, err := d.ref.blobPath(blobDigest, d.sharedBlobDir)
-	if err != nil {
-		return err
-	}
-	if err := ensureParentDirectoryExists(blobPath); err != nil {
-		return err
-	}
-
 	// need to explicitly close the file, since a rename won't otherwise work on Windows
-	err = blobFile.Close()
-	if err != nil {
+	if err := blobFile.Close(); err != nil {
 		return err
 	}
 	*closed = true
 
-	if err := os.Rename(blobFile.Name(), blobPath); err != nil {
-		return err
-	}
-
-	return nil
+	return d.blobs.putBlobFromFile(ctx, blobFile.Name(), blobDigest)
 }
 
 // TryReusingBlobWithOptions checks whether the transport already contains, or can efficiently reuse, a blob, and if so, applies it to the current destination
@@ -204,19 +197,15 @@ func (d *ociImageDestination) TryReusingBlobWithOptions(ctx context.Context, inf
 	if info.Digest == "" {
 		return false, private.ReusedBlob{}, errors.New("Can not check for a blob with unknown digest")
 	}
-	blobPath, err := d.ref.blobPath(info.Digest, d.sharedBlobDir)
-	if err != nil {
-		return false, private.ReusedBlob{}, err
-	}
-	finfo, err := os.Stat(blobPath)
-	if err != nil && os.IsNotExist(err) {
+	size, err := d.blobs.blobSize(ctx, info.Digest)
+	if err != nil && errors.Is(err, fs.ErrNotExist) {
 		return false, private.ReusedBlob{}, nil
 	}
 	if err != nil {
 		return false, private.ReusedBlob{}, err
 	}
 
-	return true, private.ReusedBlob{Digest: info.Digest, Size: finfo.Size()}, nil
+	return true, private.ReusedBlob{Digest: info.Digest, Size: size}, nil
 }
 
 // PutManifest writes a manifest to the destination.  Per our list of supported manifest MIME types,
@@ -241,14 +230,7 @@ func (d *ociImageDestination) PutManifest(ctx context.Context, m []byte, instanc
 		}
 	}
 
-	blobPath, err := d.ref.blobPath(digest, d.sharedBlobDir)
-	if err != nil {
-		return err
-	}
-	if err := ensureParentDirectoryExists(blobPath); err != nil {
-		return err
-	}
-	if err := os.WriteFile(blobPath, m, 0644); err != nil {
+	if err := d.blobs.putBlob(ctx, digest, m); err != nil {
 		return err
 	}
 
@@ -319,6 +301,11 @@ func (d *ociImageDestination) CommitWithOptions(ctx context.Context, options pri
 	if err != nil {
 		return err
 	}
+	if d.indexSnapshots {
+		if err := d.ref.snapshotIndex(indexJSON); err != nil {
+			return fmt.Errorf("recording index snapshot: %w", err)
+		}
+	}
 	return os.WriteFile(d.ref.indexPath(), indexJSON, 0644)
 }
 
@@ -378,7 +365,7 @@ func PutBlobFromLocalFile(ctx context.Context, dest types.ImageDestination, file
 		return "", -1, err
 	}
 
-	if err := d.blobFileSyncAndRename(blobFile, blobDigest, &blobFileClosed); err != nil {
+	if err := d.blobFileSyncAndCommit(ctx, blobFile, blobDigest, &blobFileClosed); err != nil {
 		return "", -1, err
 	}
 
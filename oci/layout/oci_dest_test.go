@@ -201,7 +201,7 @@ func TestPutblobFromLocalFile(t *testing.T) {
 		require.Equal(t, test.size, size)
 		require.Equal(t, test.digest, digest.String())
 
-		blobPath, err := ociDest.ref.blobPath(digest, ociDest.sharedBlobDir)
+		blobPath, err := ociDest.blobs.(*filesystemBlobStore).blobPath(digest)
 		require.NoError(t, err)
 		require.FileExists(t, blobPath)
 
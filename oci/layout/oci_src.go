@@ -1,14 +1,17 @@
 package layout
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"net/url"
-	"os"
 	"strconv"
+	"sync"
 
 	"github.com/containers/image/v5/internal/imagesource/impl"
 	"github.com/containers/image/v5/internal/imagesource/stubs"
@@ -38,13 +41,14 @@ type ociImageSource struct {
 	impl.PropertyMethodsInitialize
 	impl.NoSignatures
 	impl.DoesNotAffectLayerInfosForCopy
-	stubs.NoGetBlobAtInitialize
+	stubs.ImplementsGetBlobAt
 
-	ref           ociReference
-	index         *imgspecv1.Index
-	descriptor    imgspecv1.Descriptor
-	client        *http.Client
-	sharedBlobDir string
+	ref            ociReference
+	index          *imgspecv1.Index
+	descriptor     imgspecv1.Descriptor
+	client         *http.Client
+	blobs          blobStore
+	prefetchWindow int64 // See types.SystemContext.OCIGetBlobAtPrefetchWindow; <= 0 means no coalescing or read-ahead
 }
 
 // newImageSource returns an ImageSource for reading from an existing directory.
@@ -73,16 +77,18 @@ func newImageSource(sys *types.SystemContext, ref ociReference) (private.ImageSo
 		PropertyMethodsInitialize: impl.PropertyMethods(impl.Properties{
 			HasThreadSafeGetBlob: false,
 		}),
-		NoGetBlobAtInitialize: stubs.NoGetBlobAt(ref),
 
-		ref:        ref,
-		index:      index,
-		descriptor: descriptor,
-		client:     client,
+		ref:            ref,
+		index:          index,
+		descriptor:     descriptor,
+		client:         client,
+		blobs:          newBlobStore(sys, ref), // TODO(jonboulle): check dir existence?
+		prefetchWindow: defaultGetBlobAtPrefetchWindow,
 	}
 	if sys != nil {
-		// TODO(jonboulle): check dir existence?
-		s.sharedBlobDir = sys.OCISharedBlobDirPath
+		if sys.OCIGetBlobAtPrefetchWindow != 0 {
+			s.prefetchWindow = sys.OCIGetBlobAtPrefetchWindow
+		}
 	}
 	s.Compat = impl.AddCompat(s)
 	return s, nil
@@ -121,12 +127,12 @@ func (s *ociImageSource) GetManifest(ctx context.Context, instanceDigest *digest
 		}
 	}
 
-	manifestPath, err := s.ref.blobPath(dig, s.sharedBlobDir)
+	r, _, err := s.blobs.getBlob(ctx, dig)
 	if err != nil {
 		return nil, "", err
 	}
-
-	m, err := os.ReadFile(manifestPath)
+	defer r.Close()
+	m, err := io.ReadAll(r)
 	if err != nil {
 		return nil, "", err
 	}
@@ -150,20 +156,154 @@ func (s *ociImageSource) GetBlob(ctx context.Context, info types.BlobInfo, cache
 		}
 	}
 
-	path, err := s.ref.blobPath(info.Digest, s.sharedBlobDir)
-	if err != nil {
-		return nil, 0, err
+	return s.blobs.getBlob(ctx, info.Digest)
+}
+
+// defaultGetBlobAtPrefetchWindow is the default value of types.SystemContext.OCIGetBlobAtPrefetchWindow.
+const defaultGetBlobAtPrefetchWindow = 1024 * 1024
+
+// GetBlobAt returns a sequential channel of readers that contain data for the requested
+// blob chunks, and a channel that might get a single error value.
+// The specified chunks must be not overlapping and sorted by their offset.
+// The readers must be fully consumed, in the order they are returned, before blocking
+// to read the next chunk.
+// If the Length for the last chunk is set to math.MaxUint64, then it
+// fully fetches the remaining data from the offset to the end of the blob.
+func (s *ociImageSource) GetBlobAt(ctx context.Context, info types.BlobInfo, chunks []private.ImageSourceChunk) (chan io.ReadCloser, chan error, error) {
+	if len(info.URLs) != 0 {
+		return nil, nil, fmt.Errorf("external URLs not supported with GetBlobAt")
 	}
 
-	r, err := os.Open(path)
+	r, size, err := s.blobs.getBlob(ctx, info.Digest)
 	if err != nil {
-		return nil, 0, err
+		return nil, nil, err
+	}
+	f, ok := r.(readerAtCloser)
+	if !ok || size < 0 {
+		r.Close()
+		return nil, nil, fmt.Errorf("blob %s does not support random access", info.Digest)
 	}
-	fi, err := r.Stat()
+	reads, err := planBlobChunkReads(chunks, uint64(size), s.prefetchWindow)
 	if err != nil {
-		return nil, 0, err
+		f.Close()
+		return nil, nil, err
+	}
+
+	streams := make(chan io.ReadCloser)
+	errs := make(chan error, 1) // serveBlobChunkReads sends at most one error; don’t block if the consumer has stopped reading.
+	go serveBlobChunkReads(ctx, streams, errs, f, reads, s.prefetchWindow)
+	return streams, errs, nil
+}
+
+// readerAtCloser is a blob stream which can be used by GetBlobAt.
+type readerAtCloser interface {
+	io.ReaderAt
+	io.Closer
+}
+
+// blobChunkRead is a single read from a blob file, satisfying one or more consecutive requested chunks.
+type blobChunkRead struct {
+	offset, length uint64                     // The range of the file to read
+	chunks         []private.ImageSourceChunk // The requested chunks within that range, with math.MaxUint64 lengths resolved
+}
+
+// planBlobChunkReads validates chunks against a blob of blobSize bytes, and groups them into reads.
+// If window > 0, consecutive chunks are coalesced into a single read, as long as the read does not exceed window bytes.
+func planBlobChunkReads(chunks []private.ImageSourceChunk, blobSize uint64, window int64) ([]blobChunkRead, error) {
+	res := []blobChunkRead{}
+	nextOffset := uint64(0)
+	for i, c := range chunks {
+		if i > 0 && chunks[i-1].Length == math.MaxUint64 {
+			return nil, private.BadPartialRequestError{Status: "another chunk requested after an until-EOF chunk"}
+		}
+		if c.Offset < nextOffset {
+			return nil, private.BadPartialRequestError{Status: fmt.Sprintf("chunk at offset %d overlaps a previous chunk or is out of order", c.Offset)}
+		}
+		if c.Offset > blobSize {
+			return nil, private.BadPartialRequestError{Status: fmt.Sprintf("chunk at offset %d is beyond the end of the blob (%d bytes)", c.Offset, blobSize)}
+		}
+		if c.Length == math.MaxUint64 {
+			c.Length = blobSize - c.Offset
+		} else if c.Length > blobSize-c.Offset {
+			return nil, private.BadPartialRequestError{Status: fmt.Sprintf("chunk at offset %d, length %d, is beyond the end of the blob (%d bytes)", c.Offset, c.Length, blobSize)}
+		}
+		nextOffset = c.Offset + c.Length
+
+		if window > 0 && len(res) > 0 {
+			last := &res[len(res)-1]
+			if nextOffset-last.offset <= uint64(window) {
+				last.length = nextOffset - last.offset
+				last.chunks = append(last.chunks, c)
+				continue
+			}
+		}
+		res = append(res, blobChunkRead{offset: c.Offset, length: c.Length, chunks: []private.ImageSourceChunk{c}})
 	}
-	return r, fi.Size(), nil
+	return res, nil
+}
+
+// serveBlobChunkReads performs reads from f, and sends a stream for each of the requested chunks to streams.
+// Reads that fit within window are done using a single read call; larger ones are streamed, reading ahead window bytes at a time.
+// It closes f, streams and errs when done.
+func serveBlobChunkReads(ctx context.Context, streams chan io.ReadCloser, errs chan error, f readerAtCloser, reads []blobChunkRead, window int64) {
+	defer close(streams)
+	defer close(errs)
+	defer f.Close()
+	for _, r := range reads {
+		if window > 0 && r.length <= uint64(window) {
+			buf := make([]byte, r.length)
+			if _, err := io.ReadFull(io.NewSectionReader(f, int64(r.offset), int64(r.length)), buf); err != nil {
+				errs <- err
+				return
+			}
+			for _, c := range r.chunks {
+				start := c.Offset - r.offset
+				if !sendBlobChunk(ctx, streams, errs, bytes.NewReader(buf[start:start+c.Length])) {
+					return
+				}
+			}
+		} else {
+			var reader io.Reader = io.NewSectionReader(f, int64(r.offset), int64(r.length))
+			if window > 0 {
+				reader = bufio.NewReaderSize(reader, int(window))
+			}
+			if !sendBlobChunk(ctx, streams, errs, reader) {
+				return
+			}
+		}
+	}
+}
+
+// sendBlobChunk sends reader to streams, and waits until the consumer closes it.
+// It returns false, after sending ctx.Err() to errs, if ctx is canceled before that happens.
+func sendBlobChunk(ctx context.Context, streams chan io.ReadCloser, errs chan error, reader io.Reader) bool {
+	s := &blobChunkReader{Reader: reader, closed: make(chan struct{})}
+	select {
+	case streams <- s:
+	case <-ctx.Done():
+		errs <- ctx.Err()
+		return false
+	}
+	// The file must stay open until the consumer is done with the stream.
+	select {
+	case <-s.closed:
+		return true
+	case <-ctx.Done():
+		errs <- ctx.Err()
+		return false
+	}
+}
+
+// blobChunkReader is a stream returned by GetBlobAt, which signals when it is closed.
+type blobChunkReader struct {
+	io.Reader
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (r *blobChunkReader) Close() error {
+	r.closeOnce.Do(func() { close(r.closed) })
+	return nil
 }
 
 // getExternalBlob returns the reader of the first available blob URL from urls, which must not be empty.
@@ -228,7 +368,11 @@ func GetLocalBlobPath(ctx context.Context, src types.ImageSource, digest digest.
 		return "", errors.New("caller error: GetLocalBlobPath called with a non-oci: source")
 	}
 
-	path, err := s.ref.blobPath(digest, s.sharedBlobDir)
+	blobs, ok := s.blobs.(*filesystemBlobStore)
+	if !ok {
+		return "", errors.New("GetLocalBlobPath is not supported with a custom OCILayoutBlobStore")
+	}
+	path, err := blobs.blobPath(digest)
 	if err != nil {
 		return "", err
 	}
@@ -6,6 +6,7 @@ import (
 	"crypto/x509"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -176,3 +177,196 @@ func TestGetLocalBlobPath(t *testing.T) {
 		require.Error(t, err)
 	}
 }
+
+func TestPlanBlobChunkReads(t *testing.T) {
+	const blobSize = 1000
+	type read struct {
+		offset, length uint64
+		chunks         []private.ImageSourceChunk
+	}
+	for _, c := range []struct {
+		name     string
+		chunks   []private.ImageSourceChunk
+		window   int64
+		expected []read // nil if an error is expected
+	}{
+		{
+			name:     "no chunks",
+			chunks:   []private.ImageSourceChunk{},
+			window:   100,
+			expected: []read{},
+		},
+		{
+			name:   "out of order",
+			chunks: []private.ImageSourceChunk{{Offset: 100, Length: 10}, {Offset: 50, Length: 10}},
+			window: 100,
+		},
+		{
+			name:   "overlapping",
+			chunks: []private.ImageSourceChunk{{Offset: 100, Length: 10}, {Offset: 105, Length: 10}},
+			window: 100,
+		},
+		{
+			name:   "offset past the end",
+			chunks: []private.ImageSourceChunk{{Offset: blobSize + 1, Length: 0}},
+			window: 100,
+		},
+		{
+			name:   "length past the end",
+			chunks: []private.ImageSourceChunk{{Offset: 990, Length: 11}},
+			window: 100,
+		},
+		{
+			name:   "chunk after an until-EOF chunk",
+			chunks: []private.ImageSourceChunk{{Offset: 100, Length: math.MaxUint64}, {Offset: 900, Length: 10}},
+			window: 100,
+		},
+		{
+			name:   "until-EOF chunk resolved",
+			chunks: []private.ImageSourceChunk{{Offset: 10, Length: 10}, {Offset: 950, Length: math.MaxUint64}},
+			window: 100,
+			expected: []read{
+				{offset: 10, length: 10, chunks: []private.ImageSourceChunk{{Offset: 10, Length: 10}}},
+				{offset: 950, length: 50, chunks: []private.ImageSourceChunk{{Offset: 950, Length: 50}}},
+			},
+		},
+		{
+			name:   "coalesced exactly at the window",
+			chunks: []private.ImageSourceChunk{{Offset: 0, Length: 10}, {Offset: 50, Length: 10}, {Offset: 90, Length: 10}},
+			window: 100,
+			expected: []read{
+				{offset: 0, length: 100, chunks: []private.ImageSourceChunk{{Offset: 0, Length: 10}, {Offset: 50, Length: 10}, {Offset: 90, Length: 10}}},
+			},
+		},
+		{
+			name:   "just over the window",
+			chunks: []private.ImageSourceChunk{{Offset: 0, Length: 10}, {Offset: 50, Length: 10}, {Offset: 90, Length: 11}},
+			window: 100,
+			expected: []read{
+				{offset: 0, length: 60, chunks: []private.ImageSourceChunk{{Offset: 0, Length: 10}, {Offset: 50, Length: 10}}},
+				{offset: 90, length: 11, chunks: []private.ImageSourceChunk{{Offset: 90, Length: 11}}},
+			},
+		},
+		{
+			name:   "chunk larger than the window",
+			chunks: []private.ImageSourceChunk{{Offset: 0, Length: 10}, {Offset: 10, Length: 500}, {Offset: 510, Length: 10}},
+			window: 100,
+			expected: []read{
+				{offset: 0, length: 10, chunks: []private.ImageSourceChunk{{Offset: 0, Length: 10}}},
+				{offset: 10, length: 500, chunks: []private.ImageSourceChunk{{Offset: 10, Length: 500}}},
+				{offset: 510, length: 10, chunks: []private.ImageSourceChunk{{Offset: 510, Length: 10}}},
+			},
+		},
+	} {
+		for _, window := range []int64{c.window, 0, -1} {
+			res, err := planBlobChunkReads(c.chunks, blobSize, window)
+			if c.expected == nil {
+				assert.Error(t, err, c.name)
+				assert.IsType(t, private.BadPartialRequestError{}, err, c.name)
+				continue
+			}
+			require.NoError(t, err, c.name)
+			expected := c.expected
+			if window <= 0 { // No coalescing
+				expected = []read{}
+				for _, r := range c.expected {
+					for _, chunk := range r.chunks {
+						expected = append(expected, read{offset: chunk.Offset, length: chunk.Length, chunks: []private.ImageSourceChunk{chunk}})
+					}
+				}
+			}
+			actual := []read{}
+			for _, r := range res {
+				actual = append(actual, read{offset: r.offset, length: r.length, chunks: r.chunks})
+			}
+			assert.Equal(t, expected, actual, "%s, window %d", c.name, window)
+		}
+	}
+}
+
+// readBlobChunks reads all data returned by GetBlobAt.
+func readBlobChunks(t *testing.T, streams chan io.ReadCloser, errs chan error) ([][]byte, error) {
+	res := [][]byte{}
+	for s := range streams {
+		data, err := io.ReadAll(s)
+		require.NoError(t, err)
+		require.NoError(t, s.Close())
+		res = append(res, data)
+	}
+	return res, <-errs
+}
+
+func TestGetBlobAt(t *testing.T) {
+	tmpDir := loadFixture(t, "delete_image_multiple_images")
+	ref, err := NewReference(tmpDir, "latest")
+	require.NoError(t, err)
+
+	blob := make([]byte, 4096)
+	for i := range blob {
+		blob[i] = byte(i * 7)
+	}
+	blobDigest := digest.FromBytes(blob)
+	err = os.WriteFile(filepath.Join(tmpDir, "blobs", blobDigest.Algorithm().String(), blobDigest.Encoded()), blob, 0o644)
+	require.NoError(t, err)
+
+	chunks := []private.ImageSourceChunk{
+		{Offset: 0, Length: 10},
+		{Offset: 15, Length: 5},
+		{Offset: 100, Length: 1000},
+		{Offset: 2000, Length: 0},
+		{Offset: 3000, Length: math.MaxUint64},
+	}
+	for _, window := range []int64{0, -1, 1, 64, 1500} {
+		src, err := ref.NewImageSource(context.Background(), &types.SystemContext{OCIGetBlobAtPrefetchWindow: window})
+		require.NoError(t, err)
+		defer src.Close()
+		s, ok := src.(private.ImageSource)
+		require.True(t, ok)
+		require.True(t, s.SupportsGetBlobAt())
+
+		streams, errs, err := s.GetBlobAt(context.Background(), types.BlobInfo{Digest: blobDigest, Size: -1}, chunks)
+		require.NoError(t, err)
+		data, err := readBlobChunks(t, streams, errs)
+		require.NoError(t, err)
+		require.Len(t, data, len(chunks))
+		for i, c := range chunks {
+			end := c.Offset + c.Length
+			if c.Length == math.MaxUint64 {
+				end = uint64(len(blob))
+			}
+			assert.Equal(t, blob[c.Offset:end], data[i], "window %d, chunk %d", window, i)
+		}
+
+		// Invalid requests
+		_, _, err = s.GetBlobAt(context.Background(), types.BlobInfo{Digest: blobDigest, Size: -1},
+			[]private.ImageSourceChunk{{Offset: 4000, Length: 100}})
+		var badRequest private.BadPartialRequestError
+		assert.ErrorAs(t, err, &badRequest)
+		_, _, err = s.GetBlobAt(context.Background(), types.BlobInfo{Digest: blobDigest, Size: -1, URLs: []string{"https://example.com/blob"}}, chunks)
+		assert.Error(t, err)
+		_, _, err = s.GetBlobAt(context.Background(), types.BlobInfo{Digest: digest.FromString("missing"), Size: -1}, chunks)
+		assert.Error(t, err)
+	}
+}
+
+func TestGetBlobAtCanceled(t *testing.T) {
+	tmpDir := loadFixture(t, "delete_image_multiple_images")
+	ref, err := NewReference(tmpDir, "latest")
+	require.NoError(t, err)
+	src, err := ref.NewImageSource(context.Background(), &types.SystemContext{})
+	require.NoError(t, err)
+	defer src.Close()
+	s, ok := src.(private.ImageSource)
+	require.True(t, ok)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	streams, errs, err := s.GetBlobAt(ctx, types.BlobInfo{Digest: "sha256:557ac7d133b7770216a8101268640edf4e88beab1b4e1e1bfc9b1891a1cab861", Size: -1},
+		[]private.ImageSourceChunk{{Offset: 0, Length: 1}, {Offset: 1, Length: 1}})
+	require.NoError(t, err)
+	// Receive a stream, never close it, and stop reading; the serving goroutine must still terminate.
+	<-streams
+	cancel()
+	assert.ErrorIs(t, <-errs, context.Canceled)
+	_, ok = <-streams
+	assert.False(t, ok)
+}
@@ -0,0 +1,156 @@
+package layout
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	digest "github.com/opencontainers/go-digest"
+)
+
+// indexSnapshotsFile is the name of the file, in the root of a layout, which lists the index.json snapshots.
+const indexSnapshotsFile = "index-snapshots.json"
+
+// IndexSnapshot is a prior state of index.json of a layout, recorded if types.SystemContext.OCIIndexSnapshots is set.
+type IndexSnapshot struct {
+	// Digest is the digest of the index.json contents, which are stored as a blob in the layout.
+	Digest digest.Digest `json:"digest"`
+	// Replaced is the time when the index.json contents were replaced.
+	Replaced time.Time `json:"replaced"`
+}
+
+// indexSnapshotList is the format of indexSnapshotsFile.
+type indexSnapshotList struct {
+	Snapshots []IndexSnapshot `json:"snapshots"`
+}
+
+// ListIndexSnapshots returns the index.json snapshots recorded in the layout at dir, oldest first.
+func ListIndexSnapshots(dir string) ([]IndexSnapshot, error) {
+	ref := ociReference{dir: dir}
+	list, err := ref.indexSnapshots()
+	if err != nil {
+		return nil, err
+	}
+	return list.Snapshots, nil
+}
+
+// RestoreIndexSnapshot replaces index.json of the layout at dir with the snapshot with snapshotDigest.
+// The replaced index.json is recorded as a snapshot, so that the restore can be undone as well.
+func RestoreIndexSnapshot(dir string, snapshotDigest digest.Digest) error {
+	ref := ociReference{dir: dir}
+	list, err := ref.indexSnapshots()
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, s := range list.Snapshots {
+		if s.Digest == snapshotDigest {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("index snapshot %s not found in %q", snapshotDigest, dir)
+	}
+
+	blobPath, err := ref.blobPath(snapshotDigest, "")
+	if err != nil {
+		return err
+	}
+	contents, err := os.ReadFile(blobPath)
+	if err != nil {
+		return fmt.Errorf("reading index snapshot %s: %w", snapshotDigest, err)
+	}
+	if actual := snapshotDigest.Algorithm().FromBytes(contents); actual != snapshotDigest {
+		return fmt.Errorf("index snapshot %s is corrupt, its digest is %s", snapshotDigest, actual)
+	}
+	if err := ref.snapshotIndex(contents); err != nil {
+		return err
+	}
+	return os.WriteFile(ref.indexPath(), contents, 0644)
+}
+
+// indexSnapshots returns the list of snapshots recorded for ref’s layout; the list is empty if there are none.
+func (ref ociReference) indexSnapshots() (*indexSnapshotList, error) {
+	list, err := parseJSON[indexSnapshotList](ref.indexSnapshotsPath())
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return &indexSnapshotList{}, nil
+		}
+		return nil, fmt.Errorf("reading index snapshots: %w", err)
+	}
+	return list, nil
+}
+
+// snapshotIndex records the current contents of index.json of ref’s layout as a snapshot, unless the file does not exist,
+// or its contents are equal to newIndex (which may be nil if unknown).
+func (ref ociReference) snapshotIndex(newIndex []byte) error {
+	current, err := os.ReadFile(ref.indexPath())
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if newIndex != nil && bytes.Equal(current, newIndex) {
+		return nil
+	}
+
+	// Snapshots are always stored in the layout itself, not in OCISharedBlobDirPath, which may be shared with other layouts.
+	currentDigest := digest.FromBytes(current)
+	blobPath, err := ref.blobPath(currentDigest, "")
+	if err != nil {
+		return err
+	}
+	if err := ensureParentDirectoryExists(blobPath); err != nil {
+		return err
+	}
+	if err := os.WriteFile(blobPath, current, 0644); err != nil {
+		return err
+	}
+
+	list, err := ref.indexSnapshots()
+	if err != nil {
+		return err
+	}
+	list.Snapshots = append(list.Snapshots, IndexSnapshot{
+		Digest:   currentDigest,
+		Replaced: time.Now().UTC(),
+	})
+	return saveJSON(ref.indexSnapshotsPath(), list)
+}
+
+// countBlobsReferencedBySnapshots updates dest with usage counts of blobs required for all index.json snapshots of ref’s layout,
+// INCLUDING the snapshots themselves, so that they are not deleted while a snapshot may be restored.
+// Snapshots themselves are always read from the layout directory, but blobs they reference are read from blobs.
+func (ref ociReference) countBlobsReferencedBySnapshots(ctx context.Context, dest map[digest.Digest]int, blobs blobStore) error {
+	list, err := ref.indexSnapshots()
+	if err != nil {
+		return err
+	}
+	for _, s := range list.Snapshots {
+		blobPath, err := ref.blobPath(s.Digest, "")
+		if err != nil {
+			return err
+		}
+		index, err := parseIndex(blobPath)
+		if err != nil {
+			return fmt.Errorf("reading index snapshot %s: %w", s.Digest, err)
+		}
+		dest[s.Digest]++
+		if err := ref.countBlobsReferencedByIndex(ctx, dest, index, blobs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexSnapshotsPath returns the path of indexSnapshotsFile of ref’s layout.
+func (ref ociReference) indexSnapshotsPath() string {
+	return filepath.Join(ref.dir, indexSnapshotsFile)
+}
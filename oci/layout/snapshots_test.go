@@ -0,0 +1,98 @@
+package layout
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// putSnapshotTestManifest writes a minimal OCI manifest, which differs based on layer, to dir:tag.
+func putSnapshotTestManifest(t *testing.T, sys *types.SystemContext, dir, tag, layer string) digest.Digest {
+	ref, err := NewReference(dir, tag)
+	require.NoError(t, err)
+	dest, err := newImageDestination(sys, ref.(ociReference))
+	require.NoError(t, err)
+	defer dest.Close()
+	m := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json",` +
+		`"config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":"` + digest.FromString("config").String() + `","size":6},` +
+		`"layers":[{"mediaType":"application/vnd.oci.image.layer.v1.tar","digest":"` + digest.FromString(layer).String() + `","size":5}]}`)
+	err = dest.PutManifest(context.Background(), m, nil)
+	require.NoError(t, err)
+	err = dest.CommitWithOptions(context.Background(), private.CommitOptions{})
+	require.NoError(t, err)
+	return digest.FromBytes(m)
+}
+
+// taggedManifest returns the digest of the manifest tagged tag in the layout at dir.
+func taggedManifest(t *testing.T, dir, tag string) digest.Digest {
+	ref, err := NewReference(dir, tag)
+	require.NoError(t, err)
+	desc, err := LoadManifestDescriptor(ref)
+	require.NoError(t, err)
+	return desc.Digest
+}
+
+func TestIndexSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	sys := &types.SystemContext{OCIIndexSnapshots: true}
+
+	// No snapshots are recorded when the index is created, or without OCIIndexSnapshots
+	first := putSnapshotTestManifest(t, sys, dir, "tag", "first")
+	putSnapshotTestManifest(t, nil, dir, "other", "other")
+	snapshots, err := ListIndexSnapshots(dir)
+	require.NoError(t, err)
+	assert.Empty(t, snapshots)
+
+	// Overwriting a tag records a snapshot
+	second := putSnapshotTestManifest(t, sys, dir, "tag", "second")
+	assert.Equal(t, second, taggedManifest(t, dir, "tag"))
+	snapshots, err = ListIndexSnapshots(dir)
+	require.NoError(t, err)
+	require.Len(t, snapshots, 1)
+	assert.False(t, snapshots[0].Replaced.IsZero())
+
+	// An unchanged index does not record a snapshot
+	putSnapshotTestManifest(t, sys, dir, "tag", "second")
+	snapshots, err = ListIndexSnapshots(dir)
+	require.NoError(t, err)
+	require.Len(t, snapshots, 1)
+
+	// Restoring a snapshot restores the tag, and records the replaced state
+	err = RestoreIndexSnapshot(dir, snapshots[0].Digest)
+	require.NoError(t, err)
+	assert.Equal(t, first, taggedManifest(t, dir, "tag"))
+	snapshots, err = ListIndexSnapshots(dir)
+	require.NoError(t, err)
+	require.Len(t, snapshots, 2)
+	err = RestoreIndexSnapshot(dir, snapshots[1].Digest)
+	require.NoError(t, err)
+	assert.Equal(t, second, taggedManifest(t, dir, "tag"))
+
+	// Unknown snapshots are rejected
+	err = RestoreIndexSnapshot(dir, digest.FromString("unknown"))
+	assert.Error(t, err)
+
+	// Deleting an image records a snapshot, and preserves blobs used by snapshots
+	ref, err := NewReference(dir, "tag")
+	require.NoError(t, err)
+	err = ref.DeleteImage(context.Background(), sys)
+	require.NoError(t, err)
+	snapshots, err = ListIndexSnapshots(dir)
+	require.NoError(t, err)
+	require.Len(t, snapshots, 4)
+	for _, d := range []digest.Digest{first, second, snapshots[3].Digest} {
+		_, err := os.Stat(filepath.Join(dir, imgspecv1.ImageBlobsDir, d.Algorithm().String(), d.Encoded()))
+		assert.NoError(t, err, d.String())
+	}
+	err = RestoreIndexSnapshot(dir, snapshots[3].Digest)
+	require.NoError(t, err)
+	assert.Equal(t, second, taggedManifest(t, dir, "tag"))
+}
@@ -112,6 +112,13 @@ func (d *openshiftImageDestination) SupportsPutBlobPartial() bool {
 	return d.docker.SupportsPutBlobPartial()
 }
 
+// Capabilities returns the capabilities of the destination which are not reported by other methods.
+func (d *openshiftImageDestination) Capabilities() private.DestinationCapabilities {
+	res := d.docker.Capabilities()
+	res.Deletion = types.OptionalBoolFalse // See openshiftReference.DeleteImage.
+	return res
+}
+
 // NoteOriginalOCIConfig provides the config of the image, as it exists on the source, BUT converted to OCI format,
 // or an error obtaining that value (e.g. if the image is an artifact and not a container image).
 // The destination can use it in its TryReusingBlob/PutBlob implementations
@@ -201,6 +208,9 @@ func (d *openshiftImageDestination) PutSignaturesWithFormat(ctx context.Context,
 		if !ok {
 			return signature.UnsupportedFormatError(newSigWithFormat)
 		}
+		if newSigSimple.UntrustedTimestampToken() != nil {
+			return signature.ErrUnsupportedTimestampToken
+		}
 		newSig := newSigSimple.UntrustedSignature()
 
 		if slices.ContainsFunc(image.Signatures, func(existingSig imageSignature) bool {
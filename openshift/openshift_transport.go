@@ -45,6 +45,10 @@ func (t openshiftTransport) ValidatePolicyConfigurationScope(scope string) error
 	if scopeRegexp.FindStringIndex(scope) == nil {
 		return fmt.Errorf("Invalid scope name %s", scope)
 	}
+	// Unlike docker:, pattern scopes are not supported; refuse them instead of silently never matching them.
+	if policyconfiguration.DockerReferenceScopeIsPattern(scope) {
+		return fmt.Errorf("Pattern scopes like %s are not supported", scope)
+	}
 	return nil
 }
 
@@ -35,6 +35,7 @@ func TestTransportValidatePolicyConfigurationScope(t *testing.T) {
 	for _, scope := range []string{
 		"registry.example.com/too/deep/hierarchy",
 		"registry.example.com/ns/stream:tag1:tag2",
+		"registry.example.com/ns/*:release-*",
 	} {
 		err := Transport.ValidatePolicyConfigurationScope(scope)
 		assert.Error(t, err, scope)
@@ -100,6 +100,10 @@ func newImageDestination(ref ostreeReference, tmpDirPath string) (private.ImageD
 			MustMatchRuntimeOS:             true,
 			IgnoresEmbeddedDockerReference: false, // N/A, DockerReference() returns nil.
 			HasThreadSafePutBlob:           false,
+			Capabilities: private.DestinationCapabilities{
+				Referrers: types.OptionalBoolFalse,
+				Deletion:  types.OptionalBoolFalse,
+			},
 		}),
 		NoPutBlobPartialInitialize: stubs.NoPutBlobPartial(ref),
 
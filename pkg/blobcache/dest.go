@@ -244,6 +244,11 @@ func (d *blobCacheDestination) SupportsPutBlobPartial() bool {
 	return d.destination.SupportsPutBlobPartial()
 }
 
+// Capabilities returns the capabilities of the destination which are not reported by other methods.
+func (d *blobCacheDestination) Capabilities() private.DestinationCapabilities {
+	return d.destination.Capabilities()
+}
+
 // PutBlobPartial attempts to create a blob using the data that is already present
 // at the destination. chunkAccessor is accessed in a non-sequential way to retrieve the missing chunks.
 // It is available only if SupportsPutBlobPartial().
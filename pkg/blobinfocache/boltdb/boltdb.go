@@ -401,16 +401,61 @@ func (bdc *cache) RecordKnownLocation(transport types.ImageTransport, scope type
 	}) // FIXME? Log error (but throttle the log volume on repeated accesses)?
 }
 
-// appendReplacementCandidates creates prioritize.CandidateWithTime values for digest in scopeBucket
-// (which might be nil) with corresponding compression
-// info from compressionBucket and specificVariantCompresssionBucket (which might be nil), and returns the result of appending them
-// to candidates.
-// v2Options is not nil if the caller is CandidateLocations2: this allows including candidates with unknown location, and filters out candidates
-// with unknown compression.
-func (bdc *cache) appendReplacementCandidates(candidates []prioritize.CandidateWithTime, scopeBucket, compressionBucket, specificVariantCompresssionBucket *bolt.Bucket,
-	digest digest.Digest, v2Options *blobinfocache.CandidateLocations2Options) []prioritize.CandidateWithTime {
-	digestKey := []byte(digest.String())
-	compressionData := blobinfocache.DigestCompressorData{
+// RecordBlobAbsence records that a blob with the specified digest was found NOT to exist at location
+// within the specified (transport, scope) scope.
+// The BoltDB cache does not record absence; this does nothing.
+func (bdc *cache) RecordBlobAbsence(transport types.ImageTransport, scope types.BICTransportScope, blobDigest digest.Digest, location types.BICLocationReference) {
+}
+
+// BlobKnownAbsent returns true if a blob with the specified digest was recorded by RecordBlobAbsence as not existing at location
+// within the specified (transport, scope) scope at most maxAge ago, and its existence was not recorded since.
+// The BoltDB cache does not record absence, so this always returns false.
+func (bdc *cache) BlobKnownAbsent(transport types.ImageTransport, scope types.BICTransportScope, blobDigest digest.Digest, location types.BICLocationReference, maxAge time.Duration) bool {
+	return false
+}
+
+// PurgeScope removes all known locations recorded within the specified (transport, scope) scope.
+func (bdc *cache) PurgeScope(transport types.ImageTransport, scope types.BICTransportScope) error {
+	return bdc.update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(knownLocationsBucket)
+		if b == nil {
+			return nil
+		}
+		b = b.Bucket([]byte(transport.Name()))
+		if b == nil {
+			return nil
+		}
+		if err := b.DeleteBucket([]byte(scope.Opaque)); err != nil && !errors.Is(err, bolt.ErrBucketNotFound) {
+			return err
+		}
+		return nil
+	})
+}
+
+// DigestCompressorData returns data recorded by RecordDigestCompressorData for the blob with the specified digest.
+// If nothing is known, BaseVariantCompressor and SpecificVariantCompressor are blobinfocache.UnknownCompression.
+func (bdc *cache) DigestCompressorData(anyDigest digest.Digest) blobinfocache.DigestCompressorData {
+	res := blobinfocache.DigestCompressorData{
+		BaseVariantCompressor:      blobinfocache.UnknownCompression,
+		SpecificVariantCompressor:  blobinfocache.UnknownCompression,
+		SpecificVariantAnnotations: nil,
+	}
+	_ = bdc.view(func(tx *bolt.Tx) error {
+		data, err := digestCompressorData(tx.Bucket(digestCompressorBucket), tx.Bucket(digestSpecificVariantCompressorBucket), anyDigest)
+		if err != nil {
+			return err
+		}
+		res = data
+		return nil
+	}) // Including os.IsNotExist(err); FIXME? Log error (but throttle the log volume on repeated accesses)?
+	return res
+}
+
+// digestCompressorData returns compression data for anyDigest recorded in compressionBucket and specificVariantCompresssionBucket
+// (which might be nil).
+func digestCompressorData(compressionBucket, specificVariantCompresssionBucket *bolt.Bucket, anyDigest digest.Digest) (blobinfocache.DigestCompressorData, error) {
+	digestKey := []byte(anyDigest.String())
+	res := blobinfocache.DigestCompressorData{
 		BaseVariantCompressor:      blobinfocache.UnknownCompression,
 		SpecificVariantCompressor:  blobinfocache.UnknownCompression,
 		SpecificVariantAnnotations: nil,
@@ -419,19 +464,35 @@ func (bdc *cache) appendReplacementCandidates(candidates []prioritize.CandidateW
 		// the bucket won't exist if the cache was created by a v1 implementation and
 		// hasn't yet been updated by a v2 implementation
 		if compressorNameValue := compressionBucket.Get(digestKey); len(compressorNameValue) > 0 {
-			compressionData.BaseVariantCompressor = string(compressorNameValue)
+			res.BaseVariantCompressor = string(compressorNameValue)
 		}
 		if specificVariantCompresssionBucket != nil {
 			if svcData := specificVariantCompresssionBucket.Get(digestKey); svcData != nil {
 				if compressorBytes, annotationBytes, ok := bytes.Cut(svcData, []byte{0}); ok {
-					compressionData.SpecificVariantCompressor = string(compressorBytes)
-					if err := json.Unmarshal(annotationBytes, &compressionData.SpecificVariantAnnotations); err != nil {
-						return candidates // FIXME? Log error (but throttle the log volume on repeated accesses)?
+					res.SpecificVariantCompressor = string(compressorBytes)
+					if err := json.Unmarshal(annotationBytes, &res.SpecificVariantAnnotations); err != nil {
+						return blobinfocache.DigestCompressorData{}, err
 					}
 				}
 			}
 		}
 	}
+	return res, nil
+}
+
+// appendReplacementCandidates creates prioritize.CandidateWithTime values for digest in scopeBucket
+// (which might be nil) with corresponding compression
+// info from compressionBucket and specificVariantCompresssionBucket (which might be nil), and returns the result of appending them
+// to candidates.
+// v2Options is not nil if the caller is CandidateLocations2: this allows including candidates with unknown location, and filters out candidates
+// with unknown compression.
+func (bdc *cache) appendReplacementCandidates(candidates []prioritize.CandidateWithTime, scopeBucket, compressionBucket, specificVariantCompresssionBucket *bolt.Bucket,
+	digest digest.Digest, v2Options *blobinfocache.CandidateLocations2Options) []prioritize.CandidateWithTime {
+	digestKey := []byte(digest.String())
+	compressionData, err := digestCompressorData(compressionBucket, specificVariantCompresssionBucket, digest)
+	if err != nil {
+		return candidates // FIXME? Log error (but throttle the log volume on repeated accesses)?
+	}
 	template := prioritize.CandidateTemplateWithCompression(v2Options, digest, compressionData)
 	if template == nil {
 		return candidates
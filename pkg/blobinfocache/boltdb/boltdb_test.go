@@ -23,4 +23,8 @@ func TestNew(t *testing.T) {
 	test.GenericCache(t, newTestCache)
 }
 
+func TestPurgeScope(t *testing.T) {
+	test.PurgeScope(t, newTestCache, false)
+}
+
 // FIXME: Tests for the various corner cases / failure cases of boltDBCache should be added here.
@@ -3,6 +3,7 @@ package test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/containers/image/v5/internal/blobinfocache"
 	"github.com/containers/image/v5/internal/testing/mocks"
@@ -45,6 +46,7 @@ func GenericCache(t *testing.T, newTestCache func(t *testing.T) blobinfocache.Bl
 		{"RecordDigestUncompressedPair", testGenericRecordDigestUncompressedPair},
 		{"UncompressedDigestForTOC", testGenericUncompressedDigestForTOC},
 		{"RecordTOCUncompressedPair", testGenericRecordTOCUncompressedPair},
+		{"DigestCompressorData", testGenericDigestCompressorData},
 		{"RecordKnownLocations", testGenericRecordKnownLocations},
 		{"CandidateLocations", testGenericCandidateLocations},
 		{"CandidateLocations2", testGenericCandidateLocations2},
@@ -123,6 +125,40 @@ func testGenericRecordTOCUncompressedPair(t *testing.T, cache blobinfocache.Blob
 	}
 }
 
+func testGenericDigestCompressorData(t *testing.T, cache blobinfocache.BlobInfoCache2) {
+	unknown := blobinfocache.DigestCompressorData{
+		BaseVariantCompressor:      blobinfocache.UnknownCompression,
+		SpecificVariantCompressor:  blobinfocache.UnknownCompression,
+		SpecificVariantAnnotations: nil,
+	}
+	// Nothing is known.
+	assert.Equal(t, unknown, cache.DigestCompressorData(digestUnknown))
+
+	gzipData := blobinfocache.DigestCompressorData{
+		BaseVariantCompressor:      compressiontypes.GzipAlgorithmName,
+		SpecificVariantCompressor:  blobinfocache.UnknownCompression,
+		SpecificVariantAnnotations: nil,
+	}
+	chunkedData := blobinfocache.DigestCompressorData{
+		BaseVariantCompressor:      compressiontypes.ZstdAlgorithmName,
+		SpecificVariantCompressor:  compressiontypes.ZstdChunkedAlgorithmName,
+		SpecificVariantAnnotations: map[string]string{"a": "b"},
+	}
+	for range 2 { // Record the same data twice to ensure redundant writes don’t break things.
+		cache.RecordDigestCompressorData(digestGzip, gzipData)
+		assert.Equal(t, gzipData, cache.DigestCompressorData(digestGzip))
+		cache.RecordDigestCompressorData(digestZstdChunked, chunkedData)
+		assert.Equal(t, chunkedData, cache.DigestCompressorData(digestZstdChunked))
+	}
+	// A later record without the specific variant does not drop the chunked details.
+	cache.RecordDigestCompressorData(digestZstdChunked, blobinfocache.DigestCompressorData{
+		BaseVariantCompressor:      compressiontypes.ZstdAlgorithmName,
+		SpecificVariantCompressor:  blobinfocache.UnknownCompression,
+		SpecificVariantAnnotations: nil,
+	})
+	assert.Equal(t, chunkedData, cache.DigestCompressorData(digestZstdChunked))
+}
+
 func testGenericRecordKnownLocations(t *testing.T, cache blobinfocache.BlobInfoCache2) {
 	transport := mocks.NameImageTransport("==BlobInfocache transport mock")
 	for range 2 { // Record the same data twice to ensure redundant writes don’t break things.
@@ -146,6 +182,95 @@ func testGenericRecordKnownLocations(t *testing.T, cache blobinfocache.BlobInfoC
 	}
 }
 
+// BlobAbsence tests RecordBlobAbsence / BlobKnownAbsent, given a newTestCache
+// (as in GenericCache) for an implementation which records absence.
+func BlobAbsence(t *testing.T, newTestCache func(t *testing.T) blobinfocache.BlobInfoCache2) {
+	cache := newTestCache(t)
+	cache.Open()
+	defer cache.Close()
+
+	transport := mocks.NameImageTransport("==BlobInfocache transport mock")
+	scope := types.BICTransportScope{Opaque: "A"}
+	otherScope := types.BICTransportScope{Opaque: "B"}
+	lr1 := types.BICLocationReference{Opaque: "A1"}
+	lr2 := types.BICLocationReference{Opaque: "A2"}
+
+	// Nothing is known.
+	assert.False(t, cache.BlobKnownAbsent(transport, scope, digestCompressedA, lr1, time.Hour))
+
+	for range 2 { // Record the same data twice to ensure redundant writes don’t break things.
+		cache.RecordBlobAbsence(transport, scope, digestCompressedA, lr1)
+		assert.True(t, cache.BlobKnownAbsent(transport, scope, digestCompressedA, lr1, time.Hour))
+	}
+	// Records expire after maxAge.
+	assert.False(t, cache.BlobKnownAbsent(transport, scope, digestCompressedA, lr1, -time.Second))
+	// Other digests, locations and scopes are not affected.
+	assert.False(t, cache.BlobKnownAbsent(transport, scope, digestCompressedB, lr1, time.Hour))
+	assert.False(t, cache.BlobKnownAbsent(transport, scope, digestCompressedA, lr2, time.Hour))
+	assert.False(t, cache.BlobKnownAbsent(transport, otherScope, digestCompressedA, lr1, time.Hour))
+
+	// Recording a known location discards the absence, only for that location.
+	cache.RecordBlobAbsence(transport, scope, digestCompressedA, lr2)
+	cache.RecordKnownLocation(transport, scope, digestCompressedA, lr1)
+	assert.False(t, cache.BlobKnownAbsent(transport, scope, digestCompressedA, lr1, time.Hour))
+	assert.True(t, cache.BlobKnownAbsent(transport, scope, digestCompressedA, lr2, time.Hour))
+}
+
+// PurgeScope tests PurgeScope, given a newTestCache (as in GenericCache).
+// If recordsAbsence, the implementation is expected to record absence, and the test verifies the absences are purged as well.
+func PurgeScope(t *testing.T, newTestCache func(t *testing.T) blobinfocache.BlobInfoCache2, recordsAbsence bool) {
+	cache := newTestCache(t)
+	cache.Open()
+	defer cache.Close()
+
+	transport := mocks.NameImageTransport("==BlobInfocache transport mock")
+	otherTransport := mocks.NameImageTransport("==BlobInfocache other transport mock")
+	scope := types.BICTransportScope{Opaque: "A"}
+	otherScope := types.BICTransportScope{Opaque: "B"}
+	lr1 := types.BICLocationReference{Opaque: "1"}
+	lr2 := types.BICLocationReference{Opaque: "2"}
+
+	// Purging a scope with no records is not an error.
+	err := cache.PurgeScope(transport, scope)
+	require.NoError(t, err)
+
+	cache.RecordDigestUncompressedPair(digestCompressedA, digestUncompressed)
+	for _, tr := range []types.ImageTransport{transport, otherTransport} {
+		for _, s := range []types.BICTransportScope{scope, otherScope} {
+			for _, d := range []digest.Digest{digestCompressedA, digestCompressedB} {
+				cache.RecordKnownLocation(tr, s, d, lr1)
+				cache.RecordBlobAbsence(tr, s, d, lr2)
+			}
+		}
+	}
+
+	err = cache.PurgeScope(transport, scope)
+	require.NoError(t, err)
+	for _, d := range []digest.Digest{digestCompressedA, digestCompressedB} {
+		assert.Empty(t, cache.CandidateLocations(transport, scope, d, false))
+		assert.False(t, cache.BlobKnownAbsent(transport, scope, d, lr2, time.Hour))
+		// Other scopes and transports are not affected.
+		for _, c := range []struct {
+			transport types.ImageTransport
+			scope     types.BICTransportScope
+		}{
+			{transport, otherScope},
+			{otherTransport, scope},
+		} {
+			assert.Equal(t, []types.BICReplacementCandidate{{Digest: d, Location: lr1}},
+				cache.CandidateLocations(c.transport, c.scope, d, false))
+			assert.Equal(t, recordsAbsence, cache.BlobKnownAbsent(c.transport, c.scope, d, lr2, time.Hour))
+		}
+	}
+	// Data not specific to a scope is not affected.
+	assert.Equal(t, digestUncompressed, cache.UncompressedDigest(digestCompressedA))
+
+	// New records can be added after purging.
+	cache.RecordKnownLocation(transport, scope, digestCompressedA, lr2)
+	assert.Equal(t, []types.BICReplacementCandidate{{Digest: digestCompressedA, Location: lr2}},
+		cache.CandidateLocations(transport, scope, digestCompressedA, false))
+}
+
 // candidate is a shorthand for types.BICReplacementCandidate
 type candidate struct {
 	d  digest.Digest
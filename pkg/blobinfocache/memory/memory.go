@@ -28,6 +28,7 @@ type cache struct {
 	uncompressedDigestsByTOC map[digest.Digest]digest.Digest
 	digestsByUncompressed    map[digest.Digest]*set.Set[digest.Digest]                // stores a set of digests for each uncompressed digest
 	knownLocations           map[locationKey]map[types.BICLocationReference]time.Time // stores last known existence time for each location reference
+	knownAbsences            map[locationKey]map[types.BICLocationReference]time.Time // stores last known absence time for each location reference
 	compressors              map[digest.Digest]blobinfocache.DigestCompressorData     // stores compression data for each digest; BaseVariantCompressor != UnknownCompression
 }
 
@@ -49,6 +50,7 @@ func new2() *cache {
 		uncompressedDigestsByTOC: map[digest.Digest]digest.Digest{},
 		digestsByUncompressed:    map[digest.Digest]*set.Set[digest.Digest]{},
 		knownLocations:           map[locationKey]map[types.BICLocationReference]time.Time{},
+		knownAbsences:            map[locationKey]map[types.BICLocationReference]time.Time{},
 		compressors:              map[digest.Digest]blobinfocache.DigestCompressorData{},
 	}
 }
@@ -142,6 +144,51 @@ func (mem *cache) RecordKnownLocation(transport types.ImageTransport, scope type
 		mem.knownLocations[key] = locationScope
 	}
 	locationScope[location] = time.Now() // Possibly overwriting an older entry.
+	if absences, ok := mem.knownAbsences[key]; ok {
+		delete(absences, location)
+		if len(absences) == 0 {
+			delete(mem.knownAbsences, key)
+		}
+	}
+}
+
+// RecordBlobAbsence records that a blob with the specified digest was found NOT to exist at location
+// within the specified (transport, scope) scope.
+// The record is discarded by a later RecordKnownLocation for the same location.
+func (mem *cache) RecordBlobAbsence(transport types.ImageTransport, scope types.BICTransportScope, blobDigest digest.Digest, location types.BICLocationReference) {
+	mem.mutex.Lock()
+	defer mem.mutex.Unlock()
+	key := locationKey{transport: transport.Name(), scope: scope, blobDigest: blobDigest}
+	absences, ok := mem.knownAbsences[key]
+	if !ok {
+		absences = map[types.BICLocationReference]time.Time{}
+		mem.knownAbsences[key] = absences
+	}
+	absences[location] = time.Now() // Possibly overwriting an older entry.
+}
+
+// BlobKnownAbsent returns true if a blob with the specified digest was recorded by RecordBlobAbsence as not existing at location
+// within the specified (transport, scope) scope at most maxAge ago, and its existence was not recorded since.
+func (mem *cache) BlobKnownAbsent(transport types.ImageTransport, scope types.BICTransportScope, blobDigest digest.Digest, location types.BICLocationReference, maxAge time.Duration) bool {
+	mem.mutex.Lock()
+	defer mem.mutex.Unlock()
+	t, ok := mem.knownAbsences[locationKey{transport: transport.Name(), scope: scope, blobDigest: blobDigest}][location]
+	return ok && time.Since(t) <= maxAge
+}
+
+// PurgeScope removes all known locations and absences recorded within the specified (transport, scope) scope.
+func (mem *cache) PurgeScope(transport types.ImageTransport, scope types.BICTransportScope) error {
+	mem.mutex.Lock()
+	defer mem.mutex.Unlock()
+	transportName := transport.Name()
+	for _, m := range []map[locationKey]map[types.BICLocationReference]time.Time{mem.knownLocations, mem.knownAbsences} {
+		for key := range m {
+			if key.transport == transportName && key.scope == scope {
+				delete(m, key)
+			}
+		}
+	}
+	return nil
 }
 
 // RecordDigestCompressorData records data for the blob with the specified digest.
@@ -180,6 +227,26 @@ func (mem *cache) RecordDigestCompressorData(anyDigest digest.Digest, data blobi
 	mem.compressors[anyDigest] = data
 }
 
+// DigestCompressorData returns data recorded by RecordDigestCompressorData for the blob with the specified digest.
+// If nothing is known, BaseVariantCompressor and SpecificVariantCompressor are blobinfocache.UnknownCompression.
+func (mem *cache) DigestCompressorData(anyDigest digest.Digest) blobinfocache.DigestCompressorData {
+	mem.mutex.Lock()
+	defer mem.mutex.Unlock()
+	return mem.digestCompressorDataLocked(anyDigest)
+}
+
+// digestCompressorDataLocked implements DigestCompressorData, but must be called only with mem.mutex held.
+func (mem *cache) digestCompressorDataLocked(anyDigest digest.Digest) blobinfocache.DigestCompressorData {
+	if v, ok := mem.compressors[anyDigest]; ok {
+		return v
+	}
+	return blobinfocache.DigestCompressorData{
+		BaseVariantCompressor:      blobinfocache.UnknownCompression,
+		SpecificVariantCompressor:  blobinfocache.UnknownCompression,
+		SpecificVariantAnnotations: nil,
+	}
+}
+
 // appendReplacementCandidates creates prioritize.CandidateWithTime values for digest in memory
 // with corresponding compression info from mem.compressors, and returns the result of appending
 // them to candidates.
@@ -187,14 +254,7 @@ func (mem *cache) RecordDigestCompressorData(anyDigest digest.Digest, data blobi
 // with unknown compression.
 func (mem *cache) appendReplacementCandidates(candidates []prioritize.CandidateWithTime, transport types.ImageTransport, scope types.BICTransportScope, digest digest.Digest,
 	v2Options *blobinfocache.CandidateLocations2Options) []prioritize.CandidateWithTime {
-	compressionData := blobinfocache.DigestCompressorData{
-		BaseVariantCompressor:      blobinfocache.UnknownCompression,
-		SpecificVariantCompressor:  blobinfocache.UnknownCompression,
-		SpecificVariantAnnotations: nil,
-	}
-	if v, ok := mem.compressors[digest]; ok {
-		compressionData = v
-	}
+	compressionData := mem.digestCompressorDataLocked(digest)
 	template := prioritize.CandidateTemplateWithCompression(v2Options, digest, compressionData)
 	if template == nil {
 		return candidates
@@ -16,3 +16,11 @@ func newTestCache(t *testing.T) blobinfocache.BlobInfoCache2 {
 func TestNew(t *testing.T) {
 	test.GenericCache(t, newTestCache)
 }
+
+func TestBlobAbsence(t *testing.T) {
+	test.BlobAbsence(t, newTestCache)
+}
+
+func TestPurgeScope(t *testing.T) {
+	test.PurgeScope(t, newTestCache, true)
+}
@@ -52,6 +52,12 @@ func (noCache) RecordTOCUncompressedPair(tocDigest digest.Digest, uncompressed d
 func (noCache) RecordKnownLocation(transport types.ImageTransport, scope types.BICTransportScope, blobDigest digest.Digest, location types.BICLocationReference) {
 }
 
+// PurgeScope removes all known locations and absences recorded within the specified (transport, scope) scope.
+// There are no such records, so this does nothing.
+func (noCache) PurgeScope(transport types.ImageTransport, scope types.BICTransportScope) error {
+	return nil
+}
+
 // CandidateLocations returns a prioritized, limited, number of blobs and their locations that could possibly be reused
 // within the specified (transport scope) (if they still exist, which is not guaranteed).
 //
@@ -0,0 +1,14 @@
+package blobinfocache
+
+import (
+	internalblobinfocache "github.com/containers/image/v5/internal/blobinfocache"
+	"github.com/containers/image/v5/types"
+)
+
+// PurgeScope removes all known locations (and absences) of blobs recorded in cache within the specified (transport, scope) scope,
+// e.g. after a registry was found to be misconfigured or untrustworthy, so that the records can’t affect future copies.
+//
+// The scope values are transport-specific; for the docker:// transport, see docker.PurgeBlobInfoCacheRegistry.
+func PurgeScope(cache types.BlobInfoCache, transport types.ImageTransport, scope types.BICTransportScope) error {
+	return internalblobinfocache.FromBlobInfoCache(cache).PurgeScope(transport, scope)
+}
@@ -325,6 +325,19 @@ func ensureDBHasCurrentSchema(db *sql.DB) error {
 				specificVariantAnnotations	BLOB NOT NULL
 			)`,
 		},
+		{
+			"KnownAbsences",
+			`CREATE TABLE IF NOT EXISTS KnownAbsences(
+				transport	TEXT NOT NULL,
+				scope 		TEXT NOT NULL,
+				digest		TEXT NOT NULL,
+				location	TEXT NOT NULL,` +
+				// Same format as KnownLocations.time.
+				`time		TIMESTAMP NOT NULL,` +
+				// Implies an index.
+				`PRIMARY KEY (transport, scope, digest, location)
+			)`,
+		},
 	}
 
 	_, err := dbTransaction(db, func(tx *sql.Tx) (void, error) {
@@ -475,10 +488,60 @@ func (sqc *cache) RecordKnownLocation(transport types.ImageTransport, scope type
 			return void{}, fmt.Errorf("recording known location %q for (%q, %q, %q): %w",
 				location.Opaque, transport.Name(), scope.Opaque, digest.String(), err)
 		}
+		if _, err := tx.Exec("DELETE FROM KnownAbsences WHERE transport = ? AND scope = ? AND digest = ? AND location = ?",
+			transport.Name(), scope.Opaque, digest.String(), location.Opaque); err != nil {
+			return void{}, fmt.Errorf("deleting known absence %q for (%q, %q, %q): %w",
+				location.Opaque, transport.Name(), scope.Opaque, digest.String(), err)
+		}
 		return void{}, nil
 	}) // FIXME? Log error (but throttle the log volume on repeated accesses)?
 }
 
+// RecordBlobAbsence records that a blob with the specified digest was found NOT to exist at location
+// within the specified (transport, scope) scope.
+// The record is discarded by a later RecordKnownLocation for the same location.
+func (sqc *cache) RecordBlobAbsence(transport types.ImageTransport, scope types.BICTransportScope, digest digest.Digest, location types.BICLocationReference) {
+	_, _ = transaction(sqc, func(tx *sql.Tx) (void, error) {
+		if _, err := tx.Exec("INSERT OR REPLACE INTO KnownAbsences(transport, scope, digest, location, time) VALUES (?, ?, ?, ?, ?)",
+			transport.Name(), scope.Opaque, digest.String(), location.Opaque, time.Now()); err != nil { // Possibly overwriting an older entry.
+			return void{}, fmt.Errorf("recording known absence %q for (%q, %q, %q): %w",
+				location.Opaque, transport.Name(), scope.Opaque, digest.String(), err)
+		}
+		return void{}, nil
+	}) // FIXME? Log error (but throttle the log volume on repeated accesses)?
+}
+
+// BlobKnownAbsent returns true if a blob with the specified digest was recorded by RecordBlobAbsence as not existing at location
+// within the specified (transport, scope) scope at most maxAge ago, and its existence was not recorded since.
+func (sqc *cache) BlobKnownAbsent(transport types.ImageTransport, scope types.BICTransportScope, digest digest.Digest, location types.BICLocationReference, maxAge time.Duration) bool {
+	res, err := transaction(sqc, func(tx *sql.Tx) (bool, error) {
+		t, found, err := querySingleValue[time.Time](tx, "SELECT time FROM KnownAbsences WHERE transport = ? AND scope = ? AND digest = ? AND location = ?",
+			transport.Name(), scope.Opaque, digest.String(), location.Opaque)
+		if err != nil {
+			return false, fmt.Errorf("looking for known absence %q for (%q, %q, %q): %w",
+				location.Opaque, transport.Name(), scope.Opaque, digest.String(), err)
+		}
+		return found && time.Since(t) <= maxAge, nil
+	})
+	if err != nil {
+		return false // FIXME? Log err (but throttle the log volume on repeated accesses)?
+	}
+	return res
+}
+
+// PurgeScope removes all known locations and absences recorded within the specified (transport, scope) scope.
+func (sqc *cache) PurgeScope(transport types.ImageTransport, scope types.BICTransportScope) error {
+	_, err := transaction(sqc, func(tx *sql.Tx) (void, error) {
+		for _, table := range []string{"KnownLocations", "KnownAbsences"} {
+			if _, err := tx.Exec("DELETE FROM "+table+" WHERE transport = ? AND scope = ?", transport.Name(), scope.Opaque); err != nil {
+				return void{}, fmt.Errorf("purging %s for (%q, %q): %w", table, transport.Name(), scope.Opaque, err)
+			}
+		}
+		return void{}, nil
+	})
+	return err
+}
+
 // RecordDigestCompressorData records data for the blob with the specified digest.
 // WARNING: Only call this with LOCALLY VERIFIED data:
 //   - don’t record a compressor for a digest just because some remote author claims so
@@ -537,6 +600,51 @@ func (sqc *cache) RecordDigestCompressorData(anyDigest digest.Digest, data blobi
 	}) // FIXME? Log error (but throttle the log volume on repeated accesses)?
 }
 
+// DigestCompressorData returns data recorded by RecordDigestCompressorData for the blob with the specified digest.
+// If nothing is known, BaseVariantCompressor and SpecificVariantCompressor are blobinfocache.UnknownCompression.
+func (sqc *cache) DigestCompressorData(anyDigest digest.Digest) blobinfocache.DigestCompressorData {
+	res, err := transaction(sqc, func(tx *sql.Tx) (blobinfocache.DigestCompressorData, error) {
+		return digestCompressorData(tx, anyDigest)
+	})
+	if err != nil {
+		// FIXME? Log err (but throttle the log volume on repeated accesses)?
+		return blobinfocache.DigestCompressorData{
+			BaseVariantCompressor:      blobinfocache.UnknownCompression,
+			SpecificVariantCompressor:  blobinfocache.UnknownCompression,
+			SpecificVariantAnnotations: nil,
+		}
+	}
+	return res
+}
+
+// digestCompressorData implements DigestCompressorData within tx.
+func digestCompressorData(tx *sql.Tx, anyDigest digest.Digest) (blobinfocache.DigestCompressorData, error) {
+	res := blobinfocache.DigestCompressorData{
+		BaseVariantCompressor:      blobinfocache.UnknownCompression,
+		SpecificVariantCompressor:  blobinfocache.UnknownCompression,
+		SpecificVariantAnnotations: nil,
+	}
+	var baseVariantCompressor string
+	var specificVariantCompressor sql.NullString
+	var annotationBytes []byte
+	switch err := tx.QueryRow("SELECT compressor, specificVariantCompressor, specificVariantAnnotations "+
+		"FROM DigestCompressors LEFT JOIN DigestSpecificVariantCompressors USING (digest) WHERE digest = ?", anyDigest.String()).
+		Scan(&baseVariantCompressor, &specificVariantCompressor, &annotationBytes); {
+	case errors.Is(err, sql.ErrNoRows): // Do nothing
+	case err != nil:
+		return blobinfocache.DigestCompressorData{}, fmt.Errorf("scanning compressor data: %w", err)
+	default:
+		res.BaseVariantCompressor = baseVariantCompressor
+		if specificVariantCompressor.Valid && annotationBytes != nil {
+			res.SpecificVariantCompressor = specificVariantCompressor.String
+			if err := json.Unmarshal(annotationBytes, &res.SpecificVariantAnnotations); err != nil {
+				return blobinfocache.DigestCompressorData{}, err
+			}
+		}
+	}
+	return res, nil
+}
+
 // appendReplacementCandidates creates prioritize.CandidateWithTime values for (transport, scope, digest),
 // and returns the result of appending them to candidates.
 // v2Options is not nil if the caller is CandidateLocations2: this allows including candidates with unknown location, and filters out candidates
@@ -549,23 +657,10 @@ func (sqc *cache) appendReplacementCandidates(candidates []prioritize.CandidateW
 		SpecificVariantAnnotations: nil,
 	}
 	if v2Options != nil {
-		var baseVariantCompressor string
-		var specificVariantCompressor sql.NullString
-		var annotationBytes []byte
-		switch err := tx.QueryRow("SELECT compressor, specificVariantCompressor, specificVariantAnnotations "+
-			"FROM DigestCompressors LEFT JOIN DigestSpecificVariantCompressors USING (digest) WHERE digest = ?", digest.String()).
-			Scan(&baseVariantCompressor, &specificVariantCompressor, &annotationBytes); {
-		case errors.Is(err, sql.ErrNoRows): // Do nothing
-		case err != nil:
-			return nil, fmt.Errorf("scanning compressor data: %w", err)
-		default:
-			compressionData.BaseVariantCompressor = baseVariantCompressor
-			if specificVariantCompressor.Valid && annotationBytes != nil {
-				compressionData.SpecificVariantCompressor = specificVariantCompressor.String
-				if err := json.Unmarshal(annotationBytes, &compressionData.SpecificVariantAnnotations); err != nil {
-					return nil, err
-				}
-			}
+		var err error
+		compressionData, err = digestCompressorData(tx, digest)
+		if err != nil {
+			return nil, err
 		}
 	}
 	template := prioritize.CandidateTemplateWithCompression(v2Options, digest, compressionData)
@@ -22,4 +22,12 @@ func TestNew(t *testing.T) {
 	test.GenericCache(t, newTestCache)
 }
 
+func TestBlobAbsence(t *testing.T) {
+	test.BlobAbsence(t, newTestCache)
+}
+
 // FIXME: Tests for the various corner cases / failure cases of sqlite.cache should be added here.
+
+func TestPurgeScope(t *testing.T) {
+	test.PurgeScope(t, newTestCache, true)
+}
@@ -0,0 +1,66 @@
+package compression
+
+import (
+	"maps"
+	"strings"
+
+	chunkedToc "github.com/containers/storage/pkg/chunked/toc"
+	digest "github.com/opencontainers/go-digest"
+)
+
+const (
+	// zstdChunkedAnnotationPrefix is the prefix of annotations describing the chunk metadata of a zstd:chunked layer.
+	zstdChunkedAnnotationPrefix = "io.github.containers.zstd-chunked."
+	// estargzTOCDigestAnnotation is the annotation recording the TOC digest of an eStargz layer.
+	// It is defined in github.com/containerd/stargz-snapshotter/estargz as TOCJSONDigestAnnotation.
+	estargzTOCDigestAnnotation = "containerd.io/snapshot/stargz/toc.digest"
+)
+
+// TOCDigest returns the digest of the table of contents of a zstd:chunked or eStargz layer, as recorded in the layer’s annotations.
+// It returns "" if the annotations don’t identify a table of contents.
+//
+// The returned value is only a claim by the annotations’ author; it is not verified against the layer.
+func TOCDigest(annotations map[string]string) (digest.Digest, error) {
+	d, err := chunkedToc.GetTOCDigest(annotations)
+	if err != nil {
+		return "", err
+	}
+	if d == nil {
+		return "", nil
+	}
+	return *d, nil
+}
+
+// IsChunkedAnnotation returns true if key is an annotation describing the chunk metadata of a zstd:chunked or eStargz layer.
+// Such annotations are only valid for the specific blob they were created with, and must be dropped if the blob is changed.
+func IsChunkedAnnotation(key string) bool {
+	return strings.HasPrefix(key, zstdChunkedAnnotationPrefix) || key == estargzTOCDigestAnnotation
+}
+
+// ChunkedAnnotations returns the subset of annotations describing the chunk metadata of a zstd:chunked or eStargz layer,
+// or nil if there are none.
+func ChunkedAnnotations(annotations map[string]string) map[string]string {
+	var res map[string]string
+	for k, v := range annotations {
+		if IsChunkedAnnotation(k) {
+			if res == nil {
+				res = map[string]string{}
+			}
+			res[k] = v
+		}
+	}
+	return res
+}
+
+// WithoutChunkedAnnotations returns a copy of annotations without any annotations describing chunk metadata
+// of a zstd:chunked or eStargz layer.
+func WithoutChunkedAnnotations(annotations map[string]string) map[string]string {
+	if annotations == nil {
+		return nil
+	}
+	res := maps.Clone(annotations)
+	maps.DeleteFunc(res, func(k, _ string) bool {
+		return IsChunkedAnnotation(k)
+	})
+	return res
+}
@@ -0,0 +1,76 @@
+package compression
+
+import (
+	"testing"
+
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testTOCDigest = digest.Digest("sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")
+
+func TestTOCDigest(t *testing.T) {
+	for _, c := range []struct {
+		annotations map[string]string
+		expected    digest.Digest
+	}{
+		{nil, ""},
+		{map[string]string{"unrelated": "value"}, ""},
+		{map[string]string{"io.github.containers.zstd-chunked.manifest-checksum": testTOCDigest.String()}, testTOCDigest},
+		{map[string]string{"containerd.io/snapshot/stargz/toc.digest": testTOCDigest.String()}, testTOCDigest},
+	} {
+		res, err := TOCDigest(c.annotations)
+		require.NoError(t, err, "%#v", c.annotations)
+		assert.Equal(t, c.expected, res, "%#v", c.annotations)
+	}
+
+	for _, annotations := range []map[string]string{
+		{"io.github.containers.zstd-chunked.manifest-checksum": "invalid"},
+		{
+			"io.github.containers.zstd-chunked.manifest-checksum": testTOCDigest.String(),
+			"containerd.io/snapshot/stargz/toc.digest":            testTOCDigest.String(),
+		},
+	} {
+		_, err := TOCDigest(annotations)
+		assert.Error(t, err, "%#v", annotations)
+	}
+}
+
+func TestIsChunkedAnnotation(t *testing.T) {
+	for _, c := range []struct {
+		key      string
+		expected bool
+	}{
+		{"io.github.containers.zstd-chunked.manifest-checksum", true},
+		{"io.github.containers.zstd-chunked.manifest-position", true},
+		{"io.github.containers.zstd-chunked.tarsplit-position", true},
+		{"containerd.io/snapshot/stargz/toc.digest", true},
+		{"org.opencontainers.image.title", false},
+		{"io.github.containers.zstd-chunked", false},
+		{"", false},
+	} {
+		assert.Equal(t, c.expected, IsChunkedAnnotation(c.key), c.key)
+	}
+}
+
+func TestChunkedAnnotations(t *testing.T) {
+	chunked := map[string]string{
+		"io.github.containers.zstd-chunked.manifest-checksum": testTOCDigest.String(),
+		"io.github.containers.zstd-chunked.manifest-position": "1:2:3:4",
+	}
+	annotations := map[string]string{"org.opencontainers.image.title": "layer"}
+	for k, v := range chunked {
+		annotations[k] = v
+	}
+
+	assert.Equal(t, chunked, ChunkedAnnotations(annotations))
+	assert.Nil(t, ChunkedAnnotations(map[string]string{"org.opencontainers.image.title": "layer"}))
+	assert.Nil(t, ChunkedAnnotations(nil))
+
+	res := WithoutChunkedAnnotations(annotations)
+	assert.Equal(t, map[string]string{"org.opencontainers.image.title": "layer"}, res)
+	assert.Len(t, annotations, 3) // The input is not modified
+	assert.Equal(t, map[string]string{}, WithoutChunkedAnnotations(chunked))
+	assert.Nil(t, WithoutChunkedAnnotations(nil))
+}
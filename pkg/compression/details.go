@@ -0,0 +1,218 @@
+package compression
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/containers/image/v5/pkg/compression/internal"
+	"github.com/opencontainers/go-digest"
+)
+
+// DetectedCompression describes a blob, as returned by DetectCompressionDetails.
+type DetectedCompression struct {
+	// Algorithm is the detected compression algorithm; valid only if Decompressor != nil.
+	// A blob with a zstd:chunked footer is reported as ZstdChunked.
+	Algorithm Algorithm
+	// Decompressor can be used to decompress the blob, or nil if the blob is not compressed in a recognized format.
+	Decompressor DecompressorFunc
+	// UncompressedSize is the size of the uncompressed data, or -1 if it can not be determined without decompressing the blob.
+	// Currently, it is determined for uncompressed blobs, and for zstd blobs where every frame records its content size.
+	UncompressedSize int64
+	// TOCDigest is the digest of the table of contents of a zstd:chunked blob, or "" if the blob has none.
+	// This is the value which is usually carried in the layer annotations, see
+	// github.com/containers/storage/pkg/chunked/toc.GetTOCDigest.
+	TOCDigest digest.Digest
+}
+
+// DetectCompressionDetails is a variant of DetectCompressionFormat which works on a blob of the specified size
+// that allows random access, and in addition to the compression algorithm it reports data which can be determined
+// without decompressing the blob.
+// Errors reading the blob are reported; malformed compression metadata only causes the relevant data to be reported as unknown.
+func DetectCompressionDetails(blob io.ReaderAt, size int64) (DetectedCompression, error) {
+	algo, decompressor, _, err := DetectCompressionFormat(io.NewSectionReader(blob, 0, size))
+	if err != nil {
+		return DetectedCompression{}, err
+	}
+	res := DetectedCompression{
+		Algorithm:        algo,
+		Decompressor:     decompressor,
+		UncompressedSize: -1,
+	}
+	switch {
+	case decompressor == nil:
+		res.UncompressedSize = size
+	case algo.Name() == Zstd.Name():
+		tocDigest, err := zstdChunkedTOCDigest(blob, size)
+		if err != nil {
+			return DetectedCompression{}, err
+		}
+		if tocDigest != "" {
+			res.Algorithm = ZstdChunked
+			res.Decompressor = internal.AlgorithmDecompressor(ZstdChunked)
+			res.TOCDigest = tocDigest
+		}
+		uncompressedSize, err := zstdContentSize(blob, size)
+		if err != nil {
+			return DetectedCompression{}, err
+		}
+		res.UncompressedSize = uncompressedSize
+	}
+	return res, nil
+}
+
+const (
+	// zstdFrameMagic is the little-endian magic number of a zstd frame.
+	zstdFrameMagic = 0xFD2FB528
+	// zstdSkippableFrameMagicMask and zstdSkippableFrameMagic identify little-endian magic numbers of skippable zstd frames.
+	zstdSkippableFrameMagicMask = 0xFFFFFFF0
+	zstdSkippableFrameMagic     = 0x184D2A50
+	// zstdChunkedFooterSize is the size of the zstd:chunked footer, stored in the last skippable frame of the blob.
+	// This must match the format used by github.com/containers/storage/pkg/chunked.
+	zstdChunkedFooterSize = 64
+)
+
+// zstdChunkedFooterMagic is the value at the end of a zstd:chunked footer.
+var zstdChunkedFooterMagic = []byte{0x47, 0x4e, 0x55, 0x6c, 0x49, 0x6e, 0x55, 0x78}
+
+// zstdChunkedTOCDigest returns the digest of the TOC of a zstd:chunked blob, or "" if blob does not contain a zstd:chunked footer.
+func zstdChunkedTOCDigest(blob io.ReaderAt, size int64) (digest.Digest, error) {
+	const frameSize = 8 + zstdChunkedFooterSize // Skippable frame header + footer
+	if size < frameSize {
+		return "", nil
+	}
+	frame := make([]byte, frameSize)
+	if _, err := blob.ReadAt(frame, size-frameSize); err != nil {
+		return "", fmt.Errorf("reading zstd:chunked footer: %w", err)
+	}
+	if binary.LittleEndian.Uint32(frame[0:4])&zstdSkippableFrameMagicMask != zstdSkippableFrameMagic ||
+		binary.LittleEndian.Uint32(frame[4:8]) != zstdChunkedFooterSize {
+		return "", nil
+	}
+	footer := frame[8:]
+	if !bytes.Equal(footer[zstdChunkedFooterSize-len(zstdChunkedFooterMagic):], zstdChunkedFooterMagic) {
+		return "", nil
+	}
+	tocOffset := binary.LittleEndian.Uint64(footer[0:8])
+	tocLength := binary.LittleEndian.Uint64(footer[8:16])
+	if tocOffset > uint64(size) || tocLength > uint64(size)-tocOffset {
+		return "", nil // The footer is not consistent with the blob; don’t claim a TOC.
+	}
+	digester := digest.Canonical.Digester()
+	if _, err := io.Copy(digester.Hash(), io.NewSectionReader(blob, int64(tocOffset), int64(tocLength))); err != nil {
+		return "", fmt.Errorf("reading zstd:chunked TOC: %w", err)
+	}
+	return digester.Digest(), nil
+}
+
+// errZstdUnknownSize is used internally by zstdContentSize to report that the content size can not be determined.
+var errZstdUnknownSize = errors.New("zstd content size is unknown")
+
+// zstdContentSize returns the total content size of the zstd frames in blob, or -1 if it is not recorded in all frames.
+// It only reads the frame and block headers.
+func zstdContentSize(blob io.ReaderAt, size int64) (int64, error) {
+	total := int64(0)
+	for offset := int64(0); offset < size; {
+		frameSize, contentSize, err := zstdFrameSizes(blob, size, offset)
+		if err != nil {
+			if errors.Is(err, errZstdUnknownSize) {
+				return -1, nil
+			}
+			return -1, err
+		}
+		offset += frameSize
+		total += contentSize
+	}
+	return total, nil
+}
+
+// zstdFrameSizes returns the size of a zstd frame at offset in blob, and the size of its content.
+// It returns errZstdUnknownSize if the content size can not be determined without decompressing the frame.
+func zstdFrameSizes(blob io.ReaderAt, size, offset int64) (int64, int64, error) {
+	readAt := func(buf []byte, at int64) error {
+		if at < 0 || at > size || int64(len(buf)) > size-at {
+			return errZstdUnknownSize // Truncated or malformed
+		}
+		if _, err := blob.ReadAt(buf, at); err != nil {
+			return fmt.Errorf("reading zstd frame header: %w", err)
+		}
+		return nil
+	}
+
+	header := make([]byte, 4+1+1+4+8) // Magic, Frame_Header_Descriptor, Window_Descriptor, Dictionary_ID, Frame_Content_Size
+	if err := readAt(header[:8], offset); err != nil {
+		return -1, -1, err
+	}
+	magic := binary.LittleEndian.Uint32(header[0:4])
+	if magic&zstdSkippableFrameMagicMask == zstdSkippableFrameMagic {
+		return 8 + int64(binary.LittleEndian.Uint32(header[4:8])), 0, nil
+	}
+	if magic != zstdFrameMagic {
+		return -1, -1, errZstdUnknownSize
+	}
+
+	descriptor := header[4]
+	singleSegment := descriptor&0x20 != 0
+	hasChecksum := descriptor&0x04 != 0
+	pos := 5
+	if !singleSegment {
+		pos++ // Window_Descriptor
+	}
+	pos += []int{0, 1, 2, 4}[descriptor&0x03] // Dictionary_ID
+	fcsSize := []int{0, 2, 4, 8}[descriptor>>6]
+	if fcsSize == 0 && singleSegment {
+		fcsSize = 1
+	}
+	if fcsSize == 0 {
+		return -1, -1, errZstdUnknownSize
+	}
+	if err := readAt(header[:pos+fcsSize], offset); err != nil {
+		return -1, -1, err
+	}
+	fcs := header[pos : pos+fcsSize]
+	var contentSize uint64
+	switch fcsSize {
+	case 1:
+		contentSize = uint64(fcs[0])
+	case 2:
+		contentSize = uint64(binary.LittleEndian.Uint16(fcs)) + 256
+	case 4:
+		contentSize = uint64(binary.LittleEndian.Uint32(fcs))
+	case 8:
+		contentSize = binary.LittleEndian.Uint64(fcs)
+	}
+	if contentSize > uint64(1<<62) {
+		return -1, -1, errZstdUnknownSize
+	}
+
+	blockOffset := offset + int64(pos+fcsSize)
+	blockHeader := make([]byte, 3)
+	for {
+		if err := readAt(blockHeader, blockOffset); err != nil {
+			return -1, -1, err
+		}
+		v := uint32(blockHeader[0]) | uint32(blockHeader[1])<<8 | uint32(blockHeader[2])<<16
+		lastBlock := v&1 != 0
+		blockSize := int64(v >> 3)
+		switch (v >> 1) & 0x03 { // Block_Type
+		case 0, 2: // Raw_Block, Compressed_Block
+		case 1: // RLE_Block
+			blockSize = 1
+		default: // Reserved
+			return -1, -1, errZstdUnknownSize
+		}
+		blockOffset += 3 + blockSize
+		if lastBlock {
+			break
+		}
+	}
+	if hasChecksum {
+		blockOffset += 4
+	}
+	if blockOffset > size {
+		return -1, -1, errZstdUnknownSize
+	}
+	return blockOffset - offset, int64(contentSize), nil
+}
@@ -0,0 +1,84 @@
+package compression
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/containers/storage/pkg/chunked/toc"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectCompressionDetails(t *testing.T) {
+	for _, c := range []struct {
+		filename         string
+		algo             Algorithm
+		uncompressedSize int64
+	}{
+		{"fixtures/Hello.uncompressed", Algorithm{}, 5},
+		{"fixtures/Hello.gz", Gzip, -1},
+		{"fixtures/Hello.bz2", Bzip2, -1},
+		{"fixtures/Hello.xz", Xz, -1},
+		{"fixtures/Hello.zst", Zstd, -1}, // The frame does not record the content size
+	} {
+		contents, err := os.ReadFile(c.filename)
+		require.NoError(t, err, c.filename)
+		res, err := DetectCompressionDetails(bytes.NewReader(contents), int64(len(contents)))
+		require.NoError(t, err, c.filename)
+		if c.algo.Name() == "" {
+			assert.Nil(t, res.Decompressor, c.filename)
+		} else {
+			assert.NotNil(t, res.Decompressor, c.filename)
+			assert.Equal(t, c.algo.Name(), res.Algorithm.Name(), c.filename)
+		}
+		assert.Equal(t, c.uncompressedSize, res.UncompressedSize, c.filename)
+		assert.Equal(t, "", res.TOCDigest.String(), c.filename)
+	}
+
+	// zstd frames which record the content size, including a skippable frame
+	encoder, err := zstd.NewWriter(nil)
+	require.NoError(t, err)
+	defer encoder.Close()
+	var blob []byte
+	blob = encoder.EncodeAll(bytes.Repeat([]byte("a"), 100000), blob)
+	blob = append(blob, 0x5a, 0x2a, 0x4d, 0x18, 3, 0, 0, 0, 1, 2, 3)
+	blob = encoder.EncodeAll(bytes.Repeat([]byte("b"), 200000), blob)
+	res, err := DetectCompressionDetails(bytes.NewReader(blob), int64(len(blob)))
+	require.NoError(t, err)
+	assert.Equal(t, Zstd.Name(), res.Algorithm.Name())
+	assert.Equal(t, int64(100000+200000), res.UncompressedSize)
+	assert.Equal(t, "", res.TOCDigest.String())
+	// Truncated data
+	res, err = DetectCompressionDetails(bytes.NewReader(blob), int64(len(blob)-1))
+	require.NoError(t, err)
+	assert.Equal(t, int64(-1), res.UncompressedSize)
+
+	// zstd:chunked
+	var tarBuf bytes.Buffer
+	tw := tar.NewWriter(&tarBuf)
+	err = tw.WriteHeader(&tar.Header{Name: "file", Typeflag: tar.TypeReg, Mode: 0o644, Size: 5})
+	require.NoError(t, err)
+	_, err = tw.Write([]byte("Hello"))
+	require.NoError(t, err)
+	err = tw.Close()
+	require.NoError(t, err)
+	var chunked bytes.Buffer
+	annotations := map[string]string{}
+	w, err := CompressStreamWithMetadata(&chunked, annotations, ZstdChunked, nil)
+	require.NoError(t, err)
+	_, err = w.Write(tarBuf.Bytes())
+	require.NoError(t, err)
+	err = w.Close()
+	require.NoError(t, err)
+	expectedTOCDigest, err := toc.GetTOCDigest(annotations)
+	require.NoError(t, err)
+	require.NotNil(t, expectedTOCDigest)
+	res, err = DetectCompressionDetails(bytes.NewReader(chunked.Bytes()), int64(chunked.Len()))
+	require.NoError(t, err)
+	assert.Equal(t, ZstdChunked.Name(), res.Algorithm.Name())
+	assert.NotNil(t, res.Decompressor)
+	assert.Equal(t, *expectedTOCDigest, res.TOCDigest)
+}
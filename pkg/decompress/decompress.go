@@ -0,0 +1,58 @@
+// Package decompress provides an ImageReference wrapper whose image sources serve layers decompressed,
+// for destinations which need uncompressed layers, regardless of how the layers are stored by the source.
+package decompress
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/containers/image/v5/internal/image"
+	"github.com/containers/image/v5/internal/imagesource"
+	"github.com/containers/image/v5/pkg/blobinfocache"
+	"github.com/containers/image/v5/transports"
+	"github.com/containers/image/v5/types"
+)
+
+// decompressReference wraps a types.ImageReference, so that its image sources serve layers decompressed.
+// All other operations, including NewImageDestination, use the wrapped reference.
+type decompressReference struct {
+	types.ImageReference
+	cache types.BlobInfoCache
+}
+
+// NewReference returns a types.ImageReference which refers to the same image as ref, but whose image sources
+// serve layers decompressed: LayerInfosForCopy reports the digests and media types of the uncompressed layers,
+// and GetBlob decompresses the original layers on the fly, so that copy.Image stores the uncompressed layers
+// (unless the destination compresses them again) and updates the manifest to match.
+//
+// The uncompressed digests are looked up in cache (if nil, the default cache for the SystemContext used to
+// create the source), or in the image config; if neither knows the digest, the layer is read to compute it.
+//
+// Signed images, and layers whose compression can not be changed (e.g. of non-image artifacts, or encrypted layers),
+// are served unmodified.
+func NewReference(ref types.ImageReference, cache types.BlobInfoCache) types.ImageReference {
+	return decompressReference{ImageReference: ref, cache: cache}
+}
+
+// NewImage returns a types.ImageCloser for this reference, possibly specialized for this ImageTransport.
+// The caller must call .Close() on the returned ImageCloser.
+// NOTE: If any kind of signature verification should happen, build an UnparsedImage from the value returned by NewImageSource,
+// verify that UnparsedImage, and convert it into a real Image via image.FromUnparsedImage.
+// WARNING: This may not do the right thing for a manifest list, see image.FromSource for details.
+func (ref decompressReference) NewImage(ctx context.Context, sys *types.SystemContext) (types.ImageCloser, error) {
+	return image.FromReference(ctx, sys, ref)
+}
+
+// NewImageSource returns a types.ImageSource for this reference.
+// The caller must call .Close() on the returned ImageSource.
+func (ref decompressReference) NewImageSource(ctx context.Context, sys *types.SystemContext) (types.ImageSource, error) {
+	src, err := ref.ImageReference.NewImageSource(ctx, sys)
+	if err != nil {
+		return nil, fmt.Errorf("initializing source %s: %w", transports.ImageName(ref.ImageReference), err)
+	}
+	cache := ref.cache
+	if cache == nil {
+		cache = blobinfocache.DefaultCache(sys)
+	}
+	return newImageSource(ref, imagesource.FromPublic(src), sys, cache), nil
+}
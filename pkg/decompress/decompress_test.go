@@ -0,0 +1,105 @@
+package decompress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containers/image/v5/copy"
+	"github.com/containers/image/v5/directory"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/pkg/blobinfocache/memory"
+	"github.com/containers/image/v5/signature"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createDirImage creates a dir: image with a single gzip-compressed layer containing uncompressed,
+// and returns its reference. If withDiffIDs, the config includes the DiffID of the layer.
+func createDirImage(t *testing.T, uncompressed []byte, withDiffIDs bool) types.ImageReference {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write(uncompressed)
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	compressed := buf.Bytes()
+
+	diffIDs := ""
+	if withDiffIDs {
+		diffIDs = `"` + digest.FromBytes(uncompressed).String() + `"`
+	}
+	config := []byte(`{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":[` + diffIDs + `]}}`)
+	m := manifest.OCI1FromComponents(imgspecv1.Descriptor{
+		MediaType: imgspecv1.MediaTypeImageConfig,
+		Digest:    digest.FromBytes(config),
+		Size:      int64(len(config)),
+	}, []imgspecv1.Descriptor{{
+		MediaType: imgspecv1.MediaTypeImageLayerGzip,
+		Digest:    digest.FromBytes(compressed),
+		Size:      int64(len(compressed)),
+	}})
+	manifestBlob, err := m.Serialize()
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	for _, blob := range [][]byte{config, compressed} {
+		err := os.WriteFile(filepath.Join(dir, digest.FromBytes(blob).Encoded()), blob, 0o644)
+		require.NoError(t, err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "manifest.json"), manifestBlob, 0o644)
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(dir, "version"), []byte("Directory Transport Version: 1.1\n"), 0o644)
+	require.NoError(t, err)
+	ref, err := directory.NewReference(dir)
+	require.NoError(t, err)
+	return ref
+}
+
+func TestDecompressingImageSource(t *testing.T) {
+	policy, err := signature.NewPolicyContext(&signature.Policy{
+		Default: signature.PolicyRequirements{signature.NewPRInsecureAcceptAnything()},
+	})
+	require.NoError(t, err)
+	defer func() {
+		err := policy.Destroy()
+		require.NoError(t, err)
+	}()
+	uncompressed := bytes.Repeat([]byte("layer data"), 1000)
+	uncompressedDigest := digest.FromBytes(uncompressed)
+
+	for _, withDiffIDs := range []bool{true, false} {
+		srcRef := createDirImage(t, uncompressed, withDiffIDs)
+		cache := memory.New()
+		ref := NewReference(srcRef, cache)
+		assert.Equal(t, srcRef.StringWithinTransport(), ref.StringWithinTransport())
+
+		destDir := t.TempDir()
+		destRef, err := directory.NewReference(destDir)
+		require.NoError(t, err)
+		copiedManifest, err := copy.Image(context.Background(), policy, destRef, ref, &copy.Options{})
+		require.NoError(t, err, withDiffIDs)
+
+		m, err := manifest.OCI1FromManifest(copiedManifest)
+		require.NoError(t, err)
+		require.Len(t, m.Layers, 1)
+		assert.Equal(t, imgspecv1.MediaTypeImageLayer, m.Layers[0].MediaType)
+		assert.Equal(t, uncompressedDigest, m.Layers[0].Digest)
+		assert.Equal(t, int64(len(uncompressed)), m.Layers[0].Size)
+		layer, err := os.ReadFile(filepath.Join(destDir, uncompressedDigest.Encoded()))
+		require.NoError(t, err)
+		assert.Equal(t, uncompressed, layer)
+		if !withDiffIDs { // The computed digest is recorded in the cache
+			origManifestBlob, err := os.ReadFile(filepath.Join(srcRef.StringWithinTransport(), "manifest.json"))
+			require.NoError(t, err)
+			orig, err := manifest.OCI1FromManifest(origManifestBlob)
+			require.NoError(t, err)
+			assert.Equal(t, uncompressedDigest, cache.UncompressedDigest(orig.Layers[0].Digest))
+		}
+	}
+}
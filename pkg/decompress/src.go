@@ -0,0 +1,218 @@
+package decompress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/containers/image/v5/internal/image"
+	"github.com/containers/image/v5/internal/imagesource/impl"
+	"github.com/containers/image/v5/internal/imagesource/stubs"
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/internal/signature"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/pkg/compression"
+	"github.com/containers/image/v5/transports"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sirupsen/logrus"
+)
+
+// uncompressedMIMETypes maps MIME types of compressed layers to MIME types of the same layers, uncompressed.
+var uncompressedMIMETypes = map[string]string{
+	imgspecv1.MediaTypeImageLayerGzip:                 imgspecv1.MediaTypeImageLayer,
+	imgspecv1.MediaTypeImageLayerZstd:                 imgspecv1.MediaTypeImageLayer,
+	imgspecv1.MediaTypeImageLayerNonDistributableGzip: imgspecv1.MediaTypeImageLayerNonDistributable, //nolint:staticcheck // NonDistributable layers are deprecated, but we want to continue to support manipulating pre-existing images.
+	imgspecv1.MediaTypeImageLayerNonDistributableZstd: imgspecv1.MediaTypeImageLayerNonDistributable, //nolint:staticcheck // NonDistributable layers are deprecated, but we want to continue to support manipulating pre-existing images.
+	manifest.DockerV2Schema2LayerMediaType:            manifest.DockerV2SchemaLayerMediaTypeUncompressed,
+}
+
+// decompressingImageSource wraps a private.ImageSource, serving its layers decompressed.
+type decompressingImageSource struct {
+	impl.Compat
+	stubs.NoGetBlobAtInitialize
+
+	ref    decompressReference
+	source private.ImageSource
+	sys    *types.SystemContext
+	cache  types.BlobInfoCache
+
+	mu              sync.Mutex
+	compressedBlobs map[digest.Digest]types.BlobInfo // Uncompressed digests reported by LayerInfosForCopy → the original compressed blobs
+}
+
+// newImageSource returns a decompressingImageSource for source, which was created from ref.
+func newImageSource(ref decompressReference, source private.ImageSource, sys *types.SystemContext, cache types.BlobInfoCache) *decompressingImageSource {
+	s := &decompressingImageSource{
+		NoGetBlobAtInitialize: stubs.NoGetBlobAt(ref),
+
+		ref:             ref,
+		source:          source,
+		sys:             sys,
+		cache:           cache,
+		compressedBlobs: map[digest.Digest]types.BlobInfo{},
+	}
+	s.Compat = impl.AddCompat(s)
+	return s
+}
+
+// Reference returns the reference used to set up this source, _as specified by the user_
+// (not as the image itself, or its underlying storage, claims).  This can be used e.g. to determine which public keys are trusted for this image.
+func (s *decompressingImageSource) Reference() types.ImageReference {
+	return s.ref
+}
+
+// Close removes resources associated with an initialized ImageSource, if any.
+func (s *decompressingImageSource) Close() error {
+	return s.source.Close()
+}
+
+// GetManifest returns the image's manifest along with its MIME type (which may be empty when it can't be determined but the manifest is available).
+// It may use a remote (= slow) service.
+// If instanceDigest is not nil, it contains a digest of the specific manifest instance to retrieve (when the primary manifest is a manifest list);
+// this never happens if the primary manifest is not a manifest list (e.g. if the source never returns manifest lists).
+func (s *decompressingImageSource) GetManifest(ctx context.Context, instanceDigest *digest.Digest) ([]byte, string, error) {
+	return s.source.GetManifest(ctx, instanceDigest)
+}
+
+// HasThreadSafeGetBlob indicates whether GetBlob can be executed concurrently.
+func (s *decompressingImageSource) HasThreadSafeGetBlob() bool {
+	return s.source.HasThreadSafeGetBlob()
+}
+
+// GetBlob returns a stream for the specified blob, and the blob’s size (or -1 if unknown).
+// The Digest field in BlobInfo is guaranteed to be provided, Size may be -1 and MediaType may be optionally provided.
+// May update BlobInfoCache, preferably after it knows for certain that a blob truly exists at a specific location.
+func (s *decompressingImageSource) GetBlob(ctx context.Context, info types.BlobInfo, cache types.BlobInfoCache) (io.ReadCloser, int64, error) {
+	s.mu.Lock()
+	compressedInfo, ok := s.compressedBlobs[info.Digest]
+	s.mu.Unlock()
+	if !ok {
+		return s.source.GetBlob(ctx, info, cache)
+	}
+
+	stream, _, err := s.source.GetBlob(ctx, compressedInfo, cache)
+	if err != nil {
+		return nil, -1, err
+	}
+	decompressed, _, err := compression.AutoDecompress(stream)
+	if err != nil {
+		stream.Close()
+		return nil, -1, fmt.Errorf("decompressing blob %s: %w", compressedInfo.Digest, err)
+	}
+	return &decompressedBlob{ReadCloser: decompressed, compressed: stream}, -1, nil
+}
+
+// decompressedBlob is a decompressed stream of a compressed blob; Close closes both.
+type decompressedBlob struct {
+	io.ReadCloser               // The decompressed stream
+	compressed    io.ReadCloser // The original stream
+}
+
+func (b *decompressedBlob) Close() error {
+	return errors.Join(b.ReadCloser.Close(), b.compressed.Close())
+}
+
+// GetSignaturesWithFormat returns the image's signatures.  It may use a remote (= slow) service.
+// If instanceDigest is not nil, it contains a digest of the specific manifest instance to retrieve signatures for
+// (when the primary manifest is a manifest list); this never happens if the primary manifest is not a manifest list
+// (e.g. if the source never returns manifest lists).
+func (s *decompressingImageSource) GetSignaturesWithFormat(ctx context.Context, instanceDigest *digest.Digest) ([]signature.Signature, error) {
+	return s.source.GetSignaturesWithFormat(ctx, instanceDigest)
+}
+
+// LayerInfosForCopy returns either nil (meaning the values in the manifest are fine), or updated values for the layer
+// blobsums that are listed in the image's manifest.  If values are returned, they should be used when using GetBlob()
+// to read the image's layers.
+// If instanceDigest is not nil, it contains a digest of the specific manifest instance to retrieve BlobInfos for
+// (when the primary manifest is a manifest list); this never happens if the primary manifest is not a manifest list
+// (e.g. if the source never returns manifest lists).
+// The Digest field is guaranteed to be provided; Size may be -1.
+// WARNING: The list may contain duplicates, and they are semantically relevant.
+func (s *decompressingImageSource) LayerInfosForCopy(ctx context.Context, instanceDigest *digest.Digest) ([]types.BlobInfo, error) {
+	infos, err := s.source.LayerInfosForCopy(ctx, instanceDigest)
+	if err != nil {
+		return nil, err
+	}
+	signatures, err := s.source.GetSignaturesWithFormat(ctx, instanceDigest)
+	if err != nil {
+		return nil, fmt.Errorf("checking if image %s has signatures: %w", transports.ImageName(s.ref), err)
+	}
+	if len(signatures) != 0 {
+		logrus.Debugf("Not decompressing layers of signed image %s", transports.ImageName(s.ref))
+		return infos, nil
+	}
+
+	img, err := image.FromUnparsedImage(ctx, s.sys, image.UnparsedInstance(s.source, instanceDigest))
+	if err != nil {
+		return nil, fmt.Errorf("parsing image %s: %w", transports.ImageName(s.ref), err)
+	}
+	if infos == nil {
+		infos = img.LayerInfos()
+	}
+	var diffIDs []digest.Digest
+	if config, err := img.OCIConfig(ctx); err == nil && len(config.RootFS.DiffIDs) == len(infos) {
+		diffIDs = config.RootFS.DiffIDs
+	}
+
+	res := make([]types.BlobInfo, len(infos))
+	for i, info := range infos {
+		uncompressedMIMEType, ok := uncompressedMIMETypes[info.MediaType]
+		if !ok || !img.CanChangeLayerCompression(info.MediaType) {
+			res[i] = info
+			continue
+		}
+		var diffID digest.Digest
+		if diffIDs != nil {
+			diffID = diffIDs[i]
+		}
+		uncompressedDigest, err := s.uncompressedDigest(ctx, info, diffID)
+		if err != nil {
+			return nil, err
+		}
+		s.mu.Lock()
+		s.compressedBlobs[uncompressedDigest] = info
+		s.mu.Unlock()
+
+		res[i] = info
+		res[i].Digest = uncompressedDigest
+		res[i].Size = -1
+		res[i].MediaType = uncompressedMIMEType
+		res[i].CompressionOperation = types.Decompress
+		res[i].CompressionAlgorithm = nil
+		res[i].Annotations = compression.WithoutChunkedAnnotations(info.Annotations)
+	}
+	return res, nil
+}
+
+// uncompressedDigest returns the digest of the uncompressed version of the layer described by info.
+// diffID, if not "", is the value from the image config.
+func (s *decompressingImageSource) uncompressedDigest(ctx context.Context, info types.BlobInfo, diffID digest.Digest) (digest.Digest, error) {
+	if d := s.cache.UncompressedDigest(info.Digest); d != "" {
+		return d, nil
+	}
+	if diffID != "" {
+		return diffID, nil
+	}
+
+	logrus.Debugf("Uncompressed digest of layer %s is unknown, computing it", info.Digest)
+	stream, _, err := s.source.GetBlob(ctx, info, s.cache)
+	if err != nil {
+		return "", fmt.Errorf("reading layer %s: %w", info.Digest, err)
+	}
+	defer stream.Close()
+	decompressed, _, err := compression.AutoDecompress(stream)
+	if err != nil {
+		return "", fmt.Errorf("decompressing layer %s: %w", info.Digest, err)
+	}
+	defer decompressed.Close()
+	d, err := digest.Canonical.FromReader(decompressed)
+	if err != nil {
+		return "", fmt.Errorf("computing uncompressed digest of layer %s: %w", info.Digest, err)
+	}
+	s.cache.RecordDigestUncompressedPair(info.Digest, d)
+	return d, nil
+}
@@ -78,44 +78,68 @@ func GetAllCredentials(sys *types.SystemContext) (map[string]types.DockerAuthCon
 	// While we're at it, we’ll also canonicalize docker.io to the standard format.
 	normalizedDockerIORegistry := normalizeRegistry("docker.io")
 
-	helpers, err := sysregistriesv2.CredentialHelpers(sys)
-	if err != nil {
-		return nil, err
-	}
-	for _, helper := range helpers {
-		switch helper {
-		// Special-case the built-in helper for auth files.
-		case sysregistriesv2.AuthenticationFileHelper:
-			for _, path := range getAuthFilePaths(sys, homedir.Get()) {
-				// parse returns an empty map in case the path doesn't exist.
-				fileContents, err := path.parse()
-				if err != nil {
-					return nil, fmt.Errorf("reading JSON file %q: %w", path.path, err)
-				}
-				// Credential helpers in the auth file have a
-				// direct mapping to a registry, so we can just
-				// walk the map.
-				allKeys.AddSeq(maps.Keys(fileContents.CredHelpers))
-				for key := range fileContents.AuthConfigs {
-					key := normalizeAuthFileKey(key, path.legacyFormat)
-					if key == normalizedDockerIORegistry {
-						key = "docker.io"
+	addKeysFromHelpers := func(sys *types.SystemContext, helpers []string) error {
+		for _, helper := range helpers {
+			switch helper {
+			// Special-case the built-in helper for auth files.
+			case sysregistriesv2.AuthenticationFileHelper:
+				for _, path := range getAuthFilePaths(sys, homedir.Get()) {
+					// parse returns an empty map in case the path doesn't exist.
+					fileContents, err := path.parse()
+					if err != nil {
+						return fmt.Errorf("reading JSON file %q: %w", path.path, err)
+					}
+					// Credential helpers in the auth file have a
+					// direct mapping to a registry, so we can just
+					// walk the map.
+					allKeys.AddSeq(maps.Keys(fileContents.CredHelpers))
+					for key := range fileContents.AuthConfigs {
+						key := normalizeAuthFileKey(key, path.legacyFormat)
+						if key == normalizedDockerIORegistry {
+							key = "docker.io"
+						}
+						allKeys.Add(key)
 					}
-					allKeys.Add(key)
 				}
-			}
-		// External helpers.
-		default:
-			creds, err := listCredsInCredHelper(helper)
-			if err != nil {
-				logrus.Debugf("Error listing credentials stored in credential helper %s: %v", helper, err)
-				if errors.Is(err, exec.ErrNotFound) {
-					creds = nil // It's okay if the helper doesn't exist.
-				} else {
-					return nil, err
+			// External helpers.
+			default:
+				creds, err := listCredsInCredHelper(helper)
+				if err != nil {
+					logrus.Debugf("Error listing credentials stored in credential helper %s: %v", helper, err)
+					if errors.Is(err, exec.ErrNotFound) {
+						creds = nil // It's okay if the helper doesn't exist.
+					} else {
+						return err
+					}
 				}
+				allKeys.AddSeq(maps.Keys(creds))
 			}
-			allKeys.AddSeq(maps.Keys(creds))
+		}
+		return nil
+	}
+
+	helpers, err := sysregistriesv2.CredentialHelpers(sys)
+	if err != nil {
+		return nil, err
+	}
+	if err := addKeysFromHelpers(sys, helpers); err != nil {
+		return nil, err
+	}
+	// Registries may be configured to use their own credential helpers or auth files.
+	registries, err := sysregistriesv2.GetRegistries(sys)
+	if err != nil {
+		return nil, err
+	}
+	for _, reg := range registries {
+		if len(reg.CredentialHelpers) == 0 && reg.AuthFile == "" {
+			continue
+		}
+		regSys, regHelpers, err := credentialSourcesForKey(sys, reg.Prefix)
+		if err != nil {
+			return nil, err
+		}
+		if err := addKeysFromHelpers(regSys, regHelpers); err != nil {
+			return nil, err
 		}
 	}
 
@@ -210,6 +234,18 @@ func getCredentialsWithHomeDir(sys *types.SystemContext, key, homeDir string) (t
 		registry = key
 	}
 
+	if creds, found, err := getCredentialsFromStores(sys, key, registry); err != nil {
+		return types.DockerAuthConfig{}, err
+	} else if found {
+		logrus.Debugf("Returning credentials for %s from a registered credential store", key)
+		return creds, nil
+	}
+
+	sys, helpers, err := credentialSourcesForKey(sys, key)
+	if err != nil {
+		return types.DockerAuthConfig{}, err
+	}
+
 	// Anonymous function to query credentials from auth files.
 	getCredentialsFromAuthFiles := func() (types.DockerAuthConfig, string, error) {
 		for _, path := range getAuthFilePaths(sys, homeDir) {
@@ -225,11 +261,6 @@ func getCredentialsWithHomeDir(sys *types.SystemContext, key, homeDir string) (t
 		return types.DockerAuthConfig{}, "", nil
 	}
 
-	helpers, err := sysregistriesv2.CredentialHelpers(sys)
-	if err != nil {
-		return types.DockerAuthConfig{}, err
-	}
-
 	var multiErr []error
 	for _, helper := range helpers {
 		var (
@@ -319,7 +350,7 @@ func SetCredentials(sys *types.SystemContext, key, username, password string) (s
 		switch helper {
 		// Special-case the built-in helpers for auth files.
 		case sysregistriesv2.AuthenticationFileHelper:
-			desc, err = jsonEditor(sys, func(fileContents *dockerConfigFile) (bool, string, error) {
+			desc, err = jsonEditor(func(fileContents *dockerConfigFile) (bool, string, error) {
 				if ch, exists := fileContents.CredHelpers[key]; exists {
 					if isNamespaced {
 						return false, "", unsupportedNamespaceErr(ch)
@@ -401,7 +432,7 @@ func RemoveAuthentication(sys *types.SystemContext, key string) error {
 		switch helper {
 		// Special-case the built-in helper for auth files.
 		case sysregistriesv2.AuthenticationFileHelper:
-			_, err = jsonEditor(sys, func(fileContents *dockerConfigFile) (bool, string, error) {
+			_, err = jsonEditor(func(fileContents *dockerConfigFile) (bool, string, error) {
 				var helperErr error
 				if innerHelper, exists := fileContents.CredHelpers[key]; exists {
 					helperErr = removeFromCredHelper(innerHelper)
@@ -447,7 +478,7 @@ func RemoveAllAuthentication(sys *types.SystemContext) error {
 		switch helper {
 		// Special-case the built-in helper for auth files.
 		case sysregistriesv2.AuthenticationFileHelper:
-			_, err = jsonEditor(sys, func(fileContents *dockerConfigFile) (bool, string, error) {
+			_, err = jsonEditor(func(fileContents *dockerConfigFile) (bool, string, error) {
 				for registry, helper := range fileContents.CredHelpers {
 					// Helpers in auth files are expected
 					// to exist, so no special treatment
@@ -498,7 +529,7 @@ func RemoveAllAuthentication(sys *types.SystemContext) error {
 // - a function which can be used to edit the JSON file
 // - the key value to actually use in credential helpers / JSON
 // - a boolean which is true if key is namespaced (and should not be used with credential helpers).
-func prepareForEdit(sys *types.SystemContext, key string, keyRelevant bool) ([]string, func(*types.SystemContext, func(*dockerConfigFile) (bool, string, error)) (string, error), string, bool, error) {
+func prepareForEdit(sys *types.SystemContext, key string, keyRelevant bool) ([]string, func(func(*dockerConfigFile) (bool, string, error)) (string, error), string, bool, error) {
 	var isNamespaced bool
 	if keyRelevant {
 		ns, err := validateKey(key)
@@ -522,15 +553,55 @@ func prepareForEdit(sys *types.SystemContext, key string, keyRelevant bool) ([]s
 		}
 
 		// Do not use helpers defined in sysregistriesv2 because Docker isn’t aware of them.
-		return []string{sysregistriesv2.AuthenticationFileHelper}, modifyDockerConfigJSON, key, false, nil
+		return []string{sysregistriesv2.AuthenticationFileHelper}, func(editor func(*dockerConfigFile) (bool, string, error)) (string, error) {
+			return modifyDockerConfigJSON(sys, editor)
+		}, key, false, nil
 	}
 
-	helpers, err := sysregistriesv2.CredentialHelpers(sys)
+	var helpers []string
+	var err error
+	if keyRelevant {
+		sys, helpers, err = credentialSourcesForKey(sys, key)
+	} else {
+		helpers, err = sysregistriesv2.CredentialHelpers(sys)
+	}
 	if err != nil {
 		return nil, nil, "", false, err
 	}
 
-	return helpers, modifyJSON, key, isNamespaced, nil
+	return helpers, func(editor func(*dockerConfigFile) (bool, string, error)) (string, error) {
+		return modifyJSON(sys, editor)
+	}, key, isNamespaced, nil
+}
+
+// credentialSourcesForKey returns the credential helpers to use for key, and a SystemContext
+// to use for locating auth files, taking into account the per-registry credential-helpers and auth-file
+// options in registries.conf for the registry matching key.
+func credentialSourcesForKey(sys *types.SystemContext, key string) (*types.SystemContext, []string, error) {
+	helpers, err := sysregistriesv2.CredentialHelpers(sys)
+	if err != nil {
+		return nil, nil, err
+	}
+	reg, err := sysregistriesv2.FindRegistry(sys, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if reg == nil {
+		return sys, helpers, nil
+	}
+	if len(reg.CredentialHelpers) != 0 {
+		helpers = reg.CredentialHelpers
+	}
+	// An auth file explicitly chosen by the caller takes precedence over system-wide configuration.
+	if reg.AuthFile != "" && (sys == nil || (sys.AuthFilePath == "" && sys.DockerCompatAuthFilePath == "" && sys.LegacyFormatAuthFilePath == "")) {
+		sysCopy := types.SystemContext{}
+		if sys != nil {
+			sysCopy = *sys
+		}
+		sysCopy.AuthFilePath = reg.AuthFile
+		sys = &sysCopy
+	}
+	return sys, helpers, nil
 }
 
 func listCredsInCredHelper(credHelper string) (map[string]string, error) {
@@ -2,6 +2,7 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -1072,3 +1073,147 @@ func TestSetGetCredentials(t *testing.T) {
 		}
 	}
 }
+
+func TestPerRegistryCredentialSources(t *testing.T) {
+	tmpDir := t.TempDir()
+	registryAuthFile := filepath.Join(tmpDir, "registry-auth.json")
+	confPath := filepath.Join(tmpDir, "registries.conf")
+	err := os.WriteFile(confPath, []byte(fmt.Sprintf(`
+[[registry]]
+location = "registry.example.com"
+auth-file = %q
+
+[[registry]]
+location = "registry-a.com"
+credential-helpers = [ "helper-registry" ]
+`, registryAuthFile)), 0o600)
+	require.NoError(t, err)
+	runtimeDir := filepath.Join(tmpDir, "runtime")
+	err = os.MkdirAll(filepath.Join(runtimeDir, "containers"), 0o700)
+	require.NoError(t, err)
+	t.Setenv("XDG_RUNTIME_DIR", runtimeDir)
+	defaultAuthFile := filepath.Join(runtimeDir, "containers", "auth.json")
+	path, err := os.Getwd()
+	require.NoError(t, err)
+	t.Setenv("PATH", fmt.Sprintf("%s:%s", filepath.Join(path, "testdata"), os.Getenv("PATH")))
+	err = os.Chmod(filepath.Join(path, "testdata", "docker-credential-helper-registry"), os.ModePerm)
+	require.NoError(t, err)
+	sys := &types.SystemContext{
+		SystemRegistriesConfPath:    confPath,
+		SystemRegistriesConfDirPath: filepath.Join(tmpDir, "IdoNotExist"),
+	}
+
+	// Credentials for registry.example.com are stored only in its own auth file.
+	_, err = SetCredentials(sys, "registry.example.com", "user", "password")
+	require.NoError(t, err)
+	_, err = SetCredentials(sys, "other.example.com", "other-user", "other-password")
+	require.NoError(t, err)
+	auth, err := newAuthPathDefault(registryAuthFile).parse()
+	require.NoError(t, err)
+	assert.Contains(t, auth.AuthConfigs, "registry.example.com")
+	assert.NotContains(t, auth.AuthConfigs, "other.example.com")
+	auth, err = newAuthPathDefault(defaultAuthFile).parse()
+	require.NoError(t, err)
+	assert.NotContains(t, auth.AuthConfigs, "registry.example.com")
+	assert.Contains(t, auth.AuthConfigs, "other.example.com")
+
+	creds, err := GetCredentials(sys, "registry.example.com/ns/repo")
+	require.NoError(t, err)
+	assert.Equal(t, types.DockerAuthConfig{Username: "user", Password: "password"}, creds)
+
+	// The per-registry credential helper is used instead of the global ones.
+	creds, err = GetCredentials(sys, "registry-a.com")
+	require.NoError(t, err)
+	assert.Equal(t, types.DockerAuthConfig{Username: "foo", Password: "bar"}, creds)
+
+	allCreds, err := GetAllCredentials(sys)
+	require.NoError(t, err)
+	assert.Equal(t, types.DockerAuthConfig{Username: "user", Password: "password"}, allCreds["registry.example.com"])
+	assert.Equal(t, types.DockerAuthConfig{Username: "foo", Password: "bar"}, allCreds["registry-a.com"])
+
+	// An explicitly specified auth file takes precedence.
+	creds, err = GetCredentials(&types.SystemContext{
+		AuthFilePath:                defaultAuthFile,
+		SystemRegistriesConfPath:    confPath,
+		SystemRegistriesConfDirPath: sys.SystemRegistriesConfDirPath,
+	}, "registry.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, types.DockerAuthConfig{}, creds)
+
+	err = RemoveAuthentication(sys, "registry.example.com")
+	require.NoError(t, err)
+	auth, err = newAuthPathDefault(registryAuthFile).parse()
+	require.NoError(t, err)
+	assert.NotContains(t, auth.AuthConfigs, "registry.example.com")
+}
+
+// testCredentialStore is a CredentialStore for tests, mapping instance and key to credentials.
+type testCredentialStore map[string]map[string]types.DockerAuthConfig
+
+func (s testCredentialStore) GetCredentials(instance, key string) (types.DockerAuthConfig, bool, error) {
+	if instance == "broken" {
+		return types.DockerAuthConfig{}, false, errors.New("store is broken")
+	}
+	creds, ok := s[instance][key]
+	return creds, ok, nil
+}
+
+func TestCredentialStores(t *testing.T) {
+	store := testCredentialStore{
+		"request-1": {
+			"registry.example.com/ns": {Username: "ns-user", Password: "ns-password"},
+			"registry.example.com":    {Username: "user", Password: "password"},
+		},
+		"request-2": {
+			"other.example.com": {Username: "other-user", Password: "other-password"},
+		},
+	}
+	err := RegisterCredentialStore("test-store", store)
+	require.NoError(t, err)
+	t.Cleanup(func() { UnregisterCredentialStore("test-store") })
+	err = RegisterCredentialStore("test-store", store)
+	assert.Error(t, err)
+	err = RegisterCredentialStore("invalid:scheme", store)
+	assert.Error(t, err)
+
+	tmpDir := t.TempDir()
+	authFile := filepath.Join(tmpDir, "auth.json")
+	confPath := filepath.Join(tmpDir, "registries.conf")
+	err = os.WriteFile(confPath, []byte{}, 0o600)
+	require.NoError(t, err)
+	sys := &types.SystemContext{
+		AuthFilePath:                authFile,
+		SystemRegistriesConfPath:    confPath,
+		SystemRegistriesConfDirPath: filepath.Join(tmpDir, "IdoNotExist"),
+	}
+	_, err = SetCredentials(sys, "file.example.com", "file-user", "file-password")
+	require.NoError(t, err)
+	_, err = SetCredentials(sys, "other.example.com", "other-file-user", "other-file-password")
+	require.NoError(t, err)
+
+	sys.CredentialStores = []string{"test-store:request-1", "test-store:request-2"}
+	for _, c := range []struct {
+		key      string
+		expected types.DockerAuthConfig
+	}{
+		{"registry.example.com/ns/repo", types.DockerAuthConfig{Username: "ns-user", Password: "ns-password"}},
+		{"registry.example.com/other/repo", types.DockerAuthConfig{Username: "user", Password: "password"}},
+		{"other.example.com/repo", types.DockerAuthConfig{Username: "other-user", Password: "other-password"}}, // Stores take precedence over files
+		{"file.example.com/repo", types.DockerAuthConfig{Username: "file-user", Password: "file-password"}},    // Files are used as a fallback
+		{"unknown.example.com", types.DockerAuthConfig{}},
+	} {
+		creds, err := GetCredentials(sys, c.key)
+		require.NoError(t, err, c.key)
+		assert.Equal(t, c.expected, creds, c.key)
+	}
+
+	for _, stores := range [][]string{
+		{"test-store:broken"},
+		{"unknown-store:request-1"},
+		{"no-instance"},
+	} {
+		sys.CredentialStores = stores
+		_, err := GetCredentials(sys, "registry.example.com")
+		assert.Error(t, err, stores)
+	}
+}
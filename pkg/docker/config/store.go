@@ -0,0 +1,79 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/containers/image/v5/types"
+)
+
+// CredentialStore is an in-process source of registry credentials, registered using RegisterCredentialStore
+// and referenced from types.SystemContext.CredentialStores.
+type CredentialStore interface {
+	// GetCredentials returns the credentials stored for exactly key (a repository, a namespace within a registry,
+	// or a registry hostname) in the store instance identified by instance.
+	// It returns false if no credentials for key are stored, in which case less specific keys are looked up by the caller.
+	GetCredentials(instance, key string) (types.DockerAuthConfig, bool, error)
+}
+
+var (
+	credentialStoresLock sync.RWMutex
+	credentialStores     = map[string]CredentialStore{}
+)
+
+// RegisterCredentialStore makes store available to types.SystemContext.CredentialStores entries of the form "scheme:instance".
+// It is typically called by applications embedding this library during initialization, e.g. to serve per-request credentials.
+func RegisterCredentialStore(scheme string, store CredentialStore) error {
+	if scheme == "" || strings.ContainsAny(scheme, ":/") {
+		return fmt.Errorf("invalid credential store scheme %q", scheme)
+	}
+	if store == nil {
+		return errors.New("credential store must not be nil")
+	}
+	credentialStoresLock.Lock()
+	defer credentialStoresLock.Unlock()
+	if _, ok := credentialStores[scheme]; ok {
+		return fmt.Errorf("a credential store for scheme %q is already registered", scheme)
+	}
+	credentialStores[scheme] = store
+	return nil
+}
+
+// UnregisterCredentialStore removes a store registered using RegisterCredentialStore for scheme, if any.
+func UnregisterCredentialStore(scheme string) {
+	credentialStoresLock.Lock()
+	defer credentialStoresLock.Unlock()
+	delete(credentialStores, scheme)
+}
+
+// getCredentialsFromStores looks up credentials for key in the credential stores listed in sys.CredentialStores, in order.
+// It returns false if no store contains credentials for key or any of its parents.
+func getCredentialsFromStores(sys *types.SystemContext, key, registry string) (types.DockerAuthConfig, bool, error) {
+	if sys == nil {
+		return types.DockerAuthConfig{}, false, nil
+	}
+	for _, entry := range sys.CredentialStores {
+		scheme, instance, ok := strings.Cut(entry, ":")
+		if !ok {
+			return types.DockerAuthConfig{}, false, fmt.Errorf("invalid credential store %q, expected scheme:instance", entry)
+		}
+		credentialStoresLock.RLock()
+		store, ok := credentialStores[scheme]
+		credentialStoresLock.RUnlock()
+		if !ok {
+			return types.DockerAuthConfig{}, false, fmt.Errorf("unknown credential store scheme %q", scheme)
+		}
+		for lookupKey := range authKeyLookupOrder(key, registry, false) {
+			creds, found, err := store.GetCredentials(instance, lookupKey)
+			if err != nil {
+				return types.DockerAuthConfig{}, false, fmt.Errorf("looking up credentials for %s in credential store %s: %w", lookupKey, entry, err)
+			}
+			if found {
+				return creds, true, nil
+			}
+		}
+	}
+	return types.DockerAuthConfig{}, false, nil
+}
@@ -0,0 +1,155 @@
+// Package ratelimit implements token bucket rate limiting of registry requests, for use as types.SystemContext.DockerRequestRateLimiter,
+// so that highly parallel operations stay under known registry rate limits instead of triggering HTTP 429 responses.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/containers/image/v5/types"
+)
+
+// Scope determines which requests share a token bucket.
+type Scope int
+
+const (
+	// PerRegistry uses a single bucket for all requests to a registry.
+	PerRegistry Scope = iota
+	// PerRepository uses a separate bucket for each repository on a registry;
+	// registry-wide requests (e.g. the initial ping) use a bucket of their own.
+	PerRepository
+)
+
+// Limit is a token bucket configuration.
+type Limit struct {
+	RequestsPerSecond float64 // The sustained request rate; 0 means no limit
+	Burst             int     // The number of requests which may be sent at once; values < 1 are treated as 1
+}
+
+// Options configure a Limiter.
+type Options struct {
+	Scope Scope
+	// Default applies to all registries not listed in Registries.
+	Default Limit
+	// Registries, if set, maps registry host[:port] values to limits which override Default.
+	Registries map[string]Limit
+}
+
+// Limiter limits the rate of requests to registries, per Options.
+// It is safe for concurrent use, and is intended to be shared by all operations which should be limited together.
+type Limiter struct {
+	options Options
+	now     func() time.Time // Can be overridden for tests
+
+	mutex   sync.Mutex
+	buckets map[string]*bucket // Keyed by registry, or registry/repository
+}
+
+var _ types.DockerRequestRateLimiter = (*Limiter)(nil)
+
+// New returns a Limiter configured by options.
+func New(options Options) (*Limiter, error) {
+	if options.Scope != PerRegistry && options.Scope != PerRepository {
+		return nil, fmt.Errorf("invalid rate limit scope %d", options.Scope)
+	}
+	if err := validateLimit(options.Default); err != nil {
+		return nil, err
+	}
+	for registry, limit := range options.Registries {
+		if err := validateLimit(limit); err != nil {
+			return nil, fmt.Errorf("registry %q: %w", registry, err)
+		}
+	}
+	return &Limiter{
+		options: options,
+		now:     time.Now,
+		buckets: map[string]*bucket{},
+	}, nil
+}
+
+// validateLimit returns an error if limit is invalid.
+func validateLimit(limit Limit) error {
+	if limit.RequestsPerSecond < 0 {
+		return errors.New("the request rate must not be negative")
+	}
+	return nil
+}
+
+// Wait implements types.DockerRequestRateLimiter.
+func (l *Limiter) Wait(ctx context.Context, registry, repository string) error {
+	limit, ok := l.options.Registries[registry]
+	if !ok {
+		limit = l.options.Default
+	}
+	if limit.RequestsPerSecond == 0 {
+		return nil
+	}
+
+	key := registry
+	if l.options.Scope == PerRepository && repository != "" {
+		key = registry + "/" + repository
+	}
+	l.mutex.Lock()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = newBucket(limit, l.now())
+		l.buckets[key] = b
+	}
+	delay := b.reserve(l.now())
+	l.mutex.Unlock()
+	if delay == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		l.mutex.Lock()
+		b.cancelReservation()
+		l.mutex.Unlock()
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// bucket is a single token bucket. All methods must be called with Limiter.mutex held.
+type bucket struct {
+	rate   float64   // Tokens added per second
+	burst  float64   // Maximum number of tokens
+	tokens float64   // Available tokens as of last; negative if requests are waiting for future tokens
+	last   time.Time // The time tokens was last updated
+}
+
+// newBucket returns a full bucket for limit.
+func newBucket(limit Limit, now time.Time) *bucket {
+	burst := float64(max(limit.Burst, 1))
+	return &bucket{
+		rate:   limit.RequestsPerSecond,
+		burst:  burst,
+		tokens: burst,
+		last:   now,
+	}
+}
+
+// reserve takes a token from the bucket, and returns how long the caller must wait before using it.
+func (b *bucket) reserve(now time.Time) time.Duration {
+	if now.After(b.last) {
+		b.tokens = min(b.tokens+now.Sub(b.last).Seconds()*b.rate, b.burst)
+		b.last = now
+	}
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// cancelReservation returns a token taken by reserve, if the caller is not going to use it.
+func (b *bucket) cancelReservation() {
+	b.tokens = min(b.tokens+1, b.burst)
+}
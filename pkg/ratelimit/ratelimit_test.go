@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	for _, options := range []Options{
+		{Scope: Scope(99)},
+		{Default: Limit{RequestsPerSecond: -1}},
+		{Registries: map[string]Limit{"example.com": {RequestsPerSecond: -1}}},
+	} {
+		_, err := New(options)
+		assert.Error(t, err, "%#v", options)
+	}
+
+	l, err := New(Options{Scope: PerRepository, Default: Limit{RequestsPerSecond: 1, Burst: 2}})
+	require.NoError(t, err)
+	assert.NotNil(t, l)
+}
+
+func TestBucketReserve(t *testing.T) {
+	start := time.Unix(1000, 0)
+	b := newBucket(Limit{RequestsPerSecond: 2, Burst: 2}, start)
+	// The burst is available immediately
+	assert.Equal(t, time.Duration(0), b.reserve(start))
+	assert.Equal(t, time.Duration(0), b.reserve(start))
+	// Further requests wait for new tokens, one every 1/rate
+	assert.Equal(t, 500*time.Millisecond, b.reserve(start))
+	assert.Equal(t, time.Second, b.reserve(start))
+	// Canceled reservations are returned
+	b.cancelReservation()
+	assert.Equal(t, time.Second, b.reserve(start))
+	// Tokens are refilled over time, up to burst
+	assert.Equal(t, time.Duration(0), b.reserve(start.Add(time.Hour)))
+	assert.Equal(t, time.Duration(0), b.reserve(start.Add(time.Hour)))
+	assert.Equal(t, 500*time.Millisecond, b.reserve(start.Add(time.Hour)))
+
+	// Burst < 1 is treated as 1
+	b = newBucket(Limit{RequestsPerSecond: 1}, start)
+	assert.Equal(t, time.Duration(0), b.reserve(start))
+	assert.Equal(t, time.Second, b.reserve(start))
+}
+
+func TestLimiterWait(t *testing.T) {
+	now := time.Unix(1000, 0)
+	for _, c := range []struct {
+		scope         Scope
+		sharedBuckets bool
+	}{
+		{PerRegistry, true},
+		{PerRepository, false},
+	} {
+		l, err := New(Options{
+			Scope:      c.scope,
+			Default:    Limit{RequestsPerSecond: 0.001},
+			Registries: map[string]Limit{"unlimited.example.com": {}},
+		})
+		require.NoError(t, err)
+		l.now = func() time.Time { return now }
+
+		ctx := context.Background()
+		err = l.Wait(ctx, "example.com", "repo1")
+		require.NoError(t, err)
+		// A second request has to wait for a long time; cancel it.
+		canceledCtx, cancel := context.WithCancel(ctx)
+		cancel()
+		err = l.Wait(canceledCtx, "example.com", "repo1")
+		assert.ErrorIs(t, err, context.Canceled)
+		// Other repositories share the bucket only with PerRegistry
+		err = l.Wait(canceledCtx, "example.com", "repo2")
+		if c.sharedBuckets {
+			assert.ErrorIs(t, err, context.Canceled)
+		} else {
+			assert.NoError(t, err)
+		}
+		// Other registries never share the bucket
+		err = l.Wait(canceledCtx, "other.example.com", "repo1")
+		assert.NoError(t, err)
+		// Registries without a limit are not limited
+		for range 10 {
+			err = l.Wait(canceledCtx, "unlimited.example.com", "repo1")
+			assert.NoError(t, err)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package shortnames
+
+import (
+	"slices"
+
+	"github.com/containers/image/v5/types"
+	"github.com/sirupsen/logrus"
+)
+
+// Values of Audit.Rationale.
+const (
+	// The input was not a short name, and was used as is.
+	AuditRationaleFullyQualified = "fully-qualified"
+	// The input resolved to a short-name alias.
+	AuditRationaleAlias = "alias"
+	// The input was completed with all unqualified-search registries.
+	AuditRationaleUnqualifiedSearch = "unqualified-search"
+	// The input was completed with an unqualified-search registry selected by the user in a prompt.
+	AuditRationaleUserSelection = "user-selection"
+	// The input was resolved to docker.io, as enforced by the caller.
+	AuditRationaleEnforcedDockerHub = "enforced-docker-hub"
+)
+
+// Audit is a machine-readable record of how Resolve resolved a name, so that
+// security audits can reconstruct why an unqualified name resolved to a
+// particular registry.
+type Audit struct {
+	// The name passed to Resolve.
+	Input string `json:"input"`
+	// The short-name mode used for the resolution: "disabled", "permissive" or "enforcing".
+	Mode string `json:"mode"`
+	// One of the AuditRationale* values.
+	Rationale string `json:"rationale"`
+	// Where the alias or the unqualified-search registries are defined, if relevant.
+	Origin string `json:"origin,omitempty"`
+	// The short-name alias, if Rationale is AuditRationaleAlias.
+	Alias string `json:"alias,omitempty"`
+	// The unqualified-search registries tried, in order, if relevant.
+	SearchRegistries []string `json:"searchRegistries,omitempty"`
+	// The prompt shown to the user, if any.
+	Prompt *AuditPrompt `json:"prompt,omitempty"`
+	// The resulting pull candidates, in order.
+	PullCandidates []string `json:"pullCandidates"`
+	// The pull candidate which was pulled successfully, once PullCandidate.Record has been called.
+	Pulled string `json:"pulled,omitempty"`
+	// Whether PullCandidate.Record recorded Pulled as a new short-name alias.
+	RecordedAlias bool `json:"recordedAlias,omitempty"`
+}
+
+// AuditPrompt is a record of a prompt shown to the user during short-name resolution.
+type AuditPrompt struct {
+	// The choices offered to the user, in order.
+	Choices []string `json:"choices"`
+	// The choice selected by the user.
+	Selection string `json:"selection"`
+}
+
+// Audit returns a machine-readable record of the resolution of r.
+// If types.SystemContext.ShortNameAuditLog is set, the same data is also logged as structured data by Resolve and PullCandidate.Record.
+func (r *Resolved) Audit() Audit {
+	res := Audit{
+		Input:            r.input,
+		Mode:             shortNameModeString(r.mode),
+		Origin:           r.originDescription,
+		SearchRegistries: slices.Clone(r.searchRegistries),
+		PullCandidates:   make([]string, 0, len(r.PullCandidates)),
+		Pulled:           r.pulled,
+		RecordedAlias:    r.recordedAlias,
+	}
+	switch r.rationale {
+	case rationaleAlias:
+		res.Rationale = AuditRationaleAlias
+		if len(r.PullCandidates) > 0 {
+			res.Alias = r.PullCandidates[0].Value.String()
+		}
+	case rationaleUSR:
+		res.Rationale = AuditRationaleUnqualifiedSearch
+	case rationaleUserSelection:
+		res.Rationale = AuditRationaleUserSelection
+	case rationaleEnforcedDockerHub:
+		res.Rationale = AuditRationaleEnforcedDockerHub
+	case rationaleNone:
+		fallthrough
+	default:
+		res.Rationale = AuditRationaleFullyQualified
+	}
+	if r.prompt != nil {
+		res.Prompt = &AuditPrompt{
+			Choices:   slices.Clone(r.prompt.Choices),
+			Selection: r.prompt.Selection,
+		}
+	}
+	for _, c := range r.PullCandidates {
+		res.PullCandidates = append(res.PullCandidates, c.Value.String())
+	}
+	return res
+}
+
+// logAudit logs the audit trail of r with msg, if enabled in r.systemContext.
+func (r *Resolved) logAudit(msg string) {
+	if r.systemContext == nil || !r.systemContext.ShortNameAuditLog {
+		return
+	}
+	a := r.Audit()
+	fields := logrus.Fields{
+		"input":          a.Input,
+		"mode":           a.Mode,
+		"rationale":      a.Rationale,
+		"pullCandidates": a.PullCandidates,
+	}
+	if a.Origin != "" {
+		fields["origin"] = a.Origin
+	}
+	if a.Alias != "" {
+		fields["alias"] = a.Alias
+	}
+	if len(a.SearchRegistries) != 0 {
+		fields["searchRegistries"] = a.SearchRegistries
+	}
+	if a.Prompt != nil {
+		fields["promptChoices"] = a.Prompt.Choices
+		fields["promptSelection"] = a.Prompt.Selection
+	}
+	if a.Pulled != "" {
+		fields["pulled"] = a.Pulled
+		fields["recordedAlias"] = a.RecordedAlias
+	}
+	logrus.WithFields(fields).Info(msg)
+}
+
+// shortNameModeString returns a string representation of mode, as used in registries.conf.
+func shortNameModeString(mode types.ShortNameMode) string {
+	switch mode {
+	case types.ShortNameModeDisabled:
+		return "disabled"
+	case types.ShortNameModePermissive:
+		return "permissive"
+	case types.ShortNameModeEnforcing:
+		return "enforcing"
+	default:
+		return "invalid"
+	}
+}
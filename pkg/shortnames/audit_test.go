@@ -0,0 +1,64 @@
+package shortnames
+
+import (
+	"os"
+	"testing"
+
+	"github.com/containers/image/v5/pkg/sysregistriesv2"
+	"github.com/containers/image/v5/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolvedAudit(t *testing.T) {
+	tmp, err := os.CreateTemp("", "aliases.conf")
+	require.NoError(t, err)
+	defer os.Remove(tmp.Name())
+
+	mode := types.ShortNameModePermissive
+	sys := &types.SystemContext{
+		SystemRegistriesConfPath:    "testdata/two-reg.conf",
+		SystemRegistriesConfDirPath: "testdata/this-does-not-exist",
+		UserShortNameAliasConfPath:  tmp.Name(),
+		ShortNameMode:               &mode,
+		ShortNameAuditLog:           true,
+	}
+	_, err = sysregistriesv2.TryUpdatingCache(sys)
+	require.NoError(t, err)
+
+	// Fully-qualified
+	resolved, err := Resolve(sys, "quay.io/repo/image:tag")
+	require.NoError(t, err)
+	assert.Equal(t, Audit{
+		Input:          "quay.io/repo/image:tag",
+		Mode:           "permissive",
+		Rationale:      AuditRationaleFullyQualified,
+		PullCandidates: []string{"quay.io/repo/image:tag"},
+	}, resolved.Audit())
+
+	// Alias
+	resolved, err = Resolve(sys, "repo/image")
+	require.NoError(t, err)
+	audit := resolved.Audit()
+	assert.Equal(t, AuditRationaleAlias, audit.Rationale)
+	assert.Equal(t, "quay.io/repo/image:latest", audit.Alias)
+	assert.NotEmpty(t, audit.Origin)
+	assert.Empty(t, audit.SearchRegistries)
+	assert.Equal(t, []string{"quay.io/repo/image:latest"}, audit.PullCandidates)
+
+	// Unqualified-search registries (without a TTY, so no prompt), and the final choice
+	resolved, err = Resolve(sys, "foo:tag")
+	require.NoError(t, err)
+	audit = resolved.Audit()
+	assert.Equal(t, AuditRationaleUnqualifiedSearch, audit.Rationale)
+	assert.Equal(t, "testdata/two-reg.conf", audit.Origin)
+	assert.Equal(t, []string{"quay.io", "registry.com"}, audit.SearchRegistries)
+	assert.Nil(t, audit.Prompt)
+	assert.Equal(t, []string{"quay.io/foo:tag", "registry.com/foo:tag"}, audit.PullCandidates)
+	assert.Empty(t, audit.Pulled)
+	err = resolved.PullCandidates[1].Record()
+	require.NoError(t, err)
+	audit = resolved.Audit()
+	assert.Equal(t, "registry.com/foo:tag", audit.Pulled)
+	assert.False(t, audit.RecordedAlias)
+}
@@ -113,6 +113,14 @@ type Resolved struct {
 	systemContext     *types.SystemContext
 	rationale         rationale
 	originDescription string
+
+	// Audit trail data; see Audit.
+	input            string
+	mode             types.ShortNameMode
+	searchRegistries []string
+	prompt           *AuditPrompt
+	pulled           string
+	recordedAlias    bool
 }
 
 func (r *Resolved) addCandidate(named reference.Named) {
@@ -196,7 +204,9 @@ type PullCandidate struct {
 
 // Record may store a short-name alias for the PullCandidate.
 func (c *PullCandidate) Record() error {
+	c.resolved.pulled = c.Value.String()
 	if !c.record {
+		c.resolved.logAudit("Pulled resolved image name")
 		return nil
 	}
 
@@ -207,6 +217,8 @@ func (c *PullCandidate) Record() error {
 	if err := Add(c.resolved.systemContext, name.String(), value); err != nil {
 		return fmt.Errorf("recording short-name alias (%q=%q): %w", c.resolved.userInput, c.Value, err)
 	}
+	c.resolved.recordedAlias = true
+	c.resolved.logAudit("Pulled resolved image name")
 	return nil
 }
 
@@ -229,8 +241,20 @@ func (c *PullCandidate) Record() error {
 // Furthermore, before attempting to pull callers *should* call
 // `(Resolved).Description` and afterwards use
 // `(Resolved).FormatPullErrors` in case of pull errors.
+//
+// `(Resolved).Audit` returns a machine-readable record of the resolution.
 func Resolve(ctx *types.SystemContext, name string) (*Resolved, error) {
-	resolved := &Resolved{}
+	resolved, err := resolve(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	resolved.logAudit("Resolved image name")
+	return resolved, nil
+}
+
+// resolve implements Resolve, except for logging the audit trail.
+func resolve(ctx *types.SystemContext, name string) (*Resolved, error) {
+	resolved := &Resolved{input: name}
 
 	// Create a copy of the system context to make it usable beyond this
 	// function call.
@@ -245,6 +269,7 @@ func Resolve(ctx *types.SystemContext, name string) (*Resolved, error) {
 	if err != nil {
 		return nil, err
 	}
+	resolved.mode = mode
 
 	// Sanity check the short-name mode.
 	switch mode {
@@ -322,6 +347,7 @@ func Resolve(ctx *types.SystemContext, name string) (*Resolved, error) {
 		return nil, fmt.Errorf("short-name %q did not resolve to an alias and no containers-registries.conf(5) was found", name)
 	}
 	resolved.originDescription = usrConfig
+	resolved.searchRegistries = unqualifiedSearchRegistries
 
 	for _, reg := range unqualifiedSearchRegistries {
 		named, err := reference.ParseNormalizedNamed(fmt.Sprintf("%s/%s", reg, name))
@@ -378,6 +404,7 @@ func Resolve(ctx *types.SystemContext, name string) (*Resolved, error) {
 	if err != nil {
 		return nil, fmt.Errorf("selection %q is not a valid reference: %w", selection, err)
 	}
+	resolved.prompt = &AuditPrompt{Choices: strCandidates, Selection: selection}
 
 	resolved.PullCandidates = nil
 	resolved.addCandidateToRecord(named)
@@ -0,0 +1,206 @@
+// Package sigv4 implements AWS Signature Version 4 request signing, for use as types.SystemContext.DockerRequestSigner
+// with registries (e.g. ECR-compatible endpoints, or registries backed by S3) which require it.
+package sigv4
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/containers/image/v5/types"
+)
+
+const (
+	algorithm = "AWS4-HMAC-SHA256"
+	// unsignedPayload is used instead of the payload hash when the request body can't be read without consuming it.
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+	timeFormat      = "20060102T150405Z"
+	dateFormat      = "20060102"
+)
+
+// Credentials are AWS credentials used to sign requests.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string // Optional, for temporary credentials
+}
+
+// Signer signs requests using AWS Signature Version 4.
+type Signer struct {
+	credentials Credentials
+	region      string
+	service     string
+	now         func() time.Time // Can be overridden for tests
+}
+
+var _ types.DockerRequestSigner = (*Signer)(nil)
+
+// NewSigner returns a Signer which signs requests for service (e.g. "ecr" or "s3") in region using credentials.
+func NewSigner(credentials Credentials, region, service string) (*Signer, error) {
+	if credentials.AccessKeyID == "" || credentials.SecretAccessKey == "" {
+		return nil, errors.New("AWS access key ID and secret access key must be set")
+	}
+	if region == "" || service == "" {
+		return nil, errors.New("AWS region and service must be set")
+	}
+	return &Signer{
+		credentials: credentials,
+		region:      region,
+		service:     service,
+		now:         time.Now,
+	}, nil
+}
+
+// SignRequest implements types.DockerRequestSigner.
+func (s *Signer) SignRequest(req *http.Request) error {
+	now := s.now().UTC()
+	amzDate := now.Format(timeFormat)
+	payloadHash, err := s.payloadHash(req)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if s.service == "s3" {
+		// S3 requires the header; other services compute the payload hash themselves.
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+	if s.credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.credentials.SessionToken)
+	}
+
+	canonicalHeaders, signedHeaders := s.canonicalHeaders(req)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s.canonicalURI(req),
+		canonicalQuery(req),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := strings.Join([]string{now.Format(dateFormat), s.region, s.service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{algorithm, amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.credentials.SecretAccessKey), now.Format(dateFormat))
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, s.service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		algorithm, s.credentials.AccessKeyID, scope, signedHeaders, signature))
+	return nil
+}
+
+// payloadHash returns the hex-encoded SHA-256 of the body of req, or unsignedPayload if it can't be read.
+func (s *Signer) payloadHash(req *http.Request) (string, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return sha256Hex(nil), nil
+	}
+	if req.GetBody == nil {
+		return unsignedPayload, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return "", fmt.Errorf("reading request body to sign it: %w", err)
+	}
+	defer body.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, body); err != nil {
+		return "", fmt.Errorf("reading request body to sign it: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// canonicalURI returns the canonical URI of req.
+func (s *Signer) canonicalURI(req *http.Request) string {
+	path := req.URL.Path
+	if path == "" {
+		path = "/"
+	}
+	res := escape(path, false)
+	if s.service != "s3" {
+		// All services except S3 expect each path segment to be encoded twice.
+		res = escape(res, false)
+	}
+	return res
+}
+
+// canonicalQuery returns the canonical query string of req.
+func canonicalQuery(req *http.Request) string {
+	query := req.URL.Query()
+	params := []string{}
+	for key, values := range query {
+		for _, value := range values {
+			params = append(params, escape(key, true)+"="+escape(value, true))
+		}
+	}
+	slices.Sort(params)
+	return strings.Join(params, "&")
+}
+
+// canonicalHeaders returns the canonical headers and the list of signed headers of req.
+// Only the host and x-amz-* headers are signed, other headers might be modified by proxies.
+func (s *Signer) canonicalHeaders(req *http.Request) (string, string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") {
+			trimmed := make([]string, 0, len(values))
+			for _, v := range values {
+				trimmed = append(trimmed, strings.Join(strings.Fields(v), " "))
+			}
+			headers[lower] = strings.Join(trimmed, ",")
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	sb := strings.Builder{}
+	for _, name := range names {
+		sb.WriteString(name + ":" + headers[name] + "\n")
+	}
+	return sb.String(), strings.Join(names, ";")
+}
+
+// escape URI-encodes s as required by Signature Version 4: everything except unreserved characters,
+// and "/" unless encodeSlash.
+func escape(s string, encodeSlash bool) string {
+	sb := strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~',
+			c == '/' && !encodeSlash:
+			sb.WriteByte(c)
+		default:
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
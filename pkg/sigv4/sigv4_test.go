@@ -0,0 +1,87 @@
+package sigv4
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSigner returns a signer using the credentials and time of the AWS Signature Version 4 test suite.
+func testSigner(t *testing.T, service string, sessionToken string) *Signer {
+	s, err := NewSigner(Credentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		SessionToken:    sessionToken,
+	}, "us-east-1", service)
+	require.NoError(t, err)
+	s.now = func() time.Time { return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC) }
+	return s
+}
+
+func TestNewSigner(t *testing.T) {
+	for _, c := range []struct {
+		creds           Credentials
+		region, service string
+	}{
+		{Credentials{SecretAccessKey: "secret"}, "us-east-1", "ecr"},
+		{Credentials{AccessKeyID: "id"}, "us-east-1", "ecr"},
+		{Credentials{AccessKeyID: "id", SecretAccessKey: "secret"}, "", "ecr"},
+		{Credentials{AccessKeyID: "id", SecretAccessKey: "secret"}, "us-east-1", ""},
+	} {
+		_, err := NewSigner(c.creds, c.region, c.service)
+		assert.Error(t, err, "%#v", c)
+	}
+}
+
+func TestSignRequest(t *testing.T) {
+	// Test vectors from the AWS Signature Version 4 test suite
+	for _, c := range []struct {
+		url, signature string
+	}{
+		{ // get-vanilla
+			"https://example.amazonaws.com/",
+			"5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{ // get-vanilla-query-order-key-case
+			"https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			"b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+	} {
+		req, err := http.NewRequest(http.MethodGet, c.url, nil)
+		require.NoError(t, err)
+		err = testSigner(t, "service", "").SignRequest(req)
+		require.NoError(t, err)
+		assert.Equal(t, "20150830T123600Z", req.Header.Get("X-Amz-Date"))
+		assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature="+c.signature,
+			req.Header.Get("Authorization"), c.url)
+	}
+}
+
+func TestSignRequestHeaders(t *testing.T) {
+	// S3 signs the payload hash; the session token is signed
+	req, err := http.NewRequest(http.MethodPut, "https://bucket.s3.amazonaws.com/a/b", bytes.NewReader([]byte("data")))
+	require.NoError(t, err)
+	err = testSigner(t, "s3", "token").SignRequest(req)
+	require.NoError(t, err)
+	assert.Equal(t, "3a6eb0790f39ac87c94f3856b2dd2c5d110e6811602261a9a923d3bb23adc8b7", req.Header.Get("X-Amz-Content-Sha256"))
+	assert.Equal(t, "token", req.Header.Get("X-Amz-Security-Token"))
+	assert.Contains(t, req.Header.Get("Authorization"), "SignedHeaders=host;x-amz-content-sha256;x-amz-date;x-amz-security-token,")
+
+	// A body which can't be re-read is not hashed
+	req, err = http.NewRequest(http.MethodPut, "https://bucket.s3.amazonaws.com/a/b", strings.NewReader("data"))
+	require.NoError(t, err)
+	req.GetBody = nil
+	err = testSigner(t, "s3", "").SignRequest(req)
+	require.NoError(t, err)
+	assert.Equal(t, unsignedPayload, req.Header.Get("X-Amz-Content-Sha256"))
+}
+
+func TestEscape(t *testing.T) {
+	assert.Equal(t, "/a-b_c.d~e/%20%2B%3A", escape("/a-b_c.d~e/ +:", false))
+	assert.Equal(t, "%2Fa%2Fb", escape("/a/b", true))
+}
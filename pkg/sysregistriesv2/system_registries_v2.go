@@ -135,6 +135,15 @@ type Registry struct {
 	// tag can potentially yield different images, depending on which endpoint
 	// we pull from.  Restricting mirrors to pulls by digest avoids that issue.
 	MirrorByDigestOnly bool `toml:"mirror-by-digest-only,omitempty"`
+	// Credential helpers to use for this registry, overriding the global
+	// CredentialHelpers (which are not consulted at all for this registry).
+	// The same values as in V2RegistriesConf.CredentialHelpers are accepted.
+	CredentialHelpers []string `toml:"credential-helpers,omitempty"`
+	// An absolute path to the auth file (in the containers-auth.json(5) format)
+	// to use for this registry instead of the default auth file locations,
+	// when the "containers-auth.json" credential helper is used.
+	// This is ignored if the caller explicitly specifies an auth file.
+	AuthFile string `toml:"auth-file,omitempty"`
 }
 
 // PullSource consists of an Endpoint and a Reference. Note that the reference is
@@ -426,6 +435,15 @@ func (config *V2RegistriesConf) postProcessRegistries() error {
 			}
 		}
 
+		for _, helper := range reg.CredentialHelpers {
+			if helper == "" {
+				return &InvalidRegistries{s: fmt.Sprintf("invalid empty credential helper for registry %q", reg.Prefix)}
+			}
+		}
+		if reg.AuthFile != "" && !filepath.IsAbs(reg.AuthFile) {
+			return &InvalidRegistries{s: fmt.Sprintf("auth-file %q for registry %q is not an absolute path", reg.AuthFile, reg.Prefix)}
+		}
+
 		// validate the mirror usage settings does not apply to primary registry
 		if reg.PullFromMirror != "" {
 			return fmt.Errorf("pull-from-mirror must not be set for a non-mirror registry %q", reg.Prefix)
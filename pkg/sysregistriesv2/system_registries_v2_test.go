@@ -440,6 +440,8 @@ func TestInvalidV2Configs(t *testing.T) {
 		{"testdata/blocked-conflicts.conf", "registry 'registry.com' is defined multiple times with conflicting 'blocked' setting"},
 		{"testdata/missing-mirror-location.conf", "invalid condition: mirror location is unset"},
 		{"testdata/invalid-prefix.conf", "invalid location"},
+		{"testdata/invalid-auth-file.conf", "is not an absolute path"},
+		{"testdata/invalid-credential-helper.conf", "invalid empty credential helper"},
 		{"testdata/this-does-not-exist.conf", "no such file or directory"},
 	} {
 		_, err := GetRegistries(&types.SystemContext{SystemRegistriesConfPath: c.path})
@@ -974,3 +976,29 @@ func TestCredentialHelpers(t *testing.T) {
 		require.Equal(t, test.helpers, helpers, "%v", test)
 	}
 }
+
+func TestPerRegistryCredentialConfig(t *testing.T) {
+	sys := &types.SystemContext{
+		SystemRegistriesConfPath:    "testdata/per-registry-credentials.conf",
+		SystemRegistriesConfDirPath: "testdata/this-does-not-exist",
+	}
+	helpers, err := CredentialHelpers(sys)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"helper-1"}, helpers)
+
+	for _, c := range []struct {
+		ref      string
+		helpers  []string
+		authFile string
+	}{
+		{"registry-a.com/foo", []string{"helper-a"}, ""},
+		{"registry-b.com/foo", nil, "/etc/containers/auth/registry-b.json"},
+		{"registry-c.com/foo", nil, ""},
+	} {
+		reg, err := FindRegistry(sys, c.ref)
+		require.NoError(t, err, c.ref)
+		require.NotNil(t, reg, c.ref)
+		assert.Equal(t, c.helpers, reg.CredentialHelpers, c.ref)
+		assert.Equal(t, c.authFile, reg.AuthFile, c.ref)
+	}
+}
@@ -78,6 +78,22 @@ func SetupCertificates(dir string, tlsc *tls.Config) error {
 	return nil
 }
 
+// AppendCACertificates adds the CA certificates in pemData to tlsc.RootCAs, starting with the system certificate pool if tlsc.RootCAs is not set.
+// It fails if pemData does not contain any certificates.
+func AppendCACertificates(pemData []byte, tlsc *tls.Config) error {
+	if tlsc.RootCAs == nil {
+		systemPool, err := x509.SystemCertPool()
+		if err != nil {
+			return fmt.Errorf("unable to get system cert pool: %w", err)
+		}
+		tlsc.RootCAs = systemPool
+	}
+	if !tlsc.RootCAs.AppendCertsFromPEM(pemData) {
+		return errors.New("no PEM-encoded certificates found")
+	}
+	return nil
+}
+
 func hasFile(files []os.DirEntry, name string) bool {
 	return slices.ContainsFunc(files, func(f os.DirEntry) bool {
 		return f.Name() == name
@@ -129,3 +129,32 @@ func TestSetupCertificates(t *testing.T) {
 	err = SetupCertificates("testdata/unreadable-cert", &tlsc)
 	assert.Error(t, err)
 }
+
+func TestAppendCACertificates(t *testing.T) {
+	ca1, err := os.ReadFile("testdata/full/ca-cert-1.crt")
+	require.NoError(t, err)
+	ca2, err := os.ReadFile("testdata/full/ca-cert-2.crt")
+	require.NoError(t, err)
+
+	// Success, starting with the system cert pool
+	tlsc := tls.Config{}
+	err = AppendCACertificates(ca1, &tlsc)
+	require.NoError(t, err)
+	expected, err := x509.SystemCertPool()
+	require.NoError(t, err)
+	ok := expected.AppendCertsFromPEM(ca1)
+	require.True(t, ok)
+	assert.True(t, expected.Equal(tlsc.RootCAs))
+
+	// Success, adding to an existing pool
+	err = AppendCACertificates(ca2, &tlsc)
+	require.NoError(t, err)
+	ok = expected.AppendCertsFromPEM(ca2)
+	require.True(t, ok)
+	assert.True(t, expected.Equal(tlsc.RootCAs))
+
+	// No certificates
+	tlsc = tls.Config{}
+	err = AppendCACertificates([]byte("this is not PEM"), &tlsc)
+	assert.Error(t, err)
+}
@@ -0,0 +1,141 @@
+package transportstubs
+
+import (
+	"context"
+	"errors"
+
+	destimpl "github.com/containers/image/v5/internal/imagedestination/impl"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// DestinationProperties collects properties of a types.ImageDestination that are constant throughout its lifetime
+// (but might differ across instances).
+type DestinationProperties struct {
+	// SupportedManifestMIMETypes tells which manifest MIME types the destination supports.
+	// A empty slice or nil means any MIME type can be tried to upload.
+	SupportedManifestMIMETypes []string
+	// DesiredLayerCompression indicates the kind of compression to apply on layers
+	DesiredLayerCompression types.LayerCompression
+	// AcceptsForeignLayerURLs is false if foreign layers in manifest should be actually
+	// uploaded to the image destination, true otherwise.
+	AcceptsForeignLayerURLs bool
+	// MustMatchRuntimeOS is set to true if the destination can store only images targeted for the current runtime architecture and OS.
+	MustMatchRuntimeOS bool
+	// IgnoresEmbeddedDockerReference is set to true if the destination does not care about Image.EmbeddedDockerReferenceConflicts(),
+	// and would prefer to receive an unmodified manifest instead of one modified for the destination.
+	// Does not make a difference if Reference().DockerReference() is nil.
+	IgnoresEmbeddedDockerReference bool
+	// HasThreadSafePutBlob indicates that PutBlob can be executed concurrently.
+	HasThreadSafePutBlob bool
+}
+
+// DestinationPropertyMethodsInitialize implements parts of types.ImageDestination corresponding to DestinationProperties.
+// See DestinationPropertyMethods() below.
+type DestinationPropertyMethodsInitialize struct {
+	impl destimpl.PropertyMethodsInitialize
+}
+
+// DestinationPropertyMethods creates a DestinationPropertyMethodsInitialize for vals.
+func DestinationPropertyMethods(vals DestinationProperties) DestinationPropertyMethodsInitialize {
+	return DestinationPropertyMethodsInitialize{
+		impl: destimpl.PropertyMethods(destimpl.Properties{
+			SupportedManifestMIMETypes:     vals.SupportedManifestMIMETypes,
+			DesiredLayerCompression:        vals.DesiredLayerCompression,
+			AcceptsForeignLayerURLs:        vals.AcceptsForeignLayerURLs,
+			MustMatchRuntimeOS:             vals.MustMatchRuntimeOS,
+			IgnoresEmbeddedDockerReference: vals.IgnoresEmbeddedDockerReference,
+			HasThreadSafePutBlob:           vals.HasThreadSafePutBlob,
+		}),
+	}
+}
+
+// SupportedManifestMIMETypes tells which manifest mime types the destination supports
+// If an empty slice or nil it's returned, then any mime type can be tried to upload
+func (o DestinationPropertyMethodsInitialize) SupportedManifestMIMETypes() []string {
+	return o.impl.SupportedManifestMIMETypes()
+}
+
+// DesiredLayerCompression indicates the kind of compression to apply on layers
+func (o DestinationPropertyMethodsInitialize) DesiredLayerCompression() types.LayerCompression {
+	return o.impl.DesiredLayerCompression()
+}
+
+// AcceptsForeignLayerURLs returns false iff foreign layers in manifest should be actually
+// uploaded to the image destination, true otherwise.
+func (o DestinationPropertyMethodsInitialize) AcceptsForeignLayerURLs() bool {
+	return o.impl.AcceptsForeignLayerURLs()
+}
+
+// MustMatchRuntimeOS returns true iff the destination can store only images targeted for the current runtime architecture and OS. False otherwise.
+func (o DestinationPropertyMethodsInitialize) MustMatchRuntimeOS() bool {
+	return o.impl.MustMatchRuntimeOS()
+}
+
+// IgnoresEmbeddedDockerReference() returns true iff the destination does not care about Image.EmbeddedDockerReferenceConflicts(),
+// and would prefer to receive an unmodified manifest instead of one modified for the destination.
+// Does not make a difference if Reference().DockerReference() is nil.
+func (o DestinationPropertyMethodsInitialize) IgnoresEmbeddedDockerReference() bool {
+	return o.impl.IgnoresEmbeddedDockerReference()
+}
+
+// HasThreadSafePutBlob indicates whether PutBlob can be executed concurrently.
+func (o DestinationPropertyMethodsInitialize) HasThreadSafePutBlob() bool {
+	return o.impl.HasThreadSafePutBlob()
+}
+
+// AlwaysSupportsSignatures implements SupportsSignatures() that returns nil.
+type AlwaysSupportsSignatures struct{}
+
+// SupportsSignatures returns an error (to be displayed to the user) if the destination certainly can't store signatures.
+// Note: It is still possible for PutSignatures to fail if SupportsSignatures returns nil.
+func (stub AlwaysSupportsSignatures) SupportsSignatures(ctx context.Context) error {
+	return nil
+}
+
+// NoSignaturesInitialize implements parts of types.ImageDestination
+// for transports that don’t support storing signatures.
+// See NoSignatures() below.
+type NoSignaturesInitialize struct {
+	message string
+}
+
+// NoSignatures creates a NoSignaturesInitialize, failing with message.
+func NoSignatures(message string) NoSignaturesInitialize {
+	return NoSignaturesInitialize{
+		message: message,
+	}
+}
+
+// SupportsSignatures returns an error (to be displayed to the user) if the destination certainly can't store signatures.
+// Note: It is still possible for PutSignatures to fail if SupportsSignatures returns nil.
+func (stub NoSignaturesInitialize) SupportsSignatures(ctx context.Context) error {
+	return errors.New(stub.message)
+}
+
+// PutSignatures writes a set of signatures to the destination.
+// If instanceDigest is not nil, it contains a digest of the specific manifest instance to write or overwrite the signatures for
+// (when the primary manifest is a manifest list); this should always be nil if the primary manifest is not a manifest list.
+// MUST be called after PutManifest (signatures may reference manifest contents).
+func (stub NoSignaturesInitialize) PutSignatures(ctx context.Context, signatures [][]byte, instanceDigest *digest.Digest) error {
+	if len(signatures) != 0 {
+		return errors.New(stub.message)
+	}
+	return nil
+}
+
+// NoTryReusingBlob implements TryReusingBlob() that never reuses blobs.
+type NoTryReusingBlob struct{}
+
+// TryReusingBlob checks whether the transport already contains, or can efficiently reuse, a blob, and if so, applies it to the current destination
+// (e.g. if the blob is a filesystem layer, this signifies that the changes it describes need to be applied again when composing a filesystem tree).
+// info.Digest must not be empty.
+// If canSubstitute, TryReusingBlob can use an equivalent of the desired blob; in that case the returned info may not match the input.
+// If the blob has been successfully reused, returns (true, info, nil); info must contain at least a digest and size, and may
+// include CompressionOperation and CompressionAlgorithm fields to indicate that a change to the compression type should be
+// reflected in the manifest that will be written.
+// If the transport can not reuse the requested blob, TryReusingBlob returns (false, {}, nil); it returns a non-nil error only on an unexpected failure.
+// May use and/or update cache.
+func (stub NoTryReusingBlob) TryReusingBlob(ctx context.Context, info types.BlobInfo, cache types.BlobInfoCache, canSubstitute bool) (bool, types.BlobInfo, error) {
+	return false, types.BlobInfo{}, nil
+}
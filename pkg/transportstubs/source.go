@@ -0,0 +1,62 @@
+package transportstubs
+
+import (
+	"context"
+
+	srcimpl "github.com/containers/image/v5/internal/imagesource/impl"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// SourceProperties collects properties of a types.ImageSource that are constant throughout its lifetime
+// (but might differ across instances).
+type SourceProperties struct {
+	// HasThreadSafeGetBlob indicates whether GetBlob can be executed concurrently.
+	HasThreadSafeGetBlob bool
+}
+
+// SourcePropertyMethodsInitialize implements parts of types.ImageSource corresponding to SourceProperties.
+// See SourcePropertyMethods() below.
+type SourcePropertyMethodsInitialize struct {
+	impl srcimpl.PropertyMethodsInitialize
+}
+
+// SourcePropertyMethods creates a SourcePropertyMethodsInitialize for vals.
+func SourcePropertyMethods(vals SourceProperties) SourcePropertyMethodsInitialize {
+	return SourcePropertyMethodsInitialize{
+		impl: srcimpl.PropertyMethods(srcimpl.Properties{
+			HasThreadSafeGetBlob: vals.HasThreadSafeGetBlob,
+		}),
+	}
+}
+
+// HasThreadSafeGetBlob indicates whether GetBlob can be executed concurrently.
+func (o SourcePropertyMethodsInitialize) HasThreadSafeGetBlob() bool {
+	return o.impl.HasThreadSafeGetBlob()
+}
+
+// DoesNotAffectLayerInfosForCopy implements LayerInfosForCopy() that returns nothing.
+type DoesNotAffectLayerInfosForCopy struct{}
+
+// LayerInfosForCopy returns either nil (meaning the values in the manifest are fine), or updated values for the layer
+// blobsums that are listed in the image's manifest.  If values are returned, they should be used when using GetBlob()
+// to read the image's layers.
+// If instanceDigest is not nil, it contains a digest of the specific manifest instance to retrieve BlobInfos for
+// (when the primary manifest is a manifest list); this never happens if the primary manifest is not a manifest list
+// (e.g. if the source never returns manifest lists).
+// The Digest field is guaranteed to be provided; Size may be -1.
+// WARNING: The list may contain duplicates, and they are semantically relevant.
+func (stub DoesNotAffectLayerInfosForCopy) LayerInfosForCopy(ctx context.Context, instanceDigest *digest.Digest) ([]types.BlobInfo, error) {
+	return nil, nil
+}
+
+// NoGetSignatures implements GetSignatures() that returns nothing.
+type NoGetSignatures struct{}
+
+// GetSignatures returns the image's signatures.  It may use a remote (= slow) service.
+// If instanceDigest is not nil, it contains a digest of the specific manifest instance to retrieve signatures for
+// (when the primary manifest is a manifest list); this never happens if the primary manifest is not a manifest list
+// (e.g. if the source never returns manifest lists).
+func (stub NoGetSignatures) GetSignatures(ctx context.Context, instanceDigest *digest.Digest) ([][]byte, error) {
+	return nil, nil
+}
@@ -0,0 +1,36 @@
+// Package transportstubs contains trivial implementations of parts of types.ImageSource and types.ImageDestination,
+// for transports implemented outside of this module.
+//
+// Transports implemented outside of this module implement the public types.ImageSource and types.ImageDestination interfaces;
+// copy.Image and other users automatically adapt them to the internal interfaces, so there is no need to
+// wrap them explicitly. For the same reason, this package contains no stubs for features only available through the internal
+// interfaces (like NoPutBlobPartialInitialize, which implements the internal PutBlobPartial): the adapters already
+// implement them, with the same behavior as the stubs.
+//
+// All types in this package are independent of the internal implementation, so that they can remain stable
+// as the internal interfaces evolve.
+//
+// There are two kinds of helpers:
+//
+// First, there are pure stubs, like AlwaysSupportsSignatures. Those can just be included in an implementation:
+//
+//	type yourDestination struct {
+//		transportstubs.AlwaysSupportsSignatures
+//		…
+//	}
+//
+// Second, there are stubs with a constructor, like DestinationPropertyMethodsInitialize. The Initialize marker
+// means that a constructor must be called:
+//
+//	type yourDestination struct {
+//		transportstubs.DestinationPropertyMethodsInitialize
+//		…
+//	}
+//
+//	dest := &yourDestination{
+//		…
+//		DestinationPropertyMethodsInitialize: transportstubs.DestinationPropertyMethods(transportstubs.DestinationProperties{
+//			SupportedManifestMIMETypes: …,
+//		}),
+//	}
+package transportstubs
@@ -0,0 +1,94 @@
+package transportstubs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+)
+
+// testDestination is an incomplete types.ImageDestination built from the stubs.
+type testDestination struct {
+	DestinationPropertyMethodsInitialize
+	NoSignaturesInitialize
+	NoTryReusingBlob
+}
+
+// testSource is an incomplete types.ImageSource built from the stubs.
+type testSource struct {
+	SourcePropertyMethodsInitialize
+	DoesNotAffectLayerInfosForCopy
+	NoGetSignatures
+}
+
+// Ensure the stubs implement the expected parts of the public interfaces.
+var _ interface {
+	SupportedManifestMIMETypes() []string
+	DesiredLayerCompression() types.LayerCompression
+	AcceptsForeignLayerURLs() bool
+	MustMatchRuntimeOS() bool
+	IgnoresEmbeddedDockerReference() bool
+	HasThreadSafePutBlob() bool
+	SupportsSignatures(ctx context.Context) error
+	PutSignatures(ctx context.Context, signatures [][]byte, instanceDigest *digest.Digest) error
+	TryReusingBlob(ctx context.Context, info types.BlobInfo, cache types.BlobInfoCache, canSubstitute bool) (bool, types.BlobInfo, error)
+} = testDestination{}
+var _ interface {
+	HasThreadSafeGetBlob() bool
+	LayerInfosForCopy(ctx context.Context, instanceDigest *digest.Digest) ([]types.BlobInfo, error)
+	GetSignatures(ctx context.Context, instanceDigest *digest.Digest) ([][]byte, error)
+} = testSource{}
+var _ interface {
+	SupportsSignatures(ctx context.Context) error
+} = AlwaysSupportsSignatures{}
+
+func TestDestinationStubs(t *testing.T) {
+	dest := testDestination{
+		DestinationPropertyMethodsInitialize: DestinationPropertyMethods(DestinationProperties{
+			SupportedManifestMIMETypes: []string{"a", "b"},
+			DesiredLayerCompression:    types.Decompress,
+			HasThreadSafePutBlob:       true,
+		}),
+		NoSignaturesInitialize: NoSignatures("signatures are not supported"),
+	}
+	assert.Equal(t, []string{"a", "b"}, dest.SupportedManifestMIMETypes())
+	assert.Equal(t, types.Decompress, dest.DesiredLayerCompression())
+	assert.False(t, dest.AcceptsForeignLayerURLs())
+	assert.True(t, dest.HasThreadSafePutBlob())
+
+	err := dest.SupportsSignatures(context.Background())
+	assert.ErrorContains(t, err, "signatures are not supported")
+	err = dest.PutSignatures(context.Background(), nil, nil)
+	assert.NoError(t, err)
+	err = dest.PutSignatures(context.Background(), [][]byte{[]byte("sig")}, nil)
+	assert.ErrorContains(t, err, "signatures are not supported")
+
+	// Internal-only capabilities are not exposed through the public stubs.
+	_, ok := any(dest.DestinationPropertyMethodsInitialize).(interface {
+		Capabilities() private.DestinationCapabilities
+	})
+	assert.False(t, ok)
+
+	reused, _, err := dest.TryReusingBlob(context.Background(), types.BlobInfo{Digest: digest.FromString("blob")}, nil, true)
+	assert.NoError(t, err)
+	assert.False(t, reused)
+
+	err = AlwaysSupportsSignatures{}.SupportsSignatures(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestSourceStubs(t *testing.T) {
+	src := testSource{
+		SourcePropertyMethodsInitialize: SourcePropertyMethods(SourceProperties{HasThreadSafeGetBlob: true}),
+	}
+	assert.True(t, src.HasThreadSafeGetBlob())
+	infos, err := src.LayerInfosForCopy(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.Nil(t, infos)
+	sigs, err := src.GetSignatures(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.Nil(t, sigs)
+}
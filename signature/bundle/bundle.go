@@ -0,0 +1,234 @@
+// Package bundle exports all signatures of an image into a standalone bundle, and attaches signatures from such a bundle
+// to the same image at another location, to move trust material (e.g. across an air gap) separately from the image contents.
+//
+// A bundle contains all signatures returned by the source transport: simple signing signatures, and sigstore signatures
+// (including those stored as registry attachments, if enabled in registries.d).
+// Signatures which only exist as OCI referrers artifacts are not included, because image sources can not list referrers.
+package bundle
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/containers/image/v5/docker"
+	"github.com/containers/image/v5/internal/image"
+	"github.com/containers/image/v5/internal/imagedestination"
+	"github.com/containers/image/v5/internal/imagesource"
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/internal/signature"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/transports"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// bundleVersion is the only supported value of bundleFile.Version.
+const bundleVersion = 1
+
+// bundleFile is the serialized format of a bundle.
+type bundleFile struct {
+	Version int `json:"version"`
+	// Manifests contains the signatures of the top-level manifest first, followed by the instances of a multi-platform image, if any.
+	Manifests []manifestSignatures `json:"manifests"`
+}
+
+// manifestSignatures are the signatures of a single manifest.
+type manifestSignatures struct {
+	Digest     digest.Digest `json:"digest"`
+	Signatures [][]byte      `json:"signatures"` // In the format of internal/signature.Blob
+}
+
+// Export writes all signatures of the image at ref, and of all of its instances if it is a multi-platform image, as a bundle to w.
+func Export(ctx context.Context, sys *types.SystemContext, ref types.ImageReference, w io.Writer) (retErr error) {
+	publicSrc, err := ref.NewImageSource(ctx, sys)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := publicSrc.Close(); err != nil && retErr == nil {
+			retErr = fmt.Errorf("closing %s: %w", transports.ImageName(ref), err)
+		}
+	}()
+	src := imagesource.FromPublic(publicSrc)
+
+	_, manifestDigest, instances, err := readManifestAndInstances(ctx, src)
+	if err != nil {
+		return err
+	}
+
+	res := bundleFile{Version: bundleVersion}
+	for _, d := range append([]digest.Digest{manifestDigest}, instances...) {
+		var instanceDigest *digest.Digest
+		if d != manifestDigest {
+			instanceDigest = &d
+		}
+		sigs, err := src.GetSignaturesWithFormat(ctx, instanceDigest)
+		if err != nil {
+			return fmt.Errorf("reading signatures of %s: %w", d, err)
+		}
+		blobs := make([][]byte, 0, len(sigs))
+		for _, sig := range sigs {
+			blob, err := signature.Blob(sig)
+			if err != nil {
+				return err
+			}
+			blobs = append(blobs, blob)
+		}
+		res.Manifests = append(res.Manifests, manifestSignatures{Digest: d, Signatures: blobs})
+	}
+	return json.NewEncoder(w).Encode(res)
+}
+
+// Import reads a bundle created by Export from r, and attaches its signatures to the image at ref,
+// which must have the same manifest digest as the exported image. Signatures already present at ref are preserved.
+//
+// The image must already exist at ref; only the docker: transport, which can add signatures to an existing image, is supported.
+func Import(ctx context.Context, sys *types.SystemContext, ref types.ImageReference, r io.Reader) (retErr error) {
+	if ref.Transport().Name() != docker.Transport.Name() {
+		return fmt.Errorf("importing signature bundles to %s is not supported, only %s: is supported", transports.ImageName(ref), docker.Transport.Name())
+	}
+	bundle, err := readBundle(r)
+	if err != nil {
+		return err
+	}
+
+	publicSrc, err := ref.NewImageSource(ctx, sys)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := publicSrc.Close(); err != nil && retErr == nil {
+			retErr = fmt.Errorf("closing %s: %w", transports.ImageName(ref), err)
+		}
+	}()
+	src := imagesource.FromPublic(publicSrc)
+	manifestBlob, manifestDigest, instances, err := readManifestAndInstances(ctx, src)
+	if err != nil {
+		return err
+	}
+	if manifestDigest != bundle.Manifests[0].Digest {
+		return fmt.Errorf("image %s has manifest digest %s, the signature bundle is for %s", transports.ImageName(ref), manifestDigest, bundle.Manifests[0].Digest)
+	}
+	for _, m := range bundle.Manifests[1:] {
+		if !slices.Contains(instances, m.Digest) {
+			return fmt.Errorf("image %s does not contain instance %s, which the signature bundle contains signatures for", transports.ImageName(ref), m.Digest)
+		}
+	}
+
+	publicDest, err := ref.NewImageDestination(ctx, sys)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := publicDest.Close(); err != nil && retErr == nil {
+			retErr = fmt.Errorf("closing %s: %w", transports.ImageName(ref), err)
+		}
+	}()
+	dest := imagedestination.FromPublic(publicDest)
+	if err := dest.SupportsSignatures(ctx); err != nil {
+		return fmt.Errorf("can not attach signatures to %s: %w", transports.ImageName(ref), err)
+	}
+
+	// Instances first, so that the top-level manifest is written last, as in copy.Image.
+	for _, m := range bundle.Manifests[1:] {
+		instanceManifest, _, err := src.GetManifest(ctx, &m.Digest)
+		if err != nil {
+			return fmt.Errorf("reading manifest %s: %w", m.Digest, err)
+		}
+		if err := attachSignatures(ctx, src, dest, instanceManifest, &m.Digest, m.Signatures); err != nil {
+			return err
+		}
+	}
+	if err := attachSignatures(ctx, src, dest, manifestBlob, nil, bundle.Manifests[0].Signatures); err != nil {
+		return err
+	}
+	return dest.CommitWithOptions(ctx, private.CommitOptions{
+		UnparsedToplevel: image.UnparsedInstance(src, nil),
+	})
+}
+
+// readBundle reads and validates a bundle from r.
+func readBundle(r io.Reader) (*bundleFile, error) {
+	var bundle bundleFile
+	if err := json.NewDecoder(r).Decode(&bundle); err != nil {
+		return nil, fmt.Errorf("parsing signature bundle: %w", err)
+	}
+	if bundle.Version != bundleVersion {
+		return nil, fmt.Errorf("unsupported signature bundle version %d", bundle.Version)
+	}
+	if len(bundle.Manifests) == 0 {
+		return nil, errors.New("invalid signature bundle: no manifests")
+	}
+	for _, m := range bundle.Manifests {
+		if err := m.Digest.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid signature bundle: %w", err)
+		}
+	}
+	return &bundle, nil
+}
+
+// readManifestAndInstances returns the top-level manifest of src, its digest, and digests of its instances if it is a multi-platform image.
+func readManifestAndInstances(ctx context.Context, src private.ImageSource) ([]byte, digest.Digest, []digest.Digest, error) {
+	manifestBlob, mimeType, err := src.GetManifest(ctx, nil)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("reading manifest: %w", err)
+	}
+	manifestDigest, err := manifest.Digest(manifestBlob)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	var instances []digest.Digest
+	if manifest.MIMETypeIsMultiImage(mimeType) {
+		list, err := manifest.ListFromBlob(manifestBlob, mimeType)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		instances = list.Instances()
+	}
+	return manifestBlob, manifestDigest, instances, nil
+}
+
+// attachSignatures adds signatures (in the format of internal/signature.Blob) to the signatures of manifestBlob
+// (the top-level manifest if instanceDigest is nil) already present in src, and writes the result to dest.
+func attachSignatures(ctx context.Context, src private.ImageSource, dest private.ImageDestination, manifestBlob []byte, instanceDigest *digest.Digest, signatures [][]byte) error {
+	existing, err := src.GetSignaturesWithFormat(ctx, instanceDigest)
+	if err != nil {
+		return fmt.Errorf("reading existing signatures: %w", err)
+	}
+	existingBlobs := make([][]byte, 0, len(existing))
+	for _, sig := range existing {
+		blob, err := signature.Blob(sig)
+		if err != nil {
+			return err
+		}
+		existingBlobs = append(existingBlobs, blob)
+	}
+	merged := existing
+	for _, blob := range signatures {
+		if slices.ContainsFunc(existingBlobs, func(e []byte) bool { return bytes.Equal(e, blob) }) {
+			continue
+		}
+		sig, err := signature.FromBlob(blob)
+		if err != nil {
+			return fmt.Errorf("invalid signature in signature bundle: %w", err)
+		}
+		merged = append(merged, sig)
+		existingBlobs = append(existingBlobs, blob)
+	}
+	if len(merged) == len(existing) {
+		return nil // Nothing to do
+	}
+
+	if err := dest.PutManifest(ctx, manifestBlob, instanceDigest); err != nil {
+		return fmt.Errorf("writing manifest: %w", err)
+	}
+	if err := dest.PutSignaturesWithFormat(ctx, merged, instanceDigest); err != nil {
+		return fmt.Errorf("writing signatures: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package bundle
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/containers/image/v5/directory"
+	"github.com/containers/image/v5/docker"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRegistry returns a registry host:port serving manifestBlob as test/image:latest,
+// and storing signatures using the X-Registry-Supports-Signatures API extension.
+func newTestRegistry(t *testing.T, manifestBlob []byte, mimeType string) string {
+	manifestDigest := digest.FromBytes(manifestBlob)
+	var mutex sync.Mutex
+	signatures := []json.RawMessage{}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		switch {
+		case r.URL.Path == "/v2/":
+			w.Header().Set("X-Registry-Supports-Signatures", "1")
+			w.WriteHeader(http.StatusOK)
+		case r.URL.Path == "/v2/test/image/manifests/latest" || r.URL.Path == "/v2/test/image/manifests/"+manifestDigest.String():
+			if r.Method == http.MethodPut {
+				body, err := io.ReadAll(r.Body)
+				assert.NoError(t, err)
+				assert.Equal(t, manifestBlob, body)
+				w.WriteHeader(http.StatusCreated)
+				return
+			}
+			w.Header().Set("Content-Type", mimeType)
+			w.Header().Set("Docker-Content-Digest", manifestDigest.String())
+			_, err := w.Write(manifestBlob)
+			assert.NoError(t, err)
+		case r.URL.Path == "/extensions/v2/test/image/signatures/"+manifestDigest.String():
+			if r.Method == http.MethodPut {
+				body, err := io.ReadAll(r.Body)
+				assert.NoError(t, err)
+				signatures = append(signatures, body)
+				w.WriteHeader(http.StatusCreated)
+				return
+			}
+			err := json.NewEncoder(w).Encode(map[string]any{"signatures": signatures})
+			assert.NoError(t, err)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return strings.TrimPrefix(s.URL, "http://")
+}
+
+func TestExportImport(t *testing.T) {
+	const fixture = "../fixtures/dir-img-valid"
+	manifestBlob, err := os.ReadFile(filepath.Join(fixture, "manifest.json"))
+	require.NoError(t, err)
+	signatureBlob, err := os.ReadFile(filepath.Join(fixture, "signature-1"))
+	require.NoError(t, err)
+
+	tmpDir := t.TempDir()
+	registriesConf := filepath.Join(tmpDir, "registries.conf")
+	err = os.WriteFile(registriesConf, []byte{}, 0o600)
+	require.NoError(t, err)
+	sys := &types.SystemContext{
+		SystemRegistriesConfPath:    registriesConf,
+		RegistriesDirPath:           tmpDir,
+		AuthFilePath:                filepath.Join(tmpDir, "auth.json"),
+		DockerInsecureSkipTLSVerify: types.OptionalBoolTrue,
+	}
+
+	srcRef, err := directory.NewReference(fixture)
+	require.NoError(t, err)
+	var exported bytes.Buffer
+	err = Export(context.Background(), sys, srcRef, &exported)
+	require.NoError(t, err)
+	bundle, err := readBundle(bytes.NewReader(exported.Bytes()))
+	require.NoError(t, err)
+	require.Len(t, bundle.Manifests, 1)
+	assert.Equal(t, digest.FromBytes(manifestBlob), bundle.Manifests[0].Digest)
+	assert.Equal(t, [][]byte{signatureBlob}, bundle.Manifests[0].Signatures)
+
+	registry := newTestRegistry(t, manifestBlob, "application/vnd.docker.distribution.manifest.v2+json")
+	destRef, err := docker.ParseReference("//" + registry + "/test/image:latest")
+	require.NoError(t, err)
+	// Importing twice does not duplicate signatures.
+	for range 2 {
+		err = Import(context.Background(), sys, destRef, bytes.NewReader(exported.Bytes()))
+		require.NoError(t, err)
+	}
+	var reexported bytes.Buffer
+	err = Export(context.Background(), sys, destRef, &reexported)
+	require.NoError(t, err)
+	assert.JSONEq(t, exported.String(), reexported.String())
+
+	// Bundles for a different image are rejected.
+	otherManifestRegistry := newTestRegistry(t, []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json"}`), "application/vnd.oci.image.manifest.v1+json")
+	otherRef, err := docker.ParseReference("//" + otherManifestRegistry + "/test/image:latest")
+	require.NoError(t, err)
+	err = Import(context.Background(), sys, otherRef, bytes.NewReader(exported.Bytes()))
+	assert.Error(t, err)
+
+	// Transports other than docker: are rejected.
+	err = Import(context.Background(), sys, srcRef, bytes.NewReader(exported.Bytes()))
+	assert.Error(t, err)
+}
+
+func TestReadBundle(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"not JSON",
+		`{"version":2,"manifests":[{"digest":"sha256:0000000000000000000000000000000000000000000000000000000000000000"}]}`,
+		`{"version":1,"manifests":[]}`,
+		`{"version":1,"manifests":[{"digest":"invalid"}]}`,
+	} {
+		_, err := readBundle(strings.NewReader(input))
+		assert.Error(t, err, input)
+	}
+
+	res, err := readBundle(strings.NewReader(`{"version":1,"manifests":[{"digest":"sha256:0000000000000000000000000000000000000000000000000000000000000000","signatures":["AA=="]}]}`))
+	require.NoError(t, err)
+	assert.Equal(t, &bundleFile{
+		Version: 1,
+		Manifests: []manifestSignatures{{
+			Digest:     "sha256:0000000000000000000000000000000000000000000000000000000000000000",
+			Signatures: [][]byte{{0}},
+		}},
+	}, res)
+}
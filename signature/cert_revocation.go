@@ -0,0 +1,292 @@
+package signature
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containers/image/v5/signature/internal"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ocsp"
+)
+
+const (
+	// crlFetchTimeout is the timeout for downloading a single CRL.
+	crlFetchTimeout = 30 * time.Second
+	// maxCRLSize is the maximum size of a downloaded CRL.
+	maxCRLSize = 16 * 1024 * 1024
+	// defaultCRLCacheDuration is used for fetched CRLs which do not specify a nextUpdate time.
+	defaultCRLCacheDuration = 1 * time.Hour
+)
+
+// crlHTTPClient is used to fetch CRLs; it is a variable to allow tests to replace it.
+var crlHTTPClient = &http.Client{Timeout: crlFetchTimeout}
+
+// fetchedCRLs caches CRLs fetched from CRL distribution points, process-wide.
+var fetchedCRLs = crlCache{entries: map[string]crlCacheEntry{}}
+
+// crlCache is a cache of CRLs, indexed by URL.
+type crlCache struct {
+	mutex   sync.Mutex // Protects entries
+	entries map[string]crlCacheEntry
+}
+
+type crlCacheEntry struct {
+	crl     *x509.RevocationList
+	expires time.Time
+}
+
+// get returns the CRL at url, either from the cache or by fetching it.
+func (c *crlCache) get(url string) (*x509.RevocationList, error) {
+	now := time.Now()
+	c.mutex.Lock()
+	entry, ok := c.entries[url]
+	c.mutex.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.crl, nil
+	}
+
+	crl, err := fetchCRL(url)
+	if err != nil {
+		return nil, err
+	}
+	expires := crl.NextUpdate
+	if expires.IsZero() {
+		expires = now.Add(defaultCRLCacheDuration)
+	}
+	c.mutex.Lock()
+	c.entries[url] = crlCacheEntry{crl: crl, expires: expires}
+	c.mutex.Unlock()
+	return crl, nil
+}
+
+// fetchCRL downloads and parses a CRL from url.
+func fetchCRL(url string) (*x509.RevocationList, error) {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("unsupported CRL distribution point %q", url)
+	}
+	logrus.Debugf("Fetching CRL from %s", url)
+	res, err := crlHTTPClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("fetching CRL from %s: %w", url, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching CRL from %s: status %d (%s)", url, res.StatusCode, http.StatusText(res.StatusCode))
+	}
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxCRLSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading CRL from %s: %w", url, err)
+	}
+	if len(data) > maxCRLSize {
+		return nil, fmt.Errorf("CRL from %s is too large", url)
+	}
+	crls, err := parseCRLs(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing CRL from %s: %w", url, err)
+	}
+	if len(crls) != 1 {
+		return nil, fmt.Errorf("expected exactly one CRL at %s, got %d", url, len(crls))
+	}
+	return crls[0], nil
+}
+
+// parseCRLs parses data, containing either a single DER-encoded CRL, or any number of PEM-encoded CRLs.
+func parseCRLs(data []byte) ([]*x509.RevocationList, error) {
+	if !bytes.Contains(data, []byte("-----BEGIN")) {
+		crl, err := x509.ParseRevocationList(data)
+		if err != nil {
+			return nil, err
+		}
+		return []*x509.RevocationList{crl}, nil
+	}
+	res := []*x509.RevocationList{}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "X509 CRL" {
+			continue
+		}
+		crl, err := x509.ParseRevocationList(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, crl)
+	}
+	if len(res) == 0 {
+		return nil, errors.New("no CRL found in PEM data")
+	}
+	return res, nil
+}
+
+// revocationChecker checks whether certificates in a verified chain have been revoked.
+type revocationChecker struct {
+	softFail          bool
+	fetchCRLs         bool
+	localCRLs         []*x509.RevocationList
+	acceptStapledOCSP bool
+}
+
+// prepareRevocationChecker creates a revocationChecker from the input data.
+// (This also prevents external implementations of this interface, ensuring that prSigstoreSignedRevocation is the only one.)
+func (r *prSigstoreSignedRevocation) prepareRevocationChecker() (*revocationChecker, error) {
+	res := revocationChecker{
+		softFail:          r.FailureMode == revocationFailureModeSoft,
+		fetchCRLs:         r.FetchCRLs,
+		acceptStapledOCSP: r.AcceptStapledOCSP,
+	}
+	for _, path := range r.CRLPaths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		crls, err := parseCRLs(data)
+		if err != nil {
+			return nil, fmt.Errorf("parsing CRLs in %q: %w", path, err)
+		}
+		res.localCRLs = append(res.localCRLs, crls...)
+	}
+	return &res, nil
+}
+
+// checkChains verifies that at least one of the verified certificate chains, as returned by x509.Certificate.Verify,
+// does not contain a revoked certificate.
+// untrustedOCSPResponse, if not empty, is an OCSP response for the leaf certificate stapled to the signature.
+func (c *revocationChecker) checkChains(chains [][]*x509.Certificate, untrustedOCSPResponse []byte) error {
+	var firstErr error
+	for _, chain := range chains {
+		err := c.checkChain(chain, untrustedOCSPResponse)
+		if err == nil {
+			return nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr == nil { // Coverage: x509.Certificate.Verify always returns at least one chain on success.
+		return errors.New("Internal error: no verified certificate chain")
+	}
+	return firstErr
+}
+
+// checkChain verifies that chain, starting with the leaf certificate and ending with a trusted root, does not contain
+// a revoked certificate.
+func (c *revocationChecker) checkChain(chain []*x509.Certificate, untrustedOCSPResponse []byte) error {
+	now := time.Now()
+	// The root certificate is trusted by definition, revoking it is a matter of updating the policy.
+	for i := 0; i < len(chain)-1; i++ {
+		cert, issuer := chain[i], chain[i+1]
+		var stapledOCSP []byte
+		if i == 0 {
+			stapledOCSP = untrustedOCSPResponse
+		}
+		known, err := c.checkCertificate(now, cert, issuer, stapledOCSP)
+		if err != nil {
+			return err
+		}
+		if !known {
+			msg := fmt.Sprintf("revocation status of certificate %q (serial %s) can not be determined", cert.Subject.String(), cert.SerialNumber.String())
+			if !c.softFail {
+				return internal.NewInvalidSignatureError(msg)
+			}
+			logrus.Warnf("%s, accepting it anyway", msg)
+		}
+	}
+	return nil
+}
+
+// checkCertificate checks whether cert, issued by issuer, has been revoked.
+// It returns an error if cert is known to be revoked, and false if its revocation status can not be determined.
+func (c *revocationChecker) checkCertificate(now time.Time, cert, issuer *x509.Certificate, untrustedOCSPResponse []byte) (bool, error) {
+	if c.acceptStapledOCSP && len(untrustedOCSPResponse) > 0 {
+		known, err := checkStapledOCSP(now, cert, issuer, untrustedOCSPResponse)
+		if err != nil {
+			return false, err
+		}
+		if known {
+			return true, nil
+		}
+	}
+
+	known := false
+	for _, crl := range c.localCRLs {
+		usable, err := checkCRL(now, cert, issuer, crl)
+		if err != nil {
+			return false, err
+		}
+		known = known || usable
+	}
+	if c.fetchCRLs {
+		for _, url := range cert.CRLDistributionPoints {
+			crl, err := fetchedCRLs.get(url)
+			if err != nil {
+				logrus.Debugf("Ignoring CRL distribution point: %v", err)
+				continue
+			}
+			usable, err := checkCRL(now, cert, issuer, crl)
+			if err != nil {
+				return false, err
+			}
+			known = known || usable
+		}
+	}
+	return known, nil
+}
+
+// checkStapledOCSP checks whether untrustedOCSPResponse reports cert, issued by issuer, as revoked.
+// It returns an error if cert is known to be revoked, and false if the response can not be used to determine its revocation status.
+func checkStapledOCSP(now time.Time, cert, issuer *x509.Certificate, untrustedOCSPResponse []byte) (bool, error) {
+	resp, err := ocsp.ParseResponseForCert(untrustedOCSPResponse, cert, issuer)
+	if err != nil {
+		logrus.Debugf("Ignoring stapled OCSP response: %v", err)
+		return false, nil
+	}
+	if now.Before(resp.ThisUpdate) || (!resp.NextUpdate.IsZero() && now.After(resp.NextUpdate)) {
+		logrus.Debugf("Ignoring stapled OCSP response valid from %v to %v", resp.ThisUpdate, resp.NextUpdate)
+		return false, nil
+	}
+	switch resp.Status {
+	case ocsp.Good:
+		return true, nil
+	case ocsp.Revoked:
+		return false, internal.NewInvalidSignatureError(fmt.Sprintf("certificate %q (serial %s) was revoked at %v",
+			cert.Subject.String(), cert.SerialNumber.String(), resp.RevokedAt))
+	default:
+		return false, nil
+	}
+}
+
+// checkCRL checks whether crl reports cert, issued by issuer, as revoked.
+// It returns an error if cert is known to be revoked, and false if crl can not be used to determine its revocation status
+// (e.g. because it was issued by a different CA, or because it is out of date).
+func checkCRL(now time.Time, cert, issuer *x509.Certificate, crl *x509.RevocationList) (bool, error) {
+	if !bytes.Equal(crl.RawIssuer, cert.RawIssuer) {
+		return false, nil
+	}
+	if err := crl.CheckSignatureFrom(issuer); err != nil {
+		logrus.Debugf("Ignoring CRL with an invalid signature: %v", err)
+		return false, nil
+	}
+	if now.Before(crl.ThisUpdate) || (!crl.NextUpdate.IsZero() && now.After(crl.NextUpdate)) {
+		logrus.Debugf("Ignoring CRL valid from %v to %v", crl.ThisUpdate, crl.NextUpdate)
+		return false, nil
+	}
+	for _, entry := range crl.RevokedCertificateEntries {
+		if entry.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+			return false, internal.NewInvalidSignatureError(fmt.Sprintf("certificate %q (serial %s) was revoked at %v",
+				cert.Subject.String(), cert.SerialNumber.String(), entry.RevocationTime))
+		}
+	}
+	return true, nil
+}
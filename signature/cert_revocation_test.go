@@ -0,0 +1,255 @@
+package signature
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ocsp"
+)
+
+// revocationTestPKI is a CA and a leaf certificate issued by it, for testing revocation checks.
+type revocationTestPKI struct {
+	caKey     *ecdsa.PrivateKey
+	caCert    *x509.Certificate
+	caPool    *x509.CertPool
+	leafCert  *x509.Certificate
+	leafPEM   []byte
+	crlNumber int64
+}
+
+func newRevocationTestPKI(t *testing.T, crlDistributionPoints []string) *revocationTestPKI {
+	now := time.Now()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caSN, err := cryptoutils.GenerateSerialNumber()
+	require.NoError(t, err)
+	caContents := x509.Certificate{
+		SerialNumber:          caSN,
+		Subject:               pkix.Name{CommonName: "root CA"},
+		NotBefore:             now.Add(-1 * time.Minute),
+		NotAfter:              now.Add(1 * time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+	}
+	caCertBytes, err := x509.CreateCertificate(rand.Reader, &caContents, &caContents, caKey.Public(), caKey)
+	require.NoError(t, err)
+	caCert, err := x509.ParseCertificate(caCertBytes)
+	require.NoError(t, err)
+	caPool := x509.NewCertPool()
+	caPool.AddCert(caCert)
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	leafSN, err := cryptoutils.GenerateSerialNumber()
+	require.NoError(t, err)
+	leafContents := x509.Certificate{
+		SerialNumber:          leafSN,
+		Subject:               pkix.Name{CommonName: "leaf"},
+		NotBefore:             now.Add(-1 * time.Minute),
+		NotAfter:              now.Add(1 * time.Hour),
+		EmailAddresses:        []string{"test-user@example.com"},
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		CRLDistributionPoints: crlDistributionPoints,
+	}
+	leafCertBytes, err := x509.CreateCertificate(rand.Reader, &leafContents, caCert, leafKey.Public(), caKey)
+	require.NoError(t, err)
+	leafCert, err := x509.ParseCertificate(leafCertBytes)
+	require.NoError(t, err)
+	return &revocationTestPKI{
+		caKey:    caKey,
+		caCert:   caCert,
+		caPool:   caPool,
+		leafCert: leafCert,
+		leafPEM:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafCertBytes}),
+	}
+}
+
+// crl returns a DER-encoded CRL revoking the specified serial numbers.
+func (p *revocationTestPKI) crl(t *testing.T, nextUpdate time.Time, revoked ...*big.Int) []byte {
+	p.crlNumber++
+	template := x509.RevocationList{
+		Number:     big.NewInt(p.crlNumber),
+		ThisUpdate: time.Now().Add(-1 * time.Minute),
+		NextUpdate: nextUpdate,
+	}
+	for _, sn := range revoked {
+		template.RevokedCertificateEntries = append(template.RevokedCertificateEntries, x509.RevocationListEntry{
+			SerialNumber:   sn,
+			RevocationTime: time.Now().Add(-1 * time.Minute),
+		})
+	}
+	crl, err := x509.CreateRevocationList(rand.Reader, &template, p.caCert, p.caKey)
+	require.NoError(t, err)
+	return crl
+}
+
+// ocspResponse returns a DER-encoded OCSP response for the leaf certificate.
+func (p *revocationTestPKI) ocspResponse(t *testing.T, status int) []byte {
+	resp, err := ocsp.CreateResponse(p.caCert, p.caCert, ocsp.Response{
+		Status:       status,
+		SerialNumber: p.leafCert.SerialNumber,
+		ThisUpdate:   time.Now().Add(-1 * time.Minute),
+		NextUpdate:   time.Now().Add(1 * time.Hour),
+		RevokedAt:    time.Now().Add(-1 * time.Minute),
+	}, crypto.Signer(p.caKey))
+	require.NoError(t, err)
+	return resp
+}
+
+func (p *revocationTestPKI) verify(revocation *revocationChecker, untrustedOCSPResponse []byte) error {
+	_, err := verifyPKI(&pkiTrustRoot{
+		caRootsCertificates: p.caPool,
+		subjectEmail:        "test-user@example.com",
+		revocation:          revocation,
+	}, p.leafPEM, nil, untrustedOCSPResponse)
+	return err
+}
+
+func TestPRSigstoreSignedRevocationPrepareRevocationChecker(t *testing.T) {
+	p := newRevocationTestPKI(t, nil)
+	dir := t.TempDir()
+	derPath := filepath.Join(dir, "crl.der")
+	err := os.WriteFile(derPath, p.crl(t, time.Now().Add(time.Hour)), 0o600)
+	require.NoError(t, err)
+	pemPath := filepath.Join(dir, "crl.pem")
+	pemData := append(pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: p.crl(t, time.Now().Add(time.Hour))}),
+		pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: p.crl(t, time.Now().Add(time.Hour))})...)
+	err = os.WriteFile(pemPath, pemData, 0o600)
+	require.NoError(t, err)
+
+	r := prSigstoreSignedRevocation{FailureMode: revocationFailureModeSoft, CRLPaths: []string{derPath, pemPath}, AcceptStapledOCSP: true}
+	c, err := r.prepareRevocationChecker()
+	require.NoError(t, err)
+	assert.True(t, c.softFail)
+	assert.False(t, c.fetchCRLs)
+	assert.True(t, c.acceptStapledOCSP)
+	assert.Len(t, c.localCRLs, 3)
+
+	// Missing and invalid files
+	invalidPath := filepath.Join(dir, "invalid.crl")
+	err = os.WriteFile(invalidPath, []byte("not a CRL"), 0o600)
+	require.NoError(t, err)
+	for _, path := range []string{filepath.Join(dir, "missing.crl"), invalidPath} {
+		r := prSigstoreSignedRevocation{CRLPaths: []string{path}}
+		_, err := r.prepareRevocationChecker()
+		assert.Error(t, err, path)
+	}
+}
+
+func TestRevocationCheckerLocalCRLs(t *testing.T) {
+	p := newRevocationTestPKI(t, nil)
+	otherPKI := newRevocationTestPKI(t, nil)
+	for _, c := range []struct {
+		name     string
+		crl      []byte
+		softFail bool
+		success  bool
+	}{
+		{"not revoked", p.crl(t, time.Now().Add(time.Hour)), false, true},
+		{"revoked", p.crl(t, time.Now().Add(time.Hour), p.leafCert.SerialNumber), false, false},
+		{"revoked, soft fail", p.crl(t, time.Now().Add(time.Hour), p.leafCert.SerialNumber), true, false},
+		{"expired CRL", p.crl(t, time.Now().Add(-30*time.Second)), false, false},
+		{"expired CRL, soft fail", p.crl(t, time.Now().Add(-30*time.Second)), true, true},
+		{"CRL from another CA", otherPKI.crl(t, time.Now().Add(time.Hour), p.leafCert.SerialNumber), false, false},
+		{"CRL from another CA, soft fail", otherPKI.crl(t, time.Now().Add(time.Hour), p.leafCert.SerialNumber), true, true},
+	} {
+		crl, err := x509.ParseRevocationList(c.crl)
+		require.NoError(t, err, c.name)
+		err = p.verify(&revocationChecker{softFail: c.softFail, localCRLs: []*x509.RevocationList{crl}}, nil)
+		if c.success {
+			assert.NoError(t, err, c.name)
+		} else {
+			assert.Error(t, err, c.name)
+		}
+	}
+}
+
+func TestRevocationCheckerFetchCRLs(t *testing.T) {
+	var requests atomic.Int64
+	var crl []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Path != "/ca.crl" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(crl)
+	}))
+	defer server.Close()
+
+	p := newRevocationTestPKI(t, []string{server.URL + "/ca.crl"})
+	crl = p.crl(t, time.Now().Add(time.Hour))
+	c := &revocationChecker{fetchCRLs: true}
+	err := p.verify(c, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), requests.Load())
+	// The CRL is cached
+	err = p.verify(c, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), requests.Load())
+
+	// An expired cache entry is refreshed
+	fetchedCRLs.mutex.Lock()
+	entry := fetchedCRLs.entries[server.URL+"/ca.crl"]
+	entry.expires = time.Now().Add(-1 * time.Second)
+	fetchedCRLs.entries[server.URL+"/ca.crl"] = entry
+	fetchedCRLs.mutex.Unlock()
+	crl = p.crl(t, time.Now().Add(time.Hour), p.leafCert.SerialNumber)
+	err = p.verify(c, nil)
+	assert.ErrorContains(t, err, "was revoked")
+	assert.Equal(t, int64(2), requests.Load())
+
+	// Unavailable CRLs
+	p = newRevocationTestPKI(t, []string{server.URL + "/missing.crl"})
+	err = p.verify(&revocationChecker{fetchCRLs: true}, nil)
+	assert.ErrorContains(t, err, "can not be determined")
+	err = p.verify(&revocationChecker{fetchCRLs: true, softFail: true}, nil)
+	assert.NoError(t, err)
+}
+
+func TestRevocationCheckerStapledOCSP(t *testing.T) {
+	p := newRevocationTestPKI(t, nil)
+	otherPKI := newRevocationTestPKI(t, nil)
+	for _, c := range []struct {
+		name          string
+		response      []byte
+		acceptStapled bool
+		success       bool
+	}{
+		{"good", p.ocspResponse(t, ocsp.Good), true, true},
+		{"good, not accepted", p.ocspResponse(t, ocsp.Good), false, false},
+		{"revoked", p.ocspResponse(t, ocsp.Revoked), true, false},
+		{"unknown", p.ocspResponse(t, ocsp.Unknown), true, false},
+		{"missing", nil, true, false},
+		{"invalid", []byte("not an OCSP response"), true, false},
+		{"signed by another CA", otherPKI.ocspResponse(t, ocsp.Good), true, false},
+	} {
+		err := p.verify(&revocationChecker{acceptStapledOCSP: c.acceptStapled}, c.response)
+		if c.success {
+			assert.NoError(t, err, c.name)
+		} else {
+			assert.Error(t, err, c.name)
+		}
+	}
+
+	// A revoked response is rejected even in soft-fail mode
+	err := p.verify(&revocationChecker{acceptStapledOCSP: true, softFail: true}, p.ocspResponse(t, ocsp.Revoked))
+	assert.ErrorContains(t, err, "was revoked")
+}
@@ -18,6 +18,11 @@ import (
 type SignOptions struct {
 	// Passphare to use when signing with the key identity.
 	Passphrase string
+	// If Deterministic is set, the signed payload does not include the optional creator (including the version of this library)
+	// and timestamp fields, so that signing the same manifest as the same dockerReference always signs a byte-identical payload,
+	// e.g. to allow deduplicating stored signatures by their payload.
+	// Note that the signatures themselves typically still differ, because signing mechanisms record the signing time.
+	Deterministic bool
 }
 
 // SignDockerManifest returns a signature for manifest as the specified dockerReference,
@@ -36,6 +41,10 @@ func SignDockerManifestWithOptions(m []byte, dockerReference string, mech Signin
 		if strings.Contains(passphrase, "\n") {
 			return nil, errors.New("invalid passphrase: must not contain a line break")
 		}
+		if options.Deterministic {
+			sig.untrustedCreatorID = nil
+			sig.untrustedTimestamp = nil
+		}
 	}
 
 	return sig.sign(mech, keyIdentity, passphrase)
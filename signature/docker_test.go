@@ -96,6 +96,39 @@ func TestSignDockerManifestWithPassphrase(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestSignDockerManifestDeterministic(t *testing.T) {
+	mech, err := newGPGSigningMechanismInDirectory(testGPGHomeDirectory)
+	require.NoError(t, err)
+	defer mech.Close()
+
+	if err := mech.SupportsSigning(); err != nil {
+		t.Skipf("Signing not supported: %v", err)
+	}
+
+	manifest, err := os.ReadFile("fixtures/image.manifest.json")
+	require.NoError(t, err)
+
+	payloads := [][]byte{}
+	for range 2 {
+		signature, err := SignDockerManifestWithOptions(manifest, TestImageSignatureReference, mech, TestKeyFingerprint, &SignOptions{Deterministic: true})
+		require.NoError(t, err)
+		verified, err := VerifyDockerManifestSignature(signature, manifest, TestImageSignatureReference, mech, TestKeyFingerprint)
+		require.NoError(t, err)
+		assert.Equal(t, TestImageManifestDigest, verified.DockerManifestDigest)
+
+		info, err := GetUntrustedSignatureInformationWithoutVerifying(signature)
+		require.NoError(t, err)
+		assert.Nil(t, info.UntrustedCreatorID)
+		assert.Nil(t, info.UntrustedTimestamp)
+		payload, _, err := mech.Verify(signature)
+		require.NoError(t, err)
+		payloads = append(payloads, payload)
+	}
+	assert.Equal(t, payloads[0], payloads[1])
+	assert.Equal(t, `{"critical":{"identity":{"docker-reference":"`+TestImageSignatureReference+`"},"image":{"docker-manifest-digest":"`+
+		TestImageManifestDigest.String()+`"},"type":"atomic container signature"},"optional":{}}`, string(payloads[0]))
+}
+
 func TestVerifyDockerManifestSignature(t *testing.T) {
 	mech, err := newGPGSigningMechanismInDirectory(testGPGHomeDirectory)
 	require.NoError(t, err)
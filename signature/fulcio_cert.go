@@ -23,6 +23,7 @@ type fulcioTrustRoot struct {
 	caCertificates *x509.CertPool
 	oidcIssuer     string
 	subjectEmail   string
+	revocation     *revocationChecker // nil if revocation is not checked
 }
 
 func (f *fulcioTrustRoot) validate() error {
@@ -87,7 +88,7 @@ func fulcioIssuerInCertificate(untrustedCertificate *x509.Certificate) (string,
 	}
 }
 
-func (f *fulcioTrustRoot) verifyFulcioCertificateAtTime(relevantTime time.Time, untrustedCertificateBytes []byte, untrustedIntermediateChainBytes []byte) (crypto.PublicKey, error) {
+func (f *fulcioTrustRoot) verifyFulcioCertificateAtTime(relevantTime time.Time, untrustedCertificateBytes []byte, untrustedIntermediateChainBytes []byte, untrustedOCSPResponse []byte) (crypto.PublicKey, error) {
 	// == Verify the certificate is correctly signed
 	var untrustedIntermediatePool *x509.CertPool // = nil
 	// untrustedCertificateChainPool.AppendCertsFromPEM does something broadly similar,
@@ -124,7 +125,7 @@ func (f *fulcioTrustRoot) verifyFulcioCertificateAtTime(relevantTime time.Time,
 		untrustedCertificate.UnhandledCriticalExtensions = remaining
 	}
 
-	if _, err := untrustedCertificate.Verify(x509.VerifyOptions{
+	chains, err := untrustedCertificate.Verify(x509.VerifyOptions{
 		Intermediates: untrustedIntermediatePool,
 		Roots:         f.caCertificates,
 		// NOTE: Cosign uses untrustedCertificate.NotBefore here (i.e. uses _that_ time for intermediate certificate validation),
@@ -133,7 +134,8 @@ func (f *fulcioTrustRoot) verifyFulcioCertificateAtTime(relevantTime time.Time,
 		// Assuming the certificate is fulcio-generated and very short-lived, that should make little difference.
 		CurrentTime: relevantTime,
 		KeyUsages:   []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
-	}); err != nil {
+	})
+	if err != nil {
 		return nil, internal.NewInvalidSignatureError(fmt.Sprintf("veryfing leaf certificate failed: %v", err))
 	}
 
@@ -182,6 +184,15 @@ func (f *fulcioTrustRoot) verifyFulcioCertificateAtTime(relevantTime time.Time,
 	// FIXME: How far into Turing-completeness for the issuer/subject do we need to get? Simultaneously accepted alternatives, for
 	// issuers and/or subjects and/or combinations? Regexps? More?
 
+	// == Check revocation
+	// Note that this is evaluated at the current time, not at relevantTime: a certificate revoked after the signature was created
+	// is rejected.
+	if f.revocation != nil {
+		if err := f.revocation.checkChains(chains, untrustedOCSPResponse); err != nil {
+			return nil, err
+		}
+	}
+
 	return untrustedCertificate.PublicKey, nil
 }
 
@@ -202,11 +213,11 @@ func parseLeafCertFromPEM(untrustedCertificateBytes []byte) (*x509.Certificate,
 
 func verifyRekorFulcio(rekorPublicKeys []*ecdsa.PublicKey, fulcioTrustRoot *fulcioTrustRoot, untrustedRekorSET []byte,
 	untrustedCertificateBytes []byte, untrustedIntermediateChainBytes []byte, untrustedBase64Signature string,
-	untrustedPayloadBytes []byte) (crypto.PublicKey, error) {
+	untrustedPayloadBytes []byte, untrustedOCSPResponse []byte) (crypto.PublicKey, error) {
 	rekorSETTime, err := internal.VerifyRekorSET(rekorPublicKeys, untrustedRekorSET, untrustedCertificateBytes,
 		untrustedBase64Signature, untrustedPayloadBytes)
 	if err != nil {
 		return nil, err
 	}
-	return fulcioTrustRoot.verifyFulcioCertificateAtTime(rekorSETTime, untrustedCertificateBytes, untrustedIntermediateChainBytes)
+	return fulcioTrustRoot.verifyFulcioCertificateAtTime(rekorSETTime, untrustedCertificateBytes, untrustedIntermediateChainBytes, untrustedOCSPResponse)
 }
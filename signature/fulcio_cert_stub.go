@@ -13,6 +13,7 @@ type fulcioTrustRoot struct {
 	caCertificates *x509.CertPool
 	oidcIssuer     string
 	subjectEmail   string
+	revocation     *revocationChecker
 }
 
 func (f *fulcioTrustRoot) validate() error {
@@ -21,7 +22,7 @@ func (f *fulcioTrustRoot) validate() error {
 
 func verifyRekorFulcio(rekorPublicKeys []*ecdsa.PublicKey, fulcioTrustRoot *fulcioTrustRoot, untrustedRekorSET []byte,
 	untrustedCertificateBytes []byte, untrustedIntermediateChainBytes []byte, untrustedBase64Signature string,
-	untrustedPayloadBytes []byte) (crypto.PublicKey, error) {
+	untrustedPayloadBytes []byte, untrustedOCSPResponse []byte) (crypto.PublicKey, error) {
 	return nil, errors.New("fulcio disabled at compile-time")
 
 }
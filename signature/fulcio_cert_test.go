@@ -248,22 +248,22 @@ func TestFulcioTrustRootVerifyFulcioCertificateAtTime(t *testing.T) {
 		oidcIssuer:     "https://github.com/login/oauth",
 		subjectEmail:   "mitr@redhat.com",
 	}
-	pk, err := tr.verifyFulcioCertificateAtTime(time.Unix(1670870899, 0), fulcioCertBytes, fulcioChainBytes)
+	pk, err := tr.verifyFulcioCertificateAtTime(time.Unix(1670870899, 0), fulcioCertBytes, fulcioChainBytes, nil)
 	require.NoError(t, err)
 	assertPublicKeyMatchesCert(t, fulcioCertBytes, pk)
 
 	// Invalid intermediate certificates
-	pk, err = tr.verifyFulcioCertificateAtTime(time.Unix(1670870899, 0), fulcioCertBytes, []byte("not a certificate"))
+	pk, err = tr.verifyFulcioCertificateAtTime(time.Unix(1670870899, 0), fulcioCertBytes, []byte("not a certificate"), nil)
 	assert.Error(t, err)
 	assert.Nil(t, pk)
 
 	// Invalid leaf certificate
-	pk, err = tr.verifyFulcioCertificateAtTime(time.Unix(1670870899, 0), []byte("not a certificate"), fulcioChainBytes)
+	pk, err = tr.verifyFulcioCertificateAtTime(time.Unix(1670870899, 0), []byte("not a certificate"), fulcioChainBytes, nil)
 	assert.Error(t, err)
 	assert.Nil(t, pk)
 
 	// No intermediate certificates: verification fails as is …
-	pk, err = tr.verifyFulcioCertificateAtTime(time.Unix(1670870899, 0), fulcioCertBytes, []byte{})
+	pk, err = tr.verifyFulcioCertificateAtTime(time.Unix(1670870899, 0), fulcioCertBytes, []byte{}, nil)
 	assert.Error(t, err)
 	assert.Nil(t, pk)
 	// … but succeeds if we add the intermediate certificates to the root of trust
@@ -275,7 +275,7 @@ func TestFulcioTrustRootVerifyFulcioCertificateAtTime(t *testing.T) {
 		oidcIssuer:     "https://github.com/login/oauth",
 		subjectEmail:   "mitr@redhat.com",
 	}
-	pk, err = trWithIntermediates.verifyFulcioCertificateAtTime(time.Unix(1670870899, 0), fulcioCertBytes, []byte{})
+	pk, err = trWithIntermediates.verifyFulcioCertificateAtTime(time.Unix(1670870899, 0), fulcioCertBytes, []byte{}, nil)
 	require.NoError(t, err)
 	assertPublicKeyMatchesCert(t, fulcioCertBytes, pk)
 
@@ -284,7 +284,7 @@ func TestFulcioTrustRootVerifyFulcioCertificateAtTime(t *testing.T) {
 		time.Date(2022, time.December, 12, 18, 48, 17, 0, time.UTC),
 		time.Date(2022, time.December, 12, 18, 58, 19, 0, time.UTC),
 	} {
-		pk, err := tr.verifyFulcioCertificateAtTime(tm, fulcioCertBytes, fulcioChainBytes)
+		pk, err := tr.verifyFulcioCertificateAtTime(tm, fulcioCertBytes, fulcioChainBytes, nil)
 		assert.Error(t, err)
 		assert.Nil(t, pk)
 	}
@@ -427,7 +427,7 @@ func TestFulcioTrustRootVerifyFulcioCertificateAtTime(t *testing.T) {
 			Type:  "CERTIFICATE",
 			Bytes: testLeafCert,
 		})
-		pk, err := tr.verifyFulcioCertificateAtTime(referenceTime, testLeafPEM, []byte{})
+		pk, err := tr.verifyFulcioCertificateAtTime(referenceTime, testLeafPEM, []byte{}, nil)
 		if c.errorFragment == "" {
 			require.NoError(t, err, c.name)
 			assertPublicKeyMatchesCert(t, testLeafPEM, pk)
@@ -467,7 +467,7 @@ func TestVerifyRekorFulcio(t *testing.T) {
 		caCertificates: caCertificates,
 		oidcIssuer:     "https://github.com/login/oauth",
 		subjectEmail:   "mitr@redhat.com",
-	}, setBytes, certBytes, chainBytes, string(sigBase64), payloadBytes)
+	}, setBytes, certBytes, chainBytes, string(sigBase64), payloadBytes, nil)
 	require.NoError(t, err)
 	assertPublicKeyMatchesCert(t, certBytes, pk)
 
@@ -476,7 +476,7 @@ func TestVerifyRekorFulcio(t *testing.T) {
 		caCertificates: caCertificates,
 		oidcIssuer:     "https://github.com/login/oauth",
 		subjectEmail:   "mitr@redhat.com",
-	}, setBytes, certBytes, chainBytes, string(sigBase64), []byte("this payload does not match"))
+	}, setBytes, certBytes, chainBytes, string(sigBase64), []byte("this payload does not match"), nil)
 	assert.Error(t, err)
 	assert.Nil(t, pk)
 
@@ -485,7 +485,7 @@ func TestVerifyRekorFulcio(t *testing.T) {
 		caCertificates: caCertificates,
 		oidcIssuer:     "https://github.com/login/oauth",
 		subjectEmail:   "this-does-not-match@example.com",
-	}, setBytes, certBytes, chainBytes, string(sigBase64), payloadBytes)
+	}, setBytes, certBytes, chainBytes, string(sigBase64), payloadBytes, nil)
 	assert.Error(t, err)
 	assert.Nil(t, pk)
 }
@@ -0,0 +1,236 @@
+package internal
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/smallstep/pkcs7"
+)
+
+// RFC3161TimestampQueryMIMEType and RFC3161TimestampReplyMIMEType are the HTTP content types
+// used to talk to a RFC 3161 time-stamping authority.
+const (
+	RFC3161TimestampQueryMIMEType = "application/timestamp-query"
+	RFC3161TimestampReplyMIMEType = "application/timestamp-reply"
+)
+
+var (
+	// id-ct-TSTInfo, RFC 3161 section 2.4.2
+	oidRFC3161TSTInfo = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+	// id-sha256, RFC 5754
+	oidSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	// id-sha384, RFC 5754
+	oidSHA384 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	// id-sha512, RFC 5754
+	oidSHA512 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+)
+
+// rfc3161MessageImprint is MessageImprint from RFC 3161.
+type rfc3161MessageImprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+// rfc3161TimeStampReq is TimeStampReq from RFC 3161.
+type rfc3161TimeStampReq struct {
+	Version        int
+	MessageImprint rfc3161MessageImprint
+	ReqPolicy      asn1.ObjectIdentifier `asn1:"optional"`
+	Nonce          *big.Int              `asn1:"optional"`
+	CertReq        bool                  `asn1:"optional"`
+	Extensions     []pkix.Extension      `asn1:"optional,tag:0"`
+}
+
+// rfc3161PKIStatusInfo is PKIStatusInfo from RFC 3161.
+type rfc3161PKIStatusInfo struct {
+	Status       int
+	StatusString asn1.RawValue  `asn1:"optional"`
+	FailInfo     asn1.BitString `asn1:"optional"`
+}
+
+// rfc3161TimeStampResp is TimeStampResp from RFC 3161.
+type rfc3161TimeStampResp struct {
+	Status         rfc3161PKIStatusInfo
+	TimeStampToken asn1.RawValue `asn1:"optional"`
+}
+
+// rfc3161Accuracy is Accuracy from RFC 3161.
+type rfc3161Accuracy struct {
+	Seconds int `asn1:"optional"`
+	Millis  int `asn1:"optional,tag:0"`
+	Micros  int `asn1:"optional,tag:1"`
+}
+
+// rfc3161TSTInfo is TSTInfo from RFC 3161.
+type rfc3161TSTInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint rfc3161MessageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time        `asn1:"generalized"`
+	Accuracy       rfc3161Accuracy  `asn1:"optional"`
+	Ordering       bool             `asn1:"optional"`
+	Nonce          *big.Int         `asn1:"optional"`
+	TSA            asn1.RawValue    `asn1:"optional,explicit,tag:0"`
+	Extensions     []pkix.Extension `asn1:"optional,tag:1"`
+}
+
+// rfc3161Hashes are the message imprint algorithms we accept, in order of preference.
+var rfc3161Hashes = []struct {
+	oid  asn1.ObjectIdentifier
+	hash crypto.Hash
+}{
+	{oidSHA256, crypto.SHA256},
+	{oidSHA384, crypto.SHA384},
+	{oidSHA512, crypto.SHA512},
+}
+
+// rfc3161MessageImprintFor returns a RFC 3161 message imprint of data using the default hash algorithm.
+func rfc3161MessageImprintFor(data []byte) rfc3161MessageImprint {
+	h := rfc3161Hashes[0].hash.New()
+	h.Write(data)
+	return rfc3161MessageImprint{
+		HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: rfc3161Hashes[0].oid, Parameters: asn1.NullRawValue},
+		HashedMessage: h.Sum(nil),
+	}
+}
+
+// matches returns nil if mi is an imprint of data.
+func (mi rfc3161MessageImprint) matches(data []byte) error {
+	for _, alg := range rfc3161Hashes {
+		if !alg.oid.Equal(mi.HashAlgorithm.Algorithm) {
+			continue
+		}
+		h := alg.hash.New()
+		h.Write(data)
+		if !bytes.Equal(h.Sum(nil), mi.HashedMessage) {
+			return NewInvalidSignatureError("timestamp message imprint does not match the signature")
+		}
+		return nil
+	}
+	return NewInvalidSignatureError(fmt.Sprintf("unsupported timestamp message imprint algorithm %s", mi.HashAlgorithm.Algorithm.String()))
+}
+
+// NewRFC3161TimestampRequest returns a DER-encoded RFC 3161 TimeStampReq asking for a timestamp of data,
+// and the nonce used in the request.
+func NewRFC3161TimestampRequest(data []byte) ([]byte, *big.Int, error) {
+	nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, nil, fmt.Errorf("generating a timestamp request nonce: %w", err)
+	}
+	req, err := asn1.Marshal(rfc3161TimeStampReq{
+		Version:        1,
+		MessageImprint: rfc3161MessageImprintFor(data),
+		Nonce:          nonce,
+		CertReq:        true, // We need the certificate to be able to verify the token without any other data.
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating a timestamp request: %w", err)
+	}
+	return req, nonce, nil
+}
+
+// RFC3161TimestampTokenFromResponse returns the timestamp token from a DER-encoded RFC 3161 TimeStampResp,
+// returned in response to NewRFC3161TimestampRequest(data) with nonce.
+// The token is NOT verified to be signed by a trusted time-stamping authority.
+func RFC3161TimestampTokenFromResponse(response []byte, data []byte, nonce *big.Int) ([]byte, error) {
+	var resp rfc3161TimeStampResp
+	rest, err := asn1.Unmarshal(response, &resp)
+	if err != nil {
+		return nil, fmt.Errorf("parsing timestamp response: %w", err)
+	}
+	if len(rest) != 0 {
+		return nil, errors.New("parsing timestamp response: unexpected trailing data")
+	}
+	// PKIStatus: granted (0) or grantedWithMods (1)
+	if resp.Status.Status != 0 && resp.Status.Status != 1 {
+		return nil, fmt.Errorf("timestamp request rejected, status %d", resp.Status.Status)
+	}
+	if len(resp.TimeStampToken.FullBytes) == 0 {
+		return nil, errors.New("timestamp response does not contain a token")
+	}
+	token := resp.TimeStampToken.FullBytes
+	tstInfo, _, err := parseRFC3161TimestampToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if err := tstInfo.MessageImprint.matches(data); err != nil {
+		return nil, err
+	}
+	if tstInfo.Nonce == nil || tstInfo.Nonce.Cmp(nonce) != 0 {
+		return nil, errors.New("timestamp response does not match the request nonce")
+	}
+	return bytes.Clone(token), nil
+}
+
+// parseRFC3161TimestampToken parses an UNTRUSTED timestamp token, WITHOUT verifying its signature.
+func parseRFC3161TimestampToken(untrustedToken []byte) (rfc3161TSTInfo, *pkcs7.PKCS7, error) {
+	p7, err := pkcs7.Parse(untrustedToken)
+	if err != nil {
+		return rfc3161TSTInfo{}, nil, NewInvalidSignatureError(fmt.Sprintf("parsing timestamp token: %v", err))
+	}
+	// RFC 3161 requires the content-type signed attribute to be present; it is the only way we have to
+	// check the content type with this PKCS#7 implementation.
+	var contentType asn1.ObjectIdentifier
+	if err := p7.UnmarshalSignedAttribute(pkcs7.OIDAttributeContentType, &contentType); err != nil {
+		return rfc3161TSTInfo{}, nil, NewInvalidSignatureError(fmt.Sprintf("parsing timestamp token content type: %v", err))
+	}
+	if !contentType.Equal(oidRFC3161TSTInfo) {
+		return rfc3161TSTInfo{}, nil, NewInvalidSignatureError(fmt.Sprintf("unexpected timestamp token content type %s", contentType.String()))
+	}
+	var tstInfo rfc3161TSTInfo
+	rest, err := asn1.Unmarshal(p7.Content, &tstInfo)
+	if err != nil {
+		return rfc3161TSTInfo{}, nil, NewInvalidSignatureError(fmt.Sprintf("parsing timestamp token contents: %v", err))
+	}
+	if len(rest) != 0 {
+		return rfc3161TSTInfo{}, nil, NewInvalidSignatureError("parsing timestamp token contents: unexpected trailing data")
+	}
+	if tstInfo.Version != 1 {
+		return rfc3161TSTInfo{}, nil, NewInvalidSignatureError(fmt.Sprintf("unsupported timestamp token version %d", tstInfo.Version))
+	}
+	return tstInfo, p7, nil
+}
+
+// VerifyRFC3161TimestampToken verifies that untrustedToken is a RFC 3161 timestamp token for untrustedData,
+// issued by a time-stamping authority with a certificate chaining to trustedRoots,
+// and returns the time asserted by the token.
+func VerifyRFC3161TimestampToken(trustedRoots *x509.CertPool, untrustedToken []byte, untrustedData []byte) (time.Time, error) {
+	tstInfo, p7, err := parseRFC3161TimestampToken(untrustedToken)
+	if err != nil {
+		return time.Time{}, err
+	}
+	untrustedCertificate := p7.GetOnlySigner()
+	if untrustedCertificate == nil {
+		return time.Time{}, NewInvalidSignatureError("timestamp token does not have exactly one signer")
+	}
+	intermediates := x509.NewCertPool()
+	for _, c := range p7.Certificates {
+		intermediates.AddCert(c)
+	}
+	// The TSA certificate is verified as of the asserted time; that is the point of using a timestamp,
+	// the TSA is trusted to only assert the current time.
+	if _, err := untrustedCertificate.Verify(x509.VerifyOptions{
+		Intermediates: intermediates,
+		Roots:         trustedRoots,
+		CurrentTime:   tstInfo.GenTime,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	}); err != nil {
+		return time.Time{}, NewInvalidSignatureError(fmt.Sprintf("verifying timestamp authority certificate: %v", err))
+	}
+	if err := p7.Verify(); err != nil {
+		return time.Time{}, NewInvalidSignatureError(fmt.Sprintf("verifying timestamp token signature: %v", err))
+	}
+	if err := tstInfo.MessageImprint.matches(untrustedData); err != nil {
+		return time.Time{}, err
+	}
+	return tstInfo.GenTime, nil
+}
@@ -0,0 +1,112 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/containers/image/v5/internal/testing/rfc3161tsa"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRFC3161TimestampRequestResponse(t *testing.T) {
+	tsa, err := rfc3161tsa.New()
+	require.NoError(t, err)
+	data := []byte("signature data")
+
+	req, nonce, err := NewRFC3161TimestampRequest(data)
+	require.NoError(t, err)
+	var parsedReq rfc3161TimeStampReq
+	rest, err := asn1.Unmarshal(req, &parsedReq)
+	require.NoError(t, err)
+	assert.Empty(t, rest)
+	assert.Equal(t, 1, parsedReq.Version)
+	assert.Equal(t, nonce, parsedReq.Nonce)
+	assert.True(t, parsedReq.CertReq)
+	assert.NoError(t, parsedReq.MessageImprint.matches(data))
+
+	resp, err := tsa.Respond(req)
+	require.NoError(t, err)
+	token, err := RFC3161TimestampTokenFromResponse(resp, data, nonce)
+	require.NoError(t, err)
+	assert.NotEmpty(t, token)
+
+	// Nonce mismatch
+	_, err = RFC3161TimestampTokenFromResponse(resp, data, big.NewInt(1))
+	assert.Error(t, err)
+	// Data mismatch
+	_, err = RFC3161TimestampTokenFromResponse(resp, []byte("other data"), nonce)
+	assert.Error(t, err)
+	// Invalid response
+	_, err = RFC3161TimestampTokenFromResponse([]byte("invalid"), data, nonce)
+	assert.Error(t, err)
+	// Trailing data
+	_, err = RFC3161TimestampTokenFromResponse(append(resp, 0), data, nonce)
+	assert.Error(t, err)
+	// Rejected request
+	rejection, err := asn1.Marshal(rfc3161TimeStampResp{Status: rfc3161PKIStatusInfo{Status: 2}})
+	require.NoError(t, err)
+	_, err = RFC3161TimestampTokenFromResponse(rejection, data, nonce)
+	assert.Error(t, err)
+	// Granted, but no token
+	noToken, err := asn1.Marshal(rfc3161TimeStampResp{Status: rfc3161PKIStatusInfo{Status: 0}})
+	require.NoError(t, err)
+	_, err = RFC3161TimestampTokenFromResponse(noToken, data, nonce)
+	assert.Error(t, err)
+}
+
+func TestVerifyRFC3161TimestampToken(t *testing.T) {
+	tsa, err := rfc3161tsa.New()
+	require.NoError(t, err)
+	roots := x509.NewCertPool()
+	require.True(t, roots.AppendCertsFromPEM(tsa.RootCertificatePEM))
+	data := []byte("signature data")
+	genTime := time.Now().Add(-time.Minute).UTC().Truncate(time.Second)
+
+	token, err := tsa.Token(data, genTime)
+	require.NoError(t, err)
+
+	// Success
+	res, err := VerifyRFC3161TimestampToken(roots, token, data)
+	require.NoError(t, err)
+	assert.True(t, genTime.Equal(res))
+
+	// Data mismatch
+	_, err = VerifyRFC3161TimestampToken(roots, token, []byte("other data"))
+	assert.Error(t, err)
+	assert.IsType(t, InvalidSignatureError{}, err)
+
+	// Untrusted TSA
+	otherTSA, err := rfc3161tsa.New()
+	require.NoError(t, err)
+	otherRoots := x509.NewCertPool()
+	require.True(t, otherRoots.AppendCertsFromPEM(otherTSA.RootCertificatePEM))
+	_, err = VerifyRFC3161TimestampToken(otherRoots, token, data)
+	assert.Error(t, err)
+	assert.IsType(t, InvalidSignatureError{}, err)
+
+	// Time outside of the TSA certificate validity
+	tokenOutsideValidity, err := tsa.Token(data, time.Now().Add(-12*time.Hour))
+	require.NoError(t, err)
+	_, err = VerifyRFC3161TimestampToken(roots, tokenOutsideValidity, data)
+	assert.Error(t, err)
+	assert.IsType(t, InvalidSignatureError{}, err)
+
+	// Invalid token
+	_, err = VerifyRFC3161TimestampToken(roots, []byte("invalid"), data)
+	assert.Error(t, err)
+	assert.IsType(t, InvalidSignatureError{}, err)
+	// Modified genTime
+	genTimeBytes := []byte(genTime.Format("20060102150405"))
+	i := bytes.Index(token, genTimeBytes)
+	require.NotEqual(t, -1, i)
+	modifiedToken := bytes.Clone(token)
+	modifiedToken[i+13] ^= 1 // Changes the last digit of the seconds value, so that the time is still within the certificate validity
+	_, err = VerifyRFC3161TimestampToken(roots, modifiedToken, data)
+	assert.Error(t, err)
+	assert.IsType(t, InvalidSignatureError{}, err)
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"time"
 
 	// This code is used only to parse the data in an explicitly-untrusted
 	// code path, where cryptography is not relevant. For now, continue to
@@ -46,6 +47,15 @@ type signingMechanismWithPassphrase interface {
 	SignWithPassphrase(input []byte, keyIdentity string, passphrase string) ([]byte, error)
 }
 
+// signingMechanismWithVerificationTime is an internal extension of SigningMechanism.
+type signingMechanismWithVerificationTime interface {
+	SigningMechanism
+
+	// VerifyAtTime is like Verify, but evaluates expiration of the signature and of the signing key as of verificationTime
+	// instead of the current time, and also returns the creation time recorded in the signature.
+	VerifyAtTime(unverifiedSignature []byte, verificationTime time.Time) (contents []byte, keyIdentity string, creationTime time.Time, err error)
+}
+
 // SigningNotSupportedError is returned when trying to sign using a mechanism which does not support that.
 type SigningNotSupportedError string
 
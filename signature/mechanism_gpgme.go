@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/containers/image/v5/signature/internal"
 	"github.com/proglottis/gpgme"
@@ -169,31 +170,98 @@ func (m *gpgmeSigningMechanism) Sign(input []byte, keyIdentity string) ([]byte,
 	return m.SignWithPassphrase(input, keyIdentity, "")
 }
 
+// Error codes from libgpg-error, which are not exported by the gpgme package.
+const (
+	gpgErrorKeyExpired gpgme.ErrorCode = 153 // GPG_ERR_KEY_EXPIRED
+	gpgErrorSigExpired gpgme.ErrorCode = 154 // GPG_ERR_SIG_EXPIRED
+)
+
 // Verify parses unverifiedSignature and returns the content and the signer's identity
 func (m *gpgmeSigningMechanism) Verify(unverifiedSignature []byte) (contents []byte, keyIdentity string, err error) {
+	contents, sig, err := m.verifySignature(unverifiedSignature)
+	if err != nil {
+		return nil, "", err
+	}
+	// This is sig.Summary == gpgme.SigSumValid except for key trust, which we handle ourselves
+	if sig.Status != nil || sig.Validity == gpgme.ValidityNever || sig.ValidityReason != nil || sig.WrongKeyUsage {
+		// FIXME: Better error reporting eventually
+		return nil, "", internal.NewInvalidSignatureError(fmt.Sprintf("Invalid GPG signature: %#v", sig))
+	}
+	return contents, sig.Fingerprint, nil
+}
+
+// VerifyAtTime is like Verify, but evaluates expiration of the signature and of the signing key as of verificationTime
+// instead of the current time, and also returns the creation time recorded in the signature.
+func (m *gpgmeSigningMechanism) VerifyAtTime(unverifiedSignature []byte, verificationTime time.Time) (contents []byte, keyIdentity string, creationTime time.Time, err error) {
+	contents, sig, err := m.verifySignature(unverifiedSignature)
+	if err != nil {
+		return nil, "", time.Time{}, err
+	}
+	status := sig.Status
+	// GPG evaluates expiration as of the current time; if that is the only problem, re-evaluate it as of verificationTime.
+	var gpgErr gpgme.Error
+	if errors.As(status, &gpgErr) && (gpgErr.Code() == gpgErrorKeyExpired || gpgErr.Code() == gpgErrorSigExpired) &&
+		sig.Summary&(gpgme.SigSumRed|gpgme.SigSumKeyRevoked|gpgme.SigSumKeyMissing) == 0 {
+		expired := false
+		if sig.Summary&gpgme.SigSumSigExpired != 0 && (sig.ExpTimestamp.IsZero() || !verificationTime.Before(sig.ExpTimestamp)) {
+			expired = true
+		}
+		if sig.Summary&gpgme.SigSumKeyExpired != 0 {
+			keyExpired, err := m.keyExpiredAt(sig.Fingerprint, verificationTime)
+			if err != nil {
+				return nil, "", time.Time{}, err
+			}
+			expired = expired || keyExpired
+		}
+		if !expired {
+			status = nil
+		}
+	}
+	if status != nil || sig.Validity == gpgme.ValidityNever || sig.ValidityReason != nil || sig.WrongKeyUsage {
+		// FIXME: Better error reporting eventually
+		return nil, "", time.Time{}, internal.NewInvalidSignatureError(fmt.Sprintf("Invalid GPG signature: %#v", sig))
+	}
+	return contents, sig.Fingerprint, sig.Timestamp, nil
+}
+
+// verifySignature parses unverifiedSignature and returns the content and the GPG signature status,
+// WITHOUT checking that the signature is valid.
+func (m *gpgmeSigningMechanism) verifySignature(unverifiedSignature []byte) ([]byte, gpgme.Signature, error) {
 	signedBuffer := bytes.Buffer{}
 	signedData, err := gpgme.NewDataWriter(&signedBuffer)
 	if err != nil {
-		return nil, "", err
+		return nil, gpgme.Signature{}, err
 	}
 	unverifiedSignatureData, err := gpgme.NewDataBytes(unverifiedSignature)
 	if err != nil {
-		return nil, "", err
+		return nil, gpgme.Signature{}, err
 	}
 	_, sigs, err := m.ctx.Verify(unverifiedSignatureData, nil, signedData)
 	if err != nil {
-		return nil, "", err
+		return nil, gpgme.Signature{}, err
 	}
 	if len(sigs) != 1 {
-		return nil, "", internal.NewInvalidSignatureError(fmt.Sprintf("Unexpected GPG signature count %d", len(sigs)))
+		return nil, gpgme.Signature{}, internal.NewInvalidSignatureError(fmt.Sprintf("Unexpected GPG signature count %d", len(sigs)))
 	}
-	sig := sigs[0]
-	// This is sig.Summary == gpgme.SigSumValid except for key trust, which we handle ourselves
-	if sig.Status != nil || sig.Validity == gpgme.ValidityNever || sig.ValidityReason != nil || sig.WrongKeyUsage {
-		// FIXME: Better error reporting eventually
-		return nil, "", internal.NewInvalidSignatureError(fmt.Sprintf("Invalid GPG signature: %#v", sig))
+	return signedBuffer.Bytes(), sigs[0], nil
+}
+
+// keyExpiredAt returns true if the primary key, or the subkey, with fingerprint has expired as of t.
+func (m *gpgmeSigningMechanism) keyExpiredAt(fingerprint string, t time.Time) (bool, error) {
+	key, err := m.ctx.GetKey(fingerprint, false)
+	if err != nil {
+		return false, err
+	}
+	primary := key.SubKeys()
+	for sk := primary; sk != nil; sk = sk.Next() {
+		if sk != primary && sk.Fingerprint() != fingerprint {
+			continue
+		}
+		if expires := sk.Expires(); !expires.IsZero() && !t.Before(expires) {
+			return true, nil
+		}
 	}
-	return signedBuffer.Bytes(), sig.Fingerprint, nil
+	return false, nil
 }
 
 // UntrustedSignatureContents returns UNTRUSTED contents of the signature WITHOUT ANY VERIFICATION,
@@ -129,12 +129,19 @@ func (m *openpgpSigningMechanism) Sign(input []byte, keyIdentity string) ([]byte
 
 // Verify parses unverifiedSignature and returns the content and the signer's identity
 func (m *openpgpSigningMechanism) Verify(unverifiedSignature []byte) (contents []byte, keyIdentity string, err error) {
+	contents, keyIdentity, _, err = m.VerifyAtTime(unverifiedSignature, time.Now())
+	return contents, keyIdentity, err
+}
+
+// VerifyAtTime is like Verify, but evaluates expiration of the signature and of the signing key as of verificationTime
+// instead of the current time, and also returns the creation time recorded in the signature.
+func (m *openpgpSigningMechanism) VerifyAtTime(unverifiedSignature []byte, verificationTime time.Time) (contents []byte, keyIdentity string, creationTime time.Time, err error) {
 	md, err := openpgp.ReadMessage(bytes.NewReader(unverifiedSignature), m.keyring, nil, nil)
 	if err != nil {
-		return nil, "", err
+		return nil, "", time.Time{}, err
 	}
 	if !md.IsSigned {
-		return nil, "", errors.New("not signed")
+		return nil, "", time.Time{}, errors.New("not signed")
 	}
 	content, err := io.ReadAll(md.UnverifiedBody)
 	if err != nil {
@@ -144,29 +151,35 @@ func (m *openpgpSigningMechanism) Verify(unverifiedSignature []byte) (contents [
 		// we would expect the signature verification to fail as well, and that is checked
 		// first.  Besides, we are not supplying any decryption keys, so we really
 		// can never reach this “encrypted data MDC mismatch” path.
-		return nil, "", err
+		return nil, "", time.Time{}, err
 	}
 	if md.SignatureError != nil {
-		return nil, "", fmt.Errorf("signature error: %v", md.SignatureError)
+		return nil, "", time.Time{}, fmt.Errorf("signature error: %v", md.SignatureError)
 	}
 	if md.SignedBy == nil {
-		return nil, "", internal.NewInvalidSignatureError(fmt.Sprintf("Key not found for key ID %x in signature", md.SignedByKeyId))
+		return nil, "", time.Time{}, internal.NewInvalidSignatureError(fmt.Sprintf("Key not found for key ID %x in signature", md.SignedByKeyId))
+	}
+	if md.SignedBy.SelfSignature != nil && md.SignedBy.SelfSignature.KeyExpired(verificationTime) {
+		return nil, "", time.Time{}, internal.NewInvalidSignatureError(fmt.Sprintf("Key %X has expired", md.SignedBy.PublicKey.Fingerprint))
 	}
 	if md.Signature != nil {
 		if md.Signature.SigLifetimeSecs != nil {
 			expiry := md.Signature.CreationTime.Add(time.Duration(*md.Signature.SigLifetimeSecs) * time.Second)
-			if time.Now().After(expiry) {
-				return nil, "", internal.NewInvalidSignatureError(fmt.Sprintf("Signature expired on %s", expiry))
+			if verificationTime.After(expiry) {
+				return nil, "", time.Time{}, internal.NewInvalidSignatureError(fmt.Sprintf("Signature expired on %s", expiry))
 			}
 		}
-	} else if md.SignatureV3 == nil {
+		creationTime = md.Signature.CreationTime
+	} else if md.SignatureV3 != nil {
+		creationTime = md.SignatureV3.CreationTime
+	} else {
 		// Coverage: If md.SignedBy != nil, the final md.UnverifiedBody.Read() either sets one of md.Signature or md.SignatureV3,
 		// or sets md.SignatureError.
-		return nil, "", internal.NewInvalidSignatureError("Unexpected openpgp.MessageDetails: neither Signature nor SignatureV3 is set")
+		return nil, "", time.Time{}, internal.NewInvalidSignatureError("Unexpected openpgp.MessageDetails: neither Signature nor SignatureV3 is set")
 	}
 
 	// Uppercase the fingerprint to be compatible with gpgme
-	return content, strings.ToUpper(fmt.Sprintf("%x", md.SignedBy.PublicKey.Fingerprint)), nil
+	return content, strings.ToUpper(fmt.Sprintf("%x", md.SignedBy.PublicKey.Fingerprint)), creationTime, nil
 }
 
 // UntrustedSignatureContents returns UNTRUSTED contents of the signature WITHOUT ANY VERIFICATION,
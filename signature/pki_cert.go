@@ -16,6 +16,7 @@ type pkiTrustRoot struct {
 	caIntermediateCertificates *x509.CertPool
 	subjectEmail               string
 	subjectHostname            string
+	revocation                 *revocationChecker // nil if revocation is not checked
 }
 
 func (p *pkiTrustRoot) validate() error {
@@ -25,7 +26,7 @@ func (p *pkiTrustRoot) validate() error {
 	return nil
 }
 
-func verifyPKI(pkiTrustRoot *pkiTrustRoot, untrustedCertificateBytes []byte, untrustedIntermediateChainBytes []byte) (crypto.PublicKey, error) {
+func verifyPKI(pkiTrustRoot *pkiTrustRoot, untrustedCertificateBytes []byte, untrustedIntermediateChainBytes []byte, untrustedOCSPResponse []byte) (crypto.PublicKey, error) {
 	var untrustedIntermediatePool *x509.CertPool
 	if pkiTrustRoot.caIntermediateCertificates != nil {
 		untrustedIntermediatePool = pkiTrustRoot.caIntermediateCertificates.Clone()
@@ -49,11 +50,12 @@ func verifyPKI(pkiTrustRoot *pkiTrustRoot, untrustedCertificateBytes []byte, unt
 		return nil, err
 	}
 
-	if _, err := untrustedCertificate.Verify(x509.VerifyOptions{
+	chains, err := untrustedCertificate.Verify(x509.VerifyOptions{
 		Intermediates: untrustedIntermediatePool,
 		Roots:         pkiTrustRoot.caRootsCertificates,
 		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
-	}); err != nil {
+	})
+	if err != nil {
 		return nil, internal.NewInvalidSignatureError(fmt.Sprintf("veryfing leaf certificate failed: %v", err))
 	}
 
@@ -70,5 +72,11 @@ func verifyPKI(pkiTrustRoot *pkiTrustRoot, untrustedCertificateBytes []byte, unt
 		}
 	}
 
+	if pkiTrustRoot.revocation != nil {
+		if err := pkiTrustRoot.revocation.checkChains(chains, untrustedOCSPResponse); err != nil {
+			return nil, err
+		}
+	}
+
 	return untrustedCertificate.PublicKey, nil
 }
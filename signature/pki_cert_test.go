@@ -83,24 +83,24 @@ func TestPKIVerify(t *testing.T) {
 		subjectEmail:               "qiwan@redhat.com",
 		subjectHostname:            "myhost.example.com",
 	}
-	pk, err := verifyPKI(tr, certBytes, chainBytes)
+	pk, err := verifyPKI(tr, certBytes, chainBytes, nil)
 	require.NoError(t, err)
 	assertPublicKeyMatchesCert(t, certBytes, pk)
 
 	// Invalid intermediate certificate
-	pk, err = verifyPKI(tr, certBytes, []byte("not a certificate"))
+	pk, err = verifyPKI(tr, certBytes, []byte("not a certificate"), nil)
 	assert.Error(t, err)
 	assert.Nil(t, pk)
 
 	// Invalid leaf certificate
-	pk, err = verifyPKI(tr, []byte("not a certificate"), chainBytes)
+	pk, err = verifyPKI(tr, []byte("not a certificate"), chainBytes, nil)
 	assert.Error(t, err)
 	assert.Nil(t, pk)
 
 	// Failure with intermediates provided in neither signature nor config
 	pk, err = verifyPKI(&pkiTrustRoot{
 		caRootsCertificates: caRootsCertificates,
-	}, certBytes, []byte{})
+	}, certBytes, []byte{}, nil)
 	require.Error(t, err)
 	assert.Nil(t, pk)
 
@@ -108,14 +108,14 @@ func TestPKIVerify(t *testing.T) {
 	pk, err = verifyPKI(&pkiTrustRoot{
 		caRootsCertificates:        caRootsCertificates,
 		caIntermediateCertificates: caIntermediateCertificates,
-	}, certBytes, []byte{})
+	}, certBytes, []byte{}, nil)
 	require.NoError(t, err)
 	assertPublicKeyMatchesCert(t, certBytes, pk)
 
 	// Success with intermediate provided in signature only
 	pk, err = verifyPKI(&pkiTrustRoot{
 		caRootsCertificates: caRootsCertificates,
-	}, certBytes, chainBytes)
+	}, certBytes, chainBytes, nil)
 	require.NoError(t, err)
 	assertPublicKeyMatchesCert(t, certBytes, pk)
 
@@ -262,7 +262,7 @@ func TestPKIVerify(t *testing.T) {
 			Type:  "CERTIFICATE",
 			Bytes: testLeafCert,
 		})
-		pk, err := verifyPKI(&tr, testLeafPEM, chainBytes)
+		pk, err := verifyPKI(&tr, testLeafPEM, chainBytes, nil)
 		if c.errorFragment == "" {
 			require.NoError(t, err, c.name)
 			assertPublicKeyMatchesCert(t, testLeafPEM, pk)
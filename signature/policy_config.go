@@ -257,6 +257,10 @@ func newPolicyRequirementFromJSON(data []byte) (PolicyRequirement, error) {
 		res = &prSignedBaseLayer{}
 	case prTypeSigstoreSigned:
 		res = &prSigstoreSigned{}
+	case prTypeSBOMAttested:
+		res = &prSBOMAttested{}
+	case prTypeWebhook:
+		res = &prWebhook{}
 	default:
 		return nil, InvalidPolicyFormatError(fmt.Sprintf("Unknown policy requirement type %q", typeField.Type))
 	}
@@ -326,8 +330,35 @@ func (pr *prReject) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// PRSignedByOption is a way to pass optional values to NewPRSignedByKeyPath, NewPRSignedByKeyPaths and NewPRSignedByKeyData.
+type PRSignedByOption func(*prSignedBy) error
+
+// PRSignedByWithTimestampAuthorityCAPath requires signatures to carry an RFC 3161 timestamp
+// issued by a time-stamping authority with one of the root certificates in the PEM file at caPath.
+func PRSignedByWithTimestampAuthorityCAPath(caPath string) PRSignedByOption {
+	return func(pr *prSignedBy) error {
+		if pr.TimestampAuthority != nil {
+			return InvalidPolicyFormatError(`"timestampAuthority" already specified`)
+		}
+		pr.TimestampAuthority = &prSignedByTimestampAuthority{CAPath: caPath}
+		return nil
+	}
+}
+
+// PRSignedByWithTimestampAuthorityCAData requires signatures to carry an RFC 3161 timestamp
+// issued by a time-stamping authority with one of the root certificates in PEM-formatted caData.
+func PRSignedByWithTimestampAuthorityCAData(caData []byte) PRSignedByOption {
+	return func(pr *prSignedBy) error {
+		if pr.TimestampAuthority != nil {
+			return InvalidPolicyFormatError(`"timestampAuthority" already specified`)
+		}
+		pr.TimestampAuthority = &prSignedByTimestampAuthority{CAData: caData}
+		return nil
+	}
+}
+
 // newPRSignedBy returns a new prSignedBy if parameters are valid.
-func newPRSignedBy(keyType sbKeyType, keyPath string, keyPaths []string, keyData []byte, signedIdentity PolicyReferenceMatch) (*prSignedBy, error) {
+func newPRSignedBy(keyType sbKeyType, keyPath string, keyPaths []string, keyData []byte, signedIdentity PolicyReferenceMatch, options ...PRSignedByOption) (*prSignedBy, error) {
 	if !keyType.IsValid() {
 		return nil, InvalidPolicyFormatError(fmt.Sprintf("invalid keyType %q", keyType))
 	}
@@ -347,44 +378,55 @@ func newPRSignedBy(keyType sbKeyType, keyPath string, keyPaths []string, keyData
 	if signedIdentity == nil {
 		return nil, InvalidPolicyFormatError("signedIdentity not specified")
 	}
-	return &prSignedBy{
+	res := &prSignedBy{
 		prCommon:       prCommon{Type: prTypeSignedBy},
 		KeyType:        keyType,
 		KeyPath:        keyPath,
 		KeyPaths:       keyPaths,
 		KeyData:        keyData,
 		SignedIdentity: signedIdentity,
-	}, nil
+	}
+	for _, o := range options {
+		if err := o(res); err != nil {
+			return nil, err
+		}
+	}
+	if res.TimestampAuthority != nil {
+		if err := res.TimestampAuthority.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
 }
 
 // newPRSignedByKeyPath is NewPRSignedByKeyPath, except it returns the private type.
-func newPRSignedByKeyPath(keyType sbKeyType, keyPath string, signedIdentity PolicyReferenceMatch) (*prSignedBy, error) {
-	return newPRSignedBy(keyType, keyPath, nil, nil, signedIdentity)
+func newPRSignedByKeyPath(keyType sbKeyType, keyPath string, signedIdentity PolicyReferenceMatch, options ...PRSignedByOption) (*prSignedBy, error) {
+	return newPRSignedBy(keyType, keyPath, nil, nil, signedIdentity, options...)
 }
 
 // NewPRSignedByKeyPath returns a new "signedBy" PolicyRequirement using a KeyPath
-func NewPRSignedByKeyPath(keyType sbKeyType, keyPath string, signedIdentity PolicyReferenceMatch) (PolicyRequirement, error) {
-	return newPRSignedByKeyPath(keyType, keyPath, signedIdentity)
+func NewPRSignedByKeyPath(keyType sbKeyType, keyPath string, signedIdentity PolicyReferenceMatch, options ...PRSignedByOption) (PolicyRequirement, error) {
+	return newPRSignedByKeyPath(keyType, keyPath, signedIdentity, options...)
 }
 
 // newPRSignedByKeyPaths is NewPRSignedByKeyPaths, except it returns the private type.
-func newPRSignedByKeyPaths(keyType sbKeyType, keyPaths []string, signedIdentity PolicyReferenceMatch) (*prSignedBy, error) {
-	return newPRSignedBy(keyType, "", keyPaths, nil, signedIdentity)
+func newPRSignedByKeyPaths(keyType sbKeyType, keyPaths []string, signedIdentity PolicyReferenceMatch, options ...PRSignedByOption) (*prSignedBy, error) {
+	return newPRSignedBy(keyType, "", keyPaths, nil, signedIdentity, options...)
 }
 
 // NewPRSignedByKeyPaths returns a new "signedBy" PolicyRequirement using KeyPaths
-func NewPRSignedByKeyPaths(keyType sbKeyType, keyPaths []string, signedIdentity PolicyReferenceMatch) (PolicyRequirement, error) {
-	return newPRSignedByKeyPaths(keyType, keyPaths, signedIdentity)
+func NewPRSignedByKeyPaths(keyType sbKeyType, keyPaths []string, signedIdentity PolicyReferenceMatch, options ...PRSignedByOption) (PolicyRequirement, error) {
+	return newPRSignedByKeyPaths(keyType, keyPaths, signedIdentity, options...)
 }
 
 // newPRSignedByKeyData is NewPRSignedByKeyData, except it returns the private type.
-func newPRSignedByKeyData(keyType sbKeyType, keyData []byte, signedIdentity PolicyReferenceMatch) (*prSignedBy, error) {
-	return newPRSignedBy(keyType, "", nil, keyData, signedIdentity)
+func newPRSignedByKeyData(keyType sbKeyType, keyData []byte, signedIdentity PolicyReferenceMatch, options ...PRSignedByOption) (*prSignedBy, error) {
+	return newPRSignedBy(keyType, "", nil, keyData, signedIdentity, options...)
 }
 
 // NewPRSignedByKeyData returns a new "signedBy" PolicyRequirement using a KeyData
-func NewPRSignedByKeyData(keyType sbKeyType, keyData []byte, signedIdentity PolicyReferenceMatch) (PolicyRequirement, error) {
-	return newPRSignedByKeyData(keyType, keyData, signedIdentity)
+func NewPRSignedByKeyData(keyType sbKeyType, keyData []byte, signedIdentity PolicyReferenceMatch, options ...PRSignedByOption) (PolicyRequirement, error) {
+	return newPRSignedByKeyData(keyType, keyData, signedIdentity, options...)
 }
 
 // Compile-time check that prSignedBy implements json.Unmarshaler.
@@ -395,7 +437,7 @@ func (pr *prSignedBy) UnmarshalJSON(data []byte) error {
 	*pr = prSignedBy{}
 	var tmp prSignedBy
 	var gotKeyPath, gotKeyPaths, gotKeyData = false, false, false
-	var signedIdentity json.RawMessage
+	var signedIdentity, timestampAuthority json.RawMessage
 	if err := internal.ParanoidUnmarshalJSONObject(data, func(key string) any {
 		switch key {
 		case "type":
@@ -413,6 +455,8 @@ func (pr *prSignedBy) UnmarshalJSON(data []byte) error {
 			return &tmp.KeyData
 		case "signedIdentity":
 			return &signedIdentity
+		case "timestampAuthority":
+			return &timestampAuthority
 		default:
 			return nil
 		}
@@ -432,16 +476,28 @@ func (pr *prSignedBy) UnmarshalJSON(data []byte) error {
 		}
 		tmp.SignedIdentity = si
 	}
+	var opts []PRSignedByOption
+	if timestampAuthority != nil {
+		var tsa prSignedByTimestampAuthority
+		if err := json.Unmarshal(timestampAuthority, &tsa); err != nil {
+			return err
+		}
+		if tsa.CAPath != "" {
+			opts = append(opts, PRSignedByWithTimestampAuthorityCAPath(tsa.CAPath))
+		} else {
+			opts = append(opts, PRSignedByWithTimestampAuthorityCAData(tsa.CAData))
+		}
+	}
 
 	var res *prSignedBy
 	var err error
 	switch {
 	case gotKeyPath && !gotKeyPaths && !gotKeyData:
-		res, err = newPRSignedByKeyPath(tmp.KeyType, tmp.KeyPath, tmp.SignedIdentity)
+		res, err = newPRSignedByKeyPath(tmp.KeyType, tmp.KeyPath, tmp.SignedIdentity, opts...)
 	case !gotKeyPath && gotKeyPaths && !gotKeyData:
-		res, err = newPRSignedByKeyPaths(tmp.KeyType, tmp.KeyPaths, tmp.SignedIdentity)
+		res, err = newPRSignedByKeyPaths(tmp.KeyType, tmp.KeyPaths, tmp.SignedIdentity, opts...)
 	case !gotKeyPath && !gotKeyPaths && gotKeyData:
-		res, err = newPRSignedByKeyData(tmp.KeyType, tmp.KeyData, tmp.SignedIdentity)
+		res, err = newPRSignedByKeyData(tmp.KeyType, tmp.KeyData, tmp.SignedIdentity, opts...)
 	case !gotKeyPath && !gotKeyPaths && !gotKeyData:
 		return InvalidPolicyFormatError("Exactly one of keyPath, keyPaths and keyData must be specified, none of them present")
 	default:
@@ -455,6 +511,46 @@ func (pr *prSignedBy) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// validate returns an error if tsa is not a valid configuration.
+func (tsa *prSignedByTimestampAuthority) validate() error {
+	switch {
+	case tsa.CAPath != "" && tsa.CAData == nil:
+		return nil
+	case tsa.CAPath == "" && tsa.CAData != nil:
+		return nil
+	case tsa.CAPath == "" && tsa.CAData == nil:
+		return InvalidPolicyFormatError(`Exactly one of "caPath" and "caData" must be specified in "timestampAuthority", none of them present`)
+	default:
+		return InvalidPolicyFormatError(`Exactly one of "caPath" and "caData" must be specified in "timestampAuthority", both present`)
+	}
+}
+
+// Compile-time check that prSignedByTimestampAuthority implements json.Unmarshaler.
+var _ json.Unmarshaler = (*prSignedByTimestampAuthority)(nil)
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (tsa *prSignedByTimestampAuthority) UnmarshalJSON(data []byte) error {
+	*tsa = prSignedByTimestampAuthority{}
+	var tmp prSignedByTimestampAuthority
+	if err := internal.ParanoidUnmarshalJSONObject(data, func(key string) any {
+		switch key {
+		case "caPath":
+			return &tmp.CAPath
+		case "caData":
+			return &tmp.CAData
+		default:
+			return nil
+		}
+	}); err != nil {
+		return err
+	}
+	if err := tmp.validate(); err != nil {
+		return err
+	}
+	*tsa = tmp
+	return nil
+}
+
 // IsValid returns true iff kt is a recognized value
 func (kt sbKeyType) IsValid() bool {
 	switch kt {
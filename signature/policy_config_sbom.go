@@ -0,0 +1,205 @@
+package signature
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/containers/image/v5/signature/internal"
+)
+
+// PRSBOMAttestedOption is a way to pass values to NewPRSBOMAttested
+type PRSBOMAttestedOption func(*prSBOMAttested) error
+
+// PRSBOMAttestedWithKeyPath specifies a value for the "keyPath" field when calling NewPRSBOMAttested.
+func PRSBOMAttestedWithKeyPath(keyPath string) PRSBOMAttestedOption {
+	return func(pr *prSBOMAttested) error {
+		if pr.KeyPath != "" {
+			return InvalidPolicyFormatError(`"keyPath" already specified`)
+		}
+		pr.KeyPath = keyPath
+		return nil
+	}
+}
+
+// PRSBOMAttestedWithKeyPaths specifies a value for the "keyPaths" field when calling NewPRSBOMAttested.
+func PRSBOMAttestedWithKeyPaths(keyPaths []string) PRSBOMAttestedOption {
+	return func(pr *prSBOMAttested) error {
+		if pr.KeyPaths != nil {
+			return InvalidPolicyFormatError(`"keyPaths" already specified`)
+		}
+		if len(keyPaths) == 0 {
+			return InvalidPolicyFormatError(`"keyPaths" contains no entries`)
+		}
+		pr.KeyPaths = keyPaths
+		return nil
+	}
+}
+
+// PRSBOMAttestedWithKeyData specifies a value for the "keyData" field when calling NewPRSBOMAttested.
+func PRSBOMAttestedWithKeyData(keyData []byte) PRSBOMAttestedOption {
+	return func(pr *prSBOMAttested) error {
+		if pr.KeyData != nil {
+			return InvalidPolicyFormatError(`"keyData" already specified`)
+		}
+		pr.KeyData = keyData
+		return nil
+	}
+}
+
+// PRSBOMAttestedWithKeyDatas specifies a value for the "keyDatas" field when calling NewPRSBOMAttested.
+func PRSBOMAttestedWithKeyDatas(keyDatas [][]byte) PRSBOMAttestedOption {
+	return func(pr *prSBOMAttested) error {
+		if pr.KeyDatas != nil {
+			return InvalidPolicyFormatError(`"keyDatas" already specified`)
+		}
+		if len(keyDatas) == 0 {
+			return InvalidPolicyFormatError(`"keyDatas" contains no entries`)
+		}
+		pr.KeyDatas = keyDatas
+		return nil
+	}
+}
+
+// PRSBOMAttestedWithPKI specifies a value for the "pki" field when calling NewPRSBOMAttested.
+func PRSBOMAttestedWithPKI(p PRSigstoreSignedPKI) PRSBOMAttestedOption {
+	return func(pr *prSBOMAttested) error {
+		if pr.PKI != nil {
+			return InvalidPolicyFormatError(`"pki" already specified`)
+		}
+		pr.PKI = p
+		return nil
+	}
+}
+
+// PRSBOMAttestedWithSBOMFormat specifies a value for the "sbomFormat" field when calling NewPRSBOMAttested.
+func PRSBOMAttestedWithSBOMFormat(format sbomFormat) PRSBOMAttestedOption {
+	return func(pr *prSBOMAttested) error {
+		if pr.SBOMFormat != "" {
+			return InvalidPolicyFormatError(`"sbomFormat" already specified`)
+		}
+		if !format.IsValid() {
+			return InvalidPolicyFormatError(fmt.Sprintf("invalid SBOM format %q", format))
+		}
+		pr.SBOMFormat = format
+		return nil
+	}
+}
+
+// newPRSBOMAttested is NewPRSBOMAttested, except it returns the private type.
+func newPRSBOMAttested(options ...PRSBOMAttestedOption) (*prSBOMAttested, error) {
+	res := prSBOMAttested{
+		prCommon: prCommon{Type: prTypeSBOMAttested},
+	}
+	for _, o := range options {
+		if err := o(&res); err != nil {
+			return nil, err
+		}
+	}
+
+	keySources := 0
+	if res.KeyPath != "" {
+		keySources++
+	}
+	if res.KeyPaths != nil {
+		keySources++
+	}
+	if res.KeyData != nil {
+		keySources++
+	}
+	if res.KeyDatas != nil {
+		keySources++
+	}
+	if res.PKI != nil {
+		keySources++
+	}
+	if keySources != 1 {
+		return nil, InvalidPolicyFormatError("exactly one of keyPath, keyPaths, keyData, keyDatas, and pki must be specified")
+	}
+
+	return &res, nil
+}
+
+// NewPRSBOMAttested returns a new "sbomAttested" PolicyRequirement based on options.
+func NewPRSBOMAttested(options ...PRSBOMAttestedOption) (PolicyRequirement, error) {
+	return newPRSBOMAttested(options...)
+}
+
+// Compile-time check that prSBOMAttested implements json.Unmarshaler.
+var _ json.Unmarshaler = (*prSBOMAttested)(nil)
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (pr *prSBOMAttested) UnmarshalJSON(data []byte) error {
+	*pr = prSBOMAttested{}
+	var tmp prSBOMAttested
+	var gotKeyPath, gotKeyPaths, gotKeyData, gotKeyDatas, gotPKI, gotSBOMFormat bool
+	var pki prSigstoreSignedPKI
+	if err := internal.ParanoidUnmarshalJSONObject(data, func(key string) any {
+		switch key {
+		case "type":
+			return &tmp.Type
+		case "keyPath":
+			gotKeyPath = true
+			return &tmp.KeyPath
+		case "keyPaths":
+			gotKeyPaths = true
+			return &tmp.KeyPaths
+		case "keyData":
+			gotKeyData = true
+			return &tmp.KeyData
+		case "keyDatas":
+			gotKeyDatas = true
+			return &tmp.KeyDatas
+		case "pki":
+			gotPKI = true
+			return &pki
+		case "sbomFormat":
+			gotSBOMFormat = true
+			return &tmp.SBOMFormat
+		default:
+			return nil
+		}
+	}); err != nil {
+		return err
+	}
+
+	if tmp.Type != prTypeSBOMAttested {
+		return InvalidPolicyFormatError(fmt.Sprintf("Unexpected policy requirement type %q", tmp.Type))
+	}
+
+	var opts []PRSBOMAttestedOption
+	if gotKeyPath {
+		opts = append(opts, PRSBOMAttestedWithKeyPath(tmp.KeyPath))
+	}
+	if gotKeyPaths {
+		opts = append(opts, PRSBOMAttestedWithKeyPaths(tmp.KeyPaths))
+	}
+	if gotKeyData {
+		opts = append(opts, PRSBOMAttestedWithKeyData(tmp.KeyData))
+	}
+	if gotKeyDatas {
+		opts = append(opts, PRSBOMAttestedWithKeyDatas(tmp.KeyDatas))
+	}
+	if gotPKI {
+		opts = append(opts, PRSBOMAttestedWithPKI(&pki))
+	}
+	if gotSBOMFormat {
+		opts = append(opts, PRSBOMAttestedWithSBOMFormat(tmp.SBOMFormat))
+	}
+
+	res, err := newPRSBOMAttested(opts...)
+	if err != nil {
+		return err
+	}
+	*pr = *res
+	return nil
+}
+
+// IsValid returns true if format is a supported sbomFormat value
+func (format sbomFormat) IsValid() bool {
+	switch format {
+	case SBOMFormatSPDX, SBOMFormatCycloneDX:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,170 @@
+package signature
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPRSBOMAttested(t *testing.T) {
+	const testKeyPath = "/foo/bar"
+	testKeyData := []byte("abc")
+	testPKI, err := NewPRSigstoreSignedPKI(
+		PRSigstoreSignedPKIWithCARootsPath("fixtures/pki_root_crts.pem"),
+		PRSigstoreSignedPKIWithSubjectHostname("myhost.example.com"),
+	)
+	require.NoError(t, err)
+
+	// Success
+	for _, c := range []struct {
+		options  []PRSBOMAttestedOption
+		expected prSBOMAttested
+	}{
+		{
+			options: []PRSBOMAttestedOption{PRSBOMAttestedWithKeyPath(testKeyPath)},
+			expected: prSBOMAttested{
+				prCommon: prCommon{prTypeSBOMAttested},
+				KeyPath:  testKeyPath,
+			},
+		},
+		{
+			options: []PRSBOMAttestedOption{PRSBOMAttestedWithKeyPaths([]string{testKeyPath, "/baz/bar"})},
+			expected: prSBOMAttested{
+				prCommon: prCommon{prTypeSBOMAttested},
+				KeyPaths: []string{testKeyPath, "/baz/bar"},
+			},
+		},
+		{
+			options: []PRSBOMAttestedOption{
+				PRSBOMAttestedWithKeyData(testKeyData),
+				PRSBOMAttestedWithSBOMFormat(SBOMFormatSPDX),
+			},
+			expected: prSBOMAttested{
+				prCommon:   prCommon{prTypeSBOMAttested},
+				KeyData:    testKeyData,
+				SBOMFormat: SBOMFormatSPDX,
+			},
+		},
+		{
+			options: []PRSBOMAttestedOption{PRSBOMAttestedWithKeyDatas([][]byte{testKeyData, []byte("def")})},
+			expected: prSBOMAttested{
+				prCommon: prCommon{prTypeSBOMAttested},
+				KeyDatas: [][]byte{testKeyData, []byte("def")},
+			},
+		},
+		{
+			options: []PRSBOMAttestedOption{
+				PRSBOMAttestedWithPKI(testPKI),
+				PRSBOMAttestedWithSBOMFormat(SBOMFormatCycloneDX),
+			},
+			expected: prSBOMAttested{
+				prCommon:   prCommon{prTypeSBOMAttested},
+				PKI:        testPKI,
+				SBOMFormat: SBOMFormatCycloneDX,
+			},
+		},
+	} {
+		pr, err := newPRSBOMAttested(c.options...)
+		require.NoError(t, err)
+		assert.Equal(t, &c.expected, pr)
+	}
+
+	for _, c := range [][]PRSBOMAttestedOption{
+		{}, // No key source
+		{ // Two key sources
+			PRSBOMAttestedWithKeyPath(testKeyPath),
+			PRSBOMAttestedWithKeyData(testKeyData),
+		},
+		{ // Two key sources, one of them PKI
+			PRSBOMAttestedWithKeyData(testKeyData),
+			PRSBOMAttestedWithPKI(testPKI),
+		},
+		{ // Duplicate keyPath
+			PRSBOMAttestedWithKeyPath(testKeyPath),
+			PRSBOMAttestedWithKeyPath(testKeyPath),
+		},
+		{ // Empty keyPaths
+			PRSBOMAttestedWithKeyPaths([]string{}),
+		},
+		{ // Empty keyDatas
+			PRSBOMAttestedWithKeyDatas([][]byte{}),
+		},
+		{ // Duplicate pki
+			PRSBOMAttestedWithPKI(testPKI),
+			PRSBOMAttestedWithPKI(testPKI),
+		},
+		{ // Invalid sbomFormat
+			PRSBOMAttestedWithKeyPath(testKeyPath),
+			PRSBOMAttestedWithSBOMFormat("this is invalid"),
+		},
+		{ // Duplicate sbomFormat
+			PRSBOMAttestedWithKeyPath(testKeyPath),
+			PRSBOMAttestedWithSBOMFormat(SBOMFormatSPDX),
+			PRSBOMAttestedWithSBOMFormat(SBOMFormatSPDX),
+		},
+	} {
+		_, err = newPRSBOMAttested(c...)
+		assert.Error(t, err)
+	}
+}
+
+func TestPRSBOMAttestedUnmarshalJSON(t *testing.T) {
+	policyJSONUmarshallerTests[PolicyRequirement]{
+		newDest: func() json.Unmarshaler { return &prSBOMAttested{} },
+		newValidObject: func() (PolicyRequirement, error) {
+			return NewPRSBOMAttested(
+				PRSBOMAttestedWithKeyData([]byte("abc")),
+				PRSBOMAttestedWithSBOMFormat(SBOMFormatSPDX),
+			)
+		},
+		otherJSONParser: newPolicyRequirementFromJSON,
+		breakFns: []func(mSA){
+			// The "type" field is missing
+			func(v mSA) { delete(v, "type") },
+			// Wrong "type" field
+			func(v mSA) { v["type"] = 1 },
+			func(v mSA) { v["type"] = "this is invalid" },
+			// Extra top-level sub-object
+			func(v mSA) { v["unexpected"] = 1 },
+			// All of "keyPath", "keyPaths", "keyData", "keyDatas", and "pki" is missing
+			func(v mSA) { delete(v, "keyData") },
+			// Both "keyPath" and "keyData" is present
+			func(v mSA) { v["keyPath"] = "/foo/bar" },
+			// Both "keyData" and "pki" is present
+			func(v mSA) {
+				v["pki"] = mSA{
+					"caRootsPath":     "/foo/bar",
+					"subjectHostname": "example.com",
+				}
+			},
+			// Invalid "keyPaths" field
+			func(v mSA) { delete(v, "keyData"); v["keyPaths"] = []string{} },
+			// Invalid "keyData" field
+			func(v mSA) { v["keyData"] = "this is invalid base64" },
+			// Invalid "pki" field
+			func(v mSA) { delete(v, "keyData"); v["pki"] = mSA{} },
+			// "fulcio" is not supported
+			func(v mSA) {
+				delete(v, "keyData")
+				v["fulcio"] = mSA{
+					"caPath":       "/foo/baz",
+					"oidcIssuer":   "https://example.com",
+					"subjectEmail": "test@example.com",
+				}
+			},
+			// Invalid "sbomFormat" field
+			func(v mSA) { v["sbomFormat"] = 1 },
+			func(v mSA) { v["sbomFormat"] = "" },
+			func(v mSA) { v["sbomFormat"] = "this is invalid" },
+		},
+		duplicateFields: []string{"type", "keyData", "sbomFormat"},
+	}.run(t)
+
+	// "sbomFormat" is optional
+	var pr prSBOMAttested
+	err := json.Unmarshal([]byte(`{"type":"sbomAttested","keyPath":"/foo/bar"}`), &pr)
+	require.NoError(t, err)
+	assert.Equal(t, prSBOMAttested{prCommon: prCommon{prTypeSBOMAttested}, KeyPath: "/foo/bar"}, pr)
+}
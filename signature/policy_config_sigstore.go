@@ -384,6 +384,17 @@ func PRSigstoreSignedFulcioWithSubjectEmail(subjectEmail string) PRSigstoreSigne
 	}
 }
 
+// PRSigstoreSignedFulcioWithRevocation specifies a value for the "revocation" field when calling NewPRSigstoreSignedFulcio
+func PRSigstoreSignedFulcioWithRevocation(revocation PRSigstoreSignedRevocation) PRSigstoreSignedFulcioOption {
+	return func(f *prSigstoreSignedFulcio) error {
+		if f.Revocation != nil {
+			return InvalidPolicyFormatError(`"revocation" already specified`)
+		}
+		f.Revocation = revocation
+		return nil
+	}
+}
+
 // newPRSigstoreSignedFulcio is NewPRSigstoreSignedFulcio, except it returns the private type
 func newPRSigstoreSignedFulcio(options ...PRSigstoreSignedFulcioOption) (*prSigstoreSignedFulcio, error) {
 	res := prSigstoreSignedFulcio{}
@@ -420,7 +431,8 @@ var _ json.Unmarshaler = (*prSigstoreSignedFulcio)(nil)
 func (f *prSigstoreSignedFulcio) UnmarshalJSON(data []byte) error {
 	*f = prSigstoreSignedFulcio{}
 	var tmp prSigstoreSignedFulcio
-	var gotCAPath, gotCAData, gotOIDCIssuer, gotSubjectEmail bool // = false...
+	var gotCAPath, gotCAData, gotOIDCIssuer, gotSubjectEmail, gotRevocation bool // = false...
+	var revocation prSigstoreSignedRevocation
 	if err := internal.ParanoidUnmarshalJSONObject(data, func(key string) any {
 		switch key {
 		case "caPath":
@@ -435,6 +447,9 @@ func (f *prSigstoreSignedFulcio) UnmarshalJSON(data []byte) error {
 		case "subjectEmail":
 			gotSubjectEmail = true
 			return &tmp.SubjectEmail
+		case "revocation":
+			gotRevocation = true
+			return &revocation
 		default:
 			return nil
 		}
@@ -455,6 +470,9 @@ func (f *prSigstoreSignedFulcio) UnmarshalJSON(data []byte) error {
 	if gotSubjectEmail {
 		opts = append(opts, PRSigstoreSignedFulcioWithSubjectEmail(tmp.SubjectEmail))
 	}
+	if gotRevocation {
+		opts = append(opts, PRSigstoreSignedFulcioWithRevocation(&revocation))
+	}
 
 	res, err := newPRSigstoreSignedFulcio(opts...)
 	if err != nil {
@@ -534,6 +552,17 @@ func PRSigstoreSignedPKIWithSubjectHostname(subjectHostname string) PRSigstoreSi
 	}
 }
 
+// PRSigstoreSignedPKIWithRevocation specifies a value for the "revocation" field when calling NewPRSigstoreSignedPKI
+func PRSigstoreSignedPKIWithRevocation(revocation PRSigstoreSignedRevocation) PRSigstoreSignedPKIOption {
+	return func(p *prSigstoreSignedPKI) error {
+		if p.Revocation != nil {
+			return InvalidPolicyFormatError(`"revocation" already specified`)
+		}
+		p.Revocation = revocation
+		return nil
+	}
+}
+
 // newPRSigstoreSignedPKI is NewPRSigstoreSignedPKI, except it returns the private type
 func newPRSigstoreSignedPKI(options ...PRSigstoreSignedPKIOption) (*prSigstoreSignedPKI, error) {
 	res := prSigstoreSignedPKI{}
@@ -572,7 +601,8 @@ var _ json.Unmarshaler = (*prSigstoreSignedPKI)(nil)
 func (p *prSigstoreSignedPKI) UnmarshalJSON(data []byte) error {
 	*p = prSigstoreSignedPKI{}
 	var tmp prSigstoreSignedPKI
-	var gotCARootsPath, gotCARootsData, gotCAIntermediatesPath, gotCAIntermediatesData, gotSubjectEmail, gotSubjectHostname bool
+	var gotCARootsPath, gotCARootsData, gotCAIntermediatesPath, gotCAIntermediatesData, gotSubjectEmail, gotSubjectHostname, gotRevocation bool
+	var revocation prSigstoreSignedRevocation
 	if err := internal.ParanoidUnmarshalJSONObject(data, func(key string) any {
 		switch key {
 		case "caRootsPath":
@@ -593,6 +623,9 @@ func (p *prSigstoreSignedPKI) UnmarshalJSON(data []byte) error {
 		case "subjectHostname":
 			gotSubjectHostname = true
 			return &tmp.SubjectHostname
+		case "revocation":
+			gotRevocation = true
+			return &revocation
 		default:
 			return nil
 		}
@@ -619,6 +652,9 @@ func (p *prSigstoreSignedPKI) UnmarshalJSON(data []byte) error {
 	if gotSubjectHostname {
 		opts = append(opts, PRSigstoreSignedPKIWithSubjectHostname(tmp.SubjectHostname))
 	}
+	if gotRevocation {
+		opts = append(opts, PRSigstoreSignedPKIWithRevocation(&revocation))
+	}
 
 	res, err := newPRSigstoreSignedPKI(opts...)
 	if err != nil {
@@ -628,3 +664,124 @@ func (p *prSigstoreSignedPKI) UnmarshalJSON(data []byte) error {
 	*p = *res
 	return nil
 }
+
+// PRSigstoreSignedRevocationOption is a way to pass values to NewPRSigstoreSignedRevocation
+type PRSigstoreSignedRevocationOption func(*prSigstoreSignedRevocation) error
+
+// PRSigstoreSignedRevocationWithFailureMode specifies a value for the "failureMode" field when calling NewPRSigstoreSignedRevocation
+func PRSigstoreSignedRevocationWithFailureMode(failureMode string) PRSigstoreSignedRevocationOption {
+	return func(r *prSigstoreSignedRevocation) error {
+		if r.FailureMode != "" {
+			return InvalidPolicyFormatError(`"failureMode" already specified`)
+		}
+		r.FailureMode = failureMode
+		return nil
+	}
+}
+
+// PRSigstoreSignedRevocationWithFetchCRLs specifies a value for the "fetchCRLs" field when calling NewPRSigstoreSignedRevocation
+func PRSigstoreSignedRevocationWithFetchCRLs(fetchCRLs bool) PRSigstoreSignedRevocationOption {
+	return func(r *prSigstoreSignedRevocation) error {
+		r.FetchCRLs = fetchCRLs
+		return nil
+	}
+}
+
+// PRSigstoreSignedRevocationWithCRLPaths specifies a value for the "crlPaths" field when calling NewPRSigstoreSignedRevocation
+func PRSigstoreSignedRevocationWithCRLPaths(crlPaths []string) PRSigstoreSignedRevocationOption {
+	return func(r *prSigstoreSignedRevocation) error {
+		if r.CRLPaths != nil {
+			return InvalidPolicyFormatError(`"crlPaths" already specified`)
+		}
+		if len(crlPaths) == 0 {
+			return InvalidPolicyFormatError(`"crlPaths" contains no entries`)
+		}
+		r.CRLPaths = crlPaths
+		return nil
+	}
+}
+
+// PRSigstoreSignedRevocationWithAcceptStapledOCSP specifies a value for the "acceptStapledOCSP" field when calling NewPRSigstoreSignedRevocation
+func PRSigstoreSignedRevocationWithAcceptStapledOCSP(acceptStapledOCSP bool) PRSigstoreSignedRevocationOption {
+	return func(r *prSigstoreSignedRevocation) error {
+		r.AcceptStapledOCSP = acceptStapledOCSP
+		return nil
+	}
+}
+
+// newPRSigstoreSignedRevocation is NewPRSigstoreSignedRevocation, except it returns the private type
+func newPRSigstoreSignedRevocation(options ...PRSigstoreSignedRevocationOption) (*prSigstoreSignedRevocation, error) {
+	res := prSigstoreSignedRevocation{}
+	for _, o := range options {
+		if err := o(&res); err != nil {
+			return nil, err
+		}
+	}
+
+	switch res.FailureMode {
+	case "", revocationFailureModeHard, revocationFailureModeSoft:
+	default:
+		return nil, InvalidPolicyFormatError(fmt.Sprintf("Unknown revocation failureMode %q", res.FailureMode))
+	}
+	if !res.FetchCRLs && res.CRLPaths == nil && !res.AcceptStapledOCSP {
+		return nil, InvalidPolicyFormatError("At least one of fetchCRLs, crlPaths, acceptStapledOCSP must be specified")
+	}
+
+	return &res, nil
+}
+
+// NewPRSigstoreSignedRevocation returns a PRSigstoreSignedRevocation based on options.
+func NewPRSigstoreSignedRevocation(options ...PRSigstoreSignedRevocationOption) (PRSigstoreSignedRevocation, error) {
+	return newPRSigstoreSignedRevocation(options...)
+}
+
+// Compile-time check that prSigstoreSignedRevocation implements json.Unmarshaler.
+var _ json.Unmarshaler = (*prSigstoreSignedRevocation)(nil)
+
+func (r *prSigstoreSignedRevocation) UnmarshalJSON(data []byte) error {
+	*r = prSigstoreSignedRevocation{}
+	var tmp prSigstoreSignedRevocation
+	var gotFailureMode, gotFetchCRLs, gotCRLPaths, gotAcceptStapledOCSP bool // = false...
+	if err := internal.ParanoidUnmarshalJSONObject(data, func(key string) any {
+		switch key {
+		case "failureMode":
+			gotFailureMode = true
+			return &tmp.FailureMode
+		case "fetchCRLs":
+			gotFetchCRLs = true
+			return &tmp.FetchCRLs
+		case "crlPaths":
+			gotCRLPaths = true
+			return &tmp.CRLPaths
+		case "acceptStapledOCSP":
+			gotAcceptStapledOCSP = true
+			return &tmp.AcceptStapledOCSP
+		default:
+			return nil
+		}
+	}); err != nil {
+		return err
+	}
+
+	var opts []PRSigstoreSignedRevocationOption
+	if gotFailureMode {
+		opts = append(opts, PRSigstoreSignedRevocationWithFailureMode(tmp.FailureMode))
+	}
+	if gotFetchCRLs {
+		opts = append(opts, PRSigstoreSignedRevocationWithFetchCRLs(tmp.FetchCRLs))
+	}
+	if gotCRLPaths {
+		opts = append(opts, PRSigstoreSignedRevocationWithCRLPaths(tmp.CRLPaths))
+	}
+	if gotAcceptStapledOCSP {
+		opts = append(opts, PRSigstoreSignedRevocationWithAcceptStapledOCSP(tmp.AcceptStapledOCSP))
+	}
+
+	res, err := newPRSigstoreSignedRevocation(opts...)
+	if err != nil {
+		return err
+	}
+
+	*r = *res
+	return nil
+}
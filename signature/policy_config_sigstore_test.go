@@ -1022,6 +1022,9 @@ func TestPRSigstoreSignedPKIUnmarshalJSON(t *testing.T) {
 				PRSigstoreSignedPKIWithCAIntermediatesPath("fixtures/pki_intermediate_crts.pem"),
 				PRSigstoreSignedPKIWithSubjectHostname("myhost.example.com"),
 				PRSigstoreSignedPKIWithSubjectEmail("qiwan@redhat.com"),
+				PRSigstoreSignedPKIWithRevocation(xNewPRSigstoreSignedRevocation(
+					PRSigstoreSignedRevocationWithFetchCRLs(true),
+				)),
 			)
 		},
 		otherJSONParser: nil,
@@ -1047,8 +1050,11 @@ func TestPRSigstoreSignedPKIUnmarshalJSON(t *testing.T) {
 				delete(v, "subjectHostname")
 				delete(v, "subjectEmail")
 			},
+			// Invalid "revocation" field
+			func(v mSA) { v["revocation"] = 1 },
+			func(v mSA) { v["revocation"] = mSA{} },
 		},
-		duplicateFields: []string{"caRootsPath", "caIntermediatesPath", "subjectHostname", "subjectEmail"},
+		duplicateFields: []string{"caRootsPath", "caIntermediatesPath", "subjectHostname", "subjectEmail", "revocation"},
 	}.run(t)
 
 	// Test caRootsData specifics
@@ -1074,3 +1080,122 @@ func TestPRSigstoreSignedPKIUnmarshalJSON(t *testing.T) {
 		duplicateFields: []string{"caRootsData", "caIntermediatesData", "subjectHostname", "subjectEmail"},
 	}.run(t)
 }
+
+// xNewPRSigstoreSignedRevocation is like NewPRSigstoreSignedRevocation, except it must not fail.
+func xNewPRSigstoreSignedRevocation(options ...PRSigstoreSignedRevocationOption) PRSigstoreSignedRevocation {
+	r, err := NewPRSigstoreSignedRevocation(options...)
+	if err != nil {
+		panic("NewPRSigstoreSignedRevocation failed")
+	}
+	return r
+}
+
+func TestNewPRSigstoreSignedRevocation(t *testing.T) {
+	testCRLPaths := []string{"/foo/bar.crl", "/foo/baz.crl"}
+
+	// Success:
+	for _, c := range []struct {
+		options  []PRSigstoreSignedRevocationOption
+		expected prSigstoreSignedRevocation
+	}{
+		{
+			options: []PRSigstoreSignedRevocationOption{
+				PRSigstoreSignedRevocationWithFetchCRLs(true),
+			},
+			expected: prSigstoreSignedRevocation{
+				FetchCRLs: true,
+			},
+		},
+		{
+			options: []PRSigstoreSignedRevocationOption{
+				PRSigstoreSignedRevocationWithFailureMode("softFail"),
+				PRSigstoreSignedRevocationWithCRLPaths(testCRLPaths),
+			},
+			expected: prSigstoreSignedRevocation{
+				FailureMode: "softFail",
+				CRLPaths:    testCRLPaths,
+			},
+		},
+		{
+			options: []PRSigstoreSignedRevocationOption{
+				PRSigstoreSignedRevocationWithFailureMode("hardFail"),
+				PRSigstoreSignedRevocationWithFetchCRLs(true),
+				PRSigstoreSignedRevocationWithCRLPaths(testCRLPaths),
+				PRSigstoreSignedRevocationWithAcceptStapledOCSP(true),
+			},
+			expected: prSigstoreSignedRevocation{
+				FailureMode:       "hardFail",
+				FetchCRLs:         true,
+				CRLPaths:          testCRLPaths,
+				AcceptStapledOCSP: true,
+			},
+		},
+	} {
+		r, err := newPRSigstoreSignedRevocation(c.options...)
+		require.NoError(t, err)
+		assert.Equal(t, &c.expected, r)
+	}
+
+	for _, c := range [][]PRSigstoreSignedRevocationOption{
+		{}, // Nothing to check
+		{ // Nothing to check
+			PRSigstoreSignedRevocationWithFailureMode("softFail"),
+			PRSigstoreSignedRevocationWithFetchCRLs(false),
+		},
+		{ // Invalid failureMode
+			PRSigstoreSignedRevocationWithFailureMode("sometimesFail"),
+			PRSigstoreSignedRevocationWithFetchCRLs(true),
+		},
+		{ // Duplicate failureMode
+			PRSigstoreSignedRevocationWithFailureMode("softFail"),
+			PRSigstoreSignedRevocationWithFailureMode("hardFail"),
+			PRSigstoreSignedRevocationWithFetchCRLs(true),
+		},
+		{ // Duplicate crlPaths
+			PRSigstoreSignedRevocationWithCRLPaths(testCRLPaths),
+			PRSigstoreSignedRevocationWithCRLPaths([]string{"/foo/other.crl"}),
+		},
+		{ // Empty crlPaths
+			PRSigstoreSignedRevocationWithCRLPaths([]string{}),
+		},
+	} {
+		_, err := newPRSigstoreSignedRevocation(c...)
+		assert.Error(t, err)
+	}
+}
+
+func TestPRSigstoreSignedRevocationUnmarshalJSON(t *testing.T) {
+	policyJSONUmarshallerTests[PRSigstoreSignedRevocation]{
+		newDest: func() json.Unmarshaler { return &prSigstoreSignedRevocation{} },
+		newValidObject: func() (PRSigstoreSignedRevocation, error) {
+			return NewPRSigstoreSignedRevocation(
+				PRSigstoreSignedRevocationWithFailureMode("softFail"),
+				PRSigstoreSignedRevocationWithFetchCRLs(true),
+				PRSigstoreSignedRevocationWithCRLPaths([]string{"/foo/bar.crl"}),
+				PRSigstoreSignedRevocationWithAcceptStapledOCSP(true),
+			)
+		},
+		otherJSONParser: nil,
+		breakFns: []func(mSA){
+			// Extra top-level sub-object
+			func(v mSA) { v["unexpected"] = 1 },
+			// Invalid "failureMode" field
+			func(v mSA) { v["failureMode"] = 1 },
+			func(v mSA) { v["failureMode"] = "sometimesFail" },
+			// Invalid "fetchCRLs" field
+			func(v mSA) { v["fetchCRLs"] = "yes" },
+			// Invalid "crlPaths" field
+			func(v mSA) { v["crlPaths"] = 1 },
+			func(v mSA) { v["crlPaths"] = []string{} },
+			// Invalid "acceptStapledOCSP" field
+			func(v mSA) { v["acceptStapledOCSP"] = "yes" },
+			// Nothing to check
+			func(v mSA) {
+				delete(v, "fetchCRLs")
+				delete(v, "crlPaths")
+				delete(v, "acceptStapledOCSP")
+			},
+		},
+		duplicateFields: []string{"failureMode", "fetchCRLs", "crlPaths", "acceptStapledOCSP"},
+	}.run(t)
+}
@@ -804,6 +804,23 @@ func TestNewPRSignedBy(t *testing.T) {
 	// Invalid signedIdentity
 	_, err = newPRSignedBy(SBKeyTypeGPGKeys, testPath, nil, nil, nil)
 	assert.Error(t, err)
+
+	// Timestamp authority options
+	pr, err = newPRSignedBy(SBKeyTypeGPGKeys, testPath, nil, nil, testIdentity, PRSignedByWithTimestampAuthorityCAPath("/tsa/path"))
+	require.NoError(t, err)
+	assert.Equal(t, &prSignedByTimestampAuthority{CAPath: "/tsa/path"}, pr.TimestampAuthority)
+	pr, err = newPRSignedBy(SBKeyTypeGPGKeys, testPath, nil, nil, testIdentity, PRSignedByWithTimestampAuthorityCAData([]byte("tsa data")))
+	require.NoError(t, err)
+	assert.Equal(t, &prSignedByTimestampAuthority{CAData: []byte("tsa data")}, pr.TimestampAuthority)
+	// Duplicate timestamp authority options
+	_, err = newPRSignedBy(SBKeyTypeGPGKeys, testPath, nil, nil, testIdentity,
+		PRSignedByWithTimestampAuthorityCAPath("/tsa/path"), PRSignedByWithTimestampAuthorityCAData([]byte("tsa data")))
+	assert.Error(t, err)
+	// Empty timestamp authority values
+	_, err = newPRSignedBy(SBKeyTypeGPGKeys, testPath, nil, nil, testIdentity, PRSignedByWithTimestampAuthorityCAPath(""))
+	assert.Error(t, err)
+	_, err = newPRSignedBy(SBKeyTypeGPGKeys, testPath, nil, nil, testIdentity, PRSignedByWithTimestampAuthorityCAData(nil))
+	assert.Error(t, err)
 }
 
 func TestNewPRSignedByKeyPath(t *testing.T) {
@@ -909,6 +926,39 @@ func TestPRSignedByUnmarshalJSON(t *testing.T) {
 		otherJSONParser: newPolicyRequirementFromJSON,
 		duplicateFields: []string{"type", "keyType", "keyPaths", "signedIdentity"},
 	}.run(t)
+	// Test the timestampAuthority-specific aspects
+	policyJSONUmarshallerTests[PolicyRequirement]{
+		newDest: func() json.Unmarshaler { return &prSignedBy{} },
+		newValidObject: func() (PolicyRequirement, error) {
+			return NewPRSignedByKeyPath(SBKeyTypeGPGKeys, "/foo/bar", NewPRMMatchRepoDigestOrExact(),
+				PRSignedByWithTimestampAuthorityCAPath("/tsa/path"))
+		},
+		otherJSONParser: newPolicyRequirementFromJSON,
+		breakFns: []func(mSA){
+			// Invalid "timestampAuthority" field
+			func(v mSA) { v["timestampAuthority"] = 1 },
+			// Extra "timestampAuthority" sub-field
+			func(v mSA) { v["timestampAuthority"] = mSA{"caPath": "/tsa/path", "unexpected": 1} },
+			// Both "caPath" and "caData" are present
+			func(v mSA) { v["timestampAuthority"] = mSA{"caPath": "/tsa/path", "caData": []byte("tsa data")} },
+			// Neither "caPath" nor "caData" is present
+			func(v mSA) { v["timestampAuthority"] = mSA{} },
+			// Invalid "caPath" field
+			func(v mSA) { v["timestampAuthority"] = mSA{"caPath": 1} },
+			// Invalid "caData" field
+			func(v mSA) { v["timestampAuthority"] = mSA{"caData": "this is invalid base64"} },
+		},
+		duplicateFields: []string{"type", "keyType", "keyPath", "signedIdentity", "timestampAuthority"},
+	}.run(t)
+	policyJSONUmarshallerTests[PolicyRequirement]{
+		newDest: func() json.Unmarshaler { return &prSignedBy{} },
+		newValidObject: func() (PolicyRequirement, error) {
+			return NewPRSignedByKeyPath(SBKeyTypeGPGKeys, "/foo/bar", NewPRMMatchRepoDigestOrExact(),
+				PRSignedByWithTimestampAuthorityCAData([]byte("tsa data")))
+		},
+		otherJSONParser: newPolicyRequirementFromJSON,
+		duplicateFields: []string{"type", "keyType", "keyPath", "signedIdentity", "timestampAuthority"},
+	}.run(t)
 
 	var pr prSignedBy
 
@@ -0,0 +1,144 @@
+package signature
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/containers/image/v5/signature/internal"
+)
+
+// PRWebhookOption is a way to pass values to NewPRWebhook
+type PRWebhookOption func(*prWebhook) error
+
+// PRWebhookWithTimeoutSeconds specifies a value for the "timeoutSeconds" field when calling NewPRWebhook.
+func PRWebhookWithTimeoutSeconds(timeoutSeconds int) PRWebhookOption {
+	return func(pr *prWebhook) error {
+		if pr.TimeoutSeconds != 0 {
+			return InvalidPolicyFormatError(`"timeoutSeconds" already specified`)
+		}
+		if timeoutSeconds <= 0 {
+			return InvalidPolicyFormatError(fmt.Sprintf("invalid timeoutSeconds %d", timeoutSeconds))
+		}
+		pr.TimeoutSeconds = timeoutSeconds
+		return nil
+	}
+}
+
+// PRWebhookWithFailureMode specifies a value for the "failureMode" field when calling NewPRWebhook.
+func PRWebhookWithFailureMode(mode webhookFailureMode) PRWebhookOption {
+	return func(pr *prWebhook) error {
+		if pr.FailureMode != "" {
+			return InvalidPolicyFormatError(`"failureMode" already specified`)
+		}
+		if !mode.IsValid() {
+			return InvalidPolicyFormatError(fmt.Sprintf("invalid failureMode %q", mode))
+		}
+		pr.FailureMode = mode
+		return nil
+	}
+}
+
+// newPRWebhook is NewPRWebhook, except it returns the private type.
+func newPRWebhook(webhookURL string, options ...PRWebhookOption) (*prWebhook, error) {
+	if err := validateWebhookURL(webhookURL); err != nil {
+		return nil, err
+	}
+	res := prWebhook{
+		prCommon: prCommon{Type: prTypeWebhook},
+		URL:      webhookURL,
+	}
+	for _, o := range options {
+		if err := o(&res); err != nil {
+			return nil, err
+		}
+	}
+	return &res, nil
+}
+
+// NewPRWebhook returns a new "webhook" PolicyRequirement, POSTing requests to webhookURL, based on options.
+func NewPRWebhook(webhookURL string, options ...PRWebhookOption) (PolicyRequirement, error) {
+	return newPRWebhook(webhookURL, options...)
+}
+
+// validateWebhookURL returns an error if webhookURL is not usable as prWebhook.URL.
+func validateWebhookURL(webhookURL string) error {
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return InvalidPolicyFormatError(fmt.Sprintf("invalid webhook URL %q: %v", webhookURL, err))
+	}
+	switch u.Scheme {
+	case "http", "https":
+		if u.Host == "" {
+			return InvalidPolicyFormatError(fmt.Sprintf("webhook URL %q does not contain a host", webhookURL))
+		}
+	case "unix":
+		if u.Host != "" || u.Path == "" {
+			return InvalidPolicyFormatError(fmt.Sprintf("webhook URL %q must be of the form unix:///path/to/socket", webhookURL))
+		}
+	default:
+		return InvalidPolicyFormatError(fmt.Sprintf("unsupported webhook URL scheme in %q", webhookURL))
+	}
+	return nil
+}
+
+// Compile-time check that prWebhook implements json.Unmarshaler.
+var _ json.Unmarshaler = (*prWebhook)(nil)
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (pr *prWebhook) UnmarshalJSON(data []byte) error {
+	*pr = prWebhook{}
+	var tmp prWebhook
+	var gotURL, gotTimeoutSeconds, gotFailureMode bool
+	if err := internal.ParanoidUnmarshalJSONObject(data, func(key string) any {
+		switch key {
+		case "type":
+			return &tmp.Type
+		case "url":
+			gotURL = true
+			return &tmp.URL
+		case "timeoutSeconds":
+			gotTimeoutSeconds = true
+			return &tmp.TimeoutSeconds
+		case "failureMode":
+			gotFailureMode = true
+			return &tmp.FailureMode
+		default:
+			return nil
+		}
+	}); err != nil {
+		return err
+	}
+
+	if tmp.Type != prTypeWebhook {
+		return InvalidPolicyFormatError(fmt.Sprintf("Unexpected policy requirement type %q", tmp.Type))
+	}
+	if !gotURL {
+		return InvalidPolicyFormatError("url not specified")
+	}
+
+	var opts []PRWebhookOption
+	if gotTimeoutSeconds {
+		opts = append(opts, PRWebhookWithTimeoutSeconds(tmp.TimeoutSeconds))
+	}
+	if gotFailureMode {
+		opts = append(opts, PRWebhookWithFailureMode(tmp.FailureMode))
+	}
+
+	res, err := newPRWebhook(tmp.URL, opts...)
+	if err != nil {
+		return err
+	}
+	*pr = *res
+	return nil
+}
+
+// IsValid returns true if mode is a supported webhookFailureMode value
+func (mode webhookFailureMode) IsValid() bool {
+	switch mode {
+	case WebhookFailureReject, WebhookFailureAccept:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,128 @@
+package signature
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPRWebhook(t *testing.T) {
+	// Success
+	for _, c := range []struct {
+		url      string
+		options  []PRWebhookOption
+		expected prWebhook
+	}{
+		{
+			url: "https://policy.example.com/verify",
+			expected: prWebhook{
+				prCommon: prCommon{prTypeWebhook},
+				URL:      "https://policy.example.com/verify",
+			},
+		},
+		{
+			url: "http://localhost:8080",
+			options: []PRWebhookOption{
+				PRWebhookWithTimeoutSeconds(5),
+				PRWebhookWithFailureMode(WebhookFailureAccept),
+			},
+			expected: prWebhook{
+				prCommon:       prCommon{prTypeWebhook},
+				URL:            "http://localhost:8080",
+				TimeoutSeconds: 5,
+				FailureMode:    WebhookFailureAccept,
+			},
+		},
+		{
+			url:     "unix:///run/policy.sock",
+			options: []PRWebhookOption{PRWebhookWithFailureMode(WebhookFailureReject)},
+			expected: prWebhook{
+				prCommon:    prCommon{prTypeWebhook},
+				URL:         "unix:///run/policy.sock",
+				FailureMode: WebhookFailureReject,
+			},
+		},
+	} {
+		pr, err := newPRWebhook(c.url, c.options...)
+		require.NoError(t, err, c.url)
+		assert.Equal(t, &c.expected, pr)
+	}
+
+	// Invalid URLs
+	for _, u := range []string{
+		"",
+		"policy.example.com",
+		"ftp://policy.example.com",
+		"https://",
+		"https:///path",
+		"unix://host/run/policy.sock",
+		"unix://",
+		"://invalid",
+	} {
+		_, err := newPRWebhook(u)
+		assert.Error(t, err, u)
+	}
+
+	// Invalid options
+	for _, c := range [][]PRWebhookOption{
+		{PRWebhookWithTimeoutSeconds(0)},
+		{PRWebhookWithTimeoutSeconds(-1)},
+		{ // Duplicate timeoutSeconds
+			PRWebhookWithTimeoutSeconds(1),
+			PRWebhookWithTimeoutSeconds(1),
+		},
+		{PRWebhookWithFailureMode("this is invalid")},
+		{ // Duplicate failureMode
+			PRWebhookWithFailureMode(WebhookFailureAccept),
+			PRWebhookWithFailureMode(WebhookFailureAccept),
+		},
+	} {
+		_, err := newPRWebhook("https://policy.example.com", c...)
+		assert.Error(t, err)
+	}
+}
+
+func TestPRWebhookUnmarshalJSON(t *testing.T) {
+	policyJSONUmarshallerTests[PolicyRequirement]{
+		newDest: func() json.Unmarshaler { return &prWebhook{} },
+		newValidObject: func() (PolicyRequirement, error) {
+			return NewPRWebhook("https://policy.example.com/verify",
+				PRWebhookWithTimeoutSeconds(30),
+				PRWebhookWithFailureMode(WebhookFailureAccept),
+			)
+		},
+		otherJSONParser: newPolicyRequirementFromJSON,
+		breakFns: []func(mSA){
+			// The "type" field is missing
+			func(v mSA) { delete(v, "type") },
+			// Wrong "type" field
+			func(v mSA) { v["type"] = 1 },
+			func(v mSA) { v["type"] = "this is invalid" },
+			// Extra top-level sub-object
+			func(v mSA) { v["unexpected"] = 1 },
+			// The "url" field is missing
+			func(v mSA) { delete(v, "url") },
+			// Invalid "url" field
+			func(v mSA) { v["url"] = 1 },
+			func(v mSA) { v["url"] = "" },
+			func(v mSA) { v["url"] = "ftp://policy.example.com" },
+			// Invalid "timeoutSeconds" field
+			func(v mSA) { v["timeoutSeconds"] = "this is invalid" },
+			func(v mSA) { v["timeoutSeconds"] = 0 },
+			func(v mSA) { v["timeoutSeconds"] = -1 },
+			// Invalid "failureMode" field
+			func(v mSA) { v["failureMode"] = 1 },
+			func(v mSA) { v["failureMode"] = "" },
+			func(v mSA) { v["failureMode"] = "this is invalid" },
+		},
+		duplicateFields: []string{"type", "url", "timeoutSeconds", "failureMode"},
+	}.run(t)
+
+	// "timeoutSeconds" and "failureMode" are optional
+	var pr prWebhook
+	err := json.Unmarshal([]byte(`{"type":"webhook","url":"unix:///run/policy.sock"}`), &pr)
+	require.NoError(t, err)
+	assert.Equal(t, prWebhook{prCommon: prCommon{prTypeWebhook}, URL: "unix:///run/policy.sock"}, pr)
+}
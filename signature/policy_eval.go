@@ -8,6 +8,7 @@ package signature
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/containers/image/v5/internal/private"
 	"github.com/containers/image/v5/internal/unparsedimage"
@@ -144,13 +145,27 @@ func (pc *PolicyContext) requirementsForImageRef(ref types.ImageReference) Polic
 			return req
 		}
 
-		// Look for a match of the possible parent namespaces.
+		// Pattern scopes, if the transport supports them, matching identity.
+		var patterns []string
+		if transport, ok := ref.Transport().(private.ImageTransportWithScopePatterns); ok {
+			patterns = matchingScopePatterns(transport, transportScopes, identity)
+		}
+
+		// Look for a match of the possible parent namespaces, preferring patterns of the same specificity.
 		for _, name := range ref.PolicyConfigurationNamespaces() {
+			if pattern, ok := bestScopePattern(patterns, name); ok {
+				logrus.Debugf(` Using transport %q pattern policy section %q`, transportName, pattern)
+				return transportScopes[pattern]
+			}
 			if req, ok := transportScopes[name]; ok {
 				logrus.Debugf(` Using transport %q specific policy section %q`, transportName, name)
 				return req
 			}
 		}
+		if pattern, ok := bestScopePattern(patterns, ""); ok {
+			logrus.Debugf(` Using transport %q pattern policy section %q`, transportName, pattern)
+			return transportScopes[pattern]
+		}
 
 		// Look for a default match for the transport.
 		if req, ok := transportScopes[""]; ok {
@@ -163,6 +178,44 @@ func (pc *PolicyContext) requirementsForImageRef(ref types.ImageReference) Polic
 	return pc.Policy.Default
 }
 
+// matchingScopePatterns returns the pattern scopes in transportScopes matching identity.
+func matchingScopePatterns(transport private.ImageTransportWithScopePatterns, transportScopes PolicyTransportScopes, identity string) []string {
+	res := []string{}
+	for scope := range transportScopes {
+		if scope != "" && transport.PolicyConfigurationScopeIsPattern(scope) &&
+			transport.PolicyConfigurationScopePatternMatches(scope, identity) {
+			res = append(res, scope)
+		}
+	}
+	return res
+}
+
+// bestScopePattern returns the most specific of patterns which start with namespace, a PolicyConfigurationNamespaces() value,
+// followed by a separator, or of all patterns if namespace is "".
+// The most specific pattern is the one with the most non-"*" characters; ties are broken by choosing the
+// lexicographically smallest pattern, so that the choice does not depend on map iteration order.
+//
+// The caller checks namespaces from the most specific one, so a pattern is only preferred over a namespace scope if it is
+// at least as specific; e.g. "docker.io/*/*:*" does not override "docker.io/library/busybox".
+func bestScopePattern(patterns []string, namespace string) (string, bool) {
+	best := ""
+	bestSpecificity := -1
+	for _, pattern := range patterns {
+		if namespace != "" {
+			rest, ok := strings.CutPrefix(pattern, namespace)
+			if !ok || rest == "" || !strings.ContainsRune("/:@", rune(rest[0])) {
+				continue
+			}
+		}
+		specificity := len(pattern) - strings.Count(pattern, "*")
+		if specificity > bestSpecificity || (specificity == bestSpecificity && pattern < best) {
+			best = pattern
+			bestSpecificity = specificity
+		}
+	}
+	return best, bestSpecificity != -1
+}
+
 // GetSignaturesWithAcceptedAuthor returns those signatures from an image
 // for which the policy accepts the author (and which have been successfully
 // verified).
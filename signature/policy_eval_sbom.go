@@ -0,0 +1,299 @@
+// Policy evaluation for prSBOMAttested.
+
+package signature
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/containers/image/v5/internal/multierr"
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/internal/signature"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/signature/internal"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	sigstoreSignature "github.com/sigstore/sigstore/pkg/signature"
+)
+
+const (
+	// inTotoPayloadType is the DSSE payload type of in-toto statements.
+	inTotoPayloadType = "application/vnd.in-toto+json"
+	// spdxPredicateType and cycloneDXPredicateType are the in-toto predicate types of SBOMs;
+	// versioned variants use these values followed by "/" and a version.
+	spdxPredicateType      = "https://spdx.dev/Document"
+	cycloneDXPredicateType = "https://cyclonedx.org/bom"
+)
+
+// untrustedDSSEEnvelope is a DSSE envelope, as used by sigstore attestations.
+type untrustedDSSEEnvelope struct {
+	PayloadType string                   `json:"payloadType"`
+	Payload     string                   `json:"payload"` // base64-encoded
+	Signatures  []untrustedDSSESignature `json:"signatures"`
+}
+
+// untrustedDSSESignature is a single signature in untrustedDSSEEnvelope.
+type untrustedDSSESignature struct {
+	KeyID     string `json:"keyid"`
+	Signature string `json:"sig"` // base64-encoded
+}
+
+// untrustedInTotoStatement contains the fields of an in-toto statement we care about.
+type untrustedInTotoStatement struct {
+	Type          string                   `json:"_type"`
+	PredicateType string                   `json:"predicateType"`
+	Subject       []untrustedInTotoSubject `json:"subject"`
+}
+
+// untrustedInTotoSubject is a single subject of untrustedInTotoStatement.
+type untrustedInTotoSubject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// dssePreAuthenticationEncoding returns the data signed by DSSE signatures for payloadType and payload.
+func dssePreAuthenticationEncoding(payloadType string, payload []byte) []byte {
+	return fmt.Appendf(nil, "DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload)
+}
+
+// sbomPredicateTypeMatches returns true if predicateType identifies an SBOM in a format accepted by format;
+// an empty format accepts all supported SBOM formats.
+func sbomPredicateTypeMatches(predicateType string, format sbomFormat) bool {
+	var accepted []string
+	switch format {
+	case SBOMFormatSPDX:
+		accepted = []string{spdxPredicateType}
+	case SBOMFormatCycloneDX:
+		accepted = []string{cycloneDXPredicateType}
+	default:
+		accepted = []string{spdxPredicateType, cycloneDXPredicateType}
+	}
+	for _, t := range accepted {
+		if predicateType == t || strings.HasPrefix(predicateType, t+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// prepareTrustRoot returns a parsed version of the keys or PKI configured in pr.
+func (pr *prSBOMAttested) prepareTrustRoot() (*sigstoreSignedTrustRoot, error) {
+	res := sigstoreSignedTrustRoot{}
+
+	publicKeyPEMs, err := loadBytesFromConfigSources(configBytesSources{
+		inconsistencyErrorMessage: `Internal inconsistency: more than one of "keyPath", "keyPaths", "keyData", "keyDatas" specified`,
+		path:                      pr.KeyPath,
+		paths:                     pr.KeyPaths,
+		data:                      pr.KeyData,
+		datas:                     pr.KeyDatas, // codespell:ignore datas
+	})
+	if err != nil {
+		return nil, err
+	}
+	if publicKeyPEMs != nil {
+		for index, keyData := range publicKeyPEMs {
+			pk, err := cryptoutils.UnmarshalPEMToPublicKey(keyData)
+			if err != nil {
+				return nil, fmt.Errorf("parsing public key %d: %w", index+1, err)
+			}
+			res.publicKeys = append(res.publicKeys, pk)
+		}
+		if len(res.publicKeys) == 0 {
+			return nil, errors.New(`Internal inconsistency: "keyPath", "keyPaths", "keyData" and "keyDatas" produced no public keys`)
+		}
+	}
+
+	if pr.PKI != nil {
+		p, err := pr.PKI.prepareTrustRoot()
+		if err != nil {
+			return nil, err
+		}
+		res.pki = p
+	}
+
+	return &res, nil
+}
+
+func (pr *prSBOMAttested) isSignatureAuthorAccepted(ctx context.Context, image private.UnparsedImage, sig []byte) (signatureAcceptanceResult, *Signature, error) {
+	// Attestations are not signatures of the image identity, so there is nothing meaningful to return in Signature.
+	return sarRejected, nil, errors.New("isSignatureAuthorAccepted is not implemented for sbomAttested")
+}
+
+// isAttestationAccepted returns whether att is an SBOM attestation for image, signed by a key in trustRoot.
+func (pr *prSBOMAttested) isAttestationAccepted(ctx context.Context, image private.UnparsedImage, trustRoot *sigstoreSignedTrustRoot, att signature.Sigstore) (signatureAcceptanceResult, error) {
+	var publicKeys []crypto.PublicKey
+	switch {
+	case trustRoot.publicKeys != nil && trustRoot.pki != nil: // newPRSBOMAttested rejects more than one key sources.
+		return sarRejected, errors.New("Internal inconsistency: Both a public key and PKI specified")
+	case trustRoot.publicKeys != nil:
+		publicKeys = trustRoot.publicKeys
+	case trustRoot.pki != nil:
+		untrustedAnnotations := att.UntrustedAnnotations()
+		untrustedCert, ok := untrustedAnnotations[signature.SigstoreCertificateAnnotationKey]
+		if !ok {
+			return sarRejected, fmt.Errorf("missing %s annotation", signature.SigstoreCertificateAnnotationKey)
+		}
+		var untrustedIntermediateChainBytes []byte
+		if untrustedIntermediateChain, ok := untrustedAnnotations[signature.SigstoreIntermediateCertificateChainAnnotationKey]; ok {
+			untrustedIntermediateChainBytes = []byte(untrustedIntermediateChain)
+		}
+		untrustedOCSPResponse, err := untrustedOCSPResponseFromAnnotations(untrustedAnnotations)
+		if err != nil {
+			return sarRejected, err
+		}
+		pk, err := verifyPKI(trustRoot.pki, []byte(untrustedCert), untrustedIntermediateChainBytes, untrustedOCSPResponse)
+		if err != nil {
+			return sarRejected, err
+		}
+		publicKeys = []crypto.PublicKey{pk}
+	default: // newPRSBOMAttested rejects empty key sources.
+		return sarRejected, errors.New("Internal inconsistency: A public key or PKI must be specified.")
+	}
+
+	var envelope untrustedDSSEEnvelope
+	if err := json.Unmarshal(att.UntrustedPayload(), &envelope); err != nil {
+		return sarRejected, internal.NewInvalidSignatureError(fmt.Sprintf("invalid DSSE envelope: %v", err))
+	}
+	if envelope.PayloadType != inTotoPayloadType {
+		return sarRejected, internal.NewInvalidSignatureError(fmt.Sprintf("unexpected DSSE payload type %q", envelope.PayloadType))
+	}
+	payload, err := base64.StdEncoding.DecodeString(envelope.Payload)
+	if err != nil {
+		return sarRejected, internal.NewInvalidSignatureError(fmt.Sprintf("invalid DSSE payload: %v", err))
+	}
+	if err := verifyDSSESignatures(publicKeys, envelope, payload); err != nil {
+		return sarRejected, err
+	}
+
+	var statement untrustedInTotoStatement
+	if err := json.Unmarshal(payload, &statement); err != nil {
+		return sarRejected, internal.NewInvalidSignatureError(fmt.Sprintf("invalid in-toto statement: %v", err))
+	}
+	if !sbomPredicateTypeMatches(statement.PredicateType, pr.SBOMFormat) {
+		if pr.SBOMFormat != "" {
+			return sarRejected, PolicyRequirementError(fmt.Sprintf("Attestation predicate type %q is not a %s SBOM", statement.PredicateType, pr.SBOMFormat))
+		}
+		return sarRejected, PolicyRequirementError(fmt.Sprintf("Attestation predicate type %q is not a supported SBOM", statement.PredicateType))
+	}
+
+	m, _, err := image.Manifest(ctx)
+	if err != nil {
+		return sarRejected, err
+	}
+	for _, subject := range statement.Subject {
+		for algo, value := range subject.Digest {
+			d := digest.NewDigestFromEncoded(digest.Algorithm(algo), value)
+			if d.Validate() != nil {
+				continue
+			}
+			matches, err := manifest.MatchesDigest(m, d)
+			if err != nil {
+				return sarRejected, err
+			}
+			if matches {
+				return sarAccepted, nil
+			}
+		}
+	}
+	return sarRejected, PolicyRequirementError("SBOM attestation subject does not match the image")
+}
+
+// verifyDSSESignatures verifies that at least one of the signatures in envelope, over payload, was created by any of publicKeys.
+func verifyDSSESignatures(publicKeys []crypto.PublicKey, envelope untrustedDSSEEnvelope, payload []byte) error {
+	verifiers := make([]sigstoreSignature.Verifier, 0, len(publicKeys))
+	for _, key := range publicKeys {
+		// As in verifySigstorePayloadBlobSignature, fail on invalid keys even if other keys might be valid.
+		verifier, err := sigstoreSignature.LoadVerifier(key, crypto.SHA256)
+		if err != nil {
+			return err
+		}
+		verifiers = append(verifiers, verifier)
+	}
+
+	pae := dssePreAuthenticationEncoding(envelope.PayloadType, payload)
+	var failures []string
+	for _, untrustedSig := range envelope.Signatures {
+		sig, err := base64.StdEncoding.DecodeString(untrustedSig.Signature)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("invalid DSSE signature encoding: %v", err))
+			continue
+		}
+		for _, verifier := range verifiers {
+			err := verifier.VerifySignature(bytes.NewReader(sig), bytes.NewReader(pae))
+			if err == nil {
+				return nil
+			}
+			failures = append(failures, err.Error())
+		}
+	}
+	if len(failures) == 0 {
+		return internal.NewInvalidSignatureError("DSSE envelope contains no signatures")
+	}
+	return internal.NewInvalidSignatureError("cryptographic signature verification failed: " + strings.Join(failures, ", "))
+}
+
+func (pr *prSBOMAttested) isRunningImageAllowed(ctx context.Context, image private.UnparsedImage) (bool, error) {
+	var attestations []signature.Sigstore
+	if withAttestations, ok := image.(private.UnparsedImageWithAttestations); ok {
+		atts, err := withAttestations.UntrustedAttestations(ctx)
+		if err != nil {
+			return false, err
+		}
+		attestations = append(attestations, atts...)
+	}
+	// Some transports store attestations along with signatures.
+	sigs, err := image.UntrustedSignatures(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, s := range sigs {
+		if sigstoreSig, ok := s.(signature.Sigstore); ok {
+			attestations = append(attestations, sigstoreSig)
+		}
+	}
+
+	var trustRoot *sigstoreSignedTrustRoot // Only prepared when there is something to verify
+	var rejections []error
+	for _, att := range attestations {
+		if att.UntrustedMIMEType() != signature.SigstoreDSSEEnvelopeMIMEType {
+			continue
+		}
+		if trustRoot == nil {
+			trustRoot, err = pr.prepareTrustRoot()
+			if err != nil {
+				return false, err
+			}
+		}
+
+		var reason error
+		switch res, err := pr.isAttestationAccepted(ctx, image, trustRoot, att); res {
+		case sarAccepted:
+			// One accepted attestation is enough.
+			return true, nil
+		case sarRejected:
+			reason = err
+		case sarUnknown:
+			// Huh?! This should not happen at all; treat it as any other invalid value.
+			fallthrough
+		default:
+			reason = fmt.Errorf(`Internal error: Unexpected attestation verification result %q`, string(res))
+		}
+		rejections = append(rejections, reason)
+	}
+	var summary error
+	switch len(rejections) {
+	case 0:
+		summary = PolicyRequirementError("An SBOM attestation was required, but no attestation exists")
+	case 1:
+		summary = rejections[0]
+	default:
+		summary = PolicyRequirementError(multierr.Format("None of the attestations were accepted, reasons: ", "; ", "", rejections).Error())
+	}
+	return false, summary
+}
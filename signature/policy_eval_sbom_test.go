@@ -0,0 +1,239 @@
+package signature
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/containers/image/v5/directory"
+	"github.com/containers/image/v5/internal/image"
+	"github.com/containers/image/v5/internal/imagesource"
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/internal/signature"
+	"github.com/containers/image/v5/manifest"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	sigstoreSignature "github.com/sigstore/sigstore/pkg/signature"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// attestedImageSourceMock inherits dirImageSource, but returns the specified attestations.
+type attestedImageSourceMock struct {
+	private.ImageSource
+	attestations []signature.Sigstore
+	err          error
+}
+
+func (s *attestedImageSourceMock) GetAttestations(ctx context.Context, instanceDigest *digest.Digest) ([]signature.Sigstore, error) {
+	return s.attestations, s.err
+}
+
+// attestedImageMock returns a private.UnparsedImage for a directory, with the specified attestations.
+func attestedImageMock(t *testing.T, dir string, attestations []signature.Sigstore, err error) private.UnparsedImage {
+	srcRef, e := directory.NewReference(dir)
+	require.NoError(t, e)
+	src, e := srcRef.NewImageSource(context.Background(), nil)
+	require.NoError(t, e)
+	t.Cleanup(func() {
+		err := src.Close()
+		require.NoError(t, err)
+	})
+	return image.UnparsedInstance(&attestedImageSourceMock{
+		ImageSource:  imagesource.FromPublic(src),
+		attestations: attestations,
+		err:          err,
+	}, nil)
+}
+
+// sbomTestKey returns a new ECDSA private key and the PEM form of its public key.
+func sbomTestKey(t *testing.T) (*ecdsa.PrivateKey, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	pem, err := cryptoutils.MarshalPublicKeyToPEM(key.Public())
+	require.NoError(t, err)
+	return key, pem
+}
+
+// sbomAttestation returns an attestation with an in-toto statement with predicateType and subjectDigest, signed by key.
+func sbomAttestation(t *testing.T, key *ecdsa.PrivateKey, predicateType string, subjectDigest digest.Digest) signature.Sigstore {
+	statement, err := json.Marshal(map[string]any{
+		"_type":         "https://in-toto.io/Statement/v0.1",
+		"predicateType": predicateType,
+		"subject": []map[string]any{
+			{
+				"name":   "example.com/image",
+				"digest": map[string]string{subjectDigest.Algorithm().String(): subjectDigest.Encoded()},
+			},
+		},
+		"predicate": map[string]any{},
+	})
+	require.NoError(t, err)
+	signer, err := sigstoreSignature.LoadECDSASigner(key, crypto.SHA256)
+	require.NoError(t, err)
+	sig, err := signer.SignMessage(bytes.NewReader(dssePreAuthenticationEncoding(inTotoPayloadType, statement)))
+	require.NoError(t, err)
+	envelope, err := json.Marshal(untrustedDSSEEnvelope{
+		PayloadType: inTotoPayloadType,
+		Payload:     base64.StdEncoding.EncodeToString(statement),
+		Signatures:  []untrustedDSSESignature{{Signature: base64.StdEncoding.EncodeToString(sig)}},
+	})
+	require.NoError(t, err)
+	return signature.SigstoreFromComponents(signature.SigstoreDSSEEnvelopeMIMEType, envelope,
+		map[string]string{signature.SigstoreSignatureAnnotationKey: ""})
+}
+
+func TestSBOMPredicateTypeMatches(t *testing.T) {
+	for _, c := range []struct {
+		predicateType string
+		format        sbomFormat
+		expected      bool
+	}{
+		{"https://spdx.dev/Document", "", true},
+		{"https://spdx.dev/Document/v2.3", "", true},
+		{"https://cyclonedx.org/bom", "", true},
+		{"https://cyclonedx.org/bom/v1.4", "", true},
+		{"https://slsa.dev/provenance/v0.2", "", false},
+		{"https://spdx.dev/DocumentX", "", false},
+		{"https://spdx.dev/Document", SBOMFormatSPDX, true},
+		{"https://spdx.dev/Document", SBOMFormatCycloneDX, false},
+		{"https://cyclonedx.org/bom/v1.5", SBOMFormatCycloneDX, true},
+		{"https://cyclonedx.org/bom", SBOMFormatSPDX, false},
+	} {
+		res := sbomPredicateTypeMatches(c.predicateType, c.format)
+		assert.Equal(t, c.expected, res, "%q %q", c.predicateType, c.format)
+	}
+}
+
+func TestPRSBOMAttestedIsSignatureAuthorAccepted(t *testing.T) {
+	pr, err := newPRSBOMAttested(PRSBOMAttestedWithKeyPath("fixtures/cosign.pub"))
+	require.NoError(t, err)
+	img := attestedImageMock(t, "fixtures/dir-img-unsigned", nil, nil)
+	sar, parsedSig, err := pr.isSignatureAuthorAccepted(context.Background(), img, []byte{})
+	assertSARRejected(t, sar, parsedSig, err)
+}
+
+func TestPRSBOMAttestedIsRunningImageAllowed(t *testing.T) {
+	const dir = "fixtures/dir-img-unsigned"
+	manifestBlob, err := os.ReadFile(dir + "/manifest.json")
+	require.NoError(t, err)
+	manifestDigest, err := manifest.Digest(manifestBlob)
+	require.NoError(t, err)
+	key, keyPEM := sbomTestKey(t)
+	otherKey, otherKeyPEM := sbomTestKey(t)
+
+	spdx := sbomAttestation(t, key, "https://spdx.dev/Document", manifestDigest)
+	cycloneDX := sbomAttestation(t, key, "https://cyclonedx.org/bom/v1.4", manifestDigest)
+
+	// Success
+	for _, c := range []struct {
+		options      []PRSBOMAttestedOption
+		attestations []signature.Sigstore
+	}{
+		{ // Any format
+			options:      []PRSBOMAttestedOption{PRSBOMAttestedWithKeyData(keyPEM)},
+			attestations: []signature.Sigstore{spdx},
+		},
+		{ // Requested format
+			options:      []PRSBOMAttestedOption{PRSBOMAttestedWithKeyData(keyPEM), PRSBOMAttestedWithSBOMFormat(SBOMFormatCycloneDX)},
+			attestations: []signature.Sigstore{cycloneDX},
+		},
+		{ // One of several keys
+			options:      []PRSBOMAttestedOption{PRSBOMAttestedWithKeyDatas([][]byte{otherKeyPEM, keyPEM})},
+			attestations: []signature.Sigstore{spdx},
+		},
+		{ // One of several attestations
+			options:      []PRSBOMAttestedOption{PRSBOMAttestedWithKeyData(keyPEM), PRSBOMAttestedWithSBOMFormat(SBOMFormatSPDX)},
+			attestations: []signature.Sigstore{cycloneDX, spdx},
+		},
+	} {
+		pr, err := newPRSBOMAttested(c.options...)
+		require.NoError(t, err)
+		img := attestedImageMock(t, dir, c.attestations, nil)
+		allowed, err := pr.isRunningImageAllowed(context.Background(), img)
+		assertRunningAllowed(t, allowed, err)
+	}
+
+	// Rejected by policy
+	unsignedEnvelope, err := json.Marshal(untrustedDSSEEnvelope{
+		PayloadType: inTotoPayloadType,
+		Payload:     base64.StdEncoding.EncodeToString([]byte("{}")),
+	})
+	require.NoError(t, err)
+	for _, c := range []struct {
+		options      []PRSBOMAttestedOption
+		attestations []signature.Sigstore
+	}{
+		{ // No attestations
+			options:      []PRSBOMAttestedOption{PRSBOMAttestedWithKeyData(keyPEM)},
+			attestations: nil,
+		},
+		{ // Only non-attestation attachments
+			options: []PRSBOMAttestedOption{PRSBOMAttestedWithKeyData(keyPEM)},
+			attestations: []signature.Sigstore{
+				signature.SigstoreFromComponents(signature.SigstoreSignatureMIMEType, []byte("payload"), nil),
+			},
+		},
+		{ // Wrong format
+			options:      []PRSBOMAttestedOption{PRSBOMAttestedWithKeyData(keyPEM), PRSBOMAttestedWithSBOMFormat(SBOMFormatSPDX)},
+			attestations: []signature.Sigstore{cycloneDX},
+		},
+		{ // Not an SBOM
+			options:      []PRSBOMAttestedOption{PRSBOMAttestedWithKeyData(keyPEM)},
+			attestations: []signature.Sigstore{sbomAttestation(t, key, "https://slsa.dev/provenance/v0.2", manifestDigest)},
+		},
+		{ // Subject does not match
+			options:      []PRSBOMAttestedOption{PRSBOMAttestedWithKeyData(keyPEM)},
+			attestations: []signature.Sigstore{sbomAttestation(t, key, "https://spdx.dev/Document", digest.FromString("other"))},
+		},
+		{ // Signed by a different key
+			options:      []PRSBOMAttestedOption{PRSBOMAttestedWithKeyData(keyPEM)},
+			attestations: []signature.Sigstore{sbomAttestation(t, otherKey, "https://spdx.dev/Document", manifestDigest)},
+		},
+		{ // No signatures in the envelope
+			options: []PRSBOMAttestedOption{PRSBOMAttestedWithKeyData(keyPEM)},
+			attestations: []signature.Sigstore{
+				signature.SigstoreFromComponents(signature.SigstoreDSSEEnvelopeMIMEType, unsignedEnvelope, nil),
+			},
+		},
+		{ // Invalid envelope
+			options: []PRSBOMAttestedOption{PRSBOMAttestedWithKeyData(keyPEM)},
+			attestations: []signature.Sigstore{
+				signature.SigstoreFromComponents(signature.SigstoreDSSEEnvelopeMIMEType, []byte("this is invalid"), nil),
+			},
+		},
+		{ // PKI, no certificate
+			options: []PRSBOMAttestedOption{PRSBOMAttestedWithPKI(&prSigstoreSignedPKI{
+				CARootsPath:     "fixtures/pki_root_crts.pem",
+				SubjectHostname: "myhost.example.com",
+			})},
+			attestations: []signature.Sigstore{spdx},
+		},
+	} {
+		pr, err := newPRSBOMAttested(c.options...)
+		require.NoError(t, err)
+		img := attestedImageMock(t, dir, c.attestations, nil)
+		allowed, err := pr.isRunningImageAllowed(context.Background(), img)
+		assertRunningRejected(t, allowed, err)
+	}
+
+	// Error reading attestations
+	pr, err := newPRSBOMAttested(PRSBOMAttestedWithKeyData(keyPEM))
+	require.NoError(t, err)
+	img := attestedImageMock(t, dir, nil, errors.New("attestations unavailable"))
+	allowed, err := pr.isRunningImageAllowed(context.Background(), img)
+	assertRunningRejected(t, allowed, err)
+
+	// An image without attestation support
+	img = dirImageMock(t, dir, "testing/manifest:latest")
+	allowed, err = pr.isRunningImageAllowed(context.Background(), img)
+	assertRunningRejectedPolicyRequirement(t, allowed, err)
+}
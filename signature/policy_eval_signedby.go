@@ -4,17 +4,35 @@ package signature
 
 import (
 	"context"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"slices"
 
 	"github.com/containers/image/v5/internal/multierr"
 	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/internal/signature"
 	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/signature/internal"
 	digest "github.com/opencontainers/go-digest"
 )
 
 func (pr *prSignedBy) isSignatureAuthorAccepted(ctx context.Context, image private.UnparsedImage, sig []byte) (signatureAcceptanceResult, *Signature, error) {
+	// A raw signature can’t carry a timestamp token, so this always fails if pr.TimestampAuthority is set.
+	var tsaRoots *x509.CertPool
+	if pr.TimestampAuthority != nil {
+		roots, err := pr.TimestampAuthority.trustedRoots()
+		if err != nil {
+			return sarRejected, nil, err
+		}
+		tsaRoots = roots
+	}
+	return pr.isSignatureAccepted(ctx, image, signature.SimpleSigningFromBlob(sig), tsaRoots)
+}
+
+// isSignatureAccepted is the implementation of isSignatureAuthorAccepted, with access to the full signature data.
+// tsaRoots must be pr.TimestampAuthority.trustedRoots() if pr.TimestampAuthority is set, and nil otherwise.
+func (pr *prSignedBy) isSignatureAccepted(ctx context.Context, image private.UnparsedImage, sig signature.SimpleSigning, tsaRoots *x509.CertPool) (signatureAcceptanceResult, *Signature, error) {
 	switch pr.KeyType {
 	case SBKeyTypeGPGKeys:
 	case SBKeyTypeSignedByGPGKeys, SBKeyTypeX509Certificates, SBKeyTypeSignedByX509CAs:
@@ -50,7 +68,23 @@ func (pr *prSignedBy) isSignatureAuthorAccepted(ctx context.Context, image priva
 		return sarRejected, nil, PolicyRequirementError("No public keys imported")
 	}
 
-	signature, err := verifyAndExtractSignature(mech, sig, signatureAcceptanceRules{
+	untrustedSignature := sig.UntrustedSignature()
+	verify := verifyAndExtractSignature
+	if pr.TimestampAuthority != nil {
+		untrustedToken := sig.UntrustedTimestampToken()
+		if untrustedToken == nil {
+			return sarRejected, nil, PolicyRequirementError("A signature timestamp was required, but the signature has no timestamp")
+		}
+		timestamp, err := internal.VerifyRFC3161TimestampToken(tsaRoots, untrustedToken, untrustedSignature)
+		if err != nil {
+			return sarRejected, nil, err
+		}
+		// The signature is known to have existed at timestamp, so evaluate key and signature expiration as of that time.
+		verify = func(mech SigningMechanism, unverifiedSignature []byte, rules signatureAcceptanceRules) (*Signature, error) {
+			return verifyAndExtractSignatureAtTime(mech, unverifiedSignature, timestamp, rules)
+		}
+	}
+	res, err := verify(mech, untrustedSignature, signatureAcceptanceRules{
 		validateKeyIdentity: func(keyIdentity string) error {
 			if slices.Contains(trustedIdentities, keyIdentity) {
 				return nil
@@ -84,20 +118,51 @@ func (pr *prSignedBy) isSignatureAuthorAccepted(ctx context.Context, image priva
 		return sarRejected, nil, err
 	}
 
-	return sarAccepted, signature, nil
+	return sarAccepted, res, nil
+}
+
+// trustedRoots returns the root certificates accepted for time-stamping authorities.
+func (tsa *prSignedByTimestampAuthority) trustedRoots() (*x509.CertPool, error) {
+	caCertPEMs, err := loadBytesFromConfigSources(configBytesSources{
+		inconsistencyErrorMessage: `Internal inconsistency: both "caPath" and "caData" specified`,
+		path:                      tsa.CAPath,
+		data:                      tsa.CAData,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(caCertPEMs) != 1 {
+		return nil, errors.New(`Internal inconsistency: timestampAuthority specified with not exactly one of "caPath" nor "caData"`)
+	}
+	roots := x509.NewCertPool()
+	if ok := roots.AppendCertsFromPEM(caCertPEMs[0]); !ok {
+		return nil, errors.New("error loading time-stamping authority CA certificates")
+	}
+	return roots, nil
 }
 
 func (pr *prSignedBy) isRunningImageAllowed(ctx context.Context, image private.UnparsedImage) (bool, error) {
-	// FIXME: Use image.UntrustedSignatures, use that to improve error messages
+	// FIXME: Use the non-simple-signing signatures to improve error messages
 	// (needs tests!)
-	sigs, err := image.Signatures(ctx)
+	sigs, err := image.UntrustedSignatures(ctx)
 	if err != nil {
 		return false, err
 	}
+	var tsaRoots *x509.CertPool
+	if pr.TimestampAuthority != nil {
+		tsaRoots, err = pr.TimestampAuthority.trustedRoots()
+		if err != nil {
+			return false, err
+		}
+	}
 	var rejections []error
 	for _, s := range sigs {
+		simpleSig, ok := s.(signature.SimpleSigning)
+		if !ok {
+			continue
+		}
 		var reason error
-		switch res, _, err := pr.isSignatureAuthorAccepted(ctx, image, s); res {
+		switch res, _, err := pr.isSignatureAccepted(ctx, image, simpleSig, tsaRoots); res {
 		case sarAccepted:
 			// One accepted signature is enough.
 			return true, nil
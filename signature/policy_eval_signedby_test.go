@@ -1,19 +1,29 @@
 package signature
 
 import (
+	"bytes"
 	"context"
+	"crypto"
 	"os"
 	"path"
 	"testing"
+	"time"
 
 	"github.com/containers/image/v5/directory"
 	"github.com/containers/image/v5/docker/reference"
 	"github.com/containers/image/v5/internal/image"
 	"github.com/containers/image/v5/internal/imagesource"
 	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/internal/signature"
+	"github.com/containers/image/v5/internal/testing/rfc3161tsa"
+	"github.com/containers/image/v5/manifest"
 	"github.com/containers/image/v5/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	//lint:ignore SA1019 This is only used to create test keys and signatures, matching the deprecated implementation used by the package.
+	"golang.org/x/crypto/openpgp"        //nolint:staticcheck
+	"golang.org/x/crypto/openpgp/packet" //nolint:staticcheck
 )
 
 // dirImageMock returns a private.UnparsedImage for a directory, claiming a specified dockerReference.
@@ -278,3 +288,166 @@ func TestPRSignedByIsRunningImageAllowed(t *testing.T) {
 	allowed, err = pr.isRunningImageAllowed(context.Background(), image)
 	assertRunningRejectedPolicyRequirement(t, allowed, err)
 }
+
+// createTimestampedSigDir creates a directory suitable for dirImageMock, containing fixtures/dir-img-valid
+// with a timestamp token issued by tsa attached to the signature.
+func createTimestampedSigDir(t *testing.T, tsa *rfc3161tsa.TSA) string {
+	dir := t.TempDir()
+	manifest, err := os.ReadFile("fixtures/dir-img-valid/manifest.json")
+	require.NoError(t, err)
+	err = os.WriteFile(path.Join(dir, "manifest.json"), manifest, 0644)
+	require.NoError(t, err)
+	sig, err := os.ReadFile("fixtures/dir-img-valid/signature-1")
+	require.NoError(t, err)
+	token, err := tsa.Token(sig, time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	blob, err := signature.Blob(signature.SimpleSigningWithTimestampToken(signature.SimpleSigningFromBlob(sig), token))
+	require.NoError(t, err)
+	err = os.WriteFile(path.Join(dir, "signature-1"), blob, 0644)
+	require.NoError(t, err)
+	return dir
+}
+
+func TestPRSignedByTimestampAuthority(t *testing.T) {
+	ktGPG := SBKeyTypeGPGKeys
+	prm := NewPRMMatchExact()
+	tsa, err := rfc3161tsa.New()
+	require.NoError(t, err)
+	otherTSA, err := rfc3161tsa.New()
+	require.NoError(t, err)
+	timestampedDir := createTimestampedSigDir(t, tsa)
+	caPath := path.Join(t.TempDir(), "tsa.pem")
+	err = os.WriteFile(caPath, tsa.RootCertificatePEM, 0644)
+	require.NoError(t, err)
+
+	// Success, with caPath and caData
+	for _, opt := range []PRSignedByOption{
+		PRSignedByWithTimestampAuthorityCAPath(caPath),
+		PRSignedByWithTimestampAuthorityCAData(tsa.RootCertificatePEM),
+	} {
+		image := dirImageMock(t, timestampedDir, "testing/manifest:latest")
+		pr, err := NewPRSignedByKeyPath(ktGPG, "fixtures/public-key.gpg", prm, opt)
+		require.NoError(t, err)
+		allowed, err := pr.isRunningImageAllowed(context.Background(), image)
+		assertRunningAllowed(t, allowed, err)
+	}
+
+	// Timestamps are ignored if the policy does not require them
+	image := dirImageMock(t, timestampedDir, "testing/manifest:latest")
+	pr, err := NewPRSignedByKeyPath(ktGPG, "fixtures/public-key.gpg", prm)
+	require.NoError(t, err)
+	allowed, err := pr.isRunningImageAllowed(context.Background(), image)
+	assertRunningAllowed(t, allowed, err)
+
+	// Timestamp by an untrusted TSA
+	image = dirImageMock(t, timestampedDir, "testing/manifest:latest")
+	pr, err = NewPRSignedByKeyPath(ktGPG, "fixtures/public-key.gpg", prm,
+		PRSignedByWithTimestampAuthorityCAData(otherTSA.RootCertificatePEM))
+	require.NoError(t, err)
+	allowed, err = pr.isRunningImageAllowed(context.Background(), image)
+	assertRunningRejected(t, allowed, err)
+
+	// Invalid CA data
+	image = dirImageMock(t, timestampedDir, "testing/manifest:latest")
+	pr, err = NewPRSignedByKeyPath(ktGPG, "fixtures/public-key.gpg", prm,
+		PRSignedByWithTimestampAuthorityCAData([]byte("not a certificate")))
+	require.NoError(t, err)
+	allowed, err = pr.isRunningImageAllowed(context.Background(), image)
+	assertRunningRejected(t, allowed, err)
+
+	// A valid signature without a timestamp
+	image = dirImageMock(t, "fixtures/dir-img-valid", "testing/manifest:latest")
+	pr, err = NewPRSignedByKeyPath(ktGPG, "fixtures/public-key.gpg", prm,
+		PRSignedByWithTimestampAuthorityCAData(tsa.RootCertificatePEM))
+	require.NoError(t, err)
+	allowed, err = pr.isRunningImageAllowed(context.Background(), image)
+	assertRunningRejectedPolicyRequirement(t, allowed, err)
+
+	// isSignatureAuthorAccepted has no access to the timestamp
+	image = dirImageMock(t, timestampedDir, "testing/manifest:latest")
+	sig, err := os.ReadFile("fixtures/dir-img-valid/signature-1")
+	require.NoError(t, err)
+	sar, parsedSig, err := pr.isSignatureAuthorAccepted(context.Background(), image, sig)
+	assertSARRejectedPolicyRequirement(t, sar, parsedSig, err)
+}
+
+// createExpiredKeySigDir creates a directory suitable for dirImageMock, containing the manifest from fixtures/dir-img-valid,
+// signed at signingTime by a newly generated key valid from keyCreationTime until keyExpiryTime,
+// with a timestamp token issued by tsa at tokenTime attached to the signature.
+// It returns the directory and the public key.
+func createExpiredKeySigDir(t *testing.T, tsa *rfc3161tsa.TSA, keyCreationTime, keyExpiryTime, signingTime, tokenTime time.Time) (string, []byte) {
+	keyConfig := &packet.Config{DefaultHash: crypto.SHA256, Time: func() time.Time { return keyCreationTime }}
+	entity, err := openpgp.NewEntity("Expired key", "", "expired@example.com", keyConfig)
+	require.NoError(t, err)
+	lifetime := uint32(keyExpiryTime.Sub(keyCreationTime).Seconds())
+	for _, id := range entity.Identities {
+		id.SelfSignature.KeyLifetimeSecs = &lifetime
+		id.SelfSignature.PreferredHash = []uint8{8} // SHA-256
+		err := id.SelfSignature.SignUserId(id.UserId.Id, entity.PrimaryKey, entity.PrivateKey, keyConfig)
+		require.NoError(t, err)
+	}
+	var publicKey bytes.Buffer
+	err = entity.Serialize(&publicKey)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	manifestBlob, err := os.ReadFile("fixtures/dir-img-valid/manifest.json")
+	require.NoError(t, err)
+	err = os.WriteFile(path.Join(dir, "manifest.json"), manifestBlob, 0644)
+	require.NoError(t, err)
+	manifestDigest, err := manifest.Digest(manifestBlob)
+	require.NoError(t, err)
+	payload, err := newUntrustedSignature(manifestDigest, "testing/manifest:latest").MarshalJSON()
+	require.NoError(t, err)
+	var sig bytes.Buffer
+	w, err := openpgp.Sign(&sig, entity, nil, &packet.Config{DefaultHash: crypto.SHA256, Time: func() time.Time { return signingTime }})
+	require.NoError(t, err)
+	_, err = w.Write(payload)
+	require.NoError(t, err)
+	err = w.Close()
+	require.NoError(t, err)
+
+	token, err := tsa.Token(sig.Bytes(), tokenTime)
+	require.NoError(t, err)
+	blob, err := signature.Blob(signature.SimpleSigningWithTimestampToken(signature.SimpleSigningFromBlob(sig.Bytes()), token))
+	require.NoError(t, err)
+	err = os.WriteFile(path.Join(dir, "signature-1"), blob, 0644)
+	require.NoError(t, err)
+	return dir, publicKey.Bytes()
+}
+
+func TestPRSignedByTimestampAuthorityExpiredKey(t *testing.T) {
+	ktGPG := SBKeyTypeGPGKeys
+	prm := NewPRMMatchExact()
+	tsa, err := rfc3161tsa.New()
+	require.NoError(t, err)
+	now := time.Now().Truncate(time.Second)
+	keyCreationTime := now.Add(-30 * time.Minute)
+	keyExpiryTime := now.Add(-20 * time.Minute)
+	signingTime := now.Add(-29 * time.Minute)
+
+	// A signature timestamped while the key was valid is accepted only if the policy requires the timestamp.
+	dir, publicKey := createExpiredKeySigDir(t, tsa, keyCreationTime, keyExpiryTime, signingTime, now.Add(-28*time.Minute))
+	pr, err := NewPRSignedByKeyData(ktGPG, publicKey, prm, PRSignedByWithTimestampAuthorityCAData(tsa.RootCertificatePEM))
+	require.NoError(t, err)
+	allowed, err := pr.isRunningImageAllowed(context.Background(), dirImageMock(t, dir, "testing/manifest:latest"))
+	assertRunningAllowed(t, allowed, err)
+	pr, err = NewPRSignedByKeyData(ktGPG, publicKey, prm)
+	require.NoError(t, err)
+	allowed, err = pr.isRunningImageAllowed(context.Background(), dirImageMock(t, dir, "testing/manifest:latest"))
+	assertRunningRejected(t, allowed, err)
+
+	// Timestamp after the key expired
+	dir, publicKey = createExpiredKeySigDir(t, tsa, keyCreationTime, keyExpiryTime, signingTime, now.Add(-10*time.Minute))
+	pr, err = NewPRSignedByKeyData(ktGPG, publicKey, prm, PRSignedByWithTimestampAuthorityCAData(tsa.RootCertificatePEM))
+	require.NoError(t, err)
+	allowed, err = pr.isRunningImageAllowed(context.Background(), dirImageMock(t, dir, "testing/manifest:latest"))
+	assertRunningRejected(t, allowed, err)
+
+	// Timestamp before the signature was created
+	dir, publicKey = createExpiredKeySigDir(t, tsa, keyCreationTime, keyExpiryTime, signingTime, signingTime.Add(-30*time.Second))
+	pr, err = NewPRSignedByKeyData(ktGPG, publicKey, prm, PRSignedByWithTimestampAuthorityCAData(tsa.RootCertificatePEM))
+	require.NoError(t, err)
+	allowed, err = pr.isRunningImageAllowed(context.Background(), dirImageMock(t, dir, "testing/manifest:latest"))
+	assertRunningRejected(t, allowed, err)
+}
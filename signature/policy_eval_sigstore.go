@@ -7,6 +7,7 @@ import (
 	"crypto"
 	"crypto/ecdsa"
 	"crypto/x509"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"os"
@@ -91,6 +92,13 @@ func (f *prSigstoreSignedFulcio) prepareTrustRoot() (*fulcioTrustRoot, error) {
 		oidcIssuer:     f.OIDCIssuer,
 		subjectEmail:   f.SubjectEmail,
 	}
+	if f.Revocation != nil {
+		revocation, err := f.Revocation.prepareRevocationChecker()
+		if err != nil {
+			return nil, err
+		}
+		fulcio.revocation = revocation
+	}
 	if err := fulcio.validate(); err != nil {
 		return nil, err
 	}
@@ -142,6 +150,13 @@ func (p *prSigstoreSignedPKI) prepareTrustRoot() (*pkiTrustRoot, error) {
 		}
 		pki.caIntermediateCertificates = intermediatePool
 	}
+	if p.Revocation != nil {
+		revocation, err := p.Revocation.prepareRevocationChecker()
+		if err != nil {
+			return nil, err
+		}
+		pki.revocation = revocation
+	}
 
 	if err := pki.validate(); err != nil {
 		return nil, err
@@ -236,6 +251,19 @@ func (pr *prSigstoreSigned) isSignatureAuthorAccepted(ctx context.Context, image
 	return sarRejected, nil, errors.New("isSignatureAuthorAccepted is not implemented for sigstore")
 }
 
+// untrustedOCSPResponseFromAnnotations returns the OCSP response stapled to a signature with untrustedAnnotations, or nil if there is none.
+func untrustedOCSPResponseFromAnnotations(untrustedAnnotations map[string]string) ([]byte, error) {
+	untrustedBase64OCSPResponse, ok := untrustedAnnotations[signature.SigstoreOCSPResponseAnnotationKey]
+	if !ok {
+		return nil, nil
+	}
+	untrustedOCSPResponse, err := base64.StdEncoding.DecodeString(untrustedBase64OCSPResponse)
+	if err != nil {
+		return nil, internal.NewInvalidSignatureError(fmt.Sprintf("invalid %s annotation: %v", signature.SigstoreOCSPResponseAnnotationKey, err))
+	}
+	return untrustedOCSPResponse, nil
+}
+
 func (pr *prSigstoreSigned) isSignatureAccepted(ctx context.Context, image private.UnparsedImage, sig signature.Sigstore) (signatureAcceptanceResult, error) {
 	// FIXME: move this to per-context initialization
 	trustRoot, err := pr.prepareTrustRoot()
@@ -319,8 +347,13 @@ func (pr *prSigstoreSigned) isSignatureAccepted(ctx context.Context, image priva
 		if untrustedIntermediateChain, ok := untrustedAnnotations[signature.SigstoreIntermediateCertificateChainAnnotationKey]; ok {
 			untrustedIntermediateChainBytes = []byte(untrustedIntermediateChain)
 		}
+		untrustedOCSPResponse, err := untrustedOCSPResponseFromAnnotations(untrustedAnnotations)
+		if err != nil {
+			return sarRejected, err
+		}
 		pk, err := verifyRekorFulcio(trustRoot.rekorPublicKeys, trustRoot.fulcio,
-			[]byte(untrustedSET), []byte(untrustedCert), untrustedIntermediateChainBytes, untrustedBase64Signature, untrustedPayload)
+			[]byte(untrustedSET), []byte(untrustedCert), untrustedIntermediateChainBytes, untrustedBase64Signature, untrustedPayload,
+			untrustedOCSPResponse)
 		if err != nil {
 			return sarRejected, err
 		}
@@ -338,7 +371,11 @@ func (pr *prSigstoreSigned) isSignatureAccepted(ctx context.Context, image priva
 		if untrustedIntermediateChain, ok := untrustedAnnotations[signature.SigstoreIntermediateCertificateChainAnnotationKey]; ok {
 			untrustedIntermediateChainBytes = []byte(untrustedIntermediateChain)
 		}
-		pk, err := verifyPKI(trustRoot.pki, []byte(untrustedCert), untrustedIntermediateChainBytes)
+		untrustedOCSPResponse, err := untrustedOCSPResponseFromAnnotations(untrustedAnnotations)
+		if err != nil {
+			return sarRejected, err
+		}
+		pk, err := verifyPKI(trustRoot.pki, []byte(untrustedCert), untrustedIntermediateChainBytes, untrustedOCSPResponse)
 		if err != nil {
 			return sarRejected, err
 		}
@@ -76,6 +76,9 @@ type pcImageReferenceMock struct {
 }
 
 func (ref pcImageReferenceMock) Transport() types.ImageTransport {
+	if ref.transportName == docker.Transport.Name() {
+		return docker.Transport // To support pattern scopes
+	}
 	return mocks.NameImageTransport(ref.transportName)
 }
 func (ref pcImageReferenceMock) StringWithinTransport() string {
@@ -151,6 +154,14 @@ func TestPolicyContextRequirementsForImageRef(t *testing.T) {
 		{"docker", "deep.com/n1/n2/n3"},
 		{"docker", "deep.com/n1/n2/n3/repo"},
 		{"docker", "deep.com/n1/n2/n3/repo:tag2"},
+		{"docker", "deep.com/n1/n2/n3/repo:release-*"},
+		{"docker", "deep.com/n1/n2/n3/*:release-*"},
+		{"docker", "deep.com/n1/n2/n3/*:release-1*"},
+		{"docker", "deep.com/n1/n2/n3/*:*-1*"},
+		{"docker", "deep.com/n1/n2/n3/repo@*"},
+		{"docker", "deep.com/*/*/n3/*@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"},
+		{"docker", "deep.com/*/n2/n3/repo:nottag*"},
+		{"docker", "deep.*/n1/n2/n3/repo:tag2"},
 		{"atomic", "unmatched"},
 	} {
 		if _, ok := policy.Transports[t.transport]; !ok {
@@ -165,8 +176,21 @@ func TestPolicyContextRequirementsForImageRef(t *testing.T) {
 	for _, c := range []struct{ inputTransport, input, matchedTransport, matched string }{
 		// Full match
 		{"docker", "deep.com/n1/n2/n3/repo:tag2", "docker", "deep.com/n1/n2/n3/repo:tag2"},
+		// Pattern matches
+		{"docker", "deep.com/n1/n2/n3/repo:release-2", "docker", "deep.com/n1/n2/n3/repo:release-*"},
+		{"docker", "deep.com/n1/n2/n3/notrepo:release-2", "docker", "deep.com/n1/n2/n3/*:release-*"},
+		{"docker", "deep.com/n1/n2/n3/notrepo:release-1", "docker", "deep.com/n1/n2/n3/*:release-1*"}, // More specific than *:release-*
+		{"docker", "deep.com/n1/n2/n3/repo:release-1", "docker", "deep.com/n1/n2/n3/repo:release-*"},  // More specific than *:release-1*
+		{"docker", "deep.com/n1/n2/n3/notrepo:dev-1", "docker", "deep.com/n1/n2/n3/*:*-1*"},
+		{"docker", "deep.com/n1/n2/n3/repo:dev-1", "docker", "deep.com/n1/n2/n3/repo"}, // The repository scope is more specific than *:*-1*
+		{"docker", "deep.com/x/y/n3/repo@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+			"docker", "deep.com/*/*/n3/*@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"},
+		{"docker", "deep.com/n1/n2/n3/repo@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", "docker", "deep.com/n1/n2/n3/repo@*"},
+		{"docker", "deep.com/n1/n2/n3/n4/repo:release-2", "docker", "deep.com/n1/n2/n3"}, // "*" does not match "/"
+		{"docker", "deep.com/x/n2/n3/repo:nottag2", "docker", "deep.com/*/n2/n3/repo:nottag*"},
+		{"docker", "deep.org/n1/n2/n3/repo:tag2", "docker", "deep.*/n1/n2/n3/repo:tag2"}, // Does not start with any namespace
 		// Namespace matches
-		{"docker", "deep.com/n1/n2/n3/repo:nottag2", "docker", "deep.com/n1/n2/n3/repo"},
+		{"docker", "deep.com/n1/n2/n3/repo:nottag2", "docker", "deep.com/n1/n2/n3/repo"}, // Not deep.com/*/n2/n3/repo:nottag*, which is less specific
 		{"docker", "deep.com/n1/n2/n3/notrepo:tag2", "docker", "deep.com/n1/n2/n3"},
 		{"docker", "deep.com/n1/n2/notn3/repo:tag2", "docker", "deep.com/n1/n2"},
 		{"docker", "deep.com/n1/notn2/n3/repo:tag2", "docker", "deep.com/n1"},
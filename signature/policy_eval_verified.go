@@ -0,0 +1,153 @@
+package signature
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/containers/image/v5/internal/image"
+	"github.com/containers/image/v5/internal/set"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// VerifiedImage is an image which has been accepted by a policy.
+//
+// The manifest is read only once, when evaluating the policy; all later operations use that manifest,
+// and only blobs referenced by that manifest can be read, verifying their digests.
+// This allows consumers to access image data without accidentally using data which was not subject to the policy check.
+type VerifiedImage struct {
+	src              types.ImageSource
+	unparsed         *image.UnparsedImage
+	manifest         []byte
+	manifestMIMEType string
+	manifestDigest   digest.Digest
+	blobs            *set.Set[digest.Digest] // Digests of blobs referenced by manifest
+}
+
+// NewVerifiedImage opens ref (or, if instanceDigest is not nil, the specified instance of a manifest list in ref),
+// and returns a VerifiedImage if pc allows running it.
+// If the image is rejected, no data other than the manifest and signatures is read from the source,
+// and the returned error is a PolicyRequirementError if the policy evaluation succeeded but the image was rejected.
+//
+// If the manifest is a manifest list, the VerifiedImage does not allow reading any blobs; callers should choose
+// an instance from the list, and call NewVerifiedImage again with instanceDigest set.
+//
+// The caller must call Close() on the returned VerifiedImage.
+func (pc *PolicyContext) NewVerifiedImage(ctx context.Context, sys *types.SystemContext, ref types.ImageReference, instanceDigest *digest.Digest) (_ *VerifiedImage, retErr error) {
+	src, err := ref.NewImageSource(ctx, sys)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if retErr != nil {
+			src.Close()
+		}
+	}()
+
+	unparsed := image.UnparsedInstance(src, instanceDigest)
+	if allowed, err := pc.IsRunningImageAllowed(ctx, unparsed); !allowed || err != nil { // Be paranoid and fail if either return value indicates so.
+		if err == nil {
+			err = errors.New("internal error: image rejected without reporting a reason")
+		}
+		return nil, fmt.Errorf("Source image rejected: %w", err)
+	}
+
+	// This returns the manifest cached by unparsed, i.e. the one which was evaluated by the policy.
+	manifestBlob, manifestMIMEType, err := unparsed.Manifest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	manifestDigest, err := manifest.Digest(manifestBlob)
+	if err != nil {
+		return nil, fmt.Errorf("computing manifest digest: %w", err)
+	}
+	blobs := set.New[digest.Digest]()
+	if !manifest.MIMETypeIsMultiImage(manifestMIMEType) {
+		m, err := manifest.FromBlob(manifestBlob, manifestMIMEType)
+		if err != nil {
+			return nil, fmt.Errorf("parsing manifest: %w", err)
+		}
+		if config := m.ConfigInfo(); config.Digest != "" {
+			blobs.Add(config.Digest)
+		}
+		for _, layer := range m.LayerInfos() {
+			blobs.Add(layer.Digest)
+		}
+	}
+	return &VerifiedImage{
+		src:              src,
+		unparsed:         unparsed,
+		manifest:         manifestBlob,
+		manifestMIMEType: manifestMIMEType,
+		manifestDigest:   manifestDigest,
+		blobs:            blobs,
+	}, nil
+}
+
+// Reference returns the reference used to set up the image.
+func (v *VerifiedImage) Reference() types.ImageReference {
+	return v.src.Reference()
+}
+
+// Manifest returns the manifest accepted by the policy, and its MIME type.
+func (v *VerifiedImage) Manifest() ([]byte, string) {
+	return v.manifest, v.manifestMIMEType
+}
+
+// ManifestDigest returns the digest of the manifest accepted by the policy.
+func (v *VerifiedImage) ManifestDigest() digest.Digest {
+	return v.manifestDigest
+}
+
+// Image returns a types.Image for the accepted manifest, e.g. to read the image configuration.
+// The returned image is valid only until the VerifiedImage is closed.
+func (v *VerifiedImage) Image(ctx context.Context, sys *types.SystemContext) (types.Image, error) {
+	return image.FromUnparsedImage(ctx, sys, v.unparsed)
+}
+
+// GetBlob returns a stream for the blob described by info, and the blob’s size (or -1 if unknown).
+// The blob must be referenced by the accepted manifest; the stream fails with an error at EOF if the data does not match info.Digest.
+// The caller must call Close() on the returned stream.
+func (v *VerifiedImage) GetBlob(ctx context.Context, info types.BlobInfo, cache types.BlobInfoCache) (io.ReadCloser, int64, error) {
+	if !v.blobs.Contains(info.Digest) {
+		return nil, -1, fmt.Errorf("blob %q is not referenced by the verified manifest %s", info.Digest, v.manifestDigest)
+	}
+	if err := info.Digest.Validate(); err != nil { // Make sure info.Digest.Verifier() does not panic.
+		return nil, -1, fmt.Errorf("invalid blob digest %q: %w", info.Digest, err)
+	}
+	stream, size, err := v.src.GetBlob(ctx, info, cache)
+	if err != nil {
+		return nil, -1, err
+	}
+	return &verifyingReader{source: stream, digest: info.Digest, verifier: info.Digest.Verifier()}, size, nil
+}
+
+// Close removes resources associated with the VerifiedImage.
+func (v *VerifiedImage) Close() error {
+	return v.src.Close()
+}
+
+// verifyingReader is an io.ReadCloser which fails at EOF if the data read does not match digest.
+type verifyingReader struct {
+	source   io.ReadCloser
+	digest   digest.Digest
+	verifier digest.Verifier
+}
+
+func (r *verifyingReader) Read(p []byte) (int, error) {
+	n, err := r.source.Read(p)
+	if n > 0 {
+		_, _ = r.verifier.Write(p[:n]) // Writes to a digest.Verifier never fail.
+	}
+	if err == io.EOF && !r.verifier.Verified() {
+		return n, fmt.Errorf("blob does not match the expected digest %s", r.digest)
+	}
+	return n, err
+}
+
+func (r *verifyingReader) Close() error {
+	return r.source.Close()
+}
@@ -0,0 +1,101 @@
+package signature
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containers/image/v5/directory"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyContextNewVerifiedImage(t *testing.T) {
+	config := []byte(`{"architecture":"amd64","os":"linux"}`)
+	layer := []byte("layer data")
+	configDesc := imgspecv1.Descriptor{MediaType: imgspecv1.MediaTypeImageConfig, Digest: digest.FromBytes(config), Size: int64(len(config))}
+	layerDesc := imgspecv1.Descriptor{MediaType: imgspecv1.MediaTypeImageLayer, Digest: digest.FromBytes(layer), Size: int64(len(layer))}
+	manifestBlob, err := manifest.OCI1FromComponents(configDesc, []imgspecv1.Descriptor{layerDesc}).Serialize()
+	require.NoError(t, err)
+	dir := t.TempDir()
+	for _, blob := range [][]byte{config, layer} {
+		err := os.WriteFile(filepath.Join(dir, digest.FromBytes(blob).Encoded()), blob, 0o644)
+		require.NoError(t, err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "manifest.json"), manifestBlob, 0o644)
+	require.NoError(t, err)
+	ref, err := directory.NewReference(dir)
+	require.NoError(t, err)
+
+	// Rejected
+	pc, err := NewPolicyContext(&Policy{Default: PolicyRequirements{NewPRReject()}})
+	require.NoError(t, err)
+	defer func() {
+		err := pc.Destroy()
+		require.NoError(t, err)
+	}()
+	_, err = pc.NewVerifiedImage(context.Background(), nil, ref, nil)
+	var prErr PolicyRequirementError
+	assert.ErrorAs(t, err, &prErr)
+
+	// Accepted
+	pc2, err := NewPolicyContext(&Policy{Default: PolicyRequirements{NewPRInsecureAcceptAnything()}})
+	require.NoError(t, err)
+	defer func() {
+		err := pc2.Destroy()
+		require.NoError(t, err)
+	}()
+	v, err := pc2.NewVerifiedImage(context.Background(), nil, ref, nil)
+	require.NoError(t, err)
+	defer func() {
+		err := v.Close()
+		require.NoError(t, err)
+	}()
+	assert.Equal(t, ref, v.Reference())
+	m, mimeType := v.Manifest()
+	assert.Equal(t, manifestBlob, m)
+	assert.Equal(t, imgspecv1.MediaTypeImageManifest, mimeType)
+	assert.Equal(t, digest.FromBytes(manifestBlob), v.ManifestDigest())
+
+	// The manifest is not re-read after the policy check.
+	err = os.WriteFile(filepath.Join(dir, "manifest.json"), []byte("modified"), 0o644)
+	require.NoError(t, err)
+	img, err := v.Image(context.Background(), nil)
+	require.NoError(t, err)
+	ociConfig, err := img.OCIConfig(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "amd64", ociConfig.Architecture)
+
+	for _, blob := range [][]byte{config, layer} {
+		stream, _, err := v.GetBlob(context.Background(), types.BlobInfo{Digest: digest.FromBytes(blob)}, nil)
+		require.NoError(t, err)
+		contents, err := io.ReadAll(stream)
+		require.NoError(t, err)
+		assert.Equal(t, blob, contents)
+		err = stream.Close()
+		require.NoError(t, err)
+	}
+
+	// Blobs not referenced by the manifest are rejected
+	other := []byte("other data")
+	err = os.WriteFile(filepath.Join(dir, digest.FromBytes(other).Encoded()), other, 0o644)
+	require.NoError(t, err)
+	_, _, err = v.GetBlob(context.Background(), types.BlobInfo{Digest: digest.FromBytes(other)}, nil)
+	assert.Error(t, err)
+
+	// Blobs with unexpected contents are rejected
+	err = os.WriteFile(filepath.Join(dir, layerDesc.Digest.Encoded()), []byte("modified layer"), 0o644)
+	require.NoError(t, err)
+	stream, _, err := v.GetBlob(context.Background(), types.BlobInfo{Digest: layerDesc.Digest}, nil)
+	require.NoError(t, err)
+	_, err = io.ReadAll(stream)
+	assert.Error(t, err)
+	err = stream.Close()
+	require.NoError(t, err)
+}
@@ -0,0 +1,183 @@
+// Policy evaluation for prWebhook.
+
+package signature
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/containers/image/v5/internal/iolimits"
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/internal/signature"
+	"github.com/containers/image/v5/manifest"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultWebhookTimeout is the time to wait for a webhook verdict if prWebhook.TimeoutSeconds is not set.
+	defaultWebhookTimeout = 10 * time.Second
+	// maxWebhookResponseSize is the maximum accepted size of a webhook response.
+	maxWebhookResponseSize = 1024 * 1024
+)
+
+// webhookRequest is the JSON body POSTed to a webhook.
+type webhookRequest struct {
+	Transport       string             `json:"transport"`
+	Reference       string             `json:"reference"`                 // StringWithinTransport
+	DockerReference string             `json:"dockerReference,omitempty"` // If the image has one
+	ManifestDigest  string             `json:"manifestDigest"`
+	Signatures      []webhookSignature `json:"signatures"`
+}
+
+// webhookSignature is a single (unverified) signature in webhookRequest.
+type webhookSignature struct {
+	Format      signature.FormatID `json:"format"`
+	Signature   []byte             `json:"signature,omitempty"` // For signature.SimpleSigningFormat
+	MIMEType    string             `json:"mimeType,omitempty"`  // For signature.SigstoreFormat
+	Payload     []byte             `json:"payload,omitempty"`   // For signature.SigstoreFormat
+	Annotations map[string]string  `json:"annotations,omitempty"`
+}
+
+// webhookResponse is the JSON body of a webhook response.
+type webhookResponse struct {
+	Allowed *bool  `json:"allowed"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+func (pr *prWebhook) isSignatureAuthorAccepted(ctx context.Context, image private.UnparsedImage, sig []byte) (signatureAcceptanceResult, *Signature, error) {
+	return sarUnknown, nil, nil
+}
+
+func (pr *prWebhook) isRunningImageAllowed(ctx context.Context, image private.UnparsedImage) (bool, error) {
+	request, err := pr.newRequest(ctx, image)
+	if err != nil {
+		return false, err
+	}
+	verdict, err := pr.query(ctx, request)
+	if err != nil {
+		if ctx.Err() != nil { // Canceled by the caller, not a webhook failure.
+			return false, err
+		}
+		if pr.FailureMode == WebhookFailureAccept {
+			logrus.Warnf("Webhook %s failed, accepting the image: %v", pr.URL, err)
+			return true, nil
+		}
+		return false, fmt.Errorf("webhook %s failed: %w", pr.URL, err)
+	}
+	if !*verdict.Allowed {
+		if verdict.Reason != "" {
+			return false, PolicyRequirementError(fmt.Sprintf("Image rejected by webhook %s: %s", pr.URL, verdict.Reason))
+		}
+		return false, PolicyRequirementError(fmt.Sprintf("Image rejected by webhook %s", pr.URL))
+	}
+	return true, nil
+}
+
+// newRequest returns the data about image to send to the webhook.
+func (pr *prWebhook) newRequest(ctx context.Context, image private.UnparsedImage) (*webhookRequest, error) {
+	ref := image.Reference()
+	m, _, err := image.Manifest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	manifestDigest, err := manifest.Digest(m)
+	if err != nil {
+		return nil, err
+	}
+	res := webhookRequest{
+		Transport:      ref.Transport().Name(),
+		Reference:      ref.StringWithinTransport(),
+		ManifestDigest: manifestDigest.String(),
+		Signatures:     []webhookSignature{},
+	}
+	if dockerRef := ref.DockerReference(); dockerRef != nil {
+		res.DockerReference = dockerRef.String()
+	}
+
+	sigs, err := image.UntrustedSignatures(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, sig := range sigs {
+		switch sig := sig.(type) {
+		case signature.SimpleSigning:
+			res.Signatures = append(res.Signatures, webhookSignature{
+				Format:    sig.FormatID(),
+				Signature: sig.UntrustedSignature(),
+			})
+		case signature.Sigstore:
+			res.Signatures = append(res.Signatures, webhookSignature{
+				Format:      sig.FormatID(),
+				MIMEType:    sig.UntrustedMIMEType(),
+				Payload:     sig.UntrustedPayload(),
+				Annotations: sig.UntrustedAnnotations(),
+			})
+		default:
+			logrus.Debugf("Not sending a signature with unknown format %q to webhook %s", sig.FormatID(), pr.URL)
+		}
+	}
+	return &res, nil
+}
+
+// query sends request to the webhook, and returns its verdict.
+func (pr *prWebhook) query(ctx context.Context, request *webhookRequest) (*webhookResponse, error) {
+	timeout := defaultWebhookTimeout
+	if pr.TimeoutSeconds != 0 {
+		timeout = time.Duration(pr.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(pr.URL)
+	if err != nil { // Coverage: newPRWebhook validates the URL.
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	requestURL := pr.URL
+	if u.Scheme == "unix" {
+		socketPath := u.Path
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+		}
+		requestURL = "http://localhost/"
+	}
+	client := &http.Client{Transport: transport}
+	defer transport.CloseIdleConnections()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, requestURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status %s", res.Status)
+	}
+	responseBody, err := iolimits.ReadAtMost(res.Body, maxWebhookResponseSize)
+	if err != nil {
+		return nil, err
+	}
+	var response webhookResponse
+	if err := json.Unmarshal(responseBody, &response); err != nil {
+		return nil, fmt.Errorf("parsing webhook response: %w", err)
+	}
+	if response.Allowed == nil {
+		return nil, errors.New(`webhook response does not contain "allowed"`)
+	}
+	return &response, nil
+}
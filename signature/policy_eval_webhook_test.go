@@ -0,0 +1,205 @@
+package signature
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/internal/signature"
+	"github.com/containers/image/v5/manifest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// webhookImageReferenceMock is a refImageReferenceMock which also supports StringWithinTransport.
+type webhookImageReferenceMock struct {
+	refImageReferenceMock
+}
+
+func (ref webhookImageReferenceMock) StringWithinTransport() string {
+	return ref.ref.String()
+}
+
+// webhookImageMock returns a private.UnparsedImage for a directory, claiming a specified dockerReference.
+func webhookImageMock(t *testing.T, dir, dockerReference string) private.UnparsedImage {
+	ref, err := reference.ParseNormalizedNamed(dockerReference)
+	require.NoError(t, err)
+	return dirImageMockWithRef(t, dir, webhookImageReferenceMock{refImageReferenceMock{ref: ref}})
+}
+
+func TestPRWebhookIsSignatureAuthorAccepted(t *testing.T) {
+	pr, err := newPRWebhook("https://policy.example.com")
+	require.NoError(t, err)
+	image := webhookImageMock(t, "fixtures/dir-img-valid", "testing/manifest:latest")
+	sig, err := os.ReadFile("fixtures/dir-img-valid/signature-1")
+	require.NoError(t, err)
+	sar, parsedSig, err := pr.isSignatureAuthorAccepted(context.Background(), image, sig)
+	assertSARUnknown(t, sar, parsedSig, err)
+}
+
+// webhookTestServer returns a http.Handler which records the received webhookRequest in *received,
+// and responds with statusCode and responseBody.
+func webhookTestServer(t *testing.T, received *webhookRequest, statusCode int, responseBody string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		err = json.Unmarshal(body, received)
+		require.NoError(t, err)
+		w.WriteHeader(statusCode)
+		_, err = w.Write([]byte(responseBody))
+		require.NoError(t, err)
+	})
+}
+
+func TestPRWebhookIsRunningImageAllowed(t *testing.T) {
+	manifestBlob, err := os.ReadFile("fixtures/dir-img-valid/manifest.json")
+	require.NoError(t, err)
+	manifestDigest, err := manifest.Digest(manifestBlob)
+	require.NoError(t, err)
+	sig, err := os.ReadFile("fixtures/dir-img-valid/signature-1")
+	require.NoError(t, err)
+	expectedRequest := webhookRequest{
+		Transport:       "== Transport mock",
+		Reference:       "docker.io/testing/manifest:latest",
+		DockerReference: "docker.io/testing/manifest:latest",
+		ManifestDigest:  manifestDigest.String(),
+		Signatures: []webhookSignature{{
+			Format:    signature.SimpleSigningFormat,
+			Signature: sig,
+		}},
+	}
+
+	for _, c := range []struct {
+		statusCode   int
+		responseBody string
+		failureMode  webhookFailureMode
+		allowed      bool
+		policyError  bool
+	}{
+		{http.StatusOK, `{"allowed":true}`, "", true, false},
+		{http.StatusOK, `{"allowed":true,"reason":"ignored"}`, WebhookFailureAccept, true, false},
+		{http.StatusOK, `{"allowed":false,"reason":"not in the allow list"}`, "", false, true},
+		{http.StatusOK, `{"allowed":false}`, WebhookFailureAccept, false, true}, // An explicit rejection is not a failure
+		// Webhook failures
+		{http.StatusInternalServerError, `{"allowed":true}`, "", false, false},
+		{http.StatusInternalServerError, `{"allowed":true}`, WebhookFailureReject, false, false},
+		{http.StatusInternalServerError, `{"allowed":false}`, WebhookFailureAccept, true, false},
+		{http.StatusOK, `this is invalid`, "", false, false},
+		{http.StatusOK, `this is invalid`, WebhookFailureAccept, true, false},
+		{http.StatusOK, `{"reason":"no verdict"}`, "", false, false},
+		{http.StatusOK, `{"allowed":"true"}`, "", false, false},
+	} {
+		var received webhookRequest
+		server := httptest.NewServer(webhookTestServer(t, &received, c.statusCode, c.responseBody))
+		var options []PRWebhookOption
+		if c.failureMode != "" {
+			options = append(options, PRWebhookWithFailureMode(c.failureMode))
+		}
+		pr, err := newPRWebhook(server.URL, options...)
+		require.NoError(t, err)
+		image := webhookImageMock(t, "fixtures/dir-img-valid", "testing/manifest:latest")
+		allowed, err := pr.isRunningImageAllowed(context.Background(), image)
+		switch {
+		case c.allowed:
+			assertRunningAllowed(t, allowed, err)
+		case c.policyError:
+			assertRunningRejectedPolicyRequirement(t, allowed, err)
+		default:
+			assertRunningRejected(t, allowed, err)
+		}
+		assert.Equal(t, expectedRequest, received)
+		server.Close()
+	}
+
+	// The reason is included in the error message
+	server := httptest.NewServer(webhookTestServer(t, &webhookRequest{}, http.StatusOK, `{"allowed":false,"reason":"not in the allow list"}`))
+	defer server.Close()
+	pr, err := newPRWebhook(server.URL)
+	require.NoError(t, err)
+	image := webhookImageMock(t, "fixtures/dir-img-valid", "testing/manifest:latest")
+	_, err = pr.isRunningImageAllowed(context.Background(), image)
+	assert.ErrorContains(t, err, "not in the allow list")
+
+	// An unsigned image is sent with an empty list of signatures
+	var received webhookRequest
+	unsignedServer := httptest.NewServer(webhookTestServer(t, &received, http.StatusOK, `{"allowed":true}`))
+	defer unsignedServer.Close()
+	pr, err = newPRWebhook(unsignedServer.URL)
+	require.NoError(t, err)
+	image = webhookImageMock(t, "fixtures/dir-img-unsigned", "testing/manifest:latest")
+	allowed, err := pr.isRunningImageAllowed(context.Background(), image)
+	assertRunningAllowed(t, allowed, err)
+	assert.Equal(t, []webhookSignature{}, received.Signatures)
+
+	// Timeouts
+	slowServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body) // So that the server notices when the client disconnects.
+		<-r.Context().Done()
+	}))
+	defer slowServer.Close()
+	for _, c := range []struct {
+		failureMode webhookFailureMode
+		allowed     bool
+	}{
+		{WebhookFailureReject, false},
+		{WebhookFailureAccept, true},
+	} {
+		pr, err := newPRWebhook(slowServer.URL, PRWebhookWithTimeoutSeconds(1), PRWebhookWithFailureMode(c.failureMode))
+		require.NoError(t, err)
+		image := webhookImageMock(t, "fixtures/dir-img-valid", "testing/manifest:latest")
+		allowed, err := pr.isRunningImageAllowed(context.Background(), image)
+		if c.allowed {
+			assertRunningAllowed(t, allowed, err)
+		} else {
+			assertRunningRejected(t, allowed, err)
+		}
+	}
+
+	// A caller cancellation is not subject to failureMode
+	pr, err = newPRWebhook(slowServer.URL, PRWebhookWithFailureMode(WebhookFailureAccept))
+	require.NoError(t, err)
+	image = webhookImageMock(t, "fixtures/dir-img-valid", "testing/manifest:latest")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	allowed, err = pr.isRunningImageAllowed(ctx, image)
+	assertRunningRejected(t, allowed, err)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// The webhook is unreachable
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachableURL := unreachable.URL
+	unreachable.Close()
+	pr, err = newPRWebhook(unreachableURL)
+	require.NoError(t, err)
+	image = webhookImageMock(t, "fixtures/dir-img-valid", "testing/manifest:latest")
+	allowed, err = pr.isRunningImageAllowed(context.Background(), image)
+	assertRunningRejected(t, allowed, err)
+}
+
+func TestPRWebhookUnixSocket(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "webhook.sock")
+	listener, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+	var received webhookRequest
+	server := httptest.NewUnstartedServer(webhookTestServer(t, &received, http.StatusOK, `{"allowed":true}`))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	pr, err := newPRWebhook("unix://" + socketPath)
+	require.NoError(t, err)
+	image := webhookImageMock(t, "fixtures/dir-img-valid", "testing/manifest:latest")
+	allowed, err := pr.isRunningImageAllowed(context.Background(), image)
+	assertRunningAllowed(t, allowed, err)
+	assert.Equal(t, "docker.io/testing/manifest:latest", received.DockerReference)
+}
@@ -23,6 +23,8 @@ type Policy struct {
 // there is one scope precisely matching to a single image, and namespace scopes as prefixes
 // of the single-image scope. (e.g. hostname[/zero[/or[/more[/namespaces[/individualimage]]]]])
 // The empty scope, if exists, is considered a parent namespace of all other scopes.
+// Some transports also support pattern scopes (e.g. "quay.io/org/*:release-*" for docker:), which are
+// considered before a namespace scope they literally start with (e.g. "quay.io/org"), but after more specific scopes.
 // Most specific scope wins, duplication is prohibited (hard failure).
 type PolicyTransportScopes map[string]PolicyRequirements
 
@@ -47,6 +49,8 @@ const (
 	prTypeSignedBy               prTypeIdentifier = "signedBy"
 	prTypeSignedBaseLayer        prTypeIdentifier = "signedBaseLayer"
 	prTypeSigstoreSigned         prTypeIdentifier = "sigstoreSigned"
+	prTypeSBOMAttested           prTypeIdentifier = "sbomAttested"
+	prTypeWebhook                prTypeIdentifier = "webhook"
 )
 
 // prInsecureAcceptAnything is a PolicyRequirement with type = prTypeInsecureAcceptAnything:
@@ -82,6 +86,18 @@ type prSignedBy struct {
 	// SignedIdentity specifies what image identity the signature must be claiming about the image.
 	// Defaults to "matchRepoDigestOrExact" if not specified.
 	SignedIdentity PolicyReferenceMatch `json:"signedIdentity"`
+
+	// TimestampAuthority, if set, requires the signature to carry an RFC 3161 timestamp token
+	// issued by a time-stamping authority trusted by this configuration.
+	TimestampAuthority *prSignedByTimestampAuthority `json:"timestampAuthority,omitempty"`
+}
+
+// prSignedByTimestampAuthority contains trust anchors for RFC 3161 timestamps of prSignedBy signatures.
+type prSignedByTimestampAuthority struct {
+	// CAPath is a path to a file containing accepted time-stamping authority root certificates, in PEM format. Exactly one of CAPath and CAData must be specified.
+	CAPath string `json:"caPath,omitempty"`
+	// CAData contains accepted time-stamping authority root certificates in PEM format, all of that base64-encoded. Exactly one of CAPath and CAData must be specified.
+	CAData []byte `json:"caData,omitempty"`
 }
 
 // sbKeyType are the allowed values for prSignedBy.KeyType
@@ -160,7 +176,7 @@ type PRSigstoreSignedFulcio interface {
 
 // prSigstoreSignedFulcio collects Fulcio configuration options for prSigstoreSigned
 type prSigstoreSignedFulcio struct {
-	// CAPath a path to a file containing accepted CA root certificates, in PEM format. Exactly one of CAPath and CAData must be specified.
+	// CAPath is a path to a file containing accepted CA root certificates, in PEM format. Exactly one of CAPath and CAData must be specified.
 	CAPath string `json:"caPath,omitempty"`
 	// CAData contains accepted CA root certificates in PEM format, all of that base64-encoded. Exactly one of CAPath and CAData must be specified.
 	CAData []byte `json:"caData,omitempty"`
@@ -168,6 +184,8 @@ type prSigstoreSignedFulcio struct {
 	OIDCIssuer string `json:"oidcIssuer,omitempty"`
 	// SubjectEmail specifies the expected email address of the authenticated OIDC identity, recorded by Fulcio into the generated certificates.
 	SubjectEmail string `json:"subjectEmail,omitempty"`
+	// Revocation specifies how to check whether the certificates have been revoked. If not specified, revocation is not checked.
+	Revocation PRSigstoreSignedRevocation `json:"revocation,omitempty"`
 }
 
 // PRSigstoreSignedPKI contains PKI configuration options for a "sigstoreSigned" PolicyRequirement.
@@ -192,8 +210,97 @@ type prSigstoreSignedPKI struct {
 	SubjectEmail string `json:"subjectEmail,omitempty"`
 	// SubjectHostname specifies the expected hostname imposed on the subject to which the certificate was issued. At least one of SubjectEmail and SubjectHostname must be specified.
 	SubjectHostname string `json:"subjectHostname,omitempty"`
+
+	// Revocation specifies how to check whether the certificates have been revoked. If not specified, revocation is not checked.
+	Revocation PRSigstoreSignedRevocation `json:"revocation,omitempty"`
 }
 
+// PRSigstoreSignedRevocation contains certificate revocation checking options for the "fulcio" and "pki" fields of a "sigstoreSigned" PolicyRequirement.
+// This is a public type with a single private implementation.
+type PRSigstoreSignedRevocation interface {
+	// prepareRevocationChecker creates a revocationChecker from the input data.
+	// (This also prevents external implementations of this interface, ensuring that prSigstoreSignedRevocation is the only one.)
+	prepareRevocationChecker() (*revocationChecker, error)
+}
+
+// prSigstoreSignedRevocation collects certificate revocation checking options for prSigstoreSignedFulcio and prSigstoreSignedPKI
+type prSigstoreSignedRevocation struct {
+	// FailureMode specifies what happens if the revocation status of a certificate can not be determined:
+	// "hardFail" (the default) rejects the signature, "softFail" accepts it.
+	// Certificates known to be revoked are always rejected.
+	FailureMode string `json:"failureMode,omitempty"`
+	// FetchCRLs, if true, causes CRLs to be fetched from the HTTP(S) CRL distribution points listed in the certificates.
+	// Fetched CRLs are cached in memory until their nextUpdate time.
+	FetchCRLs bool `json:"fetchCRLs,omitempty"`
+	// CRLPaths are paths to files containing CRLs, in DER or PEM format.
+	CRLPaths []string `json:"crlPaths,omitempty"`
+	// AcceptStapledOCSP, if true, accepts an OCSP response for the leaf certificate stapled to the signature.
+	AcceptStapledOCSP bool `json:"acceptStapledOCSP,omitempty"`
+}
+
+const (
+	// revocationFailureModeHard rejects certificates with an unknown revocation status.
+	revocationFailureModeHard = "hardFail"
+	// revocationFailureModeSoft accepts certificates with an unknown revocation status.
+	revocationFailureModeSoft = "softFail"
+)
+
+// prSBOMAttested is a PolicyRequirement with type = prTypeSBOMAttested: the image has an attached SBOM attestation,
+// signed by trusted keys, with a subject matching the image.
+type prSBOMAttested struct {
+	prCommon
+
+	// KeyPath is a pathname to a local file containing the trusted key. Exactly one of KeyPath, KeyPaths, KeyData, KeyDatas, and PKI must be specified.
+	KeyPath string `json:"keyPath,omitempty"`
+	// KeyPaths is a set of pathnames to local files containing the trusted key(s). Exactly one of KeyPath, KeyPaths, KeyData, KeyDatas, and PKI must be specified.
+	KeyPaths []string `json:"keyPaths,omitempty"`
+	// KeyData contains the trusted key, base64-encoded. Exactly one of KeyPath, KeyPaths, KeyData, KeyDatas, and PKI must be specified.
+	KeyData []byte `json:"keyData,omitempty"`
+	// KeyDatas is a set of trusted keys, base64-encoded. Exactly one of KeyPath, KeyPaths, KeyData, KeyDatas, and PKI must be specified.
+	KeyDatas [][]byte `json:"keyDatas,omitempty"`
+
+	// PKI specifies which PKI-generated certificates are accepted. Exactly one of KeyPath, KeyPaths, KeyData, KeyDatas, and PKI must be specified.
+	PKI PRSigstoreSignedPKI `json:"pki,omitempty"`
+
+	// SBOMFormat, if set, specifies the format the SBOM must use: "spdx" or "cyclonedx".
+	// If not specified, any of the supported formats is accepted.
+	SBOMFormat sbomFormat `json:"sbomFormat,omitempty"`
+}
+
+// sbomFormat are the allowed values for prSBOMAttested.SBOMFormat
+type sbomFormat string
+
+const (
+	// SBOMFormatSPDX refers to SPDX documents
+	SBOMFormatSPDX sbomFormat = "spdx"
+	// SBOMFormatCycloneDX refers to CycloneDX BOMs
+	SBOMFormatCycloneDX sbomFormat = "cyclonedx"
+)
+
+// prWebhook is a PolicyRequirement with type = prTypeWebhook: the image is accepted if an external service,
+// given the image reference, manifest digest and signatures, allows it.
+type prWebhook struct {
+	prCommon
+
+	// URL is the http://, https:// or unix:// (a local socket path) URL the request is POSTed to.
+	URL string `json:"url"`
+	// TimeoutSeconds, if not 0, is the maximum time to wait for a verdict; the default is defaultWebhookTimeout.
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+	// FailureMode specifies how to treat a failure to obtain a verdict (e.g. a timeout): "reject" or "accept".
+	// Defaults to "reject" if not specified.
+	FailureMode webhookFailureMode `json:"failureMode,omitempty"`
+}
+
+// webhookFailureMode are the allowed values for prWebhook.FailureMode
+type webhookFailureMode string
+
+const (
+	// WebhookFailureReject rejects the image if the webhook does not return a verdict
+	WebhookFailureReject webhookFailureMode = "reject"
+	// WebhookFailureAccept accepts the image if the webhook does not return a verdict
+	WebhookFailureAccept webhookFailureMode = "accept"
+)
+
 // PolicyReferenceMatch specifies a set of image identities accepted in PolicyRequirement.
 // The type is public, but its implementation is private.
 
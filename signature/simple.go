@@ -77,6 +77,8 @@ var _ json.Marshaler = untrustedSignature{}
 var _ json.Marshaler = (*untrustedSignature)(nil)
 
 // MarshalJSON implements the json.Marshaler interface.
+// The output is canonical (object keys are sorted, and there is no insignificant whitespace), so the same contents
+// are always serialized to the same bytes; SignOptions.Deterministic relies on that, so keep it that way.
 func (s untrustedSignature) MarshalJSON() ([]byte, error) {
 	if s.untrustedDockerManifestDigest == "" || s.untrustedDockerReference == "" {
 		return nil, errors.New("Unexpected empty signature content")
@@ -219,6 +221,29 @@ func verifyAndExtractSignature(mech SigningMechanism, unverifiedSignature []byte
 	if err != nil {
 		return nil, err
 	}
+	return extractVerifiedSignature(signed, keyIdentity, rules)
+}
+
+// verifyAndExtractSignatureAtTime is like verifyAndExtractSignature, but evaluates expiration of the signature and of the signing key
+// as of verificationTime, which must not be before the signature creation time.
+func verifyAndExtractSignatureAtTime(mech SigningMechanism, unverifiedSignature []byte, verificationTime time.Time, rules signatureAcceptanceRules) (*Signature, error) {
+	mechWithTime, ok := mech.(signingMechanismWithVerificationTime)
+	if !ok {
+		return nil, errors.New("internal error: signing mechanism does not support verification at a specific time")
+	}
+	signed, keyIdentity, creationTime, err := mechWithTime.VerifyAtTime(unverifiedSignature, verificationTime)
+	if err != nil {
+		return nil, err
+	}
+	if verificationTime.Before(creationTime) {
+		return nil, internal.NewInvalidSignatureError(fmt.Sprintf("Signature created at %s, after the verification time %s", creationTime, verificationTime))
+	}
+	return extractVerifiedSignature(signed, keyIdentity, rules)
+}
+
+// extractVerifiedSignature validates signed, the contents of a cryptographically verified signature by keyIdentity,
+// against rules, and returns it.
+func extractVerifiedSignature(signed []byte, keyIdentity string, rules signatureAcceptanceRules) (*Signature, error) {
 	if err := rules.validateKeyIdentity(keyIdentity); err != nil {
 		return nil, err
 	}
@@ -1,15 +1,21 @@
 package simplesigning
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
 	"strings"
+	"time"
 
 	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/internal/iolimits"
 	internalSig "github.com/containers/image/v5/internal/signature"
 	internalSigner "github.com/containers/image/v5/internal/signer"
 	"github.com/containers/image/v5/signature"
+	"github.com/containers/image/v5/signature/internal"
 	"github.com/containers/image/v5/signature/signer"
 )
 
@@ -17,9 +23,15 @@ import (
 type simpleSigner struct {
 	mech           signature.SigningMechanism
 	keyFingerprint string
-	passphrase     string // "" if not provided.
+	passphrase     string       // "" if not provided.
+	deterministic  bool         // See signature.SignOptions.Deterministic.
+	tsaURL         string       // "" if timestamps should not be requested.
+	tsaClient      *http.Client // nil if not provided.
 }
 
+// defaultTimestampRequestTimeout is the timeout for requests to time-stamping authorities, if WithTimestampAuthorityHTTPClient is not used.
+const defaultTimestampRequestTimeout = 30 * time.Second
+
 type Option func(*simpleSigner) error
 
 // WithKeyFingerprint returns an Option for NewSigner, specifying a key to sign with, using the provided GPG key fingerprint.
@@ -43,6 +55,47 @@ func WithPassphrase(passphrase string) Option {
 	}
 }
 
+// WithDeterministicPayload returns an Option for NewSigner, specifying that the signed payload should not include
+// the creator and timestamp fields, so that signing the same manifest as the same reference always signs a byte-identical payload.
+// Note that the created signatures still differ, because the signing mechanism records the signing time.
+func WithDeterministicPayload() Option {
+	return func(s *simpleSigner) error {
+		s.deterministic = true
+		return nil
+	}
+}
+
+// WithTimestampAuthority returns an Option for NewSigner, specifying a RFC 3161 time-stamping authority
+// to obtain a timestamp of each created signature from, using HTTP at tsaURL.
+// The timestamp token is recorded along with the signature, to allow policies to require proof of the signing time.
+//
+// WARNING: Signatures with a timestamp token use a new storage format. Older versions of this library fail to read
+// such signatures from `dir:`, `containers-storage:` and lookaside storage, even when they would accept
+// another signature of the same image; and the signatures can’t be stored using the registry signature extension API at all.
+func WithTimestampAuthority(tsaURL string) Option {
+	return func(s *simpleSigner) error {
+		u, err := url.Parse(tsaURL)
+		if err != nil {
+			return fmt.Errorf("invalid time-stamping authority URL %q: %w", tsaURL, err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("invalid time-stamping authority URL %q: unsupported scheme", tsaURL)
+		}
+		s.tsaURL = tsaURL
+		return nil
+	}
+}
+
+// WithTimestampAuthorityHTTPClient returns an Option for NewSigner, specifying a HTTP client to use
+// for requests to the time-stamping authority set by WithTimestampAuthority, e.g. to configure TLS or proxies.
+// If this is not specified, a client with default settings and a timeout is used.
+func WithTimestampAuthorityHTTPClient(client *http.Client) Option {
+	return func(s *simpleSigner) error {
+		s.tsaClient = client
+		return nil
+	}
+}
+
 // NewSigner returns a signature.Signer which creates “simple signing” signatures using the user’s default
 // GPG configuration ($GNUPGHOME / ~/.gnupg).
 //
@@ -92,12 +145,56 @@ func (s *simpleSigner) SignImageManifest(ctx context.Context, m []byte, dockerRe
 		return nil, fmt.Errorf("reference %s can’t be signed, it has neither a tag nor a digest", dockerReference.String())
 	}
 	simpleSig, err := signature.SignDockerManifestWithOptions(m, dockerReference.String(), s.mech, s.keyFingerprint, &signature.SignOptions{
-		Passphrase: s.passphrase,
+		Passphrase:    s.passphrase,
+		Deterministic: s.deterministic,
 	})
 	if err != nil {
 		return nil, err
 	}
-	return internalSig.SimpleSigningFromBlob(simpleSig), nil
+	sig := internalSig.SimpleSigningFromBlob(simpleSig)
+	if s.tsaURL != "" {
+		client := s.tsaClient
+		if client == nil {
+			client = &http.Client{Timeout: defaultTimestampRequestTimeout}
+		}
+		token, err := requestTimestampToken(ctx, client, s.tsaURL, simpleSig)
+		if err != nil {
+			return nil, err
+		}
+		sig = internalSig.SimpleSigningWithTimestampToken(sig, token)
+	}
+	return sig, nil
+}
+
+// requestTimestampToken obtains a RFC 3161 timestamp token of data from the time-stamping authority at tsaURL, using client.
+func requestTimestampToken(ctx context.Context, client *http.Client, tsaURL string, data []byte) ([]byte, error) {
+	tsReq, nonce, err := internal.NewRFC3161TimestampRequest(data)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tsaURL, bytes.NewReader(tsReq))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", internal.RFC3161TimestampQueryMIMEType)
+	req.Header.Set("Accept", internal.RFC3161TimestampReplyMIMEType)
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("requesting a timestamp from %s: %w", tsaURL, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("requesting a timestamp from %s: HTTP status %s", tsaURL, res.Status)
+	}
+	tsResp, err := iolimits.ReadAtMost(res.Body, iolimits.MaxSignatureBodySize)
+	if err != nil {
+		return nil, fmt.Errorf("reading timestamp response from %s: %w", tsaURL, err)
+	}
+	token, err := internal.RFC3161TimestampTokenFromResponse(tsResp, data, nonce)
+	if err != nil {
+		return nil, fmt.Errorf("processing timestamp response from %s: %w", tsaURL, err)
+	}
+	return token, nil
 }
 
 func (s *simpleSigner) Close() error {
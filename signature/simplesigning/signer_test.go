@@ -2,6 +2,9 @@ package simplesigning
 
 import (
 	"context"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
@@ -9,7 +12,9 @@ import (
 	internalSig "github.com/containers/image/v5/internal/signature"
 	internalSigner "github.com/containers/image/v5/internal/signer"
 	"github.com/containers/image/v5/internal/testing/gpgagent"
+	"github.com/containers/image/v5/internal/testing/rfc3161tsa"
 	"github.com/containers/image/v5/signature"
+	"github.com/containers/image/v5/signature/internal"
 	"github.com/opencontainers/go-digest"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
@@ -161,20 +166,43 @@ func TestSimpleSignerSignImageManifest(t *testing.T) {
 		testFailure(c)
 	}
 
+	tsa, err := rfc3161tsa.New()
+	require.NoError(t, err)
+	tsaServer := httptest.NewServer(tsa)
+	defer tsaServer.Close()
+	tsaTLSServer := httptest.NewTLSServer(tsa)
+	defer tsaTLSServer.Close()
+	tsaRoots := x509.NewCertPool()
+	require.True(t, tsaRoots.AppendCertsFromPEM(tsa.RootCertificatePEM))
+	notFoundServer := httptest.NewServer(http.NotFoundHandler())
+	defer notFoundServer.Close()
+
 	// Successful signing
 	for _, c := range []struct {
-		name        string
-		fingerprint string
-		opts        []Option
+		name           string
+		fingerprint    string
+		opts           []Option
+		usesPassphrase bool
 	}{
 		{
 			name:        "No passphrase",
 			fingerprint: testKeyFingerprint,
 		},
 		{
-			name:        "With passphrase",
-			fingerprint: testKeyFingerprintWithPassphrase,
-			opts:        []Option{WithPassphrase(testPassphrase)},
+			name:           "With passphrase",
+			fingerprint:    testKeyFingerprintWithPassphrase,
+			opts:           []Option{WithPassphrase(testPassphrase)},
+			usesPassphrase: true,
+		},
+		{
+			name:        "With timestamp authority",
+			fingerprint: testKeyFingerprint,
+			opts:        []Option{WithTimestampAuthority(tsaServer.URL)},
+		},
+		{
+			name:        "With timestamp authority using a custom HTTP client",
+			fingerprint: testKeyFingerprint,
+			opts:        []Option{WithTimestampAuthority(tsaTLSServer.URL), WithTimestampAuthorityHTTPClient(tsaTLSServer.Client())},
 		},
 	} {
 		s, err := NewSigner(append([]Option{WithKeyFingerprint(c.fingerprint)}, c.opts...)...)
@@ -185,11 +213,15 @@ func TestSimpleSignerSignImageManifest(t *testing.T) {
 		require.NoError(t, err, c.name)
 		simpleSig, ok := sig.(internalSig.SimpleSigning)
 		require.True(t, ok)
+		if token := simpleSig.UntrustedTimestampToken(); token != nil {
+			_, err := internal.VerifyRFC3161TimestampToken(tsaRoots, token, simpleSig.UntrustedSignature())
+			assert.NoError(t, err, c.name)
+		}
 
 		// FIXME FIXME: gpgme_op_sign with a passphrase succeeds, but somehow confuses the GPGME internal state
 		// so that gpgme_op_verify below never completes (it polls on an already closed FD).
 		// That’s probably a GPGME bug, and needs investigating and fixing, but it isn’t related to this “signer” implementation.
-		if len(c.opts) == 0 {
+		if !c.usesPassphrase {
 			mech, err := signature.NewGPGSigningMechanism()
 			require.NoError(t, err)
 			defer mech.Close()
@@ -229,7 +261,89 @@ func TestSimpleSignerSignImageManifest(t *testing.T) {
 			},
 			ref: testImageSignatureReference,
 		},
+		{
+			name: "Error obtaining a timestamp",
+			opts: []Option{
+				WithKeyFingerprint(testKeyFingerprint),
+				WithTimestampAuthority(notFoundServer.URL),
+			},
+			ref: testImageSignatureReference,
+		},
 	} {
 		testFailure(c)
 	}
 }
+
+func TestWithDeterministicPayload(t *testing.T) {
+	var s simpleSigner
+	err := WithDeterministicPayload()(&s)
+	require.NoError(t, err)
+	assert.True(t, s.deterministic)
+}
+
+func TestWithTimestampAuthority(t *testing.T) {
+	for _, c := range []struct {
+		url   string
+		valid bool
+	}{
+		{"https://tsa.example.com/tsr", true},
+		{"http://tsa.example.com", true},
+		{"ftp://tsa.example.com", false},
+		{"tsa.example.com", false},
+		{"https://[invalid", false},
+	} {
+		var s simpleSigner
+		err := WithTimestampAuthority(c.url)(&s)
+		if c.valid {
+			require.NoError(t, err, c.url)
+			assert.Equal(t, c.url, s.tsaURL, c.url)
+		} else {
+			assert.Error(t, err, c.url)
+		}
+	}
+}
+
+func TestWithTimestampAuthorityHTTPClient(t *testing.T) {
+	client := &http.Client{}
+	var s simpleSigner
+	err := WithTimestampAuthorityHTTPClient(client)(&s)
+	require.NoError(t, err)
+	assert.Same(t, client, s.tsaClient)
+}
+
+func TestRequestTimestampToken(t *testing.T) {
+	tsa, err := rfc3161tsa.New()
+	require.NoError(t, err)
+	tsaServer := httptest.NewServer(tsa)
+	defer tsaServer.Close()
+	tsaRoots := x509.NewCertPool()
+	require.True(t, tsaRoots.AppendCertsFromPEM(tsa.RootCertificatePEM))
+	data := []byte("signature data")
+
+	token, err := requestTimestampToken(context.Background(), tsaServer.Client(), tsaServer.URL, data)
+	require.NoError(t, err)
+	_, err = internal.VerifyRFC3161TimestampToken(tsaRoots, token, data)
+	assert.NoError(t, err)
+
+	// HTTP error
+	notFoundServer := httptest.NewServer(http.NotFoundHandler())
+	defer notFoundServer.Close()
+	_, err = requestTimestampToken(context.Background(), notFoundServer.Client(), notFoundServer.URL, data)
+	assert.Error(t, err)
+
+	// Invalid response
+	invalidServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("this is not a timestamp response"))
+	}))
+	defer invalidServer.Close()
+	_, err = requestTimestampToken(context.Background(), invalidServer.Client(), invalidServer.URL, data)
+	assert.Error(t, err)
+
+	// The client’s TLS configuration is used
+	tlsServer := httptest.NewTLSServer(tsa)
+	defer tlsServer.Close()
+	_, err = requestTimestampToken(context.Background(), tlsServer.Client(), tlsServer.URL, data)
+	assert.NoError(t, err)
+	_, err = requestTimestampToken(context.Background(), &http.Client{}, tlsServer.URL, data)
+	assert.Error(t, err)
+}
@@ -0,0 +1,166 @@
+//go:build !containers_image_storage_stub
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/containers/storage"
+	graphdriver "github.com/containers/storage/drivers"
+	"github.com/containers/storage/pkg/chunked"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// fsVerityDigestsArtifact is the key of the fs-verity digests (a map[string]string from paths within the layer)
+	// in graphdriver.DriverWithDifferOutput.Artifacts; c/storage/pkg/chunked does not export this value.
+	fsVerityDigestsArtifact = "fs-verity-digests"
+	// layerFsVerityDigestsBigDataKey is the name of the layer big data item where we record the fs-verity digests
+	// of files in a layer, as generated when creating composefs metadata.
+	layerFsVerityDigestsBigDataKey = "containers-image-fs-verity-digests"
+)
+
+// usesComposefs returns true if a store using graphDriverName with graphOptions is configured to use composefs,
+// i.e. if layers created using a c/storage differ get composefs metadata.
+func usesComposefs(graphDriverName string, graphOptions []string) bool {
+	if graphDriverName != "overlay" && graphDriverName != "overlay2" {
+		return false
+	}
+	res := false
+	// This follows the option parsing in c/storage/drivers/overlay.
+	for _, option := range graphOptions {
+		key, val, ok := strings.Cut(option, "=")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		key = strings.TrimPrefix(key, "overlay.")
+		key = strings.TrimPrefix(key, "overlay2.")
+		key = strings.TrimPrefix(key, ".")
+		if key == "use_composefs" {
+			if b, err := strconv.ParseBool(strings.TrimSpace(val)); err == nil {
+				res = b
+			}
+		}
+	}
+	return res
+}
+
+// fileChunkAccessor implements chunked.ImageSourceSeekable for a local file.
+type fileChunkAccessor struct {
+	file *os.File
+}
+
+// GetBlobAt returns a sequential channel of readers that contain data for the requested chunks of the file.
+func (f fileChunkAccessor) GetBlobAt(chunks []chunked.ImageSourceChunk) (chan io.ReadCloser, chan error, error) {
+	streams := make(chan io.ReadCloser)
+	errs := make(chan error)
+	go func() {
+		defer close(streams)
+		defer close(errs)
+		for _, c := range chunks {
+			if c.Offset > math.MaxInt64 {
+				errs <- fmt.Errorf("invalid chunk offset %d", c.Offset)
+				return
+			}
+			length := int64(math.MaxInt64) // io.NewSectionReader handles the overflow
+			if c.Length <= math.MaxInt64 {
+				length = int64(c.Length)
+			}
+			streams <- io.NopCloser(io.NewSectionReader(f.file, int64(c.Offset), length))
+		}
+	}()
+	return streams, errs, nil
+}
+
+// putLayerWithDiffer creates newLayerID on top of parentLayer from the blob in file, which matches blobDigest,
+// using a c/storage differ, so that the graph driver generates composefs metadata for the layer now,
+// instead of extracting it as an ordinary layer.
+// If trustedDiffID is set, the uncompressed contents must match it.
+// If the store is not configured to allow that (i.e. convert_images is not set), it returns an error matching
+// chunked.ErrFallbackToOrdinaryLayerDownload, and the caller should create the layer using PutLayer.
+func (s *storageImageDestination) putLayerWithDiffer(file *os.File, blobDigest digest.Digest, trustedDiffID digest.Digest, parentLayer, newLayerID string) (*storage.Layer, error) {
+	fi, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	store := s.imageRef.transport.store
+	// TODO: This can take quite some time, and should ideally be cancellable using a context.
+	differ, err := chunked.GetDiffer(context.TODO(), store, blobDigest, fi.Size(), nil, fileChunkAccessor{file: file})
+	if err != nil {
+		return nil, err
+	}
+	out, err := store.PrepareStagedLayer(nil, differ)
+	if err != nil {
+		return nil, fmt.Errorf("staging layer %s: %w", blobDigest, err)
+	}
+	if trustedDiffID != "" {
+		if out.UncompressedDigest == "" {
+			out.UncompressedDigest = trustedDiffID
+		} else if out.UncompressedDigest != trustedDiffID {
+			_ = store.CleanupStagedLayer(out)
+			return nil, fmt.Errorf("uncompressed digest of layer %s is %s, expected %s", blobDigest, out.UncompressedDigest, trustedDiffID)
+		}
+	}
+	layer, err := store.ApplyStagedLayer(storage.ApplyStagedLayerOptions{
+		ID:          newLayerID,
+		ParentLayer: parentLayer,
+		DiffOutput:  out,
+		DiffOptions: &graphdriver.ApplyDiffWithDifferOpts{},
+	})
+	if err != nil {
+		_ = store.CleanupStagedLayer(out)
+		if !errors.Is(err, storage.ErrDuplicateID) {
+			return nil, fmt.Errorf("adding layer with blob %s: %w", blobDigest, err)
+		}
+		return layer, nil
+	}
+	if err := s.recordFsVerityDigests(layer.ID, out); err != nil {
+		return nil, err
+	}
+	logrus.Debugf("Created layer %q for blob %s with composefs metadata", layer.ID, blobDigest)
+	return layer, nil
+}
+
+// recordFsVerityDigests records the fs-verity digests computed by a differ, if any, for layerID.
+func (s *storageImageDestination) recordFsVerityDigests(layerID string, out *graphdriver.DriverWithDifferOutput) error {
+	digests, ok := out.Artifacts[fsVerityDigestsArtifact].(map[string]string)
+	if !ok || len(digests) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(digests)
+	if err != nil {
+		return err
+	}
+	if err := s.imageRef.transport.store.SetLayerBigData(layerID, layerFsVerityDigestsBigDataKey, bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("recording fs-verity digests of layer %q: %w", layerID, err)
+	}
+	return nil
+}
+
+// layerFsVerityDigests returns the fs-verity digests recorded for layerID, or nil if there are none.
+func layerFsVerityDigests(store storage.Store, layerID string) (map[string]string, error) {
+	rc, err := store.LayerBigData(layerID, layerFsVerityDigestsBigDataKey)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer rc.Close()
+	res := map[string]string{}
+	if err := json.NewDecoder(rc).Decode(&res); err != nil {
+		return nil, fmt.Errorf("parsing fs-verity digests of layer %q: %w", layerID, err)
+	}
+	return res, nil
+}
@@ -0,0 +1,99 @@
+//go:build !containers_image_storage_stub
+
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containers/image/v5/pkg/blobinfocache/memory"
+	"github.com/containers/storage/pkg/archive"
+	"github.com/containers/storage/pkg/chunked"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsesComposefs(t *testing.T) {
+	for _, c := range []struct {
+		driver   string
+		options  []string
+		expected bool
+	}{
+		{"overlay", nil, false},
+		{"overlay", []string{"overlay.mountopt=nodev"}, false},
+		{"overlay", []string{"overlay.use_composefs=true"}, true},
+		{"overlay", []string{"use_composefs=true"}, true},
+		{"overlay", []string{".use_composefs=1"}, true},
+		{"overlay2", []string{"overlay2.Use_Composefs=true"}, true},
+		{"overlay", []string{"overlay.use_composefs=false"}, false},
+		{"overlay", []string{"overlay.use_composefs=true", "overlay.use_composefs=false"}, false},
+		{"overlay", []string{"overlay.use_composefs=invalid"}, false},
+		{"vfs", []string{"overlay.use_composefs=true"}, false},
+	} {
+		assert.Equal(t, c.expected, usesComposefs(c.driver, c.options), "%s %#v", c.driver, c.options)
+	}
+}
+
+func TestFileChunkAccessor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blob")
+	err := os.WriteFile(path, []byte("0123456789"), 0o600)
+	require.NoError(t, err)
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	streams, errs, err := fileChunkAccessor{file: file}.GetBlobAt([]chunked.ImageSourceChunk{
+		{Offset: 1, Length: 2},
+		{Offset: 5, Length: 1},
+		{Offset: 8, Length: math.MaxUint64},
+	})
+	require.NoError(t, err)
+	res := []string{}
+	for stream := range streams {
+		data, err := io.ReadAll(stream)
+		require.NoError(t, err)
+		stream.Close()
+		res = append(res, string(data))
+	}
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, []string{"12", "5", "89"}, res)
+}
+
+func TestLayerFsVerityDigests(t *testing.T) {
+	ensureTestCanCreateImages(t)
+
+	store := newStore(t)
+	ref, err := Transport.ParseStoreReference(store, "test")
+	require.NoError(t, err)
+	createImage(t, ref, memory.New(), []testBlob{makeLayer(t, archive.Gzip)}, nil)
+	layers, err := ImageLayers(ref)
+	require.NoError(t, err)
+	require.Len(t, layers, 1)
+	layerID := layers[0].ID
+
+	res, err := layerFsVerityDigests(store, layerID)
+	require.NoError(t, err)
+	assert.Nil(t, res)
+
+	digests := map[string]string{"usr/bin/true": "sha256-aaaa"}
+	data, err := json.Marshal(digests)
+	require.NoError(t, err)
+	err = store.SetLayerBigData(layerID, layerFsVerityDigestsBigDataKey, bytes.NewReader(data))
+	require.NoError(t, err)
+	res, err = layerFsVerityDigests(store, layerID)
+	require.NoError(t, err)
+	assert.Equal(t, digests, res)
+	layers, err = ImageLayers(ref)
+	require.NoError(t, err)
+	assert.Equal(t, digests, layers[0].FsVerityDigests)
+
+	_, err = layerFsVerityDigests(store, "this-layer-does-not-exist")
+	assert.Error(t, err)
+}
@@ -16,6 +16,7 @@ import (
 	"sync/atomic"
 
 	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/internal/blobinfocache"
 	"github.com/containers/image/v5/internal/image"
 	"github.com/containers/image/v5/internal/imagedestination/impl"
 	"github.com/containers/image/v5/internal/imagedestination/stubs"
@@ -66,6 +67,7 @@ type storageImageDestination struct {
 	signatures            []byte                   // Signature contents, temporary
 	signatureses          map[digest.Digest][]byte // Instance signature contents, temporary
 	metadata              storageImageMetadata     // Metadata contents being built
+	usingComposefs        bool                     // The store is configured to use composefs; layers should be created using a differ to generate its metadata
 
 	// Mapping from layer (by index) to the associated ID in the storage.
 	// It's protected *implicitly* since `commitLayer()`, at any given
@@ -160,11 +162,16 @@ func newImageDestination(sys *types.SystemContext, imageRef storageReference) (*
 			MustMatchRuntimeOS:             true,
 			IgnoresEmbeddedDockerReference: true, // Yes, we want the unmodified manifest
 			HasThreadSafePutBlob:           true,
+			Capabilities: private.DestinationCapabilities{
+				Referrers: types.OptionalBoolFalse,
+				Deletion:  types.OptionalBoolTrue,
+			},
 		}),
 
-		imageRef:     imageRef,
-		directory:    directory,
-		signatureses: make(map[digest.Digest][]byte),
+		imageRef:       imageRef,
+		directory:      directory,
+		signatureses:   make(map[digest.Digest][]byte),
+		usingComposefs: usesComposefs(imageRef.transport.store.GraphDriverName(), imageRef.transport.store.GraphOptions()),
 		metadata: storageImageMetadata{
 			SignatureSizes:  []int{},
 			SignaturesSizes: make(map[digest.Digest][]int),
@@ -416,7 +423,10 @@ func (s *storageImageDestination) PutBlobPartial(ctx context.Context, chunkAcces
 
 	defer func() {
 		var perr chunked.ErrFallbackToOrdinaryLayerDownload
-		if errors.As(retErr, &perr) {
+		// c/storage only retries a BadPartialRequestError returned directly by GetBlobAt; if the registry rejects
+		// a later request for the remaining chunks, fall back to downloading the whole layer.
+		var badRequestErr private.BadPartialRequestError
+		if errors.As(retErr, &perr) || errors.As(retErr, &badRequestErr) {
 			retErr = private.NewErrFallbackToOrdinaryLayerDownload(retErr)
 		}
 	}()
@@ -603,6 +613,7 @@ func (s *storageImageDestination) tryReusingBlobAsPending(blobDigest digest.Dige
 		return false, private.ReusedBlob{}, fmt.Errorf(`looking for layers with digest %q: %w`, blobDigest, err)
 	}
 	if len(layers) > 0 {
+		recordReusedLayerProvenance(options.Cache, layers[0])
 		s.lockProtected.blobDiffIDs[blobDigest] = blobDigest
 		return true, private.ReusedBlob{
 			Digest: blobDigest,
@@ -622,6 +633,7 @@ func (s *storageImageDestination) tryReusingBlobAsPending(blobDigest digest.Dige
 		if diffID == "" {
 			return false, private.ReusedBlob{}, fmt.Errorf("internal error: compressed layer %q (for compressed digest %q) does not have an uncompressed digest", layers[0].ID, blobDigest.String())
 		}
+		recordReusedLayerProvenance(options.Cache, layers[0])
 		s.lockProtected.blobDiffIDs[blobDigest] = diffID
 		return true, private.ReusedBlob{
 			Digest: blobDigest,
@@ -639,6 +651,7 @@ func (s *storageImageDestination) tryReusingBlobAsPending(blobDigest digest.Dige
 				return false, private.ReusedBlob{}, fmt.Errorf(`looking for layers with digest %q: %w`, uncompressedDigest, err)
 			}
 			if found, reused := reusedBlobFromLayerLookup(layers, blobDigest, size, options); found {
+				recordReusedLayerProvenance(options.Cache, layers[0])
 				s.lockProtected.blobDiffIDs[reused.Digest] = uncompressedDigest
 				return true, reused, nil
 			}
@@ -655,6 +668,7 @@ func (s *storageImageDestination) tryReusingBlobAsPending(blobDigest digest.Dige
 				return false, private.ReusedBlob{}, fmt.Errorf(`looking for layers with digest %q: %w`, uncompressedDigest, err)
 			}
 			if found, reused := reusedBlobFromLayerLookup(layers, blobDigest, size, options); found {
+				recordReusedLayerProvenance(options.Cache, layers[0])
 				s.lockProtected.indexToDiffID[*options.LayerIndex] = uncompressedDigest
 				reused.MatchedByTOCDigest = true
 				return true, reused, nil
@@ -666,6 +680,7 @@ func (s *storageImageDestination) tryReusingBlobAsPending(blobDigest digest.Dige
 			return false, private.ReusedBlob{}, fmt.Errorf(`looking for layers with TOC digest %q: %w`, options.TOCDigest, err)
 		}
 		if found, reused := reusedBlobFromLayerLookup(layers, blobDigest, size, options); found {
+			recordReusedLayerProvenance(options.Cache, layers[0])
 			if uncompressedDigest == "" && layers[0].UncompressedDigest != "" {
 				// Determine an uncompressed digest if at all possible, to use a traditional image ID
 				// and to maximize image reuse.
@@ -705,6 +720,27 @@ func reusedBlobFromLayerLookup(layers []storage.Layer, blobDigest digest.Digest,
 	return false, private.ReusedBlob{}
 }
 
+// recordReusedLayerProvenance records the digests known for layer, which is about to be reused instead of pulling a blob, in cache.
+//
+// The layer might reside in an additional (read-only) image store, populated by a different process or host with a different
+// blob info cache; recording the compressed digest it was created from allows later pushes of images using this layer to find
+// (and reuse) the compressed variant, the same way as if we had pulled the blob ourselves.
+func recordReusedLayerProvenance(cache blobinfocache.BlobInfoCache2, layer storage.Layer) {
+	if layer.ReadOnly {
+		logrus.Debugf("Reusing layer %q from an additional image store", layer.ID)
+	}
+	if layer.UncompressedDigest == "" {
+		return
+	}
+	// c/storage has computed these values when creating the layer, so they are locally verified.
+	if layer.CompressedDigest != "" {
+		cache.RecordDigestUncompressedPair(layer.CompressedDigest, layer.UncompressedDigest)
+	}
+	if layer.TOCDigest != "" {
+		cache.RecordTOCUncompressedPair(layer.TOCDigest, layer.UncompressedDigest)
+	}
+}
+
 // trustedLayerIdentityData is a _consistent_ set of information known about a single layer.
 type trustedLayerIdentityData struct {
 	// true if we decided the layer should be identified by tocDigest, false if by diffID
@@ -1134,8 +1170,14 @@ func (s *storageImageDestination) createNewLayer(index int, trusted trustedLayer
 			},
 		}
 		layer, err := s.imageRef.transport.store.ApplyStagedLayer(args)
-		if err != nil && !errors.Is(err, storage.ErrDuplicateID) {
-			return nil, fmt.Errorf("failed to put layer using a partial pull: %w", err)
+		if err != nil {
+			if !errors.Is(err, storage.ErrDuplicateID) {
+				return nil, fmt.Errorf("failed to put layer using a partial pull: %w", err)
+			}
+			return layer, nil
+		}
+		if err := s.recordFsVerityDigests(layer.ID, diffOutput); err != nil {
+			return nil, err
 		}
 		return layer, nil
 	}
@@ -1265,6 +1307,17 @@ func (s *storageImageDestination) createNewLayer(index int, trusted trustedLayer
 		return nil, fmt.Errorf("opening file %q: %w", filename, err)
 	}
 	defer file.Close()
+	if s.usingComposefs && trustedOriginalDigest != "" {
+		layer, err := s.putLayerWithDiffer(file, trustedOriginalDigest, trusted.diffID, parentLayer, newLayerID)
+		if err == nil {
+			return layer, nil
+		}
+		var fallbackErr chunked.ErrFallbackToOrdinaryLayerDownload
+		if !errors.As(err, &fallbackErr) {
+			return nil, err
+		}
+		logrus.Debugf("Not generating composefs metadata for layer with blob %s: %v", trusted.logString(), err)
+	}
 	// Build the new layer using the diff, regardless of where it came from.
 	// TODO: This can take quite some time, and should ideally be cancellable using ctx.Done().
 	layer, _, err := s.imageRef.transport.store.PutLayer(newLayerID, parentLayer, nil, "", false, &storage.LayerOptions{
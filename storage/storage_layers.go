@@ -0,0 +1,86 @@
+//go:build !containers_image_storage_stub
+
+package storage
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/containers/image/v5/transports"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/sirupsen/logrus"
+)
+
+// ImageLayer describes a storage layer of an image.
+type ImageLayer struct {
+	// ID is the ID of the layer in the store.
+	ID string
+	// UncompressedDigest is the digest of the uncompressed layer contents (the DiffID), or "" if unknown
+	// (typically for layers pulled by TOC, without computing the full uncompressed digest).
+	UncompressedDigest digest.Digest
+	// TOCDigest is the digest of the TOC of the layer, if the layer was pulled using a TOC, or "".
+	TOCDigest digest.Digest
+	// UncompressedSize is the size of the uncompressed layer contents, or -1 if unknown.
+	UncompressedSize int64
+	// SizeOnDisk is an approximation of the storage used by the layer, as computed by the graph driver, or -1 if unknown.
+	// Note that layers may be shared with other images.
+	SizeOnDisk int64
+	// FsVerityDigests are the fs-verity digests of regular files in the layer, by path, if they were computed
+	// when generating composefs metadata while the layer was pulled; nil otherwise.
+	// They can be used e.g. to build a signed policy covering the layer contents.
+	FsVerityDigests map[string]string
+}
+
+// ImageLayers returns the storage layers of the image referenced by ref, from the base layer to the top layer.
+// This can be used e.g. after copying an image to containers-storage (using copy.Options.ReportResolvedReference),
+// to determine the actual storage impact of the image.
+//
+// Returns an error matching ErrNoSuchImage if an image matching ref was not found.
+func ImageLayers(ref types.ImageReference) ([]ImageLayer, error) {
+	sref, ok := ref.(*storageReference)
+	if !ok {
+		return nil, fmt.Errorf("trying to list layers of a non-%s: reference %q", Transport.Name(),
+			transports.ImageName(ref))
+	}
+	img, err := sref.resolveImage(nil)
+	if err != nil {
+		return nil, err
+	}
+	store := sref.transport.store
+	res := []ImageLayer{}
+	for layerID := img.TopLayer; layerID != ""; {
+		layer, err := store.Layer(layerID)
+		if err != nil {
+			return nil, fmt.Errorf("reading layer %q of image %q: %w", layerID, img.ID, err)
+		}
+		sizeOnDisk, err := store.LayerSize(layerID)
+		if err != nil {
+			// The size is informational; don’t fail the whole listing, e.g. for layers in an Additional Layer Store.
+			logrus.Debugf("Computing size of layer %q of image %q: %v", layerID, img.ID, err)
+			sizeOnDisk = -1
+		} else if sizeOnDisk < 0 {
+			sizeOnDisk = -1
+		}
+		fsVerityDigests, err := layerFsVerityDigests(store, layerID)
+		if err != nil {
+			return nil, fmt.Errorf("reading fs-verity digests of layer %q of image %q: %w", layerID, img.ID, err)
+		}
+		uncompressedSize := int64(-1)
+		// As in getSize, layers in an Additional Layer Store may not provide UncompressedSize.
+		if (layer.UncompressedDigest != "" || layer.TOCDigest != "") && layer.UncompressedSize >= 0 {
+			uncompressedSize = layer.UncompressedSize
+		}
+		res = append(res, ImageLayer{
+			ID:                 layer.ID,
+			UncompressedDigest: layer.UncompressedDigest,
+			TOCDigest:          layer.TOCDigest,
+			UncompressedSize:   uncompressedSize,
+			SizeOnDisk:         sizeOnDisk,
+			FsVerityDigests:    fsVerityDigests,
+		})
+		layerID = layer.Parent
+	}
+	slices.Reverse(res)
+	return res, nil
+}
@@ -0,0 +1,52 @@
+//go:build !containers_image_storage_stub
+
+package storage
+
+import (
+	"testing"
+
+	"github.com/containers/image/v5/directory"
+	"github.com/containers/image/v5/pkg/blobinfocache/memory"
+	"github.com/containers/storage/pkg/archive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageLayers(t *testing.T) {
+	ensureTestCanCreateImages(t)
+
+	store := newStore(t)
+	cache := memory.New()
+
+	layer1 := makeLayer(t, archive.Gzip)
+	layer2 := makeLayer(t, archive.Uncompressed)
+	ref, err := Transport.ParseStoreReference(store, "test")
+	require.NoError(t, err)
+	createImage(t, ref, cache, []testBlob{layer1, layer2}, nil)
+
+	layers, err := ImageLayers(ref)
+	require.NoError(t, err)
+	require.Len(t, layers, 2)
+	for i, expected := range []testBlob{layer1, layer2} {
+		assert.Equal(t, expected.uncompressedDigest, layers[i].UncompressedDigest)
+		assert.Equal(t, expected.uncompressedSize, layers[i].UncompressedSize)
+		assert.Empty(t, layers[i].TOCDigest)
+		assert.NotEqual(t, int64(0), layers[i].SizeOnDisk)
+		assert.Nil(t, layers[i].FsVerityDigests)
+	}
+	layer, err := store.Layer(layers[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, layers[0].ID, layer.Parent)
+
+	// Nonexistent image
+	ref, err = Transport.ParseStoreReference(store, "nonexistent")
+	require.NoError(t, err)
+	_, err = ImageLayers(ref)
+	assert.ErrorIs(t, err, ErrNoSuchImage)
+
+	// Not a storage reference
+	dirRef, err := directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+	_, err = ImageLayers(dirRef)
+	assert.Error(t, err)
+}
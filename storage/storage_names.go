@@ -0,0 +1,153 @@
+//go:build !containers_image_storage_stub
+
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/transports"
+	"github.com/containers/image/v5/types"
+	"github.com/containers/storage"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/sirupsen/logrus"
+)
+
+// imageNames returns the values to record in storage.Image.Names for names, or an error if any of them is not suitable.
+func imageNames(names []reference.Named) ([]string, error) {
+	res := make([]string, 0, len(names))
+	for _, name := range names {
+		if _, ok := name.(reference.Digested); ok {
+			return nil, fmt.Errorf("image name %q contains a digest; use AddImageManifestDigest instead: %w", name.String(), ErrInvalidReference)
+		}
+		// Match the names recorded by ParseStoreReference + the destination, which always include a tag.
+		res = append(res, reference.TagNameOnly(name).String())
+	}
+	return res, nil
+}
+
+// AddImageNames adds names (e.g. tags) to the image referenced by ref; names without a tag use the "latest" tag.
+// Names which are already in use by other images are moved to this image, as when pulling an image.
+// names must not contain digests; to allow referring to the image using another manifest digest, use AddImageManifestDigest.
+//
+// Returns an error matching ErrNoSuchImage if an image matching ref was not found.
+func AddImageNames(ref types.ImageReference, names []reference.Named) error {
+	sref, ok := ref.(*storageReference)
+	if !ok {
+		return fmt.Errorf("trying to add names to a non-%s: reference %q", Transport.Name(), transports.ImageName(ref))
+	}
+	values, err := imageNames(names)
+	if err != nil {
+		return err
+	}
+	img, err := sref.resolveImage(nil)
+	if err != nil {
+		return err
+	}
+	store := sref.transport.store
+	if err := store.AddNames(img.ID, values); err != nil {
+		return fmt.Errorf("adding names %v to image %q: %w", values, img.ID, err)
+	}
+	logrus.Debugf("added names %v to image %q", values, img.ID)
+	return nil
+}
+
+// RemoveImageNames removes names (e.g. tags) from the image referenced by ref; names without a tag use the "latest" tag.
+// Names which are not present on the image are ignored. The image itself is not deleted, even if it has no names left.
+//
+// Returns an error matching ErrNoSuchImage if an image matching ref was not found.
+func RemoveImageNames(ref types.ImageReference, names []reference.Named) error {
+	sref, ok := ref.(*storageReference)
+	if !ok {
+		return fmt.Errorf("trying to remove names from a non-%s: reference %q", Transport.Name(), transports.ImageName(ref))
+	}
+	values, err := imageNames(names)
+	if err != nil {
+		return err
+	}
+	img, err := sref.resolveImage(nil)
+	if err != nil {
+		return err
+	}
+	store := sref.transport.store
+	if err := store.RemoveNames(img.ID, values); err != nil {
+		return fmt.Errorf("removing names %v from image %q: %w", values, img.ID, err)
+	}
+	logrus.Debugf("removed names %v from image %q", values, img.ID)
+	return nil
+}
+
+// AddImageManifestDigest records manifestBlob as an additional manifest of the image referenced by ref,
+// so that the image can also be referenced using the digest of manifestBlob (e.g. after the image was pushed
+// to a registry in a different format, or as a part of a different manifest list), and returns that digest.
+//
+// manifestBlob must either be a single-image manifest using the same config as the image,
+// or a manifest list / image index which contains one of the manifest digests already recorded for the image.
+//
+// Returns an error matching ErrNoSuchImage if an image matching ref was not found.
+func AddImageManifestDigest(ref types.ImageReference, manifestBlob []byte, mimeType string) (digest.Digest, error) {
+	sref, ok := ref.(*storageReference)
+	if !ok {
+		return "", fmt.Errorf("trying to add a manifest digest to a non-%s: reference %q", Transport.Name(), transports.ImageName(ref))
+	}
+	img, err := sref.resolveImage(nil)
+	if err != nil {
+		return "", err
+	}
+	store := sref.transport.store
+	if err := manifestRefersToImage(store, img, manifestBlob, mimeType); err != nil {
+		return "", fmt.Errorf("adding a manifest to image %q: %w", img.ID, err)
+	}
+	manifestDigest, err := manifest.Digest(manifestBlob)
+	if err != nil {
+		return "", fmt.Errorf("digesting manifest: %w", err)
+	}
+	key, err := manifestBigDataKey(manifestDigest)
+	if err != nil {
+		return "", err
+	}
+	if err := store.SetImageBigData(img.ID, key, manifestBlob, manifest.Digest); err != nil {
+		return "", fmt.Errorf("saving manifest %q for image %q: %w", manifestDigest.String(), img.ID, err)
+	}
+	logrus.Debugf("added manifest digest %q to image %q", manifestDigest.String(), img.ID)
+	return manifestDigest, nil
+}
+
+// manifestRefersToImage returns nil if manifestBlob, with mimeType ("" if unknown), refers to img, or an error explaining why not.
+func manifestRefersToImage(store storage.Store, img *storage.Image, manifestBlob []byte, mimeType string) error {
+	if mimeType == "" {
+		mimeType = manifest.GuessMIMEType(manifestBlob)
+	}
+	if manifest.MIMETypeIsMultiImage(mimeType) {
+		list, err := manifest.ListFromBlob(manifestBlob, mimeType)
+		if err != nil {
+			return err
+		}
+		for _, instance := range list.Instances() {
+			if slices.Contains(img.Digests, instance) {
+				return nil
+			}
+		}
+		return errors.New("the manifest list does not contain any of the image’s manifests")
+	}
+
+	m, err := manifest.FromBlob(manifestBlob, mimeType)
+	if err != nil {
+		return err
+	}
+	primaryBlob, err := store.ImageBigData(img.ID, storage.ImageDigestBigDataKey)
+	if err != nil {
+		return fmt.Errorf("reading the image’s manifest: %w", err)
+	}
+	primary, err := manifest.FromBlob(primaryBlob, manifest.GuessMIMEType(primaryBlob))
+	if err != nil {
+		return fmt.Errorf("parsing the image’s manifest: %w", err)
+	}
+	if m.ConfigInfo().Digest != primary.ConfigInfo().Digest {
+		return fmt.Errorf("the manifest uses config %q, but the image uses %q", m.ConfigInfo().Digest.String(), primary.ConfigInfo().Digest.String())
+	}
+	return nil
+}
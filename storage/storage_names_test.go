@@ -0,0 +1,125 @@
+//go:build !containers_image_storage_stub
+
+package storage
+
+import (
+	"testing"
+
+	"github.com/containers/image/v5/directory"
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/pkg/blobinfocache/memory"
+	"github.com/containers/storage"
+	"github.com/containers/storage/pkg/archive"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageNames(t *testing.T) {
+	ensureTestCanCreateImages(t)
+
+	store := newStore(t)
+	cache := memory.New()
+
+	layer := makeLayer(t, archive.Gzip)
+	ref, err := Transport.ParseStoreReference(store, "test")
+	require.NoError(t, err)
+	createImage(t, ref, cache, []testBlob{layer}, nil)
+	_, img, err := ResolveReference(ref)
+	require.NoError(t, err)
+
+	parse := func(s string) reference.Named {
+		named, err := reference.ParseNormalizedNamed(s)
+		require.NoError(t, err)
+		return named
+	}
+
+	err = AddImageNames(ref, []reference.Named{parse("example.com/a:b"), parse("example.com/c")})
+	require.NoError(t, err)
+	updated, err := store.Image(img.ID)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"docker.io/library/test:latest", "example.com/a:b", "example.com/c:latest"}, updated.Names)
+
+	err = RemoveImageNames(ref, []reference.Named{parse("example.com/c"), parse("example.com/nonexistent:tag")})
+	require.NoError(t, err)
+	updated, err = store.Image(img.ID)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"docker.io/library/test:latest", "example.com/a:b"}, updated.Names)
+
+	// Digests are rejected
+	digested := parse("example.com/a@" + img.Digest.String())
+	err = AddImageNames(ref, []reference.Named{digested})
+	assert.ErrorIs(t, err, ErrInvalidReference)
+	err = RemoveImageNames(ref, []reference.Named{digested})
+	assert.ErrorIs(t, err, ErrInvalidReference)
+
+	// A manifest in a different format, using the same config, can be added
+	manifestBlob, err := store.ImageBigData(img.ID, storage.ImageDigestBigDataKey)
+	require.NoError(t, err)
+	s2, err := manifest.Schema2FromManifest(manifestBlob)
+	require.NoError(t, err)
+	layers := []imgspecv1.Descriptor{}
+	for _, l := range s2.LayersDescriptors {
+		layers = append(layers, imgspecv1.Descriptor{MediaType: imgspecv1.MediaTypeImageLayerGzip, Digest: l.Digest, Size: l.Size})
+	}
+	ociBlob, err := manifest.OCI1FromComponents(imgspecv1.Descriptor{
+		MediaType: imgspecv1.MediaTypeImageConfig,
+		Digest:    s2.ConfigDescriptor.Digest,
+		Size:      s2.ConfigDescriptor.Size,
+	}, layers).Serialize()
+	require.NoError(t, err)
+	ociDigest, err := AddImageManifestDigest(ref, ociBlob, imgspecv1.MediaTypeImageManifest)
+	require.NoError(t, err)
+	digestRef, err := Transport.ParseStoreReference(store, "example.com/a@"+ociDigest.String())
+	require.NoError(t, err)
+	_, resolved, err := ResolveReference(digestRef)
+	require.NoError(t, err)
+	assert.Equal(t, img.ID, resolved.ID)
+	assert.Equal(t, ociDigest, resolved.Digest)
+
+	// A manifest list containing the image can be added
+	listBlob, err := manifest.OCI1IndexFromComponents([]imgspecv1.Descriptor{
+		{MediaType: imgspecv1.MediaTypeImageManifest, Digest: ociDigest, Size: int64(len(ociBlob))},
+	}, nil).Serialize()
+	require.NoError(t, err)
+	_, err = AddImageManifestDigest(ref, listBlob, "")
+	require.NoError(t, err)
+
+	// Manifests not referring to the image are rejected
+	otherConfig := configForLayers(t, []testBlob{layer})
+	otherBlob, err := manifest.OCI1FromComponents(imgspecv1.Descriptor{
+		MediaType: imgspecv1.MediaTypeImageConfig,
+		Digest:    otherConfig.compressedDigest,
+		Size:      otherConfig.compressedSize,
+	}, layers).Serialize()
+	require.NoError(t, err)
+	_, err = AddImageManifestDigest(ref, otherBlob, imgspecv1.MediaTypeImageManifest)
+	assert.Error(t, err)
+	otherListBlob, err := manifest.OCI1IndexFromComponents([]imgspecv1.Descriptor{
+		{MediaType: imgspecv1.MediaTypeImageManifest, Digest: otherConfig.compressedDigest, Size: 1},
+	}, nil).Serialize()
+	require.NoError(t, err)
+	_, err = AddImageManifestDigest(ref, otherListBlob, imgspecv1.MediaTypeImageIndex)
+	assert.Error(t, err)
+
+	// Nonexistent image
+	nonexistent, err := Transport.ParseStoreReference(store, "nonexistent")
+	require.NoError(t, err)
+	err = AddImageNames(nonexistent, []reference.Named{parse("example.com/d:e")})
+	assert.ErrorIs(t, err, ErrNoSuchImage)
+	err = RemoveImageNames(nonexistent, []reference.Named{parse("example.com/d:e")})
+	assert.ErrorIs(t, err, ErrNoSuchImage)
+	_, err = AddImageManifestDigest(nonexistent, ociBlob, imgspecv1.MediaTypeImageManifest)
+	assert.ErrorIs(t, err, ErrNoSuchImage)
+
+	// Not a storage reference
+	dirRef, err := directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+	err = AddImageNames(dirRef, []reference.Named{parse("example.com/d:e")})
+	assert.Error(t, err)
+	err = RemoveImageNames(dirRef, []reference.Named{parse("example.com/d:e")})
+	assert.Error(t, err)
+	_, err = AddImageManifestDigest(dirRef, ociBlob, imgspecv1.MediaTypeImageManifest)
+	assert.Error(t, err)
+}
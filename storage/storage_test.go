@@ -20,6 +20,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/containers/image/v5/internal/blobinfocache"
 	imanifest "github.com/containers/image/v5/internal/manifest"
 	"github.com/containers/image/v5/internal/private"
 	"github.com/containers/image/v5/manifest"
@@ -746,3 +747,41 @@ func (u *unparsedImage) Manifest(context.Context) ([]byte, string, error) {
 func (u *unparsedImage) Signatures(context.Context) ([][]byte, error) {
 	return u.signatures, nil
 }
+
+func TestReuseFromAdditionalImageStore(t *testing.T) {
+	ensureTestCanCreateImages(t)
+
+	// Create an image in a store which will be used as an additional image store.
+	additionalStore := newStore(t)
+	ref, err := Transport.ParseReference("test")
+	require.NoError(t, err)
+	layer := makeLayer(t, archive.Gzip)
+	config := configForLayers(t, []testBlob{layer})
+	createImage(t, ref, memory.New(), []testBlob{layer}, &config)
+	_, err = additionalStore.Shutdown(true)
+	require.NoError(t, err)
+	// Use a copy, because this process already holds read-write locks for the original.
+	additionalImageStore := filepath.Join(t.TempDir(), "additional")
+	err = os.CopyFS(additionalImageStore, os.DirFS(additionalStore.GraphRoot()))
+	require.NoError(t, err)
+
+	newStoreWithGraphDriverOptions(t, []string{"vfs.imagestore=" + additionalImageStore})
+	ref, err = Transport.ParseReference("test2")
+	require.NoError(t, err)
+	dest, err := ref.NewImageDestination(context.Background(), nil)
+	require.NoError(t, err)
+	defer dest.Close()
+	privateDest, ok := dest.(private.ImageDestination)
+	require.True(t, ok)
+
+	// A fresh cache knows nothing about the layer; reusing the layer records its compressed digest.
+	cache := blobinfocache.FromBlobInfoCache(memory.New())
+	assert.Equal(t, digest.Digest(""), cache.UncompressedDigest(layer.compressedDigest))
+	reused, reusedInfo, err := privateDest.TryReusingBlobWithOptions(context.Background(),
+		types.BlobInfo{Digest: layer.compressedDigest, Size: layer.compressedSize},
+		private.TryReusingBlobOptions{Cache: cache})
+	require.NoError(t, err)
+	assert.True(t, reused)
+	assert.Equal(t, layer.compressedDigest, reusedInfo.Digest)
+	assert.Equal(t, layer.uncompressedDigest, cache.UncompressedDigest(layer.compressedDigest))
+}
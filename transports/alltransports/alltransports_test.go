@@ -27,6 +27,7 @@ func TestParseImageName(t *testing.T) {
 func TestImageNameHandling(t *testing.T) {
 	// Always registered transports
 	for _, c := range []struct{ transport, input, roundtrip string }{
+		{"composite", "image=docker://busybox;signatures=dir:/etc", "image=docker://busybox:latest;signatures=dir:/etc"},
 		{"dir", "/etc", "/etc"},
 		{"docker", "//busybox", "//busybox:latest"},
 		{"docker", "//busybox:notlatest", "//busybox:notlatest"}, // This also tests handling of multiple ":" characters
@@ -0,0 +1,26 @@
+package transports
+
+import (
+	"context"
+	"slices"
+
+	"github.com/containers/image/v5/internal/imagedestination"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/types"
+)
+
+// DestinationCapabilities returns the capabilities of dest.
+// It may contact a remote server (to determine whether signatures are supported).
+func DestinationCapabilities(ctx context.Context, dest types.ImageDestination) types.ImageDestinationCapabilities {
+	d := imagedestination.FromPublic(dest)
+	mimeTypes := d.SupportedManifestMIMETypes()
+	caps := d.Capabilities()
+	return types.ImageDestinationCapabilities{
+		ManifestLists:   len(mimeTypes) == 0 || slices.ContainsFunc(mimeTypes, manifest.MIMETypeIsMultiImage),
+		Signatures:      d.SupportsSignatures(ctx) == nil,
+		PartialPulls:    d.SupportsPutBlobPartial(),
+		Referrers:       caps.Referrers,
+		Deletion:        caps.Deletion,
+		MaxManifestSize: caps.MaxManifestSize,
+	}
+}
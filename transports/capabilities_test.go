@@ -0,0 +1,49 @@
+package transports_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/containers/image/v5/directory"
+	"github.com/containers/image/v5/oci/layout"
+	"github.com/containers/image/v5/transports"
+	"github.com/containers/image/v5/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDestinationCapabilities(t *testing.T) {
+	dirRef, err := directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+	ociRef, err := layout.NewReference(t.TempDir(), "latest")
+	require.NoError(t, err)
+
+	for _, c := range []struct {
+		ref      types.ImageReference
+		expected types.ImageDestinationCapabilities
+	}{
+		{
+			ref: dirRef,
+			expected: types.ImageDestinationCapabilities{
+				ManifestLists: true,
+				Signatures:    true,
+				Referrers:     types.OptionalBoolFalse,
+				Deletion:      types.OptionalBoolFalse,
+			},
+		},
+		{
+			ref: ociRef,
+			expected: types.ImageDestinationCapabilities{
+				ManifestLists: true,
+				Signatures:    false,
+				Referrers:     types.OptionalBoolTrue,
+				Deletion:      types.OptionalBoolTrue,
+			},
+		},
+	} {
+		dest, err := c.ref.NewImageDestination(context.Background(), nil)
+		require.NoError(t, err)
+		defer dest.Close()
+		assert.Equal(t, c.expected, transports.DestinationCapabilities(context.Background(), dest), c.ref.Transport().Name())
+	}
+}
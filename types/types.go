@@ -3,6 +3,7 @@ package types
 import (
 	"context"
 	"io"
+	"net/http"
 	"net/url"
 	"time"
 
@@ -116,6 +117,8 @@ const (
 	Encrypt
 	// Decrypt indicates the layer is decrypted
 	Decrypt
+	// Reencrypt indicates the layer was decrypted, possibly modified (e.g. recompressed), and encrypted again
+	Reencrypt
 )
 
 // BlobInfo collects known information about a blob (layer/config).
@@ -451,6 +454,14 @@ type ManifestUpdateOptions struct {
 	LayerInfos              []BlobInfo // Complete BlobInfos (size+digest+urls+annotations) which should replace the originals, in order (the root layer first, and then successive layered layers). BlobInfos' MediaType fields are ignored.
 	EmbeddedDockerReference reference.Named
 	ManifestMIMEType        string
+	// LayerDiffIDs, if not nil, contains DiffID values which should replace the originals in the image config, in the same order as LayerInfos;
+	// an empty value leaves the DiffID of that layer unchanged. This is used when the uncompressed contents of layers were modified during a copy.
+	LayerDiffIDs []digest.Digest
+	// Config, if not nil, is an edited version of the image configuration as returned by Image.OCIConfig. Fields which differ from
+	// the current configuration replace the corresponding values in the config; other values, including those not represented in v1.Image,
+	// are preserved. RootFS is ignored, see LayerDiffIDs instead. The configuration of Docker schema1 images can only be edited
+	// when converting them to a different format.
+	Config *v1.Image
 	// The values below are NOT requests to modify the image; they provide optional context which may or may not be used.
 	InformationOnly ManifestUpdateInformation
 }
@@ -500,6 +511,116 @@ type DockerAuthConfig struct {
 	IdentityToken string
 }
 
+// DockerRequestSigner signs requests sent to container registries, for registries which require
+// request signing (e.g. AWS Signature Version 4) instead of the usual authentication challenges.
+type DockerRequestSigner interface {
+	// SignRequest adds authentication data to req immediately before it is sent.
+	// It may read req.Body only through req.GetBody, and must not consume req.Body itself.
+	// It is called again for every retry of a request.
+	SignRequest(req *http.Request) error
+}
+
+// DockerRequestRateLimiter limits the rate of requests sent to container registries.
+// A single value is typically shared by many SystemContexts, so it must be safe for concurrent use.
+type DockerRequestRateLimiter interface {
+	// Wait blocks until a request to repository (or to registry-wide endpoints, if repository is "") on registry,
+	// a host[:port] value, may be sent; it returns an error if ctx is canceled first.
+	// It is called again for every retry of a request.
+	Wait(ctx context.Context, registry, repository string) error
+}
+
+// DockerTokenCache caches bearer tokens issued by registry token servers, so that they can be shared by connections
+// to the same registry (and, depending on the implementation, across processes).
+// Keys are opaque strings computed by the docker transport; a key identifies the registry, the token server, the credentials
+// and the requested scopes. Keys are derived from the credentials without a secret, so implementations which store keys
+// persistently or where others can see them must not store them unmodified (e.g. use a HMAC of the key with a secret instead).
+// Tokens grant access to registries, so they must be stored securely.
+// A single value is typically shared by many SystemContexts, so it must be safe for concurrent use.
+type DockerTokenCache interface {
+	// GetToken returns the token recorded for key, and the time it expires, if any.
+	// It may return expired tokens; callers must check the expiration time.
+	GetToken(key string) (token string, expiration time.Time, found bool)
+	// PutToken records token, which expires at expiration, for key.
+	PutToken(key string, token string, expiration time.Time)
+}
+
+// DockerTokenProvider obtains credentials for container registries when they are needed, e.g. by exchanging cloud workload identity
+// credentials for short-lived registry tokens (as used by Amazon ECR or Google Artifact Registry).
+// A single value is typically shared by many SystemContexts, so it must be safe for concurrent use.
+type DockerTokenProvider interface {
+	// RegistryCredentials returns credentials for registry (a host[:port] value), and the time they expire (the zero value if they don’t).
+	// The credentials are used like DockerAuthConfig: for basic authentication, or to obtain bearer tokens from the registry’s token server
+	// (using an OAuth2 refresh token if IdentityToken is set).
+	// It is called when a registry connection first needs credentials, and again after they expire.
+	RegistryCredentials(ctx context.Context, registry string) (DockerAuthConfig, time.Time, error)
+}
+
+// DockerRegistryBackend is an experimental alternative to accessing container registries directly over HTTP(S),
+// e.g. a gateway-mediated or gRPC-based protocol.
+// The docker transport still expresses all registry operations as requests of the Docker Registry HTTP API V2,
+// so that docker:// references and all features of the transport keep working; the backend is responsible for executing them.
+// Warning: This API is experimental and can be changed without bumping the major version number.
+type DockerRegistryBackend interface {
+	// Do executes req, a request for registry (a host[:port] value), and returns the response, like http.Client.Do.
+	// The backend is responsible for authenticating to the registry; req does not contain any credentials.
+	// Requests to other hosts (e.g. external blob URLs) are not sent to the backend.
+	Do(registry string, req *http.Request) (*http.Response, error)
+}
+
+// DockerRetryPolicy configures retrying failed requests made by the docker transport, with exponential backoff.
+type DockerRetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first one; values ≤ 1 disable retries.
+	MaxAttempts int
+	// InitialDelay is the delay before the first retry, doubled after every retry; if 0, a default of 2 seconds is used.
+	// A longer delay requested by the registry (using a Retry-After header) is honored.
+	InitialDelay time.Duration
+	// MaxDelay limits the delay between attempts; if 0, a default of 60 seconds is used.
+	MaxDelay time.Duration
+}
+
+// OCILayoutBlobStore is an alternative storage for blobs of OCI layouts accessed using the oci: transport
+// (e.g. a content-addressed store, or object storage), instead of the blobs subdirectory of the layout.
+// The index.json and oci-layout files are still stored in the layout directory; blobs, including manifests and configs,
+// are read and written using this interface.
+// All digests passed to the store are valid; the caller verifies that blob contents match their digests.
+// Implementations must be safe for concurrent use.
+// Warning: This API is experimental and can be changed without bumping the major version number.
+type OCILayoutBlobStore interface {
+	// GetBlob returns a stream for the blob with blobDigest in the layout at layoutDir, and the blob’s size (or -1 if unknown).
+	// If the stream implements io.ReaderAt, it is used for partial pulls.
+	// If the blob does not exist, it returns an error satisfying errors.Is(err, fs.ErrNotExist).
+	GetBlob(ctx context.Context, layoutDir string, blobDigest digest.Digest) (io.ReadCloser, int64, error)
+	// BlobSize returns the size of the blob with blobDigest in the layout at layoutDir.
+	// If the blob does not exist, it returns an error satisfying errors.Is(err, fs.ErrNotExist).
+	BlobSize(ctx context.Context, layoutDir string, blobDigest digest.Digest) (int64, error)
+	// PutBlob stores contents of stream, of size bytes, as the blob with blobDigest in the layout at layoutDir,
+	// replacing the blob if it already exists.
+	PutBlob(ctx context.Context, layoutDir string, blobDigest digest.Digest, stream io.Reader, size int64) error
+	// DeleteBlob deletes the blob with blobDigest from the layout at layoutDir.
+	// If the blob does not exist, it returns an error satisfying errors.Is(err, fs.ErrNotExist).
+	DeleteBlob(ctx context.Context, layoutDir string, blobDigest digest.Digest) error
+}
+
+// ImageDestinationCapabilities describes which features an ImageDestination supports,
+// to allow callers to adapt their behavior instead of failing late; see transports.DestinationCapabilities.
+type ImageDestinationCapabilities struct {
+	// ManifestLists is true if the destination can store manifest lists / image indexes.
+	ManifestLists bool
+	// Signatures is true if the destination can store signatures (i.e. SupportsSignatures succeeds).
+	Signatures bool
+	// PartialPulls is true if the destination can create layers using only the missing chunks of a blob,
+	// instead of downloading whole layers.
+	PartialPulls bool
+	// Referrers is OptionalBoolTrue if the destination can store artifacts referring to an image using the OCI “subject” field.
+	// It is OptionalBoolUndefined if that can only be determined by trying (e.g. it depends on the registry).
+	Referrers OptionalBool
+	// Deletion is OptionalBoolTrue if images can be deleted using ImageReference.DeleteImage.
+	// It is OptionalBoolUndefined if that can only be determined by trying.
+	Deletion OptionalBool
+	// MaxManifestSize is the maximum size of a manifest the destination is expected to accept, or 0 if unknown or unlimited.
+	MaxManifestSize int64
+}
+
 // OptionalBool is a boolean with an additional undefined value, which is meant
 // to be used in the context of user input to distinguish between a
 // user-specified value and a default value.
@@ -554,6 +675,51 @@ const (
 	ShortNameModeEnforcing
 )
 
+// DockerArchiveFormat selects the metadata describing images in archives written by docker-archive: and docker-daemon: destinations.
+type DockerArchiveFormat int
+
+const (
+	// DockerArchiveFormatDockerSave writes the (docker save) manifest.json, and the legacy metadata selected by
+	// SystemContext.DockerArchiveLegacyMetadata.
+	DockerArchiveFormatDockerSave DockerArchiveFormat = iota
+	// DockerArchiveFormatDockerSaveAndOCILayout also writes an OCI image layout (oci-layout, index.json, and blobs/<algorithm>/<encoded digest>),
+	// like archives created by recent versions of Docker, so that the archive can be consumed both by (docker load) and by OCI tooling.
+	DockerArchiveFormatDockerSaveAndOCILayout
+	// DockerArchiveFormatOCILayout writes only an OCI image layout, without the (docker save) metadata.
+	// Recent versions of (docker load) accept such archives, but they can not be read by docker-archive: sources.
+	DockerArchiveFormatOCILayout
+)
+
+// DockerArchiveLegacyMetadata selects whether archives written by docker-archive: and docker-daemon: destinations
+// contain the legacy (docker save) metadata: the per-layer directories (VERSION, json, layer.tar) and the repositories file.
+// Only versions of Docker before 1.10 need that metadata.
+type DockerArchiveLegacyMetadata int
+
+const (
+	// DockerArchiveLegacyMetadataInclude writes the legacy metadata in addition to manifest.json, like (docker save).
+	DockerArchiveLegacyMetadataInclude DockerArchiveLegacyMetadata = iota
+	// DockerArchiveLegacyMetadataOmit writes only manifest.json, which roughly halves the number of archive entries.
+	DockerArchiveLegacyMetadataOmit
+	// DockerArchiveLegacyMetadataOnly writes only the legacy metadata, without manifest.json, for very old consumers.
+	// Such archives can not be read by docker-archive: sources; this requires DockerArchiveFormatDockerSave, and can not be combined
+	// with SystemContext.DockerArchiveAppend.
+	DockerArchiveLegacyMetadataOnly
+)
+
+// DockerArchiveLayerCompression selects how docker-archive: and docker-daemon: destinations store layers.
+type DockerArchiveLayerCompression int
+
+const (
+	// DockerArchiveLayerCompressionNone stores layers uncompressed, like (docker save); this is compatible with all consumers.
+	DockerArchiveLayerCompressionNone DockerArchiveLayerCompression = iota
+	// DockerArchiveLayerCompressionPreserve stores layers as they are provided, including zstd and zstd:chunked layers, instead of
+	// decompressing them. Only recent versions of (docker load) accept archives with zstd layers.
+	DockerArchiveLayerCompressionPreserve
+	// DockerArchiveLayerCompressionGzip stores layers compressed using gzip, recompressing layers using other algorithms;
+	// this keeps the archive small while remaining compatible with older versions of (docker load).
+	DockerArchiveLayerCompressionGzip
+)
+
 // SystemContext allows parameterizing access to implicitly-accessed resources,
 // like configuration files in /etc and users' login state in their home directory.
 // Various components can share the same field only if their semantics is exactly
@@ -586,6 +752,8 @@ type SystemContext struct {
 	// resolving to Docker Hub in the Docker-compatible REST API of Podman; it should never be used outside this
 	// specific context.
 	PodmanOnlyShortNamesIgnoreRegistriesConfAndForceDockerHub bool
+	// If true, pkg/shortnames logs the short-name resolution audit trail (see shortnames.Resolved.Audit) as structured data.
+	ShortNameAuditLog bool
 	// If not "", overrides the default path for the registry authentication file, but only new format files
 	AuthFilePath string
 	// if not "", overrides the default path for the registry authentication file, but with the legacy format;
@@ -599,6 +767,10 @@ type SystemContext struct {
 	// This must not be set if AuthFilePath is set.
 	// Only credentials and credential helpers in this file apre processed, not any other configuration in this file.
 	DockerCompatAuthFilePath string
+	// If not empty, credential stores registered using pkg/docker/config.RegisterCredentialStore, as "scheme:instance" entries,
+	// which are consulted in order before any credential files or helpers when looking up credentials.
+	// They are not used when storing or removing credentials, or when listing all credentials.
+	CredentialStores []string
 	// If not "", overrides the use of platform.GOARCH when choosing an image or verifying architecture match.
 	ArchitectureChoice string
 	// If not "", overrides the use of platform.GOOS when choosing an image or verifying OS match.
@@ -609,6 +781,65 @@ type SystemContext struct {
 	BlobInfoCacheDir string
 	// Additional tags when creating or copying a docker-archive.
 	DockerArchiveAdditionalTags []reference.NamedTagged
+	// If true, docker-archive: and docker-daemon: destinations also make every blob available at blobs/<algorithm>/<encoded digest>
+	// (as a hard link), like archives created by recent versions of Docker; this allows e.g. containerd to import the archive.
+	DockerArchiveDigestPathLinks bool
+	// If true, docker-archive: and docker-daemon: destinations accept layers concurrently (see copy.Options.MaxParallelDownloads):
+	// each layer is first written to a temporary file (see BigFilesTemporaryDir), and only copying it into the archive is serialized.
+	// This uses more temporary disk space.
+	DockerArchiveStageLayers bool
+	// If true, docker-archive: and docker-daemon: destinations accept multi-platform images (see copy.Options.ImageListSelection): all copied instances and
+	// the manifest list are stored in the OCI image layout, with tags referring to the manifest list, which recent versions of (docker load) accept.
+	// This requires a DockerArchiveFormat which writes an OCI image layout. docker-archive: sources read such tags as manifest lists.
+	DockerArchiveMultiPlatform bool
+	// Selects the metadata written by docker-archive: and docker-daemon: destinations; the default is DockerArchiveFormatDockerSave.
+	DockerArchiveFormat DockerArchiveFormat
+	// Selects whether docker-archive: and docker-daemon: destinations write the legacy (docker save) metadata;
+	// the default is DockerArchiveLegacyMetadataInclude.
+	DockerArchiveLegacyMetadata DockerArchiveLegacyMetadata
+	// Selects how docker-archive: and docker-daemon: destinations store layers; the default is DockerArchiveLayerCompressionNone.
+	DockerArchiveLayerCompression DockerArchiveLayerCompression
+	// If true, docker-archive: destinations referring to an existing, uncompressed archive add images to it (preserving the images
+	// already present) instead of failing. The archive must have been created by this library; the OCI image layout and digest path links
+	// are written if the existing archive contains them. The archive is modified in place, so it is left incomplete if writing fails.
+	DockerArchiveAppend bool
+	// If true, docker-archive: destinations create reproducible archives: the archive depends only on the images added, and their order,
+	// not on the order in which their blobs are copied. All entries are held (large ones in temporary files, see BigFilesTemporaryDir)
+	// until the archive is closed, and then written sorted by path. This can not be combined with DockerArchiveAppend.
+	DockerArchiveDeterministic bool
+	// If true, docker-archive: and docker-daemon: destinations record the descriptors of non-distributable (“foreign”) layers, including their URLs, in manifest.json,
+	// like (docker save), so that (docker load) keeps treating them as foreign layers. The URLs are only known if the layers are not decompressed
+	// or recompressed when copying, e.g. with DockerArchiveLayerCompressionPreserve (and without copy.Options.DownloadForeignLayers).
+	DockerArchiveForeignLayerSources bool
+	// If not nil, docker-archive: and docker-daemon: destinations compress the whole archive using this algorithm (e.g. gzip or zstd), which (docker load) accepts;
+	// docker-archive: sources decompress such archives automatically. DockerArchiveCompressionLevel, if not nil, is the compression level to use.
+	// This can not be combined with ArchiveSeekableZstd or DockerArchiveAppend.
+	DockerArchiveCompression      *compression.Algorithm
+	DockerArchiveCompressionLevel *int
+	// If true, docker-archive: destinations written to a regular file end with an index of the offsets of all archive entries,
+	// which allows docker-archive: sources to read individual images without scanning the whole archive; (docker load) ignores the index.
+	// This can not be combined with DockerArchiveCompression or ArchiveSeekableZstd (which already provides an index).
+	DockerArchiveEntryIndex bool
+	// If true, docker-archive: and oci-archive: destinations are written as a seekable zstd stream with an index of
+	// the archive entries, which allows reading individual blobs without decompressing the whole archive.
+	// docker-archive: and oci-archive: sources detect such archives automatically, and read blobs directly from them
+	// (oci-archive: sources only extract index.json and oci-layout to a temporary directory).
+	// The result is a valid zstd-compressed tar archive, so it can also be consumed by tools unaware of the index.
+	ArchiveSeekableZstd bool
+	// If true, oci-archive: destinations write blobs directly into the archive as they are received (each blob is only
+	// staged in a temporary file while its digest is verified), instead of creating a complete temporary OCI layout and archiving it
+	// when the image is committed; this avoids storing and reading the whole image twice, e.g. when saving images from containers-storage.
+	// The archive file is created immediately, and removed if the image is not committed.
+	// This can not be combined with setting the timestamp of the archive contents (copy.Options.DestinationTimestamp).
+	OCIArchiveDirectWrite bool
+	// If not nil, and ArchiveProgressInterval is not 0, progress of writing or extracting docker-archive: and oci-archive: archives
+	// (which can take a long time for large images, outside of copying individual blobs) is reported to ArchiveProgress
+	// using the ProgressEventArchive* events, at most once per ArchiveProgressInterval.
+	// docker-daemon: destinations also report writing the archive sent to the docker engine, and the engine’s progress
+	// of loading it using the ProgressEventDaemonLoad* events.
+	// copy.Image sets this to copy.Options.Progress, if not set by the caller.
+	ArchiveProgress         chan ProgressProperties
+	ArchiveProgressInterval time.Duration
 	// If not "", overrides the temporary directory to use for storing big files
 	BigFilesTemporaryDir string
 
@@ -621,8 +852,24 @@ type SystemContext struct {
 	OCIInsecureSkipTLSVerify bool
 	// If not "", use a shared directory for storing blobs rather than within OCI layouts
 	OCISharedBlobDirPath string
+	// If set, blobs of OCI layouts are stored using this interface instead of in the filesystem; OCISharedBlobDirPath is ignored.
+	OCILayoutBlobStore OCILayoutBlobStore
 	// Allow UnCompress image layer for OCI image layer
 	OCIAcceptUncompressedLayers bool
+	// If > 0, partial pulls from OCI layouts read the blob in units of up to this many bytes, coalescing nearby
+	// chunks into a single read and reading ahead within larger chunks; this avoids many small reads on
+	// high-latency (e.g. network) filesystems. If < 0, every chunk is read separately, without read-ahead.
+	// If 0, a default is used.
+	OCIGetBlobAtPrefetchWindow int64
+	// If true, every change of index.json of an OCI layout records the previous contents as a snapshot,
+	// so that accidentally overwritten or deleted tags can be recovered; see layout.ListIndexSnapshots and layout.RestoreIndexSnapshot.
+	// Blobs used by snapshots are never deleted by DeleteImage.
+	OCIIndexSnapshots bool
+	// If true, blobs written to OCI layouts are protected using fs-verity, where the filesystem supports it, and their fs-verity
+	// digest is recorded in an extended attribute; reading a blob with a recorded digest fails if the file no longer has that digest.
+	// The kernel verifies fs-verity files on every read, so this detects corruption of long-lived layouts without re-hashing blobs.
+	// fs-verity files can’t be modified, but can be deleted. Ignored if OCILayoutBlobStore is set.
+	OCIBlobVerity bool
 
 	// === docker.Transport overrides ===
 	// If not "", a directory containing a CA certificate (ending with ".crt"),
@@ -634,13 +881,48 @@ type SystemContext struct {
 	DockerPerHostCertDirPath string
 	// Allow contacting container registries over HTTP, or HTTPS with failed TLS verification. Note that this does not affect other TLS connections.
 	DockerInsecureSkipTLSVerify OptionalBool
+	// If true, every connection made when talking to container registries (including token servers, redirects and lookaside signature storage)
+	// must use HTTPS with verified certificates: plaintext HTTP is refused, and the insecure settings of registries in registries.conf are ignored.
+	// This can not be combined with DockerInsecureSkipTLSVerify == OptionalBoolTrue.
+	DockerStrictTLS bool
 	// if nil, the library tries to parse ~/.docker/config.json to retrieve credentials
 	// Ignored if DockerBearerRegistryToken is non-empty.
 	DockerAuthConfig *DockerAuthConfig
 	// if not "", the library uses this registry token to authenticate to the registry
 	DockerBearerRegistryToken string
+	// If set, credentials for registries are obtained from this provider when they are needed, instead of using DockerAuthConfig
+	// or the credentials stored in the auth files. Ignored if DockerBearerRegistryToken is non-empty.
+	DockerTokenProvider DockerTokenProvider
+	// If set, bearer tokens obtained from registry token servers are shared using this cache, instead of the default
+	// process-wide in-memory cache. Ignored if DockerDisableSharedTokenCache.
+	DockerTokenCache DockerTokenCache
+	// If not "", and DockerTokenCache is not set, bearer tokens are also recorded in this directory, so that they can be reused
+	// across process invocations until they expire. The directory should only be accessible to the user owning the credentials.
+	DockerTokenCacheDir string
+	// If true, bearer tokens are not shared across registry connections; each connection obtains its own tokens.
+	DockerDisableSharedTokenCache bool
+	// If set, requests sent to the registry host are signed using this instead of authenticating with DockerAuthConfig
+	// or DockerBearerRegistryToken. Requests to other hosts (e.g. redirects to pre-signed storage URLs) are not signed.
+	DockerRequestSigner DockerRequestSigner
+	// If set, every request sent to the registry host waits for this rate limiter first (e.g. to stay under known registry rate limits
+	// with highly parallel operations). Requests to other hosts (e.g. redirects to pre-signed storage URLs) are not limited.
+	DockerRequestRateLimiter DockerRequestRateLimiter
+	// If set, requests to the registry host are executed by this backend instead of being sent over HTTP(S),
+	// and the backend authenticates them instead of DockerAuthConfig, DockerBearerRegistryToken or DockerRequestSigner.
+	// Warning: This is experimental; see DockerRegistryBackend.
+	DockerRegistryBackend DockerRegistryBackend
 	// if not "", an User-Agent header is added to each request when contacting a registry.
 	DockerRegistryUserAgent string
+	// If not empty, product tokens (e.g. "skopeo/1.16.0"), listed in decreasing order of significance, which precede the
+	// User-Agent value (DockerRegistryUserAgent, or the default) in each request when contacting a registry.
+	DockerRegistryUserAgentProducts []string
+	// Additional headers (e.g. tenant tokens for a proxy in front of the registry) added to each request sent to the registry host,
+	// including redirects to the same host. They are not sent to other hosts, such as token servers or redirect targets
+	// (e.g. pre-signed object storage URLs), because they may contain credentials.
+	DockerRegistryHeaders http.Header
+	// Additional headers which do not contain credentials (e.g. correlation IDs) added to all requests made by the docker transport,
+	// including requests to token servers, lookaside signature storage, and redirect targets.
+	DockerTraceHeaders http.Header
 	// if true, a V1 ping attempt isn't done to give users a better error. Default is false.
 	// Note that this field is used mainly to integrate containers/image into projectatomic/docker
 	// in order to not break any existing docker's integration tests.
@@ -650,14 +932,66 @@ type SystemContext struct {
 	DockerDisableDestSchema1MIMETypes bool
 	// If true, the physical pull source of docker transport images logged as info level
 	DockerLogMirrorChoice bool
+	// If true, registry mirrors are always tried in the order listed in registries.conf. By default, mirrors which failed recently
+	// within this process are tried last, and other mirrors are tried in order of their latency observed within this process.
+	// The primary location is always tried after all mirrors.
+	DockerDisableMirrorReordering bool
 	// Directory to use for OSTree temporary files
 	OSTreeTmpDirPath string
 	// If true, all blobs will have precomputed digests to ensure layers are not uploaded that already exist on the registry.
 	// Note that this requires writing blobs to temporary files, and takes more time than the default behavior,
 	// when the digest for a blob is unknown.
 	DockerRegistryPushPrecomputeDigests bool
+	// If not 0, blobs larger than this many bytes are pushed to registries using a sequence of PATCH requests, each uploading at most
+	// this many bytes (adjusted to the limits advertised by the registry), instead of a single request. Each chunk is buffered in memory.
+	// By default, blobs are only uploaded in chunks if the registry requires it.
+	DockerUploadChunkSize int64
+	// If not nil, uploads of blob chunks which fail due to network errors or transient server errors are retried according to this policy,
+	// resuming from the data the registry reports to have received. Note that blobs uploaded in a single request are never retried;
+	// set DockerUploadChunkSize to make large uploads resumable.
+	DockerUploadRetryPolicy *DockerRetryPolicy
 	// DockerProxyURL specifies proxy configuration schema (like socks5://username:password@ip:port)
 	DockerProxyURL *url.URL
+	// If true, manifests fetched from registries are not recorded in, or revalidated against, the process-wide
+	// manifest cache (which uses ETag / If-None-Match to avoid re-downloading unchanged manifests).
+	DockerDisableManifestCache bool
+	// If not "", manifests and small blobs (such as configs) fetched from registries are cached in this directory,
+	// so that they can be reused across process invocations. Content fetched by digest is used without contacting
+	// the registry; manifests fetched by tag are reused while fresh per Cache-Control, and otherwise revalidated using their ETag.
+	// The directory should only be accessible to users allowed to read all of the cached images.
+	DockerHTTPCacheDir string
+	// When fetching chunks of a blob (for partial pulls), chunks separated by at most this many bytes are fetched
+	// as a single HTTP range, trading downloading the unneeded data in between for fewer ranges. Adjacent chunks are always merged.
+	DockerBlobChunkCoalesceGap uint64
+	// If not 0, the maximum number of HTTP ranges requested by a single request when fetching chunks of a blob;
+	// more ranges are fetched using several sequential requests. Useful for registries which reject, or rate-limit, requests with many ranges.
+	DockerBlobChunkMaxRangesPerRequest int
+	// If not 0, a blob found missing in a destination repository is recorded in the blob info cache, and assumed to still be missing
+	// for this long without checking the registry again (e.g. in tight mirroring loops which repeatedly copy to the same repositories).
+	// The record is discarded when the blob is pushed to, or mounted into, that repository.
+	DockerBlobAbsenceCacheTTL time.Duration
+	// If not empty, the manifest MIME types requested from registries (in the Accept header), in order of preference,
+	// instead of manifest.DefaultRequestedManifestMIMETypes; fetching a manifest of any other MIME type fails with
+	// docker.UnacceptableManifestMIMETypeError. E.g. this allows refusing Docker schema1 manifests.
+	DockerRequestedManifestMIMETypes []string
+	// If true, when a registry does not serve a blob but points at its canonical location in a different repository or registry
+	// (using a `Link: <https://…/v2/…/blobs/…>; rel="canonical"` response header, e.g. from an upstream cache), the blob is fetched
+	// from that location, verified against its digest, and the location is recorded in the blob info cache.
+	// Registries blocked in registries.conf are not contacted; sys.DockerAuthConfig is only sent to the original registry.
+	DockerFollowBlobLocationHints bool
+	// If not 0, the maximum number of connections to a single registry host (including connections in use and idle ones);
+	// further requests wait for a connection to become available. By default, the number of connections is not limited.
+	DockerMaxConnsPerHost int
+	// If not 0, the maximum number of idle connections to a single registry host kept for reuse. If 0, DockerMaxConnsPerHost is used
+	// if set, and otherwise only a few idle connections are kept, so parallel transfers may frequently open new connections.
+	DockerMaxIdleConnsPerHost int
+	// If not 0, how long idle connections to registries are kept for reuse, instead of a default of 90 seconds.
+	DockerIdleConnTimeout time.Duration
+	// Whether connections to registries use HTTP/2, if the registry supports it. If OptionalBoolFalse, HTTP/1.1 is always used, so that
+	// parallel transfers use separate connections instead of sharing a single HTTP/2 connection (together with DockerMaxConnsPerHost, this
+	// can better utilize high-throughput registries and mirrors). If OptionalBoolUndefined, HTTP/2 is not used either, unless enabled
+	// process-wide via GODEBUG, or by a proxy.
+	DockerAttemptHTTP2 OptionalBool
 
 	// === docker/daemon.Transport overrides ===
 	// A directory containing a CA certificate (ending with ".crt"),
@@ -668,12 +1002,24 @@ type SystemContext struct {
 	DockerDaemonHost string
 	// Used to skip TLS verification, off by default. To take effect DockerDaemonCertPath needs to be specified as well.
 	DockerDaemonInsecureSkipTLSVerify bool
+	// Whether images are exchanged with the Docker daemon using OCI image layouts (as created by (docker save) of Docker 25 and later)
+	// instead of only the legacy (docker save) format. If OptionalBoolUndefined, this is used if the daemon supports it (API version 1.44 or later).
+	// Destinations then accept OCI manifests and send them to the daemon unmodified, preserving media types and annotations,
+	// instead of converting images to Docker schema2; sources return the OCI manifest of the exported image, if the daemon includes one.
+	// This can not be combined with DockerArchiveLegacyMetadataOnly.
+	DockerDaemonOCIArchive OptionalBool
 
 	// === dir.Transport overrides ===
 	// DirForceCompress compresses the image layers if set to true
 	DirForceCompress bool
 	// DirForceDecompress decompresses the image layers if set to true
 	DirForceDecompress bool
+	// DirIntegrity, if set, makes dir: destinations write an integrity manifest listing every stored file with its digest,
+	// and makes dir: sources require the integrity manifest and verify every file they read against it.
+	DirIntegrity bool
+	// DirIntegrityKey, if not empty, implies DirIntegrity, and the integrity manifest is authenticated using HMAC-SHA256 with this key;
+	// dir: sources reject integrity manifests not authenticated with this key.
+	DirIntegrityKey []byte
 
 	// CompressionFormat is the format to use for the compression of the blobs
 	CompressionFormat *compression.Algorithm
@@ -700,6 +1046,27 @@ const (
 	// ProgressEventSkipped is fired when the artifact has been skipped because
 	// its already available at the destination
 	ProgressEventSkipped
+
+	// ProgressEventArchivePacking indicates that an archive (e.g. oci-archive: or docker-archive:)
+	// is currently being written; Artifact is not set.
+	ProgressEventArchivePacking
+
+	// ProgressEventArchivePacked is fired when writing an archive has been finished
+	ProgressEventArchivePacked
+
+	// ProgressEventArchiveUnpacking indicates that an archive (e.g. oci-archive: or docker-archive:)
+	// is currently being extracted; Artifact is not set.
+	ProgressEventArchiveUnpacking
+
+	// ProgressEventArchiveUnpacked is fired when extracting an archive has been finished
+	ProgressEventArchiveUnpacked
+
+	// ProgressEventDaemonLoading indicates that the docker engine is loading an image
+	// written by a docker-daemon: destination; Artifact is not set.
+	ProgressEventDaemonLoading
+
+	// ProgressEventDaemonLoaded is fired when the docker engine has successfully finished loading an image
+	ProgressEventDaemonLoaded
 )
 
 // ProgressProperties is used to pass information from the copy code to a monitor which
@@ -718,4 +1085,21 @@ type ProgressProperties struct {
 	// The additional offset which has been downloaded inside the last update
 	// interval. Will be reset after each ProgressEventRead event.
 	OffsetUpdate uint64
+
+	// The number of archive entries processed so far, for the ProgressEventArchive* events
+	ArchiveEntries uint64
+
+	// For the ProgressEventArchivePacking events of docker-archive: destinations, the path of the archive entry
+	// being written, the number of bytes of its contents written so far, and the size of its contents.
+	ArchiveEntryPath   string
+	ArchiveEntryOffset uint64
+	ArchiveEntrySize   uint64
+
+	// For the ProgressEventDaemonLoading events, the status reported by the docker engine (e.g. "Loading layer"),
+	// the ID of the item (typically a shortened layer ID) the status applies to, if any, and the progress of processing the item,
+	// in units chosen by the engine (both 0 if not reported).
+	DaemonLoadStatus  string
+	DaemonLoadID      string
+	DaemonLoadCurrent uint64
+	DaemonLoadTotal   uint64
 }